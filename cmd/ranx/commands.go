@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aliuygur/n8n-saas-api/pkg/ranxapi"
+	"golang.org/x/term"
+)
+
+func runLogin(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	cmd := newCommand("login")
+	token := cmd.flags.String("token", "", "personal access token, prompted for when empty")
+	apiURL := cmd.flags.String("api-url", "", "API base URL, defaults to https://ranx.cloud")
+	if err := cmd.parse(args); err != nil {
+		return err
+	}
+
+	if *token == "" {
+		fmt.Fprint(os.Stderr, "Create a token at https://ranx.cloud/account and paste it here: ")
+		secret, err := readSecret(stdin)
+		if err != nil {
+			return err
+		}
+		*token = secret
+	}
+	if *token == "" {
+		return errors.New("no token given")
+	}
+
+	// Any answer but 401 means the token is valid, it may just lack the instances:read scope
+	c := ranxapi.NewClient(ranxapi.Config{Token: *token, BaseURL: *apiURL})
+	if _, err := c.ListInstances(ctx); err != nil {
+		var apiErr *ranxapi.Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode == http.StatusUnauthorized {
+			return fmt.Errorf("failed to verify token: %w", err)
+		}
+	}
+
+	path, err := saveConfig(&config{APIURL: *apiURL, Token: *token})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Logged in, token saved to %s\n", path)
+	return nil
+}
+
+// readSecret reads a line from stdin without echoing it on terminals
+func readSecret(stdin io.Reader) (string, error) {
+	if f, ok := stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		b, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read token: %w", err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read token: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func runLogout(stdout io.Writer) error {
+	path, err := removeConfig()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Logged out, removed %s\n", path)
+	fmt.Fprintln(stdout, "The token itself stays valid until you revoke it on the account page.")
+	return nil
+}
+
+func runInstances(ctx context.Context, sub string, args []string, stdin io.Reader, stdout io.Writer) error {
+	switch sub {
+	case "list":
+		cmd := newCommand("instances list")
+		if err := cmd.parse(args); err != nil {
+			return err
+		}
+		c, err := client()
+		if err != nil {
+			return err
+		}
+		list, err := c.ListInstances(ctx)
+		if err != nil {
+			return err
+		}
+		return printInstances(stdout, cmd.output, list.Instances)
+
+	case "get":
+		cmd := newCommand("instances get")
+		if err := cmd.parse(args, "id"); err != nil {
+			return err
+		}
+		c, err := client()
+		if err != nil {
+			return err
+		}
+		instance, err := c.GetInstance(ctx, cmd.flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		if cmd.output == "json" {
+			return printJSON(stdout, instance)
+		}
+		return printInstances(stdout, cmd.output, []ranxapi.Instance{*instance})
+
+	case "create":
+		cmd := newCommand("instances create")
+		if err := cmd.parse(args, "subdomain"); err != nil {
+			return err
+		}
+		c, err := client()
+		if err != nil {
+			return err
+		}
+		instance, err := c.CreateInstance(ctx, ranxapi.CreateInstanceRequest{Subdomain: cmd.flags.Arg(0)})
+		if err != nil {
+			return err
+		}
+		if cmd.output == "json" {
+			return printJSON(stdout, instance)
+		}
+		fmt.Fprintf(stdout, "Creating %s (%s), it will be ready in a few minutes\n", instance.URL, instance.ID)
+		return nil
+
+	case "delete":
+		cmd := newCommand("instances delete")
+		yes := cmd.flags.Bool("yes", false, "skip the confirmation prompt")
+		if err := cmd.parse(args, "id"); err != nil {
+			return err
+		}
+		c, err := client()
+		if err != nil {
+			return err
+		}
+		id := cmd.flags.Arg(0)
+		if !*yes {
+			instance, err := c.GetInstance(ctx, id)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Delete %s and all of its workflows? Type the subdomain to confirm: ", instance.URL)
+			answer, err := bufio.NewReader(stdin).ReadString('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				return err
+			}
+			if strings.TrimSpace(answer) != instance.Subdomain {
+				return errors.New("aborted")
+			}
+		}
+		if err := c.DeleteInstance(ctx, id); err != nil {
+			return err
+		}
+		if cmd.output == "json" {
+			return printJSON(stdout, map[string]string{"id": id, "status": "deleted"})
+		}
+		fmt.Fprintf(stdout, "Deleted %s\n", id)
+		return nil
+
+	case "restart":
+		cmd := newCommand("instances restart")
+		if err := cmd.parse(args, "id"); err != nil {
+			return err
+		}
+		c, err := client()
+		if err != nil {
+			return err
+		}
+		id := cmd.flags.Arg(0)
+		if err := c.RestartInstance(ctx, id); err != nil {
+			return err
+		}
+		if cmd.output == "json" {
+			return printJSON(stdout, map[string]string{"id": id, "status": "restarting"})
+		}
+		fmt.Fprintf(stdout, "Restarting %s\n", id)
+		return nil
+
+	case "logs":
+		cmd := newCommand("instances logs")
+		tail := cmd.flags.Int("tail", 100, "number of lines to print, at most 500")
+		if err := cmd.parse(args, "id"); err != nil {
+			return err
+		}
+		c, err := client()
+		if err != nil {
+			return err
+		}
+		logs, err := c.GetInstanceLogs(ctx, cmd.flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		lines := logs.Lines
+		if *tail >= 0 && len(lines) > *tail {
+			lines = lines[len(lines)-*tail:]
+		}
+		if cmd.output == "json" {
+			return printJSON(stdout, ranxapi.InstanceLogs{Lines: lines})
+		}
+		for _, line := range lines {
+			fmt.Fprintln(stdout, line)
+		}
+		return nil
+
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command instances %q\n\n%s", sub, usage)
+		return errUsage
+	}
+}
+
+func printInstances(w io.Writer, output string, instances []ranxapi.Instance) error {
+	if output == "json" {
+		return printJSON(w, instances)
+	}
+
+	rows := make([][]string, 0, len(instances))
+	for _, instance := range instances {
+		rows = append(rows, []string{
+			instance.ID,
+			instance.Subdomain,
+			instance.Status,
+			instance.AppVersion,
+			instance.URL,
+			formatTime(&instance.CreatedAt),
+		})
+	}
+	return printTable(w, []string{"ID", "SUBDOMAIN", "STATUS", "VERSION", "URL", "CREATED"}, rows)
+}
+
+func runSubscription(ctx context.Context, args []string, stdout io.Writer) error {
+	cmd := newCommand("subscription")
+	if err := cmd.parse(args); err != nil {
+		return err
+	}
+	c, err := client()
+	if err != nil {
+		return err
+	}
+	sub, err := c.GetSubscription(ctx)
+	if err != nil {
+		return err
+	}
+	if cmd.output == "json" {
+		return printJSON(stdout, sub)
+	}
+
+	trial := "no"
+	if sub.Trial {
+		trial = "until " + formatTime(sub.TrialEndsAt)
+	}
+	return printTable(stdout, []string{"STATUS", "INSTANCES", "TRIAL", "SINCE"}, [][]string{{
+		sub.Status,
+		strconv.Itoa(int(sub.Quantity)),
+		trial,
+		formatTime(&sub.CreatedAt),
+	}})
+}
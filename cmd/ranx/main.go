@@ -0,0 +1,117 @@
+// Command ranx manages ranx.cloud instances from the terminal using the public API
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/aliuygur/n8n-saas-api/pkg/ranxapi"
+)
+
+const usage = `Usage: ranx <command> [flags]
+
+Commands:
+  login                         Save a personal access token
+  logout                        Forget the saved token
+  instances list                List your instances
+  instances get <id>            Show an instance
+  instances create <subdomain>  Create an instance
+  instances delete <id>         Delete an instance and its data
+  instances restart <id>        Restart the n8n pods of an instance
+  instances logs <id>           Print the recent logs of an instance
+  subscription                  Show your subscription
+
+Every command accepts -o table|json. RANX_TOKEN and RANX_API_URL override the saved login.
+`
+
+// errUsage is returned for invalid arguments, the usage has already been printed
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		if !errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return errUsage
+	}
+
+	switch args[0] {
+	case "login":
+		return runLogin(ctx, args[1:], stdin, stdout)
+	case "logout":
+		return runLogout(stdout)
+	case "instances":
+		if len(args) < 2 {
+			fmt.Fprint(os.Stderr, usage)
+			return errUsage
+		}
+		return runInstances(ctx, args[1], args[2:], stdin, stdout)
+	case "subscription":
+		return runSubscription(ctx, args[1:], stdout)
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", args[0], usage)
+		return errUsage
+	}
+}
+
+// command holds the flags every command shares
+type command struct {
+	flags  *flag.FlagSet
+	output string
+}
+
+func newCommand(name string) *command {
+	c := &command{flags: flag.NewFlagSet("ranx "+name, flag.ContinueOnError)}
+	c.flags.StringVar(&c.output, "o", "table", "output format, table or json")
+	return c
+}
+
+// parse parses flags and checks the number of positional arguments
+func (c *command) parse(args []string, positional ...string) error {
+	if err := c.flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if c.output != "table" && c.output != "json" {
+		return fmt.Errorf("unknown output format %q, use table or json", c.output)
+	}
+	if c.flags.NArg() != len(positional) {
+		fmt.Fprintf(os.Stderr, "Usage: %s", c.flags.Name())
+		for _, name := range positional {
+			fmt.Fprintf(os.Stderr, " <%s>", name)
+		}
+		fmt.Fprintln(os.Stderr, " [flags]")
+		c.flags.PrintDefaults()
+		return errUsage
+	}
+	return nil
+}
+
+// client returns an API client for the saved login
+func client() (*ranxapi.Client, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Token == "" {
+		return nil, errors.New("not logged in, run ranx login")
+	}
+	return ranxapi.NewClient(ranxapi.Config{Token: cfg.Token, BaseURL: cfg.APIURL}), nil
+}
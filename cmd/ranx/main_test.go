@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/pkg/ranxapi"
+)
+
+const testToken = "ranx_test"
+
+// fakeAPI serves the instance endpoints of the JSON API for one instance
+type fakeAPI struct {
+	*httptest.Server
+	instance ranxapi.Instance
+	deleted  []string
+}
+
+func newFakeAPI(t *testing.T) *fakeAPI {
+	api := &fakeAPI{instance: ranxapi.Instance{
+		ID:         "inst-1",
+		Subdomain:  "acme",
+		Status:     "deployed",
+		AppVersion: "1.80.0",
+		URL:        "https://acme.ranx.cloud",
+		CreatedAt:  time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC),
+	}}
+
+	writeJSON := func(w http.ResponseWriter, status int, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(v)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/instances", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, ranxapi.InstanceList{Instances: []ranxapi.Instance{api.instance}})
+	})
+	mux.HandleFunc("GET /api/v1/instances/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != api.instance.ID {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": ranxapi.Error{Code: "NotFound", Message: "instance not found"}})
+			return
+		}
+		writeJSON(w, http.StatusOK, api.instance)
+	})
+	mux.HandleFunc("DELETE /api/v1/instances/{id}", func(w http.ResponseWriter, r *http.Request) {
+		api.deleted = append(api.deleted, r.PathValue("id"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /api/v1/instances/{id}/logs", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, ranxapi.InstanceLogs{Lines: []string{"one", "two", "three"}})
+	})
+
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+testToken {
+			writeJSON(w, http.StatusUnauthorized, map[string]any{"error": ranxapi.Error{Code: "Unauthorized", Message: "invalid API token"}})
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(api.Close)
+	return api
+}
+
+// isolateConfig keeps the saved login of the tests out of the user's config directory
+func isolateConfig(t *testing.T) string {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("RANX_TOKEN", "")
+	t.Setenv("RANX_API_URL", "")
+	path, err := configPath()
+	if err != nil {
+		t.Fatalf("Failed to find config path: %v", err)
+	}
+	return path
+}
+
+func TestRunLogin(t *testing.T) {
+	api := newFakeAPI(t)
+
+	t.Run("valid token is saved", func(t *testing.T) {
+		path := isolateConfig(t)
+
+		var stdout bytes.Buffer
+		err := run(context.Background(), []string{"login", "-api-url", api.URL}, strings.NewReader(testToken+"\n"), &stdout)
+		if err != nil {
+			t.Fatalf("Expected login to succeed, got %v", err)
+		}
+		if !strings.Contains(stdout.String(), path) {
+			t.Errorf("Expected the config path in the output, got %q", stdout.String())
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if cfg.Token != testToken || cfg.APIURL != api.URL {
+			t.Errorf("Expected the token and API URL to be saved, got %+v", cfg)
+		}
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+			t.Errorf("Expected the config to be readable only by the user, got %v %v", info.Mode(), err)
+		}
+	})
+
+	t.Run("rejected token is not saved", func(t *testing.T) {
+		path := isolateConfig(t)
+
+		err := run(context.Background(), []string{"login", "-api-url", api.URL, "-token", "ranx_wrong"}, strings.NewReader(""), &bytes.Buffer{})
+		var apiErr *ranxapi.Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("Expected an unauthorized error, got %v", err)
+		}
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Expected no config to be written, got %v", err)
+		}
+	})
+
+	t.Run("logout removes the saved token", func(t *testing.T) {
+		path := isolateConfig(t)
+		if _, err := saveConfig(&config{APIURL: api.URL, Token: testToken}); err != nil {
+			t.Fatalf("Failed to save config: %v", err)
+		}
+
+		if err := run(context.Background(), []string{"logout"}, nil, &bytes.Buffer{}); err != nil {
+			t.Fatalf("Expected logout to succeed, got %v", err)
+		}
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Expected the config to be removed, got %v", err)
+		}
+	})
+}
+
+func TestRunInstances(t *testing.T) {
+	api := newFakeAPI(t)
+	isolateConfig(t)
+	t.Setenv("RANX_TOKEN", testToken)
+	t.Setenv("RANX_API_URL", api.URL)
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "list as table",
+			args: []string{"instances", "list"},
+			want: []string{"ID", "SUBDOMAIN", "inst-1", "acme", "deployed", "1.80.0"},
+		},
+		{
+			name: "get as json",
+			args: []string{"instances", "get", "-o", "json", "inst-1"},
+			want: []string{`"id": "inst-1"`, `"subdomain": "acme"`},
+		},
+		{
+			name: "logs are tailed",
+			args: []string{"instances", "logs", "-tail", "2", "inst-1"},
+			want: []string{"two\nthree\n"},
+		},
+		{
+			name:    "missing argument",
+			args:    []string{"instances", "get"},
+			wantErr: errUsage,
+		},
+		{
+			name:    "unknown subcommand",
+			args:    []string{"instances", "scale", "inst-1"},
+			wantErr: errUsage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			err := run(context.Background(), tt.args, strings.NewReader(""), &stdout)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected success, got %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("Expected output to contain %q, got:\n%s", want, stdout.String())
+				}
+			}
+		})
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		err := run(context.Background(), []string{"instances", "get", "inst-2"}, nil, &bytes.Buffer{})
+		var apiErr *ranxapi.Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected a not found error, got %v", err)
+		}
+	})
+}
+
+func TestRunInstancesDelete(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		stdin       string
+		wantErr     bool
+		wantDeleted bool
+	}{
+		{name: "confirmed", args: []string{"inst-1"}, stdin: "acme\n", wantDeleted: true},
+		{name: "wrong subdomain", args: []string{"inst-1"}, stdin: "other\n", wantErr: true},
+		{name: "no answer", args: []string{"inst-1"}, stdin: "", wantErr: true},
+		{name: "skip confirmation", args: []string{"-yes", "inst-1"}, wantDeleted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newFakeAPI(t)
+			isolateConfig(t)
+			t.Setenv("RANX_TOKEN", testToken)
+			t.Setenv("RANX_API_URL", api.URL)
+
+			var stdout bytes.Buffer
+			err := run(context.Background(), append([]string{"instances", "delete"}, tt.args...), strings.NewReader(tt.stdin), &stdout)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if deleted := len(api.deleted) == 1 && api.deleted[0] == "inst-1"; deleted != tt.wantDeleted {
+				t.Errorf("Expected deleted %v, got deletes %v", tt.wantDeleted, api.deleted)
+			}
+		})
+	}
+}
+
+func TestRunRequiresLogin(t *testing.T) {
+	isolateConfig(t)
+
+	err := run(context.Background(), []string{"instances", "list"}, nil, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "ranx login") {
+		t.Errorf("Expected a not logged in error, got %v", err)
+	}
+}
+
+func TestLoadConfigEnvOverrides(t *testing.T) {
+	path := isolateConfig(t)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"api_url":"https://saved.example","token":"ranx_saved"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("RANX_TOKEN", "ranx_env")
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Token != "ranx_env" || cfg.APIURL != "https://saved.example" {
+		t.Errorf("Expected RANX_TOKEN to override only the token, got %+v", cfg)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -76,6 +77,44 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Background workers stop when the server shuts down
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	// Evict cached tenants when instances change on any replica
+	go h.RunCacheInvalidation(bgCtx)
+
+	// Bring instance routes in line with the routing mode
+	if err := svc.EnqueueRouteReconciliation(bgCtx); err != nil {
+		logger.Error("Failed to enqueue instance route reconciliation", "error", err)
+	}
+
+	// Clean up the caches of this replica along with the background jobs
+	if err := svc.RunOnEachReplica(services.JobCleanupCaches, handler.CacheCleanupInterval, h.CleanupCaches); err != nil {
+		logger.Error("Failed to register cache cleanup", "error", err)
+		os.Exit(1)
+	}
+
+	// Run background jobs such as trial expiry, drained before exiting
+	jobsDone := make(chan struct{})
+	go func() {
+		defer close(jobsDone)
+		svc.RunJobs(appctx.WithLogger(bgCtx, logger))
+	}()
+
+	// Serve metrics on a separate port so they are not exposed through the tunnel
+	if cfg.Server.MetricsPort != "" {
+		metricsAddr := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.MetricsPort)
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("GET /debug/vars", expvar.Handler())
+		go func() {
+			logger.Info("Starting metrics server", "addr", metricsAddr)
+			if err := http.ListenAndServe(metricsAddr, metricsMux); err != nil {
+				logger.Error("Metrics server failed", "error", err)
+			}
+		}()
+	}
+
 	// Setup HTTP router
 	mux := http.NewServeMux()
 
@@ -86,12 +125,13 @@ func main() {
 	// HostRouter must be applied before logger to properly route subdomain requests
 	addr := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
 	handler := appctx.Handler(h.HostRouter(mux), logger)
+	// Read and write deadlines are set per request by HostRouter so tenant
+	// streams can outlive the strict dashboard limits
 	server := &http.Server{
-		Addr:         addr,
-		Handler:      handler,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       60 * time.Second,
 	}
 
 	// Start server in a goroutine
@@ -109,6 +149,7 @@ func main() {
 	<-quit
 
 	logger.Info("Shutting down server...")
+	stopBackground()
 
 	// Graceful shutdown
 	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
@@ -119,5 +160,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Running jobs finish or are handed back to the queue for another replica
+	select {
+	case <-jobsDone:
+	case <-ctx.Done():
+		logger.Error("Background jobs did not drain in time")
+	}
+
 	logger.Info("Server exited gracefully")
 }
@@ -0,0 +1,16 @@
+package appctx
+
+import "context"
+
+type csrfTokenContextKey struct{}
+
+// WithCSRFToken adds the CSRF token of the request to the context, for rendering into pages
+func WithCSRFToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, csrfTokenContextKey{}, token)
+}
+
+// GetCSRFToken retrieves the CSRF token from the context. Returns "" if not found.
+func GetCSRFToken(ctx context.Context) string {
+	token, _ := ctx.Value(csrfTokenContextKey{}).(string)
+	return token
+}
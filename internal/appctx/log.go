@@ -30,6 +30,7 @@ func LoggerMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
 			}
 			reqLogger := logger.With(lo.ToAnySlice(attrs)...)
 			ctx := context.WithValue(r.Context(), loggerContextKey{}, reqLogger)
+			ctx = WithRequestID(ctx, requestID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -0,0 +1,35 @@
+package appctx
+
+import "context"
+
+type requestIDContextKey struct{}
+
+type clientContextKey struct{}
+
+// Client is the browser or program that sent a request
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+// WithRequestID adds the ID of the request to the context
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+// GetRequestID retrieves the request ID from the context. Returns "" if not found.
+func GetRequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
+
+// WithClient adds the client of the request to the context
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientContextKey{}, client)
+}
+
+// GetClient retrieves the client from the context. Returns the zero Client if not found.
+func GetClient(ctx context.Context) Client {
+	client, _ := ctx.Value(clientContextKey{}).(Client)
+	return client
+}
@@ -16,6 +16,7 @@ const (
 	CodeInternalError = "InternalError"
 	CodeConflict      = "Conflict"
 	CodeForbidden     = "Forbidden"
+	CodeRateLimited   = "RateLimited"
 
 	CodeInvalidSubdomain = "InvalidSubdomain"
 )
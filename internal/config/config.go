@@ -2,7 +2,10 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,9 +15,16 @@ type Config struct {
 	Server       ServerConfig
 	Database     DatabaseConfig
 	Google       GoogleConfig
+	GitHub       GitHubConfig
+	Microsoft    MicrosoftConfig
+	Mailer       MailerConfig
 	JWT          JWTConfig
 	Polar        PolarConfig
 	LemonSqueezy LemonSqueezyConfig
+	Routing      RoutingConfig
+	N8N          N8NConfig
+	Trial        TrialConfig
+	Monitor      MonitorConfig
 }
 
 // ServerConfig holds server configuration
@@ -23,6 +33,20 @@ type ServerConfig struct {
 	Host       string
 	APIBaseURL string // External API base URL (e.g., https://api.example.com)
 	Env        string // Environment: development, staging, production
+	// MetricsPort serves expvar metrics on /debug/vars when set, kept off the public port
+	MetricsPort string
+
+	// DashboardTimeout bounds reading and writing dashboard requests
+	DashboardTimeout time.Duration
+	// ProxyTimeout bounds proxied tenant requests such as long webhooks and uploads
+	ProxyTimeout time.Duration
+	// ProxyIdleTimeout closes proxied connections, including WebSockets, after this long without traffic.
+	// It applies to every upstream connection, so it can't be shorter than ProxyTimeout.
+	ProxyIdleTimeout time.Duration
+
+	// TrustedProxies are the networks of the proxies in front of the app, such as the Cloudflare tunnel.
+	// Client IP headers are only believed on connections from them.
+	TrustedProxies []netip.Prefix
 }
 
 // IsDevelopment returns true if the environment is development
@@ -59,6 +83,31 @@ type GoogleConfig struct {
 	RedirectURL  string
 }
 
+// GitHubConfig holds GitHub OAuth configuration, login with GitHub is disabled when empty
+type GitHubConfig struct {
+	ClientID     string
+	ClientSecret string
+}
+
+// MicrosoftConfig holds Microsoft OAuth configuration, login with Microsoft is disabled when empty.
+// The app registration must include the xms_edov optional claim so work account emails can be trusted.
+type MicrosoftConfig struct {
+	ClientID     string
+	ClientSecret string
+}
+
+// MailerConfig holds outgoing email configuration
+type MailerConfig struct {
+	Driver       string // smtp, resend, log (stdout) or file
+	From         string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	APIKey       string // API key of the resend driver
+	FileDir      string
+}
+
 // JWTConfig holds JWT configuration
 type JWTConfig struct {
 	Secret string
@@ -80,6 +129,73 @@ type LemonSqueezyConfig struct {
 	VariantID     string // Product variant ID for paid plan
 }
 
+// N8NConfig holds settings of the n8n instances we deploy
+type N8NConfig struct {
+	// OwnerSecret derives the password of the n8n owner account ranx logs users in with.
+	// Changing it locks ranx out of the editor of existing instances.
+	OwnerSecret string
+}
+
+// What happens to the instances of a trial once it ended and the grace period passed
+const (
+	// TrialExpiryHibernate stops the instances but keeps their data, subscribing starts them again
+	TrialExpiryHibernate = "hibernate"
+	// TrialExpiryDelete deletes the instances and their data
+	TrialExpiryDelete = "delete"
+)
+
+// TrialConfig holds settings of the free trial expiry job
+type TrialConfig struct {
+	ExpiryAction  string        // hibernate or delete
+	GracePeriod   time.Duration // How long instances keep running after the trial ended
+	CheckInterval time.Duration // How often the job looks for ending trials
+}
+
+// MonitorConfig holds settings of the instance uptime monitor
+type MonitorConfig struct {
+	CheckInterval time.Duration // How often every running instance is probed
+}
+
+// Tenant routing modes
+const (
+	// RoutingModeProxy sends all tenant traffic through the app's reverse proxy
+	RoutingModeProxy = "proxy"
+	// RoutingModeGateway emits a Gateway API HTTPRoute per instance so traffic goes to n8n directly.
+	// Instances with an access policy stay on the proxy, which also serves any traffic still reaching the app.
+	// The proxy's plan limits (rate, concurrency, body size) don't apply to routed instances,
+	// the Gateway has to enforce its own.
+	RoutingModeGateway = "gateway"
+)
+
+// RoutingConfig holds tenant traffic routing configuration
+type RoutingConfig struct {
+	Mode             string // proxy or gateway
+	GatewayName      string // Gateway the HTTPRoutes attach to
+	GatewayNamespace string
+	// TenantDomain is the parent domain of instance hostnames (e.g., ranx.cloud for acme.ranx.cloud)
+	TenantDomain string
+}
+
+// IsGateway returns true if instances are routed by the Gateway instead of the proxy
+func (r *RoutingConfig) IsGateway() bool {
+	return r.Mode == RoutingModeGateway
+}
+
+// InstanceHostname returns the public hostname of the instance with the subdomain
+func (r *RoutingConfig) InstanceHostname(subdomain string) string {
+	return subdomain + "." + r.TenantDomain
+}
+
+// InstanceSubdomain returns the subdomain of an instance hostname, and false for
+// any other host including the apex and www
+func (r *RoutingConfig) InstanceSubdomain(host string) (string, bool) {
+	subdomain, ok := strings.CutSuffix(host, "."+r.TenantDomain)
+	if !ok || subdomain == "" || subdomain == "www" {
+		return "", false
+	}
+	return subdomain, true
+}
+
 // Load loads configuration from environment variables
 func Load() (*Config, error) {
 	// Load .env file if it exists (ignore error if file doesn't exist)
@@ -87,10 +203,11 @@ func Load() (*Config, error) {
 
 	config := &Config{
 		Server: ServerConfig{
-			Port:       getEnv("PORT", "8080"),
-			Host:       getEnv("HOST", "0.0.0.0"),
-			APIBaseURL: getEnv("API_BASE_URL", ""),
-			Env:        getEnv("ENV", "development"),
+			Port:        getEnv("PORT", "8080"),
+			Host:        getEnv("HOST", "0.0.0.0"),
+			APIBaseURL:  getEnv("API_BASE_URL", ""),
+			Env:         getEnv("ENV", "development"),
+			MetricsPort: getEnv("METRICS_PORT", ""),
 		},
 		Database: DatabaseConfig{
 			URL: getEnv("DATABASE_URL", ""),
@@ -99,6 +216,24 @@ func Load() (*Config, error) {
 			ClientID:     getEnv("GOOGLE_CLIENT_ID", ""),
 			ClientSecret: getEnv("GOOGLE_CLIENT_SECRET", ""),
 		},
+		GitHub: GitHubConfig{
+			ClientID:     getEnv("GITHUB_CLIENT_ID", ""),
+			ClientSecret: getEnv("GITHUB_CLIENT_SECRET", ""),
+		},
+		Microsoft: MicrosoftConfig{
+			ClientID:     getEnv("MICROSOFT_CLIENT_ID", ""),
+			ClientSecret: getEnv("MICROSOFT_CLIENT_SECRET", ""),
+		},
+		Mailer: MailerConfig{
+			Driver:       getEnv("MAILER", "log"),
+			From:         getEnv("MAIL_FROM", "ranx.cloud <noreply@ranx.cloud>"),
+			SMTPHost:     getEnv("SMTP_HOST", ""),
+			SMTPPort:     getEnv("SMTP_PORT", "587"),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+			APIKey:       getEnv("MAILER_API_KEY", ""),
+			FileDir:      getEnv("MAIL_FILE_DIR", "tmp/mail"),
+		},
 		JWT: JWTConfig{
 			Secret: getEnv("JWT_SECRET", ""),
 		},
@@ -114,7 +249,45 @@ func Load() (*Config, error) {
 			WebhookSecret: getEnv("LEMONSQUEEZY_WEBHOOK_SECRET", ""),
 			VariantID:     getEnv("LEMONSQUEEZY_VARIANT_ID", ""),
 		},
+		Routing: RoutingConfig{
+			Mode:             getEnv("ROUTING_MODE", RoutingModeProxy),
+			GatewayName:      getEnv("GATEWAY_NAME", "tenant-gateway"),
+			GatewayNamespace: getEnv("GATEWAY_NAMESPACE", "gateway"),
+			TenantDomain:     getEnv("TENANT_DOMAIN", "ranx.cloud"),
+		},
+		N8N: N8NConfig{
+			OwnerSecret: getEnv("N8N_OWNER_SECRET", ""),
+		},
+		Trial: TrialConfig{
+			ExpiryAction: getEnv("TRIAL_EXPIRY_ACTION", TrialExpiryHibernate),
+		},
+	}
+
+	timeouts := []struct {
+		key          string
+		defaultValue time.Duration
+		target       *time.Duration
+	}{
+		{"DASHBOARD_TIMEOUT", 15 * time.Second, &config.Server.DashboardTimeout},
+		{"PROXY_TIMEOUT", 10 * time.Minute, &config.Server.ProxyTimeout},
+		{"PROXY_IDLE_TIMEOUT", 10 * time.Minute, &config.Server.ProxyIdleTimeout},
+		{"TRIAL_GRACE_PERIOD", 48 * time.Hour, &config.Trial.GracePeriod},
+		{"TRIAL_CHECK_INTERVAL", 10 * time.Minute, &config.Trial.CheckInterval},
+		{"MONITOR_CHECK_INTERVAL", time.Minute, &config.Monitor.CheckInterval},
+	}
+	for _, t := range timeouts {
+		d, err := getEnvDuration(t.key, t.defaultValue)
+		if err != nil {
+			return nil, err
+		}
+		*t.target = d
+	}
+
+	trustedProxies, err := getEnvPrefixes("TRUSTED_PROXIES")
+	if err != nil {
+		return nil, err
 	}
+	config.Server.TrustedProxies = trustedProxies
 
 	// Validate required fields
 	if err := config.Validate(); err != nil {
@@ -132,10 +305,26 @@ func (c *Config) Validate() error {
 	if c.JWT.Secret == "" {
 		return fmt.Errorf("JWT_SECRET is required")
 	}
+	// Changing it locks ranx out of every n8n owner account, so it must not follow JWT_SECRET
+	if c.N8N.OwnerSecret == "" {
+		return fmt.Errorf("N8N_OWNER_SECRET is required")
+	}
 
 	if c.Google.ClientID == "" || c.Google.ClientSecret == "" {
 		return fmt.Errorf("GOOGLE_CLIENT_ID and GOOGLE_CLIENT_SECRET are required")
 	}
+
+	if c.Server.ProxyIdleTimeout < c.Server.ProxyTimeout {
+		return fmt.Errorf("PROXY_IDLE_TIMEOUT must be at least PROXY_TIMEOUT")
+	}
+
+	if c.Routing.Mode != RoutingModeProxy && c.Routing.Mode != RoutingModeGateway {
+		return fmt.Errorf("ROUTING_MODE must be %q or %q", RoutingModeProxy, RoutingModeGateway)
+	}
+
+	if c.Trial.ExpiryAction != TrialExpiryHibernate && c.Trial.ExpiryAction != TrialExpiryDelete {
+		return fmt.Errorf("TRIAL_EXPIRY_ACTION must be %q or %q", TrialExpiryHibernate, TrialExpiryDelete)
+	}
 	return nil
 }
 
@@ -146,3 +335,41 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvDuration parses a duration such as "30s" or "10m" from an environment variable
+func getEnvDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration like 30s or 10m", key)
+	}
+	return d, nil
+}
+
+// getEnvPrefixes parses a comma separated list of CIDRs or IPs from an environment variable
+func getEnvPrefixes(key string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(os.Getenv(key), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid IP %q", key, entry)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid CIDR %q", key, entry)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
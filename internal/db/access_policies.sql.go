@@ -0,0 +1,78 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: access_policies.sql
+
+package db
+
+import (
+	"context"
+)
+
+const getInstanceAccessPolicy = `-- name: GetInstanceAccessPolicy :one
+SELECT instance_id, allowed_cidrs, auth_mode, basic_auth_username, basic_auth_password_hash, public_paths, created_at, updated_at FROM instance_access_policies WHERE instance_id = $1
+`
+
+func (q *Queries) GetInstanceAccessPolicy(ctx context.Context, instanceID string) (InstanceAccessPolicy, error) {
+	row := q.db.QueryRow(ctx, getInstanceAccessPolicy, instanceID)
+	var i InstanceAccessPolicy
+	err := row.Scan(
+		&i.InstanceID,
+		&i.AllowedCidrs,
+		&i.AuthMode,
+		&i.BasicAuthUsername,
+		&i.BasicAuthPasswordHash,
+		&i.PublicPaths,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const upsertInstanceAccessPolicy = `-- name: UpsertInstanceAccessPolicy :one
+INSERT INTO instance_access_policies (
+    instance_id, allowed_cidrs, auth_mode, basic_auth_username, basic_auth_password_hash, public_paths
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+ON CONFLICT (instance_id) DO UPDATE
+SET allowed_cidrs = EXCLUDED.allowed_cidrs,
+    auth_mode = EXCLUDED.auth_mode,
+    basic_auth_username = EXCLUDED.basic_auth_username,
+    basic_auth_password_hash = EXCLUDED.basic_auth_password_hash,
+    public_paths = EXCLUDED.public_paths,
+    updated_at = NOW()
+RETURNING instance_id, allowed_cidrs, auth_mode, basic_auth_username, basic_auth_password_hash, public_paths, created_at, updated_at
+`
+
+type UpsertInstanceAccessPolicyParams struct {
+	InstanceID            string   `json:"instance_id"`
+	AllowedCidrs          []string `json:"allowed_cidrs"`
+	AuthMode              string   `json:"auth_mode"`
+	BasicAuthUsername     string   `json:"basic_auth_username"`
+	BasicAuthPasswordHash string   `json:"basic_auth_password_hash"`
+	PublicPaths           []string `json:"public_paths"`
+}
+
+func (q *Queries) UpsertInstanceAccessPolicy(ctx context.Context, arg UpsertInstanceAccessPolicyParams) (InstanceAccessPolicy, error) {
+	row := q.db.QueryRow(ctx, upsertInstanceAccessPolicy,
+		arg.InstanceID,
+		arg.AllowedCidrs,
+		arg.AuthMode,
+		arg.BasicAuthUsername,
+		arg.BasicAuthPasswordHash,
+		arg.PublicPaths,
+	)
+	var i InstanceAccessPolicy
+	err := row.Scan(
+		&i.InstanceID,
+		&i.AllowedCidrs,
+		&i.AuthMode,
+		&i.BasicAuthUsername,
+		&i.BasicAuthPasswordHash,
+		&i.PublicPaths,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,159 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: accounts.sql
+
+package db
+
+import (
+	"context"
+)
+
+const deleteMagicLinkTokensByEmail = `-- name: DeleteMagicLinkTokensByEmail :exec
+DELETE FROM magic_link_tokens WHERE email = $1
+`
+
+func (q *Queries) DeleteMagicLinkTokensByEmail(ctx context.Context, email string) error {
+	_, err := q.db.Exec(ctx, deleteMagicLinkTokensByEmail, email)
+	return err
+}
+
+const deleteUserAPITokens = `-- name: DeleteUserAPITokens :exec
+DELETE FROM api_tokens WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserAPITokens(ctx context.Context, userID string) error {
+	_, err := q.db.Exec(ctx, deleteUserAPITokens, userID)
+	return err
+}
+
+const deleteUserIdentities = `-- name: DeleteUserIdentities :exec
+DELETE FROM user_identities WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserIdentities(ctx context.Context, userID string) error {
+	_, err := q.db.Exec(ctx, deleteUserIdentities, userID)
+	return err
+}
+
+const deleteUserMemberships = `-- name: DeleteUserMemberships :exec
+DELETE FROM organization_members WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserMemberships(ctx context.Context, userID string) error {
+	_, err := q.db.Exec(ctx, deleteUserMemberships, userID)
+	return err
+}
+
+const listUserInstances = `-- name: ListUserInstances :many
+SELECT id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version, custom_env, organization_id, owner_setup_at FROM instances WHERE user_id = $1 ORDER BY created_at
+`
+
+// Instances the user created, including deleted ones
+func (q *Queries) ListUserInstances(ctx context.Context, userID string) ([]Instance, error) {
+	rows, err := q.db.Query(ctx, listUserInstances, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Instance
+	for rows.Next() {
+		var i Instance
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Status,
+			&i.Namespace,
+			&i.Subdomain,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeployedAt,
+			&i.DeletedAt,
+			&i.AppVersion,
+			&i.CustomEnv,
+			&i.OrganizationID,
+			&i.OwnerSetupAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserSubscriptions = `-- name: ListUserSubscriptions :many
+SELECT id, user_id, product_id, variant_id, customer_id, subscription_id, status, quantity, trial_ends_at, created_at, updated_at, organization_id, trial_warning_sent_at, trial_ended_notice_sent_at, trial_enforced_at, trial_enforcement_claimed_at FROM subscriptions WHERE user_id = $1 ORDER BY created_at
+`
+
+// Subscriptions the user started, they stay with the organization when the user leaves
+func (q *Queries) ListUserSubscriptions(ctx context.Context, userID string) ([]Subscription, error) {
+	rows, err := q.db.Query(ctx, listUserSubscriptions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Subscription
+	for rows.Next() {
+		var i Subscription
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ProductID,
+			&i.VariantID,
+			&i.CustomerID,
+			&i.SubscriptionID,
+			&i.Status,
+			&i.Quantity,
+			&i.TrialEndsAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.OrganizationID,
+			&i.TrialWarningSentAt,
+			&i.TrialEndedNoticeSentAt,
+			&i.TrialEnforcedAt,
+			&i.TrialEnforcementClaimedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const renameOrganization = `-- name: RenameOrganization :exec
+UPDATE organizations SET name = $2, updated_at = NOW() WHERE id = $1
+`
+
+type RenameOrganizationParams struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func (q *Queries) RenameOrganization(ctx context.Context, arg RenameOrganizationParams) error {
+	_, err := q.db.Exec(ctx, renameOrganization, arg.ID, arg.Name)
+	return err
+}
+
+const tombstoneUser = `-- name: TombstoneUser :exec
+UPDATE users
+SET email = 'deleted+' || id || '@ranx.cloud',
+    name = '',
+    totp_secret = NULL,
+    totp_enabled_at = NULL,
+    current_organization_id = NULL,
+    deleted_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+// Scrubs the personal data of a deleted account, the address can't collide with a real one
+func (q *Queries) TombstoneUser(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, tombstoneUser, id)
+	return err
+}
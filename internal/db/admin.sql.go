@@ -0,0 +1,389 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: admin.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const adminListAuditEventsByAction = `-- name: AdminListAuditEventsByAction :many
+SELECT audit_events.id, audit_events.organization_id, audit_events.actor_type, audit_events.actor_user_id, audit_events.action, audit_events.target_type, audit_events.target_id, audit_events.metadata, audit_events.ip_address, audit_events.user_agent, audit_events.request_id, audit_events.created_at, COALESCE(users.email, '')::varchar AS actor_email
+FROM audit_events
+LEFT JOIN users ON users.id = audit_events.actor_user_id
+WHERE audit_events.action = $1
+ORDER BY audit_events.created_at DESC
+LIMIT $2
+`
+
+type AdminListAuditEventsByActionParams struct {
+	Action    string `json:"action"`
+	MaxEvents int32  `json:"max_events"`
+}
+
+type AdminListAuditEventsByActionRow struct {
+	ID             string           `json:"id"`
+	OrganizationID *string          `json:"organization_id"`
+	ActorType      string           `json:"actor_type"`
+	ActorUserID    *string          `json:"actor_user_id"`
+	Action         string           `json:"action"`
+	TargetType     string           `json:"target_type"`
+	TargetID       string           `json:"target_id"`
+	Metadata       []byte           `json:"metadata"`
+	IpAddress      string           `json:"ip_address"`
+	UserAgent      string           `json:"user_agent"`
+	RequestID      string           `json:"request_id"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+	ActorEmail     string           `json:"actor_email"`
+}
+
+func (q *Queries) AdminListAuditEventsByAction(ctx context.Context, arg AdminListAuditEventsByActionParams) ([]AdminListAuditEventsByActionRow, error) {
+	rows, err := q.db.Query(ctx, adminListAuditEventsByAction, arg.Action, arg.MaxEvents)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AdminListAuditEventsByActionRow
+	for rows.Next() {
+		var i AdminListAuditEventsByActionRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.OrganizationID,
+			&i.ActorType,
+			&i.ActorUserID,
+			&i.Action,
+			&i.TargetType,
+			&i.TargetID,
+			&i.Metadata,
+			&i.IpAddress,
+			&i.UserAgent,
+			&i.RequestID,
+			&i.CreatedAt,
+			&i.ActorEmail,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const adminListInstances = `-- name: AdminListInstances :many
+SELECT instances.id, instances.user_id, instances.status, instances.namespace, instances.subdomain, instances.created_at, instances.updated_at, instances.deployed_at, instances.deleted_at, instances.app_version, instances.custom_env, instances.organization_id, instances.owner_setup_at, organizations.name AS organization_name, COALESCE(users.email, '')::varchar AS user_email
+FROM instances
+JOIN organizations ON organizations.id = instances.organization_id
+LEFT JOIN users ON users.id = instances.user_id
+WHERE $1::varchar IS NULL
+   OR instances.subdomain ILIKE '%' || $1::varchar || '%'
+   OR instances.namespace = $1::varchar
+   OR users.email ILIKE '%' || $1::varchar || '%'
+ORDER BY instances.created_at DESC
+LIMIT $2
+`
+
+type AdminListInstancesParams struct {
+	Search  pgtype.Text `json:"search"`
+	MaxRows int32       `json:"max_rows"`
+}
+
+type AdminListInstancesRow struct {
+	ID               string           `json:"id"`
+	UserID           string           `json:"user_id"`
+	Status           string           `json:"status"`
+	Namespace        string           `json:"namespace"`
+	Subdomain        string           `json:"subdomain"`
+	CreatedAt        pgtype.Timestamp `json:"created_at"`
+	UpdatedAt        pgtype.Timestamp `json:"updated_at"`
+	DeployedAt       pgtype.Timestamp `json:"deployed_at"`
+	DeletedAt        pgtype.Timestamp `json:"deleted_at"`
+	AppVersion       string           `json:"app_version"`
+	CustomEnv        []byte           `json:"custom_env"`
+	OrganizationID   string           `json:"organization_id"`
+	OwnerSetupAt     pgtype.Timestamp `json:"owner_setup_at"`
+	OrganizationName string           `json:"organization_name"`
+	UserEmail        string           `json:"user_email"`
+}
+
+// search matches the subdomain, the namespace or the email of the creator, deleted instances included
+func (q *Queries) AdminListInstances(ctx context.Context, arg AdminListInstancesParams) ([]AdminListInstancesRow, error) {
+	rows, err := q.db.Query(ctx, adminListInstances, arg.Search, arg.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AdminListInstancesRow
+	for rows.Next() {
+		var i AdminListInstancesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Status,
+			&i.Namespace,
+			&i.Subdomain,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeployedAt,
+			&i.DeletedAt,
+			&i.AppVersion,
+			&i.CustomEnv,
+			&i.OrganizationID,
+			&i.OwnerSetupAt,
+			&i.OrganizationName,
+			&i.UserEmail,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const adminListStuckInstances = `-- name: AdminListStuckInstances :many
+SELECT instances.id, instances.user_id, instances.status, instances.namespace, instances.subdomain, instances.created_at, instances.updated_at, instances.deployed_at, instances.deleted_at, instances.app_version, instances.custom_env, instances.organization_id, instances.owner_setup_at, organizations.name AS organization_name, COALESCE(users.email, '')::varchar AS user_email
+FROM instances
+JOIN organizations ON organizations.id = instances.organization_id
+LEFT JOIN users ON users.id = instances.user_id
+WHERE instances.deleted_at IS NULL
+  AND (instances.status = 'failed'
+       OR (instances.owner_setup_at IS NULL AND instances.created_at < $1::timestamp))
+ORDER BY instances.created_at DESC
+LIMIT $2
+`
+
+type AdminListStuckInstancesParams struct {
+	SetupDeadline pgtype.Timestamp `json:"setup_deadline"`
+	MaxRows       int32            `json:"max_rows"`
+}
+
+type AdminListStuckInstancesRow struct {
+	ID               string           `json:"id"`
+	UserID           string           `json:"user_id"`
+	Status           string           `json:"status"`
+	Namespace        string           `json:"namespace"`
+	Subdomain        string           `json:"subdomain"`
+	CreatedAt        pgtype.Timestamp `json:"created_at"`
+	UpdatedAt        pgtype.Timestamp `json:"updated_at"`
+	DeployedAt       pgtype.Timestamp `json:"deployed_at"`
+	DeletedAt        pgtype.Timestamp `json:"deleted_at"`
+	AppVersion       string           `json:"app_version"`
+	CustomEnv        []byte           `json:"custom_env"`
+	OrganizationID   string           `json:"organization_id"`
+	OwnerSetupAt     pgtype.Timestamp `json:"owner_setup_at"`
+	OrganizationName string           `json:"organization_name"`
+	UserEmail        string           `json:"user_email"`
+}
+
+// Live instances that failed, or whose n8n owner still isn't set up long after provisioning
+func (q *Queries) AdminListStuckInstances(ctx context.Context, arg AdminListStuckInstancesParams) ([]AdminListStuckInstancesRow, error) {
+	rows, err := q.db.Query(ctx, adminListStuckInstances, arg.SetupDeadline, arg.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AdminListStuckInstancesRow
+	for rows.Next() {
+		var i AdminListStuckInstancesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Status,
+			&i.Namespace,
+			&i.Subdomain,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeployedAt,
+			&i.DeletedAt,
+			&i.AppVersion,
+			&i.CustomEnv,
+			&i.OrganizationID,
+			&i.OwnerSetupAt,
+			&i.OrganizationName,
+			&i.UserEmail,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const adminListSubscriptions = `-- name: AdminListSubscriptions :many
+SELECT subscriptions.id, subscriptions.user_id, subscriptions.product_id, subscriptions.variant_id, subscriptions.customer_id, subscriptions.subscription_id, subscriptions.status, subscriptions.quantity, subscriptions.trial_ends_at, subscriptions.created_at, subscriptions.updated_at, subscriptions.organization_id, subscriptions.trial_warning_sent_at, subscriptions.trial_ended_notice_sent_at, subscriptions.trial_enforced_at, subscriptions.trial_enforcement_claimed_at, organizations.name AS organization_name, COALESCE(users.email, '')::varchar AS user_email
+FROM subscriptions
+JOIN organizations ON organizations.id = subscriptions.organization_id
+LEFT JOIN users ON users.id = subscriptions.user_id
+WHERE $1::varchar IS NULL
+   OR organizations.name ILIKE '%' || $1::varchar || '%'
+   OR users.email ILIKE '%' || $1::varchar || '%'
+   OR subscriptions.subscription_id = $1::varchar
+   OR subscriptions.customer_id = $1::varchar
+ORDER BY subscriptions.created_at DESC
+LIMIT $2
+`
+
+type AdminListSubscriptionsParams struct {
+	Search  pgtype.Text `json:"search"`
+	MaxRows int32       `json:"max_rows"`
+}
+
+type AdminListSubscriptionsRow struct {
+	ID                        string           `json:"id"`
+	UserID                    string           `json:"user_id"`
+	ProductID                 string           `json:"product_id"`
+	VariantID                 string           `json:"variant_id"`
+	CustomerID                string           `json:"customer_id"`
+	SubscriptionID            string           `json:"subscription_id"`
+	Status                    string           `json:"status"`
+	Quantity                  int32            `json:"quantity"`
+	TrialEndsAt               pgtype.Timestamp `json:"trial_ends_at"`
+	CreatedAt                 pgtype.Timestamp `json:"created_at"`
+	UpdatedAt                 pgtype.Timestamp `json:"updated_at"`
+	OrganizationID            string           `json:"organization_id"`
+	TrialWarningSentAt        pgtype.Timestamp `json:"trial_warning_sent_at"`
+	TrialEndedNoticeSentAt    pgtype.Timestamp `json:"trial_ended_notice_sent_at"`
+	TrialEnforcedAt           pgtype.Timestamp `json:"trial_enforced_at"`
+	TrialEnforcementClaimedAt pgtype.Timestamp `json:"trial_enforcement_claimed_at"`
+	OrganizationName          string           `json:"organization_name"`
+	UserEmail                 string           `json:"user_email"`
+}
+
+// search matches the organization name, the email of the subscriber or a LemonSqueezy ID
+func (q *Queries) AdminListSubscriptions(ctx context.Context, arg AdminListSubscriptionsParams) ([]AdminListSubscriptionsRow, error) {
+	rows, err := q.db.Query(ctx, adminListSubscriptions, arg.Search, arg.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AdminListSubscriptionsRow
+	for rows.Next() {
+		var i AdminListSubscriptionsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ProductID,
+			&i.VariantID,
+			&i.CustomerID,
+			&i.SubscriptionID,
+			&i.Status,
+			&i.Quantity,
+			&i.TrialEndsAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.OrganizationID,
+			&i.TrialWarningSentAt,
+			&i.TrialEndedNoticeSentAt,
+			&i.TrialEnforcedAt,
+			&i.TrialEnforcementClaimedAt,
+			&i.OrganizationName,
+			&i.UserEmail,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const adminListUsers = `-- name: AdminListUsers :many
+SELECT id, email, name, is_admin, created_at, last_login_at, deleted_at, current_organization_id,
+       (totp_enabled_at IS NOT NULL)::boolean AS two_factor_enabled
+FROM users
+WHERE $1::varchar IS NULL
+   OR email ILIKE '%' || $1::varchar || '%'
+   OR name ILIKE '%' || $1::varchar || '%'
+   OR id::text = $1::varchar
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type AdminListUsersParams struct {
+	Search  pgtype.Text `json:"search"`
+	MaxRows int32       `json:"max_rows"`
+}
+
+type AdminListUsersRow struct {
+	ID                    string           `json:"id"`
+	Email                 string           `json:"email"`
+	Name                  string           `json:"name"`
+	IsAdmin               bool             `json:"is_admin"`
+	CreatedAt             pgtype.Timestamp `json:"created_at"`
+	LastLoginAt           pgtype.Timestamp `json:"last_login_at"`
+	DeletedAt             pgtype.Timestamp `json:"deleted_at"`
+	CurrentOrganizationID *string          `json:"current_organization_id"`
+	TwoFactorEnabled      bool             `json:"two_factor_enabled"`
+}
+
+// search matches the email, the name or the exact ID, NULL lists the newest users
+func (q *Queries) AdminListUsers(ctx context.Context, arg AdminListUsersParams) ([]AdminListUsersRow, error) {
+	rows, err := q.db.Query(ctx, adminListUsers, arg.Search, arg.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AdminListUsersRow
+	for rows.Next() {
+		var i AdminListUsersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.Name,
+			&i.IsAdmin,
+			&i.CreatedAt,
+			&i.LastLoginAt,
+			&i.DeletedAt,
+			&i.CurrentOrganizationID,
+			&i.TwoFactorEnabled,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserMembership = `-- name: GetUserMembership :one
+SELECT users.id, users.email, users.current_organization_id, organization_members.role AS organization_role
+FROM users
+LEFT JOIN organization_members ON organization_members.organization_id = users.current_organization_id
+    AND organization_members.user_id = users.id
+WHERE users.id = $1 AND users.deleted_at IS NULL
+`
+
+type GetUserMembershipRow struct {
+	ID                    string      `json:"id"`
+	Email                 string      `json:"email"`
+	CurrentOrganizationID *string     `json:"current_organization_id"`
+	OrganizationRole      pgtype.Text `json:"organization_role"`
+}
+
+// The user and the organization they currently act in, role is NULL when they were removed from it
+func (q *Queries) GetUserMembership(ctx context.Context, id string) (GetUserMembershipRow, error) {
+	row := q.db.QueryRow(ctx, getUserMembership, id)
+	var i GetUserMembershipRow
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CurrentOrganizationID,
+		&i.OrganizationRole,
+	)
+	return i, err
+}
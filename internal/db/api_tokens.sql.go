@@ -0,0 +1,181 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: api_tokens.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const countUserAPITokens = `-- name: CountUserAPITokens :one
+SELECT COUNT(*) FROM api_tokens WHERE user_id = $1
+`
+
+func (q *Queries) CountUserAPITokens(ctx context.Context, userID string) (int64, error) {
+	row := q.db.QueryRow(ctx, countUserAPITokens, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createAPIToken = `-- name: CreateAPIToken :one
+INSERT INTO api_tokens (
+    user_id, organization_id, name, token_prefix, token_hash, scopes, expires_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+)
+RETURNING id, user_id, name, token_prefix, token_hash, scopes, created_at, last_used_at, expires_at, organization_id
+`
+
+type CreateAPITokenParams struct {
+	UserID         string           `json:"user_id"`
+	OrganizationID string           `json:"organization_id"`
+	Name           string           `json:"name"`
+	TokenPrefix    string           `json:"token_prefix"`
+	TokenHash      string           `json:"token_hash"`
+	Scopes         []string         `json:"scopes"`
+	ExpiresAt      pgtype.Timestamp `json:"expires_at"`
+}
+
+func (q *Queries) CreateAPIToken(ctx context.Context, arg CreateAPITokenParams) (ApiToken, error) {
+	row := q.db.QueryRow(ctx, createAPIToken,
+		arg.UserID,
+		arg.OrganizationID,
+		arg.Name,
+		arg.TokenPrefix,
+		arg.TokenHash,
+		arg.Scopes,
+		arg.ExpiresAt,
+	)
+	var i ApiToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.TokenPrefix,
+		&i.TokenHash,
+		&i.Scopes,
+		&i.CreatedAt,
+		&i.LastUsedAt,
+		&i.ExpiresAt,
+		&i.OrganizationID,
+	)
+	return i, err
+}
+
+const deleteUserAPIToken = `-- name: DeleteUserAPIToken :execrows
+DELETE FROM api_tokens WHERE id = $1 AND user_id = $2
+`
+
+type DeleteUserAPITokenParams struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+}
+
+func (q *Queries) DeleteUserAPIToken(ctx context.Context, arg DeleteUserAPITokenParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteUserAPIToken, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getActiveAPITokenByHash = `-- name: GetActiveAPITokenByHash :one
+SELECT api_tokens.id, api_tokens.user_id, api_tokens.name, api_tokens.token_prefix, api_tokens.token_hash, api_tokens.scopes, api_tokens.created_at, api_tokens.last_used_at, api_tokens.expires_at, api_tokens.organization_id, organization_members.role AS organization_role,
+       (organizations.require_two_factor AND users.totp_enabled_at IS NULL)::boolean AS two_factor_required
+FROM api_tokens
+JOIN organization_members ON organization_members.organization_id = api_tokens.organization_id
+    AND organization_members.user_id = api_tokens.user_id
+JOIN organizations ON organizations.id = api_tokens.organization_id
+JOIN users ON users.id = api_tokens.user_id
+WHERE api_tokens.token_hash = $1 AND (api_tokens.expires_at IS NULL OR api_tokens.expires_at > NOW())
+`
+
+type GetActiveAPITokenByHashRow struct {
+	ID                string           `json:"id"`
+	UserID            string           `json:"user_id"`
+	Name              string           `json:"name"`
+	TokenPrefix       string           `json:"token_prefix"`
+	TokenHash         string           `json:"token_hash"`
+	Scopes            []string         `json:"scopes"`
+	CreatedAt         pgtype.Timestamp `json:"created_at"`
+	LastUsedAt        pgtype.Timestamp `json:"last_used_at"`
+	ExpiresAt         pgtype.Timestamp `json:"expires_at"`
+	OrganizationID    string           `json:"organization_id"`
+	OrganizationRole  string           `json:"organization_role"`
+	TwoFactorRequired bool             `json:"two_factor_required"`
+}
+
+// Tokens stop working when their owner leaves the organization
+func (q *Queries) GetActiveAPITokenByHash(ctx context.Context, tokenHash string) (GetActiveAPITokenByHashRow, error) {
+	row := q.db.QueryRow(ctx, getActiveAPITokenByHash, tokenHash)
+	var i GetActiveAPITokenByHashRow
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.TokenPrefix,
+		&i.TokenHash,
+		&i.Scopes,
+		&i.CreatedAt,
+		&i.LastUsedAt,
+		&i.ExpiresAt,
+		&i.OrganizationID,
+		&i.OrganizationRole,
+		&i.TwoFactorRequired,
+	)
+	return i, err
+}
+
+const listUserAPITokens = `-- name: ListUserAPITokens :many
+SELECT id, user_id, name, token_prefix, token_hash, scopes, created_at, last_used_at, expires_at, organization_id FROM api_tokens WHERE user_id = $1 AND organization_id = $2 ORDER BY created_at DESC
+`
+
+type ListUserAPITokensParams struct {
+	UserID         string `json:"user_id"`
+	OrganizationID string `json:"organization_id"`
+}
+
+func (q *Queries) ListUserAPITokens(ctx context.Context, arg ListUserAPITokensParams) ([]ApiToken, error) {
+	rows, err := q.db.Query(ctx, listUserAPITokens, arg.UserID, arg.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ApiToken
+	for rows.Next() {
+		var i ApiToken
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Name,
+			&i.TokenPrefix,
+			&i.TokenHash,
+			&i.Scopes,
+			&i.CreatedAt,
+			&i.LastUsedAt,
+			&i.ExpiresAt,
+			&i.OrganizationID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const touchAPIToken = `-- name: TouchAPIToken :exec
+UPDATE api_tokens SET last_used_at = NOW() WHERE id = $1
+`
+
+func (q *Queries) TouchAPIToken(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, touchAPIToken, id)
+	return err
+}
@@ -0,0 +1,191 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: audit_events.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createAuditEvent = `-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (
+    organization_id, actor_type, actor_user_id, action, target_type, target_id,
+    metadata, ip_address, user_agent, request_id
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+)
+`
+
+type CreateAuditEventParams struct {
+	OrganizationID *string `json:"organization_id"`
+	ActorType      string  `json:"actor_type"`
+	ActorUserID    *string `json:"actor_user_id"`
+	Action         string  `json:"action"`
+	TargetType     string  `json:"target_type"`
+	TargetID       string  `json:"target_id"`
+	Metadata       []byte  `json:"metadata"`
+	IpAddress      string  `json:"ip_address"`
+	UserAgent      string  `json:"user_agent"`
+	RequestID      string  `json:"request_id"`
+}
+
+func (q *Queries) CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) error {
+	_, err := q.db.Exec(ctx, createAuditEvent,
+		arg.OrganizationID,
+		arg.ActorType,
+		arg.ActorUserID,
+		arg.Action,
+		arg.TargetType,
+		arg.TargetID,
+		arg.Metadata,
+		arg.IpAddress,
+		arg.UserAgent,
+		arg.RequestID,
+	)
+	return err
+}
+
+const listAuditEvents = `-- name: ListAuditEvents :many
+SELECT audit_events.id, audit_events.organization_id, audit_events.actor_type, audit_events.actor_user_id, audit_events.action, audit_events.target_type, audit_events.target_id, audit_events.metadata, audit_events.ip_address, audit_events.user_agent, audit_events.request_id, audit_events.created_at, COALESCE(users.email, '')::varchar AS actor_email
+FROM audit_events
+LEFT JOIN users ON users.id = audit_events.actor_user_id
+WHERE (audit_events.organization_id = $1
+       OR (audit_events.actor_user_id = $2::uuid
+           AND (audit_events.organization_id IS NULL
+                OR (audit_events.target_type = 'user' AND audit_events.target_id = $2::uuid::text))))
+  AND ($3::varchar IS NULL OR audit_events.action LIKE $3::varchar || '%')
+  AND ($4::uuid IS NULL OR audit_events.actor_user_id = $4::uuid)
+  AND ($5::timestamp IS NULL OR audit_events.created_at >= $5::timestamp)
+ORDER BY audit_events.created_at DESC
+LIMIT $6
+`
+
+type ListAuditEventsParams struct {
+	OrganizationID *string          `json:"organization_id"`
+	UserID         string           `json:"user_id"`
+	ActionPrefix   pgtype.Text      `json:"action_prefix"`
+	ActorUserID    *string          `json:"actor_user_id"`
+	Since          pgtype.Timestamp `json:"since"`
+	MaxEvents      int32            `json:"max_events"`
+}
+
+type ListAuditEventsRow struct {
+	ID             string           `json:"id"`
+	OrganizationID *string          `json:"organization_id"`
+	ActorType      string           `json:"actor_type"`
+	ActorUserID    *string          `json:"actor_user_id"`
+	Action         string           `json:"action"`
+	TargetType     string           `json:"target_type"`
+	TargetID       string           `json:"target_id"`
+	Metadata       []byte           `json:"metadata"`
+	IpAddress      string           `json:"ip_address"`
+	UserAgent      string           `json:"user_agent"`
+	RequestID      string           `json:"request_id"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+	ActorEmail     string           `json:"actor_email"`
+}
+
+// Events of an organization and of the user's own account, newest first.
+// organization_id is NULL for members who may only see their own account.
+// Account events are the user's own ones outside organizations and those on the user itself, like logins.
+func (q *Queries) ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]ListAuditEventsRow, error) {
+	rows, err := q.db.Query(ctx, listAuditEvents,
+		arg.OrganizationID,
+		arg.UserID,
+		arg.ActionPrefix,
+		arg.ActorUserID,
+		arg.Since,
+		arg.MaxEvents,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListAuditEventsRow
+	for rows.Next() {
+		var i ListAuditEventsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.OrganizationID,
+			&i.ActorType,
+			&i.ActorUserID,
+			&i.Action,
+			&i.TargetType,
+			&i.TargetID,
+			&i.Metadata,
+			&i.IpAddress,
+			&i.UserAgent,
+			&i.RequestID,
+			&i.CreatedAt,
+			&i.ActorEmail,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserAuditEvents = `-- name: ListUserAuditEvents :many
+SELECT id, organization_id, actor_type, actor_user_id, action, target_type, target_id, metadata, ip_address, user_agent, request_id, created_at FROM audit_events
+WHERE actor_user_id = $1
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type ListUserAuditEventsParams struct {
+	ActorUserID *string `json:"actor_user_id"`
+	MaxEvents   int32   `json:"max_events"`
+}
+
+func (q *Queries) ListUserAuditEvents(ctx context.Context, arg ListUserAuditEventsParams) ([]AuditEvent, error) {
+	rows, err := q.db.Query(ctx, listUserAuditEvents, arg.ActorUserID, arg.MaxEvents)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditEvent
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.OrganizationID,
+			&i.ActorType,
+			&i.ActorUserID,
+			&i.Action,
+			&i.TargetType,
+			&i.TargetID,
+			&i.Metadata,
+			&i.IpAddress,
+			&i.UserAgent,
+			&i.RequestID,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const scrubUserAuditEvents = `-- name: ScrubUserAuditEvents :exec
+UPDATE audit_events
+SET ip_address = '', user_agent = ''
+WHERE actor_user_id = $1 AND (ip_address <> '' OR user_agent <> '')
+`
+
+// Clears where the actions of a deleted account came from, the only change the append-only trigger allows
+func (q *Queries) ScrubUserAuditEvents(ctx context.Context, actorUserID *string) error {
+	_, err := q.db.Exec(ctx, scrubUserAuditEvents, actorUserID)
+	return err
+}
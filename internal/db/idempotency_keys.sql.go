@@ -0,0 +1,127 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: idempotency_keys.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const completeIdempotencyKey = `-- name: CompleteIdempotencyKey :exec
+UPDATE idempotency_keys
+SET response_status = $2, response_headers = $3, response_body = $4
+WHERE id = $1
+`
+
+type CompleteIdempotencyKeyParams struct {
+	ID              string      `json:"id"`
+	ResponseStatus  pgtype.Int4 `json:"response_status"`
+	ResponseHeaders []byte      `json:"response_headers"`
+	ResponseBody    []byte      `json:"response_body"`
+}
+
+func (q *Queries) CompleteIdempotencyKey(ctx context.Context, arg CompleteIdempotencyKeyParams) error {
+	_, err := q.db.Exec(ctx, completeIdempotencyKey,
+		arg.ID,
+		arg.ResponseStatus,
+		arg.ResponseHeaders,
+		arg.ResponseBody,
+	)
+	return err
+}
+
+const createIdempotencyKey = `-- name: CreateIdempotencyKey :one
+INSERT INTO idempotency_keys (
+    user_id, idempotency_key, request_hash, expires_at
+) VALUES (
+    $1, $2, $3, $4
+)
+ON CONFLICT (user_id, idempotency_key) DO NOTHING
+RETURNING id, user_id, idempotency_key, request_hash, response_status, response_headers, response_body, created_at, expires_at
+`
+
+type CreateIdempotencyKeyParams struct {
+	UserID         string           `json:"user_id"`
+	IdempotencyKey string           `json:"idempotency_key"`
+	RequestHash    string           `json:"request_hash"`
+	ExpiresAt      pgtype.Timestamp `json:"expires_at"`
+}
+
+// Returns no rows when the key is already taken
+func (q *Queries) CreateIdempotencyKey(ctx context.Context, arg CreateIdempotencyKeyParams) (IdempotencyKey, error) {
+	row := q.db.QueryRow(ctx, createIdempotencyKey,
+		arg.UserID,
+		arg.IdempotencyKey,
+		arg.RequestHash,
+		arg.ExpiresAt,
+	)
+	var i IdempotencyKey
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.IdempotencyKey,
+		&i.RequestHash,
+		&i.ResponseStatus,
+		&i.ResponseHeaders,
+		&i.ResponseBody,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const deleteIdempotencyKey = `-- name: DeleteIdempotencyKey :exec
+DELETE FROM idempotency_keys WHERE id = $1
+`
+
+func (q *Queries) DeleteIdempotencyKey(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, deleteIdempotencyKey, id)
+	return err
+}
+
+const deleteStaleUserIdempotencyKeys = `-- name: DeleteStaleUserIdempotencyKeys :exec
+DELETE FROM idempotency_keys
+WHERE user_id = $1
+  AND (expires_at <= NOW() OR (response_status IS NULL AND created_at < $2::timestamp))
+`
+
+type DeleteStaleUserIdempotencyKeysParams struct {
+	UserID        string           `json:"user_id"`
+	StartedBefore pgtype.Timestamp `json:"started_before"`
+}
+
+// Frees keys whose response expired, or whose request never finished
+func (q *Queries) DeleteStaleUserIdempotencyKeys(ctx context.Context, arg DeleteStaleUserIdempotencyKeysParams) error {
+	_, err := q.db.Exec(ctx, deleteStaleUserIdempotencyKeys, arg.UserID, arg.StartedBefore)
+	return err
+}
+
+const getIdempotencyKey = `-- name: GetIdempotencyKey :one
+SELECT id, user_id, idempotency_key, request_hash, response_status, response_headers, response_body, created_at, expires_at FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2
+`
+
+type GetIdempotencyKeyParams struct {
+	UserID         string `json:"user_id"`
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+func (q *Queries) GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKey, error) {
+	row := q.db.QueryRow(ctx, getIdempotencyKey, arg.UserID, arg.IdempotencyKey)
+	var i IdempotencyKey
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.IdempotencyKey,
+		&i.RequestHash,
+		&i.ResponseStatus,
+		&i.ResponseHeaders,
+		&i.ResponseBody,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
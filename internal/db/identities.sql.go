@@ -0,0 +1,184 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: identities.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const consumeMagicLinkToken = `-- name: ConsumeMagicLinkToken :one
+UPDATE magic_link_tokens
+SET used_at = NOW()
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+RETURNING token_hash, email, return_to, expires_at, used_at, created_at
+`
+
+func (q *Queries) ConsumeMagicLinkToken(ctx context.Context, tokenHash string) (MagicLinkToken, error) {
+	row := q.db.QueryRow(ctx, consumeMagicLinkToken, tokenHash)
+	var i MagicLinkToken
+	err := row.Scan(
+		&i.TokenHash,
+		&i.Email,
+		&i.ReturnTo,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const countRecentMagicLinkTokens = `-- name: CountRecentMagicLinkTokens :one
+SELECT COUNT(*) FROM magic_link_tokens WHERE email = $1 AND created_at > $2
+`
+
+type CountRecentMagicLinkTokensParams struct {
+	Email     string           `json:"email"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
+}
+
+func (q *Queries) CountRecentMagicLinkTokens(ctx context.Context, arg CountRecentMagicLinkTokensParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countRecentMagicLinkTokens, arg.Email, arg.CreatedAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createMagicLinkToken = `-- name: CreateMagicLinkToken :exec
+INSERT INTO magic_link_tokens (
+    token_hash, email, return_to, expires_at
+) VALUES (
+    $1, $2, $3, $4
+)
+`
+
+type CreateMagicLinkTokenParams struct {
+	TokenHash string           `json:"token_hash"`
+	Email     string           `json:"email"`
+	ReturnTo  string           `json:"return_to"`
+	ExpiresAt pgtype.Timestamp `json:"expires_at"`
+}
+
+func (q *Queries) CreateMagicLinkToken(ctx context.Context, arg CreateMagicLinkTokenParams) error {
+	_, err := q.db.Exec(ctx, createMagicLinkToken,
+		arg.TokenHash,
+		arg.Email,
+		arg.ReturnTo,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
+const createUserIdentity = `-- name: CreateUserIdentity :one
+INSERT INTO user_identities (
+    user_id, provider, subject, email
+) VALUES (
+    $1, $2, $3, $4
+)
+ON CONFLICT (provider, subject) DO UPDATE
+SET last_used_at = NOW()
+RETURNING id, user_id, provider, subject, email, created_at, last_used_at
+`
+
+type CreateUserIdentityParams struct {
+	UserID   string `json:"user_id"`
+	Provider string `json:"provider"`
+	Subject  string `json:"subject"`
+	Email    string `json:"email"`
+}
+
+func (q *Queries) CreateUserIdentity(ctx context.Context, arg CreateUserIdentityParams) (UserIdentity, error) {
+	row := q.db.QueryRow(ctx, createUserIdentity,
+		arg.UserID,
+		arg.Provider,
+		arg.Subject,
+		arg.Email,
+	)
+	var i UserIdentity
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Provider,
+		&i.Subject,
+		&i.Email,
+		&i.CreatedAt,
+		&i.LastUsedAt,
+	)
+	return i, err
+}
+
+const getUserIdentity = `-- name: GetUserIdentity :one
+SELECT id, user_id, provider, subject, email, created_at, last_used_at FROM user_identities WHERE provider = $1 AND subject = $2
+`
+
+type GetUserIdentityParams struct {
+	Provider string `json:"provider"`
+	Subject  string `json:"subject"`
+}
+
+func (q *Queries) GetUserIdentity(ctx context.Context, arg GetUserIdentityParams) (UserIdentity, error) {
+	row := q.db.QueryRow(ctx, getUserIdentity, arg.Provider, arg.Subject)
+	var i UserIdentity
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Provider,
+		&i.Subject,
+		&i.Email,
+		&i.CreatedAt,
+		&i.LastUsedAt,
+	)
+	return i, err
+}
+
+const listUserIdentities = `-- name: ListUserIdentities :many
+SELECT id, user_id, provider, subject, email, created_at, last_used_at FROM user_identities WHERE user_id = $1 ORDER BY created_at
+`
+
+func (q *Queries) ListUserIdentities(ctx context.Context, userID string) ([]UserIdentity, error) {
+	rows, err := q.db.Query(ctx, listUserIdentities, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UserIdentity
+	for rows.Next() {
+		var i UserIdentity
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Provider,
+			&i.Subject,
+			&i.Email,
+			&i.CreatedAt,
+			&i.LastUsedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const touchUserIdentity = `-- name: TouchUserIdentity :exec
+UPDATE user_identities
+SET email = $2, last_used_at = NOW()
+WHERE id = $1
+`
+
+type TouchUserIdentityParams struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+}
+
+func (q *Queries) TouchUserIdentity(ctx context.Context, arg TouchUserIdentityParams) error {
+	_, err := q.db.Exec(ctx, touchUserIdentity, arg.ID, arg.Email)
+	return err
+}
@@ -0,0 +1,19 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: instance_changes.sql
+
+package db
+
+import (
+	"context"
+)
+
+const listenInstanceChanges = `-- name: ListenInstanceChanges :exec
+LISTEN instance_changes
+`
+
+func (q *Queries) ListenInstanceChanges(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, listenInstanceChanges)
+	return err
+}
@@ -0,0 +1,242 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: instance_monitors.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createInstanceHealthCheck = `-- name: CreateInstanceHealthCheck :exec
+INSERT INTO instance_health_checks (instance_id, up, latency_ms, error)
+VALUES ($1, $2, $3, $4)
+`
+
+type CreateInstanceHealthCheckParams struct {
+	InstanceID string `json:"instance_id"`
+	Up         bool   `json:"up"`
+	LatencyMs  int32  `json:"latency_ms"`
+	Error      string `json:"error"`
+}
+
+func (q *Queries) CreateInstanceHealthCheck(ctx context.Context, arg CreateInstanceHealthCheckParams) error {
+	_, err := q.db.Exec(ctx, createInstanceHealthCheck,
+		arg.InstanceID,
+		arg.Up,
+		arg.LatencyMs,
+		arg.Error,
+	)
+	return err
+}
+
+const deleteInstanceHealthChecksBefore = `-- name: DeleteInstanceHealthChecksBefore :execrows
+DELETE FROM instance_health_checks WHERE checked_at < $1
+`
+
+func (q *Queries) DeleteInstanceHealthChecksBefore(ctx context.Context, before pgtype.Timestamp) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteInstanceHealthChecksBefore, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getInstanceMonitor = `-- name: GetInstanceMonitor :one
+SELECT instance_id, status, consecutive_failures, failing_since, last_error, last_checked_at, status_changed_at, alert_webhook_url, created_at, updated_at FROM instance_monitors WHERE instance_id = $1
+`
+
+func (q *Queries) GetInstanceMonitor(ctx context.Context, instanceID string) (InstanceMonitor, error) {
+	row := q.db.QueryRow(ctx, getInstanceMonitor, instanceID)
+	var i InstanceMonitor
+	err := row.Scan(
+		&i.InstanceID,
+		&i.Status,
+		&i.ConsecutiveFailures,
+		&i.FailingSince,
+		&i.LastError,
+		&i.LastCheckedAt,
+		&i.StatusChangedAt,
+		&i.AlertWebhookUrl,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getInstanceMonitorForUpdate = `-- name: GetInstanceMonitorForUpdate :one
+SELECT instance_id, status, consecutive_failures, failing_since, last_error, last_checked_at, status_changed_at, alert_webhook_url, created_at, updated_at FROM instance_monitors WHERE instance_id = $1 FOR UPDATE
+`
+
+func (q *Queries) GetInstanceMonitorForUpdate(ctx context.Context, instanceID string) (InstanceMonitor, error) {
+	row := q.db.QueryRow(ctx, getInstanceMonitorForUpdate, instanceID)
+	var i InstanceMonitor
+	err := row.Scan(
+		&i.InstanceID,
+		&i.Status,
+		&i.ConsecutiveFailures,
+		&i.FailingSince,
+		&i.LastError,
+		&i.LastCheckedAt,
+		&i.StatusChangedAt,
+		&i.AlertWebhookUrl,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getInstanceUptime = `-- name: GetInstanceUptime :one
+SELECT
+    COUNT(*) AS total,
+    COUNT(*) FILTER (WHERE up) AS up
+FROM instance_health_checks
+WHERE instance_id = $1 AND checked_at >= $2
+`
+
+type GetInstanceUptimeParams struct {
+	InstanceID string           `json:"instance_id"`
+	Since      pgtype.Timestamp `json:"since"`
+}
+
+type GetInstanceUptimeRow struct {
+	Total int64 `json:"total"`
+	Up    int64 `json:"up"`
+}
+
+func (q *Queries) GetInstanceUptime(ctx context.Context, arg GetInstanceUptimeParams) (GetInstanceUptimeRow, error) {
+	row := q.db.QueryRow(ctx, getInstanceUptime, arg.InstanceID, arg.Since)
+	var i GetInstanceUptimeRow
+	err := row.Scan(&i.Total, &i.Up)
+	return i, err
+}
+
+const listMonitoredInstances = `-- name: ListMonitoredInstances :many
+SELECT id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version, custom_env, organization_id, owner_setup_at FROM instances
+WHERE deleted_at IS NULL
+  AND status IN ('deployed', 'active')
+  AND owner_setup_at IS NOT NULL
+ORDER BY id
+`
+
+// Running instances that started once, new ones are watched by provisioning until they are ready
+func (q *Queries) ListMonitoredInstances(ctx context.Context) ([]Instance, error) {
+	rows, err := q.db.Query(ctx, listMonitoredInstances)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Instance
+	for rows.Next() {
+		var i Instance
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Status,
+			&i.Namespace,
+			&i.Subdomain,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeployedAt,
+			&i.DeletedAt,
+			&i.AppVersion,
+			&i.CustomEnv,
+			&i.OrganizationID,
+			&i.OwnerSetupAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertInstanceMonitorCheck = `-- name: UpsertInstanceMonitorCheck :one
+INSERT INTO instance_monitors (
+    instance_id, status, consecutive_failures, failing_since, last_error, last_checked_at, status_changed_at
+) VALUES (
+    $1, $2, $3, $4, $5, NOW(), NOW()
+)
+ON CONFLICT (instance_id) DO UPDATE
+SET status = EXCLUDED.status,
+    consecutive_failures = EXCLUDED.consecutive_failures,
+    failing_since = EXCLUDED.failing_since,
+    last_error = EXCLUDED.last_error,
+    last_checked_at = EXCLUDED.last_checked_at,
+    status_changed_at = CASE
+        WHEN instance_monitors.status = EXCLUDED.status THEN instance_monitors.status_changed_at
+        ELSE EXCLUDED.status_changed_at
+    END,
+    updated_at = NOW()
+RETURNING instance_id, status, consecutive_failures, failing_since, last_error, last_checked_at, status_changed_at, alert_webhook_url, created_at, updated_at
+`
+
+type UpsertInstanceMonitorCheckParams struct {
+	InstanceID          string           `json:"instance_id"`
+	Status              string           `json:"status"`
+	ConsecutiveFailures int32            `json:"consecutive_failures"`
+	FailingSince        pgtype.Timestamp `json:"failing_since"`
+	LastError           string           `json:"last_error"`
+}
+
+func (q *Queries) UpsertInstanceMonitorCheck(ctx context.Context, arg UpsertInstanceMonitorCheckParams) (InstanceMonitor, error) {
+	row := q.db.QueryRow(ctx, upsertInstanceMonitorCheck,
+		arg.InstanceID,
+		arg.Status,
+		arg.ConsecutiveFailures,
+		arg.FailingSince,
+		arg.LastError,
+	)
+	var i InstanceMonitor
+	err := row.Scan(
+		&i.InstanceID,
+		&i.Status,
+		&i.ConsecutiveFailures,
+		&i.FailingSince,
+		&i.LastError,
+		&i.LastCheckedAt,
+		&i.StatusChangedAt,
+		&i.AlertWebhookUrl,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const upsertInstanceMonitorWebhook = `-- name: UpsertInstanceMonitorWebhook :one
+INSERT INTO instance_monitors (instance_id, alert_webhook_url)
+VALUES ($1, $2)
+ON CONFLICT (instance_id) DO UPDATE
+SET alert_webhook_url = EXCLUDED.alert_webhook_url,
+    updated_at = NOW()
+RETURNING instance_id, status, consecutive_failures, failing_since, last_error, last_checked_at, status_changed_at, alert_webhook_url, created_at, updated_at
+`
+
+type UpsertInstanceMonitorWebhookParams struct {
+	InstanceID      string `json:"instance_id"`
+	AlertWebhookUrl string `json:"alert_webhook_url"`
+}
+
+func (q *Queries) UpsertInstanceMonitorWebhook(ctx context.Context, arg UpsertInstanceMonitorWebhookParams) (InstanceMonitor, error) {
+	row := q.db.QueryRow(ctx, upsertInstanceMonitorWebhook, arg.InstanceID, arg.AlertWebhookUrl)
+	var i InstanceMonitor
+	err := row.Scan(
+		&i.InstanceID,
+		&i.Status,
+		&i.ConsecutiveFailures,
+		&i.FailingSince,
+		&i.LastError,
+		&i.LastCheckedAt,
+		&i.StatusChangedAt,
+		&i.AlertWebhookUrl,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
@@ -7,6 +7,8 @@ package db
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const checkNamespaceExists = `-- name: CheckNamespaceExists :one
@@ -31,36 +33,95 @@ func (q *Queries) CheckSubdomainExists(ctx context.Context, subdomain string) (b
 	return exists, err
 }
 
-const countActiveInstancesByUserID = `-- name: CountActiveInstancesByUserID :one
-SELECT COUNT(*) FROM instances WHERE user_id = $1 AND deleted_at IS NULL
+const consumeEditorLoginToken = `-- name: ConsumeEditorLoginToken :one
+UPDATE editor_login_tokens
+SET used_at = NOW()
+WHERE token_hash = $1 AND instance_id = $2 AND used_at IS NULL AND expires_at > NOW()
+RETURNING token_hash, instance_id, user_id, return_to, expires_at, used_at, created_at, session_id
+`
+
+type ConsumeEditorLoginTokenParams struct {
+	TokenHash  string `json:"token_hash"`
+	InstanceID string `json:"instance_id"`
+}
+
+func (q *Queries) ConsumeEditorLoginToken(ctx context.Context, arg ConsumeEditorLoginTokenParams) (EditorLoginToken, error) {
+	row := q.db.QueryRow(ctx, consumeEditorLoginToken, arg.TokenHash, arg.InstanceID)
+	var i EditorLoginToken
+	err := row.Scan(
+		&i.TokenHash,
+		&i.InstanceID,
+		&i.UserID,
+		&i.ReturnTo,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+		&i.SessionID,
+	)
+	return i, err
+}
+
+const countActiveInstancesByOrganizationID = `-- name: CountActiveInstancesByOrganizationID :one
+SELECT COUNT(*) FROM instances WHERE organization_id = $1 AND deleted_at IS NULL
 `
 
-func (q *Queries) CountActiveInstancesByUserID(ctx context.Context, userID string) (int64, error) {
-	row := q.db.QueryRow(ctx, countActiveInstancesByUserID, userID)
+func (q *Queries) CountActiveInstancesByOrganizationID(ctx context.Context, organizationID string) (int64, error) {
+	row := q.db.QueryRow(ctx, countActiveInstancesByOrganizationID, organizationID)
 	var count int64
 	err := row.Scan(&count)
 	return count, err
 }
 
+const createEditorLoginToken = `-- name: CreateEditorLoginToken :exec
+INSERT INTO editor_login_tokens (
+    token_hash, instance_id, user_id, session_id, return_to, expires_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+`
+
+type CreateEditorLoginTokenParams struct {
+	TokenHash  string           `json:"token_hash"`
+	InstanceID string           `json:"instance_id"`
+	UserID     string           `json:"user_id"`
+	SessionID  *string          `json:"session_id"`
+	ReturnTo   string           `json:"return_to"`
+	ExpiresAt  pgtype.Timestamp `json:"expires_at"`
+}
+
+func (q *Queries) CreateEditorLoginToken(ctx context.Context, arg CreateEditorLoginTokenParams) error {
+	_, err := q.db.Exec(ctx, createEditorLoginToken,
+		arg.TokenHash,
+		arg.InstanceID,
+		arg.UserID,
+		arg.SessionID,
+		arg.ReturnTo,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
 const createInstance = `-- name: CreateInstance :one
 INSERT INTO instances (
-    user_id, namespace, subdomain, status, app_version
+    user_id, organization_id, namespace, subdomain, status, app_version
 ) VALUES (
-    $1, $2, $3, $4, $5
-) RETURNING id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version
+    $1, $2, $3, $4, $5, $6
+) RETURNING id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version, custom_env, organization_id, owner_setup_at
 `
 
 type CreateInstanceParams struct {
-	UserID     string `json:"user_id"`
-	Namespace  string `json:"namespace"`
-	Subdomain  string `json:"subdomain"`
-	Status     string `json:"status"`
-	AppVersion string `json:"app_version"`
+	UserID         string `json:"user_id"`
+	OrganizationID string `json:"organization_id"`
+	Namespace      string `json:"namespace"`
+	Subdomain      string `json:"subdomain"`
+	Status         string `json:"status"`
+	AppVersion     string `json:"app_version"`
 }
 
 func (q *Queries) CreateInstance(ctx context.Context, arg CreateInstanceParams) (Instance, error) {
 	row := q.db.QueryRow(ctx, createInstance,
 		arg.UserID,
+		arg.OrganizationID,
 		arg.Namespace,
 		arg.Subdomain,
 		arg.Status,
@@ -78,24 +139,33 @@ func (q *Queries) CreateInstance(ctx context.Context, arg CreateInstanceParams)
 		&i.DeployedAt,
 		&i.DeletedAt,
 		&i.AppVersion,
+		&i.CustomEnv,
+		&i.OrganizationID,
+		&i.OwnerSetupAt,
 	)
 	return i, err
 }
 
 const deleteInstance = `-- name: DeleteInstance :exec
-UPDATE instances 
+WITH overrides AS (
+    DELETE FROM instance_limit_overrides WHERE instance_id = $1
+), policies AS (
+    DELETE FROM instance_access_policies WHERE instance_id = $1
+)
+UPDATE instances
 SET deleted_at = NOW(), updated_at = NOW()
 WHERE id = $1
-RETURNING id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version
+RETURNING id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version, custom_env, organization_id, owner_setup_at
 `
 
+// Instances are kept for the audit log, the settings that only apply to a running instance go with it
 func (q *Queries) DeleteInstance(ctx context.Context, id string) error {
 	_, err := q.db.Exec(ctx, deleteInstance, id)
 	return err
 }
 
 const getInstance = `-- name: GetInstance :one
-SELECT id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version FROM instances WHERE id = $1 AND deleted_at IS NULL
+SELECT id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version, custom_env, organization_id, owner_setup_at FROM instances WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) GetInstance(ctx context.Context, id string) (Instance, error) {
@@ -112,12 +182,15 @@ func (q *Queries) GetInstance(ctx context.Context, id string) (Instance, error)
 		&i.DeployedAt,
 		&i.DeletedAt,
 		&i.AppVersion,
+		&i.CustomEnv,
+		&i.OrganizationID,
+		&i.OwnerSetupAt,
 	)
 	return i, err
 }
 
 const getInstanceByNamespace = `-- name: GetInstanceByNamespace :one
-SELECT id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version FROM instances WHERE namespace = $1 AND deleted_at IS NULL
+SELECT id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version, custom_env, organization_id, owner_setup_at FROM instances WHERE namespace = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) GetInstanceByNamespace(ctx context.Context, namespace string) (Instance, error) {
@@ -134,12 +207,15 @@ func (q *Queries) GetInstanceByNamespace(ctx context.Context, namespace string)
 		&i.DeployedAt,
 		&i.DeletedAt,
 		&i.AppVersion,
+		&i.CustomEnv,
+		&i.OrganizationID,
+		&i.OwnerSetupAt,
 	)
 	return i, err
 }
 
 const getInstanceBySubdomain = `-- name: GetInstanceBySubdomain :one
-SELECT id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version FROM instances WHERE subdomain = $1 AND deleted_at IS NULL
+SELECT id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version, custom_env, organization_id, owner_setup_at FROM instances WHERE subdomain = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) GetInstanceBySubdomain(ctx context.Context, subdomain string) (Instance, error) {
@@ -156,12 +232,15 @@ func (q *Queries) GetInstanceBySubdomain(ctx context.Context, subdomain string)
 		&i.DeployedAt,
 		&i.DeletedAt,
 		&i.AppVersion,
+		&i.CustomEnv,
+		&i.OrganizationID,
+		&i.OwnerSetupAt,
 	)
 	return i, err
 }
 
 const getInstanceForUpdate = `-- name: GetInstanceForUpdate :one
-SELECT id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version FROM instances WHERE id = $1 AND deleted_at IS NULL FOR UPDATE
+SELECT id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version, custom_env, organization_id, owner_setup_at FROM instances WHERE id = $1 AND deleted_at IS NULL FOR UPDATE
 `
 
 func (q *Queries) GetInstanceForUpdate(ctx context.Context, id string) (Instance, error) {
@@ -178,12 +257,15 @@ func (q *Queries) GetInstanceForUpdate(ctx context.Context, id string) (Instance
 		&i.DeployedAt,
 		&i.DeletedAt,
 		&i.AppVersion,
+		&i.CustomEnv,
+		&i.OrganizationID,
+		&i.OwnerSetupAt,
 	)
 	return i, err
 }
 
 const listAllInstances = `-- name: ListAllInstances :many
-SELECT id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version FROM instances 
+SELECT id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version, custom_env, organization_id, owner_setup_at FROM instances 
 WHERE deleted_at IS NULL
 ORDER BY created_at DESC
 LIMIT $1 OFFSET $2
@@ -214,6 +296,9 @@ func (q *Queries) ListAllInstances(ctx context.Context, arg ListAllInstancesPara
 			&i.DeployedAt,
 			&i.DeletedAt,
 			&i.AppVersion,
+			&i.CustomEnv,
+			&i.OrganizationID,
+			&i.OwnerSetupAt,
 		); err != nil {
 			return nil, err
 		}
@@ -225,14 +310,14 @@ func (q *Queries) ListAllInstances(ctx context.Context, arg ListAllInstancesPara
 	return items, nil
 }
 
-const listInstancesByUser = `-- name: ListInstancesByUser :many
-SELECT id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version FROM instances 
-WHERE user_id = $1 AND deleted_at IS NULL
+const listInstancesByOrganization = `-- name: ListInstancesByOrganization :many
+SELECT id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version, custom_env, organization_id, owner_setup_at FROM instances
+WHERE organization_id = $1 AND deleted_at IS NULL
 ORDER BY created_at DESC
 `
 
-func (q *Queries) ListInstancesByUser(ctx context.Context, userID string) ([]Instance, error) {
-	rows, err := q.db.Query(ctx, listInstancesByUser, userID)
+func (q *Queries) ListInstancesByOrganization(ctx context.Context, organizationID string) ([]Instance, error) {
+	rows, err := q.db.Query(ctx, listInstancesByOrganization, organizationID)
 	if err != nil {
 		return nil, err
 	}
@@ -251,6 +336,9 @@ func (q *Queries) ListInstancesByUser(ctx context.Context, userID string) ([]Ins
 			&i.DeployedAt,
 			&i.DeletedAt,
 			&i.AppVersion,
+			&i.CustomEnv,
+			&i.OrganizationID,
+			&i.OwnerSetupAt,
 		); err != nil {
 			return nil, err
 		}
@@ -262,11 +350,85 @@ func (q *Queries) ListInstancesByUser(ctx context.Context, userID string) ([]Ins
 	return items, nil
 }
 
+const markInstanceOwnerSetup = `-- name: MarkInstanceOwnerSetup :exec
+UPDATE instances
+SET owner_setup_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) MarkInstanceOwnerSetup(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, markInstanceOwnerSetup, id)
+	return err
+}
+
+const revertInstanceConfig = `-- name: RevertInstanceConfig :execrows
+UPDATE instances
+SET app_version = $1, custom_env = $2, updated_at = NOW()
+WHERE id = $3 AND app_version = $4 AND custom_env = $5
+`
+
+type RevertInstanceConfigParams struct {
+	PreviousAppVersion string `json:"previous_app_version"`
+	PreviousCustomEnv  []byte `json:"previous_custom_env"`
+	ID                 string `json:"id"`
+	AppVersion         string `json:"app_version"`
+	CustomEnv          []byte `json:"custom_env"`
+}
+
+// Restores the previous configuration unless another update changed it since
+func (q *Queries) RevertInstanceConfig(ctx context.Context, arg RevertInstanceConfigParams) (int64, error) {
+	result, err := q.db.Exec(ctx, revertInstanceConfig,
+		arg.PreviousAppVersion,
+		arg.PreviousCustomEnv,
+		arg.ID,
+		arg.AppVersion,
+		arg.CustomEnv,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateInstanceConfig = `-- name: UpdateInstanceConfig :one
+UPDATE instances
+SET app_version = $2, custom_env = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version, custom_env, organization_id, owner_setup_at
+`
+
+type UpdateInstanceConfigParams struct {
+	ID         string `json:"id"`
+	AppVersion string `json:"app_version"`
+	CustomEnv  []byte `json:"custom_env"`
+}
+
+func (q *Queries) UpdateInstanceConfig(ctx context.Context, arg UpdateInstanceConfigParams) (Instance, error) {
+	row := q.db.QueryRow(ctx, updateInstanceConfig, arg.ID, arg.AppVersion, arg.CustomEnv)
+	var i Instance
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Status,
+		&i.Namespace,
+		&i.Subdomain,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeployedAt,
+		&i.DeletedAt,
+		&i.AppVersion,
+		&i.CustomEnv,
+		&i.OrganizationID,
+		&i.OwnerSetupAt,
+	)
+	return i, err
+}
+
 const updateInstanceDeployed = `-- name: UpdateInstanceDeployed :one
 UPDATE instances 
 SET status = $2, deployed_at = NOW(), updated_at = NOW()
 WHERE id = $1
-RETURNING id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version
+RETURNING id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version, custom_env, organization_id, owner_setup_at
 `
 
 type UpdateInstanceDeployedParams struct {
@@ -288,6 +450,9 @@ func (q *Queries) UpdateInstanceDeployed(ctx context.Context, arg UpdateInstance
 		&i.DeployedAt,
 		&i.DeletedAt,
 		&i.AppVersion,
+		&i.CustomEnv,
+		&i.OrganizationID,
+		&i.OwnerSetupAt,
 	)
 	return i, err
 }
@@ -296,7 +461,7 @@ const updateInstanceNamespace = `-- name: UpdateInstanceNamespace :one
 UPDATE instances 
 SET namespace = $2, updated_at = NOW()
 WHERE id = $1
-RETURNING id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version
+RETURNING id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version, custom_env, organization_id, owner_setup_at
 `
 
 type UpdateInstanceNamespaceParams struct {
@@ -318,6 +483,9 @@ func (q *Queries) UpdateInstanceNamespace(ctx context.Context, arg UpdateInstanc
 		&i.DeployedAt,
 		&i.DeletedAt,
 		&i.AppVersion,
+		&i.CustomEnv,
+		&i.OrganizationID,
+		&i.OwnerSetupAt,
 	)
 	return i, err
 }
@@ -326,7 +494,7 @@ const updateInstanceStatus = `-- name: UpdateInstanceStatus :one
 UPDATE instances 
 SET status = $2, updated_at = NOW()
 WHERE id = $1
-RETURNING id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version
+RETURNING id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version, custom_env, organization_id, owner_setup_at
 `
 
 type UpdateInstanceStatusParams struct {
@@ -348,6 +516,9 @@ func (q *Queries) UpdateInstanceStatus(ctx context.Context, arg UpdateInstanceSt
 		&i.DeployedAt,
 		&i.DeletedAt,
 		&i.AppVersion,
+		&i.CustomEnv,
+		&i.OrganizationID,
+		&i.OwnerSetupAt,
 	)
 	return i, err
 }
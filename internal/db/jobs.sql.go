@@ -0,0 +1,299 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: jobs.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimDueJobSchedules = `-- name: ClaimDueJobSchedules :many
+SELECT kind, spec, next_run_at, updated_at FROM job_schedules
+WHERE kind = ANY($1::varchar[]) AND next_run_at <= NOW()
+FOR UPDATE SKIP LOCKED
+`
+
+// Locks due schedules for the rest of the transaction, other replicas skip them
+func (q *Queries) ClaimDueJobSchedules(ctx context.Context, kinds []string) ([]JobSchedule, error) {
+	rows, err := q.db.Query(ctx, claimDueJobSchedules, kinds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []JobSchedule
+	for rows.Next() {
+		var i JobSchedule
+		if err := rows.Scan(
+			&i.Kind,
+			&i.Spec,
+			&i.NextRunAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const claimJobs = `-- name: ClaimJobs :many
+UPDATE jobs
+SET status = 'running',
+    attempts = attempts + 1,
+    locked_until = $1::timestamp,
+    updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM jobs
+    WHERE kind = ANY($2::varchar[])
+      AND ((status = 'pending' AND run_at <= NOW()) OR (status = 'running' AND locked_until < NOW()))
+    ORDER BY run_at
+    LIMIT $3
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, kind, args, status, unique_key, attempts, max_attempts, run_at, locked_until, last_error, created_at, updated_at, finished_at
+`
+
+type ClaimJobsParams struct {
+	LockedUntil pgtype.Timestamp `json:"locked_until"`
+	Kinds       []string         `json:"kinds"`
+	MaxJobs     int32            `json:"max_jobs"`
+}
+
+// Claims due jobs, and running jobs whose worker stopped extending the lease
+func (q *Queries) ClaimJobs(ctx context.Context, arg ClaimJobsParams) ([]Job, error) {
+	rows, err := q.db.Query(ctx, claimJobs, arg.LockedUntil, arg.Kinds, arg.MaxJobs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Job
+	for rows.Next() {
+		var i Job
+		if err := rows.Scan(
+			&i.ID,
+			&i.Kind,
+			&i.Args,
+			&i.Status,
+			&i.UniqueKey,
+			&i.Attempts,
+			&i.MaxAttempts,
+			&i.RunAt,
+			&i.LockedUntil,
+			&i.LastError,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FinishedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const completeJob = `-- name: CompleteJob :execrows
+UPDATE jobs
+SET status = 'succeeded', locked_until = NULL, finished_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND attempts = $2 AND status = 'running'
+`
+
+type CompleteJobParams struct {
+	ID       string `json:"id"`
+	Attempts int32  `json:"attempts"`
+}
+
+func (q *Queries) CompleteJob(ctx context.Context, arg CompleteJobParams) (int64, error) {
+	result, err := q.db.Exec(ctx, completeJob, arg.ID, arg.Attempts)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteFinishedJobs = `-- name: DeleteFinishedJobs :execrows
+DELETE FROM jobs WHERE finished_at < $1
+`
+
+func (q *Queries) DeleteFinishedJobs(ctx context.Context, finishedAt pgtype.Timestamp) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteFinishedJobs, finishedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const extendJobLease = `-- name: ExtendJobLease :execrows
+UPDATE jobs SET locked_until = $2, updated_at = NOW()
+WHERE id = $1 AND attempts = $3 AND status = 'running'
+`
+
+type ExtendJobLeaseParams struct {
+	ID          string           `json:"id"`
+	LockedUntil pgtype.Timestamp `json:"locked_until"`
+	Attempts    int32            `json:"attempts"`
+}
+
+// This and the outcome queries below match the attempt that ran, so a worker whose lease
+// ran out can't touch the job once another replica claimed it again
+func (q *Queries) ExtendJobLease(ctx context.Context, arg ExtendJobLeaseParams) (int64, error) {
+	result, err := q.db.Exec(ctx, extendJobLease, arg.ID, arg.LockedUntil, arg.Attempts)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const failJob = `-- name: FailJob :execrows
+UPDATE jobs
+SET status = 'failed', locked_until = NULL, last_error = $3, finished_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND attempts = $2 AND status = 'running'
+`
+
+type FailJobParams struct {
+	ID        string `json:"id"`
+	Attempts  int32  `json:"attempts"`
+	LastError string `json:"last_error"`
+}
+
+func (q *Queries) FailJob(ctx context.Context, arg FailJobParams) (int64, error) {
+	result, err := q.db.Exec(ctx, failJob, arg.ID, arg.Attempts, arg.LastError)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const insertJob = `-- name: InsertJob :one
+INSERT INTO jobs (kind, args, unique_key, max_attempts, run_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (kind, unique_key) WHERE unique_key IS NOT NULL AND status IN ('pending', 'running')
+DO NOTHING
+RETURNING id, kind, args, status, unique_key, attempts, max_attempts, run_at, locked_until, last_error, created_at, updated_at, finished_at
+`
+
+type InsertJobParams struct {
+	Kind        string           `json:"kind"`
+	Args        []byte           `json:"args"`
+	UniqueKey   pgtype.Text      `json:"unique_key"`
+	MaxAttempts int32            `json:"max_attempts"`
+	RunAt       pgtype.Timestamp `json:"run_at"`
+}
+
+// Returns no rows when a pending or running job of the kind already has the unique key
+func (q *Queries) InsertJob(ctx context.Context, arg InsertJobParams) (Job, error) {
+	row := q.db.QueryRow(ctx, insertJob,
+		arg.Kind,
+		arg.Args,
+		arg.UniqueKey,
+		arg.MaxAttempts,
+		arg.RunAt,
+	)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.Args,
+		&i.Status,
+		&i.UniqueKey,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.RunAt,
+		&i.LockedUntil,
+		&i.LastError,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const releaseJob = `-- name: ReleaseJob :execrows
+UPDATE jobs
+SET status = 'pending', attempts = attempts - 1, locked_until = NULL, run_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND attempts = $2 AND status = 'running'
+`
+
+type ReleaseJobParams struct {
+	ID       string `json:"id"`
+	Attempts int32  `json:"attempts"`
+}
+
+// Hands back a job interrupted by shutdown, the attempt doesn't count
+func (q *Queries) ReleaseJob(ctx context.Context, arg ReleaseJobParams) (int64, error) {
+	result, err := q.db.Exec(ctx, releaseJob, arg.ID, arg.Attempts)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const retryJob = `-- name: RetryJob :execrows
+UPDATE jobs
+SET status = 'pending', locked_until = NULL, run_at = $3, last_error = $4, updated_at = NOW()
+WHERE id = $1 AND attempts = $2 AND status = 'running'
+`
+
+type RetryJobParams struct {
+	ID        string           `json:"id"`
+	Attempts  int32            `json:"attempts"`
+	RunAt     pgtype.Timestamp `json:"run_at"`
+	LastError string           `json:"last_error"`
+}
+
+func (q *Queries) RetryJob(ctx context.Context, arg RetryJobParams) (int64, error) {
+	result, err := q.db.Exec(ctx, retryJob,
+		arg.ID,
+		arg.Attempts,
+		arg.RunAt,
+		arg.LastError,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateJobScheduleNextRun = `-- name: UpdateJobScheduleNextRun :exec
+UPDATE job_schedules SET next_run_at = $2, updated_at = NOW() WHERE kind = $1
+`
+
+type UpdateJobScheduleNextRunParams struct {
+	Kind      string           `json:"kind"`
+	NextRunAt pgtype.Timestamp `json:"next_run_at"`
+}
+
+func (q *Queries) UpdateJobScheduleNextRun(ctx context.Context, arg UpdateJobScheduleNextRunParams) error {
+	_, err := q.db.Exec(ctx, updateJobScheduleNextRun, arg.Kind, arg.NextRunAt)
+	return err
+}
+
+const upsertJobSchedule = `-- name: UpsertJobSchedule :exec
+INSERT INTO job_schedules (kind, spec, next_run_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (kind) DO UPDATE
+SET spec = EXCLUDED.spec,
+    next_run_at = CASE WHEN job_schedules.spec = EXCLUDED.spec THEN job_schedules.next_run_at ELSE EXCLUDED.next_run_at END,
+    updated_at = NOW()
+`
+
+type UpsertJobScheduleParams struct {
+	Kind      string           `json:"kind"`
+	Spec      string           `json:"spec"`
+	NextRunAt pgtype.Timestamp `json:"next_run_at"`
+}
+
+// Keeps the next run of an unchanged schedule, so restarts don't skip or repeat runs
+func (q *Queries) UpsertJobSchedule(ctx context.Context, arg UpsertJobScheduleParams) error {
+	_, err := q.db.Exec(ctx, upsertJobSchedule, arg.Kind, arg.Spec, arg.NextRunAt)
+	return err
+}
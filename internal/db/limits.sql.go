@@ -0,0 +1,254 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: limits.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const deleteInstanceLimitOverride = `-- name: DeleteInstanceLimitOverride :exec
+DELETE FROM instance_limit_overrides WHERE instance_id = $1
+`
+
+func (q *Queries) DeleteInstanceLimitOverride(ctx context.Context, instanceID string) error {
+	_, err := q.db.Exec(ctx, deleteInstanceLimitOverride, instanceID)
+	return err
+}
+
+const getInstanceLimitOverride = `-- name: GetInstanceLimitOverride :one
+SELECT instance_id, requests_per_second, burst, ip_requests_per_second, ip_burst, max_body_bytes, max_concurrent, created_at, updated_at FROM instance_limit_overrides WHERE instance_id = $1
+`
+
+func (q *Queries) GetInstanceLimitOverride(ctx context.Context, instanceID string) (InstanceLimitOverride, error) {
+	row := q.db.QueryRow(ctx, getInstanceLimitOverride, instanceID)
+	var i InstanceLimitOverride
+	err := row.Scan(
+		&i.InstanceID,
+		&i.RequestsPerSecond,
+		&i.Burst,
+		&i.IpRequestsPerSecond,
+		&i.IpBurst,
+		&i.MaxBodyBytes,
+		&i.MaxConcurrent,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getPlanLimits = `-- name: GetPlanLimits :one
+SELECT plan, requests_per_second, burst, ip_requests_per_second, ip_burst, max_body_bytes, max_concurrent, created_at, updated_at FROM plan_limits WHERE plan = $1
+`
+
+func (q *Queries) GetPlanLimits(ctx context.Context, plan string) (PlanLimit, error) {
+	row := q.db.QueryRow(ctx, getPlanLimits, plan)
+	var i PlanLimit
+	err := row.Scan(
+		&i.Plan,
+		&i.RequestsPerSecond,
+		&i.Burst,
+		&i.IpRequestsPerSecond,
+		&i.IpBurst,
+		&i.MaxBodyBytes,
+		&i.MaxConcurrent,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const listInstanceLimitOverrides = `-- name: ListInstanceLimitOverrides :many
+SELECT instance_limit_overrides.instance_id, instance_limit_overrides.requests_per_second, instance_limit_overrides.burst, instance_limit_overrides.ip_requests_per_second, instance_limit_overrides.ip_burst, instance_limit_overrides.max_body_bytes, instance_limit_overrides.max_concurrent, instance_limit_overrides.created_at, instance_limit_overrides.updated_at, instances.subdomain
+FROM instance_limit_overrides
+JOIN instances ON instances.id = instance_limit_overrides.instance_id
+WHERE instances.deleted_at IS NULL
+ORDER BY instances.subdomain
+`
+
+type ListInstanceLimitOverridesRow struct {
+	InstanceID          string           `json:"instance_id"`
+	RequestsPerSecond   pgtype.Int4      `json:"requests_per_second"`
+	Burst               pgtype.Int4      `json:"burst"`
+	IpRequestsPerSecond pgtype.Int4      `json:"ip_requests_per_second"`
+	IpBurst             pgtype.Int4      `json:"ip_burst"`
+	MaxBodyBytes        pgtype.Int8      `json:"max_body_bytes"`
+	MaxConcurrent       pgtype.Int4      `json:"max_concurrent"`
+	CreatedAt           pgtype.Timestamp `json:"created_at"`
+	UpdatedAt           pgtype.Timestamp `json:"updated_at"`
+	Subdomain           string           `json:"subdomain"`
+}
+
+// Overrides of live instances for the admin console
+func (q *Queries) ListInstanceLimitOverrides(ctx context.Context) ([]ListInstanceLimitOverridesRow, error) {
+	rows, err := q.db.Query(ctx, listInstanceLimitOverrides)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListInstanceLimitOverridesRow
+	for rows.Next() {
+		var i ListInstanceLimitOverridesRow
+		if err := rows.Scan(
+			&i.InstanceID,
+			&i.RequestsPerSecond,
+			&i.Burst,
+			&i.IpRequestsPerSecond,
+			&i.IpBurst,
+			&i.MaxBodyBytes,
+			&i.MaxConcurrent,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Subdomain,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPlanLimits = `-- name: ListPlanLimits :many
+SELECT plan, requests_per_second, burst, ip_requests_per_second, ip_burst, max_body_bytes, max_concurrent, created_at, updated_at FROM plan_limits ORDER BY plan
+`
+
+func (q *Queries) ListPlanLimits(ctx context.Context) ([]PlanLimit, error) {
+	rows, err := q.db.Query(ctx, listPlanLimits)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PlanLimit
+	for rows.Next() {
+		var i PlanLimit
+		if err := rows.Scan(
+			&i.Plan,
+			&i.RequestsPerSecond,
+			&i.Burst,
+			&i.IpRequestsPerSecond,
+			&i.IpBurst,
+			&i.MaxBodyBytes,
+			&i.MaxConcurrent,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertInstanceLimitOverride = `-- name: UpsertInstanceLimitOverride :one
+INSERT INTO instance_limit_overrides (
+    instance_id, requests_per_second, burst, ip_requests_per_second, ip_burst, max_body_bytes, max_concurrent
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+)
+ON CONFLICT (instance_id) DO UPDATE
+SET requests_per_second = EXCLUDED.requests_per_second,
+    burst = EXCLUDED.burst,
+    ip_requests_per_second = EXCLUDED.ip_requests_per_second,
+    ip_burst = EXCLUDED.ip_burst,
+    max_body_bytes = EXCLUDED.max_body_bytes,
+    max_concurrent = EXCLUDED.max_concurrent,
+    updated_at = NOW()
+RETURNING instance_id, requests_per_second, burst, ip_requests_per_second, ip_burst, max_body_bytes, max_concurrent, created_at, updated_at
+`
+
+type UpsertInstanceLimitOverrideParams struct {
+	InstanceID          string      `json:"instance_id"`
+	RequestsPerSecond   pgtype.Int4 `json:"requests_per_second"`
+	Burst               pgtype.Int4 `json:"burst"`
+	IpRequestsPerSecond pgtype.Int4 `json:"ip_requests_per_second"`
+	IpBurst             pgtype.Int4 `json:"ip_burst"`
+	MaxBodyBytes        pgtype.Int8 `json:"max_body_bytes"`
+	MaxConcurrent       pgtype.Int4 `json:"max_concurrent"`
+}
+
+func (q *Queries) UpsertInstanceLimitOverride(ctx context.Context, arg UpsertInstanceLimitOverrideParams) (InstanceLimitOverride, error) {
+	row := q.db.QueryRow(ctx, upsertInstanceLimitOverride,
+		arg.InstanceID,
+		arg.RequestsPerSecond,
+		arg.Burst,
+		arg.IpRequestsPerSecond,
+		arg.IpBurst,
+		arg.MaxBodyBytes,
+		arg.MaxConcurrent,
+	)
+	var i InstanceLimitOverride
+	err := row.Scan(
+		&i.InstanceID,
+		&i.RequestsPerSecond,
+		&i.Burst,
+		&i.IpRequestsPerSecond,
+		&i.IpBurst,
+		&i.MaxBodyBytes,
+		&i.MaxConcurrent,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const upsertPlanLimits = `-- name: UpsertPlanLimits :one
+INSERT INTO plan_limits (
+    plan, requests_per_second, burst, ip_requests_per_second, ip_burst, max_body_bytes, max_concurrent
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+)
+ON CONFLICT (plan) DO UPDATE
+SET requests_per_second = EXCLUDED.requests_per_second,
+    burst = EXCLUDED.burst,
+    ip_requests_per_second = EXCLUDED.ip_requests_per_second,
+    ip_burst = EXCLUDED.ip_burst,
+    max_body_bytes = EXCLUDED.max_body_bytes,
+    max_concurrent = EXCLUDED.max_concurrent,
+    updated_at = NOW()
+RETURNING plan, requests_per_second, burst, ip_requests_per_second, ip_burst, max_body_bytes, max_concurrent, created_at, updated_at
+`
+
+type UpsertPlanLimitsParams struct {
+	Plan                string `json:"plan"`
+	RequestsPerSecond   int32  `json:"requests_per_second"`
+	Burst               int32  `json:"burst"`
+	IpRequestsPerSecond int32  `json:"ip_requests_per_second"`
+	IpBurst             int32  `json:"ip_burst"`
+	MaxBodyBytes        int64  `json:"max_body_bytes"`
+	MaxConcurrent       int32  `json:"max_concurrent"`
+}
+
+func (q *Queries) UpsertPlanLimits(ctx context.Context, arg UpsertPlanLimitsParams) (PlanLimit, error) {
+	row := q.db.QueryRow(ctx, upsertPlanLimits,
+		arg.Plan,
+		arg.RequestsPerSecond,
+		arg.Burst,
+		arg.IpRequestsPerSecond,
+		arg.IpBurst,
+		arg.MaxBodyBytes,
+		arg.MaxConcurrent,
+	)
+	var i PlanLimit
+	err := row.Scan(
+		&i.Plan,
+		&i.RequestsPerSecond,
+		&i.Burst,
+		&i.IpRequestsPerSecond,
+		&i.IpBurst,
+		&i.MaxBodyBytes,
+		&i.MaxConcurrent,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
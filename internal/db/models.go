@@ -8,6 +8,34 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type ApiToken struct {
+	ID             string           `json:"id"`
+	UserID         string           `json:"user_id"`
+	Name           string           `json:"name"`
+	TokenPrefix    string           `json:"token_prefix"`
+	TokenHash      string           `json:"token_hash"`
+	Scopes         []string         `json:"scopes"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+	LastUsedAt     pgtype.Timestamp `json:"last_used_at"`
+	ExpiresAt      pgtype.Timestamp `json:"expires_at"`
+	OrganizationID string           `json:"organization_id"`
+}
+
+type AuditEvent struct {
+	ID             string           `json:"id"`
+	OrganizationID *string          `json:"organization_id"`
+	ActorType      string           `json:"actor_type"`
+	ActorUserID    *string          `json:"actor_user_id"`
+	Action         string           `json:"action"`
+	TargetType     string           `json:"target_type"`
+	TargetID       string           `json:"target_id"`
+	Metadata       []byte           `json:"metadata"`
+	IpAddress      string           `json:"ip_address"`
+	UserAgent      string           `json:"user_agent"`
+	RequestID      string           `json:"request_id"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+}
+
 type CheckoutSession struct {
 	ID          string           `json:"id"`
 	UserID      string           `json:"user_id"`
@@ -23,38 +51,277 @@ type CheckoutSession struct {
 	CompletedAt pgtype.Timestamp `json:"completed_at"`
 }
 
-type Instance struct {
-	ID         string           `json:"id"`
+type EditorLoginToken struct {
+	TokenHash  string           `json:"token_hash"`
+	InstanceID string           `json:"instance_id"`
 	UserID     string           `json:"user_id"`
-	Status     string           `json:"status"`
-	Namespace  string           `json:"namespace"`
-	Subdomain  string           `json:"subdomain"`
+	ReturnTo   string           `json:"return_to"`
+	ExpiresAt  pgtype.Timestamp `json:"expires_at"`
+	UsedAt     pgtype.Timestamp `json:"used_at"`
 	CreatedAt  pgtype.Timestamp `json:"created_at"`
-	UpdatedAt  pgtype.Timestamp `json:"updated_at"`
-	DeployedAt pgtype.Timestamp `json:"deployed_at"`
-	DeletedAt  pgtype.Timestamp `json:"deleted_at"`
-	AppVersion string           `json:"app_version"`
+	SessionID  *string          `json:"session_id"`
 }
 
-type Subscription struct {
+type GateExchangeToken struct {
+	ID         string           `json:"id"`
+	InstanceID string           `json:"instance_id"`
+	ExpiresAt  pgtype.Timestamp `json:"expires_at"`
+	UsedAt     pgtype.Timestamp `json:"used_at"`
+	CreatedAt  pgtype.Timestamp `json:"created_at"`
+}
+
+type IdempotencyKey struct {
+	ID              string           `json:"id"`
+	UserID          string           `json:"user_id"`
+	IdempotencyKey  string           `json:"idempotency_key"`
+	RequestHash     string           `json:"request_hash"`
+	ResponseStatus  pgtype.Int4      `json:"response_status"`
+	ResponseHeaders []byte           `json:"response_headers"`
+	ResponseBody    []byte           `json:"response_body"`
+	CreatedAt       pgtype.Timestamp `json:"created_at"`
+	ExpiresAt       pgtype.Timestamp `json:"expires_at"`
+}
+
+type Instance struct {
 	ID             string           `json:"id"`
 	UserID         string           `json:"user_id"`
-	ProductID      string           `json:"product_id"`
-	VariantID      string           `json:"variant_id"`
-	CustomerID     string           `json:"customer_id"`
-	SubscriptionID string           `json:"subscription_id"`
 	Status         string           `json:"status"`
-	Quantity       int32            `json:"quantity"`
-	TrialEndsAt    pgtype.Timestamp `json:"trial_ends_at"`
+	Namespace      string           `json:"namespace"`
+	Subdomain      string           `json:"subdomain"`
 	CreatedAt      pgtype.Timestamp `json:"created_at"`
 	UpdatedAt      pgtype.Timestamp `json:"updated_at"`
+	DeployedAt     pgtype.Timestamp `json:"deployed_at"`
+	DeletedAt      pgtype.Timestamp `json:"deleted_at"`
+	AppVersion     string           `json:"app_version"`
+	CustomEnv      []byte           `json:"custom_env"`
+	OrganizationID string           `json:"organization_id"`
+	OwnerSetupAt   pgtype.Timestamp `json:"owner_setup_at"`
 }
 
-type User struct {
+type InstanceAccessPolicy struct {
+	InstanceID            string           `json:"instance_id"`
+	AllowedCidrs          []string         `json:"allowed_cidrs"`
+	AuthMode              string           `json:"auth_mode"`
+	BasicAuthUsername     string           `json:"basic_auth_username"`
+	BasicAuthPasswordHash string           `json:"basic_auth_password_hash"`
+	PublicPaths           []string         `json:"public_paths"`
+	CreatedAt             pgtype.Timestamp `json:"created_at"`
+	UpdatedAt             pgtype.Timestamp `json:"updated_at"`
+}
+
+type InstanceHealthCheck struct {
+	InstanceID string           `json:"instance_id"`
+	CheckedAt  pgtype.Timestamp `json:"checked_at"`
+	Up         bool             `json:"up"`
+	LatencyMs  int32            `json:"latency_ms"`
+	Error      string           `json:"error"`
+}
+
+type InstanceLimitOverride struct {
+	InstanceID          string           `json:"instance_id"`
+	RequestsPerSecond   pgtype.Int4      `json:"requests_per_second"`
+	Burst               pgtype.Int4      `json:"burst"`
+	IpRequestsPerSecond pgtype.Int4      `json:"ip_requests_per_second"`
+	IpBurst             pgtype.Int4      `json:"ip_burst"`
+	MaxBodyBytes        pgtype.Int8      `json:"max_body_bytes"`
+	MaxConcurrent       pgtype.Int4      `json:"max_concurrent"`
+	CreatedAt           pgtype.Timestamp `json:"created_at"`
+	UpdatedAt           pgtype.Timestamp `json:"updated_at"`
+}
+
+type InstanceMonitor struct {
+	InstanceID          string           `json:"instance_id"`
+	Status              string           `json:"status"`
+	ConsecutiveFailures int32            `json:"consecutive_failures"`
+	FailingSince        pgtype.Timestamp `json:"failing_since"`
+	LastError           string           `json:"last_error"`
+	LastCheckedAt       pgtype.Timestamp `json:"last_checked_at"`
+	StatusChangedAt     pgtype.Timestamp `json:"status_changed_at"`
+	AlertWebhookUrl     string           `json:"alert_webhook_url"`
+	CreatedAt           pgtype.Timestamp `json:"created_at"`
+	UpdatedAt           pgtype.Timestamp `json:"updated_at"`
+}
+
+type Job struct {
 	ID          string           `json:"id"`
-	Email       string           `json:"email"`
-	Name        string           `json:"name"`
+	Kind        string           `json:"kind"`
+	Args        []byte           `json:"args"`
+	Status      string           `json:"status"`
+	UniqueKey   pgtype.Text      `json:"unique_key"`
+	Attempts    int32            `json:"attempts"`
+	MaxAttempts int32            `json:"max_attempts"`
+	RunAt       pgtype.Timestamp `json:"run_at"`
+	LockedUntil pgtype.Timestamp `json:"locked_until"`
+	LastError   string           `json:"last_error"`
 	CreatedAt   pgtype.Timestamp `json:"created_at"`
-	LastLoginAt pgtype.Timestamp `json:"last_login_at"`
 	UpdatedAt   pgtype.Timestamp `json:"updated_at"`
+	FinishedAt  pgtype.Timestamp `json:"finished_at"`
+}
+
+type JobSchedule struct {
+	Kind      string           `json:"kind"`
+	Spec      string           `json:"spec"`
+	NextRunAt pgtype.Timestamp `json:"next_run_at"`
+	UpdatedAt pgtype.Timestamp `json:"updated_at"`
+}
+
+type MagicLinkToken struct {
+	TokenHash string           `json:"token_hash"`
+	Email     string           `json:"email"`
+	ReturnTo  string           `json:"return_to"`
+	ExpiresAt pgtype.Timestamp `json:"expires_at"`
+	UsedAt    pgtype.Timestamp `json:"used_at"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
+}
+
+type Notification struct {
+	ID        string           `json:"id"`
+	UserID    string           `json:"user_id"`
+	Kind      string           `json:"kind"`
+	Recipient string           `json:"recipient"`
+	Subject   string           `json:"subject"`
+	TextBody  string           `json:"text_body"`
+	HtmlBody  string           `json:"html_body"`
+	Status    string           `json:"status"`
+	Error     string           `json:"error"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
+	SentAt    pgtype.Timestamp `json:"sent_at"`
+}
+
+type NotificationPreference struct {
+	UserID    string           `json:"user_id"`
+	Kind      string           `json:"kind"`
+	Enabled   bool             `json:"enabled"`
+	UpdatedAt pgtype.Timestamp `json:"updated_at"`
+}
+
+type Organization struct {
+	ID               string           `json:"id"`
+	Name             string           `json:"name"`
+	Personal         bool             `json:"personal"`
+	CreatedAt        pgtype.Timestamp `json:"created_at"`
+	UpdatedAt        pgtype.Timestamp `json:"updated_at"`
+	RequireTwoFactor bool             `json:"require_two_factor"`
+}
+
+type OrganizationInvitation struct {
+	ID             string           `json:"id"`
+	OrganizationID string           `json:"organization_id"`
+	Email          string           `json:"email"`
+	Role           string           `json:"role"`
+	TokenHash      string           `json:"token_hash"`
+	InvitedBy      *string          `json:"invited_by"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+	ExpiresAt      pgtype.Timestamp `json:"expires_at"`
+}
+
+type OrganizationMember struct {
+	OrganizationID string           `json:"organization_id"`
+	UserID         string           `json:"user_id"`
+	Role           string           `json:"role"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+}
+
+type PlanLimit struct {
+	Plan                string           `json:"plan"`
+	RequestsPerSecond   int32            `json:"requests_per_second"`
+	Burst               int32            `json:"burst"`
+	IpRequestsPerSecond int32            `json:"ip_requests_per_second"`
+	IpBurst             int32            `json:"ip_burst"`
+	MaxBodyBytes        int64            `json:"max_body_bytes"`
+	MaxConcurrent       int32            `json:"max_concurrent"`
+	CreatedAt           pgtype.Timestamp `json:"created_at"`
+	UpdatedAt           pgtype.Timestamp `json:"updated_at"`
+}
+
+type Session struct {
+	ID                string           `json:"id"`
+	UserID            string           `json:"user_id"`
+	TokenHash         string           `json:"token_hash"`
+	UserAgent         string           `json:"user_agent"`
+	IpAddress         string           `json:"ip_address"`
+	CreatedAt         pgtype.Timestamp `json:"created_at"`
+	LastSeenAt        pgtype.Timestamp `json:"last_seen_at"`
+	ExpiresAt         pgtype.Timestamp `json:"expires_at"`
+	AbsoluteExpiresAt pgtype.Timestamp `json:"absolute_expires_at"`
+}
+
+type Subscription struct {
+	ID                        string           `json:"id"`
+	UserID                    string           `json:"user_id"`
+	ProductID                 string           `json:"product_id"`
+	VariantID                 string           `json:"variant_id"`
+	CustomerID                string           `json:"customer_id"`
+	SubscriptionID            string           `json:"subscription_id"`
+	Status                    string           `json:"status"`
+	Quantity                  int32            `json:"quantity"`
+	TrialEndsAt               pgtype.Timestamp `json:"trial_ends_at"`
+	CreatedAt                 pgtype.Timestamp `json:"created_at"`
+	UpdatedAt                 pgtype.Timestamp `json:"updated_at"`
+	OrganizationID            string           `json:"organization_id"`
+	TrialWarningSentAt        pgtype.Timestamp `json:"trial_warning_sent_at"`
+	TrialEndedNoticeSentAt    pgtype.Timestamp `json:"trial_ended_notice_sent_at"`
+	TrialEnforcedAt           pgtype.Timestamp `json:"trial_enforced_at"`
+	TrialEnforcementClaimedAt pgtype.Timestamp `json:"trial_enforcement_claimed_at"`
+}
+
+type User struct {
+	ID                    string           `json:"id"`
+	Email                 string           `json:"email"`
+	Name                  string           `json:"name"`
+	CreatedAt             pgtype.Timestamp `json:"created_at"`
+	LastLoginAt           pgtype.Timestamp `json:"last_login_at"`
+	UpdatedAt             pgtype.Timestamp `json:"updated_at"`
+	CurrentOrganizationID *string          `json:"current_organization_id"`
+	TotpSecret            pgtype.Text      `json:"totp_secret"`
+	TotpEnabledAt         pgtype.Timestamp `json:"totp_enabled_at"`
+	TotpLastStep          int64            `json:"totp_last_step"`
+	TwoFactorFailures     int32            `json:"two_factor_failures"`
+	TwoFactorLockedUntil  pgtype.Timestamp `json:"two_factor_locked_until"`
+	DeletedAt             pgtype.Timestamp `json:"deleted_at"`
+	IsAdmin               bool             `json:"is_admin"`
+}
+
+type UserIdentity struct {
+	ID         string           `json:"id"`
+	UserID     string           `json:"user_id"`
+	Provider   string           `json:"provider"`
+	Subject    string           `json:"subject"`
+	Email      string           `json:"email"`
+	CreatedAt  pgtype.Timestamp `json:"created_at"`
+	LastUsedAt pgtype.Timestamp `json:"last_used_at"`
+}
+
+type UserRecoveryCode struct {
+	ID        string           `json:"id"`
+	UserID    string           `json:"user_id"`
+	CodeHash  string           `json:"code_hash"`
+	UsedAt    pgtype.Timestamp `json:"used_at"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             string           `json:"id"`
+	EndpointID     string           `json:"endpoint_id"`
+	EventID        string           `json:"event_id"`
+	Event          string           `json:"event"`
+	Payload        string           `json:"payload"`
+	Status         string           `json:"status"`
+	Attempts       int32            `json:"attempts"`
+	ResponseStatus int32            `json:"response_status"`
+	ResponseBody   string           `json:"response_body"`
+	Error          string           `json:"error"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+	LastAttemptAt  pgtype.Timestamp `json:"last_attempt_at"`
+	DeliveredAt    pgtype.Timestamp `json:"delivered_at"`
+}
+
+type WebhookEndpoint struct {
+	ID             string           `json:"id"`
+	OrganizationID string           `json:"organization_id"`
+	Url            string           `json:"url"`
+	Secret         string           `json:"secret"`
+	Events         []string         `json:"events"`
+	CreatedAt      pgtype.Timestamp `json:"created_at"`
+	UpdatedAt      pgtype.Timestamp `json:"updated_at"`
 }
@@ -0,0 +1,278 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: notifications.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const adminListNotifications = `-- name: AdminListNotifications :many
+SELECT id, user_id, kind, recipient, subject, status, error, created_at, sent_at FROM notifications
+WHERE $1::varchar IS NULL OR recipient ILIKE '%' || $1::varchar || '%'
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type AdminListNotificationsParams struct {
+	Search  pgtype.Text `json:"search"`
+	MaxRows int32       `json:"max_rows"`
+}
+
+type AdminListNotificationsRow struct {
+	ID        string           `json:"id"`
+	UserID    string           `json:"user_id"`
+	Kind      string           `json:"kind"`
+	Recipient string           `json:"recipient"`
+	Subject   string           `json:"subject"`
+	Status    string           `json:"status"`
+	Error     string           `json:"error"`
+	CreatedAt pgtype.Timestamp `json:"created_at"`
+	SentAt    pgtype.Timestamp `json:"sent_at"`
+}
+
+// Most recent emails, optionally only those to recipients matching search
+func (q *Queries) AdminListNotifications(ctx context.Context, arg AdminListNotificationsParams) ([]AdminListNotificationsRow, error) {
+	rows, err := q.db.Query(ctx, adminListNotifications, arg.Search, arg.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AdminListNotificationsRow
+	for rows.Next() {
+		var i AdminListNotificationsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Kind,
+			&i.Recipient,
+			&i.Subject,
+			&i.Status,
+			&i.Error,
+			&i.CreatedAt,
+			&i.SentAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createNotification = `-- name: CreateNotification :one
+INSERT INTO notifications (user_id, kind, recipient, subject, text_body, html_body)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, user_id, kind, recipient, subject, text_body, html_body, status, error, created_at, sent_at
+`
+
+type CreateNotificationParams struct {
+	UserID    string `json:"user_id"`
+	Kind      string `json:"kind"`
+	Recipient string `json:"recipient"`
+	Subject   string `json:"subject"`
+	TextBody  string `json:"text_body"`
+	HtmlBody  string `json:"html_body"`
+}
+
+func (q *Queries) CreateNotification(ctx context.Context, arg CreateNotificationParams) (Notification, error) {
+	row := q.db.QueryRow(ctx, createNotification,
+		arg.UserID,
+		arg.Kind,
+		arg.Recipient,
+		arg.Subject,
+		arg.TextBody,
+		arg.HtmlBody,
+	)
+	var i Notification
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Kind,
+		&i.Recipient,
+		&i.Subject,
+		&i.TextBody,
+		&i.HtmlBody,
+		&i.Status,
+		&i.Error,
+		&i.CreatedAt,
+		&i.SentAt,
+	)
+	return i, err
+}
+
+const deleteUserNotificationPreferences = `-- name: DeleteUserNotificationPreferences :exec
+DELETE FROM notification_preferences WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserNotificationPreferences(ctx context.Context, userID string) error {
+	_, err := q.db.Exec(ctx, deleteUserNotificationPreferences, userID)
+	return err
+}
+
+const deleteUserNotifications = `-- name: DeleteUserNotifications :exec
+DELETE FROM notifications WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserNotifications(ctx context.Context, userID string) error {
+	_, err := q.db.Exec(ctx, deleteUserNotifications, userID)
+	return err
+}
+
+const getNotification = `-- name: GetNotification :one
+SELECT id, user_id, kind, recipient, subject, text_body, html_body, status, error, created_at, sent_at FROM notifications WHERE id = $1
+`
+
+func (q *Queries) GetNotification(ctx context.Context, id string) (Notification, error) {
+	row := q.db.QueryRow(ctx, getNotification, id)
+	var i Notification
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Kind,
+		&i.Recipient,
+		&i.Subject,
+		&i.TextBody,
+		&i.HtmlBody,
+		&i.Status,
+		&i.Error,
+		&i.CreatedAt,
+		&i.SentAt,
+	)
+	return i, err
+}
+
+const getNotificationPreference = `-- name: GetNotificationPreference :one
+SELECT enabled FROM notification_preferences WHERE user_id = $1 AND kind = $2
+`
+
+type GetNotificationPreferenceParams struct {
+	UserID string `json:"user_id"`
+	Kind   string `json:"kind"`
+}
+
+func (q *Queries) GetNotificationPreference(ctx context.Context, arg GetNotificationPreferenceParams) (bool, error) {
+	row := q.db.QueryRow(ctx, getNotificationPreference, arg.UserID, arg.Kind)
+	var enabled bool
+	err := row.Scan(&enabled)
+	return enabled, err
+}
+
+const listNotificationPreferences = `-- name: ListNotificationPreferences :many
+SELECT user_id, kind, enabled, updated_at FROM notification_preferences WHERE user_id = $1
+`
+
+func (q *Queries) ListNotificationPreferences(ctx context.Context, userID string) ([]NotificationPreference, error) {
+	rows, err := q.db.Query(ctx, listNotificationPreferences, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []NotificationPreference
+	for rows.Next() {
+		var i NotificationPreference
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Kind,
+			&i.Enabled,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserNotifications = `-- name: ListUserNotifications :many
+SELECT id, user_id, kind, recipient, subject, text_body, html_body, status, error, created_at, sent_at FROM notifications
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type ListUserNotificationsParams struct {
+	UserID  string `json:"user_id"`
+	MaxRows int32  `json:"max_rows"`
+}
+
+func (q *Queries) ListUserNotifications(ctx context.Context, arg ListUserNotificationsParams) ([]Notification, error) {
+	rows, err := q.db.Query(ctx, listUserNotifications, arg.UserID, arg.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Notification
+	for rows.Next() {
+		var i Notification
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Kind,
+			&i.Recipient,
+			&i.Subject,
+			&i.TextBody,
+			&i.HtmlBody,
+			&i.Status,
+			&i.Error,
+			&i.CreatedAt,
+			&i.SentAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markNotificationFailed = `-- name: MarkNotificationFailed :exec
+UPDATE notifications SET status = 'failed', error = $2 WHERE id = $1
+`
+
+type MarkNotificationFailedParams struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+func (q *Queries) MarkNotificationFailed(ctx context.Context, arg MarkNotificationFailedParams) error {
+	_, err := q.db.Exec(ctx, markNotificationFailed, arg.ID, arg.Error)
+	return err
+}
+
+const markNotificationSent = `-- name: MarkNotificationSent :exec
+UPDATE notifications SET status = 'sent', error = '', sent_at = NOW() WHERE id = $1
+`
+
+func (q *Queries) MarkNotificationSent(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, markNotificationSent, id)
+	return err
+}
+
+const upsertNotificationPreference = `-- name: UpsertNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, kind, enabled)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, kind) DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = NOW()
+`
+
+type UpsertNotificationPreferenceParams struct {
+	UserID  string `json:"user_id"`
+	Kind    string `json:"kind"`
+	Enabled bool   `json:"enabled"`
+}
+
+func (q *Queries) UpsertNotificationPreference(ctx context.Context, arg UpsertNotificationPreferenceParams) error {
+	_, err := q.db.Exec(ctx, upsertNotificationPreference, arg.UserID, arg.Kind, arg.Enabled)
+	return err
+}
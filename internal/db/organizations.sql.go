@@ -0,0 +1,437 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: organizations.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const countOrganizationOwners = `-- name: CountOrganizationOwners :one
+SELECT COUNT(*) FROM organization_members WHERE organization_id = $1 AND role = 'owner'
+`
+
+func (q *Queries) CountOrganizationOwners(ctx context.Context, organizationID string) (int64, error) {
+	row := q.db.QueryRow(ctx, countOrganizationOwners, organizationID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createOrganization = `-- name: CreateOrganization :one
+INSERT INTO organizations (name, personal) VALUES ($1, $2) RETURNING id, name, personal, created_at, updated_at, require_two_factor
+`
+
+type CreateOrganizationParams struct {
+	Name     string `json:"name"`
+	Personal bool   `json:"personal"`
+}
+
+func (q *Queries) CreateOrganization(ctx context.Context, arg CreateOrganizationParams) (Organization, error) {
+	row := q.db.QueryRow(ctx, createOrganization, arg.Name, arg.Personal)
+	var i Organization
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Personal,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.RequireTwoFactor,
+	)
+	return i, err
+}
+
+const createOrganizationInvitation = `-- name: CreateOrganizationInvitation :one
+INSERT INTO organization_invitations (
+    organization_id, email, role, token_hash, invited_by, expires_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+ON CONFLICT (organization_id, email) DO UPDATE
+SET role = EXCLUDED.role, token_hash = EXCLUDED.token_hash, invited_by = EXCLUDED.invited_by,
+    created_at = NOW(), expires_at = EXCLUDED.expires_at
+RETURNING id, organization_id, email, role, token_hash, invited_by, created_at, expires_at
+`
+
+type CreateOrganizationInvitationParams struct {
+	OrganizationID string           `json:"organization_id"`
+	Email          string           `json:"email"`
+	Role           string           `json:"role"`
+	TokenHash      string           `json:"token_hash"`
+	InvitedBy      *string          `json:"invited_by"`
+	ExpiresAt      pgtype.Timestamp `json:"expires_at"`
+}
+
+// A new invitation to the same address replaces the pending one
+func (q *Queries) CreateOrganizationInvitation(ctx context.Context, arg CreateOrganizationInvitationParams) (OrganizationInvitation, error) {
+	row := q.db.QueryRow(ctx, createOrganizationInvitation,
+		arg.OrganizationID,
+		arg.Email,
+		arg.Role,
+		arg.TokenHash,
+		arg.InvitedBy,
+		arg.ExpiresAt,
+	)
+	var i OrganizationInvitation
+	err := row.Scan(
+		&i.ID,
+		&i.OrganizationID,
+		&i.Email,
+		&i.Role,
+		&i.TokenHash,
+		&i.InvitedBy,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const createOrganizationMember = `-- name: CreateOrganizationMember :exec
+INSERT INTO organization_members (organization_id, user_id, role) VALUES ($1, $2, $3)
+ON CONFLICT (organization_id, user_id) DO NOTHING
+`
+
+type CreateOrganizationMemberParams struct {
+	OrganizationID string `json:"organization_id"`
+	UserID         string `json:"user_id"`
+	Role           string `json:"role"`
+}
+
+func (q *Queries) CreateOrganizationMember(ctx context.Context, arg CreateOrganizationMemberParams) error {
+	_, err := q.db.Exec(ctx, createOrganizationMember, arg.OrganizationID, arg.UserID, arg.Role)
+	return err
+}
+
+const deleteOrganizationInvitation = `-- name: DeleteOrganizationInvitation :execrows
+DELETE FROM organization_invitations WHERE id = $1 AND organization_id = $2
+`
+
+type DeleteOrganizationInvitationParams struct {
+	ID             string `json:"id"`
+	OrganizationID string `json:"organization_id"`
+}
+
+func (q *Queries) DeleteOrganizationInvitation(ctx context.Context, arg DeleteOrganizationInvitationParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteOrganizationInvitation, arg.ID, arg.OrganizationID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteOrganizationMember = `-- name: DeleteOrganizationMember :execrows
+DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2
+`
+
+type DeleteOrganizationMemberParams struct {
+	OrganizationID string `json:"organization_id"`
+	UserID         string `json:"user_id"`
+}
+
+func (q *Queries) DeleteOrganizationMember(ctx context.Context, arg DeleteOrganizationMemberParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteOrganizationMember, arg.OrganizationID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getActiveOrganizationInvitationByTokenHash = `-- name: GetActiveOrganizationInvitationByTokenHash :one
+SELECT organization_invitations.id, organization_invitations.organization_id, organization_invitations.email, organization_invitations.role, organization_invitations.token_hash, organization_invitations.invited_by, organization_invitations.created_at, organization_invitations.expires_at, organizations.name AS organization_name
+FROM organization_invitations
+JOIN organizations ON organizations.id = organization_invitations.organization_id
+WHERE organization_invitations.token_hash = $1 AND organization_invitations.expires_at > NOW()
+`
+
+type GetActiveOrganizationInvitationByTokenHashRow struct {
+	ID               string           `json:"id"`
+	OrganizationID   string           `json:"organization_id"`
+	Email            string           `json:"email"`
+	Role             string           `json:"role"`
+	TokenHash        string           `json:"token_hash"`
+	InvitedBy        *string          `json:"invited_by"`
+	CreatedAt        pgtype.Timestamp `json:"created_at"`
+	ExpiresAt        pgtype.Timestamp `json:"expires_at"`
+	OrganizationName string           `json:"organization_name"`
+}
+
+func (q *Queries) GetActiveOrganizationInvitationByTokenHash(ctx context.Context, tokenHash string) (GetActiveOrganizationInvitationByTokenHashRow, error) {
+	row := q.db.QueryRow(ctx, getActiveOrganizationInvitationByTokenHash, tokenHash)
+	var i GetActiveOrganizationInvitationByTokenHashRow
+	err := row.Scan(
+		&i.ID,
+		&i.OrganizationID,
+		&i.Email,
+		&i.Role,
+		&i.TokenHash,
+		&i.InvitedBy,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.OrganizationName,
+	)
+	return i, err
+}
+
+const getOrganization = `-- name: GetOrganization :one
+SELECT id, name, personal, created_at, updated_at, require_two_factor FROM organizations WHERE id = $1
+`
+
+func (q *Queries) GetOrganization(ctx context.Context, id string) (Organization, error) {
+	row := q.db.QueryRow(ctx, getOrganization, id)
+	var i Organization
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Personal,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.RequireTwoFactor,
+	)
+	return i, err
+}
+
+const getOrganizationMember = `-- name: GetOrganizationMember :one
+SELECT organization_id, user_id, role, created_at FROM organization_members WHERE organization_id = $1 AND user_id = $2
+`
+
+type GetOrganizationMemberParams struct {
+	OrganizationID string `json:"organization_id"`
+	UserID         string `json:"user_id"`
+}
+
+func (q *Queries) GetOrganizationMember(ctx context.Context, arg GetOrganizationMemberParams) (OrganizationMember, error) {
+	row := q.db.QueryRow(ctx, getOrganizationMember, arg.OrganizationID, arg.UserID)
+	var i OrganizationMember
+	err := row.Scan(
+		&i.OrganizationID,
+		&i.UserID,
+		&i.Role,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getPersonalOrganization = `-- name: GetPersonalOrganization :one
+SELECT organizations.id, organizations.name, organizations.personal, organizations.created_at, organizations.updated_at, organizations.require_two_factor FROM organizations
+JOIN organization_members ON organization_members.organization_id = organizations.id
+WHERE organization_members.user_id = $1 AND organizations.personal
+LIMIT 1
+`
+
+func (q *Queries) GetPersonalOrganization(ctx context.Context, userID string) (Organization, error) {
+	row := q.db.QueryRow(ctx, getPersonalOrganization, userID)
+	var i Organization
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Personal,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.RequireTwoFactor,
+	)
+	return i, err
+}
+
+const listOrganizationInvitations = `-- name: ListOrganizationInvitations :many
+SELECT id, organization_id, email, role, token_hash, invited_by, created_at, expires_at FROM organization_invitations
+WHERE organization_id = $1 AND expires_at > NOW()
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListOrganizationInvitations(ctx context.Context, organizationID string) ([]OrganizationInvitation, error) {
+	rows, err := q.db.Query(ctx, listOrganizationInvitations, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []OrganizationInvitation
+	for rows.Next() {
+		var i OrganizationInvitation
+		if err := rows.Scan(
+			&i.ID,
+			&i.OrganizationID,
+			&i.Email,
+			&i.Role,
+			&i.TokenHash,
+			&i.InvitedBy,
+			&i.CreatedAt,
+			&i.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOrganizationMembers = `-- name: ListOrganizationMembers :many
+SELECT organization_members.organization_id, organization_members.user_id, organization_members.role, organization_members.created_at, users.email, users.name,
+       (users.totp_enabled_at IS NOT NULL)::boolean AS two_factor_enabled
+FROM organization_members
+JOIN users ON users.id = organization_members.user_id
+WHERE organization_members.organization_id = $1
+ORDER BY organization_members.created_at
+`
+
+type ListOrganizationMembersRow struct {
+	OrganizationID   string           `json:"organization_id"`
+	UserID           string           `json:"user_id"`
+	Role             string           `json:"role"`
+	CreatedAt        pgtype.Timestamp `json:"created_at"`
+	Email            string           `json:"email"`
+	Name             string           `json:"name"`
+	TwoFactorEnabled bool             `json:"two_factor_enabled"`
+}
+
+func (q *Queries) ListOrganizationMembers(ctx context.Context, organizationID string) ([]ListOrganizationMembersRow, error) {
+	rows, err := q.db.Query(ctx, listOrganizationMembers, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListOrganizationMembersRow
+	for rows.Next() {
+		var i ListOrganizationMembersRow
+		if err := rows.Scan(
+			&i.OrganizationID,
+			&i.UserID,
+			&i.Role,
+			&i.CreatedAt,
+			&i.Email,
+			&i.Name,
+			&i.TwoFactorEnabled,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserOrganizations = `-- name: ListUserOrganizations :many
+SELECT organizations.id, organizations.name, organizations.personal, organizations.created_at, organizations.updated_at, organizations.require_two_factor, organization_members.role
+FROM organizations
+JOIN organization_members ON organization_members.organization_id = organizations.id
+WHERE organization_members.user_id = $1
+ORDER BY organizations.personal DESC, organizations.name
+`
+
+type ListUserOrganizationsRow struct {
+	ID               string           `json:"id"`
+	Name             string           `json:"name"`
+	Personal         bool             `json:"personal"`
+	CreatedAt        pgtype.Timestamp `json:"created_at"`
+	UpdatedAt        pgtype.Timestamp `json:"updated_at"`
+	RequireTwoFactor bool             `json:"require_two_factor"`
+	Role             string           `json:"role"`
+}
+
+func (q *Queries) ListUserOrganizations(ctx context.Context, userID string) ([]ListUserOrganizationsRow, error) {
+	rows, err := q.db.Query(ctx, listUserOrganizations, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUserOrganizationsRow
+	for rows.Next() {
+		var i ListUserOrganizationsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Personal,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.RequireTwoFactor,
+			&i.Role,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockOrganizationMembers = `-- name: LockOrganizationMembers :exec
+SELECT 1 FROM organization_members WHERE organization_id = $1 FOR UPDATE
+`
+
+// Serializes changes to the members of an organization
+func (q *Queries) LockOrganizationMembers(ctx context.Context, organizationID string) error {
+	_, err := q.db.Exec(ctx, lockOrganizationMembers, organizationID)
+	return err
+}
+
+const setUserCurrentOrganization = `-- name: SetUserCurrentOrganization :exec
+UPDATE users SET current_organization_id = $2, updated_at = NOW() WHERE id = $1
+`
+
+type SetUserCurrentOrganizationParams struct {
+	ID                    string  `json:"id"`
+	CurrentOrganizationID *string `json:"current_organization_id"`
+}
+
+func (q *Queries) SetUserCurrentOrganization(ctx context.Context, arg SetUserCurrentOrganizationParams) error {
+	_, err := q.db.Exec(ctx, setUserCurrentOrganization, arg.ID, arg.CurrentOrganizationID)
+	return err
+}
+
+const updateInstanceOrganization = `-- name: UpdateInstanceOrganization :one
+UPDATE instances SET organization_id = $2, updated_at = NOW() WHERE id = $1 RETURNING id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version, custom_env, organization_id, owner_setup_at
+`
+
+type UpdateInstanceOrganizationParams struct {
+	ID             string `json:"id"`
+	OrganizationID string `json:"organization_id"`
+}
+
+func (q *Queries) UpdateInstanceOrganization(ctx context.Context, arg UpdateInstanceOrganizationParams) (Instance, error) {
+	row := q.db.QueryRow(ctx, updateInstanceOrganization, arg.ID, arg.OrganizationID)
+	var i Instance
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Status,
+		&i.Namespace,
+		&i.Subdomain,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeployedAt,
+		&i.DeletedAt,
+		&i.AppVersion,
+		&i.CustomEnv,
+		&i.OrganizationID,
+		&i.OwnerSetupAt,
+	)
+	return i, err
+}
+
+const updateOrganizationMemberRole = `-- name: UpdateOrganizationMemberRole :execrows
+UPDATE organization_members SET role = $3 WHERE organization_id = $1 AND user_id = $2
+`
+
+type UpdateOrganizationMemberRoleParams struct {
+	OrganizationID string `json:"organization_id"`
+	UserID         string `json:"user_id"`
+	Role           string `json:"role"`
+}
+
+func (q *Queries) UpdateOrganizationMemberRole(ctx context.Context, arg UpdateOrganizationMemberRoleParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateOrganizationMemberRole, arg.OrganizationID, arg.UserID, arg.Role)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
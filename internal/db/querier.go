@@ -6,43 +6,231 @@ package db
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Querier interface {
+	// Accepts a code once, a concurrent request with the same code updates no row
+	AcceptTOTPStep(ctx context.Context, arg AcceptTOTPStepParams) (int64, error)
 	AcquireLock(ctx context.Context, hashtext string) error
+	AdminListAuditEventsByAction(ctx context.Context, arg AdminListAuditEventsByActionParams) ([]AdminListAuditEventsByActionRow, error)
+	// search matches the subdomain, the namespace or the email of the creator, deleted instances included
+	AdminListInstances(ctx context.Context, arg AdminListInstancesParams) ([]AdminListInstancesRow, error)
+	// Most recent emails, optionally only those to recipients matching search
+	AdminListNotifications(ctx context.Context, arg AdminListNotificationsParams) ([]AdminListNotificationsRow, error)
+	// Live instances that failed, or whose n8n owner still isn't set up long after provisioning
+	AdminListStuckInstances(ctx context.Context, arg AdminListStuckInstancesParams) ([]AdminListStuckInstancesRow, error)
+	// search matches the organization name, the email of the subscriber or a LemonSqueezy ID
+	AdminListSubscriptions(ctx context.Context, arg AdminListSubscriptionsParams) ([]AdminListSubscriptionsRow, error)
+	// search matches the email, the name or the exact ID, NULL lists the newest users
+	AdminListUsers(ctx context.Context, arg AdminListUsersParams) ([]AdminListUsersRow, error)
 	CheckNamespaceExists(ctx context.Context, namespace string) (bool, error)
 	CheckSubdomainExists(ctx context.Context, subdomain string) (bool, error)
-	CountActiveInstancesByUserID(ctx context.Context, userID string) (int64, error)
+	// Locks due schedules for the rest of the transaction, other replicas skip them
+	ClaimDueJobSchedules(ctx context.Context, kinds []string) ([]JobSchedule, error)
+	// Claims due jobs, and running jobs whose worker stopped extending the lease
+	ClaimJobs(ctx context.Context, arg ClaimJobsParams) ([]Job, error)
+	// Claims a trial that is still to be enforced, unless another run claimed it after stale_before
+	ClaimTrialEnforcement(ctx context.Context, arg ClaimTrialEnforcementParams) (Subscription, error)
+	CompleteIdempotencyKey(ctx context.Context, arg CompleteIdempotencyKeyParams) error
+	CompleteJob(ctx context.Context, arg CompleteJobParams) (int64, error)
+	ConsumeEditorLoginToken(ctx context.Context, arg ConsumeEditorLoginTokenParams) (EditorLoginToken, error)
+	ConsumeGateExchangeToken(ctx context.Context, arg ConsumeGateExchangeTokenParams) (int64, error)
+	ConsumeMagicLinkToken(ctx context.Context, tokenHash string) (MagicLinkToken, error)
+	ConsumeRecoveryCode(ctx context.Context, arg ConsumeRecoveryCodeParams) (int64, error)
+	CountActiveInstancesByOrganizationID(ctx context.Context, organizationID string) (int64, error)
+	CountOrganizationOwners(ctx context.Context, organizationID string) (int64, error)
+	CountRecentMagicLinkTokens(ctx context.Context, arg CountRecentMagicLinkTokensParams) (int64, error)
+	CountUnusedRecoveryCodes(ctx context.Context, userID string) (int64, error)
+	CountUserAPITokens(ctx context.Context, userID string) (int64, error)
+	CountWebhookEndpoints(ctx context.Context, organizationID string) (int64, error)
+	CreateAPIToken(ctx context.Context, arg CreateAPITokenParams) (ApiToken, error)
+	CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) error
 	CreateCheckoutSession(ctx context.Context, arg CreateCheckoutSessionParams) (CheckoutSession, error)
+	CreateEditorLoginToken(ctx context.Context, arg CreateEditorLoginTokenParams) error
+	CreateGateExchangeToken(ctx context.Context, arg CreateGateExchangeTokenParams) error
+	// Returns no rows when the key is already taken
+	CreateIdempotencyKey(ctx context.Context, arg CreateIdempotencyKeyParams) (IdempotencyKey, error)
 	CreateInstance(ctx context.Context, arg CreateInstanceParams) (Instance, error)
+	CreateInstanceHealthCheck(ctx context.Context, arg CreateInstanceHealthCheckParams) error
+	CreateMagicLinkToken(ctx context.Context, arg CreateMagicLinkTokenParams) error
+	CreateNotification(ctx context.Context, arg CreateNotificationParams) (Notification, error)
+	CreateOrganization(ctx context.Context, arg CreateOrganizationParams) (Organization, error)
+	// A new invitation to the same address replaces the pending one
+	CreateOrganizationInvitation(ctx context.Context, arg CreateOrganizationInvitationParams) (OrganizationInvitation, error)
+	CreateOrganizationMember(ctx context.Context, arg CreateOrganizationMemberParams) error
+	CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) error
+	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
 	CreateSubscription(ctx context.Context, arg CreateSubscriptionParams) (Subscription, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateUserIdentity(ctx context.Context, arg CreateUserIdentityParams) (UserIdentity, error)
+	CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) (WebhookDelivery, error)
+	CreateWebhookEndpoint(ctx context.Context, arg CreateWebhookEndpointParams) (WebhookEndpoint, error)
+	DeleteExpiredUserSessions(ctx context.Context, userID string) error
+	DeleteFinishedJobs(ctx context.Context, finishedAt pgtype.Timestamp) (int64, error)
+	DeleteIdempotencyKey(ctx context.Context, id string) error
+	// Instances are kept for the audit log, the settings that only apply to a running instance go with it
 	DeleteInstance(ctx context.Context, id string) error
+	DeleteInstanceHealthChecksBefore(ctx context.Context, before pgtype.Timestamp) (int64, error)
+	DeleteInstanceLimitOverride(ctx context.Context, instanceID string) error
+	DeleteMagicLinkTokensByEmail(ctx context.Context, email string) error
+	DeleteOrganizationInvitation(ctx context.Context, arg DeleteOrganizationInvitationParams) (int64, error)
+	DeleteOrganizationMember(ctx context.Context, arg DeleteOrganizationMemberParams) (int64, error)
+	DeleteRecoveryCodes(ctx context.Context, userID string) error
+	DeleteSessionByTokenHash(ctx context.Context, tokenHash string) error
+	// Frees keys whose response expired, or whose request never finished
+	DeleteStaleUserIdempotencyKeys(ctx context.Context, arg DeleteStaleUserIdempotencyKeysParams) error
 	DeleteSubscriptionByID(ctx context.Context, id string) error
+	DeleteUserAPIToken(ctx context.Context, arg DeleteUserAPITokenParams) (int64, error)
+	DeleteUserAPITokens(ctx context.Context, userID string) error
+	DeleteUserIdentities(ctx context.Context, userID string) error
+	DeleteUserMemberships(ctx context.Context, userID string) error
+	DeleteUserNotificationPreferences(ctx context.Context, userID string) error
+	DeleteUserNotifications(ctx context.Context, userID string) error
+	DeleteUserSession(ctx context.Context, arg DeleteUserSessionParams) (int64, error)
+	DeleteUserSessions(ctx context.Context, userID string) error
+	DeleteWebhookDeliveriesBefore(ctx context.Context, before pgtype.Timestamp) (int64, error)
+	DeleteWebhookEndpoint(ctx context.Context, arg DeleteWebhookEndpointParams) (int64, error)
+	DisableUserTOTP(ctx context.Context, id string) error
+	EnableUserTOTP(ctx context.Context, arg EnableUserTOTPParams) error
+	// This and the outcome queries below match the attempt that ran, so a worker whose lease
+	// ran out can't touch the job once another replica claimed it again
+	ExtendJobLease(ctx context.Context, arg ExtendJobLeaseParams) (int64, error)
+	FailJob(ctx context.Context, arg FailJobParams) (int64, error)
+	// Tokens stop working when their owner leaves the organization
+	GetActiveAPITokenByHash(ctx context.Context, tokenHash string) (GetActiveAPITokenByHashRow, error)
+	GetActiveOrganizationInvitationByTokenHash(ctx context.Context, tokenHash string) (GetActiveOrganizationInvitationByTokenHashRow, error)
+	// organization_role is NULL when the user was removed from their current organization
+	GetActiveSessionByTokenHash(ctx context.Context, tokenHash string) (GetActiveSessionByTokenHashRow, error)
 	GetCheckoutSessionByID(ctx context.Context, id string) (CheckoutSession, error)
 	GetCheckoutSessionByProviderID(ctx context.Context, checkoutID string) (CheckoutSession, error)
+	GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKey, error)
 	GetInstance(ctx context.Context, id string) (Instance, error)
+	GetInstanceAccessPolicy(ctx context.Context, instanceID string) (InstanceAccessPolicy, error)
 	GetInstanceByNamespace(ctx context.Context, namespace string) (Instance, error)
 	GetInstanceBySubdomain(ctx context.Context, subdomain string) (Instance, error)
 	GetInstanceForUpdate(ctx context.Context, id string) (Instance, error)
+	GetInstanceLimitOverride(ctx context.Context, instanceID string) (InstanceLimitOverride, error)
+	GetInstanceMonitor(ctx context.Context, instanceID string) (InstanceMonitor, error)
+	GetInstanceMonitorForUpdate(ctx context.Context, instanceID string) (InstanceMonitor, error)
+	GetInstanceUptime(ctx context.Context, arg GetInstanceUptimeParams) (GetInstanceUptimeRow, error)
+	GetNotification(ctx context.Context, id string) (Notification, error)
+	GetNotificationPreference(ctx context.Context, arg GetNotificationPreferenceParams) (bool, error)
+	GetOrganization(ctx context.Context, id string) (Organization, error)
+	GetOrganizationMember(ctx context.Context, arg GetOrganizationMemberParams) (OrganizationMember, error)
+	GetOrganizationWebhookDelivery(ctx context.Context, arg GetOrganizationWebhookDeliveryParams) (WebhookDelivery, error)
+	GetPersonalOrganization(ctx context.Context, userID string) (Organization, error)
+	GetPlanLimits(ctx context.Context, plan string) (PlanLimit, error)
+	GetSubscriptionByOrganizationID(ctx context.Context, organizationID string) (Subscription, error)
 	GetSubscriptionByProviderID(ctx context.Context, subscriptionID string) (Subscription, error)
-	GetSubscriptionByUserID(ctx context.Context, userID string) (Subscription, error)
 	GetUserByEmail(ctx context.Context, email string) (User, error)
 	GetUserByID(ctx context.Context, id string) (User, error)
+	GetUserIdentity(ctx context.Context, arg GetUserIdentityParams) (UserIdentity, error)
+	// The user and the organization they currently act in, role is NULL when they were removed from it
+	GetUserMembership(ctx context.Context, id string) (GetUserMembershipRow, error)
+	GetWebhookDelivery(ctx context.Context, id string) (GetWebhookDeliveryRow, error)
+	GetWebhookEndpoint(ctx context.Context, arg GetWebhookEndpointParams) (WebhookEndpoint, error)
+	// Returns no rows when a pending or running job of the kind already has the unique key
+	InsertJob(ctx context.Context, arg InsertJobParams) (Job, error)
+	// Whether a session may still pass the ranx login of an instance owned by the organization:
+	// it is active, its user is a member, and the organization's two-factor policy is met
+	IsGateSessionValid(ctx context.Context, arg IsGateSessionValidParams) (bool, error)
+	ListActiveUserSessions(ctx context.Context, userID string) ([]Session, error)
 	ListAllInstances(ctx context.Context, arg ListAllInstancesParams) ([]Instance, error)
+	// Events of an organization and of the user's own account, newest first.
+	// organization_id is NULL for members who may only see their own account.
+	// Account events are the user's own ones outside organizations and those on the user itself, like logins.
+	ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]ListAuditEventsRow, error)
 	ListCheckoutSessions(ctx context.Context, limit int32) ([]CheckoutSession, error)
-	ListInstancesByUser(ctx context.Context, userID string) ([]Instance, error)
+	// Trials that ended without the owners being told
+	ListEndedTrials(ctx context.Context) ([]Subscription, error)
+	ListHibernatedInstances(ctx context.Context, organizationID string) ([]Instance, error)
+	// Overrides of live instances for the admin console
+	ListInstanceLimitOverrides(ctx context.Context) ([]ListInstanceLimitOverridesRow, error)
+	ListInstancesByOrganization(ctx context.Context, organizationID string) ([]Instance, error)
+	// Running instances that started once, new ones are watched by provisioning until they are ready
+	ListMonitoredInstances(ctx context.Context) ([]Instance, error)
+	ListNotificationPreferences(ctx context.Context, userID string) ([]NotificationPreference, error)
+	ListOrganizationInvitations(ctx context.Context, organizationID string) ([]OrganizationInvitation, error)
+	ListOrganizationMembers(ctx context.Context, organizationID string) ([]ListOrganizationMembersRow, error)
+	ListOrganizationOwners(ctx context.Context, organizationID string) ([]User, error)
+	ListPlanLimits(ctx context.Context) ([]PlanLimit, error)
+	// Trials ending before warn_before that haven't been warned yet
+	ListTrialsEndingSoon(ctx context.Context, warnBefore pgtype.Timestamp) ([]Subscription, error)
+	// Ended trials whose owners were told before grace_deadline, so the grace period is never cut short
+	ListTrialsPastGrace(ctx context.Context, graceDeadline pgtype.Timestamp) ([]Subscription, error)
+	ListUserAPITokens(ctx context.Context, arg ListUserAPITokensParams) ([]ApiToken, error)
+	ListUserAuditEvents(ctx context.Context, arg ListUserAuditEventsParams) ([]AuditEvent, error)
+	ListUserIdentities(ctx context.Context, userID string) ([]UserIdentity, error)
+	// Instances the user created, including deleted ones
+	ListUserInstances(ctx context.Context, userID string) ([]Instance, error)
+	ListUserNotifications(ctx context.Context, arg ListUserNotificationsParams) ([]Notification, error)
+	ListUserOrganizations(ctx context.Context, userID string) ([]ListUserOrganizationsRow, error)
+	// Subscriptions the user started, they stay with the organization when the user leaves
+	ListUserSubscriptions(ctx context.Context, userID string) ([]Subscription, error)
+	ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	ListWebhookEndpoints(ctx context.Context, organizationID string) ([]WebhookEndpoint, error)
+	ListWebhookEndpointsForEvent(ctx context.Context, arg ListWebhookEndpointsForEventParams) ([]WebhookEndpoint, error)
+	ListenInstanceChanges(ctx context.Context) error
+	// Serializes changes to the members of an organization
+	LockOrganizationMembers(ctx context.Context, organizationID string) error
+	MarkInstanceOwnerSetup(ctx context.Context, id string) error
+	MarkNotificationFailed(ctx context.Context, arg MarkNotificationFailedParams) error
+	MarkNotificationSent(ctx context.Context, id string) error
+	MarkTrialEndedNoticeSent(ctx context.Context, id string) error
+	// Only trials that are still trials under the same claim expire, a checkout or trial extension completing meanwhile wins
+	MarkTrialEnforced(ctx context.Context, arg MarkTrialEnforcedParams) (Subscription, error)
+	MarkTrialWarningSent(ctx context.Context, id string) error
+	// Locks two-factor checks for the given time once the user reached the allowed number of failures.
+	// The count starts over after a lockout expired, so the next incorrect code doesn't lock again.
+	RecordTwoFactorFailure(ctx context.Context, arg RecordTwoFactorFailureParams) error
+	RecordWebhookDeliveryAttempt(ctx context.Context, arg RecordWebhookDeliveryAttemptParams) error
+	// Hands back a job interrupted by shutdown, the attempt doesn't count
+	ReleaseJob(ctx context.Context, arg ReleaseJobParams) (int64, error)
 	ReleaseLock(ctx context.Context, hashtext string) error
+	// Gives a claim up, so the next run enforces the trial again
+	ReleaseTrialEnforcement(ctx context.Context, arg ReleaseTrialEnforcementParams) error
+	RenameOrganization(ctx context.Context, arg RenameOrganizationParams) error
+	ResetTwoFactorFailures(ctx context.Context, id string) error
+	// Reopens a trial at a new end date, the expiry job starts over for it
+	RestartTrial(ctx context.Context, arg RestartTrialParams) (Subscription, error)
+	RetryJob(ctx context.Context, arg RetryJobParams) (int64, error)
+	// Restores the previous configuration unless another update changed it since
+	RevertInstanceConfig(ctx context.Context, arg RevertInstanceConfigParams) (int64, error)
+	// Clears where the actions of a deleted account came from, the only change the append-only trigger allows
+	ScrubUserAuditEvents(ctx context.Context, actorUserID *string) error
+	SetOrganizationRequireTwoFactor(ctx context.Context, arg SetOrganizationRequireTwoFactorParams) error
+	SetUserCurrentOrganization(ctx context.Context, arg SetUserCurrentOrganizationParams) error
+	// Starts a setup, only while two-factor authentication is off
+	SetUserTOTPSecret(ctx context.Context, arg SetUserTOTPSecretParams) error
+	// Scrubs the personal data of a deleted account, the address can't collide with a real one
+	TombstoneUser(ctx context.Context, id string) error
+	TouchAPIToken(ctx context.Context, id string) error
+	TouchSession(ctx context.Context, arg TouchSessionParams) error
+	TouchUserIdentity(ctx context.Context, arg TouchUserIdentityParams) error
 	UpdateCheckoutSessionCompleted(ctx context.Context, arg UpdateCheckoutSessionCompletedParams) error
 	UpdateCheckoutSessionStatus(ctx context.Context, arg UpdateCheckoutSessionStatusParams) error
+	UpdateInstanceConfig(ctx context.Context, arg UpdateInstanceConfigParams) (Instance, error)
 	UpdateInstanceDeployed(ctx context.Context, arg UpdateInstanceDeployedParams) (Instance, error)
 	UpdateInstanceNamespace(ctx context.Context, arg UpdateInstanceNamespaceParams) (Instance, error)
+	UpdateInstanceOrganization(ctx context.Context, arg UpdateInstanceOrganizationParams) (Instance, error)
 	UpdateInstanceStatus(ctx context.Context, arg UpdateInstanceStatusParams) (Instance, error)
-	UpdateSubscriptionByUserID(ctx context.Context, arg UpdateSubscriptionByUserIDParams) error
+	UpdateJobScheduleNextRun(ctx context.Context, arg UpdateJobScheduleNextRunParams) error
+	UpdateOrganizationMemberRole(ctx context.Context, arg UpdateOrganizationMemberRoleParams) (int64, error)
+	UpdateSubscriptionByOrganizationID(ctx context.Context, arg UpdateSubscriptionByOrganizationIDParams) error
 	UpdateSubscriptionQuantity(ctx context.Context, arg UpdateSubscriptionQuantityParams) error
 	UpdateSubscriptionStatusByProviderID(ctx context.Context, arg UpdateSubscriptionStatusByProviderIDParams) error
 	UpdateSubscriptionTrialEndsAt(ctx context.Context, arg UpdateSubscriptionTrialEndsAtParams) (Subscription, error)
 	UpdateUserLastLogin(ctx context.Context, id string) (User, error)
+	UpsertInstanceAccessPolicy(ctx context.Context, arg UpsertInstanceAccessPolicyParams) (InstanceAccessPolicy, error)
+	UpsertInstanceLimitOverride(ctx context.Context, arg UpsertInstanceLimitOverrideParams) (InstanceLimitOverride, error)
+	UpsertInstanceMonitorCheck(ctx context.Context, arg UpsertInstanceMonitorCheckParams) (InstanceMonitor, error)
+	UpsertInstanceMonitorWebhook(ctx context.Context, arg UpsertInstanceMonitorWebhookParams) (InstanceMonitor, error)
+	// Keeps the next run of an unchanged schedule, so restarts don't skip or repeat runs
+	UpsertJobSchedule(ctx context.Context, arg UpsertJobScheduleParams) error
+	UpsertNotificationPreference(ctx context.Context, arg UpsertNotificationPreferenceParams) error
+	UpsertPlanLimits(ctx context.Context, arg UpsertPlanLimitsParams) (PlanLimit, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -0,0 +1,17 @@
+-- name: GetInstanceAccessPolicy :one
+SELECT * FROM instance_access_policies WHERE instance_id = $1;
+
+-- name: UpsertInstanceAccessPolicy :one
+INSERT INTO instance_access_policies (
+    instance_id, allowed_cidrs, auth_mode, basic_auth_username, basic_auth_password_hash, public_paths
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+ON CONFLICT (instance_id) DO UPDATE
+SET allowed_cidrs = EXCLUDED.allowed_cidrs,
+    auth_mode = EXCLUDED.auth_mode,
+    basic_auth_username = EXCLUDED.basic_auth_username,
+    basic_auth_password_hash = EXCLUDED.basic_auth_password_hash,
+    public_paths = EXCLUDED.public_paths,
+    updated_at = NOW()
+RETURNING *;
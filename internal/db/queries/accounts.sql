@@ -0,0 +1,34 @@
+-- name: ListUserSubscriptions :many
+-- Subscriptions the user started, they stay with the organization when the user leaves
+SELECT * FROM subscriptions WHERE user_id = $1 ORDER BY created_at;
+
+-- name: ListUserInstances :many
+-- Instances the user created, including deleted ones
+SELECT * FROM instances WHERE user_id = $1 ORDER BY created_at;
+
+-- name: RenameOrganization :exec
+UPDATE organizations SET name = $2, updated_at = NOW() WHERE id = $1;
+
+-- name: DeleteUserMemberships :exec
+DELETE FROM organization_members WHERE user_id = $1;
+
+-- name: DeleteUserIdentities :exec
+DELETE FROM user_identities WHERE user_id = $1;
+
+-- name: DeleteUserAPITokens :exec
+DELETE FROM api_tokens WHERE user_id = $1;
+
+-- name: DeleteMagicLinkTokensByEmail :exec
+DELETE FROM magic_link_tokens WHERE email = $1;
+
+-- name: TombstoneUser :exec
+-- Scrubs the personal data of a deleted account, the address can't collide with a real one
+UPDATE users
+SET email = 'deleted+' || id || '@ranx.cloud',
+    name = '',
+    totp_secret = NULL,
+    totp_enabled_at = NULL,
+    current_organization_id = NULL,
+    deleted_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL;
@@ -0,0 +1,66 @@
+-- name: AdminListUsers :many
+-- search matches the email, the name or the exact ID, NULL lists the newest users
+SELECT id, email, name, is_admin, created_at, last_login_at, deleted_at, current_organization_id,
+       (totp_enabled_at IS NOT NULL)::boolean AS two_factor_enabled
+FROM users
+WHERE sqlc.narg('search')::varchar IS NULL
+   OR email ILIKE '%' || sqlc.narg('search')::varchar || '%'
+   OR name ILIKE '%' || sqlc.narg('search')::varchar || '%'
+   OR id::text = sqlc.narg('search')::varchar
+ORDER BY created_at DESC
+LIMIT sqlc.arg('max_rows');
+
+-- name: AdminListSubscriptions :many
+-- search matches the organization name, the email of the subscriber or a LemonSqueezy ID
+SELECT subscriptions.*, organizations.name AS organization_name, COALESCE(users.email, '')::varchar AS user_email
+FROM subscriptions
+JOIN organizations ON organizations.id = subscriptions.organization_id
+LEFT JOIN users ON users.id = subscriptions.user_id
+WHERE sqlc.narg('search')::varchar IS NULL
+   OR organizations.name ILIKE '%' || sqlc.narg('search')::varchar || '%'
+   OR users.email ILIKE '%' || sqlc.narg('search')::varchar || '%'
+   OR subscriptions.subscription_id = sqlc.narg('search')::varchar
+   OR subscriptions.customer_id = sqlc.narg('search')::varchar
+ORDER BY subscriptions.created_at DESC
+LIMIT sqlc.arg('max_rows');
+
+-- name: AdminListInstances :many
+-- search matches the subdomain, the namespace or the email of the creator, deleted instances included
+SELECT instances.*, organizations.name AS organization_name, COALESCE(users.email, '')::varchar AS user_email
+FROM instances
+JOIN organizations ON organizations.id = instances.organization_id
+LEFT JOIN users ON users.id = instances.user_id
+WHERE sqlc.narg('search')::varchar IS NULL
+   OR instances.subdomain ILIKE '%' || sqlc.narg('search')::varchar || '%'
+   OR instances.namespace = sqlc.narg('search')::varchar
+   OR users.email ILIKE '%' || sqlc.narg('search')::varchar || '%'
+ORDER BY instances.created_at DESC
+LIMIT sqlc.arg('max_rows');
+
+-- name: AdminListStuckInstances :many
+-- Live instances that failed, or whose n8n owner still isn't set up long after provisioning
+SELECT instances.*, organizations.name AS organization_name, COALESCE(users.email, '')::varchar AS user_email
+FROM instances
+JOIN organizations ON organizations.id = instances.organization_id
+LEFT JOIN users ON users.id = instances.user_id
+WHERE instances.deleted_at IS NULL
+  AND (instances.status = 'failed'
+       OR (instances.owner_setup_at IS NULL AND instances.created_at < sqlc.arg('setup_deadline')::timestamp))
+ORDER BY instances.created_at DESC
+LIMIT sqlc.arg('max_rows');
+
+-- name: AdminListAuditEventsByAction :many
+SELECT audit_events.*, COALESCE(users.email, '')::varchar AS actor_email
+FROM audit_events
+LEFT JOIN users ON users.id = audit_events.actor_user_id
+WHERE audit_events.action = $1
+ORDER BY audit_events.created_at DESC
+LIMIT sqlc.arg('max_events');
+
+-- name: GetUserMembership :one
+-- The user and the organization they currently act in, role is NULL when they were removed from it
+SELECT users.id, users.email, users.current_organization_id, organization_members.role AS organization_role
+FROM users
+LEFT JOIN organization_members ON organization_members.organization_id = users.current_organization_id
+    AND organization_members.user_id = users.id
+WHERE users.id = $1 AND users.deleted_at IS NULL;
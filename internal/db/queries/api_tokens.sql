@@ -0,0 +1,30 @@
+-- name: CreateAPIToken :one
+INSERT INTO api_tokens (
+    user_id, organization_id, name, token_prefix, token_hash, scopes, expires_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+)
+RETURNING *;
+
+-- name: GetActiveAPITokenByHash :one
+-- Tokens stop working when their owner leaves the organization
+SELECT api_tokens.*, organization_members.role AS organization_role,
+       (organizations.require_two_factor AND users.totp_enabled_at IS NULL)::boolean AS two_factor_required
+FROM api_tokens
+JOIN organization_members ON organization_members.organization_id = api_tokens.organization_id
+    AND organization_members.user_id = api_tokens.user_id
+JOIN organizations ON organizations.id = api_tokens.organization_id
+JOIN users ON users.id = api_tokens.user_id
+WHERE api_tokens.token_hash = $1 AND (api_tokens.expires_at IS NULL OR api_tokens.expires_at > NOW());
+
+-- name: ListUserAPITokens :many
+SELECT * FROM api_tokens WHERE user_id = $1 AND organization_id = $2 ORDER BY created_at DESC;
+
+-- name: CountUserAPITokens :one
+SELECT COUNT(*) FROM api_tokens WHERE user_id = $1;
+
+-- name: TouchAPIToken :exec
+UPDATE api_tokens SET last_used_at = NOW() WHERE id = $1;
+
+-- name: DeleteUserAPIToken :execrows
+DELETE FROM api_tokens WHERE id = $1 AND user_id = $2;
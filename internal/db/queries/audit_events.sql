@@ -0,0 +1,36 @@
+-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (
+    organization_id, actor_type, actor_user_id, action, target_type, target_id,
+    metadata, ip_address, user_agent, request_id
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+);
+
+-- name: ListAuditEvents :many
+-- Events of an organization and of the user's own account, newest first.
+-- organization_id is NULL for members who may only see their own account.
+-- Account events are the user's own ones outside organizations and those on the user itself, like logins.
+SELECT audit_events.*, COALESCE(users.email, '')::varchar AS actor_email
+FROM audit_events
+LEFT JOIN users ON users.id = audit_events.actor_user_id
+WHERE (audit_events.organization_id = sqlc.narg('organization_id')
+       OR (audit_events.actor_user_id = sqlc.arg('user_id')::uuid
+           AND (audit_events.organization_id IS NULL
+                OR (audit_events.target_type = 'user' AND audit_events.target_id = sqlc.arg('user_id')::uuid::text))))
+  AND (sqlc.narg('action_prefix')::varchar IS NULL OR audit_events.action LIKE sqlc.narg('action_prefix')::varchar || '%')
+  AND (sqlc.narg('actor_user_id')::uuid IS NULL OR audit_events.actor_user_id = sqlc.narg('actor_user_id')::uuid)
+  AND (sqlc.narg('since')::timestamp IS NULL OR audit_events.created_at >= sqlc.narg('since')::timestamp)
+ORDER BY audit_events.created_at DESC
+LIMIT sqlc.arg('max_events');
+
+-- name: ScrubUserAuditEvents :exec
+-- Clears where the actions of a deleted account came from, the only change the append-only trigger allows
+UPDATE audit_events
+SET ip_address = '', user_agent = ''
+WHERE actor_user_id = $1 AND (ip_address <> '' OR user_agent <> '');
+
+-- name: ListUserAuditEvents :many
+SELECT * FROM audit_events
+WHERE actor_user_id = $1
+ORDER BY created_at DESC
+LIMIT sqlc.arg('max_events');
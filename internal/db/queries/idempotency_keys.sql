@@ -0,0 +1,26 @@
+-- name: DeleteStaleUserIdempotencyKeys :exec
+-- Frees keys whose response expired, or whose request never finished
+DELETE FROM idempotency_keys
+WHERE user_id = $1
+  AND (expires_at <= NOW() OR (response_status IS NULL AND created_at < @started_before::timestamp));
+
+-- name: CreateIdempotencyKey :one
+-- Returns no rows when the key is already taken
+INSERT INTO idempotency_keys (
+    user_id, idempotency_key, request_hash, expires_at
+) VALUES (
+    $1, $2, $3, $4
+)
+ON CONFLICT (user_id, idempotency_key) DO NOTHING
+RETURNING *;
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2;
+
+-- name: CompleteIdempotencyKey :exec
+UPDATE idempotency_keys
+SET response_status = $2, response_headers = $3, response_body = $4
+WHERE id = $1;
+
+-- name: DeleteIdempotencyKey :exec
+DELETE FROM idempotency_keys WHERE id = $1;
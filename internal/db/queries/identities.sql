@@ -0,0 +1,37 @@
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities WHERE provider = $1 AND subject = $2;
+
+-- name: ListUserIdentities :many
+SELECT * FROM user_identities WHERE user_id = $1 ORDER BY created_at;
+
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (
+    user_id, provider, subject, email
+) VALUES (
+    $1, $2, $3, $4
+)
+ON CONFLICT (provider, subject) DO UPDATE
+SET last_used_at = NOW()
+RETURNING *;
+
+-- name: TouchUserIdentity :exec
+UPDATE user_identities
+SET email = $2, last_used_at = NOW()
+WHERE id = $1;
+
+-- name: CreateMagicLinkToken :exec
+INSERT INTO magic_link_tokens (
+    token_hash, email, return_to, expires_at
+) VALUES (
+    $1, $2, $3, $4
+);
+
+-- name: CountRecentMagicLinkTokens :one
+SELECT COUNT(*) FROM magic_link_tokens WHERE email = $1 AND created_at > $2;
+
+-- name: ConsumeMagicLinkToken :one
+UPDATE magic_link_tokens
+SET used_at = NOW()
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+RETURNING *;
+
@@ -0,0 +1,2 @@
+-- name: ListenInstanceChanges :exec
+LISTEN instance_changes;
@@ -0,0 +1,54 @@
+-- name: ListMonitoredInstances :many
+-- Running instances that started once, new ones are watched by provisioning until they are ready
+SELECT * FROM instances
+WHERE deleted_at IS NULL
+  AND status IN ('deployed', 'active')
+  AND owner_setup_at IS NOT NULL
+ORDER BY id;
+
+-- name: GetInstanceMonitor :one
+SELECT * FROM instance_monitors WHERE instance_id = $1;
+
+-- name: GetInstanceMonitorForUpdate :one
+SELECT * FROM instance_monitors WHERE instance_id = $1 FOR UPDATE;
+
+-- name: UpsertInstanceMonitorCheck :one
+INSERT INTO instance_monitors (
+    instance_id, status, consecutive_failures, failing_since, last_error, last_checked_at, status_changed_at
+) VALUES (
+    $1, $2, $3, $4, $5, NOW(), NOW()
+)
+ON CONFLICT (instance_id) DO UPDATE
+SET status = EXCLUDED.status,
+    consecutive_failures = EXCLUDED.consecutive_failures,
+    failing_since = EXCLUDED.failing_since,
+    last_error = EXCLUDED.last_error,
+    last_checked_at = EXCLUDED.last_checked_at,
+    status_changed_at = CASE
+        WHEN instance_monitors.status = EXCLUDED.status THEN instance_monitors.status_changed_at
+        ELSE EXCLUDED.status_changed_at
+    END,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: UpsertInstanceMonitorWebhook :one
+INSERT INTO instance_monitors (instance_id, alert_webhook_url)
+VALUES ($1, $2)
+ON CONFLICT (instance_id) DO UPDATE
+SET alert_webhook_url = EXCLUDED.alert_webhook_url,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: CreateInstanceHealthCheck :exec
+INSERT INTO instance_health_checks (instance_id, up, latency_ms, error)
+VALUES ($1, $2, $3, $4);
+
+-- name: GetInstanceUptime :one
+SELECT
+    COUNT(*) AS total,
+    COUNT(*) FILTER (WHERE up) AS up
+FROM instance_health_checks
+WHERE instance_id = $1 AND checked_at >= sqlc.arg(since);
+
+-- name: DeleteInstanceHealthChecksBefore :execrows
+DELETE FROM instance_health_checks WHERE checked_at < sqlc.arg(before);
@@ -1,8 +1,8 @@
 -- name: CreateInstance :one
 INSERT INTO instances (
-    user_id, namespace, subdomain, status, app_version
+    user_id, organization_id, namespace, subdomain, status, app_version
 ) VALUES (
-    $1, $2, $3, $4, $5
+    $1, $2, $3, $4, $5, $6
 ) RETURNING *;
 
 -- name: GetInstance :one
@@ -17,9 +17,9 @@ SELECT * FROM instances WHERE namespace = $1 AND deleted_at IS NULL;
 -- name: GetInstanceBySubdomain :one
 SELECT * FROM instances WHERE subdomain = $1 AND deleted_at IS NULL;
 
--- name: ListInstancesByUser :many
-SELECT * FROM instances 
-WHERE user_id = $1 AND deleted_at IS NULL
+-- name: ListInstancesByOrganization :many
+SELECT * FROM instances
+WHERE organization_id = $1 AND deleted_at IS NULL
 ORDER BY created_at DESC;
 
 -- name: ListAllInstances :many
@@ -52,11 +52,47 @@ SELECT EXISTS(SELECT 1 FROM instances WHERE namespace = $1 AND deleted_at IS NUL
 -- name: CheckSubdomainExists :one
 SELECT EXISTS(SELECT 1 FROM instances WHERE subdomain = $1 AND deleted_at IS NULL);
 
--- name: CountActiveInstancesByUserID :one
-SELECT COUNT(*) FROM instances WHERE user_id = $1 AND deleted_at IS NULL;
+-- name: CountActiveInstancesByOrganizationID :one
+SELECT COUNT(*) FROM instances WHERE organization_id = $1 AND deleted_at IS NULL;
 
 -- name: DeleteInstance :exec
-UPDATE instances 
+-- Instances are kept for the audit log, the settings that only apply to a running instance go with it
+WITH overrides AS (
+    DELETE FROM instance_limit_overrides WHERE instance_id = $1
+), policies AS (
+    DELETE FROM instance_access_policies WHERE instance_id = $1
+)
+UPDATE instances
 SET deleted_at = NOW(), updated_at = NOW()
 WHERE id = $1
 RETURNING *;
+
+-- name: UpdateInstanceConfig :one
+UPDATE instances
+SET app_version = $2, custom_env = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: RevertInstanceConfig :execrows
+-- Restores the previous configuration unless another update changed it since
+UPDATE instances
+SET app_version = @previous_app_version, custom_env = @previous_custom_env, updated_at = NOW()
+WHERE id = @id AND app_version = @app_version AND custom_env = @custom_env;
+
+-- name: MarkInstanceOwnerSetup :exec
+UPDATE instances
+SET owner_setup_at = NOW()
+WHERE id = $1;
+
+-- name: CreateEditorLoginToken :exec
+INSERT INTO editor_login_tokens (
+    token_hash, instance_id, user_id, session_id, return_to, expires_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+);
+
+-- name: ConsumeEditorLoginToken :one
+UPDATE editor_login_tokens
+SET used_at = NOW()
+WHERE token_hash = $1 AND instance_id = $2 AND used_at IS NULL AND expires_at > NOW()
+RETURNING *;
@@ -0,0 +1,72 @@
+-- name: InsertJob :one
+-- Returns no rows when a pending or running job of the kind already has the unique key
+INSERT INTO jobs (kind, args, unique_key, max_attempts, run_at)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (kind, unique_key) WHERE unique_key IS NOT NULL AND status IN ('pending', 'running')
+DO NOTHING
+RETURNING *;
+
+-- name: ClaimJobs :many
+-- Claims due jobs, and running jobs whose worker stopped extending the lease
+UPDATE jobs
+SET status = 'running',
+    attempts = attempts + 1,
+    locked_until = sqlc.arg('locked_until')::timestamp,
+    updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM jobs
+    WHERE kind = ANY(sqlc.arg('kinds')::varchar[])
+      AND ((status = 'pending' AND run_at <= NOW()) OR (status = 'running' AND locked_until < NOW()))
+    ORDER BY run_at
+    LIMIT sqlc.arg('max_jobs')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: ExtendJobLease :execrows
+-- This and the outcome queries below match the attempt that ran, so a worker whose lease
+-- ran out can't touch the job once another replica claimed it again
+UPDATE jobs SET locked_until = $2, updated_at = NOW()
+WHERE id = $1 AND attempts = $3 AND status = 'running';
+
+-- name: CompleteJob :execrows
+UPDATE jobs
+SET status = 'succeeded', locked_until = NULL, finished_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND attempts = $2 AND status = 'running';
+
+-- name: RetryJob :execrows
+UPDATE jobs
+SET status = 'pending', locked_until = NULL, run_at = $3, last_error = $4, updated_at = NOW()
+WHERE id = $1 AND attempts = $2 AND status = 'running';
+
+-- name: FailJob :execrows
+UPDATE jobs
+SET status = 'failed', locked_until = NULL, last_error = $3, finished_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND attempts = $2 AND status = 'running';
+
+-- name: ReleaseJob :execrows
+-- Hands back a job interrupted by shutdown, the attempt doesn't count
+UPDATE jobs
+SET status = 'pending', attempts = attempts - 1, locked_until = NULL, run_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND attempts = $2 AND status = 'running';
+
+-- name: DeleteFinishedJobs :execrows
+DELETE FROM jobs WHERE finished_at < $1;
+
+-- name: UpsertJobSchedule :exec
+-- Keeps the next run of an unchanged schedule, so restarts don't skip or repeat runs
+INSERT INTO job_schedules (kind, spec, next_run_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (kind) DO UPDATE
+SET spec = EXCLUDED.spec,
+    next_run_at = CASE WHEN job_schedules.spec = EXCLUDED.spec THEN job_schedules.next_run_at ELSE EXCLUDED.next_run_at END,
+    updated_at = NOW();
+
+-- name: ClaimDueJobSchedules :many
+-- Locks due schedules for the rest of the transaction, other replicas skip them
+SELECT * FROM job_schedules
+WHERE kind = ANY(sqlc.arg('kinds')::varchar[]) AND next_run_at <= NOW()
+FOR UPDATE SKIP LOCKED;
+
+-- name: UpdateJobScheduleNextRun :exec
+UPDATE job_schedules SET next_run_at = $2, updated_at = NOW() WHERE kind = $1;
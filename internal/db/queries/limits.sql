@@ -0,0 +1,51 @@
+-- name: GetPlanLimits :one
+SELECT * FROM plan_limits WHERE plan = $1;
+
+-- name: ListPlanLimits :many
+SELECT * FROM plan_limits ORDER BY plan;
+
+-- name: UpsertPlanLimits :one
+INSERT INTO plan_limits (
+    plan, requests_per_second, burst, ip_requests_per_second, ip_burst, max_body_bytes, max_concurrent
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+)
+ON CONFLICT (plan) DO UPDATE
+SET requests_per_second = EXCLUDED.requests_per_second,
+    burst = EXCLUDED.burst,
+    ip_requests_per_second = EXCLUDED.ip_requests_per_second,
+    ip_burst = EXCLUDED.ip_burst,
+    max_body_bytes = EXCLUDED.max_body_bytes,
+    max_concurrent = EXCLUDED.max_concurrent,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: GetInstanceLimitOverride :one
+SELECT * FROM instance_limit_overrides WHERE instance_id = $1;
+
+-- name: UpsertInstanceLimitOverride :one
+INSERT INTO instance_limit_overrides (
+    instance_id, requests_per_second, burst, ip_requests_per_second, ip_burst, max_body_bytes, max_concurrent
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+)
+ON CONFLICT (instance_id) DO UPDATE
+SET requests_per_second = EXCLUDED.requests_per_second,
+    burst = EXCLUDED.burst,
+    ip_requests_per_second = EXCLUDED.ip_requests_per_second,
+    ip_burst = EXCLUDED.ip_burst,
+    max_body_bytes = EXCLUDED.max_body_bytes,
+    max_concurrent = EXCLUDED.max_concurrent,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteInstanceLimitOverride :exec
+DELETE FROM instance_limit_overrides WHERE instance_id = $1;
+
+-- name: ListInstanceLimitOverrides :many
+-- Overrides of live instances for the admin console
+SELECT instance_limit_overrides.*, instances.subdomain
+FROM instance_limit_overrides
+JOIN instances ON instances.id = instance_limit_overrides.instance_id
+WHERE instances.deleted_at IS NULL
+ORDER BY instances.subdomain;
@@ -0,0 +1,43 @@
+-- name: CreateNotification :one
+INSERT INTO notifications (user_id, kind, recipient, subject, text_body, html_body)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetNotification :one
+SELECT * FROM notifications WHERE id = $1;
+
+-- name: MarkNotificationSent :exec
+UPDATE notifications SET status = 'sent', error = '', sent_at = NOW() WHERE id = $1;
+
+-- name: MarkNotificationFailed :exec
+UPDATE notifications SET status = 'failed', error = $2 WHERE id = $1;
+
+-- name: ListUserNotifications :many
+SELECT * FROM notifications
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT sqlc.arg('max_rows');
+
+-- name: AdminListNotifications :many
+-- Most recent emails, optionally only those to recipients matching search
+SELECT id, user_id, kind, recipient, subject, status, error, created_at, sent_at FROM notifications
+WHERE sqlc.narg('search')::varchar IS NULL OR recipient ILIKE '%' || sqlc.narg('search')::varchar || '%'
+ORDER BY created_at DESC
+LIMIT sqlc.arg('max_rows');
+
+-- name: DeleteUserNotifications :exec
+DELETE FROM notifications WHERE user_id = $1;
+
+-- name: GetNotificationPreference :one
+SELECT enabled FROM notification_preferences WHERE user_id = $1 AND kind = $2;
+
+-- name: ListNotificationPreferences :many
+SELECT * FROM notification_preferences WHERE user_id = $1;
+
+-- name: UpsertNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, kind, enabled)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, kind) DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = NOW();
+
+-- name: DeleteUserNotificationPreferences :exec
+DELETE FROM notification_preferences WHERE user_id = $1;
@@ -0,0 +1,78 @@
+-- name: CreateOrganization :one
+INSERT INTO organizations (name, personal) VALUES ($1, $2) RETURNING *;
+
+-- name: GetOrganization :one
+SELECT * FROM organizations WHERE id = $1;
+
+-- name: ListUserOrganizations :many
+SELECT organizations.*, organization_members.role
+FROM organizations
+JOIN organization_members ON organization_members.organization_id = organizations.id
+WHERE organization_members.user_id = $1
+ORDER BY organizations.personal DESC, organizations.name;
+
+-- name: GetPersonalOrganization :one
+SELECT organizations.* FROM organizations
+JOIN organization_members ON organization_members.organization_id = organizations.id
+WHERE organization_members.user_id = $1 AND organizations.personal
+LIMIT 1;
+
+-- name: CreateOrganizationMember :exec
+INSERT INTO organization_members (organization_id, user_id, role) VALUES ($1, $2, $3)
+ON CONFLICT (organization_id, user_id) DO NOTHING;
+
+-- name: GetOrganizationMember :one
+SELECT * FROM organization_members WHERE organization_id = $1 AND user_id = $2;
+
+-- name: ListOrganizationMembers :many
+SELECT organization_members.*, users.email, users.name,
+       (users.totp_enabled_at IS NOT NULL)::boolean AS two_factor_enabled
+FROM organization_members
+JOIN users ON users.id = organization_members.user_id
+WHERE organization_members.organization_id = $1
+ORDER BY organization_members.created_at;
+
+-- name: CountOrganizationOwners :one
+SELECT COUNT(*) FROM organization_members WHERE organization_id = $1 AND role = 'owner';
+
+-- name: LockOrganizationMembers :exec
+-- Serializes changes to the members of an organization
+SELECT 1 FROM organization_members WHERE organization_id = $1 FOR UPDATE;
+
+-- name: UpdateOrganizationMemberRole :execrows
+UPDATE organization_members SET role = $3 WHERE organization_id = $1 AND user_id = $2;
+
+-- name: DeleteOrganizationMember :execrows
+DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2;
+
+-- name: SetUserCurrentOrganization :exec
+UPDATE users SET current_organization_id = $2, updated_at = NOW() WHERE id = $1;
+
+-- name: CreateOrganizationInvitation :one
+-- A new invitation to the same address replaces the pending one
+INSERT INTO organization_invitations (
+    organization_id, email, role, token_hash, invited_by, expires_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+ON CONFLICT (organization_id, email) DO UPDATE
+SET role = EXCLUDED.role, token_hash = EXCLUDED.token_hash, invited_by = EXCLUDED.invited_by,
+    created_at = NOW(), expires_at = EXCLUDED.expires_at
+RETURNING *;
+
+-- name: GetActiveOrganizationInvitationByTokenHash :one
+SELECT organization_invitations.*, organizations.name AS organization_name
+FROM organization_invitations
+JOIN organizations ON organizations.id = organization_invitations.organization_id
+WHERE organization_invitations.token_hash = $1 AND organization_invitations.expires_at > NOW();
+
+-- name: ListOrganizationInvitations :many
+SELECT * FROM organization_invitations
+WHERE organization_id = $1 AND expires_at > NOW()
+ORDER BY created_at DESC;
+
+-- name: DeleteOrganizationInvitation :execrows
+DELETE FROM organization_invitations WHERE id = $1 AND organization_id = $2;
+
+-- name: UpdateInstanceOrganization :one
+UPDATE instances SET organization_id = $2, updated_at = NOW() WHERE id = $1 RETURNING *;
@@ -0,0 +1,72 @@
+-- name: CreateSession :one
+INSERT INTO sessions (
+    user_id, token_hash, user_agent, ip_address, expires_at, absolute_expires_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+RETURNING *;
+
+-- name: GetActiveSessionByTokenHash :one
+-- organization_role is NULL when the user was removed from their current organization
+SELECT sessions.*, users.email AS user_email, users.current_organization_id,
+       organization_members.role AS organization_role,
+       (users.totp_enabled_at IS NOT NULL)::boolean AS two_factor_enabled,
+       COALESCE(organizations.require_two_factor, FALSE)::boolean AS organization_requires_two_factor,
+       users.is_admin AS user_is_admin
+FROM sessions
+JOIN users ON users.id = sessions.user_id
+LEFT JOIN organization_members ON organization_members.organization_id = users.current_organization_id
+    AND organization_members.user_id = users.id
+LEFT JOIN organizations ON organizations.id = organization_members.organization_id
+WHERE sessions.token_hash = $1
+  AND sessions.expires_at > NOW()
+  AND sessions.absolute_expires_at > NOW();
+
+-- name: ListActiveUserSessions :many
+SELECT * FROM sessions
+WHERE user_id = $1 AND expires_at > NOW() AND absolute_expires_at > NOW()
+ORDER BY last_seen_at DESC;
+
+-- name: TouchSession :exec
+UPDATE sessions
+SET last_seen_at = NOW(), expires_at = LEAST(@expires_at::timestamp, absolute_expires_at)
+WHERE id = @id;
+
+-- name: DeleteUserSession :execrows
+DELETE FROM sessions WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteSessionByTokenHash :exec
+DELETE FROM sessions WHERE token_hash = $1;
+
+-- name: DeleteUserSessions :exec
+DELETE FROM sessions WHERE user_id = $1;
+
+-- name: DeleteExpiredUserSessions :exec
+DELETE FROM sessions
+WHERE user_id = $1 AND (expires_at <= NOW() OR absolute_expires_at <= NOW());
+
+-- name: IsGateSessionValid :one
+-- Whether a session may still pass the ranx login of an instance owned by the organization:
+-- it is active, its user is a member, and the organization's two-factor policy is met
+SELECT EXISTS (
+    SELECT 1
+    FROM sessions
+    JOIN users ON users.id = sessions.user_id
+    JOIN organization_members ON organization_members.user_id = sessions.user_id
+        AND organization_members.organization_id = @organization_id
+    JOIN organizations ON organizations.id = organization_members.organization_id
+    WHERE sessions.id = @id
+      AND sessions.user_id = @user_id
+      AND sessions.expires_at > NOW()
+      AND sessions.absolute_expires_at > NOW()
+      AND users.deleted_at IS NULL
+      AND (NOT organizations.require_two_factor OR users.totp_enabled_at IS NOT NULL)
+)::boolean;
+
+-- name: CreateGateExchangeToken :exec
+INSERT INTO gate_exchange_tokens (id, instance_id, expires_at) VALUES ($1, $2, $3);
+
+-- name: ConsumeGateExchangeToken :execrows
+UPDATE gate_exchange_tokens
+SET used_at = NOW()
+WHERE id = $1 AND instance_id = $2 AND used_at IS NULL AND expires_at > NOW();
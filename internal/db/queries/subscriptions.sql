@@ -1,6 +1,7 @@
 -- name: CreateSubscription :one
 INSERT INTO subscriptions (
     user_id,
+    organization_id,
     product_id,
     variant_id,
     customer_id,
@@ -9,7 +10,7 @@ INSERT INTO subscriptions (
     status,
     quantity
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
 ) RETURNING *;
 
 -- name: GetSubscriptionByProviderID :one
@@ -17,10 +18,9 @@ SELECT * FROM subscriptions
 WHERE subscription_id = $1
 LIMIT 1;
 
--- name: GetSubscriptionByUserID :one
+-- name: GetSubscriptionByOrganizationID :one
 SELECT * FROM subscriptions
-WHERE user_id = $1
-LIMIT 1;
+WHERE organization_id = $1;
 
 -- name: UpdateSubscriptionStatusByProviderID :exec
 UPDATE subscriptions
@@ -28,7 +28,7 @@ SET status = $2,
     updated_at = NOW()
 WHERE subscription_id = $1;
 
--- name: UpdateSubscriptionByUserID :exec
+-- name: UpdateSubscriptionByOrganizationID :exec
 UPDATE subscriptions
 SET product_id = $2,
     variant_id = $3,
@@ -38,7 +38,7 @@ SET product_id = $2,
     trial_ends_at = $7,
     quantity = $8,
     updated_at = NOW()
-WHERE user_id = $1;
+WHERE organization_id = $1;
 
 -- name: DeleteSubscriptionByID :exec
 DELETE FROM subscriptions
@@ -0,0 +1,71 @@
+-- name: ListTrialsEndingSoon :many
+-- Trials ending before warn_before that haven't been warned yet
+SELECT * FROM subscriptions
+WHERE status = 'trial'
+  AND trial_ends_at > NOW() AND trial_ends_at <= sqlc.arg('warn_before')::timestamp
+  AND trial_warning_sent_at IS NULL
+ORDER BY trial_ends_at;
+
+-- name: ListEndedTrials :many
+-- Trials that ended without the owners being told
+SELECT * FROM subscriptions
+WHERE status = 'trial' AND trial_ends_at <= NOW() AND trial_ended_notice_sent_at IS NULL
+ORDER BY trial_ends_at;
+
+-- name: ListTrialsPastGrace :many
+-- Ended trials whose owners were told before grace_deadline, so the grace period is never cut short
+SELECT * FROM subscriptions
+WHERE status = 'trial' AND trial_ended_notice_sent_at <= sqlc.arg('grace_deadline')::timestamp AND trial_enforced_at IS NULL
+ORDER BY trial_ends_at;
+
+-- name: MarkTrialWarningSent :exec
+UPDATE subscriptions SET trial_warning_sent_at = NOW(), updated_at = NOW() WHERE id = $1;
+
+-- name: MarkTrialEndedNoticeSent :exec
+UPDATE subscriptions SET trial_ended_notice_sent_at = NOW(), updated_at = NOW() WHERE id = $1;
+
+-- name: ClaimTrialEnforcement :one
+-- Claims a trial that is still to be enforced, unless another run claimed it after stale_before
+UPDATE subscriptions
+SET trial_enforcement_claimed_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND status = 'trial' AND trial_enforced_at IS NULL
+  AND (trial_enforcement_claimed_at IS NULL OR trial_enforcement_claimed_at < sqlc.arg('stale_before')::timestamp)
+RETURNING *;
+
+-- name: ReleaseTrialEnforcement :exec
+-- Gives a claim up, so the next run enforces the trial again
+UPDATE subscriptions
+SET trial_enforcement_claimed_at = NULL, updated_at = NOW()
+WHERE id = $1 AND trial_enforcement_claimed_at = sqlc.arg('claimed_at')::timestamp;
+
+-- name: MarkTrialEnforced :one
+-- Only trials that are still trials under the same claim expire, a checkout or trial extension completing meanwhile wins
+UPDATE subscriptions
+SET status = 'expired', trial_enforced_at = NOW(), trial_enforcement_claimed_at = NULL, updated_at = NOW()
+WHERE id = $1 AND status = 'trial' AND trial_enforcement_claimed_at = sqlc.arg('claimed_at')::timestamp
+RETURNING *;
+
+-- name: RestartTrial :one
+-- Reopens a trial at a new end date, the expiry job starts over for it
+UPDATE subscriptions
+SET status = 'trial',
+    trial_ends_at = $2,
+    trial_warning_sent_at = NULL,
+    trial_ended_notice_sent_at = NULL,
+    trial_enforced_at = NULL,
+    trial_enforcement_claimed_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: ListOrganizationOwners :many
+SELECT users.* FROM organization_members
+JOIN users ON users.id = organization_members.user_id
+WHERE organization_members.organization_id = $1 AND organization_members.role = 'owner'
+  AND users.deleted_at IS NULL
+ORDER BY organization_members.created_at;
+
+-- name: ListHibernatedInstances :many
+SELECT * FROM instances
+WHERE organization_id = $1 AND status = 'hibernated' AND deleted_at IS NULL
+ORDER BY created_at;
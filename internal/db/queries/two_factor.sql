@@ -0,0 +1,62 @@
+-- name: SetUserTOTPSecret :exec
+-- Starts a setup, only while two-factor authentication is off
+UPDATE users
+SET totp_secret = $2, updated_at = NOW()
+WHERE id = $1 AND totp_enabled_at IS NULL;
+
+-- name: EnableUserTOTP :exec
+UPDATE users
+SET totp_enabled_at = NOW(), totp_last_step = $2, two_factor_failures = 0, two_factor_locked_until = NULL, updated_at = NOW()
+WHERE id = $1;
+
+-- name: DisableUserTOTP :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0,
+    two_factor_failures = 0, two_factor_locked_until = NULL, updated_at = NOW()
+WHERE id = $1;
+
+-- name: AcceptTOTPStep :execrows
+-- Accepts a code once, a concurrent request with the same code updates no row
+UPDATE users
+SET totp_last_step = $2, two_factor_failures = 0, two_factor_locked_until = NULL
+WHERE id = $1 AND totp_last_step < $2;
+
+-- name: ResetTwoFactorFailures :exec
+UPDATE users
+SET two_factor_failures = 0, two_factor_locked_until = NULL
+WHERE id = $1;
+
+-- name: RecordTwoFactorFailure :exec
+-- Locks two-factor checks for the given time once the user reached the allowed number of failures.
+-- The count starts over after a lockout expired, so the next incorrect code doesn't lock again.
+UPDATE users
+SET two_factor_failures = CASE
+        WHEN two_factor_locked_until <= NOW() THEN 1
+        ELSE two_factor_failures + 1
+    END,
+    two_factor_locked_until = CASE
+        WHEN (CASE WHEN two_factor_locked_until <= NOW() THEN 1 ELSE two_factor_failures + 1 END) >= @max_failures::int
+            THEN @locked_until::timestamp
+        WHEN two_factor_locked_until <= NOW() THEN NULL
+        ELSE two_factor_locked_until
+    END
+WHERE id = @id;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2);
+
+-- name: ConsumeRecoveryCode :execrows
+UPDATE user_recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: CountUnusedRecoveryCodes :one
+SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM user_recovery_codes WHERE user_id = $1;
+
+-- name: SetOrganizationRequireTwoFactor :exec
+UPDATE organizations
+SET require_two_factor = $2, updated_at = NOW()
+WHERE id = $1;
@@ -0,0 +1,275 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: sessions.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const consumeGateExchangeToken = `-- name: ConsumeGateExchangeToken :execrows
+UPDATE gate_exchange_tokens
+SET used_at = NOW()
+WHERE id = $1 AND instance_id = $2 AND used_at IS NULL AND expires_at > NOW()
+`
+
+type ConsumeGateExchangeTokenParams struct {
+	ID         string `json:"id"`
+	InstanceID string `json:"instance_id"`
+}
+
+func (q *Queries) ConsumeGateExchangeToken(ctx context.Context, arg ConsumeGateExchangeTokenParams) (int64, error) {
+	result, err := q.db.Exec(ctx, consumeGateExchangeToken, arg.ID, arg.InstanceID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const createGateExchangeToken = `-- name: CreateGateExchangeToken :exec
+INSERT INTO gate_exchange_tokens (id, instance_id, expires_at) VALUES ($1, $2, $3)
+`
+
+type CreateGateExchangeTokenParams struct {
+	ID         string           `json:"id"`
+	InstanceID string           `json:"instance_id"`
+	ExpiresAt  pgtype.Timestamp `json:"expires_at"`
+}
+
+func (q *Queries) CreateGateExchangeToken(ctx context.Context, arg CreateGateExchangeTokenParams) error {
+	_, err := q.db.Exec(ctx, createGateExchangeToken, arg.ID, arg.InstanceID, arg.ExpiresAt)
+	return err
+}
+
+const createSession = `-- name: CreateSession :one
+INSERT INTO sessions (
+    user_id, token_hash, user_agent, ip_address, expires_at, absolute_expires_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+RETURNING id, user_id, token_hash, user_agent, ip_address, created_at, last_seen_at, expires_at, absolute_expires_at
+`
+
+type CreateSessionParams struct {
+	UserID            string           `json:"user_id"`
+	TokenHash         string           `json:"token_hash"`
+	UserAgent         string           `json:"user_agent"`
+	IpAddress         string           `json:"ip_address"`
+	ExpiresAt         pgtype.Timestamp `json:"expires_at"`
+	AbsoluteExpiresAt pgtype.Timestamp `json:"absolute_expires_at"`
+}
+
+func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error) {
+	row := q.db.QueryRow(ctx, createSession,
+		arg.UserID,
+		arg.TokenHash,
+		arg.UserAgent,
+		arg.IpAddress,
+		arg.ExpiresAt,
+		arg.AbsoluteExpiresAt,
+	)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TokenHash,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.CreatedAt,
+		&i.LastSeenAt,
+		&i.ExpiresAt,
+		&i.AbsoluteExpiresAt,
+	)
+	return i, err
+}
+
+const deleteExpiredUserSessions = `-- name: DeleteExpiredUserSessions :exec
+DELETE FROM sessions
+WHERE user_id = $1 AND (expires_at <= NOW() OR absolute_expires_at <= NOW())
+`
+
+func (q *Queries) DeleteExpiredUserSessions(ctx context.Context, userID string) error {
+	_, err := q.db.Exec(ctx, deleteExpiredUserSessions, userID)
+	return err
+}
+
+const deleteSessionByTokenHash = `-- name: DeleteSessionByTokenHash :exec
+DELETE FROM sessions WHERE token_hash = $1
+`
+
+func (q *Queries) DeleteSessionByTokenHash(ctx context.Context, tokenHash string) error {
+	_, err := q.db.Exec(ctx, deleteSessionByTokenHash, tokenHash)
+	return err
+}
+
+const deleteUserSession = `-- name: DeleteUserSession :execrows
+DELETE FROM sessions WHERE id = $1 AND user_id = $2
+`
+
+type DeleteUserSessionParams struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+}
+
+func (q *Queries) DeleteUserSession(ctx context.Context, arg DeleteUserSessionParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteUserSession, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteUserSessions = `-- name: DeleteUserSessions :exec
+DELETE FROM sessions WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserSessions(ctx context.Context, userID string) error {
+	_, err := q.db.Exec(ctx, deleteUserSessions, userID)
+	return err
+}
+
+const getActiveSessionByTokenHash = `-- name: GetActiveSessionByTokenHash :one
+SELECT sessions.id, sessions.user_id, sessions.token_hash, sessions.user_agent, sessions.ip_address, sessions.created_at, sessions.last_seen_at, sessions.expires_at, sessions.absolute_expires_at, users.email AS user_email, users.current_organization_id,
+       organization_members.role AS organization_role,
+       (users.totp_enabled_at IS NOT NULL)::boolean AS two_factor_enabled,
+       COALESCE(organizations.require_two_factor, FALSE)::boolean AS organization_requires_two_factor,
+       users.is_admin AS user_is_admin
+FROM sessions
+JOIN users ON users.id = sessions.user_id
+LEFT JOIN organization_members ON organization_members.organization_id = users.current_organization_id
+    AND organization_members.user_id = users.id
+LEFT JOIN organizations ON organizations.id = organization_members.organization_id
+WHERE sessions.token_hash = $1
+  AND sessions.expires_at > NOW()
+  AND sessions.absolute_expires_at > NOW()
+`
+
+type GetActiveSessionByTokenHashRow struct {
+	ID                            string           `json:"id"`
+	UserID                        string           `json:"user_id"`
+	TokenHash                     string           `json:"token_hash"`
+	UserAgent                     string           `json:"user_agent"`
+	IpAddress                     string           `json:"ip_address"`
+	CreatedAt                     pgtype.Timestamp `json:"created_at"`
+	LastSeenAt                    pgtype.Timestamp `json:"last_seen_at"`
+	ExpiresAt                     pgtype.Timestamp `json:"expires_at"`
+	AbsoluteExpiresAt             pgtype.Timestamp `json:"absolute_expires_at"`
+	UserEmail                     string           `json:"user_email"`
+	CurrentOrganizationID         *string          `json:"current_organization_id"`
+	OrganizationRole              pgtype.Text      `json:"organization_role"`
+	TwoFactorEnabled              bool             `json:"two_factor_enabled"`
+	OrganizationRequiresTwoFactor bool             `json:"organization_requires_two_factor"`
+	UserIsAdmin                   bool             `json:"user_is_admin"`
+}
+
+// organization_role is NULL when the user was removed from their current organization
+func (q *Queries) GetActiveSessionByTokenHash(ctx context.Context, tokenHash string) (GetActiveSessionByTokenHashRow, error) {
+	row := q.db.QueryRow(ctx, getActiveSessionByTokenHash, tokenHash)
+	var i GetActiveSessionByTokenHashRow
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TokenHash,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.CreatedAt,
+		&i.LastSeenAt,
+		&i.ExpiresAt,
+		&i.AbsoluteExpiresAt,
+		&i.UserEmail,
+		&i.CurrentOrganizationID,
+		&i.OrganizationRole,
+		&i.TwoFactorEnabled,
+		&i.OrganizationRequiresTwoFactor,
+		&i.UserIsAdmin,
+	)
+	return i, err
+}
+
+const isGateSessionValid = `-- name: IsGateSessionValid :one
+SELECT EXISTS (
+    SELECT 1
+    FROM sessions
+    JOIN users ON users.id = sessions.user_id
+    JOIN organization_members ON organization_members.user_id = sessions.user_id
+        AND organization_members.organization_id = $1
+    JOIN organizations ON organizations.id = organization_members.organization_id
+    WHERE sessions.id = $2
+      AND sessions.user_id = $3
+      AND sessions.expires_at > NOW()
+      AND sessions.absolute_expires_at > NOW()
+      AND users.deleted_at IS NULL
+      AND (NOT organizations.require_two_factor OR users.totp_enabled_at IS NOT NULL)
+)::boolean
+`
+
+type IsGateSessionValidParams struct {
+	OrganizationID string `json:"organization_id"`
+	ID             string `json:"id"`
+	UserID         string `json:"user_id"`
+}
+
+// Whether a session may still pass the ranx login of an instance owned by the organization:
+// it is active, its user is a member, and the organization's two-factor policy is met
+func (q *Queries) IsGateSessionValid(ctx context.Context, arg IsGateSessionValidParams) (bool, error) {
+	row := q.db.QueryRow(ctx, isGateSessionValid, arg.OrganizationID, arg.ID, arg.UserID)
+	var column_1 bool
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const listActiveUserSessions = `-- name: ListActiveUserSessions :many
+SELECT id, user_id, token_hash, user_agent, ip_address, created_at, last_seen_at, expires_at, absolute_expires_at FROM sessions
+WHERE user_id = $1 AND expires_at > NOW() AND absolute_expires_at > NOW()
+ORDER BY last_seen_at DESC
+`
+
+func (q *Queries) ListActiveUserSessions(ctx context.Context, userID string) ([]Session, error) {
+	rows, err := q.db.Query(ctx, listActiveUserSessions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Session
+	for rows.Next() {
+		var i Session
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.TokenHash,
+			&i.UserAgent,
+			&i.IpAddress,
+			&i.CreatedAt,
+			&i.LastSeenAt,
+			&i.ExpiresAt,
+			&i.AbsoluteExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const touchSession = `-- name: TouchSession :exec
+UPDATE sessions
+SET last_seen_at = NOW(), expires_at = LEAST($1::timestamp, absolute_expires_at)
+WHERE id = $2
+`
+
+type TouchSessionParams struct {
+	ExpiresAt pgtype.Timestamp `json:"expires_at"`
+	ID        string           `json:"id"`
+}
+
+func (q *Queries) TouchSession(ctx context.Context, arg TouchSessionParams) error {
+	_, err := q.db.Exec(ctx, touchSession, arg.ExpiresAt, arg.ID)
+	return err
+}
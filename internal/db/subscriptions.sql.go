@@ -14,6 +14,7 @@ import (
 const createSubscription = `-- name: CreateSubscription :one
 INSERT INTO subscriptions (
     user_id,
+    organization_id,
     product_id,
     variant_id,
     customer_id,
@@ -22,12 +23,13 @@ INSERT INTO subscriptions (
     status,
     quantity
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8
-) RETURNING id, user_id, product_id, variant_id, customer_id, subscription_id, status, quantity, trial_ends_at, created_at, updated_at
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
+) RETURNING id, user_id, product_id, variant_id, customer_id, subscription_id, status, quantity, trial_ends_at, created_at, updated_at, organization_id, trial_warning_sent_at, trial_ended_notice_sent_at, trial_enforced_at, trial_enforcement_claimed_at
 `
 
 type CreateSubscriptionParams struct {
 	UserID         string           `json:"user_id"`
+	OrganizationID string           `json:"organization_id"`
 	ProductID      string           `json:"product_id"`
 	VariantID      string           `json:"variant_id"`
 	CustomerID     string           `json:"customer_id"`
@@ -40,6 +42,7 @@ type CreateSubscriptionParams struct {
 func (q *Queries) CreateSubscription(ctx context.Context, arg CreateSubscriptionParams) (Subscription, error) {
 	row := q.db.QueryRow(ctx, createSubscription,
 		arg.UserID,
+		arg.OrganizationID,
 		arg.ProductID,
 		arg.VariantID,
 		arg.CustomerID,
@@ -61,6 +64,11 @@ func (q *Queries) CreateSubscription(ctx context.Context, arg CreateSubscription
 		&i.TrialEndsAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.OrganizationID,
+		&i.TrialWarningSentAt,
+		&i.TrialEndedNoticeSentAt,
+		&i.TrialEnforcedAt,
+		&i.TrialEnforcementClaimedAt,
 	)
 	return i, err
 }
@@ -75,14 +83,13 @@ func (q *Queries) DeleteSubscriptionByID(ctx context.Context, id string) error {
 	return err
 }
 
-const getSubscriptionByProviderID = `-- name: GetSubscriptionByProviderID :one
-SELECT id, user_id, product_id, variant_id, customer_id, subscription_id, status, quantity, trial_ends_at, created_at, updated_at FROM subscriptions
-WHERE subscription_id = $1
-LIMIT 1
+const getSubscriptionByOrganizationID = `-- name: GetSubscriptionByOrganizationID :one
+SELECT id, user_id, product_id, variant_id, customer_id, subscription_id, status, quantity, trial_ends_at, created_at, updated_at, organization_id, trial_warning_sent_at, trial_ended_notice_sent_at, trial_enforced_at, trial_enforcement_claimed_at FROM subscriptions
+WHERE organization_id = $1
 `
 
-func (q *Queries) GetSubscriptionByProviderID(ctx context.Context, subscriptionID string) (Subscription, error) {
-	row := q.db.QueryRow(ctx, getSubscriptionByProviderID, subscriptionID)
+func (q *Queries) GetSubscriptionByOrganizationID(ctx context.Context, organizationID string) (Subscription, error) {
+	row := q.db.QueryRow(ctx, getSubscriptionByOrganizationID, organizationID)
 	var i Subscription
 	err := row.Scan(
 		&i.ID,
@@ -96,18 +103,23 @@ func (q *Queries) GetSubscriptionByProviderID(ctx context.Context, subscriptionI
 		&i.TrialEndsAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.OrganizationID,
+		&i.TrialWarningSentAt,
+		&i.TrialEndedNoticeSentAt,
+		&i.TrialEnforcedAt,
+		&i.TrialEnforcementClaimedAt,
 	)
 	return i, err
 }
 
-const getSubscriptionByUserID = `-- name: GetSubscriptionByUserID :one
-SELECT id, user_id, product_id, variant_id, customer_id, subscription_id, status, quantity, trial_ends_at, created_at, updated_at FROM subscriptions
-WHERE user_id = $1
+const getSubscriptionByProviderID = `-- name: GetSubscriptionByProviderID :one
+SELECT id, user_id, product_id, variant_id, customer_id, subscription_id, status, quantity, trial_ends_at, created_at, updated_at, organization_id, trial_warning_sent_at, trial_ended_notice_sent_at, trial_enforced_at, trial_enforcement_claimed_at FROM subscriptions
+WHERE subscription_id = $1
 LIMIT 1
 `
 
-func (q *Queries) GetSubscriptionByUserID(ctx context.Context, userID string) (Subscription, error) {
-	row := q.db.QueryRow(ctx, getSubscriptionByUserID, userID)
+func (q *Queries) GetSubscriptionByProviderID(ctx context.Context, subscriptionID string) (Subscription, error) {
+	row := q.db.QueryRow(ctx, getSubscriptionByProviderID, subscriptionID)
 	var i Subscription
 	err := row.Scan(
 		&i.ID,
@@ -121,11 +133,16 @@ func (q *Queries) GetSubscriptionByUserID(ctx context.Context, userID string) (S
 		&i.TrialEndsAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.OrganizationID,
+		&i.TrialWarningSentAt,
+		&i.TrialEndedNoticeSentAt,
+		&i.TrialEnforcedAt,
+		&i.TrialEnforcementClaimedAt,
 	)
 	return i, err
 }
 
-const updateSubscriptionByUserID = `-- name: UpdateSubscriptionByUserID :exec
+const updateSubscriptionByOrganizationID = `-- name: UpdateSubscriptionByOrganizationID :exec
 UPDATE subscriptions
 SET product_id = $2,
     variant_id = $3,
@@ -135,11 +152,11 @@ SET product_id = $2,
     trial_ends_at = $7,
     quantity = $8,
     updated_at = NOW()
-WHERE user_id = $1
+WHERE organization_id = $1
 `
 
-type UpdateSubscriptionByUserIDParams struct {
-	UserID         string           `json:"user_id"`
+type UpdateSubscriptionByOrganizationIDParams struct {
+	OrganizationID string           `json:"organization_id"`
 	ProductID      string           `json:"product_id"`
 	VariantID      string           `json:"variant_id"`
 	CustomerID     string           `json:"customer_id"`
@@ -149,9 +166,9 @@ type UpdateSubscriptionByUserIDParams struct {
 	Quantity       int32            `json:"quantity"`
 }
 
-func (q *Queries) UpdateSubscriptionByUserID(ctx context.Context, arg UpdateSubscriptionByUserIDParams) error {
-	_, err := q.db.Exec(ctx, updateSubscriptionByUserID,
-		arg.UserID,
+func (q *Queries) UpdateSubscriptionByOrganizationID(ctx context.Context, arg UpdateSubscriptionByOrganizationIDParams) error {
+	_, err := q.db.Exec(ctx, updateSubscriptionByOrganizationID,
+		arg.OrganizationID,
 		arg.ProductID,
 		arg.VariantID,
 		arg.CustomerID,
@@ -202,7 +219,7 @@ UPDATE subscriptions
 SET trial_ends_at = $2,
     updated_at = NOW()
 WHERE id = $1
-RETURNING id, user_id, product_id, variant_id, customer_id, subscription_id, status, quantity, trial_ends_at, created_at, updated_at
+RETURNING id, user_id, product_id, variant_id, customer_id, subscription_id, status, quantity, trial_ends_at, created_at, updated_at, organization_id, trial_warning_sent_at, trial_ended_notice_sent_at, trial_enforced_at, trial_enforcement_claimed_at
 `
 
 type UpdateSubscriptionTrialEndsAtParams struct {
@@ -225,6 +242,11 @@ func (q *Queries) UpdateSubscriptionTrialEndsAt(ctx context.Context, arg UpdateS
 		&i.TrialEndsAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.OrganizationID,
+		&i.TrialWarningSentAt,
+		&i.TrialEndedNoticeSentAt,
+		&i.TrialEnforcedAt,
+		&i.TrialEnforcementClaimedAt,
 	)
 	return i, err
 }
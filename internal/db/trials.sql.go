@@ -0,0 +1,382 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: trials.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimTrialEnforcement = `-- name: ClaimTrialEnforcement :one
+UPDATE subscriptions
+SET trial_enforcement_claimed_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND status = 'trial' AND trial_enforced_at IS NULL
+  AND (trial_enforcement_claimed_at IS NULL OR trial_enforcement_claimed_at < $2::timestamp)
+RETURNING id, user_id, product_id, variant_id, customer_id, subscription_id, status, quantity, trial_ends_at, created_at, updated_at, organization_id, trial_warning_sent_at, trial_ended_notice_sent_at, trial_enforced_at, trial_enforcement_claimed_at
+`
+
+type ClaimTrialEnforcementParams struct {
+	ID          string           `json:"id"`
+	StaleBefore pgtype.Timestamp `json:"stale_before"`
+}
+
+// Claims a trial that is still to be enforced, unless another run claimed it after stale_before
+func (q *Queries) ClaimTrialEnforcement(ctx context.Context, arg ClaimTrialEnforcementParams) (Subscription, error) {
+	row := q.db.QueryRow(ctx, claimTrialEnforcement, arg.ID, arg.StaleBefore)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ProductID,
+		&i.VariantID,
+		&i.CustomerID,
+		&i.SubscriptionID,
+		&i.Status,
+		&i.Quantity,
+		&i.TrialEndsAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OrganizationID,
+		&i.TrialWarningSentAt,
+		&i.TrialEndedNoticeSentAt,
+		&i.TrialEnforcedAt,
+		&i.TrialEnforcementClaimedAt,
+	)
+	return i, err
+}
+
+const listEndedTrials = `-- name: ListEndedTrials :many
+SELECT id, user_id, product_id, variant_id, customer_id, subscription_id, status, quantity, trial_ends_at, created_at, updated_at, organization_id, trial_warning_sent_at, trial_ended_notice_sent_at, trial_enforced_at, trial_enforcement_claimed_at FROM subscriptions
+WHERE status = 'trial' AND trial_ends_at <= NOW() AND trial_ended_notice_sent_at IS NULL
+ORDER BY trial_ends_at
+`
+
+// Trials that ended without the owners being told
+func (q *Queries) ListEndedTrials(ctx context.Context) ([]Subscription, error) {
+	rows, err := q.db.Query(ctx, listEndedTrials)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Subscription
+	for rows.Next() {
+		var i Subscription
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ProductID,
+			&i.VariantID,
+			&i.CustomerID,
+			&i.SubscriptionID,
+			&i.Status,
+			&i.Quantity,
+			&i.TrialEndsAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.OrganizationID,
+			&i.TrialWarningSentAt,
+			&i.TrialEndedNoticeSentAt,
+			&i.TrialEnforcedAt,
+			&i.TrialEnforcementClaimedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listHibernatedInstances = `-- name: ListHibernatedInstances :many
+SELECT id, user_id, status, namespace, subdomain, created_at, updated_at, deployed_at, deleted_at, app_version, custom_env, organization_id, owner_setup_at FROM instances
+WHERE organization_id = $1 AND status = 'hibernated' AND deleted_at IS NULL
+ORDER BY created_at
+`
+
+func (q *Queries) ListHibernatedInstances(ctx context.Context, organizationID string) ([]Instance, error) {
+	rows, err := q.db.Query(ctx, listHibernatedInstances, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Instance
+	for rows.Next() {
+		var i Instance
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Status,
+			&i.Namespace,
+			&i.Subdomain,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeployedAt,
+			&i.DeletedAt,
+			&i.AppVersion,
+			&i.CustomEnv,
+			&i.OrganizationID,
+			&i.OwnerSetupAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOrganizationOwners = `-- name: ListOrganizationOwners :many
+SELECT users.id, users.email, users.name, users.created_at, users.last_login_at, users.updated_at, users.current_organization_id, users.totp_secret, users.totp_enabled_at, users.totp_last_step, users.two_factor_failures, users.two_factor_locked_until, users.deleted_at, users.is_admin FROM organization_members
+JOIN users ON users.id = organization_members.user_id
+WHERE organization_members.organization_id = $1 AND organization_members.role = 'owner'
+  AND users.deleted_at IS NULL
+ORDER BY organization_members.created_at
+`
+
+func (q *Queries) ListOrganizationOwners(ctx context.Context, organizationID string) ([]User, error) {
+	rows, err := q.db.Query(ctx, listOrganizationOwners, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.Name,
+			&i.CreatedAt,
+			&i.LastLoginAt,
+			&i.UpdatedAt,
+			&i.CurrentOrganizationID,
+			&i.TotpSecret,
+			&i.TotpEnabledAt,
+			&i.TotpLastStep,
+			&i.TwoFactorFailures,
+			&i.TwoFactorLockedUntil,
+			&i.DeletedAt,
+			&i.IsAdmin,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTrialsEndingSoon = `-- name: ListTrialsEndingSoon :many
+SELECT id, user_id, product_id, variant_id, customer_id, subscription_id, status, quantity, trial_ends_at, created_at, updated_at, organization_id, trial_warning_sent_at, trial_ended_notice_sent_at, trial_enforced_at, trial_enforcement_claimed_at FROM subscriptions
+WHERE status = 'trial'
+  AND trial_ends_at > NOW() AND trial_ends_at <= $1::timestamp
+  AND trial_warning_sent_at IS NULL
+ORDER BY trial_ends_at
+`
+
+// Trials ending before warn_before that haven't been warned yet
+func (q *Queries) ListTrialsEndingSoon(ctx context.Context, warnBefore pgtype.Timestamp) ([]Subscription, error) {
+	rows, err := q.db.Query(ctx, listTrialsEndingSoon, warnBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Subscription
+	for rows.Next() {
+		var i Subscription
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ProductID,
+			&i.VariantID,
+			&i.CustomerID,
+			&i.SubscriptionID,
+			&i.Status,
+			&i.Quantity,
+			&i.TrialEndsAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.OrganizationID,
+			&i.TrialWarningSentAt,
+			&i.TrialEndedNoticeSentAt,
+			&i.TrialEnforcedAt,
+			&i.TrialEnforcementClaimedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTrialsPastGrace = `-- name: ListTrialsPastGrace :many
+SELECT id, user_id, product_id, variant_id, customer_id, subscription_id, status, quantity, trial_ends_at, created_at, updated_at, organization_id, trial_warning_sent_at, trial_ended_notice_sent_at, trial_enforced_at, trial_enforcement_claimed_at FROM subscriptions
+WHERE status = 'trial' AND trial_ended_notice_sent_at <= $1::timestamp AND trial_enforced_at IS NULL
+ORDER BY trial_ends_at
+`
+
+// Ended trials whose owners were told before grace_deadline, so the grace period is never cut short
+func (q *Queries) ListTrialsPastGrace(ctx context.Context, graceDeadline pgtype.Timestamp) ([]Subscription, error) {
+	rows, err := q.db.Query(ctx, listTrialsPastGrace, graceDeadline)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Subscription
+	for rows.Next() {
+		var i Subscription
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ProductID,
+			&i.VariantID,
+			&i.CustomerID,
+			&i.SubscriptionID,
+			&i.Status,
+			&i.Quantity,
+			&i.TrialEndsAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.OrganizationID,
+			&i.TrialWarningSentAt,
+			&i.TrialEndedNoticeSentAt,
+			&i.TrialEnforcedAt,
+			&i.TrialEnforcementClaimedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markTrialEndedNoticeSent = `-- name: MarkTrialEndedNoticeSent :exec
+UPDATE subscriptions SET trial_ended_notice_sent_at = NOW(), updated_at = NOW() WHERE id = $1
+`
+
+func (q *Queries) MarkTrialEndedNoticeSent(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, markTrialEndedNoticeSent, id)
+	return err
+}
+
+const markTrialEnforced = `-- name: MarkTrialEnforced :one
+UPDATE subscriptions
+SET status = 'expired', trial_enforced_at = NOW(), trial_enforcement_claimed_at = NULL, updated_at = NOW()
+WHERE id = $1 AND status = 'trial' AND trial_enforcement_claimed_at = $2::timestamp
+RETURNING id, user_id, product_id, variant_id, customer_id, subscription_id, status, quantity, trial_ends_at, created_at, updated_at, organization_id, trial_warning_sent_at, trial_ended_notice_sent_at, trial_enforced_at, trial_enforcement_claimed_at
+`
+
+type MarkTrialEnforcedParams struct {
+	ID        string           `json:"id"`
+	ClaimedAt pgtype.Timestamp `json:"claimed_at"`
+}
+
+// Only trials that are still trials under the same claim expire, a checkout or trial extension completing meanwhile wins
+func (q *Queries) MarkTrialEnforced(ctx context.Context, arg MarkTrialEnforcedParams) (Subscription, error) {
+	row := q.db.QueryRow(ctx, markTrialEnforced, arg.ID, arg.ClaimedAt)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ProductID,
+		&i.VariantID,
+		&i.CustomerID,
+		&i.SubscriptionID,
+		&i.Status,
+		&i.Quantity,
+		&i.TrialEndsAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OrganizationID,
+		&i.TrialWarningSentAt,
+		&i.TrialEndedNoticeSentAt,
+		&i.TrialEnforcedAt,
+		&i.TrialEnforcementClaimedAt,
+	)
+	return i, err
+}
+
+const markTrialWarningSent = `-- name: MarkTrialWarningSent :exec
+UPDATE subscriptions SET trial_warning_sent_at = NOW(), updated_at = NOW() WHERE id = $1
+`
+
+func (q *Queries) MarkTrialWarningSent(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, markTrialWarningSent, id)
+	return err
+}
+
+const releaseTrialEnforcement = `-- name: ReleaseTrialEnforcement :exec
+UPDATE subscriptions
+SET trial_enforcement_claimed_at = NULL, updated_at = NOW()
+WHERE id = $1 AND trial_enforcement_claimed_at = $2::timestamp
+`
+
+type ReleaseTrialEnforcementParams struct {
+	ID        string           `json:"id"`
+	ClaimedAt pgtype.Timestamp `json:"claimed_at"`
+}
+
+// Gives a claim up, so the next run enforces the trial again
+func (q *Queries) ReleaseTrialEnforcement(ctx context.Context, arg ReleaseTrialEnforcementParams) error {
+	_, err := q.db.Exec(ctx, releaseTrialEnforcement, arg.ID, arg.ClaimedAt)
+	return err
+}
+
+const restartTrial = `-- name: RestartTrial :one
+UPDATE subscriptions
+SET status = 'trial',
+    trial_ends_at = $2,
+    trial_warning_sent_at = NULL,
+    trial_ended_notice_sent_at = NULL,
+    trial_enforced_at = NULL,
+    trial_enforcement_claimed_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, user_id, product_id, variant_id, customer_id, subscription_id, status, quantity, trial_ends_at, created_at, updated_at, organization_id, trial_warning_sent_at, trial_ended_notice_sent_at, trial_enforced_at, trial_enforcement_claimed_at
+`
+
+type RestartTrialParams struct {
+	ID          string           `json:"id"`
+	TrialEndsAt pgtype.Timestamp `json:"trial_ends_at"`
+}
+
+// Reopens a trial at a new end date, the expiry job starts over for it
+func (q *Queries) RestartTrial(ctx context.Context, arg RestartTrialParams) (Subscription, error) {
+	row := q.db.QueryRow(ctx, restartTrial, arg.ID, arg.TrialEndsAt)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ProductID,
+		&i.VariantID,
+		&i.CustomerID,
+		&i.SubscriptionID,
+		&i.Status,
+		&i.Quantity,
+		&i.TrialEndsAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OrganizationID,
+		&i.TrialWarningSentAt,
+		&i.TrialEndedNoticeSentAt,
+		&i.TrialEnforcedAt,
+		&i.TrialEnforcementClaimedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,185 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: two_factor.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const acceptTOTPStep = `-- name: AcceptTOTPStep :execrows
+UPDATE users
+SET totp_last_step = $2, two_factor_failures = 0, two_factor_locked_until = NULL
+WHERE id = $1 AND totp_last_step < $2
+`
+
+type AcceptTOTPStepParams struct {
+	ID           string `json:"id"`
+	TotpLastStep int64  `json:"totp_last_step"`
+}
+
+// Accepts a code once, a concurrent request with the same code updates no row
+func (q *Queries) AcceptTOTPStep(ctx context.Context, arg AcceptTOTPStepParams) (int64, error) {
+	result, err := q.db.Exec(ctx, acceptTOTPStep, arg.ID, arg.TotpLastStep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const consumeRecoveryCode = `-- name: ConsumeRecoveryCode :execrows
+UPDATE user_recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+`
+
+type ConsumeRecoveryCodeParams struct {
+	UserID   string `json:"user_id"`
+	CodeHash string `json:"code_hash"`
+}
+
+func (q *Queries) ConsumeRecoveryCode(ctx context.Context, arg ConsumeRecoveryCodeParams) (int64, error) {
+	result, err := q.db.Exec(ctx, consumeRecoveryCode, arg.UserID, arg.CodeHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const countUnusedRecoveryCodes = `-- name: CountUnusedRecoveryCodes :one
+SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = $1 AND used_at IS NULL
+`
+
+func (q *Queries) CountUnusedRecoveryCodes(ctx context.Context, userID string) (int64, error) {
+	row := q.db.QueryRow(ctx, countUnusedRecoveryCodes, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createRecoveryCode = `-- name: CreateRecoveryCode :exec
+INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)
+`
+
+type CreateRecoveryCodeParams struct {
+	UserID   string `json:"user_id"`
+	CodeHash string `json:"code_hash"`
+}
+
+func (q *Queries) CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) error {
+	_, err := q.db.Exec(ctx, createRecoveryCode, arg.UserID, arg.CodeHash)
+	return err
+}
+
+const deleteRecoveryCodes = `-- name: DeleteRecoveryCodes :exec
+DELETE FROM user_recovery_codes WHERE user_id = $1
+`
+
+func (q *Queries) DeleteRecoveryCodes(ctx context.Context, userID string) error {
+	_, err := q.db.Exec(ctx, deleteRecoveryCodes, userID)
+	return err
+}
+
+const disableUserTOTP = `-- name: DisableUserTOTP :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0,
+    two_factor_failures = 0, two_factor_locked_until = NULL, updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) DisableUserTOTP(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, disableUserTOTP, id)
+	return err
+}
+
+const enableUserTOTP = `-- name: EnableUserTOTP :exec
+UPDATE users
+SET totp_enabled_at = NOW(), totp_last_step = $2, two_factor_failures = 0, two_factor_locked_until = NULL, updated_at = NOW()
+WHERE id = $1
+`
+
+type EnableUserTOTPParams struct {
+	ID           string `json:"id"`
+	TotpLastStep int64  `json:"totp_last_step"`
+}
+
+func (q *Queries) EnableUserTOTP(ctx context.Context, arg EnableUserTOTPParams) error {
+	_, err := q.db.Exec(ctx, enableUserTOTP, arg.ID, arg.TotpLastStep)
+	return err
+}
+
+const recordTwoFactorFailure = `-- name: RecordTwoFactorFailure :exec
+UPDATE users
+SET two_factor_failures = CASE
+        WHEN two_factor_locked_until <= NOW() THEN 1
+        ELSE two_factor_failures + 1
+    END,
+    two_factor_locked_until = CASE
+        WHEN (CASE WHEN two_factor_locked_until <= NOW() THEN 1 ELSE two_factor_failures + 1 END) >= $1::int
+            THEN $2::timestamp
+        WHEN two_factor_locked_until <= NOW() THEN NULL
+        ELSE two_factor_locked_until
+    END
+WHERE id = $3
+`
+
+type RecordTwoFactorFailureParams struct {
+	MaxFailures int32            `json:"max_failures"`
+	LockedUntil pgtype.Timestamp `json:"locked_until"`
+	ID          string           `json:"id"`
+}
+
+// Locks two-factor checks for the given time once the user reached the allowed number of failures.
+// The count starts over after a lockout expired, so the next incorrect code doesn't lock again.
+func (q *Queries) RecordTwoFactorFailure(ctx context.Context, arg RecordTwoFactorFailureParams) error {
+	_, err := q.db.Exec(ctx, recordTwoFactorFailure, arg.MaxFailures, arg.LockedUntil, arg.ID)
+	return err
+}
+
+const resetTwoFactorFailures = `-- name: ResetTwoFactorFailures :exec
+UPDATE users
+SET two_factor_failures = 0, two_factor_locked_until = NULL
+WHERE id = $1
+`
+
+func (q *Queries) ResetTwoFactorFailures(ctx context.Context, id string) error {
+	_, err := q.db.Exec(ctx, resetTwoFactorFailures, id)
+	return err
+}
+
+const setOrganizationRequireTwoFactor = `-- name: SetOrganizationRequireTwoFactor :exec
+UPDATE organizations
+SET require_two_factor = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type SetOrganizationRequireTwoFactorParams struct {
+	ID               string `json:"id"`
+	RequireTwoFactor bool   `json:"require_two_factor"`
+}
+
+func (q *Queries) SetOrganizationRequireTwoFactor(ctx context.Context, arg SetOrganizationRequireTwoFactorParams) error {
+	_, err := q.db.Exec(ctx, setOrganizationRequireTwoFactor, arg.ID, arg.RequireTwoFactor)
+	return err
+}
+
+const setUserTOTPSecret = `-- name: SetUserTOTPSecret :exec
+UPDATE users
+SET totp_secret = $2, updated_at = NOW()
+WHERE id = $1 AND totp_enabled_at IS NULL
+`
+
+type SetUserTOTPSecretParams struct {
+	ID         string      `json:"id"`
+	TotpSecret pgtype.Text `json:"totp_secret"`
+}
+
+// Starts a setup, only while two-factor authentication is off
+func (q *Queries) SetUserTOTPSecret(ctx context.Context, arg SetUserTOTPSecretParams) error {
+	_, err := q.db.Exec(ctx, setUserTOTPSecret, arg.ID, arg.TotpSecret)
+	return err
+}
@@ -23,7 +23,7 @@ INSERT INTO users (
     email, name
 ) VALUES (
     $1, $2
-) RETURNING id, email, name, created_at, last_login_at, updated_at
+) RETURNING id, email, name, created_at, last_login_at, updated_at, current_organization_id, totp_secret, totp_enabled_at, totp_last_step, two_factor_failures, two_factor_locked_until, deleted_at, is_admin
 `
 
 type CreateUserParams struct {
@@ -41,12 +41,20 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.CreatedAt,
 		&i.LastLoginAt,
 		&i.UpdatedAt,
+		&i.CurrentOrganizationID,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.TwoFactorFailures,
+		&i.TwoFactorLockedUntil,
+		&i.DeletedAt,
+		&i.IsAdmin,
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, email, name, created_at, last_login_at, updated_at FROM users WHERE email = $1
+SELECT id, email, name, created_at, last_login_at, updated_at, current_organization_id, totp_secret, totp_enabled_at, totp_last_step, two_factor_failures, two_factor_locked_until, deleted_at, is_admin FROM users WHERE email = $1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -59,12 +67,20 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.CreatedAt,
 		&i.LastLoginAt,
 		&i.UpdatedAt,
+		&i.CurrentOrganizationID,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.TwoFactorFailures,
+		&i.TwoFactorLockedUntil,
+		&i.DeletedAt,
+		&i.IsAdmin,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, email, name, created_at, last_login_at, updated_at FROM users WHERE id = $1
+SELECT id, email, name, created_at, last_login_at, updated_at, current_organization_id, totp_secret, totp_enabled_at, totp_last_step, two_factor_failures, two_factor_locked_until, deleted_at, is_admin FROM users WHERE id = $1
 `
 
 func (q *Queries) GetUserByID(ctx context.Context, id string) (User, error) {
@@ -77,6 +93,14 @@ func (q *Queries) GetUserByID(ctx context.Context, id string) (User, error) {
 		&i.CreatedAt,
 		&i.LastLoginAt,
 		&i.UpdatedAt,
+		&i.CurrentOrganizationID,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.TwoFactorFailures,
+		&i.TwoFactorLockedUntil,
+		&i.DeletedAt,
+		&i.IsAdmin,
 	)
 	return i, err
 }
@@ -94,7 +118,7 @@ const updateUserLastLogin = `-- name: UpdateUserLastLogin :one
 UPDATE users 
 SET last_login_at = NOW(), updated_at = NOW()
 WHERE id = $1
-RETURNING id, email, name, created_at, last_login_at, updated_at
+RETURNING id, email, name, created_at, last_login_at, updated_at, current_organization_id, totp_secret, totp_enabled_at, totp_last_step, two_factor_failures, two_factor_locked_until, deleted_at, is_admin
 `
 
 func (q *Queries) UpdateUserLastLogin(ctx context.Context, id string) (User, error) {
@@ -107,6 +131,14 @@ func (q *Queries) UpdateUserLastLogin(ctx context.Context, id string) (User, err
 		&i.CreatedAt,
 		&i.LastLoginAt,
 		&i.UpdatedAt,
+		&i.CurrentOrganizationID,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+		&i.TwoFactorFailures,
+		&i.TwoFactorLockedUntil,
+		&i.DeletedAt,
+		&i.IsAdmin,
 	)
 	return i, err
 }
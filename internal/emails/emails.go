@@ -0,0 +1,232 @@
+// Package emails renders the notification emails sent to users,
+// the HTML body with templ and a plain text body alongside it.
+package emails
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/a-h/templ"
+)
+
+// Email is a notification email
+type Email interface {
+	Subject() string
+	// Text is the plain text body
+	Text() string
+	// Body is the HTML content placed in the email layout
+	Body() templ.Component
+}
+
+// Footer ends every email
+type Footer struct {
+	// PreferencesURL links to the notification settings, empty for emails that can't be turned off
+	PreferencesURL string
+}
+
+// Rendered is an email ready to send
+type Rendered struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Render renders both bodies of an email with its footer
+func Render(ctx context.Context, email Email, footer Footer) (Rendered, error) {
+	var html bytes.Buffer
+	if err := layout(email.Subject(), footer).Render(templ.WithChildren(ctx, email.Body()), &html); err != nil {
+		return Rendered{}, fmt.Errorf("failed to render email: %w", err)
+	}
+
+	text := strings.TrimRight(email.Text(), "\n") + "\n\n-- \nranx.cloud, managed n8n hosting\n"
+	if footer.PreferencesURL != "" {
+		text += "Choose which emails you get: " + footer.PreferencesURL + "\n"
+	}
+
+	return Rendered{Subject: email.Subject(), Text: text, HTML: html.String()}, nil
+}
+
+// formatTime formats times in emails, in UTC as recipients are anywhere
+func formatTime(t time.Time) string {
+	return t.UTC().Format("January 2, 2006 15:04 MST")
+}
+
+// InstanceReady tells the creator of an instance it finished starting
+type InstanceReady struct {
+	Subdomain    string
+	InstanceURL  string
+	DashboardURL string
+}
+
+func (e InstanceReady) Subject() string {
+	return fmt.Sprintf("Your n8n instance %s is ready", e.Subdomain)
+}
+
+func (e InstanceReady) Text() string {
+	return fmt.Sprintf("Your n8n instance %s is up and running at:\n\n%s\n\n"+
+		"Open the editor from your dashboard:\n\n%s\n", e.Subdomain, e.InstanceURL, e.DashboardURL)
+}
+
+func (e InstanceReady) Body() templ.Component {
+	return instanceReadyBody(e)
+}
+
+// InstanceDeleted tells the owners which instances were deleted and why
+type InstanceDeleted struct {
+	Subdomains []string
+	Reason     string // Completes "were deleted because ...", e.g. "your free trial ended"
+	// ActionURL and ActionLabel link to what gets the user going again, optional
+	ActionURL   string
+	ActionLabel string
+}
+
+func (e InstanceDeleted) Subject() string {
+	if len(e.Subdomains) == 1 {
+		return fmt.Sprintf("Your n8n instance %s was deleted", e.Subdomains[0])
+	}
+	return "Your n8n instances were deleted"
+}
+
+func (e InstanceDeleted) Text() string {
+	text := fmt.Sprintf("These n8n instances were deleted with their workflows and data because %s:\n\n", e.Reason)
+	for _, subdomain := range e.Subdomains {
+		text += "  - " + subdomain + "\n"
+	}
+	if e.ActionURL != "" {
+		text += fmt.Sprintf("\n%s:\n\n%s\n", e.ActionLabel, e.ActionURL)
+	}
+	return text
+}
+
+func (e InstanceDeleted) Body() templ.Component {
+	return instanceDeletedBody(e)
+}
+
+// TrialEnding warns the owners a day before the free trial ends
+type TrialEnding struct {
+	EndsAt       time.Time
+	SubscribeURL string
+}
+
+func (e TrialEnding) Subject() string {
+	return "Your ranx.cloud trial ends tomorrow"
+}
+
+func (e TrialEnding) Text() string {
+	return fmt.Sprintf("Your ranx.cloud free trial ends on %s.\n\n"+
+		"Subscribe to keep your n8n instances running:\n\n%s\n", formatTime(e.EndsAt), e.SubscribeURL)
+}
+
+func (e TrialEnding) Body() templ.Component {
+	return trialEndingBody(e)
+}
+
+// TrialEnded tells the owners the free trial ended and what happens to their instances
+type TrialEnded struct {
+	StopsAt      time.Time // When the grace period ends
+	Deletes      bool      // Instances are deleted rather than stopped
+	SubscribeURL string
+}
+
+func (e TrialEnded) Subject() string {
+	return "Your ranx.cloud trial has ended"
+}
+
+func (e TrialEnded) Text() string {
+	return fmt.Sprintf("Your ranx.cloud free trial has ended.\n\n"+
+		"Unless you subscribe, your n8n instances will be %s on %s.\n\n"+
+		"Subscribe here:\n\n%s\n", e.consequence(), formatTime(e.StopsAt), e.SubscribeURL)
+}
+
+func (e TrialEnded) Body() templ.Component {
+	return trialEndedBody(e)
+}
+
+func (e TrialEnded) consequence() string {
+	if e.Deletes {
+		return "deleted with their workflows and data"
+	}
+	return "stopped. Their workflows and data are kept, and they start again when you subscribe"
+}
+
+// PaymentFailed tells the owners a subscription payment didn't go through
+type PaymentFailed struct {
+	OrganizationName string
+	BillingURL       string
+}
+
+func (e PaymentFailed) Subject() string {
+	return "Your ranx.cloud payment failed"
+}
+
+func (e PaymentFailed) Text() string {
+	return fmt.Sprintf("We couldn't charge the payment method of %s.\n\n"+
+		"Your n8n instances keep running while we retry. Please update your payment details:\n\n%s\n",
+		e.OrganizationName, e.BillingURL)
+}
+
+func (e PaymentFailed) Body() templ.Component {
+	return paymentFailedBody(e)
+}
+
+// InstanceDown tells the owners an instance stopped answering
+type InstanceDown struct {
+	Subdomain string
+	Since     time.Time // The first failed check
+	Error     string    // What the last check saw
+	DetailURL string
+}
+
+func (e InstanceDown) Subject() string {
+	return fmt.Sprintf("Your n8n instance %s is down", e.Subdomain)
+}
+
+func (e InstanceDown) Text() string {
+	return fmt.Sprintf("Your n8n instance %s hasn't answered health checks since %s.\n\n"+
+		"Last error: %s\n\nWe'll let you know when it recovers. See its uptime here:\n\n%s\n",
+		e.Subdomain, formatTime(e.Since), e.Error, e.DetailURL)
+}
+
+func (e InstanceDown) Body() templ.Component {
+	return instanceDownBody(e)
+}
+
+// InstanceRecovered tells the owners an instance that was down answers again
+type InstanceRecovered struct {
+	Subdomain string
+	Downtime  time.Duration
+	DetailURL string
+}
+
+func (e InstanceRecovered) Subject() string {
+	return fmt.Sprintf("Your n8n instance %s is back up", e.Subdomain)
+}
+
+func (e InstanceRecovered) Text() string {
+	return fmt.Sprintf("Your n8n instance %s is back up after being down for %s.\n\n"+
+		"See its uptime here:\n\n%s\n", e.Subdomain, FormatDowntime(e.Downtime), e.DetailURL)
+}
+
+func (e InstanceRecovered) Body() templ.Component {
+	return instanceRecoveredBody(e)
+}
+
+// FormatDowntime formats how long an instance was down, to the minute
+func FormatDowntime(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Minute {
+		return "less than a minute"
+	}
+	hours, minutes := int(d.Hours()), int(d.Minutes())%60
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%d min", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%d h", hours)
+	default:
+		return fmt.Sprintf("%d h %d min", hours, minutes)
+	}
+}
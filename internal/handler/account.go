@@ -1,12 +1,18 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
 	"github.com/aliuygur/n8n-saas-api/internal/handler/components"
+	"github.com/aliuygur/n8n-saas-api/internal/services"
 	"github.com/samber/lo"
 )
 
@@ -24,8 +30,8 @@ func (h *Handler) Account(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get the user's subscription
-	sub, err := h.services.GetUserSubscription(ctx, user.UserID)
+	// Get the subscription of the current organization
+	sub, err := h.services.GetOrganizationSubscription(ctx, user.Membership.OrganizationID)
 	if err != nil {
 		l.Error("Failed to get subscription", slog.Any("error", err))
 		http.Error(w, "Failed to load subscription", http.StatusInternalServerError)
@@ -43,17 +49,52 @@ func (h *Handler) Account(w http.ResponseWriter, r *http.Request) {
 		trialEndsAt = sub.TrialEndsAt.Format(time.RFC3339)
 	}
 
-	// Generate upgrade checkout URL if user is on trial
+	// Generate upgrade checkout URL if the organization is on trial, only owners manage billing
 	upgradeCheckoutURL := ""
-	if sub.IsTrial() {
+	if sub.IsTrial() && user.Membership.Can(services.RoleOwner) {
 		var err error
-		upgradeCheckoutURL, err = h.services.CreateUpgradeCheckoutURL(ctx, user.UserID)
+		upgradeCheckoutURL, err = h.services.CreateUpgradeCheckoutURL(ctx, user.Membership)
 		if err != nil {
 			l.Error("Failed to create upgrade checkout URL", slog.Any("error", err))
 			// Continue without upgrade URL rather than failing the whole page
 		}
 	}
 
+	sessions, err := h.accountSessions(ctx, user)
+	if err != nil {
+		l.Error("Failed to list sessions", slog.Any("error", err))
+		http.Error(w, "Failed to load sessions", http.StatusInternalServerError)
+		return
+	}
+
+	apiTokens, err := h.accountAPITokens(ctx, user.Membership)
+	if err != nil {
+		l.Error("Failed to list API tokens", slog.Any("error", err))
+		http.Error(w, "Failed to load API tokens", http.StatusInternalServerError)
+		return
+	}
+
+	twoFactor, err := h.accountTwoFactor(ctx, user)
+	if err != nil {
+		l.Error("Failed to get two-factor status", slog.Any("error", err))
+		http.Error(w, "Failed to load two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	notifications, err := h.accountNotifications(ctx, user.UserID)
+	if err != nil {
+		l.Error("Failed to list notification preferences", slog.Any("error", err))
+		http.Error(w, "Failed to load email notifications", http.StatusInternalServerError)
+		return
+	}
+
+	audit, err := h.accountAudit(ctx, user, url.Values{})
+	if err != nil {
+		l.Error("Failed to list audit events", slog.Any("error", err))
+		http.Error(w, "Failed to load the audit log", http.StatusInternalServerError)
+		return
+	}
+
 	accountData := components.AccountData{
 		User: components.UserAccount{
 			ID:        userDetails.ID,
@@ -74,7 +115,240 @@ func (h *Handler) Account(w http.ResponseWriter, r *http.Request) {
 			Quantity:       sub.Quantity,
 		},
 		UpgradeCheckoutURL: upgradeCheckoutURL,
+		Sessions:           sessions,
+		APITokens:          apiTokens,
+		TwoFactor:          twoFactor,
+		Notifications:      notifications,
+		Delete:             components.AccountDeleteData{TwoFactorEnabled: twoFactor.Enabled},
+		Audit:              audit,
 	}
 
 	lo.Must0(components.AccountPage(accountData).Render(ctx, w))
 }
+
+// RevokeSession logs out one of the user's sessions and re-renders the sessions list
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+	sessionID := r.PathValue("id")
+
+	message := ""
+	if err := h.services.RevokeSession(ctx, user.UserID, sessionID); err != nil {
+		if !apperrs.CodeIs(err, apperrs.CodeNotFound) {
+			l.Error("Failed to revoke session", slog.Any("error", err))
+			message = "Failed to revoke the session, please try again"
+		}
+		// A session that is already gone needs nothing more
+	} else {
+		l.Info("Session revoked", slog.String("user_id", user.UserID), slog.String("session_id", sessionID))
+	}
+
+	if sessionID == user.SessionID && message == "" {
+		clearSessionCookie(w)
+		w.Header().Set("HX-Redirect", "/login")
+		return
+	}
+
+	sessions, err := h.accountSessions(ctx, user)
+	if err != nil {
+		l.Error("Failed to list sessions", slog.Any("error", err))
+		http.Error(w, "Failed to load sessions", http.StatusInternalServerError)
+		return
+	}
+
+	lo.Must0(components.AccountSessions(sessions, message).Render(ctx, w))
+}
+
+// RevokeAllSessions logs the user out on every device
+func (h *Handler) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	if err := h.services.RevokeAllSessions(ctx, user.UserID); err != nil {
+		l.Error("Failed to revoke sessions", slog.Any("error", err))
+		http.Error(w, "Failed to log out everywhere", http.StatusInternalServerError)
+		return
+	}
+
+	l.Info("All sessions revoked", slog.String("user_id", user.UserID))
+
+	clearSessionCookie(w)
+	w.Header().Set("HX-Redirect", "/login")
+}
+
+// accountSessions lists the active sessions of the user for the account page
+func (h *Handler) accountSessions(ctx context.Context, user *AuthUser) ([]components.AccountSession, error) {
+	sessions, err := h.services.ListUserSessions(ctx, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]components.AccountSession, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, components.AccountSession{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			CreatedAt:  session.CreatedAt.Format(time.RFC3339),
+			LastSeenAt: session.LastSeenAt.Format(time.RFC3339),
+			Current:    session.ID == user.SessionID,
+		})
+	}
+	return result, nil
+}
+
+// CreateAPIToken creates a personal access token and shows its secret once
+func (h *Handler) CreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+
+	expiresInDays, _ := strconv.Atoi(r.FormValue("expires_in_days"))
+
+	secret, token, createErr := h.services.CreateAPIToken(ctx, services.CreateAPITokenParams{
+		Member:    user.Membership,
+		Name:      r.FormValue("name"),
+		Scopes:    r.Form["scopes"],
+		ExpiresIn: time.Duration(max(expiresInDays, 0)) * 24 * time.Hour,
+	})
+	if createErr != nil {
+		l.Error("Failed to create API token", slog.Any("error", createErr))
+	} else {
+		l.Info("API token created", slog.String("user_id", user.UserID), slog.String("api_token_id", token.ID))
+	}
+
+	data, err := h.accountAPITokens(ctx, user.Membership)
+	if err != nil {
+		l.Error("Failed to list API tokens", slog.Any("error", err))
+		http.Error(w, "Failed to load API tokens", http.StatusInternalServerError)
+		return
+	}
+	if createErr != nil {
+		data.Message, data.IsError = createErr.Error(), true
+	} else {
+		data.NewToken = secret
+	}
+
+	lo.Must0(components.AccountAPITokens(data).Render(ctx, w))
+}
+
+// RevokeAPIToken deletes a personal access token and re-renders the token list
+func (h *Handler) RevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+	tokenID := r.PathValue("id")
+
+	revokeErr := h.services.RevokeAPIToken(ctx, user.UserID, tokenID)
+	if revokeErr != nil && !apperrs.CodeIs(revokeErr, apperrs.CodeNotFound) {
+		l.Error("Failed to revoke API token", slog.Any("error", revokeErr))
+	} else {
+		l.Info("API token revoked", slog.String("user_id", user.UserID), slog.String("api_token_id", tokenID))
+	}
+
+	data, err := h.accountAPITokens(ctx, user.Membership)
+	if err != nil {
+		l.Error("Failed to list API tokens", slog.Any("error", err))
+		http.Error(w, "Failed to load API tokens", http.StatusInternalServerError)
+		return
+	}
+	if revokeErr != nil && !apperrs.CodeIs(revokeErr, apperrs.CodeNotFound) {
+		data.Message, data.IsError = "Failed to revoke the token, please try again", true
+	}
+
+	lo.Must0(components.AccountAPITokens(data).Render(ctx, w))
+}
+
+// accountAPITokens lists the API tokens the user created in their current organization for the account page
+func (h *Handler) accountAPITokens(ctx context.Context, member services.Membership) (components.AccountAPITokensData, error) {
+	tokens, err := h.services.ListAPITokens(ctx, member)
+	if err != nil {
+		return components.AccountAPITokensData{}, err
+	}
+
+	data := components.AccountAPITokensData{
+		Tokens: make([]components.AccountAPIToken, 0, len(tokens)),
+		Scopes: services.APITokenScopes,
+	}
+	for _, token := range tokens {
+		view := components.AccountAPIToken{
+			ID:          token.ID,
+			Name:        token.Name,
+			TokenPrefix: token.TokenPrefix,
+			Scopes:      token.Scopes,
+			CreatedAt:   token.CreatedAt.Format(time.RFC3339),
+		}
+		if token.LastUsedAt != nil {
+			view.LastUsedAt = token.LastUsedAt.Format(time.RFC3339)
+		}
+		if token.ExpiresAt != nil {
+			view.ExpiresAt = token.ExpiresAt.Format(time.RFC3339)
+		}
+		data.Tokens = append(data.Tokens, view)
+	}
+	return data, nil
+}
+
+// ExportAccount downloads everything stored about the user as JSON
+func (h *Handler) ExportAccount(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	export, err := h.services.ExportAccount(ctx, user.UserID)
+	if err != nil {
+		l.Error("Failed to export account", slog.Any("error", err))
+		http.Error(w, "Failed to export your data", http.StatusInternalServerError)
+		return
+	}
+
+	l.Info("Account exported", slog.String("user_id", user.UserID))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="ranx-account-`+export.ExportedAt.Format("20060102")+`.json"`)
+	w.Header().Set("Cache-Control", "no-store")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(export); err != nil {
+		l.Error("Failed to write account export", slog.Any("error", err))
+	}
+}
+
+// DeleteAccount deletes the user's account and logs them out, errors re-render the card via HTMX
+func (h *Handler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	err := h.services.DeleteAccount(ctx, services.DeleteAccountParams{
+		UserID: user.UserID,
+		Email:  r.FormValue("email"),
+		Code:   r.FormValue("code"),
+	})
+	if err != nil {
+		l.Error("Failed to delete account", slog.Any("error", err))
+		twoFactor, statusErr := h.services.GetTwoFactorStatus(ctx, user.UserID)
+		if statusErr != nil {
+			l.Error("Failed to get two-factor status", slog.Any("error", statusErr))
+			http.Error(w, "Failed to delete your account", http.StatusInternalServerError)
+			return
+		}
+		lo.Must0(components.AccountDelete(components.AccountDeleteData{
+			TwoFactorEnabled: twoFactor.Enabled,
+			Message:          err.Error(),
+		}).Render(ctx, w))
+		return
+	}
+
+	l.Info("Account deleted", slog.String("user_id", user.UserID))
+
+	clearSessionCookie(w)
+	w.Header().Set("HX-Redirect", "/")
+}
@@ -0,0 +1,567 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/handler/components"
+	"github.com/aliuygur/n8n-saas-api/internal/services"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/samber/lo"
+)
+
+const (
+	// impersonationCookieName holds the signed token of an admin viewing the dashboard as a user
+	impersonationCookieName = "impersonation"
+	// impersonationTTL limits how long an admin views the dashboard as a user
+	impersonationTTL = time.Hour
+	// impersonationPurpose marks impersonation tokens so they can't be mixed up with other signed tokens
+	impersonationPurpose = "impersonation"
+)
+
+// impersonationClaims let an admin session view the dashboard as a user
+type impersonationClaims struct {
+	SessionID string `json:"session_id"` // The admin's session, impersonation ends with it
+	UserID    string `json:"user_id"`
+	Purpose   string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+func (h *Handler) signImpersonationToken(sessionID, userID string) (string, error) {
+	now := time.Now()
+	claims := &impersonationClaims{
+		SessionID: sessionID,
+		UserID:    userID,
+		Purpose:   impersonationPurpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(impersonationTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    "ranx.cloud",
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(h.jwtSecret)
+}
+
+// impersonatedUser returns the user an admin views the dashboard as, or nil when they aren't
+func (h *Handler) impersonatedUser(r *http.Request, admin *AuthUser) *AuthUser {
+	cookie, err := r.Cookie(impersonationCookieName)
+	if err != nil {
+		return nil
+	}
+
+	l := appctx.GetLogger(r.Context())
+
+	token, err := jwt.ParseWithClaims(cookie.Value, &impersonationClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return h.jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		l.Debug("Ignoring invalid impersonation token", slog.Any("error", err))
+		return nil
+	}
+	claims, ok := token.Claims.(*impersonationClaims)
+	if !ok || !token.Valid || claims.Purpose != impersonationPurpose || claims.SessionID != admin.SessionID {
+		return nil
+	}
+
+	session, err := h.users.GetImpersonatedSession(r.Context(), claims.UserID)
+	if err != nil {
+		l.Error("Failed to load impersonated user", slog.String("user_id", claims.UserID), slog.Any("error", err))
+		return nil
+	}
+
+	return &AuthUser{
+		UserID:         session.UserID,
+		Email:          session.UserEmail,
+		Membership:     session.Membership,
+		SessionID:      admin.SessionID,
+		ImpersonatorID: admin.UserID,
+	}
+}
+
+// impersonationAllowed reports whether a request may be served while an admin views the dashboard
+// as a user. Only reads are, and not the ones that hand out the user's instances or data.
+func impersonationAllowed(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	path := r.URL.Path
+	return !strings.HasSuffix(path, "/sso") && !strings.HasSuffix(path, "/editor") && path != "/account/export"
+}
+
+// withImpersonation marks the pages rendered for an impersonated user, so the layout shows a banner
+func withImpersonation(ctx context.Context, user *AuthUser) context.Context {
+	if user.ImpersonatorID == "" {
+		return ctx
+	}
+	return components.WithImpersonation(ctx, user.Email)
+}
+
+func clearImpersonationCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     impersonationCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// adminTabs are the sections of the admin console
+var adminTabs = []string{"users", "subscriptions", "instances", "failures", "emails", "limits"}
+
+// AdminPage renders the admin console
+func (h *Handler) AdminPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	admin := MustGetUser(ctx)
+
+	data := components.AdminData{
+		Tab:    r.URL.Query().Get("tab"),
+		Search: strings.TrimSpace(r.URL.Query().Get("q")),
+	}
+	if !slices.Contains(adminTabs, data.Tab) {
+		data.Tab = adminTabs[0]
+	}
+	if impersonated := h.impersonatedUser(r, admin); impersonated != nil {
+		data.Impersonating = impersonated.Email
+	}
+
+	var err error
+	switch data.Tab {
+	case "users":
+		data.Users, err = h.adminUsers(ctx, admin, data.Search)
+	case "subscriptions":
+		data.Subscriptions, err = h.adminSubscriptions(ctx, data.Search)
+	case "instances":
+		data.Instances, err = h.adminInstances(ctx, data.Search)
+	case "failures":
+		data.Failures, err = h.adminFailures(ctx)
+	case "emails":
+		data.Emails, err = h.adminEmails(ctx, data.Search)
+	case "limits":
+		data.Limits, err = h.adminLimits(ctx)
+	}
+	if err != nil {
+		l.Error("Failed to load admin console", slog.String("tab", data.Tab), slog.Any("error", err))
+		http.Error(w, "Failed to load the admin console", http.StatusInternalServerError)
+		return
+	}
+
+	lo.Must0(components.AdminPage(data).Render(ctx, w))
+}
+
+func (h *Handler) adminUsers(ctx context.Context, admin *AuthUser, search string) ([]components.AdminUserRow, error) {
+	users, err := h.services.AdminListUsers(ctx, search)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]components.AdminUserRow, 0, len(users))
+	for _, user := range users {
+		row := components.AdminUserRow{
+			ID:        user.ID,
+			Email:     user.Email,
+			Name:      user.Name,
+			IsAdmin:   user.IsAdmin,
+			Deleted:   user.DeletedAt != nil,
+			Self:      user.ID == admin.UserID,
+			CreatedAt: user.CreatedAt.Format(time.RFC3339),
+
+			TwoFactorEnabled: user.TwoFactorEnabled,
+		}
+		if user.LastLoginAt != nil {
+			row.LastLoginAt = user.LastLoginAt.Format(time.RFC3339)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (h *Handler) adminSubscriptions(ctx context.Context, search string) ([]components.AdminSubscriptionRow, error) {
+	subscriptions, err := h.services.AdminListSubscriptions(ctx, search)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]components.AdminSubscriptionRow, 0, len(subscriptions))
+	for _, sub := range subscriptions {
+		row := components.AdminSubscriptionRow{
+			OrganizationID:   sub.OrganizationID,
+			OrganizationName: sub.OrganizationName,
+			UserEmail:        sub.UserEmail,
+			Status:           sub.Status,
+			SubscriptionID:   sub.SubscriptionID,
+			Quantity:         sub.Quantity,
+			CanExtendTrial:   sub.Status == services.SubscriptionStatusTrial || sub.IsExpiredFreeTrial(),
+			CreatedAt:        sub.CreatedAt.Format(time.RFC3339),
+		}
+		if sub.TrialEndsAt != nil {
+			row.TrialEndsAt = sub.TrialEndsAt.Format(time.RFC3339)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (h *Handler) adminInstances(ctx context.Context, search string) ([]components.AdminInstanceRow, error) {
+	instances, err := h.services.AdminListInstances(ctx, search)
+	if err != nil {
+		return nil, err
+	}
+	return toAdminInstanceRows(instances), nil
+}
+
+func toAdminInstanceRows(instances []services.AdminInstance) []components.AdminInstanceRow {
+	rows := make([]components.AdminInstanceRow, 0, len(instances))
+	for _, instance := range instances {
+		rows = append(rows, components.AdminInstanceRow{
+			ID:               instance.ID,
+			Subdomain:        instance.Subdomain,
+			URL:              instance.GetInstanceURL(),
+			Namespace:        instance.Namespace,
+			OrganizationName: instance.OrganizationName,
+			UserEmail:        instance.UserEmail,
+			Status:           instance.Status,
+			AppVersion:       instance.AppVersion,
+			OwnerSetup:       instance.OwnerSetupAt != nil,
+			Deleted:          instance.DeletedAt != nil,
+			CreatedAt:        instance.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return rows
+}
+
+func (h *Handler) adminEmails(ctx context.Context, search string) ([]components.AdminEmailRow, error) {
+	notifications, err := h.services.AdminListNotifications(ctx, search)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]components.AdminEmailRow, 0, len(notifications))
+	for _, n := range notifications {
+		row := components.AdminEmailRow{
+			Recipient: n.Recipient,
+			Kind:      n.Kind,
+			Subject:   n.Subject,
+			Status:    n.Status,
+			Error:     n.Error,
+			CreatedAt: n.CreatedAt.Format(time.RFC3339),
+		}
+		if n.SentAt != nil {
+			row.SentAt = n.SentAt.Format(time.RFC3339)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (h *Handler) adminFailures(ctx context.Context) (components.AdminFailuresData, error) {
+	failures, err := h.services.AdminProvisioningFailures(ctx)
+	if err != nil {
+		return components.AdminFailuresData{}, err
+	}
+
+	data := components.AdminFailuresData{Instances: toAdminInstanceRows(failures.Instances)}
+	for _, event := range failures.Events {
+		data.Events = append(data.Events, components.AdminFailureEvent{
+			Subdomain: event.Metadata["subdomain"],
+			Actor:     auditActorName(event),
+			Error:     event.Metadata["error"],
+			CreatedAt: event.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	for _, checkout := range failures.Checkouts {
+		data.Checkouts = append(data.Checkouts, components.AdminCheckoutRow{
+			UserEmail: checkout.UserEmail,
+			Subdomain: checkout.Subdomain,
+			Status:    checkout.Status,
+			CreatedAt: checkout.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return data, nil
+}
+
+func (h *Handler) adminLimits(ctx context.Context) (components.AdminLimitsData, error) {
+	plans, err := h.services.AdminListPlanLimits(ctx)
+	if err != nil {
+		return components.AdminLimitsData{}, err
+	}
+	overrides, err := h.services.AdminListInstanceLimitOverrides(ctx)
+	if err != nil {
+		return components.AdminLimitsData{}, err
+	}
+
+	var data components.AdminLimitsData
+	for _, plan := range plans {
+		data.Plans = append(data.Plans, components.AdminPlanLimitsRow{
+			Plan:                plan.Plan,
+			RequestsPerSecond:   plan.RequestsPerSecond,
+			Burst:               plan.Burst,
+			IPRequestsPerSecond: plan.IPRequestsPerSecond,
+			IPBurst:             plan.IPBurst,
+			MaxBodyBytes:        plan.MaxBodyBytes,
+			MaxConcurrent:       plan.MaxConcurrent,
+		})
+	}
+	for _, override := range overrides {
+		data.Overrides = append(data.Overrides, components.AdminLimitOverrideRow{
+			InstanceID:          override.InstanceID,
+			Subdomain:           override.Subdomain,
+			RequestsPerSecond:   formatLimit(override.RequestsPerSecond),
+			Burst:               formatLimit(override.Burst),
+			IPRequestsPerSecond: formatLimit(override.IPRequestsPerSecond),
+			IPBurst:             formatLimit(override.IPBurst),
+			MaxBodyBytes:        formatLimit(override.MaxBodyBytes),
+			MaxConcurrent:       formatLimit(override.MaxConcurrent),
+		})
+	}
+	return data, nil
+}
+
+// formatLimit renders an override field, empty when the plan limit applies
+func formatLimit[T int | int64](v *T) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatInt(int64(*v), 10)
+}
+
+// parseLimit reads an optional limit field of a form, nil when it is empty
+func parseLimit[T int | int64](r *http.Request, name string) (*T, error) {
+	value := strings.TrimSpace(r.FormValue(name))
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a number", strings.ReplaceAll(name, "_", " "))
+	}
+	v := T(n)
+	return &v, nil
+}
+
+// parseLimitOverride reads the limit fields of the admin console forms
+func parseLimitOverride(r *http.Request) (services.LimitOverride, error) {
+	var override services.LimitOverride
+	var err error
+	if override.RequestsPerSecond, err = parseLimit[int](r, "requests_per_second"); err != nil {
+		return override, err
+	}
+	if override.Burst, err = parseLimit[int](r, "burst"); err != nil {
+		return override, err
+	}
+	if override.IPRequestsPerSecond, err = parseLimit[int](r, "ip_requests_per_second"); err != nil {
+		return override, err
+	}
+	if override.IPBurst, err = parseLimit[int](r, "ip_burst"); err != nil {
+		return override, err
+	}
+	if override.MaxBodyBytes, err = parseLimit[int64](r, "max_body_bytes"); err != nil {
+		return override, err
+	}
+	if override.MaxConcurrent, err = parseLimit[int](r, "max_concurrent"); err != nil {
+		return override, err
+	}
+	return override, nil
+}
+
+// AdminImpersonate starts viewing the dashboard as a user via HTMX
+func (h *Handler) AdminImpersonate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	admin := MustGetUser(ctx)
+	userID := r.PathValue("userID")
+
+	if _, err := h.services.StartImpersonation(ctx, admin.UserID, userID); err != nil {
+		l.Error("Failed to start impersonation", slog.String("user_id", userID), slog.Any("error", err))
+		lo.Must0(components.AdminNotice(err.Error(), true).Render(ctx, w))
+		return
+	}
+
+	token, err := h.signImpersonationToken(admin.SessionID, userID)
+	if err != nil {
+		l.Error("Failed to sign impersonation token", slog.Any("error", err))
+		lo.Must0(components.AdminNotice("Failed to start viewing as this user", true).Render(ctx, w))
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     impersonationCookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(impersonationTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	l.Info("Admin started impersonation", slog.String("admin_id", admin.UserID), slog.String("user_id", userID))
+	w.Header().Set("HX-Redirect", "/dashboard")
+}
+
+// AdminStopImpersonation goes back to the admin's own account via HTMX
+func (h *Handler) AdminStopImpersonation(w http.ResponseWriter, r *http.Request) {
+	clearImpersonationCookie(w)
+	w.Header().Set("HX-Redirect", "/admin")
+}
+
+// AdminResetTwoFactor turns off two-factor authentication of a user who lost their authenticator via HTMX
+func (h *Handler) AdminResetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	admin := MustGetUser(ctx)
+	userID := r.PathValue("userID")
+
+	if err := h.services.AdminResetTwoFactor(ctx, admin.UserID, userID); err != nil {
+		l.Error("Failed to reset two-factor authentication", slog.String("user_id", userID), slog.Any("error", err))
+		lo.Must0(components.AdminNotice(err.Error(), true).Render(ctx, w))
+		return
+	}
+
+	l.Info("Admin reset two-factor authentication", slog.String("admin_id", admin.UserID), slog.String("user_id", userID))
+	lo.Must0(components.AdminNotice("Two-factor authentication turned off, the user can set it up again", false).Render(ctx, w))
+}
+
+// AdminExtendTrial extends the free trial of an organization via HTMX
+func (h *Handler) AdminExtendTrial(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	admin := MustGetUser(ctx)
+	organizationID := r.PathValue("organizationID")
+
+	days, err := strconv.Atoi(r.FormValue("days"))
+	if err != nil {
+		lo.Must0(components.AdminNotice("Enter the number of days", true).Render(ctx, w))
+		return
+	}
+
+	sub, err := h.services.AdminExtendTrial(ctx, admin.UserID, organizationID, days)
+	if err != nil {
+		l.Error("Failed to extend trial", slog.String("organization_id", organizationID), slog.Any("error", err))
+		lo.Must0(components.AdminNotice(err.Error(), true).Render(ctx, w))
+		return
+	}
+
+	message := fmt.Sprintf("Trial extended by %d days, it now ends %s", days, sub.TrialEndsAt.UTC().Format("January 2, 2006 15:04 MST"))
+	lo.Must0(components.AdminNotice(message, false).Render(ctx, w))
+}
+
+// AdminRestartInstance restarts a customer's instance via HTMX
+func (h *Handler) AdminRestartInstance(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	admin := MustGetUser(ctx)
+	instanceID := r.PathValue("id")
+
+	if err := h.services.AdminRestartInstance(ctx, admin.UserID, instanceID); err != nil {
+		l.Error("Failed to restart instance", slog.String("instance_id", instanceID), slog.Any("error", err))
+		lo.Must0(components.AdminNotice(err.Error(), true).Render(ctx, w))
+		return
+	}
+
+	lo.Must0(components.AdminNotice("Instance is restarting", false).Render(ctx, w))
+}
+
+// AdminDeleteInstance force-deletes a customer's instance via HTMX
+func (h *Handler) AdminDeleteInstance(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	admin := MustGetUser(ctx)
+	instanceID := r.PathValue("id")
+
+	if err := h.services.AdminDeleteInstance(ctx, admin.UserID, instanceID); err != nil {
+		l.Error("Failed to delete instance", slog.String("instance_id", instanceID), slog.Any("error", err))
+		lo.Must0(components.AdminNotice(err.Error(), true).Render(ctx, w))
+		return
+	}
+
+	l.Info("Admin deleted instance", slog.String("admin_id", admin.UserID), slog.String("instance_id", instanceID))
+	lo.Must0(components.AdminNotice("Instance deleted", false).Render(ctx, w))
+}
+
+// AdminUpdatePlanLimits changes the proxy limits of a plan via HTMX
+func (h *Handler) AdminUpdatePlanLimits(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	admin := MustGetUser(ctx)
+	plan := r.PathValue("plan")
+
+	fields, err := parseLimitOverride(r)
+	if err == nil && (fields.RequestsPerSecond == nil || fields.Burst == nil || fields.IPRequestsPerSecond == nil ||
+		fields.IPBurst == nil || fields.MaxBodyBytes == nil || fields.MaxConcurrent == nil) {
+		err = errors.New("every limit of a plan is required")
+	}
+	if err != nil {
+		lo.Must0(components.AdminNotice(err.Error(), true).Render(ctx, w))
+		return
+	}
+
+	err = h.services.AdminUpdatePlanLimits(ctx, admin.UserID, services.ProxyLimits{
+		Plan:                plan,
+		RequestsPerSecond:   *fields.RequestsPerSecond,
+		Burst:               *fields.Burst,
+		IPRequestsPerSecond: *fields.IPRequestsPerSecond,
+		IPBurst:             *fields.IPBurst,
+		MaxBodyBytes:        *fields.MaxBodyBytes,
+		MaxConcurrent:       *fields.MaxConcurrent,
+	})
+	if err != nil {
+		l.Error("Failed to update plan limits", slog.String("plan", plan), slog.Any("error", err))
+		lo.Must0(components.AdminNotice(err.Error(), true).Render(ctx, w))
+		return
+	}
+
+	l.Info("Admin updated plan limits", slog.String("admin_id", admin.UserID), slog.String("plan", plan))
+	lo.Must0(components.AdminNotice("Limits of the "+plan+" plan saved", false).Render(ctx, w))
+}
+
+// AdminSetInstanceLimitOverride overrides the proxy limits of an instance via HTMX
+func (h *Handler) AdminSetInstanceLimitOverride(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	admin := MustGetUser(ctx)
+	subdomain := strings.TrimSpace(r.FormValue("subdomain"))
+
+	override, err := parseLimitOverride(r)
+	if err != nil {
+		lo.Must0(components.AdminNotice(err.Error(), true).Render(ctx, w))
+		return
+	}
+
+	instance, err := h.services.AdminSetInstanceLimitOverride(ctx, admin.UserID, subdomain, override)
+	if err != nil {
+		l.Error("Failed to set instance limit override", slog.String("subdomain", subdomain), slog.Any("error", err))
+		lo.Must0(components.AdminNotice(err.Error(), true).Render(ctx, w))
+		return
+	}
+
+	l.Info("Admin set instance limit override", slog.String("admin_id", admin.UserID), slog.String("instance_id", instance.ID))
+	w.Header().Set("HX-Redirect", "/admin?tab=limits")
+}
+
+// AdminClearInstanceLimitOverride applies the plan limits to an instance again via HTMX
+func (h *Handler) AdminClearInstanceLimitOverride(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	admin := MustGetUser(ctx)
+	instanceID := r.PathValue("id")
+
+	if err := h.services.AdminClearInstanceLimitOverride(ctx, admin.UserID, instanceID); err != nil {
+		l.Error("Failed to clear instance limit override", slog.String("instance_id", instanceID), slog.Any("error", err))
+		lo.Must0(components.AdminNotice(err.Error(), true).Render(ctx, w))
+		return
+	}
+
+	l.Info("Admin cleared instance limit override", slog.String("admin_id", admin.UserID), slog.String("instance_id", instanceID))
+	w.Header().Set("HX-Redirect", "/admin?tab=limits")
+}
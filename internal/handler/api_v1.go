@@ -0,0 +1,375 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/services"
+)
+
+// maxAPIRequestBody limits the size of JSON request bodies
+const maxAPIRequestBody = 1 << 20
+
+// tokenStore authenticates API tokens, replaced in tests
+type tokenStore interface {
+	AuthenticateAPIToken(ctx context.Context, token string) (*services.APIToken, error)
+}
+
+// APIError is the body of every failed /api/v1 response
+type APIError struct {
+	Error APIErrorDetail `json:"error"`
+}
+
+// APIErrorDetail describes what went wrong
+type APIErrorDetail struct {
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// APIInstance is an n8n instance in API responses
+type APIInstance struct {
+	ID         string            `json:"id"`
+	Subdomain  string            `json:"subdomain"`
+	URL        string            `json:"url"`
+	Status     string            `json:"status"`
+	AppVersion string            `json:"app_version"`
+	Env        map[string]string `json:"env"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	DeployedAt *time.Time        `json:"deployed_at,omitempty"`
+}
+
+// APIInstanceList is the response of GET /api/v1/instances
+type APIInstanceList struct {
+	Instances []APIInstance `json:"instances"`
+}
+
+// APIInstanceLogs is the response of GET /api/v1/instances/{id}/logs
+type APIInstanceLogs struct {
+	Lines []string `json:"lines"`
+}
+
+// APICreateInstanceRequest is the body of POST /api/v1/instances
+type APICreateInstanceRequest struct {
+	Subdomain string `json:"subdomain"`
+}
+
+// APIUpdateInstanceRequest is the body of PUT /api/v1/instances/{id}, the desired configuration
+type APIUpdateInstanceRequest struct {
+	AppVersion string            `json:"app_version"`
+	Env        map[string]string `json:"env"`
+}
+
+// APISubscription is the subscription of the token owner
+type APISubscription struct {
+	Status      string     `json:"status"`
+	Trial       bool       `json:"trial"`
+	TrialEndsAt *time.Time `json:"trial_ends_at,omitempty"`
+	Quantity    int32      `json:"quantity"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// requireAPIToken authenticates /api/v1 requests with a bearer token granted scope.
+// Cookies are never accepted here, so the API is not exposed to CSRF.
+func (h *Handler) requireAPIToken(scope string, handlerFunc http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		secret, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || secret == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ranx.cloud"`)
+			writeAPIError(w, r, apperrs.Client(apperrs.CodeUnauthorized, "missing bearer token"))
+			return
+		}
+
+		token, err := h.tokens.AuthenticateAPIToken(ctx, strings.TrimSpace(secret))
+		if err != nil {
+			if apperrs.CodeIs(err, apperrs.CodeUnauthorized) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="ranx.cloud", error="invalid_token"`)
+			}
+			writeAPIError(w, r, err)
+			return
+		}
+
+		// Tokens must not get around the organization's two-factor policy, the user turns it on in the dashboard
+		if token.TwoFactorRequired {
+			writeAPIError(w, r, apperrs.Client(apperrs.CodeForbidden, "the organization requires two-factor authentication, turn it on in your account to use API tokens"))
+			return
+		}
+
+		if !token.HasScope(scope) {
+			writeAPIError(w, r, apperrs.Client(apperrs.CodeForbidden, "token is missing the "+scope+" scope"))
+			return
+		}
+
+		ctx = context.WithValue(ctx, userContextKey, &AuthUser{
+			UserID:     token.UserID,
+			Membership: token.Membership,
+			APITokenID: token.ID,
+		})
+		handlerFunc(w, r.WithContext(ctx))
+	}
+}
+
+// APIListInstances returns the instances of the token owner
+func (h *Handler) APIListInstances(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := MustGetUser(ctx)
+
+	instances, err := h.services.GetOrganizationInstances(ctx, user.Membership.OrganizationID)
+	if err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+
+	list := APIInstanceList{Instances: make([]APIInstance, 0, len(instances))}
+	for _, instance := range instances {
+		list.Instances = append(list.Instances, toAPIInstance(&instance))
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// APIGetInstance returns one instance of the token owner
+func (h *Handler) APIGetInstance(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := MustGetUser(ctx)
+
+	instance, err := h.services.GetMemberInstance(ctx, user.Membership, r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPIInstance(instance))
+}
+
+// APICreateInstance creates an instance, provisioning continues in the background
+func (h *Handler) APICreateInstance(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	var req APICreateInstanceRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+
+	instance, err := h.services.CreateInstance(ctx, services.CreateInstanceParams{
+		Member:    user.Membership,
+		Subdomain: req.Subdomain,
+	})
+	if err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+
+	l.Info("Instance created via API",
+		slog.String("instance_id", instance.ID),
+		slog.String("user_id", user.UserID),
+		slog.String("api_token_id", user.APITokenID))
+
+	w.Header().Set("Location", "/api/v1/instances/"+instance.ID)
+	writeJSON(w, http.StatusCreated, toAPIInstance(instance))
+}
+
+// APIUpdateInstance applies the desired configuration to an instance of the token owner
+func (h *Handler) APIUpdateInstance(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	var req APIUpdateInstanceRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+
+	instance, err := h.services.UpdateInstance(ctx, services.UpdateInstanceParams{
+		Member:     user.Membership,
+		InstanceID: r.PathValue("id"),
+		AppVersion: req.AppVersion,
+		Env:        req.Env,
+	})
+	if err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+
+	l.Info("Instance updated via API",
+		slog.String("instance_id", instance.ID),
+		slog.String("user_id", user.UserID),
+		slog.String("api_token_id", user.APITokenID),
+		slog.String("app_version", instance.AppVersion))
+
+	writeJSON(w, http.StatusOK, toAPIInstance(instance))
+}
+
+// APIDeleteInstance deletes an instance of the token owner
+func (h *Handler) APIDeleteInstance(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	// Report other organizations' instances as missing rather than forbidden
+	instance, err := h.services.GetMemberInstance(ctx, user.Membership, r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+
+	if err := h.services.DeleteInstance(ctx, services.DeleteInstanceParams{
+		Member:     user.Membership,
+		InstanceID: instance.ID,
+	}); err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+
+	l.Info("Instance deleted via API",
+		slog.String("instance_id", instance.ID),
+		slog.String("user_id", user.UserID),
+		slog.String("api_token_id", user.APITokenID))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// APIRestartInstance restarts the n8n pods of an instance
+func (h *Handler) APIRestartInstance(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+	instanceID := r.PathValue("id")
+
+	if err := h.services.RestartInstance(ctx, user.Membership, instanceID); err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+
+	l.Info("Instance restarted via API",
+		slog.String("instance_id", instanceID),
+		slog.String("user_id", user.UserID),
+		slog.String("api_token_id", user.APITokenID))
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// APIGetInstanceLogs returns the recent logs of an instance, oldest line first
+func (h *Handler) APIGetInstanceLogs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := MustGetUser(ctx)
+
+	lines, err := h.services.GetInstanceLogs(ctx, user.Membership, r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+	if lines == nil {
+		lines = []string{}
+	}
+	writeJSON(w, http.StatusOK, APIInstanceLogs{Lines: lines})
+}
+
+// APIGetSubscription returns the subscription of the token owner
+func (h *Handler) APIGetSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := MustGetUser(ctx)
+
+	sub, err := h.services.GetOrganizationSubscription(ctx, user.Membership.OrganizationID)
+	if err != nil {
+		writeAPIError(w, r, err)
+		return
+	}
+	if sub == nil {
+		writeAPIError(w, r, apperrs.Client(apperrs.CodeNotFound, "no subscription found"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APISubscription{
+		Status:      sub.Status,
+		Trial:       sub.IsTrial(),
+		TrialEndsAt: sub.TrialEndsAt,
+		Quantity:    sub.Quantity,
+		CreatedAt:   sub.CreatedAt,
+	})
+}
+
+func toAPIInstance(instance *services.Instance) APIInstance {
+	env := instance.Env
+	if env == nil {
+		env = map[string]string{}
+	}
+	return APIInstance{
+		ID:         instance.ID,
+		Subdomain:  instance.Subdomain,
+		URL:        instance.GetInstanceURL(),
+		Status:     instance.Status,
+		AppVersion: instance.AppVersion,
+		Env:        env,
+		CreatedAt:  instance.CreatedAt,
+		UpdatedAt:  instance.UpdatedAt,
+		DeployedAt: instance.DeployedAt,
+	}
+}
+
+// decodeJSON reads a JSON request body, rejecting unknown fields
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return apperrs.Client(apperrs.CodeInvalidInput, "invalid JSON body: "+err.Error())
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeAPIError writes err as an APIError. Only client errors expose their message.
+func writeAPIError(w http.ResponseWriter, r *http.Request, err error) {
+	var appErr *apperrs.Error
+	if !errors.As(err, &appErr) || appErr.Kind != apperrs.KindClient {
+		appctx.GetLogger(r.Context()).Error("API request failed", slog.Any("error", err))
+		writeJSON(w, http.StatusInternalServerError, APIError{Error: APIErrorDetail{
+			Code:    apperrs.CodeInternalError,
+			Message: "internal server error",
+		}})
+		return
+	}
+
+	detail := APIErrorDetail{Code: appErr.Code, Message: appErr.Msg}
+	if len(appErr.Meta) > 0 {
+		detail.Details = appErr.Meta
+	}
+	writeJSON(w, apiErrorStatus(appErr.Code), APIError{Error: detail})
+}
+
+// apiErrorStatus maps an apperrs code to its HTTP status
+func apiErrorStatus(code string) int {
+	switch code {
+	case apperrs.CodeInvalidInput, apperrs.CodeInvalidSubdomain:
+		return http.StatusBadRequest
+	case apperrs.CodeUnauthorized:
+		return http.StatusUnauthorized
+	case apperrs.CodeForbidden:
+		return http.StatusForbidden
+	case apperrs.CodeNotFound:
+		return http.StatusNotFound
+	case apperrs.CodeConflict:
+		return http.StatusConflict
+	case apperrs.CodeRateLimited:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusBadRequest
+	}
+}
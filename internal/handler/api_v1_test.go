@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/config"
+	"github.com/aliuygur/n8n-saas-api/internal/services"
+)
+
+type fakeTokenStore map[string]*services.APIToken
+
+func (f fakeTokenStore) AuthenticateAPIToken(_ context.Context, secret string) (*services.APIToken, error) {
+	token, ok := f[secret]
+	if !ok {
+		return nil, apperrs.Client(apperrs.CodeUnauthorized, "invalid API token")
+	}
+	return token, nil
+}
+
+func decodeAPIError(t *testing.T, w *httptest.ResponseRecorder) APIErrorDetail {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Expected JSON error body, got content type %q", ct)
+	}
+	var body APIError
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode error body: %v", err)
+	}
+	return body.Error
+}
+
+func TestRequireAPIToken(t *testing.T) {
+	h := &Handler{tokens: fakeTokenStore{
+		"ranx_read": {
+			ID:         "token-1",
+			UserID:     "user-1",
+			Membership: services.Membership{OrganizationID: "org-1", UserID: "user-1", Role: services.RoleViewer},
+			Scopes:     []string{services.ScopeInstancesRead},
+		},
+		"ranx_no_2fa": {
+			ID:                "token-2",
+			UserID:            "user-2",
+			Membership:        services.Membership{OrganizationID: "org-2", UserID: "user-2", Role: services.RoleOwner},
+			Scopes:            []string{services.ScopeInstancesRead},
+			TwoFactorRequired: true,
+		},
+	}}
+
+	var gotUser *AuthUser
+	protected := h.requireAPIToken(services.ScopeInstancesRead, func(w http.ResponseWriter, r *http.Request) {
+		gotUser = MustGetUser(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+	writeProtected := h.requireAPIToken(services.ScopeInstancesWrite, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name          string
+		handler       http.HandlerFunc
+		authorization string
+		wantStatus    int
+		wantCode      string
+	}{
+		{"missing token", protected, "", http.StatusUnauthorized, apperrs.CodeUnauthorized},
+		{"missing bearer prefix", protected, "ranx_read", http.StatusUnauthorized, apperrs.CodeUnauthorized},
+		{"unknown token", protected, "Bearer ranx_other", http.StatusUnauthorized, apperrs.CodeUnauthorized},
+		{"missing scope", writeProtected, "Bearer ranx_read", http.StatusForbidden, apperrs.CodeForbidden},
+		{"organization requires two-factor", protected, "Bearer ranx_no_2fa", http.StatusForbidden, apperrs.CodeForbidden},
+		{"valid token", protected, "Bearer ranx_read", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withTestLogger(httptest.NewRequest(http.MethodGet, "/api/v1/instances", nil))
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			tt.handler(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantCode != "" {
+				if got := decodeAPIError(t, w).Code; got != tt.wantCode {
+					t.Errorf("Expected error code %q, got %q", tt.wantCode, got)
+				}
+			}
+		})
+	}
+
+	if gotUser == nil || gotUser.UserID != "user-1" || gotUser.APITokenID != "token-1" {
+		t.Fatalf("Expected token owner in context, got %+v", gotUser)
+	}
+	if gotUser.Membership.OrganizationID != "org-1" || gotUser.Membership.Role != services.RoleViewer {
+		t.Errorf("Expected the token's organization membership in context, got %+v", gotUser.Membership)
+	}
+}
+
+func TestNewServesAPIRoutes(t *testing.T) {
+	svc := &services.Service{}
+	h, err := New(&config.Config{Routing: config.RoutingConfig{TenantDomain: "ranx.test"}}, svc)
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	if h.tokens != tokenStore(svc) || h.users != userStore(svc) || h.idempotency != idempotencyStore(svc) || h.audit != auditStore(svc) {
+		t.Fatal("Expected New to back the stores with the services")
+	}
+
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	server := h.HostRouter(mux)
+
+	t.Run("requests without a token are rejected before CSRF checks", func(t *testing.T) {
+		req := withTestLogger(httptest.NewRequest(http.MethodPost, "/api/v1/instances", strings.NewReader(`{}`)))
+		req.Host = "ranx.test"
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
+		}
+		if got := decodeAPIError(t, w).Code; got != apperrs.CodeUnauthorized {
+			t.Errorf("Expected error code %q, got %q", apperrs.CodeUnauthorized, got)
+		}
+		if w.Header().Get("WWW-Authenticate") == "" {
+			t.Error("Expected a WWW-Authenticate challenge")
+		}
+	})
+
+	t.Run("OpenAPI document", func(t *testing.T) {
+		req := withTestLogger(httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+		req.Host = "ranx.test"
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		var doc map[string]any
+		if err := json.NewDecoder(w.Body).Decode(&doc); err != nil || doc["paths"] == nil {
+			t.Errorf("Expected the OpenAPI document built by New, got %v", err)
+		}
+	})
+}
+
+func TestWriteAPIError(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+	}{
+		{
+			name:        "client error",
+			err:         apperrs.Client(apperrs.CodeConflict, "subdomain already taken"),
+			wantStatus:  http.StatusConflict,
+			wantCode:    apperrs.CodeConflict,
+			wantMessage: "subdomain already taken",
+		},
+		{
+			name:        "wrapped client error",
+			err:         errors.Join(errors.New("context"), apperrs.Client(apperrs.CodeNotFound, "instance not found")),
+			wantStatus:  http.StatusNotFound,
+			wantCode:    apperrs.CodeNotFound,
+			wantMessage: "instance not found",
+		},
+		{
+			name:        "server error hides details",
+			err:         apperrs.Server("failed to delete namespace", errors.New("dial tcp 10.0.0.1:443")),
+			wantStatus:  http.StatusInternalServerError,
+			wantCode:    apperrs.CodeInternalError,
+			wantMessage: "internal server error",
+		},
+		{
+			name:        "plain error",
+			err:         errors.New("boom"),
+			wantStatus:  http.StatusInternalServerError,
+			wantCode:    apperrs.CodeInternalError,
+			wantMessage: "internal server error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeAPIError(w, withTestLogger(httptest.NewRequest(http.MethodGet, "/api/v1/instances", nil)), tt.err)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			detail := decodeAPIError(t, w)
+			if detail.Code != tt.wantCode || detail.Message != tt.wantMessage {
+				t.Errorf("Expected %s %q, got %s %q", tt.wantCode, tt.wantMessage, detail.Code, detail.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/handler/components"
+	"github.com/aliuygur/n8n-saas-api/internal/services"
+	"github.com/samber/lo"
+)
+
+// auditStore is the part of the services the audit log depends on, replaced in tests
+type auditStore interface {
+	ListAuditEvents(ctx context.Context, params services.ListAuditEventsParams) ([]services.AuditEvent, error)
+	ListOrganizationMembers(ctx context.Context, member services.Membership) ([]services.OrganizationMember, error)
+}
+
+// AuditEvents re-renders the audit log card of the account page with new filters via HTMX
+func (h *Handler) AuditEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	data, err := h.accountAudit(ctx, user, r.URL.Query())
+	if err != nil {
+		l.Error("Failed to list audit events", slog.Any("error", err))
+		http.Error(w, "Failed to load the audit log", http.StatusInternalServerError)
+		return
+	}
+
+	lo.Must0(components.AccountAudit(data).Render(ctx, w))
+}
+
+// ExportAuditEvents downloads the filtered audit log as CSV or JSON
+func (h *Handler) ExportAuditEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	format := r.URL.Query().Get("format")
+	if format != "csv" && format != "json" {
+		http.Error(w, "format must be csv or json", http.StatusBadRequest)
+		return
+	}
+
+	params, _, err := h.auditFilter(ctx, user, r.URL.Query())
+	if err != nil {
+		l.Error("Failed to list organization members", slog.Any("error", err))
+		http.Error(w, "Failed to export the audit log", http.StatusInternalServerError)
+		return
+	}
+	params.Export = true
+
+	events, err := h.audit.ListAuditEvents(ctx, params)
+	if err != nil {
+		l.Error("Failed to list audit events", slog.Any("error", err))
+		http.Error(w, "Failed to export the audit log", http.StatusInternalServerError)
+		return
+	}
+
+	filename := "ranx-audit-" + time.Now().UTC().Format("20060102") + "." + format
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(auditExportRows(events)); err != nil {
+			l.Error("Failed to write audit export", slog.Any("error", err))
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	cw := csv.NewWriter(w)
+	lo.Must0(cw.Write([]string{
+		"created_at", "action", "actor_type", "actor_user_id", "actor_email", "organization_id",
+		"target_type", "target_id", "metadata", "ip_address", "user_agent", "request_id",
+	}))
+	for _, row := range auditExportRows(events) {
+		metadata, _ := json.Marshal(row.Metadata)
+		if err := cw.Write([]string{
+			row.CreatedAt.Format(time.RFC3339), row.Action, row.ActorType, row.ActorUserID, row.ActorEmail, row.OrganizationID,
+			row.TargetType, row.TargetID, string(metadata), row.IPAddress, row.UserAgent, row.RequestID,
+		}); err != nil {
+			l.Error("Failed to write audit export", slog.Any("error", err))
+			return
+		}
+	}
+	cw.Flush()
+}
+
+// auditExportRow is an audit event in an export
+type auditExportRow struct {
+	CreatedAt      time.Time         `json:"created_at"`
+	Action         string            `json:"action"`
+	ActorType      string            `json:"actor_type"`
+	ActorUserID    string            `json:"actor_user_id,omitempty"`
+	ActorEmail     string            `json:"actor_email,omitempty"`
+	OrganizationID string            `json:"organization_id,omitempty"`
+	TargetType     string            `json:"target_type"`
+	TargetID       string            `json:"target_id"`
+	Metadata       map[string]string `json:"metadata"`
+	IPAddress      string            `json:"ip_address"`
+	UserAgent      string            `json:"user_agent"`
+	RequestID      string            `json:"request_id"`
+}
+
+func auditExportRows(events []services.AuditEvent) []auditExportRow {
+	rows := make([]auditExportRow, 0, len(events))
+	for _, event := range events {
+		rows = append(rows, auditExportRow{
+			CreatedAt:      event.CreatedAt.UTC(),
+			Action:         event.Action,
+			ActorType:      event.ActorType,
+			ActorUserID:    event.ActorUserID,
+			ActorEmail:     event.ActorEmail,
+			OrganizationID: event.OrganizationID,
+			TargetType:     event.TargetType,
+			TargetID:       event.TargetID,
+			Metadata:       event.Metadata,
+			IPAddress:      event.IPAddress,
+			UserAgent:      event.UserAgent,
+			RequestID:      event.RequestID,
+		})
+	}
+	return rows
+}
+
+// accountAudit returns the audit log card for the filters in query
+func (h *Handler) accountAudit(ctx context.Context, user *AuthUser, query url.Values) (components.AccountAuditData, error) {
+	params, actors, err := h.auditFilter(ctx, user, query)
+	if err != nil {
+		return components.AccountAuditData{}, err
+	}
+
+	events, err := h.audit.ListAuditEvents(ctx, params)
+	if err != nil {
+		return components.AccountAuditData{}, err
+	}
+
+	data := components.AccountAuditData{
+		Categories:   services.AuditCategories,
+		Actors:       actors,
+		Category:     params.Category,
+		ActorID:      params.ActorID,
+		Days:         query.Get("days"),
+		Organization: user.Membership.Can(services.RoleAdmin),
+	}
+	if _, err := strconv.Atoi(data.Days); err != nil {
+		data.Days = ""
+	}
+	data.ExportQuery = url.Values{"category": {data.Category}, "actor": {data.ActorID}, "days": {data.Days}}.Encode()
+
+	for _, event := range events {
+		data.Events = append(data.Events, components.AccountAuditEvent{
+			Action:    event.Action,
+			Actor:     auditActorName(event),
+			Target:    auditTargetName(event),
+			Details:   auditDetails(event.Metadata),
+			IPAddress: event.IPAddress,
+			UserAgent: event.UserAgent,
+			CreatedAt: event.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return data, nil
+}
+
+// auditFilter reads the audit log filters from query. Unknown values are ignored, and members
+// who only see their own account can't filter by actor, so they get no list of actors.
+func (h *Handler) auditFilter(ctx context.Context, user *AuthUser, query url.Values) (services.ListAuditEventsParams, []components.AccountAuditActor, error) {
+	params := services.ListAuditEventsParams{Member: user.Membership}
+
+	if category := query.Get("category"); slices.Contains(services.AuditCategories, category) {
+		params.Category = category
+	}
+	if days, err := strconv.Atoi(query.Get("days")); err == nil && days > 0 {
+		params.Since = time.Now().AddDate(0, 0, -days)
+	}
+
+	if !user.Membership.Can(services.RoleAdmin) {
+		return params, nil, nil
+	}
+
+	members, err := h.audit.ListOrganizationMembers(ctx, user.Membership)
+	if err != nil {
+		return params, nil, err
+	}
+	actors := make([]components.AccountAuditActor, 0, len(members))
+	for _, member := range members {
+		actors = append(actors, components.AccountAuditActor{ID: member.UserID, Email: member.Email})
+		if member.UserID == query.Get("actor") {
+			params.ActorID = member.UserID
+		}
+	}
+	return params, actors, nil
+}
+
+func auditActorName(event services.AuditEvent) string {
+	switch event.ActorType {
+	case services.AuditActorUser:
+		return event.ActorEmail
+	case services.AuditActorLemonSqueezy:
+		return "Lemon Squeezy"
+	default:
+		return "ranx"
+	}
+}
+
+func auditTargetName(event services.AuditEvent) string {
+	if subdomain := event.Metadata["subdomain"]; subdomain != "" {
+		return subdomain
+	}
+	if event.TargetType == "user" {
+		return ""
+	}
+	return event.TargetType
+}
+
+// auditDetails lists the metadata of an event as key=value pairs
+func auditDetails(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for _, key := range slices.Sorted(maps.Keys(metadata)) {
+		if key == "subdomain" {
+			continue
+		}
+		pairs = append(pairs, key+"="+metadata[key])
+	}
+	return strings.Join(pairs, " · ")
+}
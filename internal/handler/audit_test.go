@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/services"
+)
+
+// fakeAuditStore returns events and members, remembering the last filters it was asked for
+type fakeAuditStore struct {
+	events  []services.AuditEvent
+	members []services.OrganizationMember
+	params  services.ListAuditEventsParams
+}
+
+func (s *fakeAuditStore) ListAuditEvents(ctx context.Context, params services.ListAuditEventsParams) ([]services.AuditEvent, error) {
+	s.params = params
+	return s.events, nil
+}
+
+func (s *fakeAuditStore) ListOrganizationMembers(ctx context.Context, member services.Membership) ([]services.OrganizationMember, error) {
+	return s.members, nil
+}
+
+func TestAuditFilter(t *testing.T) {
+	admin := &AuthUser{UserID: "user-1", Membership: services.Membership{OrganizationID: "org-1", UserID: "user-1", Role: services.RoleAdmin}}
+	viewer := &AuthUser{UserID: "user-2", Membership: services.Membership{OrganizationID: "org-1", UserID: "user-2", Role: services.RoleViewer}}
+
+	tests := []struct {
+		name         string
+		user         *AuthUser
+		query        url.Values
+		wantCategory string
+		wantActor    string
+		wantDays     int
+		wantActors   int
+	}{
+		{name: "no filters", user: admin, wantActors: 2},
+		{name: "category", user: admin, query: url.Values{"category": {"instance"}}, wantCategory: "instance", wantActors: 2},
+		{name: "unknown category", user: admin, query: url.Values{"category": {"secrets"}}, wantActors: 2},
+		{name: "days", user: admin, query: url.Values{"days": {"7"}}, wantDays: 7, wantActors: 2},
+		{name: "invalid days", user: admin, query: url.Values{"days": {"-3"}}, wantActors: 2},
+		{name: "actor in the organization", user: admin, query: url.Values{"actor": {"user-2"}}, wantActor: "user-2", wantActors: 2},
+		{name: "actor outside the organization", user: admin, query: url.Values{"actor": {"user-9"}}, wantActors: 2},
+		{name: "viewers can't filter by actor", user: viewer, query: url.Values{"actor": {"user-1"}, "category": {"user"}}, wantCategory: "user"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{audit: &fakeAuditStore{members: []services.OrganizationMember{
+				{UserID: "user-1", Email: "admin@example.com"},
+				{UserID: "user-2", Email: "viewer@example.com"},
+			}}}
+
+			params, actors, err := h.auditFilter(context.Background(), tt.user, tt.query)
+			if err != nil {
+				t.Fatalf("Failed to read filters: %v", err)
+			}
+			if params.Member != tt.user.Membership || params.Category != tt.wantCategory || params.ActorID != tt.wantActor {
+				t.Errorf("Unexpected filters %+v", params)
+			}
+			if tt.wantDays == 0 && !params.Since.IsZero() {
+				t.Errorf("Expected no start, got %v", params.Since)
+			}
+			if tt.wantDays > 0 {
+				want := time.Now().AddDate(0, 0, -tt.wantDays)
+				if d := params.Since.Sub(want); d < -time.Minute || d > time.Minute {
+					t.Errorf("Expected events since %v, got %v", want, params.Since)
+				}
+			}
+			if len(actors) != tt.wantActors {
+				t.Errorf("Expected %d actors, got %+v", tt.wantActors, actors)
+			}
+		})
+	}
+}
+
+func TestExportAuditEvents(t *testing.T) {
+	createdAt := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	events := []services.AuditEvent{{
+		OrganizationID: "org-1",
+		ActorType:      services.AuditActorUser,
+		ActorUserID:    "user-1",
+		ActorEmail:     "admin@example.com",
+		Action:         services.AuditActionInstanceCreate,
+		TargetType:     "instance",
+		TargetID:       "instance-1",
+		Metadata:       map[string]string{"subdomain": "acme"},
+		IPAddress:      "203.0.113.7",
+		UserAgent:      "Firefox, on Linux",
+		RequestID:      "request-1",
+		CreatedAt:      createdAt,
+	}}
+	user := &AuthUser{UserID: "user-1", Membership: services.Membership{OrganizationID: "org-1", UserID: "user-1", Role: services.RoleOwner}}
+
+	export := func(t *testing.T, query string) (*httptest.ResponseRecorder, *fakeAuditStore) {
+		t.Helper()
+		store := &fakeAuditStore{events: events}
+		h := &Handler{audit: store}
+		req := withTestLogger(httptest.NewRequest(http.MethodGet, "/account/audit/export?"+query, nil))
+		req = req.WithContext(context.WithValue(req.Context(), userContextKey, user))
+		w := httptest.NewRecorder()
+		h.ExportAuditEvents(w, req)
+		return w, store
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		w, store := export(t, "format=csv&category=instance")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if !store.params.Export || store.params.Category != "instance" {
+			t.Errorf("Expected an export with the filters, got %+v", store.params)
+		}
+		if got := w.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+			t.Errorf("Unexpected content type %q", got)
+		}
+		if got := w.Header().Get("Content-Disposition"); !strings.HasPrefix(got, `attachment; filename="ranx-audit-`) || !strings.HasSuffix(got, `.csv"`) {
+			t.Errorf("Unexpected content disposition %q", got)
+		}
+
+		records, err := csv.NewReader(w.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("Failed to read CSV: %v", err)
+		}
+		if len(records) != 2 {
+			t.Fatalf("Expected a header and one row, got %d records", len(records))
+		}
+		if strings.Join(records[0], ",") != "created_at,action,actor_type,actor_user_id,actor_email,organization_id,target_type,target_id,metadata,ip_address,user_agent,request_id" {
+			t.Errorf("Unexpected header %v", records[0])
+		}
+		want := []string{
+			"2026-03-01T12:30:00Z", services.AuditActionInstanceCreate, services.AuditActorUser, "user-1", "admin@example.com", "org-1",
+			"instance", "instance-1", `{"subdomain":"acme"}`, "203.0.113.7", "Firefox, on Linux", "request-1",
+		}
+		if strings.Join(records[1], "|") != strings.Join(want, "|") {
+			t.Errorf("Expected row %v, got %v", want, records[1])
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		w, _ := export(t, "format=json")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if got := w.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("Unexpected content type %q", got)
+		}
+
+		var rows []auditExportRow
+		if err := json.NewDecoder(w.Body).Decode(&rows); err != nil {
+			t.Fatalf("Failed to decode JSON: %v", err)
+		}
+		if len(rows) != 1 || rows[0].Action != services.AuditActionInstanceCreate || !rows[0].CreatedAt.Equal(createdAt) ||
+			rows[0].Metadata["subdomain"] != "acme" || rows[0].UserAgent != "Firefox, on Linux" {
+			t.Errorf("Unexpected rows %+v", rows)
+		}
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		w, store := export(t, "format=xml")
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+		if store.params.Member.UserID != "" {
+			t.Error("Expected no events to be listed")
+		}
+	})
+}
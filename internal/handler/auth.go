@@ -1,52 +1,106 @@
 package handler
 
 import (
+	"crypto/subtle"
 	"encoding/json"
-	"io"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
 	"github.com/aliuygur/n8n-saas-api/internal/handler/components"
 	"github.com/aliuygur/n8n-saas-api/internal/services"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/samber/lo"
+	"golang.org/x/oauth2"
 )
 
-// JWTClaims represents the JWT claims
-type JWTClaims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
-	jwt.RegisteredClaims
+// AuthUser is the logged in user of a request
+type AuthUser struct {
+	UserID     string
+	Email      string
+	Membership services.Membership // The organization the request acts in
+	SessionID  string              // Set for browser sessions
+	APITokenID string              // Set for /api/v1 requests
+	// TwoFactorRequired is set when the organization requires two-factor authentication the user hasn't turned on
+	TwoFactorRequired bool
+	IsAdmin           bool // The user operates ranx and may use the admin console
+	// ImpersonatorID is the admin viewing the dashboard as this user, requests are read-only then
+	ImpersonatorID string
 }
 
-// GoogleUserInfo represents the user info returned from Google
-type GoogleUserInfo struct {
-	ID      string `json:"id"`
-	Email   string `json:"email"`
-	Name    string `json:"name"`
-	Picture string `json:"picture"`
+const (
+	// sessionCookieName holds the token of the server-side session
+	sessionCookieName = "session"
+	// oauthStateCookieName holds the signed state of a login in progress
+	oauthStateCookieName = "oauth_state"
+	// oauthStateTTL defines how long the user has to finish logging in with a provider
+	oauthStateTTL = 10 * time.Minute
+	// oauthStatePurpose marks state tokens so they can't be mixed up with other signed tokens
+	oauthStatePurpose = "oauth_state"
+)
+
+// oauthStateClaims binds an OAuth login to the browser that started it
+type oauthStateClaims struct {
+	State    string `json:"state"`
+	Verifier string `json:"verifier"` // PKCE code verifier
+	Provider string `json:"provider"`
+	ReturnTo string `json:"return_to"`
+	Purpose  string `json:"purpose"`
+	jwt.RegisteredClaims
 }
 
 // Login renders the login page
 func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	returnTo := loginReturnPath(r.URL.Query().Get("return_to"))
+
 	// Check if user is authenticated via cookie
-	cookie, err := r.Cookie("jwt")
-	if err == nil && cookie.Value != "" {
-		// User is authenticated, redirect to dashboard
-		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+	if _, err := h.GetUserFromRequest(r); err == nil {
+		// User is authenticated, send them where they were going
+		http.Redirect(w, r, returnTo, http.StatusSeeOther)
 		return
 	}
 
-	lo.Must0(components.LoginPage().Render(r.Context(), w))
+	lo.Must0(components.LoginPage(h.loginPageData(returnTo, "")).Render(r.Context(), w))
+}
+
+// loginPageData lists the configured providers for the login page
+func (h *Handler) loginPageData(returnTo, errorMessage string) components.LoginPageData {
+	data := components.LoginPageData{ReturnTo: returnTo, Error: errorMessage}
+	for _, name := range []string{services.ProviderGoogle, services.ProviderGitHub, services.ProviderMicrosoft} {
+		if provider, ok := h.providers[name]; ok {
+			data.Providers = append(data.Providers, components.LoginProviderLink{
+				Name:        provider.name,
+				DisplayName: provider.displayName,
+			})
+		}
+	}
+	return data
 }
 
-// Logout logs out the user by clearing the JWT cookie
+// Logout revokes the current session and clears the session cookie
 func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
-	// Clear the JWT cookie
+	ctx := r.Context()
+
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		if err := h.users.RevokeSessionToken(ctx, cookie.Value); err != nil {
+			appctx.GetLogger(ctx).Error("Failed to revoke session", slog.Any("error", err))
+		}
+	}
+	clearSessionCookie(w)
+	clearImpersonationCookie(w)
+
+	// Redirect to home page
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// clearSessionCookie removes the session cookie from the browser
+func clearSessionCookie(w http.ResponseWriter) {
 	http.SetCookie(w, &http.Cookie{
-		Name:     "jwt",
+		Name:     sessionCookieName,
 		Value:    "",
 		Path:     "/",
 		MaxAge:   -1,
@@ -54,128 +108,282 @@ func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
 		Secure:   true,
 		SameSite: http.SameSiteLaxMode,
 	})
-
-	// Redirect to home page
-	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
-// HandleGoogleLogin redirects to Google OAuth
-func (h *Handler) HandleGoogleLogin(w http.ResponseWriter, r *http.Request) {
+// HandleOAuthLogin redirects to the login provider in the path
+func (h *Handler) HandleOAuthLogin(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	l := appctx.GetLogger(ctx)
 
-	l.Info("Initiating Google OAuth login")
-	// Generate a random state token for CSRF protection
-	state := lo.RandomString(32, lo.LettersCharset)
+	provider, ok := h.providers[r.PathValue("provider")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	l.Info("Initiating OAuth login", slog.String("provider", provider.name))
+
+	// The state and PKCE verifier live in a signed cookie so the callback
+	// only succeeds in the browser that started the login
+	now := time.Now()
+	claims := &oauthStateClaims{
+		State:    lo.RandomString(32, lo.AlphanumericCharset),
+		Verifier: oauth2.GenerateVerifier(),
+		Provider: provider.name,
+		ReturnTo: loginReturnPath(r.URL.Query().Get("return_to")),
+		Purpose:  oauthStatePurpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(oauthStateTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    "ranx.cloud",
+		},
+	}
+
+	stateToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(h.jwtSecret)
+	if err != nil {
+		l.Error("Failed to sign OAuth state", slog.Any("error", err))
+		http.Redirect(w, r, "/login?error=auth_failed", http.StatusSeeOther)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookieName,
+		Value:    stateToken,
+		Path:     "/auth/",
+		MaxAge:   int(oauthStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
 
-	// TODO: Store state in cache/session for validation in callback
-	authURL := h.oauth2Config.AuthCodeURL(state)
+	authURL := provider.oauth2Config.AuthCodeURL(claims.State, oauth2.S256ChallengeOption(claims.Verifier))
 
 	http.Redirect(w, r, authURL, http.StatusTemporaryRedirect)
 }
 
-// HandleGoogleCallback handles the OAuth callback from Google
-func (h *Handler) HandleGoogleCallback(w http.ResponseWriter, r *http.Request) {
-	code := r.URL.Query().Get("code")
+// HandleOAuthCallback handles the OAuth callback from the login provider in the path
+func (h *Handler) HandleOAuthCallback(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
 
-	if code == "" {
-		http.Redirect(w, r, "/login?error=no_code", http.StatusSeeOther)
+	// The state cookie is single use
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookieName,
+		Value:    "",
+		Path:     "/auth/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	provider, ok := h.providers[r.PathValue("provider")]
+	if !ok {
+		http.NotFound(w, r)
 		return
 	}
 
-	ctx := r.Context()
-	l := appctx.GetLogger(ctx)
+	state, err := h.validateOAuthState(r)
+	if err == nil && state.Provider != provider.name {
+		err = fmt.Errorf("state was issued for %q", state.Provider)
+	}
+	if err != nil {
+		l.Warn("Invalid OAuth state", slog.String("provider", provider.name), slog.Any("error", err))
+		http.Redirect(w, r, "/login?error=invalid_state", http.StatusSeeOther)
+		return
+	}
+
+	if errParam := r.URL.Query().Get("error"); errParam != "" {
+		l.Info("OAuth login was not completed", slog.String("provider", provider.name), slog.String("error", errParam))
+		http.Redirect(w, r, "/login?error=auth_cancelled", http.StatusSeeOther)
+		return
+	}
 
-	// TODO: Validate state token to prevent CSRF
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		http.Redirect(w, r, "/login?error=no_code", http.StatusSeeOther)
+		return
+	}
 
 	// Exchange code for token
-	token, err := h.oauth2Config.Exchange(ctx, code)
+	token, err := provider.oauth2Config.Exchange(ctx, code, oauth2.VerifierOption(state.Verifier))
 	if err != nil {
-		l.Error("Failed to exchange code", slog.Any("error", err))
+		l.Error("Failed to exchange code", slog.String("provider", provider.name), slog.Any("error", err))
 		http.Redirect(w, r, "/login?error=auth_failed", http.StatusSeeOther)
 		return
 	}
 
-	// Get user info from Google
-	client := h.oauth2Config.Client(ctx, token)
-	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
+	identity, err := provider.fetchIdentity(ctx, provider.oauth2Config.Client(ctx, token), provider.apiURL, token)
 	if err != nil {
-		l.Error("Failed to get user info", slog.Any("error", err))
+		l.Error("Failed to get user info", slog.String("provider", provider.name), slog.Any("error", err))
 		http.Redirect(w, r, "/login?error=auth_failed", http.StatusSeeOther)
 		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		l.Error("Failed to get user info", slog.Int("status", resp.StatusCode), slog.String("body", string(body)))
+	user, err := h.users.LoginWithIdentity(ctx, *identity)
+	if err != nil {
+		if apperrs.CodeIs(err, apperrs.CodeForbidden) {
+			l.Info("Login with unverified email rejected", slog.String("provider", provider.name))
+			http.Redirect(w, r, "/login?error=email_not_verified", http.StatusSeeOther)
+			return
+		}
+		l.Error("Failed to log in user", slog.String("provider", provider.name), slog.Any("error", err))
 		http.Redirect(w, r, "/login?error=auth_failed", http.StatusSeeOther)
 		return
 	}
 
-	var googleUser GoogleUserInfo
-	if err := json.NewDecoder(resp.Body).Decode(&googleUser); err != nil {
-		l.Error("Failed to decode user info", slog.Any("error", err))
-		http.Redirect(w, r, "/login?error=auth_failed", http.StatusSeeOther)
+	l.Info("User logged in via OAuth",
+		slog.String("provider", provider.name),
+		slog.String("user_id", user.ID),
+		slog.String("email", user.Email))
+
+	h.startSession(w, r, user, state.ReturnTo)
+}
+
+// HandleEmailLogin emails a login link to the address in the form
+func (h *Handler) HandleEmailLogin(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+
+	email := r.FormValue("email")
+	returnTo := loginReturnPath(r.FormValue("return_to"))
+
+	if err := h.users.SendMagicLink(ctx, email, returnTo); err != nil {
+		if apperrs.CodeIs(err, apperrs.CodeInvalidInput) || apperrs.CodeIs(err, apperrs.CodeRateLimited) {
+			status := http.StatusBadRequest
+			if apperrs.CodeIs(err, apperrs.CodeRateLimited) {
+				status = http.StatusTooManyRequests
+			}
+			w.WriteHeader(status)
+			lo.Must0(components.LoginPage(h.loginPageData(returnTo, err.Error())).Render(ctx, w))
+			return
+		}
+		l.Error("Failed to send login link", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		lo.Must0(components.LoginPage(h.loginPageData(returnTo, "Failed to send the login link, please try again")).Render(ctx, w))
 		return
 	}
 
-	user, err := h.services.GetOrCreateUser(ctx, services.CreateUserParams{
-		Email: googleUser.Email,
-		Name:  googleUser.Name,
-	})
+	// The same page is shown for every address, so it doesn't reveal who has an account
+	lo.Must0(components.MagicLinkSentPage(email).Render(ctx, w))
+}
+
+// HandleEmailCallback asks the user to confirm an emailed login.
+// Consuming the token needs a POST, so link scanners opening the email can't use it up.
+func (h *Handler) HandleEmailCallback(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Redirect(w, r, "/login?error=invalid_link", http.StatusSeeOther)
+		return
+	}
+
+	lo.Must0(components.MagicLinkConfirmPage(token).Render(r.Context(), w))
+}
+
+// HandleEmailCallbackConfirm logs the user in with an emailed token
+func (h *Handler) HandleEmailCallbackConfirm(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+
+	user, returnTo, err := h.users.ConsumeMagicLink(ctx, r.FormValue("token"))
 	if err != nil {
-		l.Error("Failed to get or create user", slog.Any("error", err))
+		if apperrs.CodeIs(err, apperrs.CodeUnauthorized) {
+			http.Redirect(w, r, "/login?error=invalid_link", http.StatusSeeOther)
+			return
+		}
+		l.Error("Failed to log in with email link", slog.Any("error", err))
 		http.Redirect(w, r, "/login?error=auth_failed", http.StatusSeeOther)
 		return
 	}
 
-	l.Info("User logged in via Google OAuth",
+	l.Info("User logged in via email link",
 		slog.String("user_id", user.ID),
 		slog.String("email", user.Email))
 
+	h.startSession(w, r, user, loginReturnPath(returnTo))
+}
+
+// startSession creates a session for a user who just logged in and redirects to returnTo.
+// Users with two-factor authentication enter a code first, the session cookie is only issued after that.
+func (h *Handler) startSession(w http.ResponseWriter, r *http.Request, user *services.User, returnTo string) {
+	if user.TwoFactorEnabled {
+		h.beginTwoFactorLogin(w, r, user.ID, returnTo)
+		return
+	}
+	h.issueSession(w, r, user.ID, returnTo)
+}
+
+// issueSession creates a session for a fully authenticated user and sets the session cookie
+func (h *Handler) issueSession(w http.ResponseWriter, r *http.Request, userID, returnTo string) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+
 	// Update last login
-	err = h.services.UpdateUserLastLogin(ctx, user.ID)
-	if err != nil {
+	if err := h.users.UpdateUserLastLogin(ctx, userID); err != nil {
 		l.Error("Failed to update last login", slog.Any("error", err))
 		// Don't fail the login, just log the error
 	}
 
-	// Create JWT token
-	expiresAt := time.Now().Add(7 * 24 * time.Hour) // 7 days
-	claims := &JWTClaims{
-		UserID: user.ID,
-		Email:  user.Email,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expiresAt),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    "ranx.cloud",
-		},
-	}
-
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := jwtToken.SignedString(h.jwtSecret)
+	token, session, err := h.users.CreateSession(ctx, services.CreateSessionParams{
+		UserID:    userID,
+		UserAgent: r.UserAgent(),
+		IPAddress: clientIP(r, h.trustedProxies),
+	})
 	if err != nil {
-		l.Error("Failed to create JWT token", slog.Any("error", err))
+		l.Error("Failed to create session", slog.Any("error", err))
 		http.Redirect(w, r, "/login?error=auth_failed", http.StatusSeeOther)
 		return
 	}
 
-	l.Info("JWT token created", slog.String("user_id", user.ID))
+	l.Info("Session created", slog.String("user_id", userID), slog.String("session_id", session.ID))
 
-	// Set JWT token in HTTP-only cookie
+	// The cookie lives as long as the session could, the server enforces the sliding expiry
 	http.SetCookie(w, &http.Cookie{
-		Name:     "jwt",
-		Value:    tokenString,
+		Name:     sessionCookieName,
+		Value:    token,
 		Path:     "/",
-		Expires:  expiresAt,
+		MaxAge:   int(services.SessionAbsoluteTTL.Seconds()),
 		HttpOnly: true,
 		Secure:   true,
 		SameSite: http.SameSiteLaxMode,
 	})
 
-	// Redirect to dashboard
-	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+	// Redirect to the page the user started from
+	http.Redirect(w, r, returnTo, http.StatusSeeOther)
+}
+
+// validateOAuthState checks the state returned by the provider against the signed state cookie
+func (h *Handler) validateOAuthState(r *http.Request) (*oauthStateClaims, error) {
+	cookie, err := r.Cookie(oauthStateCookieName)
+	if err != nil {
+		return nil, fmt.Errorf("missing state cookie: %w", err)
+	}
+
+	token, err := jwt.ParseWithClaims(cookie.Value, &oauthStateClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return h.jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*oauthStateClaims)
+	if !ok || !token.Valid || claims.Purpose != oauthStatePurpose {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+
+	if subtle.ConstantTimeCompare([]byte(claims.State), []byte(r.URL.Query().Get("state"))) != 1 {
+		return nil, errors.New("state mismatch")
+	}
+
+	return claims, nil
+}
+
+// loginReturnPath returns where to send the user after login, defaulting to the dashboard
+func loginReturnPath(path string) string {
+	if path == "" || safeReturnPath(path) != path {
+		return "/dashboard"
+	}
+	return path
 }
 
 // GetAuthMe returns the current user information
@@ -184,29 +392,47 @@ func (h *Handler) GetAuthMe(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]any{
-		"user_id": user.UserID,
-		"email":   user.Email,
+		"user_id":         user.UserID,
+		"email":           user.Email,
+		"organization_id": user.Membership.OrganizationID,
+		"role":            user.Membership.Role,
 	})
 }
 
-// GetUserFromRequest extracts and validates the JWT from the request
-func (h *Handler) GetUserFromRequest(r *http.Request) (*JWTClaims, error) {
-	cookie, err := r.Cookie("jwt")
+// GetUserFromRequest looks up the session of the request. Admins viewing the dashboard
+// as another user get that user, see impersonatedUser.
+func (h *Handler) GetUserFromRequest(r *http.Request) (*AuthUser, error) {
+	user, err := h.sessionUser(r)
 	if err != nil {
 		return nil, err
 	}
 
-	token, err := jwt.ParseWithClaims(cookie.Value, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return h.jwtSecret, nil
-	})
+	if user.IsAdmin {
+		if impersonated := h.impersonatedUser(r, user); impersonated != nil {
+			return impersonated, nil
+		}
+	}
+	return user, nil
+}
 
+// sessionUser returns the user who owns the session of the request
+func (h *Handler) sessionUser(r *http.Request) (*AuthUser, error) {
+	cookie, err := r.Cookie(sessionCookieName)
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
-		return claims, nil
+	session, err := h.users.ValidateSession(r.Context(), cookie.Value)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, jwt.ErrInvalidKey
+	return &AuthUser{
+		UserID:            session.UserID,
+		Email:             session.UserEmail,
+		Membership:        session.Membership,
+		SessionID:         session.ID,
+		TwoFactorRequired: session.TwoFactorRequired,
+		IsAdmin:           session.IsAdmin,
+	}, nil
 }
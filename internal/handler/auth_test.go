@@ -0,0 +1,479 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/config"
+	"github.com/aliuygur/n8n-saas-api/internal/services"
+	"golang.org/x/oauth2"
+)
+
+// fakeOAuthProvider is a minimal OAuth2 provider that checks PKCE like Google does
+type fakeOAuthProvider struct {
+	*httptest.Server
+	challenges    map[string]string // code -> code_challenge
+	emailVerified bool
+}
+
+func newFakeOAuthProvider(t *testing.T) *fakeOAuthProvider {
+	p := &fakeOAuthProvider{challenges: map[string]string{}, emailVerified: true}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		code := r.FormValue("code")
+		challenge, ok := p.challenges[code]
+		if !ok {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access-" + code,
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	})
+	mux.HandleFunc("GET /userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(GoogleUserInfo{ID: "1", Email: "ada@example.com", VerifiedEmail: p.emailVerified, Name: "Ada"})
+	})
+
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+// authorize plays the consent screen: it records the PKCE challenge and issues a code
+func (p *fakeOAuthProvider) authorize(t *testing.T, authURL string) (code, state string) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("Failed to parse auth URL: %v", err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		t.Fatalf("Expected a S256 PKCE challenge in %s", authURL)
+	}
+	code = "code-" + q.Get("state")[:8]
+	p.challenges[code] = q.Get("code_challenge")
+	return code, q.Get("state")
+}
+
+// fakeUserStore logs everyone in as the same user and keeps sessions in memory
+type fakeUserStore struct {
+	sessions      map[string]*services.Session // token -> session
+	twoFactorCode string                       // Turns on two-factor authentication for the user when set
+}
+
+func newFakeUserStore() *fakeUserStore {
+	return &fakeUserStore{sessions: map[string]*services.Session{}}
+}
+
+func (f *fakeUserStore) LoginWithIdentity(_ context.Context, identity services.LoginIdentity) (*services.User, error) {
+	if !identity.EmailVerified {
+		return nil, apperrs.Client(apperrs.CodeForbidden, "the email address of this account is not verified")
+	}
+	return &services.User{ID: "user-1", Email: identity.Email, Name: identity.Name, TwoFactorEnabled: f.twoFactorCode != ""}, nil
+}
+
+func (*fakeUserStore) UpdateUserLastLogin(context.Context, string) error {
+	return nil
+}
+
+func (*fakeUserStore) SendMagicLink(context.Context, string, string) error {
+	return nil
+}
+
+func (*fakeUserStore) ConsumeMagicLink(context.Context, string) (*services.User, string, error) {
+	return nil, "", apperrs.Client(apperrs.CodeUnauthorized, "login link is invalid or has expired")
+}
+
+func (f *fakeUserStore) CreateSession(_ context.Context, params services.CreateSessionParams) (string, *services.Session, error) {
+	token := fmt.Sprintf("token-%d", len(f.sessions)+1)
+	session := &services.Session{ID: "session-" + token, UserID: params.UserID, UserEmail: "ada@example.com"}
+	f.sessions[token] = session
+	return token, session, nil
+}
+
+func (f *fakeUserStore) ValidateSession(_ context.Context, token string) (*services.Session, error) {
+	session, ok := f.sessions[token]
+	if !ok {
+		return nil, apperrs.Client(apperrs.CodeUnauthorized, "session is invalid or has expired")
+	}
+	return session, nil
+}
+
+func (f *fakeUserStore) VerifyTwoFactorCode(_ context.Context, _ string, code string) error {
+	if code != f.twoFactorCode {
+		return apperrs.Client(apperrs.CodeUnauthorized, "the code is incorrect")
+	}
+	return nil
+}
+
+func (*fakeUserStore) GetImpersonatedSession(_ context.Context, userID string) (*services.Session, error) {
+	return &services.Session{UserID: userID, UserEmail: userID + "@example.com"}, nil
+}
+
+func (f *fakeUserStore) RevokeSessionToken(_ context.Context, token string) error {
+	delete(f.sessions, token)
+	return nil
+}
+
+func newAuthTestHandler(provider *fakeOAuthProvider) *Handler {
+	newProvider := func(name string) *loginProvider {
+		return &loginProvider{
+			name: name,
+			oauth2Config: &oauth2.Config{
+				ClientID:     "client",
+				ClientSecret: "secret",
+				RedirectURL:  "http://localhost/auth/" + name + "/callback",
+				Endpoint: oauth2.Endpoint{
+					AuthURL:  provider.URL + "/authorize",
+					TokenURL: provider.URL + "/token",
+				},
+			},
+			apiURL:        provider.URL + "/userinfo",
+			fetchIdentity: fetchGoogleIdentity,
+		}
+	}
+
+	return &Handler{
+		providers: map[string]*loginProvider{
+			services.ProviderGoogle: newProvider(services.ProviderGoogle),
+			services.ProviderGitHub: newProvider(services.ProviderGitHub),
+		},
+		jwtSecret: []byte("test-secret"),
+		config:    &config.Config{},
+		users:     newFakeUserStore(),
+	}
+}
+
+func withTestLogger(r *http.Request) *http.Request {
+	return r.WithContext(appctx.WithLogger(r.Context(), slog.New(slog.DiscardHandler)))
+}
+
+// startLogin runs HandleOAuthLogin for Google and returns the provider redirect and the state cookie
+func startLogin(t *testing.T, h *Handler, returnTo string) (string, *http.Cookie) {
+	req := withTestLogger(httptest.NewRequest(http.MethodGet, "/auth/google?return_to="+url.QueryEscape(returnTo), nil))
+	req.SetPathValue("provider", services.ProviderGoogle)
+	w := httptest.NewRecorder()
+	h.HandleOAuthLogin(w, req)
+
+	if w.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("Expected redirect to provider, got %d", w.Code)
+	}
+	for _, c := range w.Result().Cookies() {
+		if c.Name == oauthStateCookieName {
+			return w.Header().Get("Location"), c
+		}
+	}
+	t.Fatal("Expected state cookie to be set")
+	return "", nil
+}
+
+func callback(h *Handler, query url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+	return providerCallback(h, services.ProviderGoogle, query, cookie)
+}
+
+func providerCallback(h *Handler, provider string, query url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := withTestLogger(httptest.NewRequest(http.MethodGet, "/auth/"+provider+"/callback?"+query.Encode(), nil))
+	req.SetPathValue("provider", provider)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	h.HandleOAuthCallback(w, req)
+	return w
+}
+
+func TestGoogleLogin_Success(t *testing.T) {
+	provider := newFakeOAuthProvider(t)
+	h := newAuthTestHandler(provider)
+
+	authURL, cookie := startLogin(t, h, "/instances/123")
+	code, state := provider.authorize(t, authURL)
+
+	w := callback(h, url.Values{"code": {code}, "state": {state}}, cookie)
+
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("Expected status %d, got %d", http.StatusSeeOther, w.Code)
+	}
+	if got := w.Header().Get("Location"); got != "/instances/123" {
+		t.Errorf("Expected redirect to return_to, got %q", got)
+	}
+
+	if sessionCookie(w) == nil {
+		t.Error("Expected session cookie to be set")
+	}
+}
+
+func sessionCookie(w *httptest.ResponseRecorder) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == sessionCookieName && c.Value != "" {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestLogout_RevokesSession(t *testing.T) {
+	provider := newFakeOAuthProvider(t)
+	h := newAuthTestHandler(provider)
+
+	authURL, cookie := startLogin(t, h, "")
+	code, state := provider.authorize(t, authURL)
+	session := sessionCookie(callback(h, url.Values{"code": {code}, "state": {state}}, cookie))
+	if session == nil {
+		t.Fatal("Expected session cookie to be set")
+	}
+
+	req := withTestLogger(httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+	req.AddCookie(session)
+	user, err := h.GetUserFromRequest(req)
+	if err != nil {
+		t.Fatalf("Expected session to authenticate: %v", err)
+	}
+	if user.UserID != "user-1" {
+		t.Errorf("Expected user-1, got %q", user.UserID)
+	}
+
+	w := httptest.NewRecorder()
+	h.Logout(w, req)
+
+	// The old cookie must stop working even if the browser keeps it
+	if _, err := h.GetUserFromRequest(req); err == nil {
+		t.Error("Expected revoked session to be rejected")
+	}
+}
+
+func TestGoogleLogin_AsksForTwoFactorCode(t *testing.T) {
+	provider := newFakeOAuthProvider(t)
+	h := newAuthTestHandler(provider)
+	h.users.(*fakeUserStore).twoFactorCode = "123456"
+
+	authURL, cookie := startLogin(t, h, "/instances/123")
+	code, state := provider.authorize(t, authURL)
+	w := callback(h, url.Values{"code": {code}, "state": {state}}, cookie)
+
+	if got := w.Header().Get("Location"); got != twoFactorLoginPath {
+		t.Fatalf("Expected redirect to %s, got %q", twoFactorLoginPath, got)
+	}
+	if sessionCookie(w) != nil {
+		t.Fatal("Expected no session cookie before the second factor")
+	}
+	var pending *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == twoFactorCookieName {
+			pending = c
+		}
+	}
+	if pending == nil {
+		t.Fatal("Expected pending two-factor login cookie to be set")
+	}
+
+	submit := func(code string) *httptest.ResponseRecorder {
+		form := url.Values{"code": {code}}
+		req := withTestLogger(httptest.NewRequest(http.MethodPost, twoFactorLoginPath, strings.NewReader(form.Encode())))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(pending)
+		w := httptest.NewRecorder()
+		h.TwoFactorLogin(w, req)
+		return w
+	}
+
+	if w := submit("000000"); w.Code != http.StatusUnauthorized || sessionCookie(w) != nil {
+		t.Errorf("Expected an incorrect code to be rejected, got %d", w.Code)
+	}
+
+	w = submit("123456")
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("Expected status %d, got %d", http.StatusSeeOther, w.Code)
+	}
+	if got := w.Header().Get("Location"); got != "/instances/123" {
+		t.Errorf("Expected redirect to return_to, got %q", got)
+	}
+	if sessionCookie(w) == nil {
+		t.Error("Expected session cookie to be set")
+	}
+}
+
+func TestGoogleLogin_RejectsInvalidState(t *testing.T) {
+	provider := newFakeOAuthProvider(t)
+	h := newAuthTestHandler(provider)
+
+	authURL, cookie := startLogin(t, h, "")
+	code, state := provider.authorize(t, authURL)
+
+	// A second browser's cookie must not complete this login
+	_, otherCookie := startLogin(t, h, "")
+
+	tests := []struct {
+		name   string
+		query  url.Values
+		cookie *http.Cookie
+	}{
+		{"missing cookie", url.Values{"code": {code}, "state": {state}}, nil},
+		{"state mismatch", url.Values{"code": {code}, "state": {"forged"}}, cookie},
+		{"cookie from another login", url.Values{"code": {code}, "state": {state}}, otherCookie},
+		{"tampered cookie", url.Values{"code": {code}, "state": {state}}, &http.Cookie{Name: oauthStateCookieName, Value: cookie.Value + "x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := callback(h, tt.query, tt.cookie)
+			if got := w.Header().Get("Location"); got != "/login?error=invalid_state" {
+				t.Errorf("Expected invalid state redirect, got %q", got)
+			}
+		})
+	}
+}
+
+func TestGoogleLogin_RequiresMatchingVerifier(t *testing.T) {
+	provider := newFakeOAuthProvider(t)
+	h := newAuthTestHandler(provider)
+
+	authURL, cookie := startLogin(t, h, "")
+	code, state := provider.authorize(t, authURL)
+
+	// The code was issued for a different challenge
+	provider.challenges[code] = "not-the-challenge"
+
+	w := callback(h, url.Values{"code": {code}, "state": {state}}, cookie)
+	if got := w.Header().Get("Location"); got != "/login?error=auth_failed" {
+		t.Errorf("Expected auth failure, got %q", got)
+	}
+}
+
+func TestOAuthLogin_RejectsStateFromAnotherProvider(t *testing.T) {
+	provider := newFakeOAuthProvider(t)
+	h := newAuthTestHandler(provider)
+
+	authURL, cookie := startLogin(t, h, "")
+	code, state := provider.authorize(t, authURL)
+
+	w := providerCallback(h, services.ProviderGitHub, url.Values{"code": {code}, "state": {state}}, cookie)
+	if got := w.Header().Get("Location"); got != "/login?error=invalid_state" {
+		t.Errorf("Expected invalid state redirect, got %q", got)
+	}
+}
+
+func TestOAuthLogin_RejectsUnverifiedEmail(t *testing.T) {
+	provider := newFakeOAuthProvider(t)
+	provider.emailVerified = false
+	h := newAuthTestHandler(provider)
+
+	authURL, cookie := startLogin(t, h, "")
+	code, state := provider.authorize(t, authURL)
+
+	w := callback(h, url.Values{"code": {code}, "state": {state}}, cookie)
+	if got := w.Header().Get("Location"); got != "/login?error=email_not_verified" {
+		t.Errorf("Expected unverified email redirect, got %q", got)
+	}
+}
+
+func TestEmailCallback_RequiresPost(t *testing.T) {
+	h := newAuthTestHandler(newFakeOAuthProvider(t))
+
+	// Opening the link only renders a confirmation form, the token is consumed by the POST
+	req := withTestLogger(httptest.NewRequest(http.MethodGet, "/auth/email/callback?token=abc", nil))
+	w := httptest.NewRecorder()
+	h.HandleEmailCallback(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected confirmation page, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `name="token" value="abc"`) {
+		t.Error("Expected confirmation form to carry the token")
+	}
+
+	req = withTestLogger(httptest.NewRequest(http.MethodPost, "/auth/email/callback", strings.NewReader("token=abc")))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	h.HandleEmailCallbackConfirm(w, req)
+	if got := w.Header().Get("Location"); got != "/login?error=invalid_link" {
+		t.Errorf("Expected invalid link redirect, got %q", got)
+	}
+}
+
+func TestImpersonation_IsReadOnly(t *testing.T) {
+	h := newAuthTestHandler(newFakeOAuthProvider(t))
+	store := h.users.(*fakeUserStore)
+	store.sessions["admin-token"] = &services.Session{ID: "session-admin", UserID: "admin-1", IsAdmin: true}
+	store.sessions["user-token"] = &services.Session{ID: "session-user", UserID: "user-1"}
+
+	token, err := h.signImpersonationToken("session-admin", "user-2")
+	if err != nil {
+		t.Fatalf("Failed to sign impersonation token: %v", err)
+	}
+
+	serve := func(wrap func(http.HandlerFunc) http.HandlerFunc, method, path, sessionToken string) (*httptest.ResponseRecorder, *AuthUser) {
+		var got *AuthUser
+		req := withTestLogger(httptest.NewRequest(method, path, nil))
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+		req.AddCookie(&http.Cookie{Name: impersonationCookieName, Value: token})
+		w := httptest.NewRecorder()
+		wrap(func(w http.ResponseWriter, r *http.Request) { got = MustGetUser(r.Context()) })(w, req)
+		return w, got
+	}
+
+	_, user := serve(h.requireAuth, http.MethodGet, "/dashboard", "admin-token")
+	if user == nil || user.UserID != "user-2" || user.ImpersonatorID != "admin-1" {
+		t.Fatalf("Expected the admin to view the dashboard as user-2, got %+v", user)
+	}
+
+	for _, path := range []string{"/account/delete", "/instances/1/transfer"} {
+		if w, _ := serve(h.requireAuthAPI, http.MethodPost, path, "admin-token"); w.Code != http.StatusForbidden {
+			t.Errorf("Expected POST %s to be forbidden while impersonating, got %d", path, w.Code)
+		}
+	}
+	if w, _ := serve(h.requireAuth, http.MethodGet, "/instances/1/editor", "admin-token"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected the editor to be forbidden while impersonating, got %d", w.Code)
+	}
+
+	// The admin console keeps acting as the admin
+	if _, user := serve(h.requireAdmin, http.MethodPost, "/admin/impersonation/stop", "admin-token"); user == nil || user.UserID != "admin-1" {
+		t.Errorf("Expected the admin console to use the admin's session, got %+v", user)
+	}
+
+	// The token is bound to the admin's session and means nothing to anyone else
+	if _, user := serve(h.requireAuth, http.MethodGet, "/dashboard", "user-token"); user == nil || user.UserID != "user-1" || user.ImpersonatorID != "" {
+		t.Errorf("Expected user-1 to stay themselves, got %+v", user)
+	}
+	if w, _ := serve(h.requireAdmin, http.MethodGet, "/admin", "user-token"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected the admin console to be hidden from users, got %d", w.Code)
+	}
+}
+
+func TestLoginReturnPath(t *testing.T) {
+	tests := map[string]string{
+		"":                     "/dashboard",
+		"/instances/1":         "/instances/1",
+		"//evil.example":       "/dashboard",
+		"https://evil.example": "/dashboard",
+		"/\\evil.example":      "/dashboard",
+	}
+	for input, want := range tests {
+		if got := loginReturnPath(input); got != want {
+			t.Errorf("loginReturnPath(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
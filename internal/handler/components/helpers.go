@@ -1,7 +1,12 @@
 package components
 
 import (
+	"context"
+	"encoding/json"
+	"strings"
 	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
 )
 
 // Helper functions for templates
@@ -12,3 +17,72 @@ func formatDate(dateStr string) string {
 	}
 	return t.Format("January 2, 2006")
 }
+
+func formatDateTime(dateStr string) string {
+	t, err := time.Parse(time.RFC3339, dateStr)
+	if err != nil {
+		return dateStr
+	}
+	return t.Format("January 2, 2006 15:04 MST")
+}
+
+// deviceName describes the browser and OS of a user agent, e.g. "Firefox on Linux"
+func deviceName(userAgent string) string {
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	}
+
+	os := ""
+	switch {
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"):
+		os = "iOS"
+	case strings.Contains(userAgent, "Android"):
+		os = "Android"
+	case strings.Contains(userAgent, "Windows"):
+		os = "Windows"
+	case strings.Contains(userAgent, "Mac OS X"):
+		os = "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		os = "Linux"
+	}
+
+	if os == "" {
+		return browser
+	}
+	return browser + " on " + os
+}
+
+// roleLabel describes an organization role, e.g. "Admin"
+func roleLabel(role string) string {
+	if role == "" {
+		return ""
+	}
+	return strings.ToUpper(role[:1]) + role[1:]
+}
+
+// csrfHeaders returns the hx-headers value that adds the CSRF token to every HTMX request
+func csrfHeaders(ctx context.Context) string {
+	b, _ := json.Marshal(map[string]string{"X-CSRF-Token": appctx.GetCSRFToken(ctx)})
+	return string(b)
+}
+
+type impersonationContextKey struct{}
+
+// WithImpersonation marks pages rendered while an admin views the dashboard as the user with email
+func WithImpersonation(ctx context.Context, email string) context.Context {
+	return context.WithValue(ctx, impersonationContextKey{}, email)
+}
+
+// impersonatedEmail returns the user an admin views the dashboard as, empty otherwise
+func impersonatedEmail(ctx context.Context) string {
+	email, _ := ctx.Value(impersonationContextKey{}).(string)
+	return email
+}
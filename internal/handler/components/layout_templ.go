@@ -8,6 +8,8 @@ package components
 import "github.com/a-h/templ"
 import templruntime "github.com/a-h/templ/runtime"
 
+import "github.com/aliuygur/n8n-saas-api/internal/appctx"
+
 func Layout(seo SEOMetadata) templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
@@ -37,7 +39,30 @@ func Layout(seo SEOMetadata) templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "<meta charset=\"utf-8\"><meta name=\"viewport\" content=\"width=device-width, initial-scale=1\"><link rel=\"preconnect\" href=\"https://fonts.googleapis.com\"><link rel=\"preconnect\" href=\"https://fonts.gstatic.com\" crossorigin=\"anonymous\"><link rel=\"stylesheet\" href=\"https://fonts.googleapis.com/css2?family=Inter:ital,opsz,wght@0,14..32,100..900;1,14..32,100..900&display=swap\"><link rel=\"apple-touch-icon\" sizes=\"180x180\" href=\"/static/apple-touch-icon.png\"><link rel=\"icon\" type=\"image/png\" sizes=\"32x32\" href=\"/static/favicon-32x32.png\"><link rel=\"icon\" type=\"image/png\" sizes=\"16x16\" href=\"/static/favicon-16x16.png\"><link rel=\"manifest\" href=\"/static/site.webmanifest\"><script src=\"https://unpkg.com/htmx.org@1.9.10\"></script><script src=\"https://assets.lemonsqueezy.com/lemon.js\" defer></script><script src=\"https://cdn.tailwindcss.com\"></script><!-- Google Analytics --><script async src=\"https://www.googletagmanager.com/gtag/js?id=G-6YMMEM35NW\"></script><script>\n\t\t\t\twindow.dataLayer = window.dataLayer || [];\n\t\t\t\tfunction gtag(){dataLayer.push(arguments);}\n\t\t\t\tgtag('js', new Date());\n\t\t\t\tgtag('config', 'G-6YMMEM35NW');\n\t\t\t</script><script>\n\t\t\t\ttailwind.config = {\n\t\t\t\t\ttheme: {\n\t\t\t\t\t\textend: {\n\t\t\t\t\t\t\tfontFamily: {\n\t\t\t\t\t\t\t\tsans: ['Inter', 'ui-sans-serif', 'system-ui', 'sans-serif'],\n\t\t\t\t\t\t\t},\n\t\t\t\t\t\t},\n\t\t\t\t\t},\n\t\t\t\t}\n\n\t\t\t\tfunction toggleMobileMenu() {\n\t\t\t\t\tconst menu = document.getElementById('mobile-menu');\n\t\t\t\t\tconst button = document.getElementById('mobile-menu-button');\n\t\t\t\t\tconst icon = document.getElementById('menu-icon');\n\n\t\t\t\t\tif (menu.classList.contains('hidden')) {\n\t\t\t\t\t\t// Show menu\n\t\t\t\t\t\tmenu.classList.remove('hidden');\n\t\t\t\t\t\tsetTimeout(() => {\n\t\t\t\t\t\t\tmenu.style.maxHeight = '300px';\n\t\t\t\t\t\t\tmenu.classList.remove('opacity-0');\n\t\t\t\t\t\t\tmenu.classList.add('opacity-100');\n\t\t\t\t\t\t}, 10);\n\n\t\t\t\t\t\t// Rotate icon\n\t\t\t\t\t\ticon.style.transform = 'rotate(90deg)';\n\t\t\t\t\t} else {\n\t\t\t\t\t\t// Hide menu\n\t\t\t\t\t\tmenu.style.maxHeight = '0';\n\t\t\t\t\t\tmenu.classList.remove('opacity-100');\n\t\t\t\t\t\tmenu.classList.add('opacity-0');\n\n\t\t\t\t\t\tsetTimeout(() => {\n\t\t\t\t\t\t\tmenu.classList.add('hidden');\n\t\t\t\t\t\t}, 300);\n\n\t\t\t\t\t\t// Reset icon rotation\n\t\t\t\t\t\ticon.style.transform = 'rotate(0deg)';\n\t\t\t\t\t}\n\t\t\t\t}\n\t\t\t</script><style>\n\t\t\t\thtml, body {\n\t\t\t\t\tfont-feature-settings: \"kern\" 1, \"liga\" 1, \"calt\" 1;\n\t\t\t\t\ttext-rendering: optimizeLegibility;\n\t\t\t\t\t-webkit-font-smoothing: antialiased;\n\t\t\t\t\t-moz-osx-font-smoothing: grayscale;\n\t\t\t\t\toverflow-x: hidden;\n\t\t\t\t\tmax-width: 100%;\n\t\t\t\t}\n\t\t\t\thtml {\n\t\t\t\t\tscroll-behavior: smooth;\n\t\t\t\t}\n\t\t\t\tbody {\n\t\t\t\t\tposition: relative;\n\t\t\t\t}\n\t\t\t\t* {\n\t\t\t\t\tmax-width: 100%;\n\t\t\t\t}\n\t\t\t\t*:focus-visible {\n\t\t\t\t\toutline: none;\n\t\t\t\t\tring: 2px solid rgb(99 102 241);\n\t\t\t\t\tring-offset: 2px;\n\t\t\t\t\tring-offset-color: rgb(17 24 39);\n\t\t\t\t}\n\t\t\t\t.htmx-indicator {\n\t\t\t\t\topacity: 0;\n\t\t\t\t\tvisibility: hidden;\n\t\t\t\t}\n\t\t\t\t.htmx-request .htmx-indicator,\n\t\t\t\t.htmx-request.htmx-indicator {\n\t\t\t\t\topacity: 1;\n\t\t\t\t\tvisibility: visible;\n\t\t\t\t\ttransition: opacity 200ms ease-in;\n\t\t\t\t}\n\t\t\t</style></head><body class=\"bg-gray-950 text-gray-100 flex flex-col min-h-screen\"><div class=\"flex-1 flex flex-col\">")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "<meta charset=\"utf-8\"><meta name=\"viewport\" content=\"width=device-width, initial-scale=1\"><link rel=\"preconnect\" href=\"https://fonts.googleapis.com\"><link rel=\"preconnect\" href=\"https://fonts.gstatic.com\" crossorigin=\"anonymous\"><link rel=\"stylesheet\" href=\"https://fonts.googleapis.com/css2?family=Inter:ital,opsz,wght@0,14..32,100..900;1,14..32,100..900&display=swap\"><link rel=\"apple-touch-icon\" sizes=\"180x180\" href=\"/static/apple-touch-icon.png\"><link rel=\"icon\" type=\"image/png\" sizes=\"32x32\" href=\"/static/favicon-32x32.png\"><link rel=\"icon\" type=\"image/png\" sizes=\"16x16\" href=\"/static/favicon-16x16.png\"><link rel=\"manifest\" href=\"/static/site.webmanifest\"><script src=\"https://unpkg.com/htmx.org@1.9.10\"></script><script src=\"https://assets.lemonsqueezy.com/lemon.js\" defer></script><script src=\"https://cdn.tailwindcss.com\"></script><!-- Google Analytics --><script async src=\"https://www.googletagmanager.com/gtag/js?id=G-6YMMEM35NW\"></script><script>\n\t\t\t\twindow.dataLayer = window.dataLayer || [];\n\t\t\t\tfunction gtag(){dataLayer.push(arguments);}\n\t\t\t\tgtag('js', new Date());\n\t\t\t\tgtag('config', 'G-6YMMEM35NW');\n\t\t\t</script><script>\n\t\t\t\ttailwind.config = {\n\t\t\t\t\ttheme: {\n\t\t\t\t\t\textend: {\n\t\t\t\t\t\t\tfontFamily: {\n\t\t\t\t\t\t\t\tsans: ['Inter', 'ui-sans-serif', 'system-ui', 'sans-serif'],\n\t\t\t\t\t\t\t},\n\t\t\t\t\t\t},\n\t\t\t\t\t},\n\t\t\t\t}\n\n\t\t\t\tfunction toggleMobileMenu() {\n\t\t\t\t\tconst menu = document.getElementById('mobile-menu');\n\t\t\t\t\tconst button = document.getElementById('mobile-menu-button');\n\t\t\t\t\tconst icon = document.getElementById('menu-icon');\n\n\t\t\t\t\tif (menu.classList.contains('hidden')) {\n\t\t\t\t\t\t// Show menu\n\t\t\t\t\t\tmenu.classList.remove('hidden');\n\t\t\t\t\t\tsetTimeout(() => {\n\t\t\t\t\t\t\tmenu.style.maxHeight = '300px';\n\t\t\t\t\t\t\tmenu.classList.remove('opacity-0');\n\t\t\t\t\t\t\tmenu.classList.add('opacity-100');\n\t\t\t\t\t\t}, 10);\n\n\t\t\t\t\t\t// Rotate icon\n\t\t\t\t\t\ticon.style.transform = 'rotate(90deg)';\n\t\t\t\t\t} else {\n\t\t\t\t\t\t// Hide menu\n\t\t\t\t\t\tmenu.style.maxHeight = '0';\n\t\t\t\t\t\tmenu.classList.remove('opacity-100');\n\t\t\t\t\t\tmenu.classList.add('opacity-0');\n\n\t\t\t\t\t\tsetTimeout(() => {\n\t\t\t\t\t\t\tmenu.classList.add('hidden');\n\t\t\t\t\t\t}, 300);\n\n\t\t\t\t\t\t// Reset icon rotation\n\t\t\t\t\t\ticon.style.transform = 'rotate(0deg)';\n\t\t\t\t\t}\n\t\t\t\t}\n\t\t\t</script><style>\n\t\t\t\thtml, body {\n\t\t\t\t\tfont-feature-settings: \"kern\" 1, \"liga\" 1, \"calt\" 1;\n\t\t\t\t\ttext-rendering: optimizeLegibility;\n\t\t\t\t\t-webkit-font-smoothing: antialiased;\n\t\t\t\t\t-moz-osx-font-smoothing: grayscale;\n\t\t\t\t\toverflow-x: hidden;\n\t\t\t\t\tmax-width: 100%;\n\t\t\t\t}\n\t\t\t\thtml {\n\t\t\t\t\tscroll-behavior: smooth;\n\t\t\t\t}\n\t\t\t\tbody {\n\t\t\t\t\tposition: relative;\n\t\t\t\t}\n\t\t\t\t* {\n\t\t\t\t\tmax-width: 100%;\n\t\t\t\t}\n\t\t\t\t*:focus-visible {\n\t\t\t\t\toutline: none;\n\t\t\t\t\tring: 2px solid rgb(99 102 241);\n\t\t\t\t\tring-offset: 2px;\n\t\t\t\t\tring-offset-color: rgb(17 24 39);\n\t\t\t\t}\n\t\t\t\t.htmx-indicator {\n\t\t\t\t\topacity: 0;\n\t\t\t\t\tvisibility: hidden;\n\t\t\t\t}\n\t\t\t\t.htmx-request .htmx-indicator,\n\t\t\t\t.htmx-request.htmx-indicator {\n\t\t\t\t\topacity: 1;\n\t\t\t\t\tvisibility: visible;\n\t\t\t\t\ttransition: opacity 200ms ease-in;\n\t\t\t\t}\n\t\t\t</style></head><body class=\"bg-gray-950 text-gray-100 flex flex-col min-h-screen\" hx-headers=\"")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var2 string
+		templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinStringErrs(csrfHeaders(ctx))
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/handler/components/layout.templ`, Line: 108, Col: 101}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var2))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		if email := impersonatedEmail(ctx); email != "" {
+			templ_7745c5c3_Err = ImpersonationBanner(email).Render(ctx, templ_7745c5c3_Buffer)
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "<div class=\"flex-1 flex flex-col\">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -45,7 +70,7 @@ func Layout(seo SEOMetadata) templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "</div>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "</div>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -53,7 +78,7 @@ func Layout(seo SEOMetadata) templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "</body></html>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "</body></html>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -77,12 +102,12 @@ func Navigation() templ.Component {
 			}()
 		}
 		ctx = templ.InitializeContext(ctx)
-		templ_7745c5c3_Var2 := templ.GetChildren(ctx)
-		if templ_7745c5c3_Var2 == nil {
-			templ_7745c5c3_Var2 = templ.NopComponent
+		templ_7745c5c3_Var3 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var3 == nil {
+			templ_7745c5c3_Var3 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "<nav class=\"border-b border-gray-800 bg-gray-900/50 backdrop-blur-lg\"><div class=\"max-w-7xl mx-auto px-4 sm:px-6 lg:px-8\"><div class=\"flex justify-between items-center h-16\"><a href=\"/\" class=\"flex items-center gap-2 hover:opacity-80 transition-opacity flex-shrink-0\"><svg class=\"w-6 h-6 sm:w-8 sm:h-8 text-indigo-500\" fill=\"none\" stroke=\"currentColor\" viewBox=\"0 0 24 24\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M13 10V3L4 14h7v7l9-11h-7z\"></path></svg><h1 class=\"text-xl sm:text-2xl font-bold text-white\">ranx.cloud</h1></a><!-- Mobile menu button --><button type=\"button\" class=\"md:hidden text-gray-300 hover:text-white p-2 transition-transform duration-300\" onclick=\"toggleMobileMenu()\" aria-label=\"Toggle menu\" id=\"mobile-menu-button\"><svg class=\"w-6 h-6 transition-transform duration-300\" fill=\"none\" stroke=\"currentColor\" viewBox=\"0 0 24 24\" id=\"menu-icon\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M4 6h16M4 12h16M4 18h16\"></path></svg></button><!-- Desktop menu --><div class=\"hidden md:flex gap-2 lg:gap-4 items-center\"><a href=\"/blog\" class=\"text-gray-300 hover:text-white transition-colors px-3 lg:px-4 py-2 font-medium text-sm lg:text-base\">Blog</a> <a href=\"/pricing\" class=\"text-gray-300 hover:text-white transition-colors px-3 lg:px-4 py-2 font-medium text-sm lg:text-base\">Pricing</a> <a href=\"/login\" class=\"text-gray-300 hover:text-white transition-colors px-3 lg:px-4 py-2 font-medium text-sm lg:text-base\">Sign In</a> <a href=\"/login\" class=\"bg-indigo-600 text-white px-4 lg:px-6 py-2 rounded-lg hover:bg-indigo-500 transition-all font-medium shadow-lg shadow-indigo-500/20 text-sm lg:text-base whitespace-nowrap\">Get Started</a></div></div><!-- Mobile menu --><div id=\"mobile-menu\" class=\"hidden md:hidden overflow-hidden transition-all duration-300 ease-in-out max-h-0 opacity-0\" style=\"max-height: 0;\"><div class=\"flex flex-col space-y-2 pb-4 pt-2\"><a href=\"/blog\" class=\"text-gray-300 hover:text-white transition-colors px-4 py-2 font-medium\">Blog</a> <a href=\"/pricing\" class=\"text-gray-300 hover:text-white transition-colors px-4 py-2 font-medium\">Pricing</a> <a href=\"/login\" class=\"text-gray-300 hover:text-white transition-colors px-4 py-2 font-medium\">Sign In</a> <a href=\"/login\" class=\"bg-indigo-600 text-white px-4 py-2 rounded-lg hover:bg-indigo-500 transition-all font-medium shadow-lg shadow-indigo-500/20 text-center\">Get Started</a></div></div></div></nav>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "<nav class=\"border-b border-gray-800 bg-gray-900/50 backdrop-blur-lg\"><div class=\"max-w-7xl mx-auto px-4 sm:px-6 lg:px-8\"><div class=\"flex justify-between items-center h-16\"><a href=\"/\" class=\"flex items-center gap-2 hover:opacity-80 transition-opacity flex-shrink-0\"><svg class=\"w-6 h-6 sm:w-8 sm:h-8 text-indigo-500\" fill=\"none\" stroke=\"currentColor\" viewBox=\"0 0 24 24\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M13 10V3L4 14h7v7l9-11h-7z\"></path></svg><h1 class=\"text-xl sm:text-2xl font-bold text-white\">ranx.cloud</h1></a><!-- Mobile menu button --><button type=\"button\" class=\"md:hidden text-gray-300 hover:text-white p-2 transition-transform duration-300\" onclick=\"toggleMobileMenu()\" aria-label=\"Toggle menu\" id=\"mobile-menu-button\"><svg class=\"w-6 h-6 transition-transform duration-300\" fill=\"none\" stroke=\"currentColor\" viewBox=\"0 0 24 24\" id=\"menu-icon\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M4 6h16M4 12h16M4 18h16\"></path></svg></button><!-- Desktop menu --><div class=\"hidden md:flex gap-2 lg:gap-4 items-center\"><a href=\"/blog\" class=\"text-gray-300 hover:text-white transition-colors px-3 lg:px-4 py-2 font-medium text-sm lg:text-base\">Blog</a> <a href=\"/pricing\" class=\"text-gray-300 hover:text-white transition-colors px-3 lg:px-4 py-2 font-medium text-sm lg:text-base\">Pricing</a> <a href=\"/login\" class=\"text-gray-300 hover:text-white transition-colors px-3 lg:px-4 py-2 font-medium text-sm lg:text-base\">Sign In</a> <a href=\"/login\" class=\"bg-indigo-600 text-white px-4 lg:px-6 py-2 rounded-lg hover:bg-indigo-500 transition-all font-medium shadow-lg shadow-indigo-500/20 text-sm lg:text-base whitespace-nowrap\">Get Started</a></div></div><!-- Mobile menu --><div id=\"mobile-menu\" class=\"hidden md:hidden overflow-hidden transition-all duration-300 ease-in-out max-h-0 opacity-0\" style=\"max-height: 0;\"><div class=\"flex flex-col space-y-2 pb-4 pt-2\"><a href=\"/blog\" class=\"text-gray-300 hover:text-white transition-colors px-4 py-2 font-medium\">Blog</a> <a href=\"/pricing\" class=\"text-gray-300 hover:text-white transition-colors px-4 py-2 font-medium\">Pricing</a> <a href=\"/login\" class=\"text-gray-300 hover:text-white transition-colors px-4 py-2 font-medium\">Sign In</a> <a href=\"/login\" class=\"bg-indigo-600 text-white px-4 py-2 rounded-lg hover:bg-indigo-500 transition-all font-medium shadow-lg shadow-indigo-500/20 text-center\">Get Started</a></div></div></div></nav>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -106,12 +131,71 @@ func AuthenticatedNavigation() templ.Component {
 			}()
 		}
 		ctx = templ.InitializeContext(ctx)
-		templ_7745c5c3_Var3 := templ.GetChildren(ctx)
-		if templ_7745c5c3_Var3 == nil {
-			templ_7745c5c3_Var3 = templ.NopComponent
+		templ_7745c5c3_Var4 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var4 == nil {
+			templ_7745c5c3_Var4 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "<nav class=\"border-b border-gray-800 bg-gray-900/50 backdrop-blur-lg\"><div class=\"max-w-7xl mx-auto px-4 sm:px-6 lg:px-8\"><div class=\"flex justify-between items-center h-16\"><a href=\"/\" class=\"flex items-center gap-2 hover:opacity-80 transition-opacity flex-shrink-0\"><svg class=\"w-6 h-6 sm:w-8 sm:h-8 text-indigo-500\" fill=\"none\" stroke=\"currentColor\" viewBox=\"0 0 24 24\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M13 10V3L4 14h7v7l9-11h-7z\"></path></svg><h1 class=\"text-xl sm:text-2xl font-bold text-white\">ranx.cloud</h1></a><!-- Mobile menu button --><button type=\"button\" class=\"md:hidden text-gray-300 hover:text-white p-2 transition-transform duration-300\" onclick=\"toggleMobileMenu()\" aria-label=\"Toggle menu\" id=\"mobile-menu-button\"><svg class=\"w-6 h-6 transition-transform duration-300\" fill=\"none\" stroke=\"currentColor\" viewBox=\"0 0 24 24\" id=\"menu-icon\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M4 6h16M4 12h16M4 18h16\"></path></svg></button><!-- Desktop menu --><div class=\"hidden md:flex items-center gap-4 lg:gap-6\"><a href=\"/dashboard\" class=\"text-gray-400 hover:text-white transition-colors font-medium text-sm lg:text-base\">Dashboard</a> <a href=\"/organization\" class=\"text-gray-400 hover:text-white transition-colors font-medium text-sm lg:text-base\">Organization</a> <a href=\"/account\" class=\"text-gray-400 hover:text-white transition-colors font-medium text-sm lg:text-base\">Account</a><form method=\"POST\" action=\"/auth/logout\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = CSRFField().Render(ctx, templ_7745c5c3_Buffer)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 9, "<button type=\"submit\" class=\"text-gray-400 hover:text-white transition-colors font-medium text-sm lg:text-base\">Logout</button></form></div></div><!-- Mobile menu --><div id=\"mobile-menu\" class=\"hidden md:hidden overflow-hidden transition-all duration-300 ease-in-out max-h-0 opacity-0\" style=\"max-height: 0;\"><div class=\"flex flex-col space-y-2 pb-4 pt-2\"><a href=\"/dashboard\" class=\"text-gray-300 hover:text-white transition-colors px-4 py-2 font-medium\">Dashboard</a> <a href=\"/organization\" class=\"text-gray-300 hover:text-white transition-colors px-4 py-2 font-medium\">Organization</a> <a href=\"/account\" class=\"text-gray-300 hover:text-white transition-colors px-4 py-2 font-medium\">Account</a><form method=\"POST\" action=\"/auth/logout\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = CSRFField().Render(ctx, templ_7745c5c3_Buffer)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 10, "<button type=\"submit\" class=\"w-full text-left text-gray-300 hover:text-white transition-colors px-4 py-2 font-medium\">Logout</button></form></div></div></div></nav>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		return nil
+	})
+}
+
+// CSRFField adds the CSRF token to a plain form post
+func CSRFField() templ.Component {
+	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
+			return templ_7745c5c3_CtxErr
+		}
+		templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+		if !templ_7745c5c3_IsBuffer {
+			defer func() {
+				templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err == nil {
+					templ_7745c5c3_Err = templ_7745c5c3_BufErr
+				}
+			}()
+		}
+		ctx = templ.InitializeContext(ctx)
+		templ_7745c5c3_Var5 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var5 == nil {
+			templ_7745c5c3_Var5 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "<nav class=\"border-b border-gray-800 bg-gray-900/50 backdrop-blur-lg\"><div class=\"max-w-7xl mx-auto px-4 sm:px-6 lg:px-8\"><div class=\"flex justify-between items-center h-16\"><a href=\"/\" class=\"flex items-center gap-2 hover:opacity-80 transition-opacity flex-shrink-0\"><svg class=\"w-6 h-6 sm:w-8 sm:h-8 text-indigo-500\" fill=\"none\" stroke=\"currentColor\" viewBox=\"0 0 24 24\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M13 10V3L4 14h7v7l9-11h-7z\"></path></svg><h1 class=\"text-xl sm:text-2xl font-bold text-white\">ranx.cloud</h1></a><!-- Mobile menu button --><button type=\"button\" class=\"md:hidden text-gray-300 hover:text-white p-2 transition-transform duration-300\" onclick=\"toggleMobileMenu()\" aria-label=\"Toggle menu\" id=\"mobile-menu-button\"><svg class=\"w-6 h-6 transition-transform duration-300\" fill=\"none\" stroke=\"currentColor\" viewBox=\"0 0 24 24\" id=\"menu-icon\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M4 6h16M4 12h16M4 18h16\"></path></svg></button><!-- Desktop menu --><div class=\"hidden md:flex items-center gap-4 lg:gap-6\"><a href=\"/dashboard\" class=\"text-gray-400 hover:text-white transition-colors font-medium text-sm lg:text-base\">Dashboard</a> <a href=\"/account\" class=\"text-gray-400 hover:text-white transition-colors font-medium text-sm lg:text-base\">Account</a> <a href=\"/api/auth/logout\" class=\"text-gray-400 hover:text-white transition-colors font-medium text-sm lg:text-base\">Logout</a></div></div><!-- Mobile menu --><div id=\"mobile-menu\" class=\"hidden md:hidden overflow-hidden transition-all duration-300 ease-in-out max-h-0 opacity-0\" style=\"max-height: 0;\"><div class=\"flex flex-col space-y-2 pb-4 pt-2\"><a href=\"/dashboard\" class=\"text-gray-300 hover:text-white transition-colors px-4 py-2 font-medium\">Dashboard</a> <a href=\"/account\" class=\"text-gray-300 hover:text-white transition-colors px-4 py-2 font-medium\">Account</a> <a href=\"/api/auth/logout\" class=\"text-gray-300 hover:text-white transition-colors px-4 py-2 font-medium\">Logout</a></div></div></div></nav>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 11, "<input type=\"hidden\" name=\"csrf_token\" value=\"")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var6 string
+		templ_7745c5c3_Var6, templ_7745c5c3_Err = templ.JoinStringErrs(appctx.GetCSRFToken(ctx))
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/handler/components/layout.templ`, Line: 258, Col: 72}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var6))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 12, "\">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -135,12 +219,12 @@ func Footer() templ.Component {
 			}()
 		}
 		ctx = templ.InitializeContext(ctx)
-		templ_7745c5c3_Var4 := templ.GetChildren(ctx)
-		if templ_7745c5c3_Var4 == nil {
-			templ_7745c5c3_Var4 = templ.NopComponent
+		templ_7745c5c3_Var7 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var7 == nil {
+			templ_7745c5c3_Var7 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "<footer class=\"border-t border-gray-800 bg-gray-900/70 py-8 mt-12\"><div class=\"max-w-7xl mx-auto px-4 flex flex-col md:flex-row items-center justify-between gap-4 text-gray-400 text-sm\"><div class=\"mb-2 md:mb-0 text-center md:text-left\">&copy; 2025 ranx.cloud. All rights reserved.</div><div class=\"flex flex-wrap gap-3 sm:gap-4 md:gap-6 justify-center text-xs sm:text-sm\"><a href=\"/pricing\" class=\"hover:text-white transition-colors whitespace-nowrap\">Pricing</a> <a href=\"/terms\" class=\"hover:text-white transition-colors whitespace-nowrap\">Terms</a> <a href=\"/privacy\" class=\"hover:text-white transition-colors whitespace-nowrap\">Privacy</a> <a href=\"/refund-policy\" class=\"hover:text-white transition-colors whitespace-nowrap\">Refund</a></div></div></footer>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 13, "<footer class=\"border-t border-gray-800 bg-gray-900/70 py-8 mt-12\"><div class=\"max-w-7xl mx-auto px-4 flex flex-col md:flex-row items-center justify-between gap-4 text-gray-400 text-sm\"><div class=\"mb-2 md:mb-0 text-center md:text-left\">&copy; 2025 ranx.cloud. All rights reserved.</div><div class=\"flex flex-wrap gap-3 sm:gap-4 md:gap-6 justify-center text-xs sm:text-sm\"><a href=\"/pricing\" class=\"hover:text-white transition-colors whitespace-nowrap\">Pricing</a> <a href=\"/terms\" class=\"hover:text-white transition-colors whitespace-nowrap\">Terms</a> <a href=\"/privacy\" class=\"hover:text-white transition-colors whitespace-nowrap\">Privacy</a> <a href=\"/refund-policy\" class=\"hover:text-white transition-colors whitespace-nowrap\">Refund</a></div></div></footer>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -170,12 +254,12 @@ func ErrorPage(message string) templ.Component {
 			}()
 		}
 		ctx = templ.InitializeContext(ctx)
-		templ_7745c5c3_Var5 := templ.GetChildren(ctx)
-		if templ_7745c5c3_Var5 == nil {
-			templ_7745c5c3_Var5 = templ.NopComponent
+		templ_7745c5c3_Var8 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var8 == nil {
+			templ_7745c5c3_Var8 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Var6 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		templ_7745c5c3_Var9 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 			templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 			templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
 			if !templ_7745c5c3_IsBuffer {
@@ -187,26 +271,26 @@ func ErrorPage(message string) templ.Component {
 				}()
 			}
 			ctx = templ.InitializeContext(ctx)
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "<main class=\"pt-16 p-4 container mx-auto\"><h1 class=\"text-4xl font-bold text-white mb-4\">Error</h1><p class=\"text-gray-400\">")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 14, "<main class=\"pt-16 p-4 container mx-auto\"><h1 class=\"text-4xl font-bold text-white mb-4\">Error</h1><p class=\"text-gray-400\">")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			var templ_7745c5c3_Var7 string
-			templ_7745c5c3_Var7, templ_7745c5c3_Err = templ.JoinStringErrs(message)
+			var templ_7745c5c3_Var10 string
+			templ_7745c5c3_Var10, templ_7745c5c3_Err = templ.JoinStringErrs(message)
 			if templ_7745c5c3_Err != nil {
-				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/handler/components/layout.templ`, Line: 260, Col: 37}
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/handler/components/layout.templ`, Line: 285, Col: 37}
 			}
-			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var7))
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var10))
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 9, "</p></main>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 15, "</p></main>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
 			return nil
 		})
-		templ_7745c5c3_Err = Layout(errorPageSEO).Render(templ.WithChildren(ctx, templ_7745c5c3_Var6), templ_7745c5c3_Buffer)
+		templ_7745c5c3_Err = Layout(errorPageSEO).Render(templ.WithChildren(ctx, templ_7745c5c3_Var9), templ_7745c5c3_Buffer)
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
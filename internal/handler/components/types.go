@@ -14,6 +14,31 @@ func (i *Instance) GetInstanceURL() string {
 	return i.InstanceURL
 }
 
+// AccessPolicy represents the editor access policy of an instance
+type AccessPolicy struct {
+	AllowedCIDRs         string // One CIDR range per line
+	AuthMode             string
+	BasicAuthUsername    string
+	HasBasicAuthPassword bool
+	PublicPaths          string // One path prefix per line
+}
+
+// InstanceUptime is the health of an instance as seen by the uptime monitor
+type InstanceUptime struct {
+	Status          string // unknown, up, down or paused
+	LastError       string
+	LastCheckedAt   string // RFC3339, empty before the first check
+	DownSince       string // RFC3339, set while down
+	Windows         []UptimeWindow
+	AlertWebhookURL string
+}
+
+// UptimeWindow is the uptime of an instance over a period
+type UptimeWindow struct {
+	Label   string // e.g. "7 days"
+	Percent string // Formatted, a dash without checks
+}
+
 // Subscription represents a user's subscription
 type Subscription struct {
 	ID             string
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+)
+
+// csrfTestServer wraps a handler that records the token it saw
+func csrfTestServer() (http.Handler, *string) {
+	var seen string
+	h := &Handler{}
+	return h.CSRFMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = appctx.GetCSRFToken(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})), &seen
+}
+
+// csrfToken fetches a page and returns the CSRF cookie the browser would store
+func csrfToken(t *testing.T, server http.Handler) *http.Cookie {
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, withTestLogger(httptest.NewRequest(http.MethodGet, "http://ranx.cloud/dashboard", nil)))
+	for _, c := range w.Result().Cookies() {
+		if c.Name == csrfCookieName {
+			return c
+		}
+	}
+	t.Fatal("Expected CSRF cookie to be set")
+	return nil
+}
+
+func formPost(path string, form url.Values) *http.Request {
+	req := withTestLogger(httptest.NewRequest(http.MethodPost, "http://ranx.cloud"+path, strings.NewReader(form.Encode())))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestCSRFMiddleware_RejectsCrossSiteRequests(t *testing.T) {
+	server, _ := csrfTestServer()
+	cookie := csrfToken(t, server)
+
+	tests := []struct {
+		name string
+		req  func() *http.Request
+	}{
+		{"form post without cookie", func() *http.Request {
+			return formPost("/api/create-instance", url.Values{"subdomain": {"x"}})
+		}},
+		{"form post with cookie but no token", func() *http.Request {
+			// A cross-site form makes the browser send the cookie, but the attacker can't read it
+			req := formPost("/api/create-instance", url.Values{"subdomain": {"x"}})
+			req.AddCookie(cookie)
+			return req
+		}},
+		{"form post with wrong token", func() *http.Request {
+			req := formPost("/api/create-instance", url.Values{csrfFormField: {"guessed"}})
+			req.AddCookie(cookie)
+			return req
+		}},
+		{"delete without header", func() *http.Request {
+			req := withTestLogger(httptest.NewRequest(http.MethodDelete, "http://ranx.cloud/instances/1", nil))
+			req.AddCookie(cookie)
+			return req
+		}},
+		{"valid token from a tenant subdomain", func() *http.Request {
+			req := formPost("/auth/logout", url.Values{csrfFormField: {cookie.Value}})
+			req.AddCookie(cookie)
+			req.Header.Set("Origin", "https://evil.ranx.cloud")
+			return req
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, tt.req())
+			if w.Code != http.StatusForbidden {
+				t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+			}
+		})
+	}
+}
+
+func TestCSRFMiddleware_AllowsSameSiteRequests(t *testing.T) {
+	server, seen := csrfTestServer()
+	cookie := csrfToken(t, server)
+
+	if *seen != cookie.Value {
+		t.Errorf("Expected token %q in context, got %q", cookie.Value, *seen)
+	}
+
+	htmx := withTestLogger(httptest.NewRequest(http.MethodDelete, "http://ranx.cloud/instances/1", nil))
+	htmx.AddCookie(cookie)
+	htmx.Header.Set(csrfHeaderName, cookie.Value)
+	htmx.Header.Set("Origin", "http://ranx.cloud")
+
+	form := formPost("/auth/logout", url.Values{csrfFormField: {cookie.Value}})
+	form.AddCookie(cookie)
+
+	webhook := withTestLogger(httptest.NewRequest(http.MethodPost, "http://ranx.cloud/api/webhooks/lemonsqueezy", strings.NewReader("{}")))
+
+	for name, req := range map[string]*http.Request{"htmx header": htmx, "form field": form, "webhook": webhook} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+			}
+		})
+	}
+}
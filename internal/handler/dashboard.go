@@ -15,8 +15,8 @@ import (
 func (h *Handler) Dashboard(w http.ResponseWriter, r *http.Request) {
 	user := MustGetUser(r.Context())
 
-	// List instances for the user
-	instances, err := h.services.GetInstancesByUser(r.Context(), user.UserID)
+	// List instances of the current organization
+	instances, err := h.services.GetOrganizationInstances(r.Context(), user.Membership.OrganizationID)
 	if err != nil {
 		appctx.GetLogger(r.Context()).Error("failed to fetch instances", slog.Any("error", err))
 		http.Error(w, "Failed to load instances", http.StatusInternalServerError)
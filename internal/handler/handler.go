@@ -1,83 +1,95 @@
 package handler
 
 import (
-	"sync"
+	"context"
+	"fmt"
+	"net/netip"
 	"time"
 
 	"github.com/aliuygur/n8n-saas-api/internal/config"
 	"github.com/aliuygur/n8n-saas-api/internal/services"
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 )
 
-const (
-	// instanceCacheTTL defines how long instances are cached
-	instanceCacheTTL = 5 * time.Minute
-	// cacheCleanupInterval defines how often to clean up expired cache entries
-	cacheCleanupInterval = 10 * time.Minute
-)
-
-// instanceCacheEntry holds cached instance data with expiration
-type instanceCacheEntry struct {
-	instance  *services.Instance
-	expiresAt time.Time
+// userStore is the part of the services the login flow and sessions depend on, replaced in tests
+type userStore interface {
+	LoginWithIdentity(ctx context.Context, identity services.LoginIdentity) (*services.User, error)
+	UpdateUserLastLogin(ctx context.Context, userID string) error
+	SendMagicLink(ctx context.Context, email, returnTo string) error
+	ConsumeMagicLink(ctx context.Context, token string) (*services.User, string, error)
+	CreateSession(ctx context.Context, params services.CreateSessionParams) (string, *services.Session, error)
+	ValidateSession(ctx context.Context, token string) (*services.Session, error)
+	RevokeSessionToken(ctx context.Context, token string) error
+	VerifyTwoFactorCode(ctx context.Context, userID, code string) error
+	GetImpersonatedSession(ctx context.Context, userID string) (*services.Session, error)
 }
 
 // Handler holds all dependencies for HTTP handlers
 type Handler struct {
-	oauth2Config       *oauth2.Config
+	// providers are the OAuth login providers keyed by name
+	providers          map[string]*loginProvider
 	jwtSecret          []byte
 	config             *config.Config
 	polarWebhookSecret string
 
 	services *services.Service
+	users    userStore
+	tokens   tokenStore
+	access   tenantAccessStore
+	audit    auditStore
+
+	// idempotency stores responses of API requests sent with an Idempotency-Key
+	idempotency idempotencyStore
+
+	// tenantCache caches subdomain -> Instance mapping, evicted by RunCacheInvalidation
+	tenantCache tenantCache
+
+	// proxyLimiter enforces rate limits on proxied tenant traffic
+	proxyLimiter proxyLimiter
+
+	// gateSessions caches the session checks of gate cookies
+	gateSessions gateSessionCache
 
-	// instanceCache caches subdomain -> Instance mapping
-	instanceCache sync.Map
+	// trustedProxies may set the client IP headers, see clientIP
+	trustedProxies []netip.Prefix
+
+	// upstreams pools reverse proxies per instance namespace
+	upstreams upstreamPool
+
+	// openAPIDocument is the OpenAPI document of the JSON API, built from apiV1Operations
+	openAPIDocument []byte
 }
 
 // New creates a new Handler instance
 func New(cfg *config.Config, svc *services.Service) (*Handler, error) {
+	h := &Handler{
+		providers: newLoginProviders(cfg),
+		jwtSecret: []byte(cfg.JWT.Secret),
+		config:    cfg,
+		services:  svc,
+		users:     svc,
+		tokens:    svc,
+		access:    svc,
+		audit:     svc,
 
-	// Initialize OAuth2 config
-	oauth2Config := &oauth2.Config{
-		ClientID:     cfg.Google.ClientID,
-		ClientSecret: cfg.Google.ClientSecret,
-		RedirectURL:  cfg.Server.BaseURL("/auth/google/callback"),
-		Scopes: []string{
-			"https://www.googleapis.com/auth/userinfo.email",
-			"https://www.googleapis.com/auth/userinfo.profile",
-		},
-		Endpoint: google.Endpoint,
+		idempotency:    svc,
+		trustedProxies: cfg.Server.TrustedProxies,
 	}
 
-	h := &Handler{
-		oauth2Config: oauth2Config,
-		jwtSecret:    []byte(cfg.JWT.Secret),
-		config:       cfg,
-		services:     svc,
+	doc, err := buildOpenAPIDocument(h.apiV1Operations())
+	if err != nil {
+		return nil, fmt.Errorf("failed to build OpenAPI document: %w", err)
 	}
-
-	// Start background cache cleanup
-	go h.cleanupExpiredCacheEntries()
+	h.openAPIDocument = doc
 
 	return h, nil
 }
 
-// cleanupExpiredCacheEntries runs periodically to remove expired cache entries
-func (h *Handler) cleanupExpiredCacheEntries() {
-	ticker := time.NewTicker(cacheCleanupInterval)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		now := time.Now()
-		h.instanceCache.Range(func(key, value any) bool {
-			if entry, ok := value.(*instanceCacheEntry); ok {
-				if now.After(entry.expiresAt) {
-					h.instanceCache.Delete(key)
-				}
-			}
-			return true
-		})
-	}
+// CleanupCaches removes expired cache entries and idle rate limiter buckets and upstreams.
+// It runs every CacheCleanupInterval on each replica, see services.RunOnEachReplica.
+func (h *Handler) CleanupCaches(ctx context.Context) {
+	now := time.Now()
+	h.tenantCache.cleanup(now)
+	h.proxyLimiter.cleanup(now)
+	h.upstreams.cleanup(now)
+	h.gateSessions.cleanup(now)
 }
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/services"
+)
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// replayedHeaders are the response headers stored with an idempotent response
+var replayedHeaders = []string{"Content-Type", "Location"}
+
+// idempotencyStore stores responses of requests sent with an Idempotency-Key, replaced in tests
+type idempotencyStore interface {
+	BeginIdempotentRequest(ctx context.Context, userID, key, requestHash string) (*services.IdempotencyKey, error)
+	CompleteIdempotentRequest(ctx context.Context, keyID string, response services.IdempotentResponse) error
+	ReleaseIdempotentRequest(ctx context.Context, keyID string) error
+}
+
+// idempotent replays the stored response when a request is retried with the same Idempotency-Key,
+// so a retried create can't provision twice. Requests without the header are handled as usual.
+// Must run after requireAPIToken, keys are scoped to the token owner.
+func (h *Handler) idempotent(handlerFunc http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			handlerFunc(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		l := appctx.GetLogger(ctx)
+		user := MustGetUser(ctx)
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxAPIRequestBody))
+		if err != nil {
+			writeAPIError(w, r, apperrs.Client(apperrs.CodeInvalidInput, "request body too large"))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.New()
+		sum.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+		sum.Write(body)
+
+		claimed, err := h.idempotency.BeginIdempotentRequest(ctx, user.UserID, key, hex.EncodeToString(sum.Sum(nil)))
+		if err != nil {
+			writeAPIError(w, r, err)
+			return
+		}
+
+		if claimed.Response != nil {
+			for name, value := range claimed.Response.Headers {
+				w.Header().Set(name, value)
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(claimed.Response.Status)
+			_, _ = w.Write(claimed.Response.Body)
+			return
+		}
+
+		rec := &responseCapture{ResponseWriter: w, status: http.StatusOK}
+		handlerFunc(rec, r)
+
+		// Store the outcome even if the client went away, that's when it retries
+		ctx = context.WithoutCancel(ctx)
+
+		// Server errors may be transient, let a retry handle the request again
+		if rec.status >= http.StatusInternalServerError {
+			if err := h.idempotency.ReleaseIdempotentRequest(ctx, claimed.ID); err != nil {
+				l.Error("failed to release idempotency key", slog.String("key_id", claimed.ID), slog.Any("error", err))
+			}
+			return
+		}
+
+		headers := map[string]string{}
+		for _, name := range replayedHeaders {
+			if value := rec.Header().Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+		if err := h.idempotency.CompleteIdempotentRequest(ctx, claimed.ID, services.IdempotentResponse{
+			Status:  rec.status,
+			Headers: headers,
+			Body:    rec.body.Bytes(),
+		}); err != nil {
+			l.Error("failed to store idempotent response", slog.String("key_id", claimed.ID), slog.Any("error", err))
+		}
+	}
+}
+
+// responseCapture passes a response through while keeping its status and body
+type responseCapture struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (c *responseCapture) WriteHeader(status int) {
+	if !c.wroteHeader {
+		c.status = status
+		c.wroteHeader = true
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *responseCapture) Write(b []byte) (int, error) {
+	c.wroteHeader = true
+	c.body.Write(b)
+	return c.ResponseWriter.Write(b)
+}
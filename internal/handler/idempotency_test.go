@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/services"
+)
+
+type fakeIdempotencyKey struct {
+	id, requestHash string
+	response        *services.IdempotentResponse
+}
+
+// fakeIdempotencyStore keeps keys in memory, mirroring the rules of the services implementation
+type fakeIdempotencyStore struct {
+	keys map[string]*fakeIdempotencyKey
+}
+
+func (f *fakeIdempotencyStore) BeginIdempotentRequest(_ context.Context, userID, key, requestHash string) (*services.IdempotencyKey, error) {
+	k, ok := f.keys[userID+"/"+key]
+	if !ok {
+		k = &fakeIdempotencyKey{id: userID + "/" + key, requestHash: requestHash}
+		f.keys[k.id] = k
+		return &services.IdempotencyKey{ID: k.id}, nil
+	}
+	if k.requestHash != requestHash {
+		return nil, apperrs.Client(apperrs.CodeInvalidInput, "Idempotency-Key was already used for a different request")
+	}
+	if k.response == nil {
+		return nil, apperrs.Client(apperrs.CodeConflict, "a request with this Idempotency-Key is still in progress")
+	}
+	return &services.IdempotencyKey{ID: k.id, Response: k.response}, nil
+}
+
+func (f *fakeIdempotencyStore) CompleteIdempotentRequest(_ context.Context, keyID string, response services.IdempotentResponse) error {
+	f.keys[keyID].response = &response
+	return nil
+}
+
+func (f *fakeIdempotencyStore) ReleaseIdempotentRequest(_ context.Context, keyID string) error {
+	delete(f.keys, keyID)
+	return nil
+}
+
+func TestIdempotent(t *testing.T) {
+	h := &Handler{idempotency: &fakeIdempotencyStore{keys: map[string]*fakeIdempotencyKey{}}}
+
+	calls := 0
+	failNext := false
+	create := h.idempotent(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if failNext {
+			failNext = false
+			writeAPIError(w, r, apperrs.Server("failed to deploy n8n", nil))
+			return
+		}
+		w.Header().Set("Location", "/api/v1/instances/inst-1")
+		writeJSON(w, http.StatusCreated, APIInstance{ID: "inst-1", Subdomain: "acme"})
+	})
+
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req := withTestLogger(httptest.NewRequest(http.MethodPost, "/api/v1/instances", strings.NewReader(body)))
+		req = req.WithContext(context.WithValue(req.Context(), userContextKey, &AuthUser{UserID: "user-1"}))
+		if key != "" {
+			req.Header.Set(idempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		create(w, req)
+		return w
+	}
+
+	first := send("key-1", `{"subdomain":"acme"}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, first.Code)
+	}
+
+	retry := send("key-1", `{"subdomain":"acme"}`)
+	if calls != 1 {
+		t.Errorf("Expected the retry to be replayed, handler ran %d times", calls)
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("Expected replayed %d %q, got %d %q", http.StatusCreated, first.Body.String(), retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get("Location") != "/api/v1/instances/inst-1" || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("Expected replayed headers, got %v", retry.Header())
+	}
+
+	if w := send("key-1", `{"subdomain":"other"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected reused key with a different body to fail with %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	// A server error frees the key so the retry runs the handler again
+	failNext = true
+	if w := send("key-2", `{"subdomain":"acme"}`); w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+	if w := send("key-2", `{"subdomain":"acme"}`); w.Code != http.StatusCreated {
+		t.Errorf("Expected retry after a server error to succeed, got %d", w.Code)
+	}
+	if calls != 3 {
+		t.Errorf("Expected handler to run 3 times, ran %d times", calls)
+	}
+
+	send("", `{"subdomain":"acme"}`)
+	send("", `{"subdomain":"acme"}`)
+	if calls != 5 {
+		t.Errorf("Expected requests without a key to always run, handler ran %d times", calls)
+	}
+}
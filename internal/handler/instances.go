@@ -1,11 +1,15 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
 	"github.com/aliuygur/n8n-saas-api/internal/handler/components"
 	"github.com/aliuygur/n8n-saas-api/internal/services"
 	"github.com/aliuygur/n8n-saas-api/pkg/domainutils"
@@ -26,7 +30,7 @@ func (h *Handler) CreateInstance(w http.ResponseWriter, r *http.Request) {
 	subdomain := r.FormValue("subdomain")
 
 	instance, err := h.services.CreateInstance(ctx, services.CreateInstanceParams{
-		UserID:    user.UserID,
+		Member:    user.Membership,
 		Subdomain: subdomain,
 	})
 	if err != nil {
@@ -147,7 +151,7 @@ func (h *Handler) DeleteInstance(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.services.DeleteInstance(ctx, services.DeleteInstanceParams{
-		UserID:     user.UserID,
+		Member:     user.Membership,
 		InstanceID: instanceID,
 	}); err != nil {
 		l.Error("Failed to delete instance", slog.Any("error", err))
@@ -175,20 +179,14 @@ func (h *Handler) InstanceDetail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get the instance
-	instance, err := h.services.GetInstanceByID(ctx, instanceID)
+	// Get the instance, instances of other organizations are reported as missing
+	instance, err := h.services.GetMemberInstance(ctx, user.Membership, instanceID)
 	if err != nil {
 		l.Error("Failed to get instance", slog.Any("error", err))
 		http.NotFound(w, r)
 		return
 	}
 
-	// Verify the instance belongs to the user
-	if instance.UserID != user.UserID {
-		http.Error(w, "Forbidden", http.StatusForbidden)
-		return
-	}
-
 	instanceView := components.Instance{
 		ID:          instance.ID,
 		InstanceURL: instance.GetInstanceURL(),
@@ -198,5 +196,144 @@ func (h *Handler) InstanceDetail(w http.ResponseWriter, r *http.Request) {
 		CreatedAt:   instance.CreatedAt.Format(time.RFC3339),
 	}
 
-	lo.Must0(components.InstanceDetailPage(instanceView).Render(ctx, w))
+	policy, err := h.services.GetInstanceAccessPolicy(ctx, instance.ID)
+	if err != nil {
+		l.Error("Failed to get access policy", slog.Any("error", err))
+		http.Error(w, "Failed to load instance", http.StatusInternalServerError)
+		return
+	}
+
+	access := components.InstanceAccess{CanManage: user.Membership.Can(services.RoleAdmin)}
+	if user.Membership.Can(services.RoleOwner) {
+		targets, err := h.transferTargets(ctx, user)
+		if err != nil {
+			l.Error("Failed to list organizations", slog.Any("error", err))
+			http.Error(w, "Failed to load instance", http.StatusInternalServerError)
+			return
+		}
+		if len(targets) > 0 {
+			access.Transfer = &components.InstanceTransferData{InstanceID: instance.ID, Targets: targets}
+		}
+	}
+
+	monitor, err := h.services.GetInstanceMonitor(ctx, instance)
+	if err != nil {
+		l.Error("Failed to get instance uptime", slog.Any("error", err))
+		http.Error(w, "Failed to load instance", http.StatusInternalServerError)
+		return
+	}
+
+	lo.Must0(components.InstanceDetailPage(instanceView, toAccessPolicyView(policy), access, toInstanceUptimeView(monitor)).Render(ctx, w))
+}
+
+// UpdateAccessPolicy saves the editor access policy of an instance via HTMX
+func (h *Handler) UpdateAccessPolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	instanceID := r.PathValue("id")
+
+	params := services.UpdateAccessPolicyParams{
+		Member:            user.Membership,
+		InstanceID:        instanceID,
+		AllowedCIDRs:      strings.Split(r.FormValue("allowed_cidrs"), "\n"),
+		AuthMode:          r.FormValue("auth_mode"),
+		BasicAuthUsername: r.FormValue("basic_auth_username"),
+		BasicAuthPassword: r.FormValue("basic_auth_password"),
+		PublicPaths:       strings.Split(r.FormValue("public_paths"), "\n"),
+	}
+
+	policy, err := h.services.UpdateInstanceAccessPolicy(ctx, params)
+	if err != nil {
+		l.Error("Failed to update access policy", slog.Any("error", err))
+
+		// Re-render the submitted values so the user can fix them
+		view := components.AccessPolicy{
+			AllowedCIDRs:      r.FormValue("allowed_cidrs"),
+			AuthMode:          params.AuthMode,
+			BasicAuthUsername: params.BasicAuthUsername,
+			PublicPaths:       r.FormValue("public_paths"),
+		}
+		lo.Must0(components.AccessPolicyForm(instanceID, view, clientErrorMessage(err, "Failed to save access policy"), true).Render(ctx, w))
+		return
+	}
+
+	l.Info("Access policy updated",
+		slog.String("instance_id", instanceID),
+		slog.String("user_id", user.UserID),
+		slog.String("auth_mode", policy.AuthMode))
+
+	lo.Must0(components.AccessPolicyForm(instanceID, toAccessPolicyView(policy), "Access policy saved", false).Render(ctx, w))
+}
+
+// clientErrorMessage returns the message of a client error, and fallback for any other
+// error so database and cluster details are never shown to users
+func clientErrorMessage(err error, fallback string) string {
+	var appErr *apperrs.Error
+	if errors.As(err, &appErr) && appErr.Kind == apperrs.KindClient {
+		return appErr.Msg
+	}
+	return fallback
+}
+
+// toAccessPolicyView maps a services.AccessPolicy to the form view
+func toAccessPolicyView(policy *services.AccessPolicy) components.AccessPolicy {
+	return components.AccessPolicy{
+		AllowedCIDRs:         strings.Join(policy.AllowedCIDRs, "\n"),
+		AuthMode:             policy.AuthMode,
+		BasicAuthUsername:    policy.BasicAuthUsername,
+		HasBasicAuthPassword: policy.HasBasicAuthPassword(),
+		PublicPaths:          strings.Join(policy.PublicPaths, "\n"),
+	}
+}
+
+// UpdateInstanceAlerts saves the alert webhook of an instance via HTMX
+func (h *Handler) UpdateInstanceAlerts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	instanceID := r.PathValue("id")
+	webhookURL := r.FormValue("alert_webhook_url")
+
+	monitor, err := h.services.UpdateInstanceAlertWebhook(ctx, services.UpdateAlertWebhookParams{
+		Member:     user.Membership,
+		InstanceID: instanceID,
+		WebhookURL: webhookURL,
+	})
+	if err != nil {
+		l.Error("Failed to update alert webhook", slog.Any("error", err))
+		lo.Must0(components.InstanceAlertsForm(instanceID, webhookURL, clientErrorMessage(err, "Failed to save alert webhook"), true).Render(ctx, w))
+		return
+	}
+
+	l.Info("Alert webhook updated",
+		slog.String("instance_id", instanceID),
+		slog.String("user_id", user.UserID))
+
+	lo.Must0(components.InstanceAlertsForm(instanceID, monitor.AlertWebhookURL, "Alerts saved", false).Render(ctx, w))
+}
+
+// toInstanceUptimeView maps a services.InstanceMonitor to the uptime card view
+func toInstanceUptimeView(monitor *services.InstanceMonitor) components.InstanceUptime {
+	view := components.InstanceUptime{
+		Status:          monitor.Status,
+		LastError:       monitor.LastError,
+		AlertWebhookURL: monitor.AlertWebhookURL,
+	}
+	if monitor.LastCheckedAt != nil {
+		view.LastCheckedAt = monitor.LastCheckedAt.Format(time.RFC3339)
+	}
+	if monitor.DownSince != nil {
+		view.DownSince = monitor.DownSince.Format(time.RFC3339)
+	}
+	for _, uptime := range monitor.Uptime {
+		percent := "—"
+		if uptime.Checks > 0 {
+			percent = fmt.Sprintf("%.2f%%", uptime.Percent)
+		}
+		view.Windows = append(view.Windows, components.UptimeWindow{Label: uptime.Label, Percent: percent})
+	}
+	return view
 }
@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/aliuygur/n8n-saas-api/internal/config"
+	"github.com/aliuygur/n8n-saas-api/internal/services"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+	"golang.org/x/oauth2/google"
+	"golang.org/x/oauth2/microsoft"
+)
+
+// microsoftConsumerTenantID is the tenant of personal Microsoft accounts, whose emails are always verified
+const microsoftConsumerTenantID = "9188040d-6c67-4c5b-b112-36a304b66dad"
+
+// loginProvider is an OAuth identity provider users can sign in with
+type loginProvider struct {
+	name         string // Used in the /auth/{provider} routes
+	displayName  string
+	oauth2Config *oauth2.Config
+	// apiURL is where fetchIdentity reads the user profile, replaced in tests
+	apiURL string
+	// fetchIdentity reads the user behind an access token
+	fetchIdentity func(ctx context.Context, client *http.Client, apiURL string, token *oauth2.Token) (*services.LoginIdentity, error)
+}
+
+// newLoginProviders returns the providers that are configured, keyed by name.
+// Google is always enabled.
+func newLoginProviders(cfg *config.Config) map[string]*loginProvider {
+	providers := map[string]*loginProvider{
+		services.ProviderGoogle: {
+			name:        services.ProviderGoogle,
+			displayName: "Google",
+			oauth2Config: &oauth2.Config{
+				ClientID:     cfg.Google.ClientID,
+				ClientSecret: cfg.Google.ClientSecret,
+				RedirectURL:  cfg.Server.BaseURL("/auth/google/callback"),
+				Scopes: []string{
+					"https://www.googleapis.com/auth/userinfo.email",
+					"https://www.googleapis.com/auth/userinfo.profile",
+				},
+				Endpoint: google.Endpoint,
+			},
+			apiURL:        "https://www.googleapis.com/oauth2/v2/userinfo",
+			fetchIdentity: fetchGoogleIdentity,
+		},
+	}
+
+	if cfg.GitHub.ClientID != "" {
+		providers[services.ProviderGitHub] = &loginProvider{
+			name:        services.ProviderGitHub,
+			displayName: "GitHub",
+			oauth2Config: &oauth2.Config{
+				ClientID:     cfg.GitHub.ClientID,
+				ClientSecret: cfg.GitHub.ClientSecret,
+				RedirectURL:  cfg.Server.BaseURL("/auth/github/callback"),
+				Scopes:       []string{"read:user", "user:email"},
+				Endpoint:     github.Endpoint,
+			},
+			apiURL:        "https://api.github.com",
+			fetchIdentity: fetchGitHubIdentity,
+		}
+	}
+
+	if cfg.Microsoft.ClientID != "" {
+		providers[services.ProviderMicrosoft] = &loginProvider{
+			name:        services.ProviderMicrosoft,
+			displayName: "Microsoft",
+			oauth2Config: &oauth2.Config{
+				ClientID:     cfg.Microsoft.ClientID,
+				ClientSecret: cfg.Microsoft.ClientSecret,
+				RedirectURL:  cfg.Server.BaseURL("/auth/microsoft/callback"),
+				Scopes:       []string{"openid", "email", "profile"},
+				Endpoint:     microsoft.AzureADEndpoint("common"),
+			},
+			fetchIdentity: fetchMicrosoftIdentity,
+		}
+	}
+
+	return providers
+}
+
+// GoogleUserInfo represents the user info returned from Google
+type GoogleUserInfo struct {
+	ID            string `json:"id"`
+	Email         string `json:"email"`
+	VerifiedEmail bool   `json:"verified_email"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+}
+
+func fetchGoogleIdentity(ctx context.Context, client *http.Client, apiURL string, _ *oauth2.Token) (*services.LoginIdentity, error) {
+	var googleUser GoogleUserInfo
+	if err := getJSON(ctx, client, apiURL, &googleUser); err != nil {
+		return nil, err
+	}
+
+	return &services.LoginIdentity{
+		Provider:      services.ProviderGoogle,
+		Subject:       googleUser.ID,
+		Email:         googleUser.Email,
+		EmailVerified: googleUser.VerifiedEmail,
+		Name:          googleUser.Name,
+	}, nil
+}
+
+// githubUser is the profile returned by GET /user
+type githubUser struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+	Name  string `json:"name"`
+}
+
+// githubEmail is an address returned by GET /user/emails
+type githubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+func fetchGitHubIdentity(ctx context.Context, client *http.Client, apiURL string, _ *oauth2.Token) (*services.LoginIdentity, error) {
+	var user githubUser
+	if err := getJSON(ctx, client, apiURL+"/user", &user); err != nil {
+		return nil, err
+	}
+
+	// The profile email is optional and may be unverified, the primary address is what GitHub vouches for
+	var emails []githubEmail
+	if err := getJSON(ctx, client, apiURL+"/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &services.LoginIdentity{
+		Provider: services.ProviderGitHub,
+		Subject:  strconv.FormatInt(user.ID, 10),
+		Name:     user.Name,
+	}
+	if identity.Name == "" {
+		identity.Name = user.Login
+	}
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email = email.Email
+			identity.EmailVerified = email.Verified
+			break
+		}
+	}
+	return identity, nil
+}
+
+// microsoftIDTokenClaims are the ID token claims used to identify a Microsoft user
+type microsoftIDTokenClaims struct {
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	TenantID string `json:"tid"`
+	// EmailDomainOwnerVerified is the xms_edov optional claim, true when the tenant owns the email domain
+	EmailDomainOwnerVerified bool `json:"xms_edov"`
+	jwt.RegisteredClaims
+}
+
+func fetchMicrosoftIdentity(_ context.Context, _ *http.Client, _ string, token *oauth2.Token) (*services.LoginIdentity, error) {
+	idToken, ok := token.Extra("id_token").(string)
+	if !ok || idToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	// The ID token came straight from the token endpoint over TLS, so its signature
+	// doesn't need to be checked again (OpenID Connect Core 3.1.3.7)
+	var claims microsoftIDTokenClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(idToken, &claims); err != nil {
+		return nil, fmt.Errorf("failed to parse id_token: %w", err)
+	}
+
+	return &services.LoginIdentity{
+		Provider: services.ProviderMicrosoft,
+		Subject:  claims.TenantID + ":" + claims.Subject,
+		Email:    claims.Email,
+		// Work accounts can set any email, so only trust it when the tenant owns the domain
+		EmailVerified: claims.TenantID == microsoftConsumerTenantID || claims.EmailDomainOwnerVerified,
+		Name:          claims.Name,
+	}, nil
+}
+
+// getJSON decodes the JSON response of an authorized GET request
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("GET %s returned %d: %s", url, resp.StatusCode, body)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -2,14 +2,20 @@ package handler
 
 import (
 	"context"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
 )
 
 // HostRouter middleware routes requests based on the Host header
-// *.ranx.cloud (except www and apex) -> proxy handler
-// www.ranx.cloud, ranx.cloud -> mux routes
+// *.TENANT_DOMAIN (except www and apex) -> proxy handler
+// anything else, such as www.ranx.cloud and ranx.cloud -> mux routes, behind CSRF protection
 func (h *Handler) HostRouter(mux http.Handler) http.Handler {
+	dashboard := h.CSRFMiddleware(mux)
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		host := r.Host
 		// Remove port if present
@@ -18,16 +24,27 @@ func (h *Handler) HostRouter(mux http.Handler) http.Handler {
 		}
 
 		// If it's a subdomain (not www or apex), proxy to n8n instance
-		if host != "www.ranx.cloud" && host != "ranx.cloud" && strings.HasSuffix(host, ".ranx.cloud") {
+		if _, ok := h.config.Routing.InstanceSubdomain(host); ok {
+			// Streams lift the timeout once the upstream answered, see isStreamingResponse
+			h.setDeadlines(w, r, h.config.Server.ProxyTimeout)
 			h.ProxyHandler(w, r)
 			return
 		}
 
-		// Otherwise, use the normal mux
-		mux.ServeHTTP(w, r)
+		// Otherwise, use the normal mux with strict limits
+		h.setDeadlines(w, r, h.config.Server.DashboardTimeout)
+		ctx := appctx.WithClient(r.Context(), appctx.Client{IP: clientIP(r, h.trustedProxies), UserAgent: r.UserAgent()})
+		dashboard.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// setDeadlines applies the per-route timeout to the client connection
+func (h *Handler) setDeadlines(w http.ResponseWriter, r *http.Request, timeout time.Duration) {
+	if err := setConnDeadlines(w, timeout); err != nil {
+		appctx.GetLogger(r.Context()).Debug("Failed to set connection deadlines", slog.Any("error", err))
+	}
+}
+
 // contextKey is a custom type for context keys to avoid collisions
 type contextKey string
 
@@ -69,8 +86,8 @@ func (h *Handler) OptionalAuthMiddleware(next http.Handler) http.Handler {
 
 // MustGetUser retrieves the user from context, panics if not found
 // Should only be used after AuthMiddleware
-func MustGetUser(ctx context.Context) *JWTClaims {
-	user, ok := ctx.Value(userContextKey).(*JWTClaims)
+func MustGetUser(ctx context.Context) *AuthUser {
+	user, ok := ctx.Value(userContextKey).(*AuthUser)
 	if !ok {
 		panic("user not found in context - ensure AuthMiddleware is applied")
 	}
@@ -78,8 +95,8 @@ func MustGetUser(ctx context.Context) *JWTClaims {
 }
 
 // GetUser retrieves the user from context, returns nil if not found
-func GetUser(ctx context.Context) *JWTClaims {
-	user, ok := ctx.Value(userContextKey).(*JWTClaims)
+func GetUser(ctx context.Context) *AuthUser {
+	user, ok := ctx.Value(userContextKey).(*AuthUser)
 	if !ok {
 		return nil
 	}
@@ -91,13 +108,29 @@ func (h *Handler) requireAuth(handlerFunc http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		user, err := h.GetUserFromRequest(r)
 		if err != nil {
-			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			// Bring the user back to the page after login
+			loginURL := "/login"
+			if r.Method == http.MethodGet {
+				loginURL += "?return_to=" + url.QueryEscape(r.URL.RequestURI())
+			}
+			http.Redirect(w, r, loginURL, http.StatusSeeOther)
+			return
+		}
+
+		// The organization requires two-factor authentication, send the user to turn it on
+		if user.TwoFactorRequired && !twoFactorSetupAllowed(r.URL.Path) {
+			http.Redirect(w, r, "/account", http.StatusSeeOther)
+			return
+		}
+
+		if user.ImpersonatorID != "" && !impersonationAllowed(r) {
+			http.Error(w, "Viewing as another user is read-only", http.StatusForbidden)
 			return
 		}
 
 		// Add user to context
 		ctx := context.WithValue(r.Context(), userContextKey, user)
-		handlerFunc(w, r.WithContext(ctx))
+		handlerFunc(w, r.WithContext(withImpersonation(ctx, user)))
 	}
 }
 
@@ -111,6 +144,33 @@ func (h *Handler) requireAuthAPI(handlerFunc http.HandlerFunc) http.HandlerFunc
 			return
 		}
 
+		if user.TwoFactorRequired && !twoFactorSetupAllowed(r.URL.Path) {
+			http.Error(w, "Two-factor authentication required", http.StatusForbidden)
+			return
+		}
+
+		if user.ImpersonatorID != "" && !impersonationAllowed(r) {
+			http.Error(w, "Viewing as another user is read-only", http.StatusForbidden)
+			return
+		}
+
+		// Add user to context
+		ctx := context.WithValue(r.Context(), userContextKey, user)
+		handlerFunc(w, r.WithContext(withImpersonation(ctx, user)))
+	}
+}
+
+// requireAdmin is a helper for the admin console. It checks the admin's own session,
+// so it keeps working while they view the dashboard as someone else.
+// Everyone else gets a 404, the console isn't advertised.
+func (h *Handler) requireAdmin(handlerFunc http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := h.sessionUser(r)
+		if err != nil || !user.IsAdmin {
+			http.NotFound(w, r)
+			return
+		}
+
 		// Add user to context
 		ctx := context.WithValue(r.Context(), userContextKey, user)
 		handlerFunc(w, r.WithContext(ctx))
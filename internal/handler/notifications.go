@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/handler/components"
+	"github.com/aliuygur/n8n-saas-api/internal/services"
+	"github.com/samber/lo"
+)
+
+// UpdateNotificationPreferences saves which emails the user gets and re-renders the card
+func (h *Handler) UpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	data := components.AccountNotificationsData{Message: "Preferences saved"}
+	prefs, err := h.services.UpdateNotificationPreferences(ctx, user.UserID, r.Form["enabled"])
+	if err != nil {
+		data.IsError = true
+		data.Message = "Failed to save preferences, please try again"
+		if apperrs.CodeIs(err, apperrs.CodeInvalidInput) {
+			data.Message = err.Error()
+		} else {
+			l.Error("Failed to update notification preferences", slog.Any("error", err))
+		}
+		if prefs, err = h.services.ListNotificationPreferences(ctx, user.UserID); err != nil {
+			l.Error("Failed to list notification preferences", slog.Any("error", err))
+			http.Error(w, "Failed to load email notifications", http.StatusInternalServerError)
+			return
+		}
+	}
+	data.Notifications = toAccountNotifications(prefs)
+
+	lo.Must0(components.AccountNotifications(data).Render(ctx, w))
+}
+
+// accountNotifications returns the notification preferences of the user for the account page
+func (h *Handler) accountNotifications(ctx context.Context, userID string) (components.AccountNotificationsData, error) {
+	prefs, err := h.services.ListNotificationPreferences(ctx, userID)
+	if err != nil {
+		return components.AccountNotificationsData{}, err
+	}
+	return components.AccountNotificationsData{Notifications: toAccountNotifications(prefs)}, nil
+}
+
+func toAccountNotifications(prefs []services.NotificationPreference) []components.AccountNotification {
+	result := make([]components.AccountNotification, 0, len(prefs))
+	for _, pref := range prefs {
+		result = append(result, components.AccountNotification{
+			Kind:        pref.Kind,
+			Name:        pref.Name,
+			Description: pref.Description,
+			Enabled:     pref.Enabled,
+			Required:    pref.Required,
+		})
+	}
+	return result
+}
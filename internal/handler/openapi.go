@@ -0,0 +1,244 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/services"
+)
+
+// apiOperation is an /api/v1 endpoint. Routes and the OpenAPI document are both built
+// from these, so the spec can't drift from what the server handles.
+type apiOperation struct {
+	Method      string
+	Path        string
+	OperationID string
+	Summary     string
+	Scope       string
+	Request     any  // Zero value of the JSON request body, nil if there is none
+	Response    any  // Zero value of the JSON response body, nil if there is none
+	Status      int  // Success status
+	Idempotent  bool // Accepts an Idempotency-Key header
+	Handler     http.HandlerFunc
+}
+
+// apiV1Operations lists the endpoints of the JSON API
+func (h *Handler) apiV1Operations() []apiOperation {
+	return []apiOperation{
+		{
+			Method: http.MethodGet, Path: "/api/v1/instances", OperationID: "ListInstances",
+			Summary: "List your instances", Scope: services.ScopeInstancesRead,
+			Response: APIInstanceList{}, Status: http.StatusOK, Handler: h.APIListInstances,
+		},
+		{
+			Method: http.MethodPost, Path: "/api/v1/instances", OperationID: "CreateInstance",
+			Summary: "Create an instance, provisioning continues in the background", Scope: services.ScopeInstancesWrite,
+			Request: APICreateInstanceRequest{}, Response: APIInstance{}, Status: http.StatusCreated, Idempotent: true,
+			Handler: h.APICreateInstance,
+		},
+		{
+			Method: http.MethodGet, Path: "/api/v1/instances/{id}", OperationID: "GetInstance",
+			Summary: "Get an instance", Scope: services.ScopeInstancesRead,
+			Response: APIInstance{}, Status: http.StatusOK, Handler: h.APIGetInstance,
+		},
+		{
+			Method: http.MethodPut, Path: "/api/v1/instances/{id}", OperationID: "UpdateInstance",
+			Summary: "Set the n8n version and custom environment of an instance, unchanged values are a no-op", Scope: services.ScopeInstancesWrite,
+			Request: APIUpdateInstanceRequest{}, Response: APIInstance{}, Status: http.StatusOK, Handler: h.APIUpdateInstance,
+		},
+		{
+			Method: http.MethodDelete, Path: "/api/v1/instances/{id}", OperationID: "DeleteInstance",
+			Summary: "Delete an instance and its data", Scope: services.ScopeInstancesWrite,
+			Status: http.StatusNoContent, Handler: h.APIDeleteInstance,
+		},
+		{
+			Method: http.MethodPost, Path: "/api/v1/instances/{id}/restart", OperationID: "RestartInstance",
+			Summary: "Restart the n8n pods of an instance", Scope: services.ScopeInstancesWrite,
+			Status: http.StatusAccepted, Handler: h.APIRestartInstance,
+		},
+		{
+			Method: http.MethodGet, Path: "/api/v1/instances/{id}/logs", OperationID: "GetInstanceLogs",
+			Summary: "Get the last 500 log lines of an instance", Scope: services.ScopeInstancesRead,
+			Response: APIInstanceLogs{}, Status: http.StatusOK, Handler: h.APIGetInstanceLogs,
+		},
+		{
+			Method: http.MethodGet, Path: "/api/v1/subscription", OperationID: "GetSubscription",
+			Summary: "Get your subscription", Scope: services.ScopeSubscriptionRead,
+			Response: APISubscription{}, Status: http.StatusOK, Handler: h.APIGetSubscription,
+		},
+	}
+}
+
+// OpenAPISpec serves the OpenAPI document of the JSON API
+func (h *Handler) OpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	_, _ = w.Write(h.openAPIDocument)
+}
+
+// buildOpenAPIDocument renders the OpenAPI 3 document for operations as indented JSON
+func buildOpenAPIDocument(operations []apiOperation) ([]byte, error) {
+	schemas := map[string]any{}
+	paths := map[string]map[string]any{}
+
+	for _, op := range operations {
+		operation := map[string]any{
+			"operationId":      op.OperationID,
+			"summary":          op.Summary,
+			"security":         []any{map[string]any{"bearerAuth": []string{}}},
+			"x-required-scope": op.Scope,
+		}
+
+		var params []any
+		for _, name := range pathParams(op.Path) {
+			params = append(params, map[string]any{
+				"name": name, "in": "path", "required": true,
+				"schema": map[string]any{"type": "string"},
+			})
+		}
+		if op.Idempotent {
+			params = append(params, map[string]any{
+				"name": idempotencyKeyHeader, "in": "header", "required": false,
+				"description": "Retries with the same key return the first response instead of repeating the request",
+				"schema":      map[string]any{"type": "string", "maxLength": 255},
+			})
+		}
+		if params != nil {
+			operation["parameters"] = params
+		}
+
+		if op.Request != nil {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content":  jsonContent(schemaFor(reflect.TypeOf(op.Request), schemas)),
+			}
+		}
+
+		success := map[string]any{"description": http.StatusText(op.Status)}
+		if op.Response != nil {
+			success["content"] = jsonContent(schemaFor(reflect.TypeOf(op.Response), schemas))
+		}
+		operation["responses"] = map[string]any{
+			strconv.Itoa(op.Status): success,
+			"default": map[string]any{
+				"description": "Error",
+				"content":     jsonContent(schemaFor(reflect.TypeOf(APIError{}), schemas)),
+			},
+		}
+
+		if paths[op.Path] == nil {
+			paths[op.Path] = map[string]any{}
+		}
+		paths[op.Path][strings.ToLower(op.Method)] = operation
+	}
+
+	doc := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "ranx.cloud API",
+			"version":     "1.0.0",
+			"description": "Manage n8n instances on ranx.cloud. Authenticate with a personal access token from the account page.",
+		},
+		"servers": []any{map[string]any{"url": "https://ranx.cloud"}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+// pathParams returns the {name} segments of a route pattern
+func pathParams(path string) []string {
+	var names []string
+	for _, segment := range strings.Split(path, "/") {
+		if name, ok := strings.CutPrefix(segment, "{"); ok {
+			names = append(names, strings.TrimSuffix(name, "}"))
+		}
+	}
+	return names
+}
+
+// schemaFor returns the JSON schema of t. Named structs are added to schemas
+// and referenced, with the API prefix of their Go name dropped.
+func schemaFor(t reflect.Type, schemas map[string]any) map[string]any {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := schemaFor(t.Elem(), schemas)
+		if _, isRef := schema["$ref"]; isRef {
+			// Siblings of $ref are ignored in OpenAPI 3.0
+			return map[string]any{"allOf": []any{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem(), schemas)}
+	case reflect.Map:
+		if t.Elem().Kind() == reflect.Interface {
+			return map[string]any{"type": "object", "additionalProperties": true}
+		}
+		return map[string]any{"type": "object", "additionalProperties": schemaFor(t.Elem(), schemas)}
+	case reflect.Struct:
+		name := strings.TrimPrefix(t.Name(), "API")
+		ref := map[string]any{"$ref": "#/components/schemas/" + name}
+		if _, ok := schemas[name]; ok {
+			return ref
+		}
+		schemas[name] = nil // Reserve the name while fields are visited
+
+		properties := map[string]any{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("json")
+			jsonName, opts, _ := strings.Cut(tag, ",")
+			if jsonName == "-" || !field.IsExported() {
+				continue
+			}
+			if jsonName == "" {
+				jsonName = field.Name
+			}
+			properties[jsonName] = schemaFor(field.Type, schemas)
+			if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Pointer {
+				required = append(required, jsonName)
+			}
+		}
+
+		schema := map[string]any{"type": "object", "properties": properties}
+		if required != nil {
+			schema["required"] = required
+		}
+		schemas[name] = schema
+		return ref
+	default:
+		panic("openapi: unsupported type " + t.String())
+	}
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var updateOpenAPI = flag.Bool("update", false, "rewrite api/openapi.json from the handler definitions")
+
+// openAPIPath is the committed spec the Go client in pkg/ranxapi is generated from
+const openAPIPath = "../../api/openapi.json"
+
+// TestOpenAPIDocumentUpToDate fails when the API changed without regenerating the committed spec.
+// Run go test ./internal/handler -run OpenAPI -update, then go generate ./pkg/ranxapi.
+func TestOpenAPIDocumentUpToDate(t *testing.T) {
+	h := &Handler{}
+	doc, err := buildOpenAPIDocument(h.apiV1Operations())
+	if err != nil {
+		t.Fatalf("Failed to build OpenAPI document: %v", err)
+	}
+
+	if *updateOpenAPI {
+		if err := os.WriteFile(openAPIPath, doc, 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", openAPIPath, err)
+		}
+		return
+	}
+
+	committed, err := os.ReadFile(openAPIPath)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", openAPIPath, err)
+	}
+	if !bytes.Equal(doc, committed) {
+		t.Errorf("%s is out of date, run go test ./internal/handler -run OpenAPI -update and go generate ./pkg/ranxapi", openAPIPath)
+	}
+}
+
+func TestOpenAPIDocumentCoversRoutes(t *testing.T) {
+	h := &Handler{}
+	doc, err := buildOpenAPIDocument(h.apiV1Operations())
+	if err != nil {
+		t.Fatalf("Failed to build OpenAPI document: %v", err)
+	}
+
+	var spec struct {
+		Paths map[string]map[string]struct {
+			OperationID string                     `json:"operationId"`
+			Responses   map[string]json.RawMessage `json:"responses"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(doc, &spec); err != nil {
+		t.Fatalf("Failed to parse OpenAPI document: %v", err)
+	}
+
+	// Every routed operation is documented with its success status and the error body
+	for _, op := range h.apiV1Operations() {
+		documented, ok := spec.Paths[op.Path][strings.ToLower(op.Method)]
+		if !ok {
+			t.Errorf("%s %s is not documented", op.Method, op.Path)
+			continue
+		}
+		if documented.OperationID != op.OperationID {
+			t.Errorf("%s %s has operationId %q, want %q", op.Method, op.Path, documented.OperationID, op.OperationID)
+		}
+		if _, ok := documented.Responses[strconv.Itoa(op.Status)]; !ok {
+			t.Errorf("%s %s does not document its %d response", op.Method, op.Path, op.Status)
+		}
+		if _, ok := documented.Responses["default"]; !ok {
+			t.Errorf("%s %s does not document its error response", op.Method, op.Path)
+		}
+	}
+
+	for _, name := range []string{"Instance", "InstanceList", "InstanceLogs", "CreateInstanceRequest", "UpdateInstanceRequest", "Subscription", "Error", "ErrorDetail"} {
+		if _, ok := spec.Components.Schemas[name]; !ok {
+			t.Errorf("Schema %s is missing", name)
+		}
+	}
+}
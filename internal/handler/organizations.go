@@ -0,0 +1,398 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/handler/components"
+	"github.com/aliuygur/n8n-saas-api/internal/services"
+	"github.com/samber/lo"
+)
+
+// OrganizationPage renders the current organization with its members and invitations
+func (h *Handler) OrganizationPage(w http.ResponseWriter, r *http.Request) {
+	h.renderOrganizationPage(w, r, "")
+}
+
+func (h *Handler) renderOrganizationPage(w http.ResponseWriter, r *http.Request, errorMessage string) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	orgs, err := h.services.ListUserOrganizations(ctx, user.UserID)
+	if err != nil {
+		l.Error("Failed to list organizations", slog.Any("error", err))
+		http.Error(w, "Failed to load organizations", http.StatusInternalServerError)
+		return
+	}
+
+	members, err := h.organizationMembers(ctx, user)
+	if err != nil {
+		l.Error("Failed to list organization members", slog.Any("error", err))
+		http.Error(w, "Failed to load organization", http.StatusInternalServerError)
+		return
+	}
+
+	data := components.OrganizationPageData{
+		Organizations: lo.Map(orgs, func(org services.Organization, _ int) components.OrganizationView {
+			return toOrganizationView(org)
+		}),
+		Members: members,
+		Error:   errorMessage,
+	}
+	if user.Membership.Can(services.RoleAdmin) {
+		webhooks, err := h.organizationWebhooks(ctx, user)
+		if err != nil {
+			l.Error("Failed to list webhook endpoints", slog.Any("error", err))
+			http.Error(w, "Failed to load organization", http.StatusInternalServerError)
+			return
+		}
+		data.Webhooks = &webhooks
+	}
+	for _, org := range data.Organizations {
+		if org.ID == user.Membership.OrganizationID {
+			data.Current = org
+		}
+	}
+
+	lo.Must0(components.OrganizationPage(data).Render(ctx, w))
+}
+
+// CreateOrganization creates an organization owned by the user and switches to it
+func (h *Handler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	org, err := h.services.CreateOrganization(ctx, user.UserID, r.FormValue("name"))
+	if err != nil {
+		l.Error("Failed to create organization", slog.Any("error", err))
+		h.renderOrganizationPage(w, r, err.Error())
+		return
+	}
+
+	l.Info("Organization created", slog.String("organization_id", org.ID), slog.String("user_id", user.UserID))
+	http.Redirect(w, r, "/organization", http.StatusSeeOther)
+}
+
+// SwitchOrganization changes the organization the user's sessions act in
+func (h *Handler) SwitchOrganization(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	if err := h.services.SwitchOrganization(ctx, user.UserID, r.FormValue("organization_id")); err != nil {
+		l.Error("Failed to switch organization", slog.Any("error", err))
+		h.renderOrganizationPage(w, r, err.Error())
+		return
+	}
+
+	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
+
+// UpdateOrganizationMemberRole changes the role of a member via HTMX
+func (h *Handler) UpdateOrganizationMemberRole(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+	memberID := r.PathValue("userID")
+	role := r.FormValue("role")
+
+	err := h.services.UpdateOrganizationMemberRole(ctx, user.Membership, memberID, role)
+	if err != nil {
+		l.Error("Failed to update member role", slog.Any("error", err))
+	} else {
+		l.Info("Organization member role updated",
+			slog.String("organization_id", user.Membership.OrganizationID),
+			slog.String("member_id", memberID),
+			slog.String("role", role))
+	}
+
+	// Owners who demote themselves lose the rights of the page they are on
+	if err == nil && memberID == user.UserID && role != services.RoleOwner {
+		w.Header().Set("HX-Redirect", "/organization")
+		return
+	}
+
+	h.renderOrganizationMembers(w, r, err, "Role updated")
+}
+
+// RemoveOrganizationMember removes a member, or lets the user leave the organization, via HTMX
+func (h *Handler) RemoveOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+	memberID := r.PathValue("userID")
+
+	err := h.services.RemoveOrganizationMember(ctx, user.Membership, memberID)
+	if err != nil {
+		l.Error("Failed to remove organization member", slog.Any("error", err))
+	} else {
+		l.Info("Organization member removed",
+			slog.String("organization_id", user.Membership.OrganizationID),
+			slog.String("member_id", memberID),
+			slog.String("user_id", user.UserID))
+	}
+
+	// The next request falls back to the personal organization
+	if err == nil && memberID == user.UserID {
+		w.Header().Set("HX-Redirect", "/organization")
+		return
+	}
+
+	h.renderOrganizationMembers(w, r, err, "Member removed")
+}
+
+// InviteOrganizationMember emails an invitation to join the organization via HTMX
+func (h *Handler) InviteOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	invitation, err := h.services.InviteOrganizationMember(ctx, services.InviteOrganizationMemberParams{
+		Member: user.Membership,
+		Email:  r.FormValue("email"),
+		Role:   r.FormValue("role"),
+	})
+	if err != nil {
+		l.Error("Failed to invite organization member", slog.Any("error", err))
+		h.renderOrganizationMembers(w, r, err, "")
+		return
+	}
+
+	l.Info("Organization member invited",
+		slog.String("organization_id", user.Membership.OrganizationID),
+		slog.String("invitation_id", invitation.ID),
+		slog.String("user_id", user.UserID))
+
+	h.renderOrganizationMembers(w, r, nil, "Invitation sent to "+invitation.Email)
+}
+
+// RevokeOrganizationInvitation deletes a pending invitation via HTMX
+func (h *Handler) RevokeOrganizationInvitation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+	invitationID := r.PathValue("id")
+
+	err := h.services.RevokeOrganizationInvitation(ctx, user.Membership, invitationID)
+	if err != nil {
+		l.Error("Failed to revoke invitation", slog.Any("error", err))
+	} else {
+		l.Info("Organization invitation revoked",
+			slog.String("organization_id", user.Membership.OrganizationID),
+			slog.String("invitation_id", invitationID))
+	}
+
+	h.renderOrganizationMembers(w, r, err, "Invitation revoked")
+}
+
+// SetOrganizationTwoFactor turns the organization's two-factor requirement on or off via HTMX
+func (h *Handler) SetOrganizationTwoFactor(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+	required := r.FormValue("require_two_factor") == "on"
+
+	err := h.services.SetOrganizationRequireTwoFactor(ctx, user.Membership, required)
+	if err != nil {
+		l.Error("Failed to update two-factor requirement", slog.Any("error", err))
+	} else {
+		l.Info("Organization two-factor requirement updated",
+			slog.String("organization_id", user.Membership.OrganizationID),
+			slog.Bool("require_two_factor", required))
+	}
+
+	message := "Two-factor authentication is no longer required"
+	if required {
+		message = "Members now need two-factor authentication"
+	}
+	h.renderOrganizationMembers(w, r, err, message)
+}
+
+// renderOrganizationMembers re-renders the members card with the outcome of a change
+func (h *Handler) renderOrganizationMembers(w http.ResponseWriter, r *http.Request, changeErr error, success string) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	data, err := h.organizationMembers(ctx, user)
+	if err != nil {
+		l.Error("Failed to list organization members", slog.Any("error", err))
+		http.Error(w, "Failed to load organization", http.StatusInternalServerError)
+		return
+	}
+	if changeErr != nil {
+		data.Message, data.IsError = changeErr.Error(), true
+	} else {
+		data.Message = success
+	}
+
+	lo.Must0(components.OrganizationMembers(data).Render(ctx, w))
+}
+
+// organizationMembers lists the members and, for admins, the pending invitations of the current organization
+func (h *Handler) organizationMembers(ctx context.Context, user *AuthUser) (components.OrganizationMembersData, error) {
+	org, err := h.services.GetOrganization(ctx, user.Membership)
+	if err != nil {
+		return components.OrganizationMembersData{}, err
+	}
+
+	members, err := h.services.ListOrganizationMembers(ctx, user.Membership)
+	if err != nil {
+		return components.OrganizationMembersData{}, err
+	}
+
+	data := components.OrganizationMembersData{
+		Personal:         org.Personal,
+		Role:             user.Membership.Role,
+		CurrentUserID:    user.UserID,
+		Roles:            services.OrganizationRoles,
+		RequireTwoFactor: org.RequireTwoFactor,
+		Members: lo.Map(members, func(m services.OrganizationMember, _ int) components.OrganizationMemberView {
+			return components.OrganizationMemberView{
+				UserID:           m.UserID,
+				Email:            m.Email,
+				Name:             m.Name,
+				Role:             m.Role,
+				TwoFactorEnabled: m.TwoFactorEnabled,
+				CreatedAt:        m.CreatedAt.Format(time.RFC3339),
+			}
+		}),
+	}
+
+	if user.Membership.Can(services.RoleAdmin) && !org.Personal {
+		invitations, err := h.services.ListOrganizationInvitations(ctx, user.Membership)
+		if err != nil {
+			return components.OrganizationMembersData{}, err
+		}
+		data.Invitations = lo.Map(invitations, func(inv services.OrganizationInvitation, _ int) components.OrganizationInvitationView {
+			return components.OrganizationInvitationView{
+				ID:        inv.ID,
+				Email:     inv.Email,
+				Role:      inv.Role,
+				ExpiresAt: inv.ExpiresAt.Format(time.RFC3339),
+			}
+		})
+	}
+
+	return data, nil
+}
+
+// InvitationPage shows an emailed invitation to the logged in user
+func (h *Handler) InvitationPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := MustGetUser(ctx)
+
+	invitation, err := h.services.GetOrganizationInvitation(ctx, r.PathValue("token"))
+	if err != nil {
+		appctx.GetLogger(ctx).Error("Failed to get invitation", slog.Any("error", err))
+		w.WriteHeader(http.StatusNotFound)
+		lo.Must0(components.InvitationPage(components.InvitationPageData{Error: "This invitation is invalid or has expired."}).Render(ctx, w))
+		return
+	}
+
+	lo.Must0(components.InvitationPage(components.InvitationPageData{
+		Token:            r.PathValue("token"),
+		OrganizationName: invitation.OrganizationName,
+		Role:             invitation.Role,
+		Email:            invitation.Email,
+		UserEmail:        user.Email,
+	}).Render(ctx, w))
+}
+
+// AcceptInvitation adds the user to the organization of an emailed invitation
+func (h *Handler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+	token := r.PathValue("token")
+
+	invitation, err := h.services.AcceptOrganizationInvitation(ctx, user.UserID, user.Email, token)
+	if err != nil {
+		l.Error("Failed to accept invitation", slog.Any("error", err))
+		data := components.InvitationPageData{Error: "This invitation is invalid or has expired."}
+		if pending, getErr := h.services.GetOrganizationInvitation(ctx, token); getErr == nil {
+			data = components.InvitationPageData{
+				Token:            token,
+				OrganizationName: pending.OrganizationName,
+				Role:             pending.Role,
+				Email:            pending.Email,
+				UserEmail:        user.Email,
+				Error:            err.Error(),
+			}
+		}
+		lo.Must0(components.InvitationPage(data).Render(ctx, w))
+		return
+	}
+
+	l.Info("Organization invitation accepted",
+		slog.String("organization_id", invitation.OrganizationID),
+		slog.String("user_id", user.UserID),
+		slog.String("role", invitation.Role))
+
+	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
+
+// TransferInstance moves an instance to another organization via HTMX
+func (h *Handler) TransferInstance(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+	instanceID := r.PathValue("id")
+	targetID := r.FormValue("organization_id")
+
+	if _, err := h.services.TransferInstance(ctx, user.Membership, instanceID, targetID); err != nil {
+		l.Error("Failed to transfer instance", slog.Any("error", err))
+
+		targets, listErr := h.transferTargets(ctx, user)
+		if listErr != nil {
+			l.Error("Failed to list organizations", slog.Any("error", listErr))
+			http.Error(w, "Failed to transfer instance", http.StatusInternalServerError)
+			return
+		}
+		lo.Must0(components.InstanceTransferForm(components.InstanceTransferData{
+			InstanceID: instanceID,
+			Targets:    targets,
+			Error:      err.Error(),
+		}).Render(ctx, w))
+		return
+	}
+
+	l.Info("Instance transferred",
+		slog.String("instance_id", instanceID),
+		slog.String("from_organization_id", user.Membership.OrganizationID),
+		slog.String("to_organization_id", targetID),
+		slog.String("user_id", user.UserID))
+
+	w.Header().Set("HX-Redirect", "/dashboard")
+}
+
+// transferTargets lists the other organizations where the user can receive instances
+func (h *Handler) transferTargets(ctx context.Context, user *AuthUser) ([]components.OrganizationView, error) {
+	orgs, err := h.services.ListUserOrganizations(ctx, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []components.OrganizationView
+	for _, org := range orgs {
+		member := services.Membership{OrganizationID: org.ID, UserID: user.UserID, Role: org.Role}
+		if org.ID != user.Membership.OrganizationID && member.Can(services.RoleAdmin) {
+			targets = append(targets, toOrganizationView(org))
+		}
+	}
+	return targets, nil
+}
+
+func toOrganizationView(org services.Organization) components.OrganizationView {
+	return components.OrganizationView{
+		ID:       org.ID,
+		Name:     org.Name,
+		Personal: org.Personal,
+		Role:     org.Role,
+	}
+}
@@ -2,32 +2,31 @@ package handler
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
 	"net/http"
-	"net/http/httputil"
 	"strings"
 	"time"
 
 	"github.com/aliuygur/n8n-saas-api/internal/appctx"
 	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/handler/components"
 	"github.com/aliuygur/n8n-saas-api/internal/services"
 )
 
 // ProxyHandler proxies requests to n8n instances based on subdomain
 // Extracts subdomain from Host header (e.g., subdomain.n8n.ranx.cloud)
 // Queries instances table to find namespace
-// Forwards request to http://n8n-main.{namespace}.svc.cluster.local
+// Forwards request to http://n8n-main.{namespace}.svc.cluster.local through a pooled per-namespace proxy
 func (h *Handler) ProxyHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	l := appctx.GetLogger(ctx)
 
 	// Extract subdomain and get instance (with caching)
-	instance, subdomain, err := h.resolveTenant(ctx, r.Host)
+	tenant, subdomain, err := h.resolveTenant(ctx, r.Host)
 	if err != nil {
 		if ok := apperrs.CodeIs(err, apperrs.CodeNotFound); ok {
 			l.Warn("Instance not found", slog.String("subdomain", subdomain))
-			http.Error(w, "Instance not found", http.StatusNotFound)
+			h.writeInstanceUnavailable(w, r, components.InstanceUnavailableNotFound)
 			return
 		}
 		l.Error("Failed to get instance", slog.String("subdomain", subdomain), slog.Any("error", err))
@@ -35,76 +34,142 @@ func (h *Handler) ProxyHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Build target host
-	targetHost := fmt.Sprintf("n8n-main.%s.svc.cluster.local", instance.Namespace)
+	instance, limits := tenant.instance, tenant.limits
+
+	// Enforce plan limits before touching the upstream
+	if limits.MaxBodyBytes > 0 {
+		if r.ContentLength > limits.MaxBodyBytes {
+			l.Warn("Request body too large",
+				slog.String("subdomain", subdomain),
+				slog.Int64("content_length", r.ContentLength))
+			http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = http.MaxBytesReader(w, r.Body, limits.MaxBodyBytes)
+		}
+	}
+
+	// The client's own bucket comes first, so a client over its limit can't spend the tokens of everyone else
+	now := time.Now()
+	ip := clientIP(r, h.trustedProxies)
+	if ok, retryAfter := h.proxyLimiter.allow("ip:"+instance.ID+":"+ip, limits.IPRequestsPerSecond, limits.IPBurst, now); !ok {
+		l.Warn("Client IP rate limit exceeded", slog.String("subdomain", subdomain), slog.String("client_ip", ip))
+		writeTooManyRequests(w, retryAfter)
+		return
+	}
+
+	if ok, retryAfter := h.proxyLimiter.allow("instance:"+instance.ID, limits.RequestsPerSecond, limits.Burst, now); !ok {
+		l.Warn("Instance rate limit exceeded", slog.String("subdomain", subdomain))
+		writeTooManyRequests(w, retryAfter)
+		return
+	}
+
+	if !h.authorizeTenantRequest(w, r, tenant) {
+		return
+	}
+
+	// Streams take a slot for as long as they are open, like any other request
+	release, ok := h.proxyLimiter.acquire(instance.ID, limits.MaxConcurrent)
+	if !ok {
+		l.Warn("Instance concurrency limit exceeded", slog.String("subdomain", subdomain))
+		writeTooManyRequests(w, time.Second)
+		return
+	}
+	defer release()
+
+	switch instance.Status {
+	case services.InstanceStatusPending:
+		h.writeInstanceUnavailable(w, r, components.InstanceUnavailableStarting)
+		return
+	case services.InstanceStatusFailed:
+		h.writeInstanceUnavailable(w, r, components.InstanceUnavailableCrashed)
+		return
+	case services.InstanceStatusHibernated:
+		h.writeInstanceUnavailable(w, r, components.InstanceUnavailableHibernated)
+		return
+	}
+
+	// Skip dialing a pod that keeps failing until its cooldown passes
+	upstream := h.upstream(instance.Namespace, now)
+	if ok, reason := upstream.available(now); !ok {
+		l.Warn("Upstream unhealthy",
+			slog.String("subdomain", subdomain),
+			slog.String("namespace", instance.Namespace),
+			slog.String("reason", reason))
+		h.writeInstanceUnavailable(w, r, reason)
+		return
+	}
 
 	l.Info("Proxying request",
 		slog.String("subdomain", subdomain),
 		slog.String("namespace", instance.Namespace),
 		slog.String("path", r.URL.Path),
-		slog.String("query", r.URL.RawQuery))
-
-	// Create reverse proxy
-	proxy := &httputil.ReverseProxy{
-		Director: func(req *http.Request) {
-			req.URL.Scheme = "http"
-			req.URL.Host = targetHost
-			req.Header.Set("X-Forwarded-Host", req.Host)
-			req.Header.Set("X-Forwarded-Proto", "https")
-		},
-		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
-			l.Error("Proxy error",
-				slog.String("subdomain", subdomain),
-				slog.String("target_host", targetHost),
-				slog.Any("error", err))
-			http.Error(w, "Bad Gateway", http.StatusBadGateway)
-		},
-		FlushInterval: -1, // WebSocket support
-	}
+		slog.String("query", r.URL.RawQuery),
+		slog.Bool("websocket", isWebSocketUpgrade(r)))
 
-	proxy.ServeHTTP(w, r)
+	ctx = withStreamStarted(ctx, func() {
+		h.setDeadlines(w, r, 0)
+	})
+	upstream.proxy.ServeHTTP(w, r.WithContext(ctx))
 }
 
-// resolveTenant extracts subdomain from host and retrieves the instance
+// resolveTenant extracts subdomain from host and retrieves the instance with its proxy limits and access policy
 // Example: ali.n8n.ranx.cloud -> ali
-// Uses in-memory cache with TTL to reduce database queries
-func (h *Handler) resolveTenant(ctx context.Context, host string) (*services.Instance, string, error) {
-	// Remove port if present
-	if idx := strings.Index(host, ":"); idx != -1 {
-		host = host[:idx]
-	}
-
-	parts := strings.Split(host, ".")
-	subdomain := parts[0]
-
+// Uses in-memory cache with TTL to reduce database queries, including misses for unknown subdomains
+func (h *Handler) resolveTenant(ctx context.Context, host string) (*instanceCacheEntry, string, error) {
+	subdomain := subdomainFromHost(host)
 	if subdomain == "" {
 		return nil, "", apperrs.Client(apperrs.CodeInvalidInput, "invalid host format")
 	}
 
 	// Check cache first
-	if cached, ok := h.instanceCache.Load(subdomain); ok {
-		if entry, ok := cached.(*instanceCacheEntry); ok {
-			// Check if cache entry is still valid
-			if time.Now().Before(entry.expiresAt) {
-				return entry.instance, subdomain, nil
-			}
-			// Cache expired, remove it
-			h.instanceCache.Delete(subdomain)
+	now := time.Now()
+	if entry, ok := h.tenantCache.get(subdomain, now); ok {
+		if entry.instance == nil {
+			return nil, subdomain, apperrs.Client(apperrs.CodeNotFound, "instance not found")
 		}
+		return entry, subdomain, nil
 	}
 
 	// Cache miss or expired - fetch from database
+	generation := h.tenantCache.generation.Load()
+
 	instance, err := h.services.GetInstanceBySubdomain(ctx, subdomain)
+	if err != nil {
+		if apperrs.CodeIs(err, apperrs.CodeNotFound) {
+			h.tenantCache.store(subdomain, &instanceCacheEntry{}, generation, now)
+		}
+		return nil, subdomain, err
+	}
+
+	limits, err := h.services.GetInstanceProxyLimits(ctx, instance)
+	if err != nil {
+		return nil, subdomain, err
+	}
+
+	policy, err := h.services.GetInstanceAccessPolicy(ctx, instance.ID)
 	if err != nil {
 		return nil, subdomain, err
 	}
 
-	// Store in cache with expiration
 	entry := &instanceCacheEntry{
-		instance:  instance,
-		expiresAt: time.Now().Add(instanceCacheTTL),
+		instance: instance,
+		limits:   limits,
+		policy:   policy,
+	}
+	h.tenantCache.store(subdomain, entry, generation, now)
+
+	return entry, subdomain, nil
+}
+
+// subdomainFromHost returns the first label of a host, ignoring any port
+func subdomainFromHost(host string) string {
+	// Remove port if present
+	if idx := strings.Index(host, ":"); idx != -1 {
+		host = host[:idx]
 	}
-	h.instanceCache.Store(subdomain, entry)
 
-	return instance, subdomain, nil
+	subdomain, _, _ := strings.Cut(host, ".")
+	return subdomain
 }
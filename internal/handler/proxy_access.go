@@ -0,0 +1,392 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/services"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/samber/lo"
+)
+
+const (
+	// gateCookieName is the host-only cookie set on instance subdomains after ranx login
+	gateCookieName = "ranx_gate"
+	// gateCookieTTL defines how long a ranx login to an instance editor lasts
+	gateCookieTTL = 12 * time.Hour
+	// gateExchangeTTL defines how long the token handed from the dashboard to the proxy is valid
+	gateExchangeTTL = time.Minute
+	// gateExchangePath is the tenant path where the proxy exchanges the token for a cookie
+	gateExchangePath = "/__ranx/sso"
+	// editorLoginPath is the tenant path where the proxy exchanges an editor login token for an n8n session
+	editorLoginPath = "/__ranx/editor"
+	// gateSessionCheckInterval bounds how long a gate cookie keeps working after its dashboard session ended
+	gateSessionCheckInterval = 30 * time.Second
+)
+
+// Gate token purposes
+const (
+	gatePurposeExchange = "gate_exchange"
+	gatePurposeSession  = "gate_session"
+)
+
+// gateClaims grants a browser access to an instance protected by ranx login
+type gateClaims struct {
+	InstanceID string `json:"instance_id"`
+	UserID     string `json:"user_id"`
+	SessionID  string `json:"session_id"` // The dashboard session, access ends with it
+	Purpose    string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// tenantAccessStore is the part of the services the ranx login of instances depends on, replaced in tests
+type tenantAccessStore interface {
+	ConsumeEditorLoginToken(ctx context.Context, instanceID, token string) (*services.EditorLogin, error)
+	ConsumeGateExchangeToken(ctx context.Context, instanceID, id string) (bool, error)
+	ValidateGateSession(ctx context.Context, sessionID, userID string, instance *services.Instance) (bool, error)
+}
+
+// gateSessionCache remembers gate cookies whose session was recently found valid,
+// so the proxy doesn't query the database on every request
+type gateSessionCache struct {
+	entries sync.Map // session ID + instance ID -> time.Time the check expires
+}
+
+func (c *gateSessionCache) valid(key string, now time.Time) bool {
+	expiresAt, ok := c.entries.Load(key)
+	return ok && now.Before(expiresAt.(time.Time))
+}
+
+func (c *gateSessionCache) store(key string, now time.Time) {
+	c.entries.Store(key, now.Add(gateSessionCheckInterval))
+}
+
+// cleanup removes expired checks
+func (c *gateSessionCache) cleanup(now time.Time) {
+	c.entries.Range(func(key, value any) bool {
+		if !now.Before(value.(time.Time)) {
+			c.entries.Delete(key)
+		}
+		return true
+	})
+}
+
+// authorizeTenantRequest enforces the instance access policy.
+// It writes the response and returns false when the request must not be proxied.
+func (h *Handler) authorizeTenantRequest(w http.ResponseWriter, r *http.Request, tenant *instanceCacheEntry) bool {
+	l := appctx.GetLogger(r.Context())
+	policy := tenant.policy
+	instance := tenant.instance
+
+	// Until ranx created the n8n owner, whoever calls the setup endpoint first would own the instance
+	if instance.OwnerSetupAt == nil && services.IsOwnerSetupPath(r.URL.Path) {
+		l.Warn("Blocked n8n owner setup", slog.String("instance_id", instance.ID))
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+
+	// Editor login works with every auth mode, the token proves the dashboard session
+	if r.URL.Path == editorLoginPath {
+		if !policy.AllowsIP(clientIP(r, h.trustedProxies)) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return false
+		}
+		h.exchangeEditorToken(w, r, tenant)
+		return false
+	}
+
+	if policy.IsOpen() || policy.IsPublicPath(r.URL.Path) {
+		return true
+	}
+
+	ip := clientIP(r, h.trustedProxies)
+	if !policy.AllowsIP(ip) {
+		l.Warn("Client IP not in instance allowlist",
+			slog.String("instance_id", instance.ID),
+			slog.String("client_ip", ip))
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+
+	switch policy.AuthMode {
+	case services.AccessAuthBasic:
+		username, password, ok := r.BasicAuth()
+		if !ok || !policy.CheckBasicAuth(username, password) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="`+h.config.Routing.InstanceHostname(instance.Subdomain)+`", charset="UTF-8"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return false
+		}
+		// Don't leak the gate credentials to n8n
+		r.Header.Del("Authorization")
+		return true
+
+	case services.AccessAuthRanx:
+		if r.URL.Path == gateExchangePath {
+			h.exchangeGateToken(w, r, instance)
+			return false
+		}
+
+		if cookie, err := r.Cookie(gateCookieName); err == nil {
+			claims, err := h.parseGateToken(cookie.Value, gatePurposeSession)
+			if err == nil && claims.InstanceID == instance.ID && h.gateSessionValid(r, claims, instance) {
+				stripCookie(r, gateCookieName)
+				return true
+			}
+		}
+
+		// Send the browser to the dashboard to prove it is logged in
+		ssoURL := h.config.Server.BaseURL("/instances/" + instance.ID + "/sso?return_to=" + url.QueryEscape(r.URL.RequestURI()))
+		http.Redirect(w, r, ssoURL, http.StatusSeeOther)
+		return false
+	}
+
+	return true
+}
+
+// gateSessionValid checks that the dashboard session a gate cookie was issued for
+// still belongs to a member of the organization that owns the instance
+func (h *Handler) gateSessionValid(r *http.Request, claims *gateClaims, instance *services.Instance) bool {
+	// Cookies issued before they were bound to a session are no longer accepted
+	if claims.SessionID == "" {
+		return false
+	}
+
+	now := time.Now()
+	key := claims.SessionID + ":" + instance.ID
+	if h.gateSessions.valid(key, now) {
+		return true
+	}
+
+	valid, err := h.access.ValidateGateSession(r.Context(), claims.SessionID, claims.UserID, instance)
+	if err != nil {
+		appctx.GetLogger(r.Context()).Error("Failed to validate gate session", slog.String("instance_id", instance.ID), slog.Any("error", err))
+		return false
+	}
+	if valid {
+		h.gateSessions.store(key, now)
+	}
+	return valid
+}
+
+// exchangeGateToken validates a single use, one-minute token issued by InstanceSSO and sets the gate cookie
+func (h *Handler) exchangeGateToken(w http.ResponseWriter, r *http.Request, instance *services.Instance) {
+	l := appctx.GetLogger(r.Context())
+
+	claims, err := h.parseGateToken(r.URL.Query().Get("token"), gatePurposeExchange)
+	if err != nil || claims.InstanceID != instance.ID || claims.ID == "" {
+		l.Warn("Invalid gate exchange token", slog.String("instance_id", instance.ID), slog.Any("error", err))
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	// The URL can end up in the history, logs or a Referer, only its first use counts
+	consumed, err := h.access.ConsumeGateExchangeToken(r.Context(), instance.ID, claims.ID)
+	if err != nil {
+		l.Error("Failed to consume gate exchange token", slog.String("instance_id", instance.ID), slog.Any("error", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !consumed {
+		l.Warn("Reused gate exchange token", slog.String("instance_id", instance.ID))
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if err := h.setGateCookie(w, instance.ID, claims.UserID, claims.SessionID); err != nil {
+		l.Error("Failed to sign gate token", slog.Any("error", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, safeReturnPath(r.URL.Query().Get("return_to")), http.StatusSeeOther)
+}
+
+// setGateCookie lets the browser past the ranx login of an instance
+func (h *Handler) setGateCookie(w http.ResponseWriter, instanceID, userID, sessionID string) error {
+	sessionToken, err := h.signGateToken(instanceID, userID, sessionID, gatePurposeSession, gateCookieTTL)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     gateCookieName,
+		Value:    sessionToken,
+		Path:     "/",
+		MaxAge:   int(gateCookieTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// exchangeEditorToken validates a single use token issued by OpenInstanceEditor and sets the n8n session cookie
+func (h *Handler) exchangeEditorToken(w http.ResponseWriter, r *http.Request, tenant *instanceCacheEntry) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	instance := tenant.instance
+
+	login, err := h.access.ConsumeEditorLoginToken(ctx, instance.ID, r.URL.Query().Get("token"))
+	if login == nil {
+		l.Warn("Invalid editor login token", slog.String("instance_id", instance.ID), slog.Any("error", err))
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	// Spare the user a second trip through the dashboard on instances behind ranx login
+	if tenant.policy.AuthMode == services.AccessAuthRanx && login.SessionID != "" {
+		if err := h.setGateCookie(w, instance.ID, login.UserID, login.SessionID); err != nil {
+			l.Error("Failed to sign gate token", slog.Any("error", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err != nil {
+		// The n8n login page is still usable, so send the user on without a session
+		l.Error("Failed to log in to n8n editor", slog.String("instance_id", instance.ID), slog.Any("error", err))
+	} else {
+		http.SetCookie(w, &http.Cookie{
+			Name:     login.Cookie.Name,
+			Value:    login.Cookie.Value,
+			Path:     "/",
+			MaxAge:   login.Cookie.MaxAge,
+			Expires:  login.Cookie.Expires,
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
+	http.Redirect(w, r, safeReturnPath(login.ReturnTo), http.StatusSeeOther)
+}
+
+// OpenInstanceEditor logs the user into the n8n editor of an instance as its owner.
+// Members who can't manage the instance are sent to it without an n8n session.
+func (h *Handler) OpenInstanceEditor(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	instanceID := r.PathValue("id")
+	returnTo := safeReturnPath(r.URL.Query().Get("return_to"))
+
+	token, instance, err := h.services.CreateEditorLoginToken(ctx, user.Membership, user.SessionID, instanceID, returnTo)
+	if apperrs.CodeIs(err, apperrs.CodeForbidden) {
+		instance, err := h.services.GetMemberInstance(ctx, user.Membership, instanceID)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, instance.GetInstanceURL()+returnTo, http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		l.Error("Failed to create editor login token", slog.Any("error", err))
+		http.NotFound(w, r)
+		return
+	}
+
+	target := instance.GetInstanceURL() + editorLoginPath + "?" + url.Values{"token": {token}}.Encode()
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// InstanceSSO hands a logged-in member of the owning organization over to an instance protected by ranx login
+func (h *Handler) InstanceSSO(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	instance, err := h.services.GetMemberInstance(ctx, user.Membership, r.PathValue("id"))
+	if err != nil {
+		l.Error("Failed to get instance", slog.Any("error", err))
+		http.NotFound(w, r)
+		return
+	}
+
+	token, err := h.signGateExchangeToken(ctx, instance.ID, user.UserID, user.SessionID)
+	if err != nil {
+		l.Error("Failed to sign gate token", slog.Any("error", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	target := instance.GetInstanceURL() + gateExchangePath + "?" + url.Values{
+		"token":     {token},
+		"return_to": {safeReturnPath(r.URL.Query().Get("return_to"))},
+	}.Encode()
+
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// signGateExchangeToken signs a token for exchangeGateToken whose ID is stored so it can only be used once
+func (h *Handler) signGateExchangeToken(ctx context.Context, instanceID, userID, sessionID string) (string, error) {
+	expiresAt := time.Now().Add(gateExchangeTTL)
+	id, err := h.services.CreateGateExchangeToken(ctx, instanceID, expiresAt)
+	if err != nil {
+		return "", err
+	}
+	return h.signGateTokenWithID(id, instanceID, userID, sessionID, gatePurposeExchange, gateExchangeTTL)
+}
+
+func (h *Handler) signGateToken(instanceID, userID, sessionID, purpose string, ttl time.Duration) (string, error) {
+	return h.signGateTokenWithID("", instanceID, userID, sessionID, purpose, ttl)
+}
+
+func (h *Handler) signGateTokenWithID(id, instanceID, userID, sessionID, purpose string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := &gateClaims{
+		InstanceID: instanceID,
+		UserID:     userID,
+		SessionID:  sessionID,
+		Purpose:    purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    "ranx.cloud",
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(h.jwtSecret)
+}
+
+func (h *Handler) parseGateToken(tokenString, purpose string) (*gateClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &gateClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return h.jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*gateClaims)
+	if !ok || !token.Valid || claims.Purpose != purpose {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return claims, nil
+}
+
+// safeReturnPath only allows local absolute paths to avoid open redirects
+func safeReturnPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return "/"
+	}
+	return path
+}
+
+// stripCookie removes a cookie from the request before it is proxied
+func stripCookie(r *http.Request, name string) {
+	cookies := lo.Filter(r.Cookies(), func(c *http.Cookie, _ int) bool {
+		return c.Name != name
+	})
+	r.Header.Del("Cookie")
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+}
@@ -0,0 +1,333 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/config"
+	"github.com/aliuygur/n8n-saas-api/internal/services"
+)
+
+// fakeTenantAccessStore answers session checks from a set of valid session IDs,
+// editor login tokens from a map of logins and gate exchanges from a set of unused token IDs
+type fakeTenantAccessStore struct {
+	sessions map[string]bool
+	checks   int
+
+	logins   map[string]*services.EditorLogin
+	loginErr error // Returned along with the login, like a failed n8n login
+
+	exchanges map[string]string // Token ID -> instance ID
+}
+
+func (s *fakeTenantAccessStore) ConsumeEditorLoginToken(ctx context.Context, instanceID, token string) (*services.EditorLogin, error) {
+	login, ok := s.logins[token]
+	if !ok {
+		return nil, apperrs.Client(apperrs.CodeUnauthorized, "editor login link is invalid or has expired")
+	}
+	// Tokens are single use
+	delete(s.logins, token)
+	return login, s.loginErr
+}
+
+func (s *fakeTenantAccessStore) ConsumeGateExchangeToken(ctx context.Context, instanceID, id string) (bool, error) {
+	if s.exchanges[id] != instanceID {
+		return false, nil
+	}
+	delete(s.exchanges, id)
+	return true, nil
+}
+
+func (s *fakeTenantAccessStore) ValidateGateSession(ctx context.Context, sessionID, userID string, instance *services.Instance) (bool, error) {
+	s.checks++
+	return s.sessions[sessionID], nil
+}
+
+func newGateTestHandler(access tenantAccessStore) (*Handler, *instanceCacheEntry) {
+	h := &Handler{
+		jwtSecret: []byte("test-secret"),
+		config:    &config.Config{Server: config.ServerConfig{APIBaseURL: "https://app.ranx.test"}},
+		access:    access,
+	}
+	tenant := &instanceCacheEntry{
+		instance: &services.Instance{ID: "instance-1", Subdomain: "acme"},
+		policy:   &services.AccessPolicy{AuthMode: services.AccessAuthRanx},
+	}
+	return h, tenant
+}
+
+func gateRequest(t *testing.T, h *Handler, instanceID, sessionID string) *http.Request {
+	token, err := h.signGateToken(instanceID, "user-1", sessionID, gatePurposeSession, gateCookieTTL)
+	if err != nil {
+		t.Fatalf("Failed to sign gate token: %v", err)
+	}
+	req := withTestLogger(httptest.NewRequest(http.MethodGet, "/workflows", nil))
+	req.AddCookie(&http.Cookie{Name: gateCookieName, Value: token})
+	return req
+}
+
+func TestAuthorizeTenantRequestGateSession(t *testing.T) {
+	tests := []struct {
+		name       string
+		instanceID string
+		sessionID  string
+		want       bool
+	}{
+		{name: "active session", instanceID: "instance-1", sessionID: "session-1", want: true},
+		{name: "revoked session", instanceID: "instance-1", sessionID: "session-2", want: false},
+		{name: "cookie without session", instanceID: "instance-1", sessionID: "", want: false},
+		{name: "cookie of another instance", instanceID: "instance-2", sessionID: "session-1", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			access := &fakeTenantAccessStore{sessions: map[string]bool{"session-1": true}}
+			h, tenant := newGateTestHandler(access)
+
+			w := httptest.NewRecorder()
+			got := h.authorizeTenantRequest(w, gateRequest(t, h, tt.instanceID, tt.sessionID), tenant)
+			if got != tt.want {
+				t.Fatalf("Expected authorized %v, got %v", tt.want, got)
+			}
+			if !got && w.Code != http.StatusSeeOther {
+				t.Errorf("Expected a redirect to the dashboard, got %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestAuthorizeTenantRequestCachesGateSession(t *testing.T) {
+	access := &fakeTenantAccessStore{sessions: map[string]bool{"session-1": true}}
+	h, tenant := newGateTestHandler(access)
+
+	for range 3 {
+		if !h.authorizeTenantRequest(httptest.NewRecorder(), gateRequest(t, h, "instance-1", "session-1"), tenant) {
+			t.Fatal("Expected the gate cookie to be accepted")
+		}
+	}
+	if access.checks != 1 {
+		t.Errorf("Expected the session to be checked once, got %d checks", access.checks)
+	}
+
+	// A revoked session keeps being checked, so it doesn't get cached
+	for range 2 {
+		h.authorizeTenantRequest(httptest.NewRecorder(), gateRequest(t, h, "instance-1", "session-2"), tenant)
+	}
+	if access.checks != 3 {
+		t.Errorf("Expected revoked sessions to be checked on every request, got %d checks", access.checks)
+	}
+}
+
+func TestAuthorizeTenantRequestBlocksOwnerSetup(t *testing.T) {
+	setupAt := time.Now()
+
+	tests := []struct {
+		name         string
+		path         string
+		ownerSetupAt *time.Time
+		want         bool
+	}{
+		{name: "owner setup", path: "/rest/owner/setup", want: false},
+		{name: "trailing slash", path: "/rest/owner/setup/", want: false},
+		{name: "other case", path: "/REST/Owner/Setup", want: false},
+		{name: "extra slashes", path: "//rest/./owner//setup", want: false},
+		{name: "other n8n endpoint", path: "/rest/login", want: true},
+		{name: "owner set up by ranx", path: "/rest/owner/setup", ownerSetupAt: &setupAt, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{}
+			tenant := &instanceCacheEntry{
+				instance: &services.Instance{ID: "instance-1", Subdomain: "acme", OwnerSetupAt: tt.ownerSetupAt},
+				policy:   &services.AccessPolicy{AuthMode: services.AccessAuthNone},
+			}
+			req := withTestLogger(httptest.NewRequest(http.MethodPost, "/", nil))
+			req.URL.Path = tt.path
+
+			w := httptest.NewRecorder()
+			if got := h.authorizeTenantRequest(w, req, tenant); got != tt.want {
+				t.Fatalf("Expected authorized %v, got %v", tt.want, got)
+			}
+			if !tt.want && w.Code != http.StatusForbidden {
+				t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+			}
+		})
+	}
+}
+
+func TestExchangeEditorToken(t *testing.T) {
+	n8nCookie := &http.Cookie{Name: "n8n-auth", Value: "n8n-session", MaxAge: 3600}
+
+	tests := []struct {
+		name          string
+		authMode      string
+		login         *services.EditorLogin
+		loginErr      error
+		wantStatus    int
+		wantLocation  string
+		wantN8NCookie bool
+		wantGate      bool
+	}{
+		{
+			name:          "open instance",
+			authMode:      services.AccessAuthNone,
+			login:         &services.EditorLogin{UserID: "user-1", SessionID: "session-1", ReturnTo: "/workflow/1", Cookie: n8nCookie},
+			wantStatus:    http.StatusSeeOther,
+			wantLocation:  "/workflow/1",
+			wantN8NCookie: true,
+		},
+		{
+			name:          "instance behind ranx login",
+			authMode:      services.AccessAuthRanx,
+			login:         &services.EditorLogin{UserID: "user-1", SessionID: "session-1", ReturnTo: "/", Cookie: n8nCookie},
+			wantStatus:    http.StatusSeeOther,
+			wantLocation:  "/",
+			wantN8NCookie: true,
+			wantGate:      true,
+		},
+		{
+			name:         "token without a session gets no gate cookie",
+			authMode:     services.AccessAuthRanx,
+			login:        &services.EditorLogin{UserID: "user-1", ReturnTo: "/", Cookie: n8nCookie},
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "/",
+			// The n8n session is still set, the gate sends the browser through the dashboard
+			wantN8NCookie: true,
+		},
+		{
+			name:         "n8n login failed",
+			authMode:     services.AccessAuthNone,
+			login:        &services.EditorLogin{UserID: "user-1", SessionID: "session-1", ReturnTo: "/"},
+			loginErr:     errors.New("n8n login returned 401"),
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "/",
+		},
+		{
+			name:       "unknown token",
+			authMode:   services.AccessAuthNone,
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			access := &fakeTenantAccessStore{logins: map[string]*services.EditorLogin{}, loginErr: tt.loginErr}
+			if tt.login != nil {
+				access.logins["token-1"] = tt.login
+			}
+			h, tenant := newGateTestHandler(access)
+			tenant.policy = &services.AccessPolicy{AuthMode: tt.authMode}
+
+			req := withTestLogger(httptest.NewRequest(http.MethodGet, editorLoginPath+"?token=token-1", nil))
+			w := httptest.NewRecorder()
+			if h.authorizeTenantRequest(w, req, tenant) {
+				t.Fatal("Expected the proxy to answer the editor login itself")
+			}
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if got := w.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Expected redirect to %q, got %q", tt.wantLocation, got)
+			}
+
+			cookies := map[string]*http.Cookie{}
+			for _, c := range w.Result().Cookies() {
+				cookies[c.Name] = c
+			}
+			if got := cookies["n8n-auth"]; (got != nil) != tt.wantN8NCookie || (got != nil && (got.Value != "n8n-session" || !got.HttpOnly || !got.Secure)) {
+				t.Errorf("Expected n8n session cookie %v, got %+v", tt.wantN8NCookie, got)
+			}
+			gate := cookies[gateCookieName]
+			if (gate != nil) != tt.wantGate {
+				t.Fatalf("Expected gate cookie %v, got %+v", tt.wantGate, gate)
+			}
+			if gate != nil {
+				claims, err := h.parseGateToken(gate.Value, gatePurposeSession)
+				if err != nil || claims.InstanceID != "instance-1" || claims.SessionID != "session-1" {
+					t.Errorf("Expected a gate cookie for the instance and session, got %+v %v", claims, err)
+				}
+			}
+
+			// The token is gone after the first exchange
+			if tt.login != nil {
+				w := httptest.NewRecorder()
+				h.authorizeTenantRequest(w, withTestLogger(httptest.NewRequest(http.MethodGet, editorLoginPath+"?token=token-1", nil)), tenant)
+				if w.Code != http.StatusForbidden {
+					t.Errorf("Expected a reused token to be rejected, got %d", w.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestExchangeGateToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		tokenID    string
+		instanceID string
+		purpose    string
+		wantStatus int
+	}{
+		{name: "exchanged", tokenID: "exchange-1", instanceID: "instance-1", purpose: gatePurposeExchange, wantStatus: http.StatusSeeOther},
+		{name: "unknown token ID", tokenID: "exchange-2", instanceID: "instance-1", purpose: gatePurposeExchange, wantStatus: http.StatusForbidden},
+		{name: "token without an ID", instanceID: "instance-1", purpose: gatePurposeExchange, wantStatus: http.StatusForbidden},
+		{name: "other instance", tokenID: "exchange-1", instanceID: "instance-2", purpose: gatePurposeExchange, wantStatus: http.StatusForbidden},
+		{name: "session token", tokenID: "exchange-1", instanceID: "instance-1", purpose: gatePurposeSession, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			access := &fakeTenantAccessStore{exchanges: map[string]string{"exchange-1": "instance-1"}}
+			h, tenant := newGateTestHandler(access)
+
+			token, err := h.signGateTokenWithID(tt.tokenID, tt.instanceID, "user-1", "session-1", tt.purpose, gateExchangeTTL)
+			if err != nil {
+				t.Fatalf("Failed to sign gate token: %v", err)
+			}
+			exchange := func() *httptest.ResponseRecorder {
+				target := gateExchangePath + "?" + url.Values{"token": {token}, "return_to": {"/workflow/1"}}.Encode()
+				w := httptest.NewRecorder()
+				if h.authorizeTenantRequest(w, withTestLogger(httptest.NewRequest(http.MethodGet, target, nil)), tenant) {
+					t.Fatal("Expected the proxy to answer the exchange itself")
+				}
+				return w
+			}
+
+			w := exchange()
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantStatus != http.StatusSeeOther {
+				return
+			}
+			if got := w.Header().Get("Location"); got != "/workflow/1" {
+				t.Errorf("Expected redirect to /workflow/1, got %q", got)
+			}
+			var gate *http.Cookie
+			for _, c := range w.Result().Cookies() {
+				if c.Name == gateCookieName {
+					gate = c
+				}
+			}
+			if gate == nil {
+				t.Fatal("Expected a gate cookie")
+			}
+			if claims, err := h.parseGateToken(gate.Value, gatePurposeSession); err != nil || claims.SessionID != "session-1" {
+				t.Errorf("Expected a gate cookie for the session, got %+v %v", claims, err)
+			}
+
+			// A replayed URL gets nothing
+			if w := exchange(); w.Code != http.StatusForbidden || len(w.Result().Cookies()) != 0 {
+				t.Errorf("Expected the second exchange to be rejected, got %d", w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// limiterIdleTTL defines how long an unused token bucket is kept before cleanup
+const limiterIdleTTL = 10 * time.Minute
+
+// limiterEntry is a token bucket with the last time it was used
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen atomic.Int64
+}
+
+// proxyLimiter enforces rate limits and concurrency caps on proxied tenant traffic.
+// Buckets are keyed by instance and by instance + client IP.
+type proxyLimiter struct {
+	buckets  sync.Map // key -> *limiterEntry
+	inflight sync.Map // instance ID -> *atomic.Int64
+}
+
+// allow takes a token from the bucket identified by key.
+// When the bucket is empty it returns false and how long the caller should wait.
+func (p *proxyLimiter) allow(key string, rps, burst int, now time.Time) (bool, time.Duration) {
+	if rps <= 0 {
+		return true, 0
+	}
+
+	value, ok := p.buckets.Load(key)
+	if !ok {
+		entry := &limiterEntry{limiter: rate.NewLimiter(rate.Limit(rps), burst)}
+		value, _ = p.buckets.LoadOrStore(key, entry)
+	}
+	entry := value.(*limiterEntry)
+	entry.lastSeen.Store(now.UnixNano())
+
+	// Limits may change when the instance cache is refreshed
+	if entry.limiter.Limit() != rate.Limit(rps) {
+		entry.limiter.SetLimitAt(now, rate.Limit(rps))
+	}
+	if entry.limiter.Burst() != burst {
+		entry.limiter.SetBurstAt(now, burst)
+	}
+
+	reservation := entry.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return false, time.Second
+	}
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// acquire reserves one of max concurrent request slots for an instance.
+// The returned release func must be called when the request finishes.
+func (p *proxyLimiter) acquire(instanceID string, max int) (func(), bool) {
+	if max <= 0 {
+		return func() {}, true
+	}
+
+	value, _ := p.inflight.LoadOrStore(instanceID, new(atomic.Int64))
+	counter := value.(*atomic.Int64)
+
+	if counter.Add(1) > int64(max) {
+		counter.Add(-1)
+		return nil, false
+	}
+	return func() { counter.Add(-1) }, true
+}
+
+// cleanup removes token buckets that have not been used for limiterIdleTTL
+func (p *proxyLimiter) cleanup(now time.Time) {
+	p.buckets.Range(func(key, value any) bool {
+		if entry, ok := value.(*limiterEntry); ok {
+			if now.Sub(time.Unix(0, entry.lastSeen.Load())) > limiterIdleTTL {
+				p.buckets.Delete(key)
+			}
+		}
+		return true
+	})
+	p.inflight.Range(func(key, value any) bool {
+		if counter, ok := value.(*atomic.Int64); ok && counter.Load() == 0 {
+			p.inflight.Delete(key)
+		}
+		return true
+	})
+}
+
+// writeTooManyRequests responds with 429 and a Retry-After header in whole seconds
+func writeTooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+}
+
+// clientIP returns the IP of the client that originated the request.
+// Tenant traffic arrives through the Cloudflare tunnel, which sets CF-Connecting-IP.
+// Forwarding headers are only believed when the connection comes from a trusted proxy,
+// anyone else could send them to dodge allowlists and rate limits.
+func clientIP(r *http.Request, trusted []netip.Prefix) string {
+	remote := remoteIP(r)
+	if !isTrustedProxy(remote, trusted) {
+		return remote
+	}
+
+	if ip := strings.TrimSpace(r.Header.Get("CF-Connecting-IP")); ip != "" {
+		return ip
+	}
+
+	// Proxies append the address they saw, so the rightmost untrusted entry is the client
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !isTrustedProxy(hop, trusted) {
+			return hop
+		}
+	}
+	return remote
+}
+
+// remoteIP returns the IP of the peer of the connection
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func isTrustedProxy(ip string, trusted []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/config"
+	"github.com/aliuygur/n8n-saas-api/internal/services"
+)
+
+func TestProxyLimiter_Allow(t *testing.T) {
+	var p proxyLimiter
+	now := time.Now()
+
+	// Burst of 2 allows two requests immediately
+	for i := 0; i < 2; i++ {
+		if ok, _ := p.allow("instance:a", 1, 2, now); !ok {
+			t.Fatalf("Expected request %d to be allowed", i+1)
+		}
+	}
+
+	// Third request exceeds the bucket
+	ok, retryAfter := p.allow("instance:a", 1, 2, now)
+	if ok {
+		t.Fatal("Expected request to be rate limited")
+	}
+	if retryAfter <= 0 || retryAfter > time.Second {
+		t.Errorf("Expected retry after within 1s, got %s", retryAfter)
+	}
+
+	// Other keys have their own bucket
+	if ok, _ := p.allow("instance:b", 1, 2, now); !ok {
+		t.Error("Expected a different key to be allowed")
+	}
+
+	// Bucket refills over time
+	if ok, _ := p.allow("instance:a", 1, 2, now.Add(time.Second)); !ok {
+		t.Error("Expected request to be allowed after refill")
+	}
+}
+
+func TestProxyLimiter_Acquire(t *testing.T) {
+	var p proxyLimiter
+
+	release1, ok := p.acquire("a", 2)
+	if !ok {
+		t.Fatal("Expected first slot to be acquired")
+	}
+	if _, ok := p.acquire("a", 2); !ok {
+		t.Fatal("Expected second slot to be acquired")
+	}
+	if _, ok := p.acquire("a", 2); ok {
+		t.Fatal("Expected third slot to be rejected")
+	}
+
+	release1()
+	if _, ok := p.acquire("a", 2); !ok {
+		t.Error("Expected slot to be available after release")
+	}
+
+	// Zero means unlimited
+	if _, ok := p.acquire("b", 0); !ok {
+		t.Error("Expected unlimited concurrency when max is 0")
+	}
+}
+
+func TestWriteTooManyRequests(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeTooManyRequests(w, 1500*time.Millisecond)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status code %d, got %d", http.StatusTooManyRequests, w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Expected Retry-After 2, got %q", got)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		trusted    []netip.Prefix
+		want       string
+	}{
+		{"remote address", "10.0.0.1:1234", nil, trusted, "10.0.0.1"},
+		{"forwarded by trusted proxy", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "203.0.113.7"}, trusted, "203.0.113.7"},
+		{"rightmost untrusted hop", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.1, 203.0.113.7, 10.0.0.2"}, trusted, "203.0.113.7"},
+		{"only trusted hops", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, trusted, "10.0.0.1"},
+		{"cloudflare header from trusted proxy", "10.0.0.1:1234", map[string]string{"CF-Connecting-IP": "198.51.100.9", "X-Forwarded-For": "203.0.113.7"}, trusted, "198.51.100.9"},
+		{"forwarded by untrusted client", "203.0.113.50:1234", map[string]string{"X-Forwarded-For": "10.1.2.3"}, trusted, "203.0.113.50"},
+		{"cloudflare header from untrusted client", "203.0.113.50:1234", map[string]string{"CF-Connecting-IP": "10.1.2.3"}, trusted, "203.0.113.50"},
+		{"no trusted proxies configured", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "203.0.113.7"}, nil, "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if got := clientIP(req, tt.trusted); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// newProxyTestHandler returns a handler that proxies acme.ranx.test with limits to upstream
+func newProxyTestHandler(t *testing.T, limits services.ProxyLimits, upstream http.HandlerFunc) *Handler {
+	t.Helper()
+
+	server := httptest.NewServer(upstream)
+	t.Cleanup(server.Close)
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &Handler{config: &config.Config{Server: config.ServerConfig{ProxyIdleTimeout: time.Minute}}}
+	instance := &services.Instance{ID: "instance-1", Subdomain: "acme", Namespace: "n8n-acme", Status: services.InstanceStatusDeployed}
+	h.tenantCache.store("acme", &instanceCacheEntry{
+		instance: instance,
+		limits:   &limits,
+		policy:   &services.AccessPolicy{AuthMode: services.AccessAuthNone},
+	}, h.tenantCache.generation.Load(), time.Now())
+
+	u := h.upstream(instance.Namespace, time.Now())
+	director := u.proxy.Director
+	u.proxy.Director = func(req *http.Request) {
+		director(req)
+		req.URL.Host = target.Host
+	}
+	return h
+}
+
+func proxyTestRequest(method, remoteAddr string, body io.Reader) *http.Request {
+	req := withTestLogger(httptest.NewRequest(method, "http://acme.ranx.test/webhook/1", body))
+	req.RemoteAddr = remoteAddr
+	return req
+}
+
+func TestProxyHandlerIPLimitSparesOtherClients(t *testing.T) {
+	limits := services.ProxyLimits{RequestsPerSecond: 1, Burst: 3, IPRequestsPerSecond: 1, IPBurst: 2}
+	h := newProxyTestHandler(t, limits, func(w http.ResponseWriter, r *http.Request) {})
+
+	// The flood only spends the instance tokens its own bucket lets through
+	var passed int
+	for range 20 {
+		w := httptest.NewRecorder()
+		h.ProxyHandler(w, proxyTestRequest(http.MethodPost, "203.0.113.7:1234", nil))
+		if w.Code == http.StatusOK {
+			passed++
+		}
+	}
+	if passed != limits.IPBurst {
+		t.Fatalf("Expected %d requests of the flooding client to pass, got %d", limits.IPBurst, passed)
+	}
+
+	w := httptest.NewRecorder()
+	h.ProxyHandler(w, proxyTestRequest(http.MethodPost, "198.51.100.9:1234", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected another client to get through, got %d", w.Code)
+	}
+}
+
+func TestProxyHandlerBodyLimit(t *testing.T) {
+	const maxBody = 1024
+
+	tests := []struct {
+		name       string
+		size       int
+		chunked    bool
+		wantStatus int
+	}{
+		{name: "within the limit", size: maxBody, wantStatus: http.StatusOK},
+		{name: "declared too large", size: maxBody + 1, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "chunked within the limit", size: maxBody, chunked: true, wantStatus: http.StatusOK},
+		{name: "chunked too large", size: 64 * maxBody, chunked: true, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits := services.ProxyLimits{RequestsPerSecond: 100, Burst: 100, IPRequestsPerSecond: 100, IPBurst: 100, MaxBodyBytes: maxBody}
+			h := newProxyTestHandler(t, limits, func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.Copy(io.Discard, r.Body)
+			})
+
+			var body io.Reader = strings.NewReader(strings.Repeat("a", tt.size))
+			if tt.chunked {
+				// Hide the length, like a client streaming its body
+				body = io.MultiReader(body)
+			}
+			req := proxyTestRequest(http.MethodPost, "203.0.113.7:1234", body)
+			if tt.chunked {
+				req.ContentLength = -1
+				req.TransferEncoding = []string{"chunked"}
+			}
+
+			w := httptest.NewRecorder()
+			h.ProxyHandler(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+		})
+	}
+}
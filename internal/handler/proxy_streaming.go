@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"context"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// isWebSocketUpgrade reports whether the request asks to switch to the WebSocket protocol
+func isWebSocketUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isStreamingResponse reports whether the upstream answered with a connection that stays open
+// for pushed data, like the n8n editor's WebSocket push connection or server-sent events.
+// It goes by the response, so clients can't opt out of the proxy timeout with request headers.
+func isStreamingResponse(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
+
+// streamStartedKey holds the callback that lifts the proxy timeout once a stream starts
+type streamStartedKey struct{}
+
+// withStreamStarted returns a context that runs onStarted when the upstream answers with a stream
+func withStreamStarted(ctx context.Context, onStarted func()) context.Context {
+	return context.WithValue(ctx, streamStartedKey{}, onStarted)
+}
+
+// streamStarted runs the callback of withStreamStarted, if any
+func streamStarted(ctx context.Context) {
+	if onStarted, ok := ctx.Value(streamStartedKey{}).(func()); ok {
+		onStarted()
+	}
+}
+
+// setConnDeadlines sets the read and write deadline of the client connection.
+// A zero timeout clears them, because the server keeps deadlines across keep-alive requests.
+func setConnDeadlines(w http.ResponseWriter, timeout time.Duration) error {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	rc := http.NewResponseController(w)
+	if err := rc.SetReadDeadline(deadline); err != nil {
+		return err
+	}
+	return rc.SetWriteDeadline(deadline)
+}
+
+// idleTimeoutConn closes a connection once no data moved in either direction for timeout.
+// Upstream connections are wrapped so upgraded WebSockets can't hang forever.
+type idleTimeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *idleTimeoutConn) Read(b []byte) (int, error) {
+	if err := c.Conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *idleTimeoutConn) Write(b []byte) (int, error) {
+	if err := c.Conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(b)
+}
+
+// dialWithIdleTimeout wraps every connection made by dial in an idleTimeoutConn
+func dialWithIdleTimeout(dial func(ctx context.Context, network, addr string) (net.Conn, error), timeout time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &idleTimeoutConn{Conn: conn, timeout: timeout}, nil
+	}
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestIsStreamingResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		want        bool
+	}{
+		{"plain response", http.StatusOK, "application/json", false},
+		{"websocket upgrade", http.StatusSwitchingProtocols, "", true},
+		{"server-sent events", http.StatusOK, "text/event-stream", true},
+		{"server-sent events with charset", http.StatusOK, "text/event-stream; charset=utf-8", true},
+		{"no content type", http.StatusOK, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			if tt.contentType != "" {
+				resp.Header.Set("Content-Type", tt.contentType)
+			}
+			if got := isStreamingResponse(resp); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStreamStarted(t *testing.T) {
+	// Requests outside the proxy have no callback
+	streamStarted(context.Background())
+
+	var started bool
+	ctx := withStreamStarted(context.Background(), func() { started = true })
+	streamStarted(ctx)
+	if !started {
+		t.Error("Expected the callback to run")
+	}
+}
+
+func TestIdleTimeoutConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	conn := &idleTimeoutConn{Conn: server, timeout: 50 * time.Millisecond}
+
+	// Traffic keeps the connection alive
+	go func() {
+		_, _ = client.Write([]byte("ping"))
+	}()
+	buf := make([]byte, 4)
+	if _, err := conn.Read(buf); err != nil {
+		t.Fatalf("Expected read to succeed, got %v", err)
+	}
+
+	// No traffic closes it
+	if _, err := conn.Read(buf); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/handler/components"
+	"github.com/samber/lo"
+)
+
+const (
+	// upstreamDialTimeout bounds how long connecting to an instance pod may take
+	upstreamDialTimeout = 5 * time.Second
+	// upstreamIdleTTL defines how long an unused upstream keeps its connections
+	upstreamIdleTTL = 10 * time.Minute
+	// upstreamRetryAttempts is how often an idempotent request is tried while the pod is unreachable
+	upstreamRetryAttempts = 3
+	// upstreamRetryDelay is multiplied by the attempt number between retries
+	upstreamRetryDelay = 250 * time.Millisecond
+	// upstreamFailureThreshold is the number of consecutive failures that marks an upstream unhealthy
+	upstreamFailureThreshold = 3
+	// upstreamCooldown defines how long an unhealthy upstream is not dialed after its last failure
+	upstreamCooldown = 5 * time.Second
+	// upstreamCrashedAfter defines how long an upstream may fail before it is reported as crashed
+	upstreamCrashedAfter = 3 * time.Minute
+)
+
+// upstream is the reverse proxy of one instance namespace with its passive health state
+type upstream struct {
+	proxy     *httputil.ReverseProxy
+	transport *http.Transport
+	lastUsed  atomic.Int64
+
+	mu           sync.Mutex
+	failures     int
+	failingSince time.Time
+	lastFailure  time.Time
+}
+
+// recordSuccess marks the upstream healthy after it answered a request
+func (u *upstream) recordSuccess() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.failures = 0
+	u.failingSince = time.Time{}
+}
+
+// recordFailure counts a request that got no response from the upstream
+func (u *upstream) recordFailure(now time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.failures == 0 {
+		u.failingSince = now
+	}
+	u.failures++
+	u.lastFailure = now
+}
+
+// available reports whether requests should be sent to the upstream.
+// When it is not available, reason tells whether the instance looks like it is starting or crashed.
+func (u *upstream) available(now time.Time) (bool, string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.failures < upstreamFailureThreshold || now.Sub(u.lastFailure) >= upstreamCooldown {
+		return true, ""
+	}
+	return false, u.reasonLocked(now)
+}
+
+// reason describes why the upstream failed
+func (u *upstream) reason(now time.Time) string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.reasonLocked(now)
+}
+
+func (u *upstream) reasonLocked(now time.Time) string {
+	if u.failures > 0 && now.Sub(u.failingSince) >= upstreamCrashedAfter {
+		return components.InstanceUnavailableCrashed
+	}
+	return components.InstanceUnavailableStarting
+}
+
+// upstreamPool keeps one reverse proxy per instance namespace so connections are reused
+type upstreamPool struct {
+	upstreams sync.Map // namespace -> *upstream
+}
+
+// upstream returns the upstream of a namespace, creating it on first use
+func (h *Handler) upstream(namespace string, now time.Time) *upstream {
+	value, ok := h.upstreams.upstreams.Load(namespace)
+	if !ok {
+		value, _ = h.upstreams.upstreams.LoadOrStore(namespace, h.newUpstream(namespace))
+	}
+	u := value.(*upstream)
+	u.lastUsed.Store(now.UnixNano())
+	return u
+}
+
+// cleanup drops upstreams that have not been used for upstreamIdleTTL and closes their connections
+func (p *upstreamPool) cleanup(now time.Time) {
+	p.upstreams.Range(func(key, value any) bool {
+		if u, ok := value.(*upstream); ok && now.Sub(time.Unix(0, u.lastUsed.Load())) > upstreamIdleTTL {
+			p.upstreams.Delete(key)
+			u.transport.CloseIdleConnections()
+		}
+		return true
+	})
+}
+
+// newUpstream builds the reverse proxy for the n8n service of a namespace
+func (h *Handler) newUpstream(namespace string) *upstream {
+	targetHost := fmt.Sprintf("n8n-main.%s.svc.cluster.local", namespace)
+
+	transport := &http.Transport{
+		DialContext: dialWithIdleTimeout((&net.Dialer{
+			Timeout:   upstreamDialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext, h.config.Server.ProxyIdleTimeout),
+		MaxIdleConns:          64,
+		MaxIdleConnsPerHost:   32,
+		IdleConnTimeout:       90 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	u := &upstream{transport: transport}
+	u.proxy = &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = "http"
+			req.URL.Host = targetHost
+			req.Header.Set("X-Forwarded-Host", req.Host)
+			req.Header.Set("X-Forwarded-Proto", "https")
+		},
+		Transport: &retryTransport{base: transport},
+		ModifyResponse: func(resp *http.Response) error {
+			u.recordSuccess()
+			// Streams stay open as long as data flows, the upstream idle timeout closes them otherwise
+			if isStreamingResponse(resp) {
+				streamStarted(resp.Request.Context())
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			l := appctx.GetLogger(r.Context())
+
+			// The client went away, nothing is wrong with the instance
+			if errors.Is(err, context.Canceled) {
+				l.Debug("Proxy request canceled", slog.String("target_host", targetHost))
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			now := time.Now()
+			u.recordFailure(now)
+			l.Error("Proxy error",
+				slog.String("target_host", targetHost),
+				slog.Any("error", err))
+			h.writeInstanceUnavailable(w, r, u.reason(now))
+		},
+		FlushInterval: -1, // WebSocket support
+	}
+	return u
+}
+
+// writeInstanceUnavailable renders the branded error page served on instance subdomains
+func (h *Handler) writeInstanceUnavailable(w http.ResponseWriter, r *http.Request, reason string) {
+	subdomain := subdomainFromHost(r.Host)
+
+	switch reason {
+	case components.InstanceUnavailableStarting:
+		w.Header().Set("Retry-After", "5")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case components.InstanceUnavailableCrashed:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusBadGateway)
+	case components.InstanceUnavailableHibernated:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	default:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusNotFound)
+	}
+
+	lo.Must0(components.InstanceUnavailablePage(reason, subdomain, h.config.Server.BaseURL("/dashboard")).Render(r.Context(), w))
+}
+
+// retryTransport retries idempotent requests without a body when the pod could not be dialed,
+// which happens briefly while an instance restarts
+type retryTransport struct {
+	base http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if err == nil || attempt >= upstreamRetryAttempts || !isRetryableProxyError(req, err) {
+			return resp, err
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, err
+		case <-time.After(time.Duration(attempt) * upstreamRetryDelay):
+		}
+	}
+}
+
+// isRetryableProxyError reports whether a failed request can be sent again safely.
+// Only dial errors qualify because the request never reached the upstream.
+func isRetryableProxyError(req *http.Request, err error) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		return false
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/handler/components"
+)
+
+func TestUpstream_Health(t *testing.T) {
+	var u upstream
+	now := time.Now()
+
+	for i := 0; i < upstreamFailureThreshold; i++ {
+		if ok, _ := u.available(now); !ok {
+			t.Fatalf("Expected upstream to be available after %d failures", i)
+		}
+		u.recordFailure(now)
+	}
+
+	ok, reason := u.available(now)
+	if ok {
+		t.Fatal("Expected upstream to be unavailable after reaching the failure threshold")
+	}
+	if reason != components.InstanceUnavailableStarting {
+		t.Errorf("Expected reason %q, got %q", components.InstanceUnavailableStarting, reason)
+	}
+
+	// Requests are let through again after the cooldown
+	if ok, _ := u.available(now.Add(upstreamCooldown)); !ok {
+		t.Error("Expected upstream to be available after the cooldown")
+	}
+
+	// Failing for long enough is reported as a crash
+	later := now.Add(upstreamCrashedAfter)
+	u.recordFailure(later)
+	if _, reason := u.available(later); reason != components.InstanceUnavailableCrashed {
+		t.Errorf("Expected reason %q, got %q", components.InstanceUnavailableCrashed, reason)
+	}
+
+	u.recordSuccess()
+	if ok, _ := u.available(later); !ok {
+		t.Error("Expected upstream to be available after a success")
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRetryTransport(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	tests := []struct {
+		name         string
+		method       string
+		body         string
+		err          error
+		wantAttempts int
+	}{
+		{"GET dial error is retried", http.MethodGet, "", dialErr, upstreamRetryAttempts},
+		{"POST is not retried", http.MethodPost, "", dialErr, 1},
+		{"GET with body is not retried", http.MethodGet, "payload", dialErr, 1},
+		{"read error is not retried", http.MethodGet, "", errors.New("connection reset"), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			transport := &retryTransport{base: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+				attempts++
+				return nil, tt.err
+			})}
+
+			req := httptest.NewRequest(tt.method, "http://n8n-main.test.svc.cluster.local/", nil)
+			if tt.body != "" {
+				req = httptest.NewRequest(tt.method, "http://n8n-main.test.svc.cluster.local/", strings.NewReader(tt.body))
+			}
+
+			if _, err := transport.RoundTrip(req); err == nil {
+				t.Fatal("Expected an error")
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("Expected %d attempts, got %d", tt.wantAttempts, attempts)
+			}
+		})
+	}
+}
@@ -20,25 +20,86 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	// Public routes (no auth required)
 	mux.HandleFunc("GET /", h.Home)
 	mux.HandleFunc("GET /login", h.Login)
-	mux.HandleFunc("GET /auth/google", h.HandleGoogleLogin)
-	mux.HandleFunc("GET /auth/google/callback", h.HandleGoogleCallback)
+	mux.HandleFunc("POST /auth/email", h.HandleEmailLogin)
+	mux.HandleFunc("GET /auth/email/callback", h.HandleEmailCallback)
+	mux.HandleFunc("POST /auth/email/callback", h.HandleEmailCallbackConfirm)
+	mux.HandleFunc("POST /auth/logout", h.Logout)
+	mux.HandleFunc("GET /auth/{provider}", h.HandleOAuthLogin)
+	mux.HandleFunc("GET /auth/{provider}/callback", h.HandleOAuthCallback)
+	mux.HandleFunc("GET "+twoFactorLoginPath, h.TwoFactorLoginPage)
+	mux.HandleFunc("POST "+twoFactorLoginPath, h.TwoFactorLogin)
 
 	// Auth required - Frontend pages (redirects to login)
 	mux.HandleFunc("GET /dashboard", h.requireAuth(h.Dashboard))
 	mux.HandleFunc("GET /create-instance", h.requireAuth(h.CreateInstancePage))
 	mux.HandleFunc("GET /provision", h.requireAuth(h.ProvisioningPage))
 	mux.HandleFunc("GET /instances/{id}", h.requireAuth(h.InstanceDetail))
+	mux.HandleFunc("GET /instances/{id}/sso", h.requireAuth(h.InstanceSSO))
+	mux.HandleFunc("GET /instances/{id}/editor", h.requireAuth(h.OpenInstanceEditor))
 	mux.HandleFunc("GET /account", h.requireAuth(h.Account))
+	mux.HandleFunc("GET /account/export", h.requireAuth(h.ExportAccount))
+	mux.HandleFunc("GET /account/audit-events/export", h.requireAuth(h.ExportAuditEvents))
+	mux.HandleFunc("GET /organization", h.requireAuth(h.OrganizationPage))
+	mux.HandleFunc("GET /organization/webhooks/{id}", h.requireAuth(h.WebhookEndpointPage))
+	mux.HandleFunc("GET /invitations/{token}", h.requireAuth(h.InvitationPage))
 	// Keep old subscription route for backwards compatibility, redirect to account
 	mux.HandleFunc("GET /subscription", h.requireAuth(h.Account))
 
 	// Auth required - API endpoints (returns 401)
-	mux.HandleFunc("GET /api/auth/logout", h.requireAuthAPI(h.Logout))
 	mux.HandleFunc("GET /api/auth/me", h.requireAuthAPI(h.GetAuthMe))
 	mux.HandleFunc("POST /api/create-instance", h.requireAuthAPI(h.CreateInstance))
 	mux.HandleFunc("POST /api/check-subdomain", h.requireAuthAPI(h.CheckSubdomain))
 	mux.HandleFunc("GET /api/check-instance-status", h.requireAuthAPI(h.CheckInstanceStatus))
 	mux.HandleFunc("DELETE /instances/{id}", h.requireAuthAPI(h.DeleteInstance))
+	mux.HandleFunc("POST /instances/{id}/access-policy", h.requireAuthAPI(h.UpdateAccessPolicy))
+	mux.HandleFunc("POST /instances/{id}/alerts", h.requireAuthAPI(h.UpdateInstanceAlerts))
+	mux.HandleFunc("POST /account/sessions/revoke-all", h.requireAuthAPI(h.RevokeAllSessions))
+	mux.HandleFunc("POST /account/sessions/{id}/revoke", h.requireAuthAPI(h.RevokeSession))
+	mux.HandleFunc("POST /account/api-tokens", h.requireAuthAPI(h.CreateAPIToken))
+	mux.HandleFunc("POST /account/api-tokens/{id}/revoke", h.requireAuthAPI(h.RevokeAPIToken))
+	mux.HandleFunc("POST /account/two-factor/setup", h.requireAuthAPI(h.BeginTwoFactorSetup))
+	mux.HandleFunc("POST /account/two-factor/enable", h.requireAuthAPI(h.EnableTwoFactor))
+	mux.HandleFunc("POST /account/two-factor/disable", h.requireAuthAPI(h.DisableTwoFactor))
+	mux.HandleFunc("POST /account/two-factor/recovery-codes", h.requireAuthAPI(h.RegenerateRecoveryCodes))
+	mux.HandleFunc("POST /account/notifications", h.requireAuthAPI(h.UpdateNotificationPreferences))
+	mux.HandleFunc("POST /account/delete", h.requireAuthAPI(h.DeleteAccount))
+	mux.HandleFunc("GET /account/audit-events", h.requireAuthAPI(h.AuditEvents))
+	mux.HandleFunc("POST /instances/{id}/transfer", h.requireAuthAPI(h.TransferInstance))
+	mux.HandleFunc("POST /organization/members/{userID}/role", h.requireAuthAPI(h.UpdateOrganizationMemberRole))
+	mux.HandleFunc("POST /organization/members/{userID}/remove", h.requireAuthAPI(h.RemoveOrganizationMember))
+	mux.HandleFunc("POST /organization/invitations", h.requireAuthAPI(h.InviteOrganizationMember))
+	mux.HandleFunc("POST /organization/invitations/{id}/revoke", h.requireAuthAPI(h.RevokeOrganizationInvitation))
+	mux.HandleFunc("POST /organization/two-factor", h.requireAuthAPI(h.SetOrganizationTwoFactor))
+	mux.HandleFunc("POST /organization/webhooks", h.requireAuthAPI(h.CreateWebhookEndpoint))
+	mux.HandleFunc("POST /organization/webhooks/{id}/delete", h.requireAuthAPI(h.DeleteWebhookEndpoint))
+	mux.HandleFunc("POST /organization/webhooks/{id}/deliveries/{deliveryID}/redeliver", h.requireAuthAPI(h.RedeliverWebhook))
+
+	// Auth required - Plain form posts (redirect)
+	mux.HandleFunc("POST /organizations", h.requireAuth(h.CreateOrganization))
+	mux.HandleFunc("POST /organization/switch", h.requireAuth(h.SwitchOrganization))
+	mux.HandleFunc("POST /invitations/{token}", h.requireAuth(h.AcceptInvitation))
+
+	// Admin console, 404 for everyone but admins
+	mux.HandleFunc("GET /admin", h.requireAdmin(h.AdminPage))
+	mux.HandleFunc("POST /admin/users/{userID}/impersonate", h.requireAdmin(h.AdminImpersonate))
+	mux.HandleFunc("POST /admin/impersonation/stop", h.requireAdmin(h.AdminStopImpersonation))
+	mux.HandleFunc("POST /admin/users/{userID}/reset-two-factor", h.requireAdmin(h.AdminResetTwoFactor))
+	mux.HandleFunc("POST /admin/organizations/{organizationID}/extend-trial", h.requireAdmin(h.AdminExtendTrial))
+	mux.HandleFunc("POST /admin/instances/{id}/restart", h.requireAdmin(h.AdminRestartInstance))
+	mux.HandleFunc("POST /admin/instances/{id}/delete", h.requireAdmin(h.AdminDeleteInstance))
+	mux.HandleFunc("POST /admin/instances/{id}/limits/clear", h.requireAdmin(h.AdminClearInstanceLimitOverride))
+	mux.HandleFunc("POST /admin/limits/plans/{plan}", h.requireAdmin(h.AdminUpdatePlanLimits))
+	mux.HandleFunc("POST /admin/limits/overrides", h.requireAdmin(h.AdminSetInstanceLimitOverride))
+
+	// JSON API, authenticated by personal access tokens
+	for _, op := range h.apiV1Operations() {
+		handlerFunc := op.Handler
+		if op.Idempotent {
+			handlerFunc = h.idempotent(handlerFunc)
+		}
+		mux.HandleFunc(op.Method+" "+op.Path, h.requireAPIToken(op.Scope, handlerFunc))
+	}
+	mux.HandleFunc("GET /api/v1/openapi.json", h.OpenAPISpec)
 
 	// Legal pages (no auth)
 	mux.HandleFunc("GET /pricing", PricingHandler)
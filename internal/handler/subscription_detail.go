@@ -17,7 +17,7 @@ func (h *Handler) SubscriptionDetail(w http.ResponseWriter, r *http.Request) {
 	user := MustGetUser(ctx)
 
 	// Get the user's subscription
-	sub, err := h.services.GetUserSubscription(ctx, user.UserID)
+	sub, err := h.services.GetOrganizationSubscription(ctx, user.Membership.OrganizationID)
 	if err != nil {
 		l.Error("Failed to get subscription", slog.Any("error", err))
 		http.Error(w, "Failed to load subscription", http.StatusInternalServerError)
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/services"
+)
+
+// CacheCleanupInterval is how often CleanupCaches should run
+const CacheCleanupInterval = 10 * time.Minute
+
+const (
+	// instanceCacheTTL defines how long instances are cached.
+	// Changes are pushed through LISTEN/NOTIFY, the TTL only bounds staleness if a notification is lost.
+	instanceCacheTTL = 5 * time.Minute
+	// negativeCacheTTL defines how long unknown subdomains are remembered
+	negativeCacheTTL = 30 * time.Second
+	// invalidationMaxBackoff caps the delay between reconnects of the change listener
+	invalidationMaxBackoff = 30 * time.Second
+)
+
+// tenantCacheMetrics exposes tenant cache counters on /debug/vars
+var tenantCacheMetrics = expvar.NewMap("tenant_cache")
+
+// instanceCacheEntry holds cached instance data with expiration.
+// A nil instance marks a subdomain that does not exist.
+type instanceCacheEntry struct {
+	instance  *services.Instance
+	limits    *services.ProxyLimits
+	policy    *services.AccessPolicy
+	expiresAt time.Time
+}
+
+// tenantCache caches subdomain -> instance routing data for the proxy
+type tenantCache struct {
+	entries sync.Map // subdomain -> *instanceCacheEntry
+	// generation changes on every eviction so lookups that raced with one are not cached
+	generation atomic.Uint64
+}
+
+// get returns the live entry of a subdomain and records a hit or miss
+func (c *tenantCache) get(subdomain string, now time.Time) (*instanceCacheEntry, bool) {
+	if cached, ok := c.entries.Load(subdomain); ok {
+		entry := cached.(*instanceCacheEntry)
+		if now.Before(entry.expiresAt) {
+			if entry.instance == nil {
+				tenantCacheMetrics.Add("negative_hits", 1)
+			} else {
+				tenantCacheMetrics.Add("hits", 1)
+			}
+			return entry, true
+		}
+		c.entries.CompareAndDelete(subdomain, cached)
+	}
+	tenantCacheMetrics.Add("misses", 1)
+	return nil, false
+}
+
+// store caches the routing data of a subdomain unless an eviction happened since
+// generation was read, in which case the data may already be stale
+func (c *tenantCache) store(subdomain string, entry *instanceCacheEntry, generation uint64, now time.Time) {
+	if entry.instance == nil {
+		entry.expiresAt = now.Add(negativeCacheTTL)
+	} else {
+		entry.expiresAt = now.Add(instanceCacheTTL)
+	}
+	if c.generation.Load() != generation {
+		return
+	}
+	c.entries.Store(subdomain, entry)
+}
+
+// evict removes a subdomain, or every entry for services.InstanceChangeAll
+func (c *tenantCache) evict(subdomain string) {
+	c.generation.Add(1)
+	if subdomain == services.InstanceChangeAll {
+		c.entries.Clear()
+		tenantCacheMetrics.Add("flushes", 1)
+		return
+	}
+	if _, ok := c.entries.LoadAndDelete(subdomain); ok {
+		tenantCacheMetrics.Add("evictions", 1)
+	}
+}
+
+// cleanup removes expired entries
+func (c *tenantCache) cleanup(now time.Time) {
+	c.entries.Range(func(key, value any) bool {
+		if entry, ok := value.(*instanceCacheEntry); ok && now.After(entry.expiresAt) {
+			c.entries.CompareAndDelete(key, value)
+		}
+		return true
+	})
+}
+
+// RunCacheInvalidation evicts tenant cache entries whenever an instance changes in
+// any replica. It reconnects with backoff until ctx is cancelled.
+func (h *Handler) RunCacheInvalidation(ctx context.Context) {
+	backoff := time.Second
+
+	for {
+		started := time.Now()
+		err := h.services.ListenInstanceChanges(ctx, h.tenantCache.evict)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Reset the backoff if the listener was healthy for a while
+		if time.Since(started) > invalidationMaxBackoff {
+			backoff = time.Second
+		}
+
+		slog.Error("Instance change listener stopped, reconnecting",
+			slog.Any("error", err),
+			slog.Duration("backoff", backoff))
+		tenantCacheMetrics.Add("listener_restarts", 1)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, invalidationMaxBackoff)
+	}
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/services"
+)
+
+func TestTenantCache_NegativeEntries(t *testing.T) {
+	var c tenantCache
+	now := time.Now()
+
+	c.store("missing", &instanceCacheEntry{}, c.generation.Load(), now)
+
+	entry, ok := c.get("missing", now)
+	if !ok || entry.instance != nil {
+		t.Fatal("Expected a cached not-found entry")
+	}
+
+	// Negative entries expire sooner than instances
+	if _, ok := c.get("missing", now.Add(negativeCacheTTL+time.Second)); ok {
+		t.Error("Expected negative entry to expire after negativeCacheTTL")
+	}
+}
+
+func TestTenantCache_Evict(t *testing.T) {
+	var c tenantCache
+	now := time.Now()
+	entry := func() *instanceCacheEntry {
+		return &instanceCacheEntry{instance: &services.Instance{}}
+	}
+
+	c.store("a", entry(), c.generation.Load(), now)
+	c.store("b", entry(), c.generation.Load(), now)
+
+	c.evict("a")
+	if _, ok := c.get("a", now); ok {
+		t.Error("Expected evicted subdomain to miss")
+	}
+	if _, ok := c.get("b", now); !ok {
+		t.Error("Expected other subdomains to stay cached")
+	}
+
+	c.evict(services.InstanceChangeAll)
+	if _, ok := c.get("b", now); ok {
+		t.Error("Expected flush to evict every subdomain")
+	}
+}
+
+func TestTenantCache_StoreAfterEviction(t *testing.T) {
+	var c tenantCache
+	now := time.Now()
+
+	// A lookup that started before an eviction must not cache its result
+	generation := c.generation.Load()
+	c.evict("a")
+	c.store("a", &instanceCacheEntry{instance: &services.Instance{}}, generation, now)
+
+	if _, ok := c.get("a", now); ok {
+		t.Error("Expected stale lookup not to be cached")
+	}
+}
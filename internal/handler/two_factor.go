@@ -0,0 +1,274 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/handler/components"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/samber/lo"
+)
+
+const (
+	// twoFactorCookieName holds the signed proof of a first factor while the user enters their code
+	twoFactorCookieName = "two_factor_login"
+	// twoFactorLoginPath is where the user enters their code after logging in
+	twoFactorLoginPath = "/login/two-factor"
+	// twoFactorLoginTTL defines how long the user has to enter their code
+	twoFactorLoginTTL = 10 * time.Minute
+	// twoFactorLoginPurpose marks pending login tokens so they can't be mixed up with other signed tokens
+	twoFactorLoginPurpose = "two_factor_login"
+)
+
+// twoFactorClaims is a login that passed the first factor and waits for a code
+type twoFactorClaims struct {
+	UserID   string `json:"user_id"`
+	ReturnTo string `json:"return_to"`
+	Purpose  string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// beginTwoFactorLogin remembers the first factor in a signed cookie and asks for a code
+func (h *Handler) beginTwoFactorLogin(w http.ResponseWriter, r *http.Request, userID, returnTo string) {
+	l := appctx.GetLogger(r.Context())
+
+	now := time.Now()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &twoFactorClaims{
+		UserID:   userID,
+		ReturnTo: returnTo,
+		Purpose:  twoFactorLoginPurpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(twoFactorLoginTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    "ranx.cloud",
+		},
+	}).SignedString(h.jwtSecret)
+	if err != nil {
+		l.Error("Failed to sign two-factor login", slog.Any("error", err))
+		http.Redirect(w, r, "/login?error=auth_failed", http.StatusSeeOther)
+		return
+	}
+
+	setTwoFactorCookie(w, token, int(twoFactorLoginTTL.Seconds()))
+	http.Redirect(w, r, twoFactorLoginPath, http.StatusSeeOther)
+}
+
+// TwoFactorLoginPage asks a user who passed the first factor for their code
+func (h *Handler) TwoFactorLoginPage(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.parseTwoFactorCookie(r); err != nil {
+		http.Redirect(w, r, "/login?error=session_expired", http.StatusSeeOther)
+		return
+	}
+
+	lo.Must0(components.TwoFactorLoginPage("").Render(r.Context(), w))
+}
+
+// TwoFactorLogin checks the code of a pending login and issues the session
+func (h *Handler) TwoFactorLogin(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+
+	claims, err := h.parseTwoFactorCookie(r)
+	if err != nil {
+		http.Redirect(w, r, "/login?error=session_expired", http.StatusSeeOther)
+		return
+	}
+
+	if err := h.users.VerifyTwoFactorCode(ctx, claims.UserID, r.FormValue("code")); err != nil {
+		message := "Failed to check the code, please try again"
+		switch {
+		case apperrs.CodeIs(err, apperrs.CodeUnauthorized):
+			l.Info("Incorrect two-factor code", slog.String("user_id", claims.UserID))
+			message = "The code is incorrect"
+			w.WriteHeader(http.StatusUnauthorized)
+		case apperrs.CodeIs(err, apperrs.CodeRateLimited):
+			l.Warn("Two-factor login locked", slog.String("user_id", claims.UserID))
+			message = err.Error()
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			l.Error("Failed to verify two-factor code", slog.Any("error", err))
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		lo.Must0(components.TwoFactorLoginPage(message).Render(ctx, w))
+		return
+	}
+
+	l.Info("User passed two-factor authentication", slog.String("user_id", claims.UserID))
+
+	setTwoFactorCookie(w, "", -1)
+	h.issueSession(w, r, claims.UserID, loginReturnPath(claims.ReturnTo))
+}
+
+func (h *Handler) parseTwoFactorCookie(r *http.Request) (*twoFactorClaims, error) {
+	cookie, err := r.Cookie(twoFactorCookieName)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.ParseWithClaims(cookie.Value, &twoFactorClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return h.jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*twoFactorClaims)
+	if !ok || !token.Valid || claims.Purpose != twoFactorLoginPurpose {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return claims, nil
+}
+
+func setTwoFactorCookie(w http.ResponseWriter, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     twoFactorCookieName,
+		Value:    value,
+		Path:     twoFactorLoginPath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// twoFactorSetupAllowed reports whether a path stays reachable while the organization requires
+// two-factor authentication the user hasn't turned on, so they can turn it on, switch organizations
+// or take their data and leave
+func twoFactorSetupAllowed(path string) bool {
+	return path == "/account" || strings.HasPrefix(path, "/account/two-factor/") ||
+		path == "/account/export" || path == "/account/delete" ||
+		path == "/organization" || path == "/organization/switch"
+}
+
+// BeginTwoFactorSetup shows a new authenticator secret on the account page via HTMX
+func (h *Handler) BeginTwoFactorSetup(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	setup, err := h.services.BeginTwoFactorSetup(ctx, user.UserID)
+	if err != nil {
+		l.Error("Failed to begin two-factor setup", slog.Any("error", err))
+		h.renderAccountTwoFactor(w, r, func(data *components.AccountTwoFactorData) {
+			data.Message, data.IsError = err.Error(), true
+		})
+		return
+	}
+
+	h.renderAccountTwoFactor(w, r, func(data *components.AccountTwoFactorData) {
+		data.Setup = &components.TwoFactorSetupView{Secret: setup.Secret, URI: setup.URI}
+	})
+}
+
+// EnableTwoFactor confirms the authenticator with a code and shows the recovery codes via HTMX
+func (h *Handler) EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	codes, err := h.services.EnableTwoFactor(ctx, user.UserID, r.FormValue("code"))
+	if err != nil {
+		l.Error("Failed to enable two-factor authentication", slog.Any("error", err))
+		h.renderAccountTwoFactor(w, r, func(data *components.AccountTwoFactorData) {
+			data.Message, data.IsError = err.Error(), true
+			// Keep showing the secret the user already scanned
+			if !data.Enabled && r.FormValue("secret") != "" {
+				data.Setup = &components.TwoFactorSetupView{Secret: r.FormValue("secret"), URI: r.FormValue("uri")}
+			}
+		})
+		return
+	}
+
+	l.Info("Two-factor authentication enabled", slog.String("user_id", user.UserID))
+
+	h.renderAccountTwoFactor(w, r, func(data *components.AccountTwoFactorData) {
+		data.RecoveryCodes = codes
+		data.Message = "Two-factor authentication is on"
+		// The requirement of the organization is met now
+		data.Required = false
+	})
+}
+
+// DisableTwoFactor turns off two-factor authentication after checking a code via HTMX
+func (h *Handler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	err := h.services.DisableTwoFactor(ctx, user.UserID, r.FormValue("code"))
+	if err != nil {
+		l.Error("Failed to disable two-factor authentication", slog.Any("error", err))
+	} else {
+		l.Info("Two-factor authentication disabled", slog.String("user_id", user.UserID))
+	}
+
+	h.renderAccountTwoFactor(w, r, func(data *components.AccountTwoFactorData) {
+		if err != nil {
+			data.Message, data.IsError = err.Error(), true
+		} else {
+			data.Message = "Two-factor authentication is off"
+		}
+	})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes after checking a code via HTMX
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	codes, err := h.services.RegenerateRecoveryCodes(ctx, user.UserID, r.FormValue("code"))
+	if err != nil {
+		l.Error("Failed to regenerate recovery codes", slog.Any("error", err))
+	} else {
+		l.Info("Recovery codes regenerated", slog.String("user_id", user.UserID))
+	}
+
+	h.renderAccountTwoFactor(w, r, func(data *components.AccountTwoFactorData) {
+		if err != nil {
+			data.Message, data.IsError = err.Error(), true
+		} else {
+			data.RecoveryCodes = codes
+		}
+	})
+}
+
+// renderAccountTwoFactor re-renders the two-factor card of the account page after a change
+func (h *Handler) renderAccountTwoFactor(w http.ResponseWriter, r *http.Request, update func(data *components.AccountTwoFactorData)) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	data, err := h.accountTwoFactor(ctx, user)
+	if err != nil {
+		l.Error("Failed to get two-factor status", slog.Any("error", err))
+		http.Error(w, "Failed to load two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+	update(&data)
+
+	lo.Must0(components.AccountTwoFactor(data).Render(ctx, w))
+}
+
+// accountTwoFactor returns the two-factor state of the user for the account page
+func (h *Handler) accountTwoFactor(ctx context.Context, user *AuthUser) (components.AccountTwoFactorData, error) {
+	status, err := h.services.GetTwoFactorStatus(ctx, user.UserID)
+	if err != nil {
+		return components.AccountTwoFactorData{}, err
+	}
+
+	data := components.AccountTwoFactorData{
+		Enabled:           status.Enabled,
+		RecoveryCodesLeft: status.RecoveryCodesLeft,
+		Required:          user.TwoFactorRequired,
+	}
+	if status.EnabledAt != nil {
+		data.EnabledAt = status.EnabledAt.Format(time.RFC3339)
+	}
+	return data, nil
+}
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/handler/components"
+	"github.com/aliuygur/n8n-saas-api/internal/services"
+	"github.com/samber/lo"
+)
+
+// WebhookEndpointPage renders the delivery log of a webhook endpoint
+func (h *Handler) WebhookEndpointPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	// Endpoints of other organizations and non-admins are reported as missing
+	endpoint, err := h.services.GetWebhookEndpoint(ctx, user.Membership, r.PathValue("id"))
+	if err != nil {
+		l.Error("Failed to get webhook endpoint", slog.Any("error", err))
+		http.NotFound(w, r)
+		return
+	}
+
+	deliveries, err := h.webhookDeliveries(ctx, user, endpoint.ID)
+	if err != nil {
+		l.Error("Failed to list webhook deliveries", slog.Any("error", err))
+		http.Error(w, "Failed to load webhook deliveries", http.StatusInternalServerError)
+		return
+	}
+
+	lo.Must0(components.WebhookEndpointPage(components.WebhookEndpointPageData{
+		Endpoint:   toWebhookEndpointView(*endpoint),
+		Deliveries: deliveries,
+	}).Render(ctx, w))
+}
+
+// CreateWebhookEndpoint registers a webhook endpoint and re-renders the webhooks card
+func (h *Handler) CreateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	endpoint, err := h.services.CreateWebhookEndpoint(ctx, services.CreateWebhookEndpointParams{
+		Member: user.Membership,
+		URL:    r.FormValue("url"),
+		Events: r.Form["events"],
+	})
+	if err != nil {
+		l.Error("Failed to create webhook endpoint", slog.Any("error", err))
+		h.renderOrganizationWebhooks(w, r, err, "")
+		return
+	}
+
+	l.Info("Webhook endpoint created",
+		slog.String("endpoint_id", endpoint.ID),
+		slog.String("organization_id", user.Membership.OrganizationID),
+		slog.String("user_id", user.UserID))
+
+	h.renderOrganizationWebhooks(w, r, nil, "Webhook added, copy its signing secret to verify deliveries")
+}
+
+// DeleteWebhookEndpoint removes a webhook endpoint and re-renders the webhooks card
+func (h *Handler) DeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	endpointID := r.PathValue("id")
+	if err := h.services.DeleteWebhookEndpoint(ctx, user.Membership, endpointID); err != nil {
+		l.Error("Failed to delete webhook endpoint", slog.Any("error", err))
+		h.renderOrganizationWebhooks(w, r, err, "")
+		return
+	}
+
+	l.Info("Webhook endpoint deleted",
+		slog.String("endpoint_id", endpointID),
+		slog.String("user_id", user.UserID))
+
+	h.renderOrganizationWebhooks(w, r, nil, "Webhook deleted")
+}
+
+// RedeliverWebhook sends the event of a delivery again and re-renders the delivery log
+func (h *Handler) RedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	endpointID := r.PathValue("id")
+	_, redeliverErr := h.services.RedeliverWebhook(ctx, user.Membership, r.PathValue("deliveryID"))
+	if redeliverErr != nil {
+		l.Error("Failed to redeliver webhook", slog.Any("error", redeliverErr))
+	}
+
+	data, err := h.webhookDeliveries(ctx, user, endpointID)
+	if err != nil {
+		l.Error("Failed to list webhook deliveries", slog.Any("error", err))
+		http.Error(w, "Failed to load webhook deliveries", http.StatusInternalServerError)
+		return
+	}
+	if redeliverErr != nil {
+		data.Message, data.IsError = redeliverErr.Error(), true
+	} else {
+		data.Message = "Event queued for redelivery"
+	}
+
+	lo.Must0(components.WebhookDeliveries(data).Render(ctx, w))
+}
+
+func (h *Handler) renderOrganizationWebhooks(w http.ResponseWriter, r *http.Request, changeErr error, success string) {
+	ctx := r.Context()
+	l := appctx.GetLogger(ctx)
+	user := MustGetUser(ctx)
+
+	data, err := h.organizationWebhooks(ctx, user)
+	if err != nil {
+		l.Error("Failed to list webhook endpoints", slog.Any("error", err))
+		http.Error(w, "Failed to load webhooks", http.StatusInternalServerError)
+		return
+	}
+	if changeErr != nil {
+		data.Message, data.IsError = changeErr.Error(), true
+	} else {
+		data.Message = success
+	}
+
+	lo.Must0(components.OrganizationWebhooks(data).Render(ctx, w))
+}
+
+// organizationWebhooks lists the webhook endpoints of the current organization
+func (h *Handler) organizationWebhooks(ctx context.Context, user *AuthUser) (components.OrganizationWebhooksData, error) {
+	endpoints, err := h.services.ListWebhookEndpoints(ctx, user.Membership)
+	if err != nil {
+		return components.OrganizationWebhooksData{}, err
+	}
+
+	return components.OrganizationWebhooksData{
+		Endpoints: lo.Map(endpoints, func(e services.WebhookEndpoint, _ int) components.WebhookEndpointView {
+			return toWebhookEndpointView(e)
+		}),
+		Events: services.WebhookEvents,
+	}, nil
+}
+
+// webhookDeliveries lists the latest deliveries to a webhook endpoint of the current organization
+func (h *Handler) webhookDeliveries(ctx context.Context, user *AuthUser, endpointID string) (components.WebhookDeliveriesData, error) {
+	deliveries, err := h.services.ListWebhookDeliveries(ctx, user.Membership, endpointID)
+	if err != nil {
+		return components.WebhookDeliveriesData{}, err
+	}
+
+	return components.WebhookDeliveriesData{
+		EndpointID: endpointID,
+		Deliveries: lo.Map(deliveries, func(d services.WebhookDelivery, _ int) components.WebhookDeliveryView {
+			return components.WebhookDeliveryView{
+				ID:             d.ID,
+				EventID:        d.EventID,
+				Event:          d.Event,
+				Payload:        d.Payload,
+				Status:         d.Status,
+				Attempts:       d.Attempts,
+				ResponseStatus: d.ResponseStatus,
+				ResponseBody:   d.ResponseBody,
+				Error:          d.Error,
+				CreatedAt:      d.CreatedAt.Format(time.RFC3339),
+			}
+		}),
+	}, nil
+}
+
+func toWebhookEndpointView(e services.WebhookEndpoint) components.WebhookEndpointView {
+	return components.WebhookEndpointView{
+		ID:        e.ID,
+		URL:       e.URL,
+		Secret:    e.Secret,
+		Events:    e.Events,
+		CreatedAt: e.CreatedAt.Format(time.RFC3339),
+	}
+}
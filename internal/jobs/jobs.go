@@ -0,0 +1,501 @@
+// Package jobs runs background work from a queue in Postgres shared by all app replicas.
+// Jobs are retried with backoff, can be unique per key, and can recur on a schedule.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	defaultWorkers      = 4
+	defaultPollInterval = 2 * time.Second
+	defaultLease        = 5 * time.Minute
+	defaultDrainTimeout = 20 * time.Second
+	defaultRetention    = 7 * 24 * time.Hour
+	defaultMaxAttempts  = 10
+
+	// maxBackoff caps the delay between attempts of a failing job
+	maxBackoff = time.Hour
+
+	// scheduledUniqueKey keeps a scheduled run from starting while the previous one is still going
+	scheduledUniqueKey = "schedule"
+
+	// KindCleanup deletes finished jobs older than Options.Retention
+	KindCleanup = "jobs.cleanup"
+)
+
+// Handler runs a job. Returning an error retries the job until it runs out of attempts.
+// The context is cancelled if the job is still running when the drain timeout passes.
+type Handler func(ctx context.Context, job *Job) error
+
+// Job is a claimed job handed to its Handler
+type Job struct {
+	ID          string
+	Kind        string
+	Args        json.RawMessage
+	Attempt     int32 // Starts at 1
+	MaxAttempts int32
+}
+
+// Bind decodes the arguments the job was enqueued with into v
+func (j *Job) Bind(v any) error {
+	if err := json.Unmarshal(j.Args, v); err != nil {
+		return fmt.Errorf("failed to decode arguments of job %s: %w", j.Kind, err)
+	}
+	return nil
+}
+
+// Options tune a Queue, zero values use the defaults
+type Options struct {
+	Workers      int           // Jobs run at once by this replica
+	PollInterval time.Duration // How often the queue is checked for due jobs
+	// Lease is how long a job stays claimed without its worker checking in,
+	// after which another replica picks it up again
+	Lease time.Duration
+	// DrainTimeout is how long Run waits for running jobs once its context is cancelled
+	DrainTimeout time.Duration
+	// Retention is how long finished jobs are kept
+	Retention time.Duration
+}
+
+// EnqueueOptions control a single job
+type EnqueueOptions struct {
+	// UniqueKey skips the job while another pending or running job of the kind has the same key
+	UniqueKey string
+	// RunAt delays the job, it runs right away when zero
+	RunAt time.Time
+	// MaxAttempts defaults to 10
+	MaxAttempts int32
+}
+
+type scheduledJob struct {
+	kind     string
+	spec     string
+	schedule schedule
+}
+
+type tickTask struct {
+	kind     string
+	interval time.Duration
+	fn       func(ctx context.Context)
+}
+
+// Queue enqueues jobs and runs the ones with a registered Handler
+type Queue struct {
+	pool      *pgxpool.Pool
+	opts      Options
+	handlers  map[string]Handler
+	schedules []scheduledJob
+	ticks     []tickTask
+}
+
+// New creates a Queue on the jobs table. Register handlers and schedules before calling Run.
+func New(pool *pgxpool.Pool, opts Options) *Queue {
+	if opts.Workers <= 0 {
+		opts.Workers = defaultWorkers
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = defaultLease
+	}
+	if opts.DrainTimeout <= 0 {
+		opts.DrainTimeout = defaultDrainTimeout
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = defaultRetention
+	}
+
+	q := &Queue{
+		pool:     pool,
+		opts:     opts,
+		handlers: make(map[string]Handler),
+	}
+	q.Register(KindCleanup, q.cleanup)
+	// The schedule is valid, so the error can't happen
+	_ = q.Schedule(KindCleanup, "17 3 * * *")
+	return q
+}
+
+// Register sets the Handler of a kind of job
+func (q *Queue) Register(kind string, h Handler) {
+	q.handlers[kind] = h
+}
+
+// Schedule enqueues a job of a registered kind on a cron expression such as "*/10 * * * *",
+// @hourly, @daily, @weekly, @monthly or @every <duration>. Across replicas a run is enqueued once,
+// and skipped while the previous run is still pending or running.
+func (q *Queue) Schedule(kind, spec string) error {
+	if _, ok := q.handlers[kind]; !ok {
+		return fmt.Errorf("no handler registered for scheduled job %s", kind)
+	}
+	sched, err := parseSchedule(spec)
+	if err != nil {
+		return err
+	}
+	if sched.next(time.Now()).IsZero() {
+		return fmt.Errorf("schedule %q of job %s never runs", spec, kind)
+	}
+	q.schedules = append(q.schedules, scheduledJob{kind: kind, spec: spec, schedule: sched})
+	return nil
+}
+
+// Tick calls fn every interval on every replica while Run is running, for work on what a replica
+// keeps in memory, such as caches. Ticks aren't stored in the queue, so they are neither retried
+// nor shared between replicas. Run stops them along with the jobs.
+func (q *Queue) Tick(kind string, interval time.Duration, fn func(ctx context.Context)) error {
+	if interval <= 0 {
+		return fmt.Errorf("interval of %s must be positive", kind)
+	}
+	q.ticks = append(q.ticks, tickTask{kind: kind, interval: interval, fn: fn})
+	return nil
+}
+
+// Enqueue adds a job of a registered kind, args are encoded as JSON.
+// It returns false if the job was skipped for its UniqueKey.
+func (q *Queue) Enqueue(ctx context.Context, kind string, args any, opts EnqueueOptions) (bool, error) {
+	return q.EnqueueTx(ctx, db.New(q.pool), kind, args, opts)
+}
+
+// EnqueueTx adds a job with queries of a transaction, so it only runs if the transaction commits
+func (q *Queue) EnqueueTx(ctx context.Context, queries *db.Queries, kind string, args any, opts EnqueueOptions) (bool, error) {
+	if _, ok := q.handlers[kind]; !ok {
+		return false, fmt.Errorf("no handler registered for job %s", kind)
+	}
+	if args == nil {
+		args = struct{}{}
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode arguments of job %s: %w", kind, err)
+	}
+	if opts.RunAt.IsZero() {
+		opts.RunAt = time.Now()
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+
+	_, err = queries.InsertJob(ctx, db.InsertJobParams{
+		Kind:        kind,
+		Args:        data,
+		UniqueKey:   pgtype.Text{String: opts.UniqueKey, Valid: opts.UniqueKey != ""},
+		MaxAttempts: opts.MaxAttempts,
+		RunAt:       pgtype.Timestamp{Time: opts.RunAt, Valid: true},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to enqueue job %s: %w", kind, err)
+	}
+	return true, nil
+}
+
+// Run claims and runs jobs until ctx is cancelled, then waits up to the drain timeout
+// for running jobs. Jobs still running after it are interrupted and handed back to the queue.
+func (q *Queue) Run(ctx context.Context) {
+	l := appctx.GetLogger(ctx)
+
+	if err := q.registerSchedules(ctx); err != nil {
+		l.Error("failed to register job schedules", "error", err)
+	}
+
+	// Jobs outlive ctx so they can finish while draining
+	jobCtx, interrupt := context.WithCancel(context.WithoutCancel(ctx))
+	defer interrupt()
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, q.opts.Workers)
+
+	var ticks sync.WaitGroup
+	defer ticks.Wait()
+	for _, task := range q.ticks {
+		ticks.Add(1)
+		go func() {
+			defer ticks.Done()
+			q.runTicks(ctx, task)
+		}()
+	}
+
+	ticker := time.NewTicker(q.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := q.enqueueDueSchedules(ctx); err != nil && ctx.Err() == nil {
+			l.Error("failed to enqueue scheduled jobs", "error", err)
+		}
+
+		if free := cap(slots) - len(slots); free > 0 && ctx.Err() == nil {
+			claimed, err := q.claim(ctx, free)
+			if err != nil && ctx.Err() == nil {
+				l.Error("failed to claim jobs", "error", err)
+			}
+			for _, job := range claimed {
+				slots <- struct{}{}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer func() { <-slots }()
+					q.run(jobCtx, job)
+				}()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			q.drain(l, &wg, interrupt)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runTicks calls a tick task every interval until ctx is cancelled
+func (q *Queue) runTicks(ctx context.Context, task tickTask) {
+	l := appctx.GetLogger(ctx).With("job_kind", task.kind)
+	ctx = appctx.WithLogger(ctx, l)
+
+	ticker := time.NewTicker(task.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						l.Error("tick panicked", "error", r)
+					}
+				}()
+				task.fn(ctx)
+			}()
+		}
+	}
+}
+
+// drain waits for running jobs, interrupting them when the drain timeout passes
+func (q *Queue) drain(l *slog.Logger, wg *sync.WaitGroup, interrupt context.CancelFunc) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	l.Info("draining background jobs", "timeout", q.opts.DrainTimeout)
+	select {
+	case <-done:
+	case <-time.After(q.opts.DrainTimeout):
+		l.Warn("background jobs still running after drain timeout, interrupting them")
+		interrupt()
+		<-done
+	}
+}
+
+func (q *Queue) claim(ctx context.Context, max int) ([]db.Job, error) {
+	kinds := make([]string, 0, len(q.handlers))
+	for kind := range q.handlers {
+		kinds = append(kinds, kind)
+	}
+	return db.New(q.pool).ClaimJobs(ctx, db.ClaimJobsParams{
+		LockedUntil: pgtype.Timestamp{Time: time.Now().Add(q.opts.Lease), Valid: true},
+		Kinds:       kinds,
+		MaxJobs:     int32(max),
+	})
+}
+
+// run runs a claimed job and records the outcome
+func (q *Queue) run(ctx context.Context, row db.Job) {
+	job := &Job{
+		ID:          row.ID,
+		Kind:        row.Kind,
+		Args:        row.Args,
+		Attempt:     row.Attempts,
+		MaxAttempts: row.MaxAttempts,
+	}
+	l := appctx.GetLogger(ctx).With("job_id", job.ID, "job_kind", job.Kind, "attempt", job.Attempt)
+	ctx = appctx.WithLogger(ctx, l)
+	queries := db.New(q.pool)
+
+	// The outcome is recorded even when the job was interrupted
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+
+	// A job claimed again after its lease ran out may have crashed the replica running it
+	if job.Attempt > job.MaxAttempts {
+		l.Error("job abandoned, its lease ran out on the last attempt")
+		recorded, err := queries.FailJob(recordCtx, db.FailJobParams{ID: job.ID, Attempts: job.Attempt, LastError: "lease expired"})
+		q.logOutcome(l, recorded, err)
+		return
+	}
+
+	start := time.Now()
+	err := q.runWithLease(ctx, job)
+
+	var recorded int64
+	switch {
+	case err == nil:
+		l.Info("job succeeded", "duration", time.Since(start))
+		recorded, err = queries.CompleteJob(recordCtx, db.CompleteJobParams{ID: job.ID, Attempts: job.Attempt})
+	case ctx.Err() != nil:
+		l.Warn("job interrupted by shutdown", "error", err)
+		recorded, err = queries.ReleaseJob(recordCtx, db.ReleaseJobParams{ID: job.ID, Attempts: job.Attempt})
+	case job.Attempt >= job.MaxAttempts:
+		l.Error("job failed, no attempts left", "error", err)
+		recorded, err = queries.FailJob(recordCtx, db.FailJobParams{ID: job.ID, Attempts: job.Attempt, LastError: err.Error()})
+	default:
+		runAt := time.Now().Add(backoff(job.Attempt))
+		l.Warn("job failed, retrying", "error", err, "run_at", runAt)
+		recorded, err = queries.RetryJob(recordCtx, db.RetryJobParams{
+			ID:        job.ID,
+			Attempts:  job.Attempt,
+			RunAt:     pgtype.Timestamp{Time: runAt, Valid: true},
+			LastError: err.Error(),
+		})
+	}
+	q.logOutcome(l, recorded, err)
+}
+
+// logOutcome logs an outcome that wasn't recorded, either because of err or because
+// the job was claimed again after the lease of this attempt ran out
+func (q *Queue) logOutcome(l *slog.Logger, recorded int64, err error) {
+	switch {
+	case err != nil:
+		l.Error("failed to record job outcome", "error", err)
+	case recorded == 0:
+		l.Warn("job outcome dropped, the job was claimed again after its lease ran out")
+	}
+}
+
+// runWithLease calls the handler while extending the lease of the job, so no other replica claims it
+func (q *Queue) runWithLease(ctx context.Context, job *Job) (err error) {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	// The lease is kept while an interrupted job winds down
+	leaseCtx := context.WithoutCancel(ctx)
+	go func() {
+		ticker := time.NewTicker(q.opts.Lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				extended, err := db.New(q.pool).ExtendJobLease(leaseCtx, db.ExtendJobLeaseParams{
+					ID:          job.ID,
+					LockedUntil: pgtype.Timestamp{Time: time.Now().Add(q.opts.Lease), Valid: true},
+					Attempts:    job.Attempt,
+				})
+				if err != nil {
+					appctx.GetLogger(leaseCtx).Error("failed to extend job lease", "error", err)
+				} else if extended == 0 {
+					appctx.GetLogger(leaseCtx).Warn("job lease lost, another replica claimed the job again")
+				}
+			}
+		}
+	}()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	return q.handlers[job.Kind](ctx, job)
+}
+
+// registerSchedules stores the schedules of this replica, keeping the next run of unchanged ones
+func (q *Queue) registerSchedules(ctx context.Context) error {
+	queries := db.New(q.pool)
+	for _, s := range q.schedules {
+		if err := queries.UpsertJobSchedule(ctx, db.UpsertJobScheduleParams{
+			Kind:      s.kind,
+			Spec:      s.spec,
+			NextRunAt: pgtype.Timestamp{Time: s.schedule.next(time.Now()), Valid: true},
+		}); err != nil {
+			return fmt.Errorf("failed to register schedule of job %s: %w", s.kind, err)
+		}
+	}
+	return nil
+}
+
+// enqueueDueSchedules enqueues a run of each due schedule and moves it to its next run
+func (q *Queue) enqueueDueSchedules(ctx context.Context) error {
+	if len(q.schedules) == 0 {
+		return nil
+	}
+	kinds := make([]string, 0, len(q.schedules))
+	byKind := make(map[string]scheduledJob, len(q.schedules))
+	for _, s := range q.schedules {
+		kinds = append(kinds, s.kind)
+		byKind[s.kind] = s
+	}
+
+	tx, err := q.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	queries := db.New(tx)
+
+	due, err := queries.ClaimDueJobSchedules(ctx, kinds)
+	if err != nil {
+		return fmt.Errorf("failed to list due schedules: %w", err)
+	}
+	now := time.Now()
+	for _, row := range due {
+		s := byKind[row.Kind]
+		// Another replica may run a different spec during a deploy, this one only enqueues its own
+		if row.Spec != s.spec {
+			continue
+		}
+		if _, err := q.EnqueueTx(ctx, queries, s.kind, nil, EnqueueOptions{UniqueKey: scheduledUniqueKey}); err != nil {
+			return err
+		}
+		// Runs missed while no replica was up are not caught up on
+		if err := queries.UpdateJobScheduleNextRun(ctx, db.UpdateJobScheduleNextRunParams{
+			Kind:      s.kind,
+			NextRunAt: pgtype.Timestamp{Time: s.schedule.next(now), Valid: true},
+		}); err != nil {
+			return fmt.Errorf("failed to update schedule of job %s: %w", s.kind, err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// cleanup deletes finished jobs past the retention
+func (q *Queue) cleanup(ctx context.Context, job *Job) error {
+	deleted, err := db.New(q.pool).DeleteFinishedJobs(ctx, pgtype.Timestamp{Time: time.Now().Add(-q.opts.Retention), Valid: true})
+	if err != nil {
+		return fmt.Errorf("failed to delete finished jobs: %w", err)
+	}
+	appctx.GetLogger(ctx).Info("deleted finished jobs", "count", deleted)
+	return nil
+}
+
+// backoff returns the delay before the next attempt, doubling from 30s up to an hour with some jitter
+// so jobs failing together don't retry together
+func backoff(attempt int32) time.Duration {
+	d := maxBackoff
+	if attempt < 8 {
+		d = min(15*time.Second<<attempt, maxBackoff)
+	}
+	return d + rand.N(d/10+1)
+}
@@ -0,0 +1,225 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/testdb"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int32
+		want    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{50, time.Hour},
+	}
+
+	for _, tt := range tests {
+		for range 20 {
+			got := backoff(tt.attempt)
+			if got < tt.want || got > tt.want+tt.want/10 {
+				t.Fatalf("Expected the backoff of attempt %d between %v and %v, got %v", tt.attempt, tt.want, tt.want+tt.want/10, got)
+			}
+		}
+	}
+}
+
+// newTestQueue returns a queue on a database of its own, see testdb.New
+func newTestQueue(t *testing.T) *Queue {
+	t.Helper()
+	return New(testdb.New(t), Options{})
+}
+
+// jobState is what the queue recorded about a job
+type jobState struct {
+	status    string
+	attempts  int32
+	lastError string
+	delayed   bool // run_at is in the future
+	finished  bool
+}
+
+func getJobState(t *testing.T, q *Queue, kind string) jobState {
+	t.Helper()
+	var s jobState
+	err := q.pool.QueryRow(context.Background(), `SELECT status, attempts, last_error, run_at > NOW(), finished_at IS NOT NULL
+		FROM jobs WHERE kind = $1`, kind).Scan(&s.status, &s.attempts, &s.lastError, &s.delayed, &s.finished)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestQueueRun(t *testing.T) {
+	errFailed := errors.New("upstream unavailable")
+
+	tests := []struct {
+		name        string
+		maxAttempts int32
+		handler     Handler
+		cancel      bool // The replica shuts down while the job runs
+		want        jobState
+	}{
+		{
+			name:        "succeeded",
+			maxAttempts: 3,
+			handler:     func(ctx context.Context, job *Job) error { return nil },
+			want:        jobState{status: "succeeded", attempts: 1, finished: true},
+		},
+		{
+			name:        "failed with attempts left",
+			maxAttempts: 3,
+			handler:     func(ctx context.Context, job *Job) error { return errFailed },
+			want:        jobState{status: "pending", attempts: 1, lastError: errFailed.Error(), delayed: true},
+		},
+		{
+			name:        "failed on the last attempt",
+			maxAttempts: 1,
+			handler:     func(ctx context.Context, job *Job) error { return errFailed },
+			want:        jobState{status: "failed", attempts: 1, lastError: errFailed.Error(), finished: true},
+		},
+		{
+			name:        "panicked",
+			maxAttempts: 3,
+			handler:     func(ctx context.Context, job *Job) error { panic("nil map") },
+			want:        jobState{status: "pending", attempts: 1, lastError: "job panicked: nil map", delayed: true},
+		},
+		{
+			name:        "interrupted by shutdown",
+			maxAttempts: 3,
+			handler: func(ctx context.Context, job *Job) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			cancel: true,
+			want:   jobState{status: "pending", attempts: 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newTestQueue(t)
+			ctx := testdb.Context()
+			q.Register("test.run", tt.handler)
+
+			if _, err := q.Enqueue(ctx, "test.run", nil, EnqueueOptions{MaxAttempts: tt.maxAttempts}); err != nil {
+				t.Fatalf("Failed to enqueue job: %v", err)
+			}
+			claimed, err := q.claim(ctx, 1)
+			if err != nil || len(claimed) != 1 {
+				t.Fatalf("Expected the job to be claimed, got %d jobs %v", len(claimed), err)
+			}
+
+			runCtx := ctx
+			if tt.cancel {
+				var cancel context.CancelFunc
+				runCtx, cancel = context.WithCancel(ctx)
+				cancel()
+			}
+			q.run(runCtx, claimed[0])
+
+			if got := getJobState(t, q, "test.run"); got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestQueueRunAbandoned(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := testdb.Context()
+	var runs int
+	q.Register("test.run", func(ctx context.Context, job *Job) error {
+		runs++
+		return nil
+	})
+
+	if _, err := q.Enqueue(ctx, "test.run", nil, EnqueueOptions{MaxAttempts: 1}); err != nil {
+		t.Fatal(err)
+	}
+	// The replica running the last attempt stopped extending the lease
+	if _, err := q.pool.Exec(ctx, "UPDATE jobs SET status = 'running', attempts = 1, locked_until = NOW() - INTERVAL '1 second'"); err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, err := q.claim(ctx, 1)
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("Expected the job to be claimed again, got %d jobs %v", len(claimed), err)
+	}
+	q.run(ctx, claimed[0])
+
+	want := jobState{status: "failed", attempts: 2, lastError: "lease expired", finished: true}
+	if got := getJobState(t, q, "test.run"); got != want || runs != 0 {
+		t.Errorf("Expected %+v without running it, got %+v after %d runs", want, got, runs)
+	}
+}
+
+func TestQueueRunReclaimed(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := testdb.Context()
+	q.Register("test.run", func(ctx context.Context, job *Job) error {
+		// Another replica claims the job again while this attempt is still running
+		_, err := q.pool.Exec(ctx, "UPDATE jobs SET attempts = attempts + 1, locked_until = NOW() + INTERVAL '5 minutes' WHERE id = $1", job.ID)
+		return err
+	})
+
+	if _, err := q.Enqueue(ctx, "test.run", nil, EnqueueOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	claimed, err := q.claim(ctx, 1)
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("Expected the job to be claimed, got %d jobs %v", len(claimed), err)
+	}
+	q.run(ctx, claimed[0])
+
+	// The outcome of the stale attempt is dropped, the job belongs to the new one
+	want := jobState{status: "running", attempts: 2}
+	if got := getJobState(t, q, "test.run"); got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
+
+func TestQueueTick(t *testing.T) {
+	q := New(nil, Options{})
+	if err := q.Tick("test.tick", 0, func(ctx context.Context) {}); err == nil {
+		t.Error("Expected a tick without an interval to be rejected")
+	}
+
+	ticks := make(chan struct{}, 10)
+	ctx, cancel := context.WithCancel(testdb.Context())
+	done := make(chan struct{})
+	var calls int
+	go func() {
+		defer close(done)
+		q.runTicks(ctx, tickTask{kind: "test.tick", interval: 5 * time.Millisecond, fn: func(ctx context.Context) {
+			calls++
+			ticks <- struct{}{}
+			if calls == 1 {
+				panic("first tick")
+			}
+		}})
+	}()
+
+	// A panicking tick doesn't stop the next ones
+	for range 3 {
+		select {
+		case <-ticks:
+		case <-time.After(time.Second):
+			t.Fatal("Expected the task to keep ticking")
+		}
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the ticks to stop with the context")
+	}
+}
@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule_Next(t *testing.T) {
+	// A Wednesday
+	after := time.Date(2026, time.March, 11, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, time.March, 11, 10, 8, 0, 0, time.UTC)},
+		{"*/10 * * * *", time.Date(2026, time.March, 11, 10, 10, 0, 0, time.UTC)},
+		{"5 * * * *", time.Date(2026, time.March, 11, 11, 5, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2026, time.March, 12, 2, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2026, time.March, 11, 13, 0, 0, 0, time.UTC)},
+		{"0 0 * * 1", time.Date(2026, time.March, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2026, time.March, 15, 0, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted
+		{"0 0 20 * 5", time.Date(2026, time.March, 13, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, time.March, 12, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 90m", after.Add(90 * time.Minute)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := parseSchedule(tt.spec)
+			if err != nil {
+				t.Fatalf("Expected %q to parse, got %v", tt.spec, err)
+			}
+			if got := s.next(after); !got.Equal(tt.want) {
+				t.Errorf("Expected next run %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSchedule_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "5-1 * * * *", "*/0 * * * *", "@every 10", "@every 0s"} {
+		if _, err := parseSchedule(spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+
+	s, err := parseSchedule("0 0 31 2 *")
+	if err != nil {
+		t.Fatalf("Expected schedule to parse, got %v", err)
+	}
+	if next := s.next(time.Now()); !next.IsZero() {
+		t.Errorf("Expected a schedule that never matches, got %v", next)
+	}
+}
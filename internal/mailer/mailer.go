@@ -0,0 +1,85 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Mailer drivers
+const (
+	DriverSMTP   = "smtp"
+	DriverResend = "resend"
+	DriverLog    = "log"
+	DriverFile   = "file"
+)
+
+// Config holds mailer configuration
+type Config struct {
+	Driver       string // smtp, resend, log or file
+	From         string // Sender address, e.g. "ranx.cloud <noreply@ranx.cloud>"
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	APIKey       string // API key of the resend driver
+	FileDir      string // Directory the file driver writes .eml files to
+}
+
+// Message is an email with a plain text body and an optional HTML alternative
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	// IdempotencyKey lets drivers that support it skip a retried send that already went out
+	IdempotencyKey string
+}
+
+// Mailer sends transactional emails
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New creates the mailer selected by config.Driver
+func New(config Config) (Mailer, error) {
+	switch config.Driver {
+	case DriverSMTP:
+		if config.SMTPHost == "" || config.SMTPPort == "" {
+			return nil, fmt.Errorf("SMTP host and port are required for the smtp mailer")
+		}
+		return &SMTPMailer{config: config}, nil
+	case DriverResend:
+		if config.APIKey == "" {
+			return nil, fmt.Errorf("an API key is required for the resend mailer")
+		}
+		return NewResendMailer(config.From, config.APIKey), nil
+	case DriverFile:
+		if config.FileDir == "" {
+			return nil, fmt.Errorf("a directory is required for the file mailer")
+		}
+		return NewFileMailer(config.From, config.FileDir), nil
+	case DriverLog, "":
+		return NewLogMailer(config.From, os.Stdout), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer driver %q", config.Driver)
+	}
+}
+
+// LogMailer writes emails to a writer instead of sending them, for local development
+type LogMailer struct {
+	from string
+	w    io.Writer
+}
+
+// NewLogMailer creates a LogMailer writing to w
+func NewLogMailer(from string, w io.Writer) *LogMailer {
+	return &LogMailer{from: from, w: w}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	_, err := fmt.Fprintf(m.w, "----- email -----\nFrom: %s\nTo: %s\nSubject: %s\n\n%s\n-----------------\n",
+		m.from, msg.To, msg.Subject, msg.Text)
+	return err
+}
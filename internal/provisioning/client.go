@@ -6,8 +6,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -121,6 +126,149 @@ func (c *Client) DeleteNamespace(ctx context.Context, namespace string) error {
 	return nil
 }
 
+// RestartDeployment rolls the pods of a deployment like kubectl rollout restart
+func (c *Client) RestartDeployment(ctx context.Context, namespace, name string) error {
+	if c.k8sClient == nil {
+		return fmt.Errorf("kubernetes client not connected")
+	}
+
+	patch := fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{"kubectl.kubernetes.io/restartedAt":%q}}}}}`,
+		time.Now().Format(time.RFC3339))
+	_, err := c.k8sClient.AppsV1().Deployments(namespace).Patch(ctx, name, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to restart deployment %s/%s: %w", namespace, name, err)
+	}
+
+	return nil
+}
+
+// ScaleDeployment sets the number of pods of a deployment, 0 stops it but keeps its configuration
+func (c *Client) ScaleDeployment(ctx context.Context, namespace, name string, replicas int32) error {
+	if c.k8sClient == nil {
+		return fmt.Errorf("kubernetes client not connected")
+	}
+
+	patch := fmt.Sprintf(`{"spec":{"replicas":%d}}`, replicas)
+	_, err := c.k8sClient.AppsV1().Deployments(namespace).Patch(ctx, name, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to scale deployment %s/%s: %w", namespace, name, err)
+	}
+
+	return nil
+}
+
+// ContainerPatch changes the image and environment of one container of a deployment
+type ContainerPatch struct {
+	Name      string
+	Image     string            // Empty keeps the current image
+	SetEnv    map[string]string // Added or replaced by name
+	RemoveEnv []string
+}
+
+// PatchDeploymentContainers applies patches to the containers of a deployment, which rolls its pods
+func (c *Client) PatchDeploymentContainers(ctx context.Context, namespace, name string, patches []ContainerPatch) error {
+	if c.k8sClient == nil {
+		return fmt.Errorf("kubernetes client not connected")
+	}
+
+	containers := make([]map[string]any, 0, len(patches))
+	for _, p := range patches {
+		container := map[string]any{"name": p.Name}
+		if p.Image != "" {
+			container["image"] = p.Image
+		}
+
+		// env is merged by name, so only the listed variables change
+		env := []map[string]any{}
+		for _, key := range slices.Sorted(maps.Keys(p.SetEnv)) {
+			env = append(env, map[string]any{"name": key, "value": p.SetEnv[key]})
+		}
+		for _, key := range p.RemoveEnv {
+			env = append(env, map[string]any{"name": key, "$patch": "delete"})
+		}
+		if len(env) > 0 {
+			container["env"] = env
+		}
+		containers = append(containers, container)
+	}
+
+	patch, err := json.Marshal(map[string]any{
+		"spec": map[string]any{"template": map[string]any{"spec": map[string]any{"containers": containers}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode deployment patch: %w", err)
+	}
+
+	_, err = c.k8sClient.AppsV1().Deployments(namespace).Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to patch deployment %s/%s: %w", namespace, name, err)
+	}
+
+	return nil
+}
+
+// PodLogs returns the last tailLines log lines of the newest pod matching selector
+func (c *Client) PodLogs(ctx context.Context, namespace, selector string, tailLines int64) ([]string, error) {
+	if c.k8sClient == nil {
+		return nil, fmt.Errorf("kubernetes client not connected")
+	}
+
+	pods, err := c.k8sClient.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods in %s: %w", namespace, err)
+	}
+	if len(pods.Items) == 0 {
+		return nil, nil
+	}
+
+	newest := pods.Items[0]
+	for _, pod := range pods.Items[1:] {
+		if pod.CreationTimestamp.After(newest.CreationTimestamp.Time) {
+			newest = pod
+		}
+	}
+
+	raw, err := c.k8sClient.CoreV1().Pods(namespace).GetLogs(newest.Name, &corev1.PodLogOptions{
+		TailLines:  &tailLines,
+		Timestamps: true,
+	}).DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logs of pod %s/%s: %w", namespace, newest.Name, err)
+	}
+
+	logs := strings.TrimRight(string(raw), "\n")
+	if logs == "" {
+		return nil, nil
+	}
+	return strings.Split(logs, "\n"), nil
+}
+
+// httpRouteResource identifies Gateway API HTTPRoutes
+var httpRouteResource = schema.GroupVersionResource{
+	Group:    "gateway.networking.k8s.io",
+	Version:  "v1",
+	Resource: "httproutes",
+}
+
+// DeleteHTTPRoute deletes an HTTPRoute, ignoring routes that do not exist
+func (c *Client) DeleteHTTPRoute(ctx context.Context, namespace, name string) error {
+	if c.restConfig == nil {
+		return fmt.Errorf("kubernetes client not connected")
+	}
+
+	dc, err := dynamic.NewForConfig(c.restConfig)
+	if err != nil {
+		return err
+	}
+
+	err = dc.Resource(httpRouteResource).Namespace(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete HTTPRoute %s/%s: %w", namespace, name, err)
+	}
+
+	return nil
+}
+
 // applyMultiYAML applies multiple YAML documents separated by "---"
 func (c *Client) applyMultiYAML(ctx context.Context, yamlData []byte) error {
 	// Split by YAML document separator
@@ -48,3 +48,24 @@ func renderTemplate(template string, substitutions map[string]string) ([]byte, e
 
 	return b, nil
 }
+
+// HTTPRoute_V1 routes an instance hostname from the tenant Gateway to its n8n service
+type HTTPRoute_V1 struct {
+	Namespace        string
+	Hostname         string
+	GatewayName      string
+	GatewayNamespace string
+}
+
+func (t *HTTPRoute_V1) Template() string {
+	return "templates/httproute.yaml"
+}
+
+func (t *HTTPRoute_V1) Content() ([]byte, error) {
+	return renderTemplate(t.Template(), map[string]string{
+		"PLACEHOLDER_NAMESPACE":        t.Namespace,
+		"PLACEHOLDER_HOSTNAME":         t.Hostname,
+		"PLACEHOLDER_PARENT_GATEWAY":   t.GatewayName,
+		"PLACEHOLDER_PARENT_NAMESPACE": t.GatewayNamespace,
+	})
+}
@@ -0,0 +1,282 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Access policy auth modes
+const (
+	AccessAuthNone  = "none"
+	AccessAuthBasic = "basic"
+	AccessAuthRanx  = "ranx"
+)
+
+// DefaultPublicPaths are the n8n path prefixes that stay reachable when an
+// instance has an access policy, so production webhooks and forms keep working
+var DefaultPublicPaths = []string{
+	"/webhook/",
+	"/webhook-test/",
+	"/webhook-waiting/",
+	"/form/",
+	"/form-test/",
+	"/form-waiting/",
+}
+
+// AccessPolicy controls who can reach an instance through the tenant proxy
+type AccessPolicy struct {
+	InstanceID        string
+	AllowedCIDRs      []string
+	AuthMode          string
+	BasicAuthUsername string
+	PublicPaths       []string
+
+	basicAuthPasswordHash string
+	networks              []*net.IPNet
+	// verified remembers credentials that already passed bcrypt for the lifetime of the policy
+	verified sync.Map
+}
+
+// toDomainAccessPolicy maps a db.InstanceAccessPolicy to an AccessPolicy (domain layer)
+func toDomainAccessPolicy(p db.InstanceAccessPolicy) *AccessPolicy {
+	policy := &AccessPolicy{
+		InstanceID:            p.InstanceID,
+		AllowedCIDRs:          p.AllowedCidrs,
+		AuthMode:              p.AuthMode,
+		BasicAuthUsername:     p.BasicAuthUsername,
+		PublicPaths:           p.PublicPaths,
+		basicAuthPasswordHash: p.BasicAuthPasswordHash,
+	}
+	for _, cidr := range p.AllowedCidrs {
+		if _, network, err := net.ParseCIDR(cidr); err == nil {
+			policy.networks = append(policy.networks, network)
+		}
+	}
+	return policy
+}
+
+// defaultAccessPolicy returns the policy of an instance that has none stored
+func defaultAccessPolicy(instanceID string) *AccessPolicy {
+	return &AccessPolicy{
+		InstanceID:  instanceID,
+		AuthMode:    AccessAuthNone,
+		PublicPaths: DefaultPublicPaths,
+	}
+}
+
+// IsOpen reports whether the policy lets every request through
+func (p *AccessPolicy) IsOpen() bool {
+	return len(p.networks) == 0 && p.AuthMode == AccessAuthNone
+}
+
+// IsPublicPath reports whether the path is exempt from the policy
+func (p *AccessPolicy) IsPublicPath(path string) bool {
+	for _, prefix := range p.PublicPaths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsIP reports whether the client IP is inside the allowlist.
+// An empty allowlist allows every address.
+func (p *AccessPolicy) AllowsIP(ip string) bool {
+	if len(p.networks) == 0 {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range p.networks {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasBasicAuthPassword reports whether a basic auth password is stored
+func (p *AccessPolicy) HasBasicAuthPassword() bool {
+	return p.basicAuthPasswordHash != ""
+}
+
+// CheckBasicAuth reports whether the credentials match the policy's basic auth user
+func (p *AccessPolicy) CheckBasicAuth(username, password string) bool {
+	if p.BasicAuthUsername == "" || p.basicAuthPasswordHash == "" || username != p.BasicAuthUsername {
+		return false
+	}
+
+	digest := sha256.Sum256([]byte(username + ":" + password))
+	if _, ok := p.verified.Load(digest); ok {
+		return true
+	}
+	if bcrypt.CompareHashAndPassword([]byte(p.basicAuthPasswordHash), []byte(password)) != nil {
+		return false
+	}
+	p.verified.Store(digest, struct{}{})
+	return true
+}
+
+// GetInstanceAccessPolicy returns the access policy of an instance, or the open default
+func (s *Service) GetInstanceAccessPolicy(ctx context.Context, instanceID string) (*AccessPolicy, error) {
+	queries := s.getDB()
+
+	policy, err := queries.GetInstanceAccessPolicy(ctx, instanceID)
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			return defaultAccessPolicy(instanceID), nil
+		}
+		return nil, fmt.Errorf("failed to get access policy: %w", err)
+	}
+
+	return toDomainAccessPolicy(policy), nil
+}
+
+type UpdateAccessPolicyParams struct {
+	Member            Membership
+	InstanceID        string
+	AllowedCIDRs      []string
+	AuthMode          string
+	BasicAuthUsername string
+	BasicAuthPassword string // Empty keeps the current password
+	PublicPaths       []string
+}
+
+// UpdateInstanceAccessPolicy validates and stores the access policy of an instance owned by the member's organization
+func (s *Service) UpdateInstanceAccessPolicy(ctx context.Context, params UpdateAccessPolicyParams) (*AccessPolicy, error) {
+	if err := params.Member.Require(RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	queries := s.getDB()
+
+	instance, err := queries.GetInstance(ctx, params.InstanceID)
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			return nil, apperrs.Client(apperrs.CodeNotFound, "instance not found")
+		}
+		return nil, fmt.Errorf("failed to get instance: %w", err)
+	}
+
+	if instance.OrganizationID != params.Member.OrganizationID {
+		return nil, apperrs.Client(apperrs.CodeNotFound, "instance not found")
+	}
+
+	cidrs, err := normalizeCIDRs(params.AllowedCIDRs)
+	if err != nil {
+		return nil, err
+	}
+
+	publicPaths := make([]string, 0, len(params.PublicPaths))
+	for _, path := range params.PublicPaths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		if !strings.HasPrefix(path, "/") || path == "/" {
+			return nil, apperrs.Client(apperrs.CodeInvalidInput, fmt.Sprintf("invalid public path %q", path))
+		}
+		publicPaths = append(publicPaths, path)
+	}
+
+	current, err := s.GetInstanceAccessPolicy(ctx, params.InstanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	username := strings.TrimSpace(params.BasicAuthUsername)
+	passwordHash := ""
+
+	switch params.AuthMode {
+	case AccessAuthNone, AccessAuthRanx:
+		username = ""
+	case AccessAuthBasic:
+		if username == "" {
+			return nil, apperrs.Client(apperrs.CodeInvalidInput, "basic auth username is required")
+		}
+		if params.BasicAuthPassword != "" {
+			if len(params.BasicAuthPassword) < 8 {
+				return nil, apperrs.Client(apperrs.CodeInvalidInput, "basic auth password must be at least 8 characters")
+			}
+			hash, err := bcrypt.GenerateFromPassword([]byte(params.BasicAuthPassword), bcrypt.DefaultCost)
+			if err != nil {
+				return nil, apperrs.Server("failed to hash basic auth password", err)
+			}
+			passwordHash = string(hash)
+		} else if current.basicAuthPasswordHash != "" {
+			passwordHash = current.basicAuthPasswordHash
+		} else {
+			return nil, apperrs.Client(apperrs.CodeInvalidInput, "basic auth password is required")
+		}
+	default:
+		return nil, apperrs.Client(apperrs.CodeInvalidInput, "invalid auth mode")
+	}
+
+	// The Gateway can't enforce a policy, so take the direct route away before the policy applies
+	open := len(cidrs) == 0 && params.AuthMode == AccessAuthNone
+	if s.config.Routing.IsGateway() && !open {
+		if err := s.deleteInstanceRoute(ctx, instance.Namespace); err != nil {
+			return nil, apperrs.Server("failed to route instance through the proxy", err)
+		}
+	}
+
+	policy, err := queries.UpsertInstanceAccessPolicy(ctx, db.UpsertInstanceAccessPolicyParams{
+		InstanceID:            params.InstanceID,
+		AllowedCidrs:          cidrs,
+		AuthMode:              params.AuthMode,
+		BasicAuthUsername:     username,
+		BasicAuthPasswordHash: passwordHash,
+		PublicPaths:           publicPaths,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update access policy: %w", err)
+	}
+
+	if s.config.Routing.IsGateway() && open {
+		if err := s.applyInstanceRoute(ctx, instance.Namespace, instance.Subdomain); err != nil {
+			// The proxy keeps serving the instance
+			appctx.GetLogger(ctx).Error("failed to restore instance route", "namespace", instance.Namespace, "error", err)
+		}
+	}
+
+	return toDomainAccessPolicy(policy), nil
+}
+
+// normalizeCIDRs validates CIDR ranges, turning single addresses into host ranges
+func normalizeCIDRs(values []string) ([]string, error) {
+	cidrs := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, apperrs.Client(apperrs.CodeInvalidInput, fmt.Sprintf("invalid IP address %q", value))
+			}
+			if ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, apperrs.Client(apperrs.CodeInvalidInput, fmt.Sprintf("invalid CIDR range %q", value))
+		}
+		cidrs = append(cidrs, network.String())
+	}
+	return cidrs, nil
+}
@@ -0,0 +1,450 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/samber/lo"
+)
+
+// deletedOrganizationName replaces the name of organizations left behind by a deleted account,
+// personal organizations are named after their user
+const deletedOrganizationName = "Deleted account"
+
+type DeleteAccountParams struct {
+	UserID string
+	Email  string // Typed by the user to confirm, must match the account
+	Code   string // Authenticator or recovery code, required when two-factor authentication is on
+}
+
+// DeleteAccount deletes a user's account on their own request. Organizations only the user
+// belongs to lose their subscription and instances, shared organizations keep theirs.
+// The users row is kept with its personal data scrubbed, so billing records still resolve.
+func (s *Service) DeleteAccount(ctx context.Context, params DeleteAccountParams) error {
+	l := appctx.GetLogger(ctx)
+	queries := s.getDB()
+
+	user, err := queries.GetUserByID(ctx, params.UserID)
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			return apperrs.Client(apperrs.CodeNotFound, "user not found")
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.DeletedAt.Valid {
+		return apperrs.Client(apperrs.CodeNotFound, "user not found")
+	}
+
+	if normalizeEmail(params.Email) != normalizeEmail(user.Email) {
+		return apperrs.Client(apperrs.CodeInvalidInput, "type the email address of your account to confirm")
+	}
+	if user.TotpEnabledAt.Valid {
+		if err := s.VerifyTwoFactorCode(ctx, user.ID, params.Code); err != nil {
+			return err
+		}
+	}
+
+	// Nothing recorded while the account is deleted keeps where the request came from
+	ctx = appctx.WithClient(ctx, appctx.Client{})
+
+	abandoned, err := s.organizationsLeftWithoutMembers(ctx, queries, user.ID)
+	if err != nil {
+		return err
+	}
+
+	// Billing and instances are external, stop them before anything is deleted here,
+	// so a failure leaves an account the user can retry deleting
+	for _, orgID := range abandoned {
+		if err := s.cancelOrganizationSubscription(ctx, orgID, user.ID); err != nil {
+			return err
+		}
+		if _, err := s.DeleteAllOrganizationInstances(ctx, orgID); err != nil {
+			return apperrs.Server("failed to delete instances", err)
+		}
+	}
+
+	queries, tx := s.getDBWithTx(ctx)
+	defer tx.Rollback(ctx)
+
+	for _, orgID := range abandoned {
+		if err := queries.RenameOrganization(ctx, db.RenameOrganizationParams{ID: orgID, Name: deletedOrganizationName}); err != nil {
+			return fmt.Errorf("failed to rename organization: %w", err)
+		}
+	}
+	if err := queries.DeleteUserMemberships(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to delete memberships: %w", err)
+	}
+	if err := queries.DeleteUserIdentities(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to delete login identities: %w", err)
+	}
+	if err := queries.DeleteUserSessions(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	if err := queries.DeleteUserAPITokens(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to delete API tokens: %w", err)
+	}
+	if err := queries.DeleteRecoveryCodes(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	if err := queries.DeleteUserNotifications(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to delete notifications: %w", err)
+	}
+	if err := queries.DeleteUserNotificationPreferences(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to delete notification preferences: %w", err)
+	}
+	if err := queries.DeleteMagicLinkTokensByEmail(ctx, user.Email); err != nil {
+		return fmt.Errorf("failed to delete login links: %w", err)
+	}
+	// The events stay for the organizations, without the IP addresses and browsers of the user
+	if err := queries.ScrubUserAuditEvents(ctx, &user.ID); err != nil {
+		return fmt.Errorf("failed to scrub audit events: %w", err)
+	}
+	if err := queries.TombstoneUser(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return apperrs.Server("failed to commit transaction", err)
+	}
+
+	s.recordAudit(ctx, auditEntry{
+		UserID:     user.ID,
+		Action:     AuditActionUserDelete,
+		TargetType: "user",
+		TargetID:   user.ID,
+	})
+
+	l.Info("deleted account", "user_id", user.ID, "organizations_closed", len(abandoned))
+	return nil
+}
+
+// organizationsLeftWithoutMembers returns the organizations that have no one left once the user is gone.
+// It fails when the user is the last owner of an organization other members still use.
+func (s *Service) organizationsLeftWithoutMembers(ctx context.Context, queries *db.Queries, userID string) ([]string, error) {
+	orgs, err := queries.ListUserOrganizations(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+
+	var abandoned []string
+	for _, org := range orgs {
+		if org.Personal {
+			abandoned = append(abandoned, org.ID)
+			continue
+		}
+
+		members, err := queries.ListOrganizationMembers(ctx, org.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list organization members: %w", err)
+		}
+		if len(members) == 1 {
+			abandoned = append(abandoned, org.ID)
+			continue
+		}
+
+		if org.Role != RoleOwner {
+			continue
+		}
+		owners, err := queries.CountOrganizationOwners(ctx, org.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count organization owners: %w", err)
+		}
+		if owners == 1 {
+			return nil, apperrs.Client(apperrs.CodeConflict,
+				fmt.Sprintf("make another member an owner of %s before deleting your account", org.Name))
+		}
+	}
+	return abandoned, nil
+}
+
+// cancelOrganizationSubscription stops the LemonSqueezy subscription of an organization from renewing
+func (s *Service) cancelOrganizationSubscription(ctx context.Context, organizationID, userID string) error {
+	l := appctx.GetLogger(ctx)
+	queries := s.getDB()
+
+	sub, err := queries.GetSubscriptionByOrganizationID(ctx, organizationID)
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	// Trials have no LemonSqueezy subscription
+	if sub.SubscriptionID == "" || sub.Status == SubscriptionStatusCanceled || sub.Status == SubscriptionStatusExpired {
+		return nil
+	}
+
+	if err := s.lemonsqueezy.CancelSubscription(ctx, sub.SubscriptionID); err != nil {
+		return apperrs.Server("failed to cancel subscription", err)
+	}
+	l.Info("cancelled subscription", "organization_id", organizationID, "subscription_id", sub.SubscriptionID)
+
+	// The cancellation webhook sets the same status, this covers the time until it arrives
+	err = queries.UpdateSubscriptionStatusByProviderID(ctx, db.UpdateSubscriptionStatusByProviderIDParams{
+		SubscriptionID: sub.SubscriptionID,
+		Status:         SubscriptionStatusCanceled,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update subscription status: %w", err)
+	}
+
+	s.recordAudit(ctx, auditEntry{
+		OrganizationID: organizationID,
+		UserID:         userID,
+		Action:         AuditActionSubscriptionPrefix + "cancelled",
+		TargetType:     "subscription",
+		TargetID:       sub.ID,
+		Metadata:       map[string]string{"status": SubscriptionStatusCanceled, "reason": "account deleted"},
+	})
+	return nil
+}
+
+// AccountExport is everything ranx stores about a user, downloaded as JSON
+type AccountExport struct {
+	ExportedAt    time.Time                   `json:"exported_at"`
+	Profile       AccountExportProfile        `json:"profile"`
+	Identities    []AccountExportIdentity     `json:"login_identities"`
+	Organizations []AccountExportOrganization `json:"organizations"`
+	Subscriptions []AccountExportSubscription `json:"subscriptions"`
+	Instances     []AccountExportInstance     `json:"instances"`
+	Sessions      []AccountExportSession      `json:"sessions"`
+	AuditEvents   []AccountExportAuditEvent   `json:"audit_events"`
+	Notifications []AccountExportNotification `json:"notifications"`
+}
+
+type AccountExportProfile struct {
+	ID               string     `json:"id"`
+	Email            string     `json:"email"`
+	Name             string     `json:"name"`
+	TwoFactorEnabled bool       `json:"two_factor_enabled"`
+	CreatedAt        time.Time  `json:"created_at"`
+	LastLoginAt      *time.Time `json:"last_login_at"`
+}
+
+type AccountExportIdentity struct {
+	Provider   string    `json:"provider"`
+	Email      string    `json:"email"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+type AccountExportOrganization struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Personal bool   `json:"personal"`
+	Role     string `json:"role"`
+}
+
+type AccountExportSubscription struct {
+	ID             string     `json:"id"`
+	OrganizationID string     `json:"organization_id"`
+	ProductID      string     `json:"product_id"`
+	VariantID      string     `json:"variant_id"`
+	Status         string     `json:"status"`
+	Quantity       int32      `json:"quantity"`
+	TrialEndsAt    *time.Time `json:"trial_ends_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+type AccountExportInstance struct {
+	ID             string     `json:"id"`
+	OrganizationID string     `json:"organization_id"`
+	Subdomain      string     `json:"subdomain"`
+	Status         string     `json:"status"`
+	AppVersion     string     `json:"app_version"`
+	CreatedAt      *time.Time `json:"created_at"`
+	DeployedAt     *time.Time `json:"deployed_at"`
+	DeletedAt      *time.Time `json:"deleted_at"`
+}
+
+type AccountExportSession struct {
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+type AccountExportAuditEvent struct {
+	OrganizationID string            `json:"organization_id,omitempty"`
+	Action         string            `json:"action"`
+	TargetType     string            `json:"target_type"`
+	TargetID       string            `json:"target_id"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	IPAddress      string            `json:"ip_address"`
+	UserAgent      string            `json:"user_agent"`
+	CreatedAt      time.Time         `json:"created_at"`
+}
+
+type AccountExportNotification struct {
+	Kind      string     `json:"kind"`
+	Recipient string     `json:"recipient"`
+	Subject   string     `json:"subject"`
+	Text      string     `json:"text"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	SentAt    *time.Time `json:"sent_at"`
+}
+
+// ExportAccount collects the data of a user for a download. Instances are exported as
+// metadata only, their workflows live in n8n and are exported there.
+func (s *Service) ExportAccount(ctx context.Context, userID string) (*AccountExport, error) {
+	queries := s.getDB()
+
+	user, err := queries.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	export := &AccountExport{
+		ExportedAt: time.Now().UTC(),
+		Profile: AccountExportProfile{
+			ID:               user.ID,
+			Email:            user.Email,
+			Name:             user.Name,
+			TwoFactorEnabled: user.TotpEnabledAt.Valid,
+			CreatedAt:        user.CreatedAt.Time,
+			LastLoginAt:      timestampPtr(user.LastLoginAt),
+		},
+		Identities:    []AccountExportIdentity{},
+		Organizations: []AccountExportOrganization{},
+		Subscriptions: []AccountExportSubscription{},
+		Instances:     []AccountExportInstance{},
+		Sessions:      []AccountExportSession{},
+		AuditEvents:   []AccountExportAuditEvent{},
+		Notifications: []AccountExportNotification{},
+	}
+
+	identities, err := queries.ListUserIdentities(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list login identities: %w", err)
+	}
+	for _, identity := range identities {
+		export.Identities = append(export.Identities, AccountExportIdentity{
+			Provider:   identity.Provider,
+			Email:      identity.Email,
+			CreatedAt:  identity.CreatedAt.Time,
+			LastUsedAt: identity.LastUsedAt.Time,
+		})
+	}
+
+	orgs, err := queries.ListUserOrganizations(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+	for _, org := range orgs {
+		export.Organizations = append(export.Organizations, AccountExportOrganization{
+			ID:       org.ID,
+			Name:     org.Name,
+			Personal: org.Personal,
+			Role:     org.Role,
+		})
+	}
+
+	subs, err := queries.ListUserSubscriptions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+	for _, sub := range subs {
+		export.Subscriptions = append(export.Subscriptions, AccountExportSubscription{
+			ID:             sub.ID,
+			OrganizationID: sub.OrganizationID,
+			ProductID:      sub.ProductID,
+			VariantID:      sub.VariantID,
+			Status:         sub.Status,
+			Quantity:       sub.Quantity,
+			TrialEndsAt:    timestampPtr(sub.TrialEndsAt),
+			CreatedAt:      sub.CreatedAt.Time,
+			UpdatedAt:      sub.UpdatedAt.Time,
+		})
+	}
+
+	instances, err := queries.ListUserInstances(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", err)
+	}
+	for _, inst := range instances {
+		export.Instances = append(export.Instances, AccountExportInstance{
+			ID:             inst.ID,
+			OrganizationID: inst.OrganizationID,
+			Subdomain:      inst.Subdomain,
+			Status:         inst.Status,
+			AppVersion:     inst.AppVersion,
+			CreatedAt:      timestampPtr(inst.CreatedAt),
+			DeployedAt:     timestampPtr(inst.DeployedAt),
+			DeletedAt:      timestampPtr(inst.DeletedAt),
+		})
+	}
+
+	sessions, err := queries.ListActiveUserSessions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	for _, session := range sessions {
+		export.Sessions = append(export.Sessions, AccountExportSession{
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IpAddress,
+			CreatedAt:  session.CreatedAt.Time,
+			LastSeenAt: session.LastSeenAt.Time,
+		})
+	}
+
+	// The user's actions, events of their organizations by other actors belong to those organizations
+	events, err := queries.ListUserAuditEvents(ctx, db.ListUserAuditEventsParams{
+		ActorUserID: &userID,
+		MaxEvents:   maxAuditExportEvents,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	for _, event := range events {
+		exported := AccountExportAuditEvent{
+			OrganizationID: lo.FromPtr(event.OrganizationID),
+			Action:         event.Action,
+			TargetType:     event.TargetType,
+			TargetID:       event.TargetID,
+			IPAddress:      event.IpAddress,
+			UserAgent:      event.UserAgent,
+			CreatedAt:      event.CreatedAt.Time,
+		}
+		if err := json.Unmarshal(event.Metadata, &exported.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to decode audit metadata: %w", err)
+		}
+		export.AuditEvents = append(export.AuditEvents, exported)
+	}
+
+	notifications, err := queries.ListUserNotifications(ctx, db.ListUserNotificationsParams{
+		UserID:  userID,
+		MaxRows: maxUserNotifications,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notifications: %w", err)
+	}
+	for _, n := range notifications {
+		export.Notifications = append(export.Notifications, AccountExportNotification{
+			Kind:      n.Kind,
+			Recipient: n.Recipient,
+			Subject:   n.Subject,
+			Text:      n.TextBody,
+			Status:    n.Status,
+			CreatedAt: n.CreatedAt.Time,
+			SentAt:    timestampPtr(n.SentAt),
+		})
+	}
+
+	return export, nil
+}
+
+func timestampPtr(ts pgtype.Timestamp) *time.Time {
+	if !ts.Valid {
+		return nil
+	}
+	return &ts.Time
+}
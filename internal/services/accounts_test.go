@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/aliuygur/n8n-saas-api/internal/testdb"
+)
+
+// withTestClient adds a client to the context like a dashboard request
+func withTestClient(ctx context.Context) context.Context {
+	return appctx.WithClient(ctx, appctx.Client{IP: "203.0.113.7", UserAgent: "Firefox"})
+}
+
+func TestDeleteAccount(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := withTestClient(testdb.Context())
+	member := createTestUser(t, s, "leaving")
+
+	if _, _, err := s.CreateSession(ctx, CreateSessionParams{UserID: member.UserID, UserAgent: "Firefox", IPAddress: "203.0.113.7"}); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	err := s.DeleteAccount(ctx, DeleteAccountParams{UserID: member.UserID, Email: "someone@example.com"})
+	if !apperrs.CodeIs(err, apperrs.CodeInvalidInput) {
+		t.Fatalf("Expected a wrong confirmation email to be rejected, got %v", err)
+	}
+
+	if err := s.DeleteAccount(ctx, DeleteAccountParams{UserID: member.UserID, Email: " Leaving@Example.com "}); err != nil {
+		t.Fatalf("Expected the account to be deleted, got %v", err)
+	}
+
+	queries := s.getDB()
+	user, err := queries.GetUserByID(ctx, member.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.DeletedAt.Valid || user.Email == "leaving@example.com" || user.Name != "" || user.CurrentOrganizationID != nil {
+		t.Errorf("Expected the personal data to be scrubbed, got %+v", user)
+	}
+	if _, err := queries.GetUserByEmail(ctx, "leaving@example.com"); !db.IsNotFoundError(err) {
+		t.Errorf("Expected the email to be free for a new signup, got %v", err)
+	}
+
+	sessions, err := queries.ListActiveUserSessions(ctx, member.UserID)
+	if err != nil || len(sessions) != 0 {
+		t.Errorf("Expected the sessions to be deleted, got %d %v", len(sessions), err)
+	}
+	org, err := queries.GetOrganization(ctx, member.OrganizationID)
+	if err != nil || org.Name != deletedOrganizationName {
+		t.Errorf("Expected the personal organization to be renamed, got %q %v", org.Name, err)
+	}
+
+	// The events stay, without where the requests came from
+	var events, withClient int
+	err = s.pool.QueryRow(ctx, `SELECT count(*), count(*) FILTER (WHERE ip_address <> '' OR user_agent <> '')
+		FROM audit_events WHERE actor_user_id = $1`, member.UserID).Scan(&events, &withClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events < 2 || withClient != 0 {
+		t.Errorf("Expected the login and deletion to be kept without client details, got %d events, %d with client", events, withClient)
+	}
+
+	if err := s.DeleteAccount(ctx, DeleteAccountParams{UserID: member.UserID, Email: user.Email}); !apperrs.CodeIs(err, apperrs.CodeNotFound) {
+		t.Errorf("Expected a deleted account to be gone, got %v", err)
+	}
+}
+
+func TestDeleteAccountSharedOrganizations(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := testdb.Context()
+	owner := createTestUser(t, s, "owner")
+	member := createTestUser(t, s, "member")
+
+	org, err := s.CreateOrganization(ctx, owner.UserID, "Acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.getDB().CreateOrganizationMember(ctx, db.CreateOrganizationMemberParams{OrganizationID: org.ID, UserID: member.UserID, Role: RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Someone has to own what the other member keeps using
+	err = s.DeleteAccount(ctx, DeleteAccountParams{UserID: owner.UserID, Email: "owner@example.com"})
+	if !apperrs.CodeIs(err, apperrs.CodeConflict) {
+		t.Fatalf("Expected the last owner to be asked to hand over the organization, got %v", err)
+	}
+
+	if err := s.DeleteAccount(ctx, DeleteAccountParams{UserID: member.UserID, Email: "member@example.com"}); err != nil {
+		t.Fatalf("Expected the member to be able to leave, got %v", err)
+	}
+	shared, err := s.getDB().GetOrganization(ctx, org.ID)
+	if err != nil || shared.Name != "Acme" {
+		t.Errorf("Expected the shared organization to be kept, got %q %v", shared.Name, err)
+	}
+	members, err := s.getDB().ListOrganizationMembers(ctx, org.ID)
+	if err != nil || len(members) != 1 {
+		t.Errorf("Expected only the owner to be left, got %d members %v", len(members), err)
+	}
+}
+
+func TestExportAccount(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := withTestClient(testdb.Context())
+	member := createTestUser(t, s, "export")
+	createTestInstance(t, s, member, "export")
+
+	if _, _, err := s.CreateSession(ctx, CreateSessionParams{UserID: member.UserID, UserAgent: "Firefox", IPAddress: "203.0.113.7"}); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	export, err := s.ExportAccount(ctx, member.UserID)
+	if err != nil {
+		t.Fatalf("Failed to export account: %v", err)
+	}
+
+	if export.Profile.ID != member.UserID || export.Profile.Email != "export@example.com" {
+		t.Errorf("Unexpected profile %+v", export.Profile)
+	}
+	if len(export.Organizations) != 1 || !export.Organizations[0].Personal || export.Organizations[0].Role != RoleOwner {
+		t.Errorf("Expected the personal organization, got %+v", export.Organizations)
+	}
+	if len(export.Subscriptions) != 1 || export.Subscriptions[0].Status != SubscriptionStatusTrial {
+		t.Errorf("Expected the trial subscription, got %+v", export.Subscriptions)
+	}
+	if len(export.Instances) != 1 || export.Instances[0].Subdomain != "export" {
+		t.Errorf("Expected the instance, got %+v", export.Instances)
+	}
+	if len(export.Sessions) != 1 || export.Sessions[0].IPAddress != "203.0.113.7" {
+		t.Errorf("Expected the session, got %+v", export.Sessions)
+	}
+	if !slices.ContainsFunc(export.AuditEvents, func(e AccountExportAuditEvent) bool {
+		return e.Action == AuditActionUserLogin && e.IPAddress == "203.0.113.7" && e.UserAgent == "Firefox"
+	}) {
+		t.Errorf("Expected the login event, got %+v", export.AuditEvents)
+	}
+
+	// Empty sections are exported as lists, not null
+	var raw map[string]json.RawMessage
+	b, err := json.Marshal(export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if string(raw["login_identities"]) != "[]" {
+		t.Errorf("Expected login_identities to be an empty list, got %s", raw["login_identities"])
+	}
+}
@@ -0,0 +1,419 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// maxAdminRows limits how many rows each list of the admin console shows
+	maxAdminRows = 50
+	// maxTrialExtensionDays limits how far one extension pushes a trial
+	maxTrialExtensionDays = 30
+)
+
+// AdminUser is a user as operators see it
+type AdminUser struct {
+	ID          string
+	Email       string
+	Name        string
+	IsAdmin     bool
+	CreatedAt   time.Time
+	LastLoginAt *time.Time
+	DeletedAt   *time.Time
+
+	TwoFactorEnabled bool
+}
+
+// AdminSubscription is a subscription with the organization it pays for
+type AdminSubscription struct {
+	Subscription
+	OrganizationName string
+	UserEmail        string
+}
+
+// AdminInstance is an instance with the organization that owns it
+type AdminInstance struct {
+	Instance
+	OrganizationName string
+	UserEmail        string // The member who created the instance
+}
+
+// AdminCheckoutSession is a checkout that didn't complete
+type AdminCheckoutSession struct {
+	ID        string
+	UserEmail string
+	Subdomain string
+	Status    string
+	CreatedAt time.Time
+}
+
+// ProvisioningFailures are the instances that didn't come up as expected
+type ProvisioningFailures struct {
+	Instances []AdminInstance        // Failed, or still without an n8n owner long after provisioning
+	Events    []AuditEvent           // Recorded AuditActionInstanceProvisionFail events
+	Checkouts []AdminCheckoutSession // Checkouts that never completed
+}
+
+// requireAdmin checks that the user operates ranx. Handlers already check the session,
+// this guards the actions that change customer data.
+func (s *Service) requireAdmin(ctx context.Context, userID string) error {
+	user, err := s.getDB().GetUserByID(ctx, userID)
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			return apperrs.Client(apperrs.CodeForbidden, "admin access required")
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.IsAdmin || user.DeletedAt.Valid {
+		return apperrs.Client(apperrs.CodeForbidden, "admin access required")
+	}
+	return nil
+}
+
+// AdminListUsers returns the newest users matching search
+func (s *Service) AdminListUsers(ctx context.Context, search string) ([]AdminUser, error) {
+	rows, err := s.getDB().AdminListUsers(ctx, db.AdminListUsersParams{
+		Search:  adminSearch(search),
+		MaxRows: maxAdminRows,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	users := make([]AdminUser, 0, len(rows))
+	for _, row := range rows {
+		users = append(users, AdminUser{
+			ID:          row.ID,
+			Email:       row.Email,
+			Name:        row.Name,
+			IsAdmin:     row.IsAdmin,
+			CreatedAt:   row.CreatedAt.Time,
+			LastLoginAt: timestampPtr(row.LastLoginAt),
+			DeletedAt:   timestampPtr(row.DeletedAt),
+
+			TwoFactorEnabled: row.TwoFactorEnabled,
+		})
+	}
+	return users, nil
+}
+
+// AdminListSubscriptions returns the newest subscriptions matching search
+func (s *Service) AdminListSubscriptions(ctx context.Context, search string) ([]AdminSubscription, error) {
+	rows, err := s.getDB().AdminListSubscriptions(ctx, db.AdminListSubscriptionsParams{
+		Search:  adminSearch(search),
+		MaxRows: maxAdminRows,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+
+	subscriptions := make([]AdminSubscription, 0, len(rows))
+	for _, row := range rows {
+		sub := toDomainSubscription(db.Subscription{
+			ID:             row.ID,
+			UserID:         row.UserID,
+			ProductID:      row.ProductID,
+			VariantID:      row.VariantID,
+			CustomerID:     row.CustomerID,
+			SubscriptionID: row.SubscriptionID,
+			Status:         row.Status,
+			Quantity:       row.Quantity,
+			TrialEndsAt:    row.TrialEndsAt,
+			CreatedAt:      row.CreatedAt,
+			UpdatedAt:      row.UpdatedAt,
+			OrganizationID: row.OrganizationID,
+		})
+		subscriptions = append(subscriptions, AdminSubscription{
+			Subscription:     *sub,
+			OrganizationName: row.OrganizationName,
+			UserEmail:        row.UserEmail,
+		})
+	}
+	return subscriptions, nil
+}
+
+// AdminListInstances returns the newest instances matching search, deleted ones included
+func (s *Service) AdminListInstances(ctx context.Context, search string) ([]AdminInstance, error) {
+	rows, err := s.getDB().AdminListInstances(ctx, db.AdminListInstancesParams{
+		Search:  adminSearch(search),
+		MaxRows: maxAdminRows,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", err)
+	}
+
+	instances := make([]AdminInstance, 0, len(rows))
+	for _, row := range rows {
+		instances = append(instances, toAdminInstance(row))
+	}
+	return instances, nil
+}
+
+func toAdminInstance(row db.AdminListInstancesRow) AdminInstance {
+	return AdminInstance{
+		Instance: toDomainInstance(db.Instance{
+			ID:             row.ID,
+			UserID:         row.UserID,
+			Status:         row.Status,
+			Namespace:      row.Namespace,
+			Subdomain:      row.Subdomain,
+			CreatedAt:      row.CreatedAt,
+			UpdatedAt:      row.UpdatedAt,
+			DeployedAt:     row.DeployedAt,
+			DeletedAt:      row.DeletedAt,
+			AppVersion:     row.AppVersion,
+			CustomEnv:      row.CustomEnv,
+			OrganizationID: row.OrganizationID,
+			OwnerSetupAt:   row.OwnerSetupAt,
+		}),
+		OrganizationName: row.OrganizationName,
+		UserEmail:        row.UserEmail,
+	}
+}
+
+// AdminListNotifications returns the sent-message log, newest first, of recipients matching search
+func (s *Service) AdminListNotifications(ctx context.Context, search string) ([]Notification, error) {
+	rows, err := s.getDB().AdminListNotifications(ctx, db.AdminListNotificationsParams{
+		Search:  adminSearch(search),
+		MaxRows: maxAdminRows,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notifications: %w", err)
+	}
+
+	notifications := make([]Notification, 0, len(rows))
+	for _, row := range rows {
+		notifications = append(notifications, Notification{
+			ID:        row.ID,
+			UserID:    row.UserID,
+			Kind:      row.Kind,
+			Recipient: row.Recipient,
+			Subject:   row.Subject,
+			Status:    row.Status,
+			Error:     row.Error,
+			CreatedAt: row.CreatedAt.Time,
+			SentAt:    timestampPtr(row.SentAt),
+		})
+	}
+	return notifications, nil
+}
+
+// AdminProvisioningFailures returns the instances, events and checkouts operators should look into
+func (s *Service) AdminProvisioningFailures(ctx context.Context) (*ProvisioningFailures, error) {
+	queries := s.getDB()
+	failures := &ProvisioningFailures{}
+
+	stuck, err := queries.AdminListStuckInstances(ctx, db.AdminListStuckInstancesParams{
+		SetupDeadline: pgtype.Timestamp{Time: time.Now().Add(-ownerSetupTimeout), Valid: true},
+		MaxRows:       maxAdminRows,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stuck instances: %w", err)
+	}
+	for _, row := range stuck {
+		failures.Instances = append(failures.Instances, toAdminInstance(db.AdminListInstancesRow(row)))
+	}
+
+	events, err := queries.AdminListAuditEventsByAction(ctx, db.AdminListAuditEventsByActionParams{
+		Action:    AuditActionInstanceProvisionFail,
+		MaxEvents: maxAdminRows,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list provisioning failures: %w", err)
+	}
+	for _, row := range events {
+		event, err := toDomainAuditEvent(db.ListAuditEventsRow(row))
+		if err != nil {
+			return nil, err
+		}
+		failures.Events = append(failures.Events, event)
+	}
+
+	checkouts, err := queries.ListCheckoutSessions(ctx, maxAdminRows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list checkout sessions: %w", err)
+	}
+	for _, checkout := range checkouts {
+		if checkout.CompletedAt.Valid {
+			continue
+		}
+		failures.Checkouts = append(failures.Checkouts, AdminCheckoutSession{
+			ID:        checkout.ID,
+			UserEmail: checkout.UserEmail,
+			Subdomain: checkout.Subdomain,
+			Status:    checkout.Status,
+			CreatedAt: checkout.CreatedAt.Time,
+		})
+	}
+
+	return failures, nil
+}
+
+// GetImpersonatedSession returns a session acting as the user, in the organization they currently use.
+// Callers must only allow reads with it.
+func (s *Service) GetImpersonatedSession(ctx context.Context, userID string) (*Session, error) {
+	queries := s.getDB()
+
+	row, err := queries.GetUserMembership(ctx, userID)
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			return nil, apperrs.Client(apperrs.CodeNotFound, "user not found")
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	session := &Session{UserID: row.ID, UserEmail: row.Email}
+	if row.CurrentOrganizationID != nil && row.OrganizationRole.Valid {
+		session.Membership = Membership{
+			OrganizationID: *row.CurrentOrganizationID,
+			UserID:         row.ID,
+			Role:           row.OrganizationRole.String,
+		}
+		return session, nil
+	}
+
+	// Unlike a login, viewing the account doesn't switch the user back to their personal organization
+	org, err := queries.GetPersonalOrganization(ctx, row.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get personal organization: %w", err)
+	}
+	session.Membership = Membership{OrganizationID: org.ID, UserID: row.ID, Role: RoleOwner}
+	return session, nil
+}
+
+// StartImpersonation records that an admin views the dashboard as a user and returns their session
+func (s *Service) StartImpersonation(ctx context.Context, adminID, userID string) (*Session, error) {
+	if err := s.requireAdmin(ctx, adminID); err != nil {
+		return nil, err
+	}
+	if adminID == userID {
+		return nil, apperrs.Client(apperrs.CodeInvalidInput, "you can't impersonate yourself")
+	}
+
+	session, err := s.GetImpersonatedSession(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordAudit(ctx, auditEntry{
+		OrganizationID: session.Membership.OrganizationID,
+		UserID:         adminID,
+		Action:         AuditActionUserImpersonate,
+		TargetType:     "user",
+		TargetID:       userID,
+	})
+	return session, nil
+}
+
+// AdminExtendTrial pushes the end of an organization's trial back by days, counting from now once it ended
+func (s *Service) AdminExtendTrial(ctx context.Context, adminID, organizationID string, days int) (*Subscription, error) {
+	if err := s.requireAdmin(ctx, adminID); err != nil {
+		return nil, err
+	}
+	if days < 1 || days > maxTrialExtensionDays {
+		return nil, apperrs.Client(apperrs.CodeInvalidInput, fmt.Sprintf("a trial can be extended by 1 to %d days", maxTrialExtensionDays))
+	}
+
+	queries, tx := s.getDBWithTx(ctx)
+	defer tx.Rollback(ctx)
+
+	sub, err := queries.GetSubscriptionByOrganizationID(ctx, organizationID)
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			return nil, apperrs.Client(apperrs.CodeNotFound, "subscription not found")
+		}
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+	// Trials on LemonSqueezy are billed by LemonSqueezy, only the free trial is ours to extend
+	if sub.Status != SubscriptionStatusTrial && !toDomainSubscription(sub).IsExpiredFreeTrial() {
+		return nil, apperrs.Client(apperrs.CodeConflict, "only free trials can be extended")
+	}
+
+	trialEndsAt := time.Now()
+	if sub.TrialEndsAt.Valid && sub.TrialEndsAt.Time.After(trialEndsAt) {
+		trialEndsAt = sub.TrialEndsAt.Time
+	}
+	trialEndsAt = trialEndsAt.AddDate(0, 0, days)
+
+	updated, err := queries.RestartTrial(ctx, db.RestartTrialParams{
+		ID:          sub.ID,
+		TrialEndsAt: pgtype.Timestamp{Time: trialEndsAt, Valid: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to extend trial: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// Instances hibernated when the trial expired run again for the extension
+	if err := s.wakeHibernatedInstances(ctx, organizationID); err != nil {
+		return nil, apperrs.Server("trial extended but failed to start its instances", err)
+	}
+
+	s.recordAudit(ctx, auditEntry{
+		OrganizationID: organizationID,
+		UserID:         adminID,
+		Action:         AuditActionSubscriptionTrialExtend,
+		TargetType:     "subscription",
+		TargetID:       sub.ID,
+		Metadata: map[string]string{
+			"days":          strconv.Itoa(days),
+			"trial_ends_at": trialEndsAt.UTC().Format(time.RFC3339),
+		},
+	})
+	s.publishSubscriptionUpdated(ctx, updated)
+	return toDomainSubscription(updated), nil
+}
+
+// AdminRestartInstance restarts any customer's instance
+func (s *Service) AdminRestartInstance(ctx context.Context, adminID, instanceID string) error {
+	member, err := s.adminInstanceMembership(ctx, adminID, instanceID)
+	if err != nil {
+		return err
+	}
+	return s.RestartInstance(ctx, member, instanceID)
+}
+
+// AdminDeleteInstance deletes any customer's instance, whatever state it is in
+func (s *Service) AdminDeleteInstance(ctx context.Context, adminID, instanceID string) error {
+	member, err := s.adminInstanceMembership(ctx, adminID, instanceID)
+	if err != nil {
+		return err
+	}
+	return s.DeleteInstance(ctx, DeleteInstanceParams{Member: member, InstanceID: instanceID})
+}
+
+// adminInstanceMembership lets an admin act as an owner of the organization of an instance.
+// Audit events keep the admin as the actor.
+func (s *Service) adminInstanceMembership(ctx context.Context, adminID, instanceID string) (Membership, error) {
+	if err := s.requireAdmin(ctx, adminID); err != nil {
+		return Membership{}, err
+	}
+
+	instance, err := s.getDB().GetInstance(ctx, instanceID)
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			return Membership{}, apperrs.Client(apperrs.CodeNotFound, "instance not found")
+		}
+		return Membership{}, fmt.Errorf("failed to get instance: %w", err)
+	}
+	if instance.DeletedAt.Valid {
+		return Membership{}, apperrs.Client(apperrs.CodeConflict, "instance is already deleted")
+	}
+
+	return Membership{OrganizationID: instance.OrganizationID, UserID: adminID, Role: RoleOwner}, nil
+}
+
+// adminSearch turns an empty search into NULL, which lists everything
+func adminSearch(search string) pgtype.Text {
+	return pgtype.Text{String: search, Valid: search != ""}
+}
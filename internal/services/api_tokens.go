@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// API token scopes
+const (
+	ScopeInstancesRead    = "instances:read"
+	ScopeInstancesWrite   = "instances:write"
+	ScopeSubscriptionRead = "subscription:read"
+)
+
+// APITokenScopes lists every scope a token can be granted
+var APITokenScopes = []string{ScopeInstancesRead, ScopeInstancesWrite, ScopeSubscriptionRead}
+
+const (
+	// apiTokenPrefix marks ranx.cloud tokens so secret scanners can recognize them
+	apiTokenPrefix = "ranx_"
+	// apiTokenDisplayLength is how much of the token is kept to recognize it in the list
+	apiTokenDisplayLength = len(apiTokenPrefix) + 6
+	// maxAPITokensPerUser limits how many tokens a user can create
+	maxAPITokensPerUser = 20
+	// apiTokenTouchInterval limits how often last_used_at is written
+	apiTokenTouchInterval = time.Minute
+)
+
+// APIToken is a personal access token for the JSON API
+type APIToken struct {
+	ID          string
+	UserID      string
+	Membership  Membership // Tokens act in the organization they were created in
+	Name        string
+	TokenPrefix string
+	Scopes      []string
+	CreatedAt   time.Time
+	LastUsedAt  *time.Time
+	ExpiresAt   *time.Time
+	// TwoFactorRequired is set when the organization requires two-factor authentication the user hasn't turned on
+	TwoFactorRequired bool
+}
+
+// HasScope reports whether the token was granted scope
+func (t *APIToken) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+type CreateAPITokenParams struct {
+	Member    Membership
+	Name      string
+	Scopes    []string
+	ExpiresIn time.Duration // Zero means the token doesn't expire
+}
+
+// CreateAPIToken creates a token and returns its secret, which is only available now
+func (s *Service) CreateAPIToken(ctx context.Context, params CreateAPITokenParams) (string, *APIToken, error) {
+	name := strings.TrimSpace(params.Name)
+	if name == "" || len(name) > 100 {
+		return "", nil, apperrs.Client(apperrs.CodeInvalidInput, "token name must be between 1 and 100 characters")
+	}
+	if len(params.Scopes) == 0 {
+		return "", nil, apperrs.Client(apperrs.CodeInvalidInput, "select at least one scope")
+	}
+	for _, scope := range params.Scopes {
+		if !slices.Contains(APITokenScopes, scope) {
+			return "", nil, apperrs.Client(apperrs.CodeInvalidInput, fmt.Sprintf("unknown scope %q", scope))
+		}
+	}
+
+	queries := s.getDB()
+
+	count, err := queries.CountUserAPITokens(ctx, params.Member.UserID)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to count API tokens: %w", err)
+	}
+	if count >= maxAPITokensPerUser {
+		return "", nil, apperrs.Client(apperrs.CodeConflict, fmt.Sprintf("you can have at most %d API tokens, revoke one first", maxAPITokensPerUser))
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, apperrs.Server("failed to generate API token", err)
+	}
+	token := apiTokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	var expiresAt pgtype.Timestamp
+	if params.ExpiresIn > 0 {
+		expiresAt = pgtype.Timestamp{Time: time.Now().Add(params.ExpiresIn), Valid: true}
+	}
+
+	scopes := slices.Clone(params.Scopes)
+	slices.Sort(scopes)
+
+	dbToken, err := queries.CreateAPIToken(ctx, db.CreateAPITokenParams{
+		UserID:         params.Member.UserID,
+		OrganizationID: params.Member.OrganizationID,
+		Name:           name,
+		TokenPrefix:    token[:apiTokenDisplayLength],
+		TokenHash:      hashAPIToken(token),
+		Scopes:         slices.Compact(scopes),
+		ExpiresAt:      expiresAt,
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create API token: %w", err)
+	}
+
+	t := toDomainAPIToken(dbToken)
+	t.Membership.Role = params.Member.Role
+	return token, t, nil
+}
+
+// AuthenticateAPIToken returns the active token for a bearer secret
+func (s *Service) AuthenticateAPIToken(ctx context.Context, token string) (*APIToken, error) {
+	if !strings.HasPrefix(token, apiTokenPrefix) {
+		return nil, apperrs.Client(apperrs.CodeUnauthorized, "invalid API token")
+	}
+
+	queries := s.getDB()
+
+	dbToken, err := queries.GetActiveAPITokenByHash(ctx, hashAPIToken(token))
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			return nil, apperrs.Client(apperrs.CodeUnauthorized, "invalid API token")
+		}
+		return nil, fmt.Errorf("failed to get API token: %w", err)
+	}
+
+	if !dbToken.LastUsedAt.Valid || time.Since(dbToken.LastUsedAt.Time) > apiTokenTouchInterval {
+		if err := queries.TouchAPIToken(ctx, dbToken.ID); err != nil {
+			return nil, fmt.Errorf("failed to touch API token: %w", err)
+		}
+	}
+
+	t := toDomainAPIToken(db.ApiToken{
+		ID:             dbToken.ID,
+		UserID:         dbToken.UserID,
+		OrganizationID: dbToken.OrganizationID,
+		Name:           dbToken.Name,
+		TokenPrefix:    dbToken.TokenPrefix,
+		TokenHash:      dbToken.TokenHash,
+		Scopes:         dbToken.Scopes,
+		CreatedAt:      dbToken.CreatedAt,
+		LastUsedAt:     dbToken.LastUsedAt,
+		ExpiresAt:      dbToken.ExpiresAt,
+	})
+	// The role follows the member, so demoting a member also limits their tokens
+	t.Membership.Role = dbToken.OrganizationRole
+	t.TwoFactorRequired = dbToken.TwoFactorRequired
+	return t, nil
+}
+
+// ListAPITokens returns the tokens the member created in their organization, newest first
+func (s *Service) ListAPITokens(ctx context.Context, member Membership) ([]APIToken, error) {
+	queries := s.getDB()
+
+	dbTokens, err := queries.ListUserAPITokens(ctx, db.ListUserAPITokensParams{
+		UserID:         member.UserID,
+		OrganizationID: member.OrganizationID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API tokens: %w", err)
+	}
+
+	tokens := make([]APIToken, 0, len(dbTokens))
+	for _, dbToken := range dbTokens {
+		tokens = append(tokens, *toDomainAPIToken(dbToken))
+	}
+	return tokens, nil
+}
+
+// RevokeAPIToken deletes a token of a user
+func (s *Service) RevokeAPIToken(ctx context.Context, userID, tokenID string) error {
+	queries := s.getDB()
+
+	rows, err := queries.DeleteUserAPIToken(ctx, db.DeleteUserAPITokenParams{
+		ID:     tokenID,
+		UserID: userID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke API token: %w", err)
+	}
+	if rows == 0 {
+		return apperrs.Client(apperrs.CodeNotFound, "API token not found")
+	}
+	return nil
+}
+
+func toDomainAPIToken(dbToken db.ApiToken) *APIToken {
+	t := &APIToken{
+		ID:     dbToken.ID,
+		UserID: dbToken.UserID,
+		Membership: Membership{
+			OrganizationID: dbToken.OrganizationID,
+			UserID:         dbToken.UserID,
+		},
+		Name:        dbToken.Name,
+		TokenPrefix: dbToken.TokenPrefix,
+		Scopes:      dbToken.Scopes,
+		CreatedAt:   dbToken.CreatedAt.Time,
+	}
+	if dbToken.LastUsedAt.Valid {
+		t.LastUsedAt = &dbToken.LastUsedAt.Time
+	}
+	if dbToken.ExpiresAt.Valid {
+		t.ExpiresAt = &dbToken.ExpiresAt.Time
+	}
+	return t
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/samber/lo"
+)
+
+// Audit actors, users act through the dashboard or the API, the others on their own
+const (
+	AuditActorUser         = "user"
+	AuditActorSystem       = "system"
+	AuditActorLemonSqueezy = "lemonsqueezy"
+)
+
+// Audited actions, named <target>.<verb>
+const (
+	AuditActionUserLogin        = "user.login"
+	AuditActionUserDelete       = "user.delete"
+	AuditActionUserImpersonate  = "user.impersonate"
+	AuditActionInstanceCreate   = "instance.create"
+	AuditActionInstanceUpdate   = "instance.update"
+	AuditActionInstanceRestart  = "instance.restart"
+	AuditActionInstanceDelete   = "instance.delete"
+	AuditActionInstanceTransfer = "instance.transfer"
+	// Provisioning failures are listed in the admin console
+	AuditActionInstanceProvisionFail   = "instance.provision_failed"
+	AuditActionSubscriptionTrialExtend = "subscription.trial_extend"
+	// Steps of the trial expiry job
+	AuditActionSubscriptionTrialEnding  = "subscription.trial_ending"
+	AuditActionSubscriptionTrialEnded   = "subscription.trial_ended"
+	AuditActionSubscriptionTrialExpired = "subscription.trial_expired"
+	// Transitions seen by the uptime monitor
+	AuditActionInstanceDown      = "instance.down"
+	AuditActionInstanceRecovered = "instance.recovered"
+	// Proxy limits changed in the admin console
+	AuditActionInstanceLimitsUpdate = "instance.limits_update"
+	AuditActionPlanLimitsUpdate     = "plan.limits_update"
+	// Two-factor authentication turned off by an admin for a user who lost their authenticator
+	AuditActionUserTwoFactorReset = "user.two_factor_reset"
+	// Subscription events are named after the LemonSqueezy webhook, e.g. subscription.payment_failed
+	AuditActionSubscriptionPrefix = "subscription."
+)
+
+// AuditCategories are the action prefixes the audit log can be filtered by
+var AuditCategories = []string{"user", "instance", "subscription"}
+
+const (
+	// maxAuditEvents limits how many events the account page shows
+	maxAuditEvents = 100
+	// maxAuditExportEvents limits how many events an export contains
+	maxAuditExportEvents = 10000
+)
+
+// AuditEvent is a recorded action
+type AuditEvent struct {
+	ID             string
+	OrganizationID string // Empty for events of the account itself
+	ActorType      string
+	ActorUserID    string
+	ActorEmail     string
+	Action         string
+	TargetType     string
+	TargetID       string
+	Metadata       map[string]string
+	IPAddress      string
+	UserAgent      string
+	RequestID      string
+	CreatedAt      time.Time
+}
+
+// auditEntry is an action to record
+type auditEntry struct {
+	OrganizationID string
+	UserID         string // The acting user, empty when the action wasn't taken by a user
+	Action         string
+	TargetType     string
+	TargetID       string
+	Metadata       map[string]string
+}
+
+type auditActorContextKey struct{}
+
+// withAuditActor attributes actions taken without a user, such as webhook-driven cleanups
+func withAuditActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, auditActorContextKey{}, actor)
+}
+
+// recordAudit appends an event to the audit log. The action already happened,
+// so a failure to record it is logged rather than returned.
+func (s *Service) recordAudit(ctx context.Context, entry auditEntry) {
+	l := appctx.GetLogger(ctx)
+
+	actorType := AuditActorUser
+	if entry.UserID == "" {
+		actorType = AuditActorSystem
+		if actor, ok := ctx.Value(auditActorContextKey{}).(string); ok {
+			actorType = actor
+		}
+	}
+
+	metadata := []byte("{}")
+	if len(entry.Metadata) > 0 {
+		var err error
+		if metadata, err = json.Marshal(entry.Metadata); err != nil {
+			l.Error("failed to encode audit metadata", "action", entry.Action, "error", err)
+			metadata = []byte("{}")
+		}
+	}
+
+	client := appctx.GetClient(ctx)
+	err := s.getDB().CreateAuditEvent(ctx, db.CreateAuditEventParams{
+		OrganizationID: lo.EmptyableToPtr(entry.OrganizationID),
+		ActorType:      actorType,
+		ActorUserID:    lo.EmptyableToPtr(entry.UserID),
+		Action:         entry.Action,
+		TargetType:     entry.TargetType,
+		TargetID:       entry.TargetID,
+		Metadata:       metadata,
+		IpAddress:      client.IP,
+		UserAgent:      truncate(client.UserAgent, 512),
+		RequestID:      appctx.GetRequestID(ctx),
+	})
+	if err != nil {
+		l.Error("failed to record audit event", "action", entry.Action, "target_id", entry.TargetID, "error", err)
+	}
+}
+
+type ListAuditEventsParams struct {
+	Member   Membership
+	Category string // One of AuditCategories, empty for all
+	ActorID  string // Only events of this user, empty for all
+	Since    time.Time
+	Export   bool // Raises the limit for downloads
+}
+
+// ListAuditEvents returns the events of the member's account and, for members who manage
+// the organization, of the organization, newest first
+func (s *Service) ListAuditEvents(ctx context.Context, params ListAuditEventsParams) ([]AuditEvent, error) {
+	limit := int32(maxAuditEvents)
+	if params.Export {
+		limit = maxAuditExportEvents
+	}
+
+	args := db.ListAuditEventsParams{
+		UserID:      params.Member.UserID,
+		ActorUserID: lo.EmptyableToPtr(params.ActorID),
+		MaxEvents:   limit,
+	}
+	// Viewers only see their own account
+	if params.Member.Can(RoleAdmin) {
+		args.OrganizationID = lo.EmptyableToPtr(params.Member.OrganizationID)
+	}
+	if params.Category != "" {
+		args.ActionPrefix = pgtype.Text{String: params.Category + ".", Valid: true}
+	}
+	if !params.Since.IsZero() {
+		args.Since = pgtype.Timestamp{Time: params.Since, Valid: true}
+	}
+
+	rows, err := s.getDB().ListAuditEvents(ctx, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+
+	events := make([]AuditEvent, 0, len(rows))
+	for _, row := range rows {
+		event, err := toDomainAuditEvent(row)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func toDomainAuditEvent(row db.ListAuditEventsRow) (AuditEvent, error) {
+	event := AuditEvent{
+		ID:             row.ID,
+		OrganizationID: lo.FromPtr(row.OrganizationID),
+		ActorType:      row.ActorType,
+		ActorUserID:    lo.FromPtr(row.ActorUserID),
+		ActorEmail:     row.ActorEmail,
+		Action:         row.Action,
+		TargetType:     row.TargetType,
+		TargetID:       row.TargetID,
+		IPAddress:      row.IpAddress,
+		UserAgent:      row.UserAgent,
+		RequestID:      row.RequestID,
+		CreatedAt:      row.CreatedAt.Time,
+	}
+	if err := json.Unmarshal(row.Metadata, &event.Metadata); err != nil {
+		return AuditEvent{}, fmt.Errorf("failed to decode audit metadata: %w", err)
+	}
+	return event, nil
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/aliuygur/n8n-saas-api/internal/testdb"
+)
+
+func TestListAuditEvents(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := withTestClient(testdb.Context())
+	owner := createTestUser(t, s, "owner")
+	viewer := createTestUser(t, s, "viewer")
+	queries := s.getDB()
+
+	org, err := s.CreateOrganization(ctx, owner.UserID, "Acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := queries.CreateOrganizationMember(ctx, db.CreateOrganizationMemberParams{OrganizationID: org.ID, UserID: viewer.UserID, Role: RoleViewer}); err != nil {
+		t.Fatal(err)
+	}
+	if err := queries.SetUserCurrentOrganization(ctx, db.SetUserCurrentOrganizationParams{ID: viewer.UserID, CurrentOrganizationID: &org.ID}); err != nil {
+		t.Fatal(err)
+	}
+	ownerMember := Membership{OrganizationID: org.ID, UserID: owner.UserID, Role: RoleOwner}
+	viewerMember := Membership{OrganizationID: org.ID, UserID: viewer.UserID, Role: RoleViewer}
+
+	if _, _, err := s.CreateSession(ctx, CreateSessionParams{UserID: viewer.UserID, UserAgent: "Firefox", IPAddress: "203.0.113.7"}); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	s.recordAudit(ctx, auditEntry{
+		OrganizationID: org.ID,
+		UserID:         owner.UserID,
+		Action:         AuditActionInstanceCreate,
+		TargetType:     "instance",
+		TargetID:       "instance-1",
+	})
+	// Events of the owner's personal organization stay out of Acme's log
+	s.recordAudit(ctx, auditEntry{
+		OrganizationID: owner.OrganizationID,
+		UserID:         owner.UserID,
+		Action:         AuditActionInstanceDelete,
+		TargetType:     "instance",
+		TargetID:       "instance-2",
+	})
+
+	tests := []struct {
+		name   string
+		params ListAuditEventsParams
+		want   []string
+	}{
+		{name: "organization", params: ListAuditEventsParams{Member: ownerMember}, want: []string{AuditActionInstanceCreate, AuditActionUserLogin}},
+		{name: "viewers see their own account", params: ListAuditEventsParams{Member: viewerMember}, want: []string{AuditActionUserLogin}},
+		{name: "category", params: ListAuditEventsParams{Member: ownerMember, Category: "instance"}, want: []string{AuditActionInstanceCreate}},
+		{name: "actor", params: ListAuditEventsParams{Member: ownerMember, ActorID: viewer.UserID}, want: []string{AuditActionUserLogin}},
+		{name: "since", params: ListAuditEventsParams{Member: ownerMember, Since: time.Now().Add(time.Hour)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := s.ListAuditEvents(ctx, tt.params)
+			if err != nil {
+				t.Fatalf("Failed to list audit events: %v", err)
+			}
+			var got []string
+			for _, event := range events {
+				got = append(got, event.Action)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	// The login is recorded in the organization the session starts in
+	events, err := s.ListAuditEvents(ctx, ListAuditEventsParams{Member: ownerMember, Category: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].OrganizationID != org.ID || events[0].ActorEmail != "viewer@example.com" || events[0].IPAddress != "203.0.113.7" {
+		t.Errorf("Expected the login in Acme, got %+v", events)
+	}
+}
+
+func TestAuditEventsAppendOnly(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := withTestClient(testdb.Context())
+	member := createTestUser(t, s, "audited")
+
+	s.recordAudit(ctx, auditEntry{
+		OrganizationID: member.OrganizationID,
+		UserID:         member.UserID,
+		Action:         AuditActionInstanceCreate,
+		TargetType:     "instance",
+		TargetID:       "instance-1",
+	})
+
+	if _, err := s.pool.Exec(ctx, "UPDATE audit_events SET action = 'instance.deleted' WHERE actor_user_id = $1", member.UserID); err == nil {
+		t.Error("Expected changing an event to be rejected")
+	}
+	if _, err := s.pool.Exec(ctx, "UPDATE audit_events SET ip_address = '', user_agent = '', target_id = 'other' WHERE actor_user_id = $1", member.UserID); err == nil {
+		t.Error("Expected a scrub that changes other columns to be rejected")
+	}
+	if _, err := s.pool.Exec(ctx, "DELETE FROM audit_events WHERE actor_user_id = $1", member.UserID); err == nil {
+		t.Error("Expected deleting an event to be rejected")
+	}
+
+	if err := s.getDB().ScrubUserAuditEvents(ctx, &member.UserID); err != nil {
+		t.Fatalf("Expected scrubbing client details to be allowed, got %v", err)
+	}
+	var ip, userAgent, action string
+	err := s.pool.QueryRow(ctx, "SELECT ip_address, user_agent, action FROM audit_events WHERE actor_user_id = $1", member.UserID).Scan(&ip, &userAgent, &action)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip != "" || userAgent != "" || action != AuditActionInstanceCreate {
+		t.Errorf("Expected only the client details to be cleared, got %q %q %q", ip, userAgent, action)
+	}
+}
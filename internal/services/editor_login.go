@@ -0,0 +1,378 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/aliuygur/n8n-saas-api/internal/emails"
+	"github.com/aliuygur/n8n-saas-api/internal/jobs"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/samber/lo"
+)
+
+const (
+	// editorLoginTTL defines how long the token handed from the dashboard to the proxy is valid
+	editorLoginTTL = time.Minute
+	// n8nAuthCookieName is the session cookie of the n8n editor
+	n8nAuthCookieName = "n8n-auth"
+	// n8nRequestTimeout bounds calls to the REST API of an instance
+	n8nRequestTimeout = 10 * time.Second
+	// ownerSetupPollInterval and ownerSetupAttemptTimeout control waiting for a new instance to start,
+	// ownerSetupAttempts times with backoff in between
+	ownerSetupPollInterval   = 10 * time.Second
+	ownerSetupAttemptTimeout = 5 * time.Minute
+	ownerSetupAttempts       = 3
+	// ownerSetupTimeout covers every attempt and the backoff between them,
+	// instances without an owner after it are listed as stuck in the admin console
+	ownerSetupTimeout = 20 * time.Minute
+	// ownerSetupPath is the n8n endpoint that creates the owner account
+	ownerSetupPath = "/rest/owner/setup"
+)
+
+var (
+	// errInstanceNotReady means n8n didn't pass its readiness check in time
+	errInstanceNotReady = errors.New("instance did not become ready")
+	// errOwnerTaken means n8n already had an owner that ranx didn't create, so ranx can't log into it
+	errOwnerTaken = errors.New("n8n owner was already set up outside ranx")
+)
+
+// EditorLogin is an n8n editor session of the instance owner, created for a dashboard user
+type EditorLogin struct {
+	UserID    string
+	SessionID string // The dashboard session the login was started from, empty for older tokens
+	ReturnTo  string
+	Cookie    *http.Cookie
+}
+
+// CreateEditorLoginToken issues a single use token that logs the member into the n8n editor.
+// The token is exchanged at the instance hostname, where the proxy can set the n8n session cookie.
+// sessionID is the dashboard session, the gate cookie set along with the n8n session is bound to it.
+func (s *Service) CreateEditorLoginToken(ctx context.Context, member Membership, sessionID, instanceID, returnTo string) (string, *Instance, error) {
+	// Everyone opening the editor shares the owner account, so it's limited to members who manage instances
+	if err := member.Require(RoleAdmin); err != nil {
+		return "", nil, err
+	}
+
+	instance, err := s.GetMemberInstance(ctx, member, instanceID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, apperrs.Server("failed to generate editor login token", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	err = s.getDB().CreateEditorLoginToken(ctx, db.CreateEditorLoginTokenParams{
+		TokenHash:  hashEditorLoginToken(token),
+		InstanceID: instance.ID,
+		UserID:     member.UserID,
+		SessionID:  lo.EmptyableToPtr(sessionID),
+		ReturnTo:   returnTo,
+		ExpiresAt:  pgtype.Timestamp{Time: time.Now().Add(editorLoginTTL), Valid: true},
+	})
+	if err != nil {
+		return "", nil, apperrs.Server("failed to store editor login token", err)
+	}
+
+	return token, instance, nil
+}
+
+// ConsumeEditorLoginToken exchanges a token from CreateEditorLoginToken for an n8n session.
+// Tokens can only be used once. The owner account is set up first if provisioning didn't get to it.
+// When n8n can't be logged into, the error comes with an EditorLogin that has no cookie.
+func (s *Service) ConsumeEditorLoginToken(ctx context.Context, instanceID, token string) (*EditorLogin, error) {
+	queries := s.getDB()
+
+	loginToken, err := queries.ConsumeEditorLoginToken(ctx, db.ConsumeEditorLoginTokenParams{
+		TokenHash:  hashEditorLoginToken(token),
+		InstanceID: instanceID,
+	})
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			return nil, apperrs.Client(apperrs.CodeUnauthorized, "editor login link is invalid or has expired")
+		}
+		return nil, apperrs.Server("failed to consume editor login token", err)
+	}
+
+	login := &EditorLogin{UserID: loginToken.UserID, SessionID: lo.FromPtr(loginToken.SessionID), ReturnTo: loginToken.ReturnTo}
+
+	instance, err := s.GetInstanceByID(ctx, instanceID)
+	if err != nil {
+		return login, err
+	}
+
+	if instance.OwnerSetupAt == nil {
+		if err := s.setupInstanceOwner(ctx, instance); err != nil {
+			return login, err
+		}
+	}
+
+	cookie, err := s.loginInstanceOwner(ctx, instance)
+	if err != nil {
+		return login, err
+	}
+	login.Cookie = cookie
+	return login, nil
+}
+
+// setupOwnerArgs are the arguments of JobSetupInstanceOwner
+type setupOwnerArgs struct {
+	InstanceID string `json:"instance_id"`
+}
+
+// enqueueOwnerSetup queues the owner setup of a new instance with the queries of the
+// transaction that creates it, so the job only runs for instances that exist
+func (s *Service) enqueueOwnerSetup(ctx context.Context, queries *db.Queries, instanceID string) error {
+	_, err := s.jobs.EnqueueTx(ctx, queries, JobSetupInstanceOwner, setupOwnerArgs{InstanceID: instanceID}, jobs.EnqueueOptions{
+		UniqueKey:   instanceID,
+		MaxAttempts: ownerSetupAttempts,
+	})
+	return err
+}
+
+// setupInstanceOwnerJob waits for a new instance to start, creates its owner account and
+// announces that the instance is ready. Editor login retries the setup if every attempt gives up.
+func (s *Service) setupInstanceOwnerJob(ctx context.Context, job *jobs.Job) error {
+	l := appctx.GetLogger(ctx)
+
+	var args setupOwnerArgs
+	if err := job.Bind(&args); err != nil {
+		return err
+	}
+
+	instance, err := s.GetInstanceByID(ctx, args.InstanceID)
+	if err != nil {
+		// Deleted before it came up
+		if apperrs.CodeIs(err, apperrs.CodeNotFound) {
+			return nil
+		}
+		return err
+	}
+	if instance.OwnerSetupAt != nil {
+		return nil
+	}
+
+	// The failure is only recorded once no attempts are left, and not when a shutdown interrupted the job
+	giveUp := func(err error) error {
+		if job.Attempt >= job.MaxAttempts && ctx.Err() == nil {
+			s.recordProvisionFailure(ctx, instance, err)
+		}
+		return err
+	}
+
+	if err := waitForInstance(ctx, instance); err != nil {
+		l.Warn("instance did not become ready for n8n owner setup", "instance_id", instance.ID, "error", err)
+		return giveUp(err)
+	}
+
+	if err := s.setupInstanceOwner(ctx, instance); err != nil {
+		l.Error("failed to set up n8n owner", "instance_id", instance.ID, "error", err)
+		// Retrying can't take the owner account back
+		if errors.Is(err, errOwnerTaken) {
+			s.recordProvisionFailure(ctx, instance, err)
+			return nil
+		}
+		return giveUp(err)
+	}
+
+	email := emails.InstanceReady{
+		Subdomain:    instance.Subdomain,
+		InstanceURL:  instance.GetInstanceURL(),
+		DashboardURL: s.config.Server.BaseURL("/dashboard"),
+	}
+	if err := s.notify(ctx, instance.UserID, NotificationInstanceReady, email); err != nil {
+		l.Error("failed to notify that the instance is ready", "instance_id", instance.ID, "error", err)
+	}
+	s.publishInstanceEvent(ctx, WebhookEventInstanceReady, instance, "")
+	return nil
+}
+
+// waitForInstance polls the readiness check of an instance for up to ownerSetupAttemptTimeout
+func waitForInstance(ctx context.Context, instance *Instance) error {
+	ctx, cancel := context.WithTimeout(ctx, ownerSetupAttemptTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(ownerSetupPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if instanceReady(ctx, instance) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errInstanceNotReady
+		case <-ticker.C:
+		}
+	}
+}
+
+// recordProvisionFailure records an instance that didn't come up. The audit log keeps the error
+// for the admin console, webhooks only get its provisionFailureReason.
+func (s *Service) recordProvisionFailure(ctx context.Context, instance *Instance, err error) {
+	s.recordAudit(ctx, auditEntry{
+		OrganizationID: instance.OrganizationID,
+		Action:         AuditActionInstanceProvisionFail,
+		TargetType:     "instance",
+		TargetID:       instance.ID,
+		Metadata:       map[string]string{"subdomain": instance.Subdomain, "error": truncate(err.Error(), 500)},
+	})
+	s.publishInstanceEvent(ctx, WebhookEventInstanceFailed, instance, provisionFailureReason(err))
+}
+
+// provisionFailureReason is the reason customers are given for a failed instance. Errors of
+// the cluster and n8n can name internal hosts, so only the known causes are passed on.
+func provisionFailureReason(err error) string {
+	switch {
+	case errors.Is(err, errInstanceNotReady):
+		return errInstanceNotReady.Error()
+	case errors.Is(err, errOwnerTaken):
+		return errOwnerTaken.Error()
+	default:
+		return "n8n owner setup failed"
+	}
+}
+
+// instanceReady reports whether n8n answers its readiness check inside the cluster
+func instanceReady(ctx context.Context, instance *Instance) bool {
+	return probeInstance(ctx, instance.Namespace) == nil
+}
+
+// setupInstanceOwner creates the n8n owner account with credentials only ranx can derive
+func (s *Service) setupInstanceOwner(ctx context.Context, instance *Instance) error {
+	if err := s.requestOwnerSetup(ctx, instance); err != nil {
+		return err
+	}
+
+	if err := s.getDB().MarkInstanceOwnerSetup(ctx, instance.ID); err != nil {
+		return apperrs.Server("failed to mark n8n owner as set up", err)
+	}
+	now := time.Now()
+	instance.OwnerSetupAt = &now
+	return nil
+}
+
+// requestOwnerSetup calls the owner setup endpoint of n8n. It returns errOwnerTaken when
+// n8n refuses because an owner exists, which the proxy only allows before ranx set it up.
+func (s *Service) requestOwnerSetup(ctx context.Context, instance *Instance) error {
+	l := appctx.GetLogger(ctx)
+
+	resp, err := postInstanceJSON(ctx, instance, ownerSetupPath, map[string]string{
+		"email":     instanceOwnerEmail(instance),
+		"firstName": "ranx",
+		"lastName":  "Owner",
+		"password":  s.instanceOwnerPassword(instance),
+	})
+	if err != nil {
+		return apperrs.Server("failed to set up n8n owner", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		l.Info("set up n8n owner", "instance_id", instance.ID)
+		return nil
+	case http.StatusBadRequest:
+		return errOwnerTaken
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return apperrs.Server(fmt.Sprintf("n8n owner setup returned %d: %s", resp.StatusCode, body), nil)
+	}
+}
+
+// IsOwnerSetupPath reports whether a tenant request path reaches the owner setup endpoint of n8n,
+// which routes case-insensitively and with or without a trailing slash
+func IsOwnerSetupPath(requestPath string) bool {
+	return strings.EqualFold(strings.TrimSuffix(path.Clean("/"+requestPath), "/"), ownerSetupPath)
+}
+
+// loginInstanceOwner logs in to n8n as the owner and returns the session cookie it issued
+func (s *Service) loginInstanceOwner(ctx context.Context, instance *Instance) (*http.Cookie, error) {
+	// No browser-id header is sent, so the session isn't bound to the browser that calls the proxy
+	resp, err := postInstanceJSON(ctx, instance, "/rest/login", map[string]string{
+		"emailOrLdapLoginId": instanceOwnerEmail(instance),
+		"password":           s.instanceOwnerPassword(instance),
+	})
+	if err != nil {
+		return nil, apperrs.Server("failed to log in to n8n", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apperrs.Server(fmt.Sprintf("n8n login returned %d", resp.StatusCode), nil)
+	}
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == n8nAuthCookieName {
+			return cookie, nil
+		}
+	}
+	return nil, apperrs.Server("n8n login did not return a session cookie", nil)
+}
+
+// postInstanceJSON calls the REST API of an instance through its cluster service, bypassing the access policy
+func postInstanceJSON(ctx context.Context, instance *Instance, path string, body any) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, instanceServiceURL(instance.Namespace, path), bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return n8nClient.Do(req)
+}
+
+// n8nClient calls instances inside the cluster, n8n's cookies are read from responses rather than stored
+var n8nClient = &http.Client{
+	Timeout: n8nRequestTimeout,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// instanceServiceURL returns the URL of an instance's n8n service inside the cluster
+func instanceServiceURL(namespace, path string) string {
+	return fmt.Sprintf("http://%s.%s.svc.cluster.local%s", n8nDeploymentName, namespace, path)
+}
+
+// instanceOwnerEmail is the login of the n8n owner account. It uses the instance ID so it
+// stays the same when the instance moves between organizations.
+func instanceOwnerEmail(instance *Instance) string {
+	return "owner+" + instance.ID + "@ranx.cloud"
+}
+
+// instanceOwnerPassword derives the n8n owner password so it never has to be stored.
+// n8n requires a digit and an uppercase letter, which the fixed prefix guarantees.
+func (s *Service) instanceOwnerPassword(instance *Instance) string {
+	mac := hmac.New(sha256.New, []byte(s.config.N8N.OwnerSecret))
+	mac.Write([]byte("n8n-owner:" + instance.ID))
+	return "Rx1-" + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func hashEditorLoginToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/aliuygur/n8n-saas-api/internal/jobs"
+	"github.com/aliuygur/n8n-saas-api/internal/testdb"
+)
+
+// fakeN8N routes the calls n8nClient makes to instances to handler for the rest of the test
+func fakeN8N(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport := n8nClient.Transport
+	n8nClient.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.URL.Scheme = target.Scheme
+		req.URL.Host = target.Host
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	t.Cleanup(func() { n8nClient.Transport = transport })
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// n8nOwnerHandler answers readiness, owner setup with setupStatus and owner logins
+func n8nOwnerHandler(setupStatus int, setups *int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz/readiness":
+			w.WriteHeader(http.StatusOK)
+		case ownerSetupPath:
+			*setups++
+			w.WriteHeader(setupStatus)
+		case "/rest/login":
+			http.SetCookie(w, &http.Cookie{Name: n8nAuthCookieName, Value: "n8n-session"})
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+}
+
+func countAuditEvents(t *testing.T, s *Service, action, targetID string) int {
+	t.Helper()
+	var n int
+	err := s.pool.QueryRow(context.Background(), "SELECT count(*) FROM audit_events WHERE action = $1 AND target_id = $2", action, targetID).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestIsOwnerSetupPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/rest/owner/setup", true},
+		{"/rest/owner/setup/", true},
+		{"/Rest/OWNER/setup", true},
+		{"//rest//owner/setup", true},
+		{"/rest/../rest/owner/setup", true},
+		{"rest/owner/setup", true},
+		{"/rest/owner", false},
+		{"/rest/owner/setup/extra", false},
+		{"/webhook/rest/owner/setup", false},
+		{"/", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsOwnerSetupPath(tt.path); got != tt.want {
+			t.Errorf("IsOwnerSetupPath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRequestOwnerSetup(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		wantErr   error
+		wantOther bool
+	}{
+		{name: "created", status: http.StatusOK},
+		{name: "owner exists", status: http.StatusBadRequest, wantErr: errOwnerTaken},
+		{name: "n8n error", status: http.StatusInternalServerError, wantOther: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body map[string]string
+			fakeN8N(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != ownerSetupPath {
+					t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+				}
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				w.WriteHeader(tt.status)
+			})
+
+			s := &Service{config: testConfig()}
+			instance := &Instance{ID: "instance-1", Namespace: "n8n-acme"}
+			err := s.requestOwnerSetup(testdb.Context(), instance)
+
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+				}
+			case tt.wantOther:
+				if err == nil || errors.Is(err, errOwnerTaken) {
+					t.Fatalf("Expected a server error, got %v", err)
+				}
+			case err != nil:
+				t.Fatalf("Expected success, got %v", err)
+			}
+
+			if body["email"] != instanceOwnerEmail(instance) || body["password"] != s.instanceOwnerPassword(instance) {
+				t.Errorf("Expected the derived owner credentials, got %v", body)
+			}
+		})
+	}
+}
+
+func TestConsumeEditorLoginToken(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := testdb.Context()
+	member := createTestUser(t, s, "editor")
+	instance := createTestInstance(t, s, member, "editor")
+
+	var setups int
+	fakeN8N(t, n8nOwnerHandler(http.StatusOK, &setups))
+
+	newToken := func() string {
+		token, _, err := s.CreateEditorLoginToken(ctx, member, "", instance.ID, "/workflow/1")
+		if err != nil {
+			t.Fatalf("Failed to create editor login token: %v", err)
+		}
+		return token
+	}
+
+	t.Run("single use", func(t *testing.T) {
+		token := newToken()
+
+		login, err := s.ConsumeEditorLoginToken(ctx, instance.ID, token)
+		if err != nil {
+			t.Fatalf("Expected the token to log in, got %v", err)
+		}
+		if login.Cookie == nil || login.Cookie.Value != "n8n-session" || login.ReturnTo != "/workflow/1" || login.UserID != member.UserID {
+			t.Errorf("Unexpected login %+v", login)
+		}
+		if setups != 1 {
+			t.Errorf("Expected the owner to be set up once, got %d", setups)
+		}
+
+		_, err = s.ConsumeEditorLoginToken(ctx, instance.ID, token)
+		if !apperrs.CodeIs(err, apperrs.CodeUnauthorized) {
+			t.Errorf("Expected a used token to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("other instance", func(t *testing.T) {
+		other := createTestInstance(t, s, member, "other")
+		_, err := s.ConsumeEditorLoginToken(ctx, other.ID, newToken())
+		if !apperrs.CodeIs(err, apperrs.CodeUnauthorized) {
+			t.Errorf("Expected a token of another instance to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		token := newToken()
+		if _, err := s.pool.Exec(ctx, "UPDATE editor_login_tokens SET expires_at = NOW() - INTERVAL '1 second' WHERE token_hash = $1", hashEditorLoginToken(token)); err != nil {
+			t.Fatal(err)
+		}
+		_, err := s.ConsumeEditorLoginToken(ctx, instance.ID, token)
+		if !apperrs.CodeIs(err, apperrs.CodeUnauthorized) {
+			t.Errorf("Expected an expired token to be rejected, got %v", err)
+		}
+	})
+
+	if setups != 1 {
+		t.Errorf("Expected later logins to reuse the owner, got %d setups", setups)
+	}
+}
+
+func TestProvisionFailureReason(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "not ready", err: errInstanceNotReady, want: "instance did not become ready"},
+		{name: "owner taken", err: fmt.Errorf("failed to set up owner: %w", errOwnerTaken), want: "n8n owner was already set up outside ranx"},
+		{name: "internal error", err: errors.New("dial tcp n8n.n8n-acme.svc.cluster.local:5678: connection refused"), want: "n8n owner setup failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := provisionFailureReason(tt.err); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSetupInstanceOwnerJob(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		attempt     int32
+		wantErr     bool
+		wantSetUp   bool
+		wantFailure string // The reason webhooks are given, empty when no failure is recorded
+	}{
+		{name: "owner created", status: http.StatusOK, attempt: 1, wantSetUp: true},
+		{name: "owner taken is not retried", status: http.StatusBadRequest, attempt: 1, wantFailure: errOwnerTaken.Error()},
+		{name: "n8n error is retried", status: http.StatusInternalServerError, attempt: 1, wantErr: true},
+		{name: "last attempt records the failure", status: http.StatusInternalServerError, attempt: ownerSetupAttempts, wantErr: true, wantFailure: "n8n owner setup failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestService(t)
+			ctx := testdb.Context()
+			member := createTestUser(t, s, "owner")
+			instance := createTestInstance(t, s, member, "owner")
+			endpoint := createTestWebhookEndpoint(t, s, member.OrganizationID, WebhookEventInstanceFailed)
+
+			var setups int
+			fakeN8N(t, n8nOwnerHandler(tt.status, &setups))
+
+			args, _ := json.Marshal(setupOwnerArgs{InstanceID: instance.ID})
+			err := s.setupInstanceOwnerJob(ctx, &jobs.Job{
+				Kind:        JobSetupInstanceOwner,
+				Args:        args,
+				Attempt:     tt.attempt,
+				MaxAttempts: ownerSetupAttempts,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+
+			got, err := s.GetInstanceByID(ctx, instance.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got.OwnerSetupAt != nil) != tt.wantSetUp {
+				t.Errorf("Expected owner set up %v, got %v", tt.wantSetUp, got.OwnerSetupAt)
+			}
+			if failed := countAuditEvents(t, s, AuditActionInstanceProvisionFail, instance.ID) == 1; failed != (tt.wantFailure != "") {
+				t.Errorf("Expected provisioning failure recorded %v, got %v", tt.wantFailure != "", failed)
+			}
+			deliveries, err := s.getDB().ListWebhookDeliveries(ctx, db.ListWebhookDeliveriesParams{EndpointID: endpoint.ID, MaxRows: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var reason string
+			for _, d := range deliveries {
+				var event struct {
+					Data webhookInstanceData `json:"data"`
+				}
+				if err := json.Unmarshal([]byte(d.Payload), &event); err != nil {
+					t.Fatal(err)
+				}
+				reason = event.Data.Error
+			}
+			if reason != tt.wantFailure {
+				t.Errorf("Expected instance.failed with %q, got %q", tt.wantFailure, reason)
+			}
+
+			// A job for an instance that has its owner does nothing
+			if tt.wantSetUp {
+				err := s.setupInstanceOwnerJob(ctx, &jobs.Job{Kind: JobSetupInstanceOwner, Args: args, Attempt: 1, MaxAttempts: ownerSetupAttempts})
+				if err != nil || setups != 1 {
+					t.Errorf("Expected the second run to skip the setup, got %v after %d setups", err, setups)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// idempotencyKeyTTL is how long a response is replayed for retries
+	idempotencyKeyTTL = 24 * time.Hour
+	// idempotencyLockTimeout frees keys whose first request never finished, e.g. after a crash
+	idempotencyLockTimeout = 10 * time.Minute
+	// maxIdempotencyKeyLength limits the Idempotency-Key header
+	maxIdempotencyKeyLength = 255
+)
+
+// IdempotentResponse is the stored response of a request sent with an idempotency key
+type IdempotentResponse struct {
+	Status  int
+	Headers map[string]string
+	Body    []byte
+}
+
+// IdempotencyKey is a claimed key. Response is set when the key was used before
+// and its response must be replayed instead of handling the request again.
+type IdempotencyKey struct {
+	ID       string
+	Response *IdempotentResponse
+}
+
+// BeginIdempotentRequest claims key for a request of a user. requestHash identifies the
+// request, reusing a key for a different request is rejected.
+func (s *Service) BeginIdempotentRequest(ctx context.Context, userID, key, requestHash string) (*IdempotencyKey, error) {
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return nil, apperrs.Client(apperrs.CodeInvalidInput, fmt.Sprintf("Idempotency-Key must be between 1 and %d characters", maxIdempotencyKeyLength))
+	}
+
+	queries := s.getDB()
+
+	// Keys are only looked up by user, so stale ones are pruned whenever the user sends a new one
+	if err := queries.DeleteStaleUserIdempotencyKeys(ctx, db.DeleteStaleUserIdempotencyKeysParams{
+		UserID:        userID,
+		StartedBefore: pgtype.Timestamp{Time: time.Now().Add(-idempotencyLockTimeout), Valid: true},
+	}); err != nil {
+		return nil, fmt.Errorf("failed to delete stale idempotency keys: %w", err)
+	}
+
+	created, err := queries.CreateIdempotencyKey(ctx, db.CreateIdempotencyKeyParams{
+		UserID:         userID,
+		IdempotencyKey: key,
+		RequestHash:    requestHash,
+		ExpiresAt:      pgtype.Timestamp{Time: time.Now().Add(idempotencyKeyTTL), Valid: true},
+	})
+	if err == nil {
+		return &IdempotencyKey{ID: created.ID}, nil
+	}
+	if !db.IsNotFoundError(err) {
+		return nil, fmt.Errorf("failed to create idempotency key: %w", err)
+	}
+
+	existing, err := queries.GetIdempotencyKey(ctx, db.GetIdempotencyKeyParams{
+		UserID:         userID,
+		IdempotencyKey: key,
+	})
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			// The other request released the key in between, the client can retry right away
+			return nil, apperrs.Client(apperrs.CodeConflict, "a request with this Idempotency-Key just failed, retry it")
+		}
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	if existing.RequestHash != requestHash {
+		return nil, apperrs.Client(apperrs.CodeInvalidInput, "Idempotency-Key was already used for a different request")
+	}
+	if !existing.ResponseStatus.Valid {
+		return nil, apperrs.Client(apperrs.CodeConflict, "a request with this Idempotency-Key is still in progress")
+	}
+
+	response := &IdempotentResponse{
+		Status: int(existing.ResponseStatus.Int32),
+		Body:   existing.ResponseBody,
+	}
+	if len(existing.ResponseHeaders) > 0 {
+		if err := json.Unmarshal(existing.ResponseHeaders, &response.Headers); err != nil {
+			return nil, apperrs.Server("failed to decode stored response headers", err)
+		}
+	}
+	return &IdempotencyKey{ID: existing.ID, Response: response}, nil
+}
+
+// CompleteIdempotentRequest stores the response to replay for retries of the request of a key
+func (s *Service) CompleteIdempotentRequest(ctx context.Context, keyID string, response IdempotentResponse) error {
+	headers, err := json.Marshal(response.Headers)
+	if err != nil {
+		return apperrs.Server("failed to encode response headers", err)
+	}
+
+	queries := s.getDB()
+
+	if err := queries.CompleteIdempotencyKey(ctx, db.CompleteIdempotencyKeyParams{
+		ID:              keyID,
+		ResponseStatus:  pgtype.Int4{Int32: int32(response.Status), Valid: true},
+		ResponseHeaders: headers,
+		ResponseBody:    response.Body,
+	}); err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotentRequest frees a key whose request failed, so a retry handles it again
+func (s *Service) ReleaseIdempotentRequest(ctx context.Context, keyID string) error {
+	queries := s.getDB()
+
+	if err := queries.DeleteIdempotencyKey(ctx, keyID); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
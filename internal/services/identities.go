@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+)
+
+// Login providers
+const (
+	ProviderGoogle    = "google"
+	ProviderGitHub    = "github"
+	ProviderMicrosoft = "microsoft"
+	ProviderEmail     = "email"
+)
+
+// LoginIdentity is a user as reported by a login provider
+type LoginIdentity struct {
+	Provider      string
+	Subject       string // Stable user ID at the provider
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// LoginWithIdentity returns the user linked to a provider identity.
+// Unknown identities are linked to the user with the same verified email, creating the user if needed.
+func (s *Service) LoginWithIdentity(ctx context.Context, identity LoginIdentity) (*User, error) {
+	queries := s.getDB()
+	email := normalizeEmail(identity.Email)
+
+	existing, err := queries.GetUserIdentity(ctx, db.GetUserIdentityParams{
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+	})
+	if err == nil {
+		if err := queries.TouchUserIdentity(ctx, db.TouchUserIdentityParams{
+			ID:    existing.ID,
+			Email: email,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to update identity: %w", err)
+		}
+		return s.GetUser(ctx, existing.UserID)
+	}
+	if !db.IsNotFoundError(err) {
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+
+	// Linking by email is only safe when the provider vouches for the address
+	if email == "" || !identity.EmailVerified {
+		return nil, apperrs.Client(apperrs.CodeForbidden, "the email address of this account is not verified")
+	}
+
+	name := identity.Name
+	if name == "" {
+		name, _, _ = strings.Cut(email, "@")
+	}
+
+	user, err := s.GetOrCreateUser(ctx, CreateUserParams{
+		Email: email,
+		Name:  name,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	linked, err := queries.CreateUserIdentity(ctx, db.CreateUserIdentityParams{
+		UserID:   user.ID,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    email,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	// A concurrent login may have linked the identity first
+	if linked.UserID != user.ID {
+		return s.GetUser(ctx, linked.UserID)
+	}
+	return user, nil
+}
+
+// normalizeEmail lowercases an email so the same address always maps to one user
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
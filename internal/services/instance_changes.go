@@ -0,0 +1,39 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+)
+
+// InstanceChangeAll is the change payload that invalidates every subdomain
+const InstanceChangeAll = "*"
+
+// ListenInstanceChanges blocks on the instance_changes channel and calls fn with the
+// subdomain of every changed instance. fn receives InstanceChangeAll once listening
+// has started, since changes made before that may have been missed.
+// It returns when ctx is cancelled or the connection fails.
+func (s *Service) ListenInstanceChanges(ctx context.Context, fn func(subdomain string)) error {
+	pooled, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	// LISTEN state lives on the connection, so take it out of the pool for good
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if err := db.New(conn).ListenInstanceChanges(ctx); err != nil {
+		return fmt.Errorf("failed to listen for instance changes: %w", err)
+	}
+
+	fn(InstanceChangeAll)
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to wait for instance change: %w", err)
+		}
+		fn(notification.Payload)
+	}
+}
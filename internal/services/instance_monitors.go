@@ -0,0 +1,474 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/aliuygur/n8n-saas-api/internal/emails"
+	"github.com/aliuygur/n8n-saas-api/internal/jobs"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Uptime monitor statuses of an instance
+const (
+	MonitorStatusUnknown = "unknown"
+	MonitorStatusUp      = "up"
+	MonitorStatusDown    = "down"
+	// MonitorStatusPaused instances aren't checked, because they are stopped or still provisioning
+	MonitorStatusPaused = "paused"
+)
+
+const (
+	// monitorFailureThreshold is how many checks in a row fail before an instance is down,
+	// so a restart or an update rolling out doesn't alert
+	monitorFailureThreshold = 3
+	// monitorConcurrency bounds how many instances are probed at once
+	monitorConcurrency = 16
+	// healthCheckRetention is how long probe results are kept, the longest uptime window
+	healthCheckRetention = 30 * 24 * time.Hour
+	// alertMaxAttempts is how often an alert webhook is called before giving up
+	alertMaxAttempts = 5
+	// maxWebhookURLLength bounds the stored webhook URLs
+	maxWebhookURLLength = 2048
+)
+
+// uptimeWindows are the periods the instance page shows the uptime of
+var uptimeWindows = []struct {
+	label  string
+	period time.Duration
+}{
+	{"24 hours", 24 * time.Hour},
+	{"7 days", 7 * 24 * time.Hour},
+	{"30 days", healthCheckRetention},
+}
+
+// InstanceMonitor is the health of an instance as seen by the uptime monitor
+type InstanceMonitor struct {
+	InstanceID      string
+	Status          string
+	LastError       string
+	LastCheckedAt   *time.Time
+	DownSince       *time.Time // First failed check of the current outage
+	AlertWebhookURL string     // Called on down and recovered transitions, empty for email only
+	Uptime          []Uptime
+}
+
+// Uptime is the share of successful checks in a period
+type Uptime struct {
+	Label   string
+	Percent float64
+	Checks  int64 // Zero when the instance wasn't checked in the period
+}
+
+// instanceAlertArgs are the arguments of JobSendInstanceAlert
+type instanceAlertArgs struct {
+	InstanceID string        `json:"instance_id"`
+	Event      string        `json:"event"` // NotificationInstanceDown or NotificationInstanceRecovered
+	Since      time.Time     `json:"since"` // First failed check of the outage
+	Error      string        `json:"error,omitempty"`
+	Downtime   time.Duration `json:"downtime,omitempty"`
+}
+
+// GetInstanceMonitor returns the health and uptime of an instance
+func (s *Service) GetInstanceMonitor(ctx context.Context, instance *Instance) (*InstanceMonitor, error) {
+	queries := s.getDB()
+	instanceID := instance.ID
+
+	monitor := &InstanceMonitor{InstanceID: instanceID, Status: MonitorStatusUnknown}
+	row, err := queries.GetInstanceMonitor(ctx, instanceID)
+	if err != nil && !db.IsNotFoundError(err) {
+		return nil, apperrs.Server("failed to get instance monitor", err)
+	}
+	if err == nil {
+		monitor.Status = row.Status
+		monitor.LastError = row.LastError
+		monitor.LastCheckedAt = timestampPtr(row.LastCheckedAt)
+		if row.Status == MonitorStatusDown {
+			monitor.DownSince = timestampPtr(row.FailingSince)
+		}
+		monitor.AlertWebhookURL = row.AlertWebhookUrl
+	}
+	if !instance.IsMonitored() {
+		monitor.Status = MonitorStatusPaused
+	}
+
+	now := time.Now()
+	for _, window := range uptimeWindows {
+		counts, err := queries.GetInstanceUptime(ctx, db.GetInstanceUptimeParams{
+			InstanceID: instanceID,
+			Since:      pgtype.Timestamp{Time: now.Add(-window.period), Valid: true},
+		})
+		if err != nil {
+			return nil, apperrs.Server("failed to get instance uptime", err)
+		}
+		uptime := Uptime{Label: window.label, Checks: counts.Total}
+		if counts.Total > 0 {
+			uptime.Percent = float64(counts.Up) * 100 / float64(counts.Total)
+		}
+		monitor.Uptime = append(monitor.Uptime, uptime)
+	}
+
+	return monitor, nil
+}
+
+type UpdateAlertWebhookParams struct {
+	Member     Membership
+	InstanceID string
+	WebhookURL string // Empty turns webhook alerts off
+}
+
+// UpdateInstanceAlertWebhook sets the URL called when an instance of the member's organization goes down or recovers
+func (s *Service) UpdateInstanceAlertWebhook(ctx context.Context, params UpdateAlertWebhookParams) (*InstanceMonitor, error) {
+	if err := params.Member.Require(RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	webhookURL, err := normalizeWebhookURL(params.WebhookURL)
+	if err != nil {
+		return nil, err
+	}
+
+	instance, err := s.GetMemberInstance(ctx, params.Member, params.InstanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.getDB().UpsertInstanceMonitorWebhook(ctx, db.UpsertInstanceMonitorWebhookParams{
+		InstanceID:      instance.ID,
+		AlertWebhookUrl: webhookURL,
+	}); err != nil {
+		return nil, apperrs.Server("failed to update alert webhook", err)
+	}
+
+	return s.GetInstanceMonitor(ctx, instance)
+}
+
+// IsMonitored reports whether the uptime monitor checks the instance, it must be running and have started once
+func (i *Instance) IsMonitored() bool {
+	return (i.Status == InstanceStatusDeployed || i.Status == InstanceStatusActive) && i.OwnerSetupAt != nil
+}
+
+// normalizeWebhookURL validates a webhook URL entered by a user, empty is left as is
+func normalizeWebhookURL(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	if len(value) > maxWebhookURLLength {
+		return "", apperrs.Client(apperrs.CodeInvalidInput, "webhook URL is too long")
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" || u.User != nil {
+		return "", apperrs.Client(apperrs.CodeInvalidInput, "webhook URL must be an https:// URL")
+	}
+	return u.String(), nil
+}
+
+// CheckInstances probes every running instance and alerts the owners of instances that went down or recovered
+func (s *Service) CheckInstances(ctx context.Context) error {
+	l := appctx.GetLogger(ctx)
+
+	instances, err := s.getDB().ListMonitoredInstances(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list instances: %w", err)
+	}
+
+	sem := make(chan struct{}, monitorConcurrency)
+	var wg sync.WaitGroup
+	for _, dbInst := range instances {
+		instance := toDomainInstance(dbInst)
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			start := time.Now()
+			probeErr := probeInstance(ctx, instance.Namespace)
+			if ctx.Err() != nil {
+				// Shutting down, the probe didn't finish
+				return
+			}
+			if err := s.recordHealthCheck(ctx, &instance, time.Since(start), probeErr); err != nil {
+				l.Error("failed to record health check", "instance_id", instance.ID, "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// recordHealthCheck stores a probe result and acts on the instance going down or recovering
+func (s *Service) recordHealthCheck(ctx context.Context, instance *Instance, latency time.Duration, probeErr error) error {
+	now := time.Now()
+
+	queries, tx := s.getDBWithTx(ctx)
+	defer tx.Rollback(ctx)
+
+	lastError := ""
+	if probeErr != nil {
+		lastError = truncate(probeErr.Error(), 500)
+	}
+	if err := queries.CreateInstanceHealthCheck(ctx, db.CreateInstanceHealthCheckParams{
+		InstanceID: instance.ID,
+		Up:         probeErr == nil,
+		LatencyMs:  int32(latency.Milliseconds()),
+		Error:      lastError,
+	}); err != nil {
+		return fmt.Errorf("failed to create health check: %w", err)
+	}
+
+	previous, err := queries.GetInstanceMonitorForUpdate(ctx, instance.ID)
+	if err != nil {
+		if !db.IsNotFoundError(err) {
+			return fmt.Errorf("failed to get instance monitor: %w", err)
+		}
+		previous = db.InstanceMonitor{Status: MonitorStatusUnknown}
+	}
+
+	status, failures, failingSince := previous.Status, previous.ConsecutiveFailures, previous.FailingSince
+	if probeErr == nil {
+		status, failures, failingSince = MonitorStatusUp, 0, pgtype.Timestamp{}
+	} else {
+		failures++
+		if !failingSince.Valid {
+			failingSince = pgtype.Timestamp{Time: now, Valid: true}
+		}
+		if failures >= monitorFailureThreshold {
+			status = MonitorStatusDown
+		}
+	}
+
+	monitor, err := queries.UpsertInstanceMonitorCheck(ctx, db.UpsertInstanceMonitorCheckParams{
+		InstanceID:          instance.ID,
+		Status:              status,
+		ConsecutiveFailures: failures,
+		FailingSince:        failingSince,
+		LastError:           lastError,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update instance monitor: %w", err)
+	}
+
+	// Coming up for the first time or staying in a status isn't worth an alert
+	var alert *instanceAlertArgs
+	switch {
+	case status == MonitorStatusDown && previous.Status != MonitorStatusDown:
+		alert = &instanceAlertArgs{InstanceID: instance.ID, Event: NotificationInstanceDown, Since: failingSince.Time, Error: lastError}
+	case status == MonitorStatusUp && previous.Status == MonitorStatusDown:
+		alert = &instanceAlertArgs{InstanceID: instance.ID, Event: NotificationInstanceRecovered, Since: previous.FailingSince.Time}
+		alert.Downtime = now.Sub(alert.Since)
+	}
+	if alert == nil {
+		return tx.Commit(ctx)
+	}
+
+	if monitor.AlertWebhookUrl != "" {
+		if _, err := s.jobs.EnqueueTx(ctx, queries, JobSendInstanceAlert, alert, jobs.EnqueueOptions{
+			MaxAttempts: alertMaxAttempts,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.alertInstanceOwners(ctx, instance, alert)
+	return nil
+}
+
+// alertInstanceOwners records a down or recovered transition and emails the owners of the instance
+func (s *Service) alertInstanceOwners(ctx context.Context, instance *Instance, alert *instanceAlertArgs) {
+	l := appctx.GetLogger(ctx)
+	detailURL := s.config.Server.BaseURL("/instances/" + instance.ID)
+
+	var email emails.Email
+	entry := auditEntry{
+		OrganizationID: instance.OrganizationID,
+		TargetType:     "instance",
+		TargetID:       instance.ID,
+		Metadata:       map[string]string{"subdomain": instance.Subdomain},
+	}
+	if alert.Event == NotificationInstanceDown {
+		l.Warn("instance is down", "instance_id", instance.ID, "error", alert.Error)
+		entry.Action = AuditActionInstanceDown
+		entry.Metadata["error"] = alert.Error
+		email = emails.InstanceDown{Subdomain: instance.Subdomain, Since: alert.Since, Error: alert.Error, DetailURL: detailURL}
+	} else {
+		l.Info("instance recovered", "instance_id", instance.ID, "downtime", alert.Downtime)
+		entry.Action = AuditActionInstanceRecovered
+		entry.Metadata["downtime"] = emails.FormatDowntime(alert.Downtime)
+		email = emails.InstanceRecovered{Subdomain: instance.Subdomain, Downtime: alert.Downtime, DetailURL: detailURL}
+	}
+
+	s.recordAudit(ctx, entry)
+	if err := s.notifyOrganizationOwners(ctx, instance.OrganizationID, alert.Event, email); err != nil {
+		l.Error("failed to notify instance owners", "instance_id", instance.ID, "event", alert.Event, "error", err)
+	}
+}
+
+// probeInstance calls n8n's readiness check inside the cluster, the errors are shown to customers
+func probeInstance(ctx context.Context, namespace string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, instanceServiceURL(namespace, "/healthz/readiness"), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := n8nClient.Do(req)
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return fmt.Errorf("no response within %s", n8nRequestTimeout)
+		}
+		return errors.New("n8n is not accepting connections")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// pruneHealthChecks is the JobPruneHealthChecks handler, it deletes probe results older than the longest uptime window
+func (s *Service) pruneHealthChecks(ctx context.Context, job *jobs.Job) error {
+	deleted, err := s.getDB().DeleteInstanceHealthChecksBefore(ctx, pgtype.Timestamp{Time: time.Now().Add(-healthCheckRetention), Valid: true})
+	if err != nil {
+		return fmt.Errorf("failed to delete health checks: %w", err)
+	}
+	appctx.GetLogger(ctx).Info("pruned health checks", "deleted", deleted)
+	return nil
+}
+
+// sendInstanceAlert is the JobSendInstanceAlert handler, it posts a down or recovered alert to the webhook of the instance
+func (s *Service) sendInstanceAlert(ctx context.Context, job *jobs.Job) error {
+	var args instanceAlertArgs
+	if err := job.Bind(&args); err != nil {
+		return err
+	}
+
+	queries := s.getDB()
+	instance, err := queries.GetInstance(ctx, args.InstanceID)
+	if err != nil {
+		// The instance was deleted meanwhile
+		if db.IsNotFoundError(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get instance: %w", err)
+	}
+	monitor, err := queries.GetInstanceMonitor(ctx, args.InstanceID)
+	if err != nil {
+		return fmt.Errorf("failed to get instance monitor: %w", err)
+	}
+	// The webhook was removed meanwhile
+	if monitor.AlertWebhookUrl == "" {
+		return nil
+	}
+
+	payload, err := instanceAlertPayload(monitor.AlertWebhookUrl, toDomainInstance(instance), args)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, monitor.AlertWebhookUrl, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create alert request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ranx.cloud-monitor")
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call alert webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("alert webhook returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// instanceAlertPayload builds the JSON posted to an alert webhook. Slack incoming webhooks
+// get a message, other URLs the event with a text summary for chat tools that show one.
+func instanceAlertPayload(webhookURL string, instance Instance, args instanceAlertArgs) ([]byte, error) {
+	var text string
+	if args.Event == NotificationInstanceDown {
+		text = fmt.Sprintf(":red_circle: n8n instance *%s* is down since %s: %s",
+			instance.Subdomain, args.Since.UTC().Format(time.RFC1123), args.Error)
+	} else {
+		text = fmt.Sprintf(":large_green_circle: n8n instance *%s* is back up after %s",
+			instance.Subdomain, emails.FormatDowntime(args.Downtime))
+	}
+
+	if u, err := url.Parse(webhookURL); err == nil && u.Hostname() == "hooks.slack.com" {
+		return json.Marshal(map[string]string{"text": text})
+	}
+
+	payload := map[string]any{
+		"event":        args.Event,
+		"instance_id":  instance.ID,
+		"subdomain":    instance.Subdomain,
+		"instance_url": instance.GetInstanceURL(),
+		"since":        args.Since.UTC().Format(time.RFC3339),
+		"text":         text,
+	}
+	if args.Event == NotificationInstanceDown {
+		payload["error"] = args.Error
+	} else {
+		payload["downtime_seconds"] = int64(args.Downtime.Seconds())
+	}
+	return json.Marshal(payload)
+}
+
+// webhookClient calls the webhook URLs users enter. It only connects to public addresses,
+// so a webhook can't reach services inside the cluster.
+var webhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: dialPublicOnly,
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, which clusters use for pods and services
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("%s is not a public address", ip)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/aliuygur/n8n-saas-api/internal/provisioning/n8ntemplates"
+)
+
+const (
+	// instanceRouteName is the name of the HTTPRoute in every instance namespace
+	instanceRouteName = "n8n-main"
+	// reconcileRoutesPageSize is how many instances are loaded at a time while reconciling routes
+	reconcileRoutesPageSize = 100
+)
+
+// applyInstanceRoute routes the instance hostname from the Gateway straight to n8n
+func (s *Service) applyInstanceRoute(ctx context.Context, namespace, subdomain string) error {
+	route := &n8ntemplates.HTTPRoute_V1{
+		Namespace:        namespace,
+		Hostname:         s.config.Routing.InstanceHostname(subdomain),
+		GatewayName:      s.config.Routing.GatewayName,
+		GatewayNamespace: s.config.Routing.GatewayNamespace,
+	}
+	if err := s.gke.Apply(ctx, route); err != nil {
+		return fmt.Errorf("failed to apply HTTPRoute: %w", err)
+	}
+	return nil
+}
+
+// deleteInstanceRoute hands the instance hostname back to the proxy
+func (s *Service) deleteInstanceRoute(ctx context.Context, namespace string) error {
+	return s.gke.DeleteHTTPRoute(ctx, namespace, instanceRouteName)
+}
+
+// syncInstanceRoute makes the HTTPRoute of an instance match the routing mode.
+// Instances with an access policy are always served by the proxy, which enforces it.
+func (s *Service) syncInstanceRoute(ctx context.Context, instance *Instance) error {
+	if !s.config.Routing.IsGateway() {
+		return s.deleteInstanceRoute(ctx, instance.Namespace)
+	}
+
+	policy, err := s.GetInstanceAccessPolicy(ctx, instance.ID)
+	if err != nil {
+		return err
+	}
+	if !policy.IsOpen() {
+		return s.deleteInstanceRoute(ctx, instance.Namespace)
+	}
+
+	return s.applyInstanceRoute(ctx, instance.Namespace, instance.Subdomain)
+}
+
+// ReconcileInstanceRoutes brings the HTTPRoutes of all instances in line with the gateway routing mode.
+// It runs as a job queued at startup, so enabling gateway mode only takes a deploy. In proxy mode it does
+// nothing, so deploys don't make an API call per instance, see k8s/app/README.md for switching back.
+func (s *Service) ReconcileInstanceRoutes(ctx context.Context) error {
+	l := appctx.GetLogger(ctx)
+	if !s.config.Routing.IsGateway() {
+		l.Debug("skipping instance route reconciliation in proxy mode")
+		return nil
+	}
+	queries := s.getDB()
+
+	var synced, failed int
+	for offset := int32(0); ; offset += reconcileRoutesPageSize {
+		instances, err := queries.ListAllInstances(ctx, db.ListAllInstancesParams{
+			Limit:  reconcileRoutesPageSize,
+			Offset: offset,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list instances: %w", err)
+		}
+
+		for _, dbInst := range instances {
+			instance := toDomainInstance(dbInst)
+			if err := s.syncInstanceRoute(ctx, &instance); err != nil {
+				l.Error("failed to sync instance route", "instance_id", instance.ID, "namespace", instance.Namespace, "error", err)
+				failed++
+				continue
+			}
+			synced++
+		}
+
+		if len(instances) < reconcileRoutesPageSize {
+			break
+		}
+	}
+
+	l.Info("reconciled instance routes", "mode", s.config.Routing.Mode, "synced", synced, "failed", failed)
+	if failed > 0 {
+		return fmt.Errorf("failed to sync %d of %d instance routes", failed, synced+failed)
+	}
+	return nil
+}
@@ -2,7 +2,9 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -13,16 +15,19 @@ import (
 
 // Instance represents an instance for internal use (domain layer)
 type Instance struct {
-	ID         string
-	UserID     string
-	Status     string
-	Namespace  string
-	Subdomain  string
-	AppVersion string
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
-	DeployedAt *time.Time
-	DeletedAt  *time.Time
+	ID             string
+	UserID         string // The member who created the instance
+	OrganizationID string
+	Status         string
+	Namespace      string
+	Subdomain      string
+	AppVersion     string
+	Env            map[string]string // Custom environment variables of the n8n container
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeployedAt     *time.Time
+	DeletedAt      *time.Time
+	OwnerSetupAt   *time.Time // When the n8n owner account ranx logs in with was created
 }
 
 func (i *Instance) GetInstanceURL() string {
@@ -36,14 +41,19 @@ func InstanceURL(subdomain string) string {
 // toDomainInstance maps a db.Instance to a types.Instance (domain layer)
 func toDomainInstance(dbInst db.Instance) Instance {
 	i := Instance{
-		ID:         dbInst.ID,
-		UserID:     dbInst.UserID,
-		Status:     dbInst.Status,
-		Namespace:  dbInst.Namespace,
-		Subdomain:  dbInst.Subdomain,
-		AppVersion: dbInst.AppVersion,
-		CreatedAt:  dbInst.CreatedAt.Time,
-		UpdatedAt:  dbInst.UpdatedAt.Time,
+		ID:             dbInst.ID,
+		UserID:         dbInst.UserID,
+		OrganizationID: dbInst.OrganizationID,
+		Status:         dbInst.Status,
+		Namespace:      dbInst.Namespace,
+		Subdomain:      dbInst.Subdomain,
+		AppVersion:     dbInst.AppVersion,
+		CreatedAt:      dbInst.CreatedAt.Time,
+		UpdatedAt:      dbInst.UpdatedAt.Time,
+	}
+	// custom_env is only written by UpdateInstance, from a map[string]string
+	if err := json.Unmarshal(dbInst.CustomEnv, &i.Env); err != nil {
+		slog.Error("failed to decode instance custom env", "instance_id", dbInst.ID, "error", err)
 	}
 	if dbInst.DeployedAt.Valid {
 		i.DeployedAt = &dbInst.DeployedAt.Time
@@ -51,14 +61,18 @@ func toDomainInstance(dbInst db.Instance) Instance {
 	if dbInst.DeletedAt.Valid {
 		i.DeletedAt = &dbInst.DeletedAt.Time
 	}
+	if dbInst.OwnerSetupAt.Valid {
+		i.OwnerSetupAt = &dbInst.OwnerSetupAt.Time
+	}
 	return i
 }
 
-func (s *Service) GetInstancesByUser(ctx context.Context, userID string) ([]Instance, error) {
+// GetOrganizationInstances returns the instances owned by an organization
+func (s *Service) GetOrganizationInstances(ctx context.Context, organizationID string) ([]Instance, error) {
 
 	queries := s.getDB()
 
-	dbInstances, err := queries.ListInstancesByUser(ctx, userID)
+	dbInstances, err := queries.ListInstancesByOrganization(ctx, organizationID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list instances: %w", err)
 	}
@@ -83,6 +97,64 @@ func (s *Service) GetInstanceByID(ctx context.Context, instanceID string) (*Inst
 	return &instance, nil
 }
 
+// GetMemberInstance returns an instance owned by the member's organization.
+// Instances of other organizations are reported as not found.
+func (s *Service) GetMemberInstance(ctx context.Context, member Membership, instanceID string) (*Instance, error) {
+	instance, err := s.GetInstanceByID(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	if instance.OrganizationID != member.OrganizationID {
+		return nil, apperrs.Client(apperrs.CodeNotFound, "instance not found")
+	}
+	return instance, nil
+}
+
+// RestartInstance rolls the n8n pods of an instance owned by the member's organization
+func (s *Service) RestartInstance(ctx context.Context, member Membership, instanceID string) error {
+	if err := member.Require(RoleAdmin); err != nil {
+		return err
+	}
+	instance, err := s.GetMemberInstance(ctx, member, instanceID)
+	if err != nil {
+		return err
+	}
+	if instance.Status != InstanceStatusDeployed && instance.Status != InstanceStatusActive {
+		return apperrs.Client(apperrs.CodeConflict, "instance can only be restarted once it is deployed")
+	}
+
+	if err := s.gke.RestartDeployment(ctx, instance.Namespace, n8nDeploymentName); err != nil {
+		return apperrs.Server("failed to restart instance", err)
+	}
+
+	s.recordAudit(ctx, auditEntry{
+		OrganizationID: instance.OrganizationID,
+		UserID:         member.UserID,
+		Action:         AuditActionInstanceRestart,
+		TargetType:     "instance",
+		TargetID:       instance.ID,
+		Metadata:       map[string]string{"subdomain": instance.Subdomain},
+	})
+	return nil
+}
+
+// GetInstanceLogs returns the last instanceLogTailLines lines logged by the n8n pod of an instance
+func (s *Service) GetInstanceLogs(ctx context.Context, member Membership, instanceID string) ([]string, error) {
+	instance, err := s.GetMemberInstance(ctx, member, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	if instance.Status != InstanceStatusDeployed && instance.Status != InstanceStatusActive {
+		return nil, apperrs.Client(apperrs.CodeConflict, "logs are available once the instance is deployed")
+	}
+
+	lines, err := s.gke.PodLogs(ctx, instance.Namespace, "app="+n8nDeploymentName, instanceLogTailLines)
+	if err != nil {
+		return nil, apperrs.Server("failed to get instance logs", err)
+	}
+	return lines, nil
+}
+
 func (s *Service) GetInstanceBySubdomain(ctx context.Context, subdomain string) (*Instance, error) {
 	queries := s.getDB()
 
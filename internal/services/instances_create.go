@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
 	"github.com/aliuygur/n8n-saas-api/internal/db"
 	"github.com/aliuygur/n8n-saas-api/internal/provisioning/n8ntemplates"
+	"github.com/aliuygur/n8n-saas-api/pkg/domainutils"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/samber/lo"
 )
@@ -17,14 +19,47 @@ import (
 // N8NVersion is the current n8n version being deployed
 const N8NVersion = "2.1.4"
 
+// n8nDeploymentName is the name of the n8n deployment in every instance namespace
+const n8nDeploymentName = "n8n-main"
+
+// instanceLogTailLines is how many log lines GetInstanceLogs returns
+const instanceLogTailLines = 500
+
 type CreateInstanceParams struct {
-	UserID    string
+	Member    Membership
 	Subdomain string
 }
 
+// CreateInstance deploys a new instance owned by the member's organization
 func (s *Service) CreateInstance(ctx context.Context, params CreateInstanceParams) (*Instance, error) {
+	instance, err := s.createInstance(ctx, params)
+	var appErr *apperrs.Error
+	if err != nil && !(errors.As(err, &appErr) && appErr.Kind == apperrs.KindClient) {
+		// The transaction rolled back, so there is no instance to point at
+		s.recordAudit(ctx, auditEntry{
+			OrganizationID: params.Member.OrganizationID,
+			UserID:         params.Member.UserID,
+			Action:         AuditActionInstanceProvisionFail,
+			TargetType:     "instance",
+			Metadata:       map[string]string{"subdomain": params.Subdomain, "error": truncate(err.Error(), 500)},
+		})
+		failed := Instance{OrganizationID: params.Member.OrganizationID, Subdomain: params.Subdomain, Status: InstanceStatusFailed}
+		s.publishInstanceEvent(ctx, WebhookEventInstanceFailed, &failed, truncate(err.Error(), 500))
+	}
+	return instance, err
+}
+
+func (s *Service) createInstance(ctx context.Context, params CreateInstanceParams) (*Instance, error) {
 	l := appctx.GetLogger(ctx)
 
+	if err := params.Member.Require(RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if err := domainutils.ValidateSubdomain(params.Subdomain); err != nil {
+		return nil, apperrs.Client(apperrs.CodeInvalidSubdomain, err.Error())
+	}
+
 	queries, tx := s.getDBWithTx(ctx)
 	defer tx.Rollback(ctx)
 
@@ -37,18 +72,22 @@ func (s *Service) CreateInstance(ctx context.Context, params CreateInstanceParam
 		return nil, apperrs.Client(apperrs.CodeConflict, "subdomain already taken")
 	}
 
-	sub, err := queries.GetSubscriptionByUserID(ctx, params.UserID)
+	sub, err := queries.GetSubscriptionByOrganizationID(ctx, params.Member.OrganizationID)
 	if err != nil {
-		return nil, apperrs.Server("failed to get subscription for user", err)
+		return nil, apperrs.Server("failed to get subscription for organization", err)
+	}
+
+	if freeTrialEnded(sub, time.Now()) {
+		return nil, apperrs.Client(apperrs.CodeForbidden, "your free trial has ended, subscribe to create instances")
 	}
 
 	if sub.Status == SubscriptionStatusTrial {
-		count, err := queries.CountActiveInstancesByUserID(ctx, params.UserID)
+		count, err := queries.CountActiveInstancesByOrganizationID(ctx, params.Member.OrganizationID)
 		if err != nil {
 			return nil, apperrs.Server("failed to count instances", err)
 		}
 		if count > 0 {
-			return nil, apperrs.Client(apperrs.CodeForbidden, "trial users can only have one instance")
+			return nil, apperrs.Client(apperrs.CodeForbidden, "trial organizations can only have one instance")
 		}
 	}
 
@@ -70,21 +109,27 @@ func (s *Service) CreateInstance(ctx context.Context, params CreateInstanceParam
 		if err != nil {
 			return nil, apperrs.Server("failed to start trial", err)
 		}
-		l.Debug("started trial subscription", "user_id", params.UserID, "subscription_id", sub.ID, "trial_ends_at", trialEndsAt)
+		l.Debug("started trial subscription", "organization_id", params.Member.OrganizationID, "subscription_id", sub.ID, "trial_ends_at", trialEndsAt)
 	}
 
 	// Create instance in database
 	dbInst, err := queries.CreateInstance(ctx, db.CreateInstanceParams{
-		UserID:     params.UserID,
-		Namespace:  namespace,
-		Subdomain:  params.Subdomain,
-		Status:     InstanceStatusDeployed,
-		AppVersion: N8NVersion,
+		UserID:         params.Member.UserID,
+		OrganizationID: params.Member.OrganizationID,
+		Namespace:      namespace,
+		Subdomain:      params.Subdomain,
+		Status:         InstanceStatusDeployed,
+		AppVersion:     N8NVersion,
 	})
 	if err != nil {
 		return nil, apperrs.Server("failed to create instance in database", err)
 	}
 
+	// Create the n8n owner account once the pod is up, so the dashboard can log users into the editor
+	if err := s.enqueueOwnerSetup(ctx, queries, dbInst.ID); err != nil {
+		return nil, apperrs.Server("failed to enqueue n8n owner setup", err)
+	}
+
 	// Create PostgreSQL database and user for the n8n instance
 	dbName := strings.ReplaceAll(namespace, "-", "_") // PostgreSQL doesn't like hyphens in identifiers
 	dbUser := dbName
@@ -119,13 +164,29 @@ func (s *Service) CreateInstance(ctx context.Context, params CreateInstanceParam
 
 	l.Debug("deployed n8n instance to GKE", "namespace", namespace, "domain", domain)
 
-	// Sync subscription quantity with LemonSqueezy
-	if err := s.SyncSubscriptionQuantity(ctx, params.UserID); err != nil {
-		// Log error but don't fail the creation
-		l.Error("failed to sync subscription quantity", "user_id", params.UserID, "error", err)
+	// New instances have no access policy, so the Gateway can route to them directly.
+	// The proxy keeps serving the instance if the route can't be created.
+	if s.config.Routing.IsGateway() {
+		if err := s.applyInstanceRoute(ctx, namespace, params.Subdomain); err != nil {
+			l.Error("failed to create instance route, falling back to proxy", "namespace", namespace, "error", err)
+		}
 	}
 
+	// Sync subscription quantity with LemonSqueezy
+	s.enqueueQuantitySync(ctx, params.Member.OrganizationID)
+
 	instance := toDomainInstance(dbInst)
+
+	s.recordAudit(ctx, auditEntry{
+		OrganizationID: instance.OrganizationID,
+		UserID:         params.Member.UserID,
+		Action:         AuditActionInstanceCreate,
+		TargetType:     "instance",
+		TargetID:       instance.ID,
+		Metadata:       map[string]string{"subdomain": instance.Subdomain},
+	})
+	s.publishInstanceEvent(ctx, WebhookEventInstanceCreated, &instance, "")
+
 	return &instance, nil
 }
 
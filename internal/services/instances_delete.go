@@ -8,50 +8,79 @@ import (
 	"github.com/aliuygur/n8n-saas-api/internal/appctx"
 	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
 	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/aliuygur/n8n-saas-api/internal/emails"
 )
 
 type DeleteInstanceParams struct {
-	UserID     string
+	Member     Membership
 	InstanceID string
 }
 
-// DeleteAllUserInstances deletes all instances for a given user.
-// This is called when a subscription expires to clean up all user resources.
-func (s *Service) DeleteAllUserInstances(ctx context.Context, userID string) error {
+// DeleteAllOrganizationInstances deletes all instances of an organization and returns the subdomains
+// of the ones it deleted, also when some failed.
+// This is called when a subscription expires to clean up all organization resources.
+func (s *Service) DeleteAllOrganizationInstances(ctx context.Context, organizationID string) ([]string, error) {
 	log := appctx.GetLogger(ctx)
 	queries := s.getDB()
 
-	instances, err := queries.ListInstancesByUser(ctx, userID)
+	instances, err := queries.ListInstancesByOrganization(ctx, organizationID)
 	if err != nil {
-		return fmt.Errorf("failed to list user instances: %w", err)
+		return nil, fmt.Errorf("failed to list organization instances: %w", err)
 	}
 
 	if len(instances) == 0 {
-		log.Info("no instances to delete for user", "user_id", userID)
-		return nil
+		log.Info("no instances to delete for organization", "organization_id", organizationID)
+		return nil, nil
 	}
 
+	// The cleanup acts with the rights of the organization itself
+	system := Membership{OrganizationID: organizationID, Role: RoleOwner}
+
+	var deleted []string
 	var deleteErrors []error
 	for _, inst := range instances {
 		if err := s.DeleteInstance(ctx, DeleteInstanceParams{
-			UserID:     userID,
+			Member:     system,
 			InstanceID: inst.ID,
 		}); err != nil {
 			log.Error("failed to delete instance", "instance_id", inst.ID, "error", err)
 			deleteErrors = append(deleteErrors, err)
 		} else {
 			log.Info("deleted instance", "instance_id", inst.ID)
+			deleted = append(deleted, inst.Subdomain)
 		}
 	}
 
 	if len(deleteErrors) > 0 {
-		return fmt.Errorf("failed to delete %d of %d instances", len(deleteErrors), len(instances))
+		return deleted, fmt.Errorf("failed to delete %d of %d instances", len(deleteErrors), len(instances))
 	}
-	return nil
+	return deleted, nil
 }
 
+// notifyInstancesDeleted tells the owners of an organization that instances were deleted because reason.
+// The instances are gone either way, so a failed email is only logged.
+func (s *Service) notifyInstancesDeleted(ctx context.Context, organizationID string, subdomains []string, reason string) {
+	if len(subdomains) == 0 {
+		return
+	}
+	email := emails.InstanceDeleted{
+		Subdomains:  subdomains,
+		Reason:      reason,
+		ActionURL:   s.config.Server.BaseURL("/subscription"),
+		ActionLabel: "Subscribe to create new instances",
+	}
+	if err := s.notifyOrganizationOwners(ctx, organizationID, NotificationInstanceDeleted, email); err != nil {
+		appctx.GetLogger(ctx).Error("failed to notify about deleted instances", "organization_id", organizationID, "error", err)
+	}
+}
+
+// DeleteInstance tears down an instance owned by the member's organization
 func (s *Service) DeleteInstance(ctx context.Context, params DeleteInstanceParams) error {
-	queries, releaseLock := s.getDBWithLock(ctx, fmt.Sprintf("user_instance_delete_%s", params.UserID))
+	if err := params.Member.Require(RoleAdmin); err != nil {
+		return err
+	}
+
+	queries, releaseLock := s.getDBWithLock(ctx, fmt.Sprintf("organization_instance_delete_%s", params.Member.OrganizationID))
 	defer releaseLock()
 
 	l := appctx.GetLogger(ctx)
@@ -64,8 +93,8 @@ func (s *Service) DeleteInstance(ctx context.Context, params DeleteInstanceParam
 		return fmt.Errorf("failed to get instance: %w", err)
 	}
 
-	if instance.UserID != params.UserID {
-		return apperrs.Client(apperrs.CodeForbidden, "user does not own the instance")
+	if instance.OrganizationID != params.Member.OrganizationID {
+		return apperrs.Client(apperrs.CodeNotFound, "instance not found")
 	}
 
 	if err := s.gke.DeleteNamespace(ctx, instance.Namespace); err != nil {
@@ -87,11 +116,20 @@ func (s *Service) DeleteInstance(ctx context.Context, params DeleteInstanceParam
 	}
 	l.Debug("deleted instance from database", "instance_id", params.InstanceID)
 
+	s.recordAudit(ctx, auditEntry{
+		OrganizationID: instance.OrganizationID,
+		UserID:         params.Member.UserID,
+		Action:         AuditActionInstanceDelete,
+		TargetType:     "instance",
+		TargetID:       instance.ID,
+		Metadata:       map[string]string{"subdomain": instance.Subdomain},
+	})
+	deleted := toDomainInstance(instance)
+	deleted.Status = InstanceStatusDeleted
+	s.publishInstanceEvent(ctx, WebhookEventInstanceDeleted, &deleted, "")
+
 	// Sync subscription quantity with LemonSqueezy
-	if err := s.SyncSubscriptionQuantity(ctx, params.UserID); err != nil {
-		// Log error but don't fail the deletion
-		l.Error("failed to sync subscription quantity", "user_id", params.UserID, "error", err)
-	}
+	s.enqueueQuantitySync(ctx, params.Member.OrganizationID)
 
 	return nil
 }
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/aliuygur/n8n-saas-api/internal/testdb"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestDeleteInstanceRecord(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := testdb.Context()
+	member := createTestUser(t, s, "deleted")
+	instance := createTestInstance(t, s, member, "deleted")
+	kept := createTestInstance(t, s, member, "kept")
+	queries := s.getDB()
+
+	for _, id := range []string{instance.ID, kept.ID} {
+		if _, err := queries.UpsertInstanceLimitOverride(ctx, db.UpsertInstanceLimitOverrideParams{
+			InstanceID:        id,
+			RequestsPerSecond: pgtype.Int4{Int32: 500, Valid: true},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := queries.UpsertInstanceAccessPolicy(ctx, db.UpsertInstanceAccessPolicyParams{
+			InstanceID:            id,
+			AllowedCidrs:          []string{"203.0.113.0/24"},
+			AuthMode:              AccessAuthBasic,
+			BasicAuthUsername:     "admin",
+			BasicAuthPasswordHash: "$2a$10$hash",
+			PublicPaths:           []string{},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := queries.DeleteInstance(ctx, instance.ID); err != nil {
+		t.Fatalf("Failed to delete instance: %v", err)
+	}
+
+	if _, err := queries.GetInstanceLimitOverride(ctx, instance.ID); !db.IsNotFoundError(err) {
+		t.Errorf("Expected the override of the deleted instance to be gone, got %v", err)
+	}
+	if _, err := queries.GetInstanceLimitOverride(ctx, kept.ID); err != nil {
+		t.Errorf("Expected the override of another instance to stay, got %v", err)
+	}
+	if _, err := queries.GetInstanceAccessPolicy(ctx, instance.ID); !db.IsNotFoundError(err) {
+		t.Errorf("Expected the access policy and its credentials to be gone, got %v", err)
+	}
+	if _, err := queries.GetInstanceAccessPolicy(ctx, kept.ID); err != nil {
+		t.Errorf("Expected the access policy of another instance to stay, got %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/aliuygur/n8n-saas-api/internal/provisioning"
+)
+
+const (
+	// maxCustomEnvVars limits the custom environment variables of an instance
+	maxCustomEnvVars = 50
+	// maxCustomEnvValueLength limits the size of one custom environment variable
+	maxCustomEnvValueLength = 4096
+)
+
+var (
+	envNamePattern    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	appVersionPattern = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
+)
+
+// reservedEnvNames are set by the n8n template, overriding them would break the instance or its isolation
+var reservedEnvNames = map[string]bool{
+	"N8N_USER_FOLDER":              true,
+	"N8N_ENCRYPTION_KEY":           true,
+	"GENERIC_TIMEZONE":             true,
+	"NODE_ENV":                     true,
+	"N8N_EDITOR_BASE_URL":          true,
+	"WEBHOOK_URL":                  true,
+	"EXECUTIONS_DATA_MAX_AGE":      true,
+	"N8N_LOG_LEVEL":                true,
+	"N8N_NATIVE_PYTHON_RUNNER":     true,
+	"N8N_BLOCK_ENV_ACCESS_IN_NODE": true,
+	"NODES_EXCLUDE":                true,
+	"N8N_PORT":                     true,
+	"N8N_LISTEN_ADDRESS":           true,
+	"N8N_PATH":                     true,
+}
+
+// reservedEnvPrefixes cover the database and task runner settings
+var reservedEnvPrefixes = []string{"DB_", "N8N_RUNNERS_"}
+
+type UpdateInstanceParams struct {
+	Member     Membership
+	InstanceID string
+	AppVersion string
+	Env        map[string]string // Replaces all custom environment variables
+}
+
+// UpdateInstance sets the desired n8n version and custom environment of an instance.
+// Sending the current configuration again changes nothing.
+//
+// The new configuration is committed before the deployment is patched, so the row isn't
+// locked during the Kubernetes call. A failed patch puts the previous configuration back.
+func (s *Service) UpdateInstance(ctx context.Context, params UpdateInstanceParams) (*Instance, error) {
+	if err := params.Member.Require(RoleAdmin); err != nil {
+		return nil, err
+	}
+	if err := validateCustomEnv(params.Env); err != nil {
+		return nil, err
+	}
+	if params.Env == nil {
+		params.Env = map[string]string{}
+	}
+
+	queries, tx := s.getDBWithTx(ctx)
+	defer tx.Rollback(ctx)
+
+	// Lock the row so concurrent updates apply one after the other
+	dbInst, err := queries.GetInstanceForUpdate(ctx, params.InstanceID)
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			return nil, apperrs.Client(apperrs.CodeNotFound, "instance not found")
+		}
+		return nil, fmt.Errorf("failed to lock instance: %w", err)
+	}
+	current := toDomainInstance(dbInst)
+
+	if current.OrganizationID != params.Member.OrganizationID {
+		return nil, apperrs.Client(apperrs.CodeNotFound, "instance not found")
+	}
+	if current.Status != InstanceStatusDeployed && current.Status != InstanceStatusActive {
+		return nil, apperrs.Client(apperrs.CodeConflict, "instance can only be updated once it is deployed")
+	}
+	if err := validateAppVersion(params.AppVersion, current.AppVersion); err != nil {
+		return nil, err
+	}
+	if params.AppVersion == current.AppVersion && maps.Equal(params.Env, current.Env) {
+		return &current, nil
+	}
+
+	env, err := json.Marshal(params.Env)
+	if err != nil {
+		return nil, apperrs.Server("failed to encode custom env", err)
+	}
+	updated, err := queries.UpdateInstanceConfig(ctx, db.UpdateInstanceConfigParams{
+		ID:         current.ID,
+		AppVersion: params.AppVersion,
+		CustomEnv:  env,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update instance: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, apperrs.Server("failed to commit transaction", err)
+	}
+
+	// The configuration is saved, a client that goes away must not leave it unapplied
+	ctx = context.WithoutCancel(ctx)
+
+	var removed []string
+	for _, key := range slices.Sorted(maps.Keys(current.Env)) {
+		if _, ok := params.Env[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+
+	if err := s.gke.PatchDeploymentContainers(ctx, current.Namespace, n8nDeploymentName, []provisioning.ContainerPatch{
+		{
+			Name:      "n8n",
+			Image:     "n8nio/n8n:" + params.AppVersion,
+			SetEnv:    params.Env,
+			RemoveEnv: removed,
+		},
+		{
+			Name:  "task-runner",
+			Image: "n8nio/runners:" + params.AppVersion,
+		},
+	}); err != nil {
+		s.revertInstanceConfig(ctx, dbInst, updated)
+		return nil, apperrs.Server("failed to update instance deployment", err)
+	}
+
+	result := toDomainInstance(updated)
+
+	// Values of the custom env can be secrets, only the names are recorded
+	s.recordAudit(ctx, auditEntry{
+		OrganizationID: result.OrganizationID,
+		UserID:         params.Member.UserID,
+		Action:         AuditActionInstanceUpdate,
+		TargetType:     "instance",
+		TargetID:       result.ID,
+		Metadata: map[string]string{
+			"subdomain":   result.Subdomain,
+			"app_version": result.AppVersion,
+			"env":         strings.Join(slices.Sorted(maps.Keys(result.Env)), ","),
+		},
+	})
+
+	return &result, nil
+}
+
+// revertInstanceConfig puts the configuration of an instance back after its deployment
+// could not be patched, so retrying the update isn't mistaken for a no-op
+func (s *Service) revertInstanceConfig(ctx context.Context, previous, updated db.Instance) {
+	l := appctx.GetLogger(ctx)
+
+	reverted, err := s.getDB().RevertInstanceConfig(ctx, db.RevertInstanceConfigParams{
+		ID:                 updated.ID,
+		PreviousAppVersion: previous.AppVersion,
+		PreviousCustomEnv:  previous.CustomEnv,
+		AppVersion:         updated.AppVersion,
+		CustomEnv:          updated.CustomEnv,
+	})
+	if err != nil {
+		l.Error("failed to revert instance config", "instance_id", updated.ID, "error", err)
+		return
+	}
+	if reverted == 0 {
+		l.Warn("instance config changed before it could be reverted", "instance_id", updated.ID)
+	}
+}
+
+// validateAppVersion allows upgrades up to the version we deploy, n8n can't migrate its database back
+func validateAppVersion(version, current string) error {
+	if !appVersionPattern.MatchString(version) {
+		return apperrs.Client(apperrs.CodeInvalidInput, "app_version must look like 1.2.3")
+	}
+	if current != "" && compareVersions(version, current) < 0 {
+		return apperrs.Client(apperrs.CodeInvalidInput, fmt.Sprintf("downgrading from n8n %s to %s is not supported", current, version))
+	}
+	if compareVersions(version, N8NVersion) > 0 {
+		return apperrs.Client(apperrs.CodeInvalidInput, fmt.Sprintf("n8n %s is not available yet, the latest supported version is %s", version, N8NVersion))
+	}
+	return nil
+}
+
+// compareVersions compares two x.y.z versions like strings.Compare
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, _ := strconv.Atoi(as[i])
+		y, _ := strconv.Atoi(bs[i])
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(as) - len(bs)
+}
+
+func validateCustomEnv(env map[string]string) error {
+	if len(env) > maxCustomEnvVars {
+		return apperrs.Client(apperrs.CodeInvalidInput, fmt.Sprintf("at most %d environment variables are allowed", maxCustomEnvVars))
+	}
+	for name, value := range env {
+		if !envNamePattern.MatchString(name) {
+			return apperrs.Client(apperrs.CodeInvalidInput, fmt.Sprintf("invalid environment variable name %q", name))
+		}
+		if reservedEnvNames[name] || slices.ContainsFunc(reservedEnvPrefixes, func(prefix string) bool {
+			return strings.HasPrefix(name, prefix)
+		}) {
+			return apperrs.Client(apperrs.CodeInvalidInput, fmt.Sprintf("environment variable %s is managed by ranx.cloud", name))
+		}
+		if len(value) > maxCustomEnvValueLength {
+			return apperrs.Client(apperrs.CodeInvalidInput, fmt.Sprintf("environment variable %s is longer than %d characters", name, maxCustomEnvValueLength))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/jobs"
+)
+
+// Kinds of background jobs, named <area>.<verb>
+const (
+	JobExpireTrials             = "trials.expire"
+	JobSyncSubscriptionQuantity = "subscriptions.sync_quantity"
+	JobSendNotification         = "notifications.send"
+	JobCheckInstances           = "instances.check_health"
+	JobPruneHealthChecks        = "instances.prune_health_checks"
+	JobSendInstanceAlert        = "instances.send_alert"
+	JobDeliverWebhook           = "webhooks.deliver"
+	JobPruneWebhookDeliveries   = "webhooks.prune_deliveries"
+	JobSetupInstanceOwner       = "instances.setup_owner"
+	JobReconcileInstanceRoutes  = "instances.reconcile_routes"
+
+	// JobCleanupCaches runs on each replica, see RunOnEachReplica
+	JobCleanupCaches = "proxy.cleanup_caches"
+)
+
+// reconcileRoutesUniqueKey keeps replicas starting together from queueing a reconciliation each
+const reconcileRoutesUniqueKey = "startup"
+
+// syncQuantityArgs are the arguments of JobSyncSubscriptionQuantity
+type syncQuantityArgs struct {
+	OrganizationID string `json:"organization_id"`
+}
+
+// registerJobs sets the handlers and schedules of background jobs
+func (s *Service) registerJobs() error {
+	s.jobs.Register(JobExpireTrials, func(ctx context.Context, job *jobs.Job) error {
+		return s.ExpireTrials(withAuditActor(ctx, AuditActorSystem))
+	})
+	if err := s.jobs.Schedule(JobExpireTrials, "@every "+s.config.Trial.CheckInterval.String()); err != nil {
+		return err
+	}
+
+	s.jobs.Register(JobSyncSubscriptionQuantity, func(ctx context.Context, job *jobs.Job) error {
+		var args syncQuantityArgs
+		if err := job.Bind(&args); err != nil {
+			return err
+		}
+		return s.SyncSubscriptionQuantity(ctx, args.OrganizationID)
+	})
+
+	s.jobs.Register(JobSendNotification, s.sendNotification)
+
+	s.jobs.Register(JobCheckInstances, func(ctx context.Context, job *jobs.Job) error {
+		return s.CheckInstances(withAuditActor(ctx, AuditActorSystem))
+	})
+	if err := s.jobs.Schedule(JobCheckInstances, "@every "+s.config.Monitor.CheckInterval.String()); err != nil {
+		return err
+	}
+
+	s.jobs.Register(JobPruneHealthChecks, s.pruneHealthChecks)
+	if err := s.jobs.Schedule(JobPruneHealthChecks, "41 4 * * *"); err != nil {
+		return err
+	}
+
+	s.jobs.Register(JobSendInstanceAlert, s.sendInstanceAlert)
+	s.jobs.Register(JobSetupInstanceOwner, s.setupInstanceOwnerJob)
+	s.jobs.Register(JobReconcileInstanceRoutes, func(ctx context.Context, job *jobs.Job) error {
+		return s.ReconcileInstanceRoutes(ctx)
+	})
+
+	s.jobs.Register(JobDeliverWebhook, s.deliverWebhook)
+	s.jobs.Register(JobPruneWebhookDeliveries, s.pruneWebhookDeliveries)
+	if err := s.jobs.Schedule(JobPruneWebhookDeliveries, "53 4 * * *"); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RunJobs runs background jobs until ctx is cancelled and the running ones drained
+func (s *Service) RunJobs(ctx context.Context) {
+	s.jobs.Run(ctx)
+}
+
+// RunOnEachReplica calls fn every interval on this replica while RunJobs runs, for work on
+// state the replica keeps in memory. Call it before RunJobs.
+func (s *Service) RunOnEachReplica(kind string, interval time.Duration, fn func(ctx context.Context)) error {
+	return s.jobs.Tick(kind, interval, fn)
+}
+
+// EnqueueRouteReconciliation queues ReconcileInstanceRoutes, so a deploy reconciles the routes
+// once instead of on every replica that starts
+func (s *Service) EnqueueRouteReconciliation(ctx context.Context) error {
+	_, err := s.jobs.Enqueue(ctx, JobReconcileInstanceRoutes, nil, jobs.EnqueueOptions{UniqueKey: reconcileRoutesUniqueKey})
+	return err
+}
+
+// enqueueQuantitySync syncs the instance count of an organization to LemonSqueezy in the background,
+// retrying while LemonSqueezy is unavailable. Syncs already queued for the organization count the
+// instances when they run, so another one is not needed.
+func (s *Service) enqueueQuantitySync(ctx context.Context, organizationID string) {
+	if _, err := s.jobs.Enqueue(ctx, JobSyncSubscriptionQuantity, syncQuantityArgs{OrganizationID: organizationID}, jobs.EnqueueOptions{
+		UniqueKey: organizationID,
+	}); err != nil {
+		appctx.GetLogger(ctx).Error("failed to enqueue subscription quantity sync", "organization_id", organizationID, "error", err)
+	}
+}
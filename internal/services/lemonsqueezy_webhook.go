@@ -3,10 +3,13 @@ package services
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aliuygur/n8n-saas-api/internal/appctx"
 	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/aliuygur/n8n-saas-api/internal/emails"
 	"github.com/aliuygur/n8n-saas-api/pkg/lemonsqueezy"
 	"github.com/jackc/pgx/v5/pgtype"
 )
@@ -100,7 +103,8 @@ type LemonSqueezyWebhookPayload struct {
 
 // CustomData represents custom data passed in the webhook
 type CustomData struct {
-	UserID string `json:"user_id"`
+	UserID         string `json:"user_id"`
+	OrganizationID string `json:"organization_id"` // Missing from checkouts started before organizations existed
 }
 
 // PauseInfo represents pause information for a subscription
@@ -114,8 +118,43 @@ func (s *Service) VerifyLemonSqueezySignature(payload []byte, signature string)
 	return s.lemonsqueezy.VerifyWebhookSignature(payload, signature)
 }
 
-// HandleLemonSqueezyEvent handles different webhook events
+// HandleLemonSqueezyEvent handles different webhook events and records subscription events in the audit log
 func (s *Service) HandleLemonSqueezyEvent(ctx context.Context, payload *LemonSqueezyWebhookPayload) error {
+	// Instances deleted on behalf of LemonSqueezy are attributed to it
+	ctx = withAuditActor(ctx, AuditActorLemonSqueezy)
+
+	if err := s.handleLemonSqueezyEvent(ctx, payload); err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(payload.Meta.EventName, "subscription_") {
+		s.auditSubscriptionEvent(ctx, payload)
+	}
+	return nil
+}
+
+// auditSubscriptionEvent records a handled subscription event for the organization it belongs to
+// and publishes the subscription to its webhooks
+func (s *Service) auditSubscriptionEvent(ctx context.Context, payload *LemonSqueezyWebhookPayload) {
+	log := appctx.GetLogger(ctx)
+
+	sub, err := s.getDB().GetSubscriptionByProviderID(ctx, payload.Data.ID)
+	if err != nil {
+		log.Error("failed to get subscription for audit log", "subscription_id", payload.Data.ID, "error", err)
+		return
+	}
+
+	s.recordAudit(ctx, auditEntry{
+		OrganizationID: sub.OrganizationID,
+		Action:         AuditActionSubscriptionPrefix + strings.TrimPrefix(payload.Meta.EventName, "subscription_"),
+		TargetType:     "subscription",
+		TargetID:       sub.ID,
+		Metadata:       map[string]string{"status": sub.Status, "quantity": strconv.Itoa(int(sub.Quantity))},
+	})
+	s.publishSubscriptionUpdated(ctx, sub)
+}
+
+func (s *Service) handleLemonSqueezyEvent(ctx context.Context, payload *LemonSqueezyWebhookPayload) error {
 	log := appctx.GetLogger(ctx)
 
 	switch payload.Meta.EventName {
@@ -170,15 +209,25 @@ func (s *Service) handleSubscriptionCreated(ctx context.Context, payload *LemonS
 		return fmt.Errorf("missing user_id in custom_data")
 	}
 
-	// Check if user has a subscription record
-	existingSub, err := queries.GetSubscriptionByUserID(ctx, userID)
+	organizationID := payload.Meta.CustomData.OrganizationID
+	if organizationID == "" {
+		org, err := queries.GetPersonalOrganization(ctx, userID)
+		if err != nil {
+			log.Error("Failed to get personal organization", "user_id", userID, "error", err)
+			return fmt.Errorf("failed to get personal organization: %w", err)
+		}
+		organizationID = org.ID
+	}
+
+	// Check if the organization has a subscription record
+	existingSub, err := queries.GetSubscriptionByOrganizationID(ctx, organizationID)
 	if err != nil {
 		if db.IsNotFoundError(err) {
-			log.Error("User has no subscription record", "user_id", userID)
-			return fmt.Errorf("user %s has no subscription record", userID)
+			log.Error("Organization has no subscription record", "organization_id", organizationID)
+			return fmt.Errorf("organization %s has no subscription record", organizationID)
 		}
-		log.Error("Failed to get user subscription", "error", err)
-		return fmt.Errorf("failed to get user subscription: %w", err)
+		log.Error("Failed to get organization subscription", "error", err)
+		return fmt.Errorf("failed to get organization subscription: %w", err)
 	}
 
 	// Parse trial end date if present
@@ -200,8 +249,8 @@ func (s *Service) handleSubscriptionCreated(ctx context.Context, payload *LemonS
 	}
 
 	// Update existing subscription with provider details
-	err = queries.UpdateSubscriptionByUserID(ctx, db.UpdateSubscriptionByUserIDParams{
-		UserID:         userID,
+	err = queries.UpdateSubscriptionByOrganizationID(ctx, db.UpdateSubscriptionByOrganizationIDParams{
+		OrganizationID: organizationID,
 		ProductID:      fmt.Sprintf("%d", payload.Data.Attributes.ProductID),
 		VariantID:      fmt.Sprintf("%d", payload.Data.Attributes.VariantID),
 		CustomerID:     fmt.Sprintf("%d", payload.Data.Attributes.CustomerID),
@@ -219,10 +268,16 @@ func (s *Service) handleSubscriptionCreated(ctx context.Context, payload *LemonS
 	log.Info("Subscription updated from webhook",
 		"subscription_id", payload.Data.ID,
 		"user_id", userID,
+		"organization_id", organizationID,
 		"existing_sub_id", existingSub.ID,
 		"quantity", quantity,
 		"status", status)
 
+	// Instances hibernated at the end of the free trial run again once the organization subscribed
+	if err := s.wakeHibernatedInstances(ctx, organizationID); err != nil {
+		log.Error("Failed to wake hibernated instances", "organization_id", organizationID, "error", err)
+	}
+
 	return nil
 }
 
@@ -289,23 +344,25 @@ func (s *Service) handleSubscriptionResumed(ctx context.Context, payload *LemonS
 
 // handleSubscriptionExpired handles subscription expiration.
 // When a subscription expires (e.g., after cancellation period ends),
-// all user instances are deleted and the subscription status is updated.
+// all instances of the organization are deleted and the subscription status is updated.
 func (s *Service) handleSubscriptionExpired(ctx context.Context, payload *LemonSqueezyWebhookPayload) error {
 	log := appctx.GetLogger(ctx)
 	queries := s.getDB()
 
-	// Get subscription to find the user
+	// Get subscription to find the organization
 	sub, err := queries.GetSubscriptionByProviderID(ctx, payload.Data.ID)
 	if err != nil {
 		log.Error("failed to get subscription", "error", err)
 		return fmt.Errorf("failed to get subscription: %w", err)
 	}
 
-	// Delete all instances for this user
-	if err := s.DeleteAllUserInstances(ctx, sub.UserID); err != nil {
-		log.Error("failed to delete user instances", "user_id", sub.UserID, "error", err)
+	// Delete all instances of this organization
+	deleted, err := s.DeleteAllOrganizationInstances(ctx, sub.OrganizationID)
+	if err != nil {
+		log.Error("failed to delete organization instances", "organization_id", sub.OrganizationID, "error", err)
 		// Continue to update status even if deletion partially failed
 	}
+	s.notifyInstancesDeleted(ctx, sub.OrganizationID, deleted, "your subscription ended")
 
 	// Update subscription status
 	err = queries.UpdateSubscriptionStatusByProviderID(ctx, db.UpdateSubscriptionStatusByProviderIDParams{
@@ -319,7 +376,7 @@ func (s *Service) handleSubscriptionExpired(ctx context.Context, payload *LemonS
 
 	log.Info("subscription expired and instances deleted",
 		"subscription_id", payload.Data.ID,
-		"user_id", sub.UserID)
+		"organization_id", sub.OrganizationID)
 	return nil
 }
 
@@ -400,9 +457,33 @@ func (s *Service) handleSubscriptionPaymentFailed(ctx context.Context, payload *
 	}
 
 	log.Warn("Subscription payment failed", "subscription_id", payload.Data.ID)
+
+	// LemonSqueezy retries the webhook on errors, so a failed email is only logged
+	if err := s.notifyPaymentFailed(ctx, payload.Data.ID); err != nil {
+		log.Error("Failed to notify about failed payment", "subscription_id", payload.Data.ID, "error", err)
+	}
 	return nil
 }
 
+// notifyPaymentFailed emails the owners of the organization paying with a subscription
+func (s *Service) notifyPaymentFailed(ctx context.Context, providerSubscriptionID string) error {
+	queries := s.getDB()
+
+	sub, err := queries.GetSubscriptionByProviderID(ctx, providerSubscriptionID)
+	if err != nil {
+		return fmt.Errorf("failed to get subscription: %w", err)
+	}
+	org, err := queries.GetOrganization(ctx, sub.OrganizationID)
+	if err != nil {
+		return fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	return s.notifyOrganizationOwners(ctx, sub.OrganizationID, NotificationPaymentFailed, emails.PaymentFailed{
+		OrganizationName: org.Name,
+		BillingURL:       s.config.Server.BaseURL("/account"),
+	})
+}
+
 // mapLemonSqueezyStatus maps Lemon Squeezy status to internal status
 func mapLemonSqueezyStatus(lsStatus string) string {
 	switch lsStatus {
@@ -425,19 +506,25 @@ func mapLemonSqueezyStatus(lsStatus string) string {
 	}
 }
 
-// CreateUpgradeCheckoutURL creates a LemonSqueezy checkout URL for upgrading from trial to paid
-func (s *Service) CreateUpgradeCheckoutURL(ctx context.Context, userID string) (string, error) {
+// CreateUpgradeCheckoutURL creates a LemonSqueezy checkout URL for upgrading the member's organization
+// from trial to paid. Only owners manage billing.
+func (s *Service) CreateUpgradeCheckoutURL(ctx context.Context, member Membership) (string, error) {
+	if err := member.Require(RoleOwner); err != nil {
+		return "", err
+	}
+
 	// Get user details for email
 	queries := s.getDB()
-	user, err := queries.GetUserByID(ctx, userID)
+	user, err := queries.GetUserByID(ctx, member.UserID)
 	if err != nil {
 		return "", fmt.Errorf("failed to get user: %w", err)
 	}
 
 	return fmt.Sprintf(
-		"https://ranx.lemonsqueezy.com/buy/%s?checkout[email]=%s&checkout[custom][user_id]=%s&embed=1&media=0&desc=0",
+		"https://ranx.lemonsqueezy.com/buy/%s?checkout[email]=%s&checkout[custom][user_id]=%s&checkout[custom][organization_id]=%s&embed=1&media=0&desc=0",
 		s.config.LemonSqueezy.VariantID,
 		user.Email,
 		user.ID,
+		member.OrganizationID,
 	), nil
 }
@@ -0,0 +1,327 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Plans used to look up proxy limits
+const (
+	PlanTrial    = "trial"
+	PlanStandard = "standard"
+)
+
+// ProxyLimits holds the limits the tenant proxy enforces for an instance.
+// Zero MaxBodyBytes or MaxConcurrent means unlimited.
+type ProxyLimits struct {
+	Plan                string
+	RequestsPerSecond   int
+	Burst               int
+	IPRequestsPerSecond int
+	IPBurst             int
+	MaxBodyBytes        int64
+	MaxConcurrent       int
+}
+
+// LimitOverride holds admin overrides for a single instance, nil fields fall back to the plan
+type LimitOverride struct {
+	RequestsPerSecond   *int
+	Burst               *int
+	IPRequestsPerSecond *int
+	IPBurst             *int
+	MaxBodyBytes        *int64
+	MaxConcurrent       *int
+}
+
+// PlanForSubscription returns the plan whose limits apply to a subscription
+func PlanForSubscription(sub *Subscription) string {
+	if sub == nil || sub.IsTrial() {
+		return PlanTrial
+	}
+	return PlanStandard
+}
+
+// GetInstanceProxyLimits resolves the effective proxy limits for an instance
+// from the owning organization's plan and any admin overrides
+func (s *Service) GetInstanceProxyLimits(ctx context.Context, instance *Instance) (*ProxyLimits, error) {
+	queries := s.getDB()
+
+	var sub *Subscription
+	dbSub, err := queries.GetSubscriptionByOrganizationID(ctx, instance.OrganizationID)
+	if err != nil && !db.IsNotFoundError(err) {
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+	if err == nil {
+		sub = toDomainSubscription(dbSub)
+	}
+
+	plan := PlanForSubscription(sub)
+	planLimits, err := queries.GetPlanLimits(ctx, plan)
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			return nil, apperrs.Server(fmt.Sprintf("no limits configured for plan %q", plan), err)
+		}
+		return nil, fmt.Errorf("failed to get plan limits: %w", err)
+	}
+
+	limits := &ProxyLimits{
+		Plan:                plan,
+		RequestsPerSecond:   int(planLimits.RequestsPerSecond),
+		Burst:               int(planLimits.Burst),
+		IPRequestsPerSecond: int(planLimits.IpRequestsPerSecond),
+		IPBurst:             int(planLimits.IpBurst),
+		MaxBodyBytes:        planLimits.MaxBodyBytes,
+		MaxConcurrent:       int(planLimits.MaxConcurrent),
+	}
+
+	override, err := queries.GetInstanceLimitOverride(ctx, instance.ID)
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			return limits, nil
+		}
+		return nil, fmt.Errorf("failed to get instance limit override: %w", err)
+	}
+
+	if override.RequestsPerSecond.Valid {
+		limits.RequestsPerSecond = int(override.RequestsPerSecond.Int32)
+	}
+	if override.Burst.Valid {
+		limits.Burst = int(override.Burst.Int32)
+	}
+	if override.IpRequestsPerSecond.Valid {
+		limits.IPRequestsPerSecond = int(override.IpRequestsPerSecond.Int32)
+	}
+	if override.IpBurst.Valid {
+		limits.IPBurst = int(override.IpBurst.Int32)
+	}
+	if override.MaxBodyBytes.Valid {
+		limits.MaxBodyBytes = override.MaxBodyBytes.Int64
+	}
+	if override.MaxConcurrent.Valid {
+		limits.MaxConcurrent = int(override.MaxConcurrent.Int32)
+	}
+
+	return limits, nil
+}
+
+// InstanceLimitOverride is the admin override of a live instance
+type InstanceLimitOverride struct {
+	LimitOverride
+	InstanceID string
+	Subdomain  string
+}
+
+// AdminListPlanLimits returns the proxy limits configured for every plan
+func (s *Service) AdminListPlanLimits(ctx context.Context) ([]ProxyLimits, error) {
+	queries := s.getDB()
+
+	rows, err := queries.ListPlanLimits(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list plan limits: %w", err)
+	}
+
+	limits := make([]ProxyLimits, 0, len(rows))
+	for _, row := range rows {
+		limits = append(limits, ProxyLimits{
+			Plan:                row.Plan,
+			RequestsPerSecond:   int(row.RequestsPerSecond),
+			Burst:               int(row.Burst),
+			IPRequestsPerSecond: int(row.IpRequestsPerSecond),
+			IPBurst:             int(row.IpBurst),
+			MaxBodyBytes:        row.MaxBodyBytes,
+			MaxConcurrent:       int(row.MaxConcurrent),
+		})
+	}
+	return limits, nil
+}
+
+// AdminListInstanceLimitOverrides returns the overrides of live instances
+func (s *Service) AdminListInstanceLimitOverrides(ctx context.Context) ([]InstanceLimitOverride, error) {
+	rows, err := s.getDB().ListInstanceLimitOverrides(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instance limit overrides: %w", err)
+	}
+
+	overrides := make([]InstanceLimitOverride, 0, len(rows))
+	for _, row := range rows {
+		overrides = append(overrides, InstanceLimitOverride{
+			InstanceID: row.InstanceID,
+			Subdomain:  row.Subdomain,
+			LimitOverride: LimitOverride{
+				RequestsPerSecond:   fromInt4(row.RequestsPerSecond),
+				Burst:               fromInt4(row.Burst),
+				IPRequestsPerSecond: fromInt4(row.IpRequestsPerSecond),
+				IPBurst:             fromInt4(row.IpBurst),
+				MaxBodyBytes:        fromInt8(row.MaxBodyBytes),
+				MaxConcurrent:       fromInt4(row.MaxConcurrent),
+			},
+		})
+	}
+	return overrides, nil
+}
+
+// AdminUpdatePlanLimits creates or replaces the proxy limits of a plan.
+// Every proxy replica drops its cached limits through the plan_limits trigger.
+func (s *Service) AdminUpdatePlanLimits(ctx context.Context, adminID string, limits ProxyLimits) error {
+	if err := s.requireAdmin(ctx, adminID); err != nil {
+		return err
+	}
+	if limits.Plan != PlanTrial && limits.Plan != PlanStandard {
+		return apperrs.Client(apperrs.CodeInvalidInput, fmt.Sprintf("unknown plan %q", limits.Plan))
+	}
+	if limits.RequestsPerSecond <= 0 || limits.Burst <= 0 || limits.IPRequestsPerSecond <= 0 || limits.IPBurst <= 0 {
+		return apperrs.Client(apperrs.CodeInvalidInput, "rates and bursts must be positive")
+	}
+	if limits.MaxBodyBytes < 0 || limits.MaxConcurrent < 0 {
+		return apperrs.Client(apperrs.CodeInvalidInput, "body size and concurrency limits cannot be negative")
+	}
+
+	queries := s.getDB()
+
+	_, err := queries.UpsertPlanLimits(ctx, db.UpsertPlanLimitsParams{
+		Plan:                limits.Plan,
+		RequestsPerSecond:   int32(limits.RequestsPerSecond),
+		Burst:               int32(limits.Burst),
+		IpRequestsPerSecond: int32(limits.IPRequestsPerSecond),
+		IpBurst:             int32(limits.IPBurst),
+		MaxBodyBytes:        limits.MaxBodyBytes,
+		MaxConcurrent:       int32(limits.MaxConcurrent),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update plan limits: %w", err)
+	}
+
+	s.recordAudit(ctx, auditEntry{
+		UserID:     adminID,
+		Action:     AuditActionPlanLimitsUpdate,
+		TargetType: "plan",
+		TargetID:   limits.Plan,
+		Metadata:   proxyLimitsMetadata(limits),
+	})
+	return nil
+}
+
+// AdminSetInstanceLimitOverride stores admin overrides for the proxy limits of the instance at subdomain
+func (s *Service) AdminSetInstanceLimitOverride(ctx context.Context, adminID, subdomain string, override LimitOverride) (*Instance, error) {
+	if err := s.requireAdmin(ctx, adminID); err != nil {
+		return nil, err
+	}
+	for _, v := range []*int{override.RequestsPerSecond, override.Burst, override.IPRequestsPerSecond, override.IPBurst} {
+		if v != nil && *v <= 0 {
+			return nil, apperrs.Client(apperrs.CodeInvalidInput, "rates and bursts must be positive")
+		}
+	}
+	if (override.MaxBodyBytes != nil && *override.MaxBodyBytes < 0) || (override.MaxConcurrent != nil && *override.MaxConcurrent < 0) {
+		return nil, apperrs.Client(apperrs.CodeInvalidInput, "body size and concurrency limits cannot be negative")
+	}
+
+	queries := s.getDB()
+
+	dbInstance, err := queries.GetInstanceBySubdomain(ctx, subdomain)
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			return nil, apperrs.Client(apperrs.CodeNotFound, "instance not found")
+		}
+		return nil, fmt.Errorf("failed to get instance: %w", err)
+	}
+
+	_, err = queries.UpsertInstanceLimitOverride(ctx, db.UpsertInstanceLimitOverrideParams{
+		InstanceID:          dbInstance.ID,
+		RequestsPerSecond:   toInt4(override.RequestsPerSecond),
+		Burst:               toInt4(override.Burst),
+		IpRequestsPerSecond: toInt4(override.IPRequestsPerSecond),
+		IpBurst:             toInt4(override.IPBurst),
+		MaxBodyBytes:        toInt8(override.MaxBodyBytes),
+		MaxConcurrent:       toInt4(override.MaxConcurrent),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set instance limit override: %w", err)
+	}
+
+	instance := toDomainInstance(dbInstance)
+	s.recordAudit(ctx, auditEntry{
+		OrganizationID: instance.OrganizationID,
+		UserID:         adminID,
+		Action:         AuditActionInstanceLimitsUpdate,
+		TargetType:     "instance",
+		TargetID:       instance.ID,
+		Metadata:       map[string]string{"subdomain": instance.Subdomain},
+	})
+	return &instance, nil
+}
+
+// AdminClearInstanceLimitOverride removes admin overrides so the plan limits apply again
+func (s *Service) AdminClearInstanceLimitOverride(ctx context.Context, adminID, instanceID string) error {
+	if err := s.requireAdmin(ctx, adminID); err != nil {
+		return err
+	}
+
+	queries := s.getDB()
+
+	dbInstance, err := queries.GetInstance(ctx, instanceID)
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			return apperrs.Client(apperrs.CodeNotFound, "instance not found")
+		}
+		return fmt.Errorf("failed to get instance: %w", err)
+	}
+
+	if err := queries.DeleteInstanceLimitOverride(ctx, instanceID); err != nil {
+		return fmt.Errorf("failed to clear instance limit override: %w", err)
+	}
+
+	s.recordAudit(ctx, auditEntry{
+		OrganizationID: dbInstance.OrganizationID,
+		UserID:         adminID,
+		Action:         AuditActionInstanceLimitsUpdate,
+		TargetType:     "instance",
+		TargetID:       instanceID,
+		Metadata:       map[string]string{"subdomain": dbInstance.Subdomain, "cleared": "true"},
+	})
+	return nil
+}
+
+func proxyLimitsMetadata(limits ProxyLimits) map[string]string {
+	return map[string]string{
+		"requests_per_second":    strconv.Itoa(limits.RequestsPerSecond),
+		"burst":                  strconv.Itoa(limits.Burst),
+		"ip_requests_per_second": strconv.Itoa(limits.IPRequestsPerSecond),
+		"ip_burst":               strconv.Itoa(limits.IPBurst),
+		"max_body_bytes":         strconv.FormatInt(limits.MaxBodyBytes, 10),
+		"max_concurrent":         strconv.Itoa(limits.MaxConcurrent),
+	}
+}
+
+func toInt4(v *int) pgtype.Int4 {
+	if v == nil {
+		return pgtype.Int4{}
+	}
+	return pgtype.Int4{Int32: int32(*v), Valid: true}
+}
+
+func toInt8(v *int64) pgtype.Int8 {
+	if v == nil {
+		return pgtype.Int8{}
+	}
+	return pgtype.Int8{Int64: *v, Valid: true}
+}
+
+func fromInt4(v pgtype.Int4) *int {
+	if !v.Valid {
+		return nil
+	}
+	n := int(v.Int32)
+	return &n
+}
+
+func fromInt8(v pgtype.Int8) *int64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Int64
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/aliuygur/n8n-saas-api/internal/emails"
+	"github.com/aliuygur/n8n-saas-api/internal/jobs"
+	"github.com/aliuygur/n8n-saas-api/internal/mailer"
+)
+
+// Kinds of notification emails, named <target>.<event>
+const (
+	NotificationInstanceReady     = "instance.ready"
+	NotificationInstanceDeleted   = "instance.deleted"
+	NotificationInstanceDown      = "instance.down"
+	NotificationInstanceRecovered = "instance.recovered"
+	NotificationTrialEnding       = "trial.ending"
+	NotificationTrialEnded        = "trial.ended"
+	NotificationPaymentFailed     = "payment.failed"
+)
+
+// Notification statuses
+const (
+	NotificationStatusPending = "pending"
+	NotificationStatusSent    = "sent"
+	NotificationStatusFailed  = "failed"
+)
+
+const (
+	// notificationMaxAttempts is how often sending an email is tried before it is marked failed
+	notificationMaxAttempts = 8
+	// maxUserNotifications limits how many sent emails an export contains
+	maxUserNotifications = 1000
+)
+
+// NotificationKind describes a kind of notification on the preferences card
+type NotificationKind struct {
+	Kind        string
+	Name        string
+	Description string
+	// Required notifications, such as failed payments, are always sent
+	Required bool
+}
+
+// NotificationKinds are the notifications users can get, in the order the account page lists them
+var NotificationKinds = []NotificationKind{
+	{Kind: NotificationInstanceReady, Name: "Instance ready", Description: "When a new instance finished starting"},
+	{Kind: NotificationInstanceDown, Name: "Instance down", Description: "When an instance stops answering health checks"},
+	{Kind: NotificationInstanceRecovered, Name: "Instance recovered", Description: "When an instance that was down answers again"},
+	{Kind: NotificationInstanceDeleted, Name: "Instance deleted", Description: "When instances are deleted because a trial or subscription ended"},
+	{Kind: NotificationTrialEnding, Name: "Trial ending", Description: "A day before the free trial ends"},
+	{Kind: NotificationTrialEnded, Name: "Trial ended", Description: "When the free trial ended, before instances are stopped"},
+	{Kind: NotificationPaymentFailed, Name: "Payment failed", Description: "When a subscription payment didn't go through", Required: true},
+}
+
+// NotificationPreference is whether a user gets a kind of notification
+type NotificationPreference struct {
+	NotificationKind
+	Enabled bool
+}
+
+// Notification is an email in the sent-message log
+type Notification struct {
+	ID        string
+	UserID    string
+	Kind      string
+	Recipient string
+	Subject   string
+	Status    string
+	Error     string
+	CreatedAt time.Time
+	SentAt    *time.Time
+}
+
+// sendNotificationArgs are the arguments of JobSendNotification
+type sendNotificationArgs struct {
+	NotificationID string `json:"notification_id"`
+}
+
+func notificationKind(kind string) (NotificationKind, bool) {
+	for _, k := range NotificationKinds {
+		if k.Kind == kind {
+			return k, true
+		}
+	}
+	return NotificationKind{}, false
+}
+
+// notify queues an email to a user unless they turned the kind of notification off.
+// It is rendered now and logged, then sent by a job that retries while the mailer fails.
+func (s *Service) notify(ctx context.Context, userID, kind string, email emails.Email) error {
+	l := appctx.GetLogger(ctx)
+
+	k, ok := notificationKind(kind)
+	if !ok {
+		return fmt.Errorf("unknown notification kind %s", kind)
+	}
+
+	queries, tx := s.getDBWithTx(ctx)
+	defer tx.Rollback(ctx)
+
+	user, err := queries.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.DeletedAt.Valid {
+		return nil
+	}
+
+	footer := emails.Footer{}
+	if !k.Required {
+		enabled, err := queries.GetNotificationPreference(ctx, db.GetNotificationPreferenceParams{UserID: userID, Kind: kind})
+		if err != nil && !db.IsNotFoundError(err) {
+			return fmt.Errorf("failed to get notification preference: %w", err)
+		}
+		if err == nil && !enabled {
+			l.Debug("notification turned off", "user_id", userID, "kind", kind)
+			return nil
+		}
+		footer.PreferencesURL = s.config.Server.BaseURL("/account#notifications")
+	}
+
+	rendered, err := emails.Render(ctx, email, footer)
+	if err != nil {
+		return err
+	}
+
+	notification, err := queries.CreateNotification(ctx, db.CreateNotificationParams{
+		UserID:    userID,
+		Kind:      kind,
+		Recipient: user.Email,
+		Subject:   rendered.Subject,
+		TextBody:  rendered.Text,
+		HtmlBody:  rendered.HTML,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create notification: %w", err)
+	}
+
+	if _, err := s.jobs.EnqueueTx(ctx, queries, JobSendNotification, sendNotificationArgs{NotificationID: notification.ID}, jobs.EnqueueOptions{
+		MaxAttempts: notificationMaxAttempts,
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	l.Info("queued notification", "notification_id", notification.ID, "user_id", userID, "kind", kind)
+	return nil
+}
+
+// notifyOrganizationOwners queues an email to every owner of an organization.
+// An owner the email can't be queued for doesn't keep it from the others.
+func (s *Service) notifyOrganizationOwners(ctx context.Context, organizationID, kind string, email emails.Email) error {
+	owners, err := s.getDB().ListOrganizationOwners(ctx, organizationID)
+	if err != nil {
+		return fmt.Errorf("failed to list organization owners: %w", err)
+	}
+	var errs []error
+	for _, owner := range owners {
+		if err := s.notify(ctx, owner.ID, kind, email); err != nil {
+			errs = append(errs, fmt.Errorf("failed to notify owner %s: %w", owner.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sendNotification is the JobSendNotification handler, it sends a queued email
+func (s *Service) sendNotification(ctx context.Context, job *jobs.Job) error {
+	var args sendNotificationArgs
+	if err := job.Bind(&args); err != nil {
+		return err
+	}
+
+	queries := s.getDB()
+	notification, err := queries.GetNotification(ctx, args.NotificationID)
+	if err != nil {
+		// The user deleted the account meanwhile
+		if db.IsNotFoundError(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get notification: %w", err)
+	}
+	if notification.Status != NotificationStatusPending {
+		return nil
+	}
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:             notification.Recipient,
+		Subject:        notification.Subject,
+		Text:           notification.TextBody,
+		HTML:           notification.HtmlBody,
+		IdempotencyKey: notification.ID,
+	})
+	if err != nil {
+		if job.Attempt >= job.MaxAttempts {
+			if markErr := queries.MarkNotificationFailed(ctx, db.MarkNotificationFailedParams{
+				ID:    notification.ID,
+				Error: truncate(err.Error(), 1000),
+			}); markErr != nil {
+				appctx.GetLogger(ctx).Error("failed to mark notification failed", "notification_id", notification.ID, "error", markErr)
+			}
+		}
+		return err
+	}
+
+	if err := queries.MarkNotificationSent(ctx, notification.ID); err != nil {
+		return fmt.Errorf("failed to mark notification sent: %w", err)
+	}
+	return nil
+}
+
+// ListNotificationPreferences returns whether the user gets each kind of notification
+func (s *Service) ListNotificationPreferences(ctx context.Context, userID string) ([]NotificationPreference, error) {
+	rows, err := s.getDB().ListNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, apperrs.Server("failed to list notification preferences", err)
+	}
+
+	enabled := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		enabled[row.Kind] = row.Enabled
+	}
+
+	prefs := make([]NotificationPreference, 0, len(NotificationKinds))
+	for _, k := range NotificationKinds {
+		on, ok := enabled[k.Kind]
+		prefs = append(prefs, NotificationPreference{
+			NotificationKind: k,
+			Enabled:          k.Required || !ok || on,
+		})
+	}
+	return prefs, nil
+}
+
+// UpdateNotificationPreferences turns on the optional notifications in enabled and turns off the others
+func (s *Service) UpdateNotificationPreferences(ctx context.Context, userID string, enabled []string) ([]NotificationPreference, error) {
+	on := make(map[string]bool, len(enabled))
+	for _, kind := range enabled {
+		if _, ok := notificationKind(kind); !ok {
+			return nil, apperrs.Client(apperrs.CodeInvalidInput, fmt.Sprintf("unknown notification %q", kind))
+		}
+		on[kind] = true
+	}
+
+	queries, tx := s.getDBWithTx(ctx)
+	defer tx.Rollback(ctx)
+
+	for _, k := range NotificationKinds {
+		if k.Required {
+			continue
+		}
+		if err := queries.UpsertNotificationPreference(ctx, db.UpsertNotificationPreferenceParams{
+			UserID:  userID,
+			Kind:    k.Kind,
+			Enabled: on[k.Kind],
+		}); err != nil {
+			return nil, apperrs.Server("failed to update notification preference", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, apperrs.Server("failed to commit transaction", err)
+	}
+
+	return s.ListNotificationPreferences(ctx, userID)
+}
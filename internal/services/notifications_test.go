@@ -0,0 +1,200 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/aliuygur/n8n-saas-api/internal/emails"
+	"github.com/aliuygur/n8n-saas-api/internal/jobs"
+	"github.com/aliuygur/n8n-saas-api/internal/testdb"
+)
+
+// listTestNotifications returns the notifications queued for a user, newest first
+func listTestNotifications(t *testing.T, s *Service, userID string) []db.Notification {
+	t.Helper()
+	rows, err := s.getDB().ListUserNotifications(testdb.Context(), db.ListUserNotificationsParams{UserID: userID, MaxRows: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := testdb.Context()
+	member := createTestUser(t, s, "prefs")
+
+	enabled := func(prefs []NotificationPreference) map[string]bool {
+		m := make(map[string]bool, len(prefs))
+		for _, p := range prefs {
+			m[p.Kind] = p.Enabled
+		}
+		return m
+	}
+
+	prefs, err := s.ListNotificationPreferences(ctx, member.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prefs) != len(NotificationKinds) {
+		t.Fatalf("Expected every kind, got %d", len(prefs))
+	}
+	for kind, on := range enabled(prefs) {
+		if !on {
+			t.Errorf("Expected %s to be on by default", kind)
+		}
+	}
+
+	prefs, err = s.UpdateNotificationPreferences(ctx, member.UserID, []string{NotificationInstanceReady})
+	if err != nil {
+		t.Fatalf("Failed to update preferences: %v", err)
+	}
+	got := enabled(prefs)
+	if !got[NotificationInstanceReady] || got[NotificationTrialEnding] || got[NotificationInstanceDown] {
+		t.Errorf("Expected only instance.ready to stay on, got %v", got)
+	}
+	if !got[NotificationPaymentFailed] {
+		t.Error("Expected the required payment.failed to stay on")
+	}
+
+	if _, err := s.UpdateNotificationPreferences(ctx, member.UserID, []string{"newsletter"}); !apperrs.CodeIs(err, apperrs.CodeInvalidInput) {
+		t.Errorf("Expected an unknown kind to be rejected, got %v", err)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := testdb.Context()
+	member := createTestUser(t, s, "notified")
+	queries := s.getDB()
+
+	if _, err := s.UpdateNotificationPreferences(ctx, member.UserID, nil); err != nil {
+		t.Fatal(err)
+	}
+	// Preferences of required kinds can't be stored through the account page, but are ignored anyway
+	if err := queries.UpsertNotificationPreference(ctx, db.UpsertNotificationPreferenceParams{UserID: member.UserID, Kind: NotificationPaymentFailed, Enabled: false}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.notify(ctx, member.UserID, NotificationTrialEnding, emails.TrialEnding{EndsAt: time.Now(), SubscribeURL: "https://app.ranx.test/subscription"}); err != nil {
+		t.Fatalf("Failed to notify: %v", err)
+	}
+	if n := listTestNotifications(t, s, member.UserID); len(n) != 0 {
+		t.Fatalf("Expected a notification turned off to be skipped, got %d", len(n))
+	}
+
+	if err := s.notify(ctx, member.UserID, NotificationPaymentFailed, emails.PaymentFailed{OrganizationName: "Acme", BillingURL: "https://app.ranx.test/subscription"}); err != nil {
+		t.Fatalf("Failed to notify: %v", err)
+	}
+	n := listTestNotifications(t, s, member.UserID)
+	if len(n) != 1 || n[0].Kind != NotificationPaymentFailed || n[0].Recipient != "notified@example.com" || n[0].Status != NotificationStatusPending {
+		t.Fatalf("Expected the required notification to be queued, got %+v", n)
+	}
+
+	if err := s.notify(ctx, member.UserID, "newsletter", emails.PaymentFailed{}); err == nil {
+		t.Error("Expected an unknown kind to be rejected")
+	}
+}
+
+func TestNotifyOrganizationOwners(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := testdb.Context()
+	owner := createTestUser(t, s, "owner")
+	other := createTestUser(t, s, "other")
+	viewer := createTestUser(t, s, "viewer")
+	queries := s.getDB()
+
+	org, err := s.CreateOrganization(ctx, owner.UserID, "Acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []db.CreateOrganizationMemberParams{
+		{OrganizationID: org.ID, UserID: other.UserID, Role: RoleOwner},
+		{OrganizationID: org.ID, UserID: viewer.UserID, Role: RoleViewer},
+	} {
+		if err := queries.CreateOrganizationMember(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.notifyInstancesDeleted(ctx, org.ID, nil, "your subscription ended")
+	if n := listTestNotifications(t, s, owner.UserID); len(n) != 0 {
+		t.Fatalf("Expected nothing to be sent without deleted instances, got %d", len(n))
+	}
+
+	s.notifyInstancesDeleted(ctx, org.ID, []string{"acme"}, "your subscription ended")
+	for _, userID := range []string{owner.UserID, other.UserID} {
+		n := listTestNotifications(t, s, userID)
+		if len(n) != 1 || n[0].Kind != NotificationInstanceDeleted {
+			t.Errorf("Expected the owner to be told about the deleted instance, got %+v", n)
+		}
+	}
+	if n := listTestNotifications(t, s, viewer.UserID); len(n) != 0 {
+		t.Errorf("Expected viewers not to be notified, got %d", len(n))
+	}
+}
+
+func TestSendNotification(t *testing.T) {
+	tests := []struct {
+		name       string
+		mailErr    error
+		attempt    int32
+		wantErr    bool
+		wantStatus string
+		wantSent   int
+	}{
+		{name: "sent", attempt: 1, wantStatus: NotificationStatusSent, wantSent: 1},
+		{name: "mailer failing is retried", mailErr: errors.New("mailer unavailable"), attempt: 1, wantErr: true, wantStatus: NotificationStatusPending},
+		{name: "last attempt marks it failed", mailErr: errors.New("mailer unavailable"), attempt: notificationMaxAttempts, wantErr: true, wantStatus: NotificationStatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mail := newTestService(t)
+			ctx := testdb.Context()
+			member := createTestUser(t, s, "mailed")
+
+			if err := s.notify(ctx, member.UserID, NotificationPaymentFailed, emails.PaymentFailed{OrganizationName: "Acme", BillingURL: "https://app.ranx.test/subscription"}); err != nil {
+				t.Fatal(err)
+			}
+			notification := listTestNotifications(t, s, member.UserID)[0]
+
+			mail.err = tt.mailErr
+			args, _ := json.Marshal(sendNotificationArgs{NotificationID: notification.ID})
+			job := &jobs.Job{Kind: JobSendNotification, Args: args, Attempt: tt.attempt, MaxAttempts: notificationMaxAttempts}
+			if err := s.sendNotification(ctx, job); (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+
+			got, err := s.getDB().GetNotification(ctx, notification.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("Expected status %s, got %s", tt.wantStatus, got.Status)
+			}
+			if tt.wantStatus == NotificationStatusFailed && got.Error != tt.mailErr.Error() {
+				t.Errorf("Expected the error to be kept, got %q", got.Error)
+			}
+			sent := mail.messages()
+			if len(sent) != tt.wantSent {
+				t.Fatalf("Expected %d messages, got %d", tt.wantSent, len(sent))
+			}
+			if tt.wantSent > 0 && (sent[0].To != "mailed@example.com" || sent[0].IdempotencyKey != notification.ID) {
+				t.Errorf("Unexpected message %+v", sent[0])
+			}
+
+			// A notification that isn't pending anymore is not sent again
+			mail.err = nil
+			if tt.wantStatus != NotificationStatusPending {
+				if err := s.sendNotification(ctx, job); err != nil || len(mail.messages()) != tt.wantSent {
+					t.Errorf("Expected the job to skip the notification, got %v", err)
+				}
+			}
+		})
+	}
+}
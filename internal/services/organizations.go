@@ -0,0 +1,665 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/aliuygur/n8n-saas-api/internal/mailer"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Organization roles, from most to least privileged
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleViewer = "viewer"
+)
+
+// OrganizationRoles lists every role a member can have
+var OrganizationRoles = []string{RoleOwner, RoleAdmin, RoleViewer}
+
+var roleRanks = map[string]int{
+	RoleViewer: 1,
+	RoleAdmin:  2,
+	RoleOwner:  3,
+}
+
+const (
+	// organizationInvitationTTL defines how long an emailed invitation can be accepted
+	organizationInvitationTTL = 7 * 24 * time.Hour
+	// maxOrganizationNameLength limits organization names
+	maxOrganizationNameLength = 100
+)
+
+// Membership is the organization a request acts in and the caller's role there
+type Membership struct {
+	OrganizationID string
+	UserID         string
+	Role           string
+}
+
+// Can reports whether the member has at least the given role
+func (m Membership) Can(role string) bool {
+	return roleRanks[m.Role] >= roleRanks[role]
+}
+
+// Require returns a forbidden error unless the member has at least the given role
+func (m Membership) Require(role string) error {
+	if !m.Can(role) {
+		return apperrs.Client(apperrs.CodeForbidden, fmt.Sprintf("this action requires the %s role", role))
+	}
+	return nil
+}
+
+// Organization is an organization the user belongs to
+type Organization struct {
+	ID               string
+	Name             string
+	Personal         bool // Every user has one personal organization that can't be shared
+	Role             string
+	RequireTwoFactor bool // Members must turn on two-factor authentication to act in the organization
+	CreatedAt        time.Time
+}
+
+// OrganizationMember is a user that belongs to an organization
+type OrganizationMember struct {
+	UserID           string
+	Email            string
+	Name             string
+	Role             string
+	TwoFactorEnabled bool
+	CreatedAt        time.Time
+}
+
+// OrganizationInvitation is a pending invitation to join an organization
+type OrganizationInvitation struct {
+	ID               string
+	OrganizationID   string
+	OrganizationName string
+	Email            string
+	Role             string
+	CreatedAt        time.Time
+	ExpiresAt        time.Time
+}
+
+// createOrganization creates an organization owned by the user, with a trial subscription
+func createOrganization(ctx context.Context, queries *db.Queries, ownerID, name string, personal bool) (db.Organization, error) {
+	org, err := queries.CreateOrganization(ctx, db.CreateOrganizationParams{
+		Name:     name,
+		Personal: personal,
+	})
+	if err != nil {
+		return db.Organization{}, fmt.Errorf("failed to create organization: %w", err)
+	}
+
+	err = queries.CreateOrganizationMember(ctx, db.CreateOrganizationMemberParams{
+		OrganizationID: org.ID,
+		UserID:         ownerID,
+		Role:           RoleOwner,
+	})
+	if err != nil {
+		return db.Organization{}, fmt.Errorf("failed to add organization owner: %w", err)
+	}
+
+	// Trial starts when the first instance is created
+	_, err = queries.CreateSubscription(ctx, db.CreateSubscriptionParams{
+		UserID:         ownerID,
+		OrganizationID: org.ID,
+		Status:         SubscriptionStatusTrial,
+		Quantity:       1,
+	})
+	if err != nil {
+		return db.Organization{}, fmt.Errorf("failed to create trial subscription: %w", err)
+	}
+
+	return org, nil
+}
+
+// personalMembership returns the membership of the user's personal organization
+// and makes it their current organization
+func personalMembership(ctx context.Context, queries *db.Queries, userID string) (Membership, error) {
+	org, err := queries.GetPersonalOrganization(ctx, userID)
+	if err != nil {
+		return Membership{}, fmt.Errorf("failed to get personal organization: %w", err)
+	}
+	err = queries.SetUserCurrentOrganization(ctx, db.SetUserCurrentOrganizationParams{
+		ID:                    userID,
+		CurrentOrganizationID: &org.ID,
+	})
+	if err != nil {
+		return Membership{}, fmt.Errorf("failed to set current organization: %w", err)
+	}
+	return Membership{OrganizationID: org.ID, UserID: userID, Role: RoleOwner}, nil
+}
+
+// ListUserOrganizations returns the organizations of a user, personal one first
+func (s *Service) ListUserOrganizations(ctx context.Context, userID string) ([]Organization, error) {
+	queries := s.getDB()
+
+	rows, err := queries.ListUserOrganizations(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+
+	orgs := make([]Organization, 0, len(rows))
+	for _, row := range rows {
+		orgs = append(orgs, Organization{
+			ID:               row.ID,
+			Name:             row.Name,
+			Personal:         row.Personal,
+			Role:             row.Role,
+			RequireTwoFactor: row.RequireTwoFactor,
+			CreatedAt:        row.CreatedAt.Time,
+		})
+	}
+	return orgs, nil
+}
+
+// GetOrganization returns an organization of the member
+func (s *Service) GetOrganization(ctx context.Context, member Membership) (*Organization, error) {
+	queries := s.getDB()
+
+	org, err := queries.GetOrganization(ctx, member.OrganizationID)
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			return nil, apperrs.Client(apperrs.CodeNotFound, "organization not found")
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	return &Organization{
+		ID:               org.ID,
+		Name:             org.Name,
+		Personal:         org.Personal,
+		Role:             member.Role,
+		RequireTwoFactor: org.RequireTwoFactor,
+		CreatedAt:        org.CreatedAt.Time,
+	}, nil
+}
+
+// CreateOrganization creates a shared organization owned by the user and switches to it
+func (s *Service) CreateOrganization(ctx context.Context, userID, name string) (*Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxOrganizationNameLength {
+		return nil, apperrs.Client(apperrs.CodeInvalidInput, fmt.Sprintf("organization name must be between 1 and %d characters", maxOrganizationNameLength))
+	}
+
+	queries, tx := s.getDBWithTx(ctx)
+	defer tx.Rollback(ctx)
+
+	org, err := createOrganization(ctx, queries, userID, name, false)
+	if err != nil {
+		return nil, err
+	}
+
+	err = queries.SetUserCurrentOrganization(ctx, db.SetUserCurrentOrganizationParams{
+		ID:                    userID,
+		CurrentOrganizationID: &org.ID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set current organization: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, apperrs.Server("failed to commit transaction", err)
+	}
+
+	return &Organization{
+		ID:        org.ID,
+		Name:      org.Name,
+		Personal:  org.Personal,
+		Role:      RoleOwner,
+		CreatedAt: org.CreatedAt.Time,
+	}, nil
+}
+
+// SwitchOrganization makes an organization of the user the one their sessions act in
+func (s *Service) SwitchOrganization(ctx context.Context, userID, organizationID string) error {
+	queries := s.getDB()
+
+	if _, err := queries.GetOrganizationMember(ctx, db.GetOrganizationMemberParams{
+		OrganizationID: organizationID,
+		UserID:         userID,
+	}); err != nil {
+		if db.IsNotFoundError(err) {
+			return apperrs.Client(apperrs.CodeNotFound, "organization not found")
+		}
+		return fmt.Errorf("failed to get organization membership: %w", err)
+	}
+
+	err := queries.SetUserCurrentOrganization(ctx, db.SetUserCurrentOrganizationParams{
+		ID:                    userID,
+		CurrentOrganizationID: &organizationID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set current organization: %w", err)
+	}
+	return nil
+}
+
+// ListOrganizationMembers returns the members of the member's organization, oldest first
+func (s *Service) ListOrganizationMembers(ctx context.Context, member Membership) ([]OrganizationMember, error) {
+	queries := s.getDB()
+
+	rows, err := queries.ListOrganizationMembers(ctx, member.OrganizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization members: %w", err)
+	}
+
+	members := make([]OrganizationMember, 0, len(rows))
+	for _, row := range rows {
+		members = append(members, OrganizationMember{
+			UserID:           row.UserID,
+			Email:            row.Email,
+			Name:             row.Name,
+			Role:             row.Role,
+			TwoFactorEnabled: row.TwoFactorEnabled,
+			CreatedAt:        row.CreatedAt.Time,
+		})
+	}
+	return members, nil
+}
+
+// UpdateOrganizationMemberRole changes the role of a member. Only owners can change roles,
+// and the last owner can't be demoted.
+func (s *Service) UpdateOrganizationMemberRole(ctx context.Context, member Membership, userID, role string) error {
+	if err := member.Require(RoleOwner); err != nil {
+		return err
+	}
+	if _, ok := roleRanks[role]; !ok {
+		return apperrs.Client(apperrs.CodeInvalidInput, "invalid role")
+	}
+
+	queries, tx := s.getDBWithTx(ctx)
+	defer tx.Rollback(ctx)
+
+	if err := lockOrganizationMembers(ctx, queries, member.OrganizationID); err != nil {
+		return err
+	}
+
+	rows, err := queries.UpdateOrganizationMemberRole(ctx, db.UpdateOrganizationMemberRoleParams{
+		OrganizationID: member.OrganizationID,
+		UserID:         userID,
+		Role:           role,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update member role: %w", err)
+	}
+	if rows == 0 {
+		return apperrs.Client(apperrs.CodeNotFound, "member not found")
+	}
+
+	if err := requireOrganizationOwner(ctx, queries, member.OrganizationID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return apperrs.Server("failed to commit transaction", err)
+	}
+	return nil
+}
+
+// RemoveOrganizationMember removes a member from an organization. Owners can remove anyone
+// and every member can leave, except the last owner and the owner of a personal organization.
+func (s *Service) RemoveOrganizationMember(ctx context.Context, member Membership, userID string) error {
+	if userID != member.UserID {
+		if err := member.Require(RoleOwner); err != nil {
+			return err
+		}
+	}
+
+	queries, tx := s.getDBWithTx(ctx)
+	defer tx.Rollback(ctx)
+
+	org, err := queries.GetOrganization(ctx, member.OrganizationID)
+	if err != nil {
+		return fmt.Errorf("failed to get organization: %w", err)
+	}
+	if org.Personal {
+		return apperrs.Client(apperrs.CodeForbidden, "you can't leave your personal organization")
+	}
+
+	if err := lockOrganizationMembers(ctx, queries, member.OrganizationID); err != nil {
+		return err
+	}
+
+	rows, err := queries.DeleteOrganizationMember(ctx, db.DeleteOrganizationMemberParams{
+		OrganizationID: member.OrganizationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+	if rows == 0 {
+		return apperrs.Client(apperrs.CodeNotFound, "member not found")
+	}
+
+	if err := requireOrganizationOwner(ctx, queries, member.OrganizationID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return apperrs.Server("failed to commit transaction", err)
+	}
+	return nil
+}
+
+// lockOrganizationMembers locks the members of an organization, so two owners
+// can't demote each other at the same time and leave the organization without one
+func lockOrganizationMembers(ctx context.Context, queries *db.Queries, organizationID string) error {
+	if err := queries.LockOrganizationMembers(ctx, organizationID); err != nil {
+		return fmt.Errorf("failed to lock organization: %w", err)
+	}
+	return nil
+}
+
+// requireOrganizationOwner fails when a change left the organization without an owner
+func requireOrganizationOwner(ctx context.Context, queries *db.Queries, organizationID string) error {
+	owners, err := queries.CountOrganizationOwners(ctx, organizationID)
+	if err != nil {
+		return fmt.Errorf("failed to count organization owners: %w", err)
+	}
+	if owners == 0 {
+		return apperrs.Client(apperrs.CodeConflict, "an organization needs at least one owner")
+	}
+	return nil
+}
+
+type InviteOrganizationMemberParams struct {
+	Member Membership
+	Email  string
+	Role   string
+}
+
+// InviteOrganizationMember emails an invitation to join the member's organization.
+// Admins can invite admins and viewers, only owners can invite owners.
+func (s *Service) InviteOrganizationMember(ctx context.Context, params InviteOrganizationMemberParams) (*OrganizationInvitation, error) {
+	if err := params.Member.Require(RoleAdmin); err != nil {
+		return nil, err
+	}
+	if _, ok := roleRanks[params.Role]; !ok {
+		return nil, apperrs.Client(apperrs.CodeInvalidInput, "invalid role")
+	}
+	if err := params.Member.Require(params.Role); err != nil {
+		return nil, err
+	}
+	addr, err := mail.ParseAddress(params.Email)
+	if err != nil {
+		return nil, apperrs.Client(apperrs.CodeInvalidInput, "invalid email address")
+	}
+	email := normalizeEmail(addr.Address)
+
+	queries := s.getDB()
+
+	org, err := queries.GetOrganization(ctx, params.Member.OrganizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	if org.Personal {
+		return nil, apperrs.Client(apperrs.CodeForbidden, "create an organization to invite teammates")
+	}
+
+	members, err := queries.ListOrganizationMembers(ctx, org.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization members: %w", err)
+	}
+	for _, m := range members {
+		if normalizeEmail(m.Email) == email {
+			return nil, apperrs.Client(apperrs.CodeConflict, "this user is already a member")
+		}
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, apperrs.Server("failed to generate invitation token", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	invitation, err := queries.CreateOrganizationInvitation(ctx, db.CreateOrganizationInvitationParams{
+		OrganizationID: org.ID,
+		Email:          email,
+		Role:           params.Role,
+		TokenHash:      hashInvitationToken(token),
+		InvitedBy:      &params.Member.UserID,
+		ExpiresAt:      pgtype.Timestamp{Time: time.Now().Add(organizationInvitationTTL), Valid: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invitation: %w", err)
+	}
+
+	link := s.config.Server.BaseURL("/invitations/" + url.PathEscape(token))
+	days := int(organizationInvitationTTL.Hours() / 24)
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: fmt.Sprintf("You're invited to %s on ranx.cloud", org.Name),
+		Text: fmt.Sprintf("You've been invited to join %s on ranx.cloud as %s:\n\n%s\n\n"+
+			"The invitation expires in %d days. If you weren't expecting it, you can ignore this email.\n",
+			org.Name, params.Role, link, days),
+		HTML: fmt.Sprintf(`<p>You've been invited to join <strong>%s</strong> on ranx.cloud as %s.</p><p><a href="%s">Accept the invitation</a></p>`+
+			`<p>The invitation expires in %d days. If you weren't expecting it, you can ignore this email.</p>`,
+			html.EscapeString(org.Name), params.Role, link, days),
+	})
+	if err != nil {
+		return nil, apperrs.Server("failed to send invitation", err)
+	}
+
+	return toDomainInvitation(invitation, org.Name), nil
+}
+
+// ListOrganizationInvitations returns the pending invitations of the member's organization
+func (s *Service) ListOrganizationInvitations(ctx context.Context, member Membership) ([]OrganizationInvitation, error) {
+	if err := member.Require(RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	queries := s.getDB()
+
+	rows, err := queries.ListOrganizationInvitations(ctx, member.OrganizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invitations: %w", err)
+	}
+
+	invitations := make([]OrganizationInvitation, 0, len(rows))
+	for _, row := range rows {
+		invitations = append(invitations, *toDomainInvitation(row, ""))
+	}
+	return invitations, nil
+}
+
+// RevokeOrganizationInvitation deletes a pending invitation of the member's organization
+func (s *Service) RevokeOrganizationInvitation(ctx context.Context, member Membership, invitationID string) error {
+	if err := member.Require(RoleAdmin); err != nil {
+		return err
+	}
+
+	queries := s.getDB()
+
+	rows, err := queries.DeleteOrganizationInvitation(ctx, db.DeleteOrganizationInvitationParams{
+		ID:             invitationID,
+		OrganizationID: member.OrganizationID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke invitation: %w", err)
+	}
+	if rows == 0 {
+		return apperrs.Client(apperrs.CodeNotFound, "invitation not found")
+	}
+	return nil
+}
+
+// GetOrganizationInvitation returns the pending invitation behind an emailed token
+func (s *Service) GetOrganizationInvitation(ctx context.Context, token string) (*OrganizationInvitation, error) {
+	queries := s.getDB()
+
+	row, err := queries.GetActiveOrganizationInvitationByTokenHash(ctx, hashInvitationToken(token))
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			return nil, apperrs.Client(apperrs.CodeNotFound, "invitation is invalid or has expired")
+		}
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+
+	return toDomainInvitation(db.OrganizationInvitation{
+		ID:             row.ID,
+		OrganizationID: row.OrganizationID,
+		Email:          row.Email,
+		Role:           row.Role,
+		CreatedAt:      row.CreatedAt,
+		ExpiresAt:      row.ExpiresAt,
+	}, row.OrganizationName), nil
+}
+
+// AcceptOrganizationInvitation adds the user to the organization they were invited to
+// and switches to it. The invitation must have been sent to the user's email address.
+func (s *Service) AcceptOrganizationInvitation(ctx context.Context, userID, email, token string) (*OrganizationInvitation, error) {
+	invitation, err := s.GetOrganizationInvitation(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if normalizeEmail(email) != invitation.Email {
+		return nil, apperrs.Client(apperrs.CodeForbidden, fmt.Sprintf("this invitation was sent to %s, log in with that address to accept it", invitation.Email))
+	}
+
+	queries, tx := s.getDBWithTx(ctx)
+	defer tx.Rollback(ctx)
+
+	err = queries.CreateOrganizationMember(ctx, db.CreateOrganizationMemberParams{
+		OrganizationID: invitation.OrganizationID,
+		UserID:         userID,
+		Role:           invitation.Role,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add organization member: %w", err)
+	}
+
+	if _, err := queries.DeleteOrganizationInvitation(ctx, db.DeleteOrganizationInvitationParams{
+		ID:             invitation.ID,
+		OrganizationID: invitation.OrganizationID,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to delete invitation: %w", err)
+	}
+
+	err = queries.SetUserCurrentOrganization(ctx, db.SetUserCurrentOrganizationParams{
+		ID:                    userID,
+		CurrentOrganizationID: &invitation.OrganizationID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set current organization: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, apperrs.Server("failed to commit transaction", err)
+	}
+	return invitation, nil
+}
+
+// TransferInstance moves an instance to another organization of the member.
+// It requires the owner role in the current organization and admin in the target.
+func (s *Service) TransferInstance(ctx context.Context, member Membership, instanceID, targetOrganizationID string) (*Instance, error) {
+	if err := member.Require(RoleOwner); err != nil {
+		return nil, err
+	}
+	if targetOrganizationID == member.OrganizationID {
+		return nil, apperrs.Client(apperrs.CodeInvalidInput, "the instance already belongs to this organization")
+	}
+	instance, err := s.GetMemberInstance(ctx, member, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	queries, tx := s.getDBWithTx(ctx)
+	defer tx.Rollback(ctx)
+
+	target, err := queries.GetOrganizationMember(ctx, db.GetOrganizationMemberParams{
+		OrganizationID: targetOrganizationID,
+		UserID:         member.UserID,
+	})
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			return nil, apperrs.Client(apperrs.CodeNotFound, "organization not found")
+		}
+		return nil, fmt.Errorf("failed to get organization membership: %w", err)
+	}
+	if err := (Membership{OrganizationID: target.OrganizationID, UserID: target.UserID, Role: target.Role}).Require(RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	sub, err := queries.GetSubscriptionByOrganizationID(ctx, targetOrganizationID)
+	if err != nil {
+		return nil, apperrs.Server("failed to get subscription for organization", err)
+	}
+	if sub.Status == SubscriptionStatusTrial {
+		count, err := queries.CountActiveInstancesByOrganizationID(ctx, targetOrganizationID)
+		if err != nil {
+			return nil, apperrs.Server("failed to count instances", err)
+		}
+		if count > 0 {
+			return nil, apperrs.Client(apperrs.CodeForbidden, "trial organizations can only have one instance")
+		}
+	}
+
+	dbInst, err := queries.UpdateInstanceOrganization(ctx, db.UpdateInstanceOrganizationParams{
+		ID:             instance.ID,
+		OrganizationID: targetOrganizationID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to transfer instance: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, apperrs.Server("failed to commit transaction", err)
+	}
+
+	// Both organizations are billed per instance
+	for _, orgID := range []string{member.OrganizationID, targetOrganizationID} {
+		s.enqueueQuantitySync(ctx, orgID)
+	}
+
+	transferred := toDomainInstance(dbInst)
+
+	// Both organizations see the transfer in their audit log
+	for _, orgID := range []string{member.OrganizationID, targetOrganizationID} {
+		s.recordAudit(ctx, auditEntry{
+			OrganizationID: orgID,
+			UserID:         member.UserID,
+			Action:         AuditActionInstanceTransfer,
+			TargetType:     "instance",
+			TargetID:       transferred.ID,
+			Metadata: map[string]string{
+				"subdomain": transferred.Subdomain,
+				"from":      member.OrganizationID,
+				"to":        targetOrganizationID,
+			},
+		})
+	}
+
+	return &transferred, nil
+}
+
+func toDomainInvitation(inv db.OrganizationInvitation, organizationName string) *OrganizationInvitation {
+	return &OrganizationInvitation{
+		ID:               inv.ID,
+		OrganizationID:   inv.OrganizationID,
+		OrganizationName: organizationName,
+		Email:            inv.Email,
+		Role:             inv.Role,
+		CreatedAt:        inv.CreatedAt.Time,
+		ExpiresAt:        inv.ExpiresAt.Time,
+	}
+}
+
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,6 +2,8 @@ package services
 
 import (
 	"github.com/aliuygur/n8n-saas-api/internal/config"
+	"github.com/aliuygur/n8n-saas-api/internal/jobs"
+	"github.com/aliuygur/n8n-saas-api/internal/mailer"
 	"github.com/aliuygur/n8n-saas-api/internal/provisioning"
 	"github.com/aliuygur/n8n-saas-api/pkg/lemonsqueezy"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -11,6 +13,8 @@ type Service struct {
 	pool         *pgxpool.Pool
 	gke          *provisioning.Client
 	lemonsqueezy *lemonsqueezy.Client
+	mailer       mailer.Mailer
+	jobs         *jobs.Queue
 	config       *config.Config
 }
 
@@ -25,10 +29,31 @@ func NewService(pool *pgxpool.Pool, config *config.Config) (*Service, error) {
 		WebhookSecret: config.LemonSqueezy.WebhookSecret,
 	})
 
-	return &Service{
+	m, err := mailer.New(mailer.Config{
+		Driver:       config.Mailer.Driver,
+		From:         config.Mailer.From,
+		SMTPHost:     config.Mailer.SMTPHost,
+		SMTPPort:     config.Mailer.SMTPPort,
+		SMTPUsername: config.Mailer.SMTPUsername,
+		SMTPPassword: config.Mailer.SMTPPassword,
+		APIKey:       config.Mailer.APIKey,
+		FileDir:      config.Mailer.FileDir,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Service{
 		pool:         pool,
 		gke:          gke,
 		lemonsqueezy: lsClient,
+		mailer:       m,
+		jobs:         jobs.New(pool, jobs.Options{}),
 		config:       config,
-	}, nil
+	}
+	if err := s.registerJobs(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
 }
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aliuygur/n8n-saas-api/internal/config"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/aliuygur/n8n-saas-api/internal/jobs"
+	"github.com/aliuygur/n8n-saas-api/internal/mailer"
+	"github.com/aliuygur/n8n-saas-api/internal/testdb"
+)
+
+// fakeMailer records the messages sent by the service, failing them while err is set
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+	err  error
+}
+
+func (m *fakeMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func (m *fakeMailer) messages() []mailer.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.sent)
+}
+
+// testConfig is the configuration of services built by tests
+func testConfig() *config.Config {
+	return &config.Config{
+		Server:  config.ServerConfig{APIBaseURL: "https://app.ranx.test"},
+		N8N:     config.N8NConfig{OwnerSecret: "test-owner-secret"},
+		Routing: config.RoutingConfig{Mode: config.RoutingModeProxy, TenantDomain: "ranx.test"},
+		Trial:   config.TrialConfig{ExpiryAction: config.TrialExpiryHibernate},
+	}
+}
+
+// newTestService returns a service on a database of its own, see testdb.New.
+// Kubernetes and billing clients are left out, tests must not reach them.
+func newTestService(t *testing.T) (*Service, *fakeMailer) {
+	t.Helper()
+	pool := testdb.New(t)
+
+	m := &fakeMailer{}
+	s := &Service{
+		pool:   pool,
+		mailer: m,
+		jobs:   jobs.New(pool, jobs.Options{}),
+		config: testConfig(),
+	}
+	if err := s.registerJobs(); err != nil {
+		t.Fatalf("Failed to register jobs: %v", err)
+	}
+	return s, m
+}
+
+// createTestUser creates a user with a personal organization on a trial and returns
+// the membership of the user in it
+func createTestUser(t *testing.T, s *Service, name string) Membership {
+	t.Helper()
+	ctx := testdb.Context()
+
+	user, err := s.GetOrCreateUser(ctx, CreateUserParams{Email: name + "@example.com", Name: name})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	dbUser, err := s.getDB().GetUserByID(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Membership{UserID: user.ID, OrganizationID: *dbUser.CurrentOrganizationID, Role: RoleOwner}
+}
+
+// createTestInstance adds a deployed instance to the organization of the member
+// without provisioning anything
+func createTestInstance(t *testing.T, s *Service, member Membership, subdomain string) *Instance {
+	t.Helper()
+
+	dbInst, err := s.getDB().CreateInstance(testdb.Context(), db.CreateInstanceParams{
+		UserID:         member.UserID,
+		OrganizationID: member.OrganizationID,
+		Namespace:      fmt.Sprintf("n8n-%s", strings.ToLower(subdomain)),
+		Subdomain:      subdomain,
+		Status:         InstanceStatusDeployed,
+		AppVersion:     N8NVersion,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create instance: %v", err)
+	}
+	instance := toDomainInstance(dbInst)
+	return &instance
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// SessionIdleTTL defines how long a session survives without being used
+	SessionIdleTTL = 7 * 24 * time.Hour
+	// SessionAbsoluteTTL caps the lifetime of a session regardless of activity
+	SessionAbsoluteTTL = 30 * 24 * time.Hour
+	// sessionTouchInterval limits how often a used session is written back to extend its expiry
+	sessionTouchInterval = 5 * time.Minute
+)
+
+// Session is a logged in browser
+type Session struct {
+	ID         string
+	UserID     string
+	UserEmail  string
+	Membership Membership // The organization the session acts in
+	UserAgent  string
+	IPAddress  string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	// TwoFactorRequired is set when the organization requires two-factor authentication the user hasn't turned on
+	TwoFactorRequired bool
+	IsAdmin           bool // The user operates ranx and may use the admin console
+}
+
+type CreateSessionParams struct {
+	UserID    string
+	UserAgent string
+	IPAddress string
+}
+
+// CreateSession starts a session and returns the token for the session cookie.
+// Only a hash of the token is stored.
+func (s *Service) CreateSession(ctx context.Context, params CreateSessionParams) (string, *Session, error) {
+	queries := s.getDB()
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, apperrs.Server("failed to generate session token", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	// Sessions are only looked up by token, so expired ones are pruned whenever the user logs in again
+	if err := queries.DeleteExpiredUserSessions(ctx, params.UserID); err != nil {
+		return "", nil, fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+
+	now := time.Now()
+	dbSession, err := queries.CreateSession(ctx, db.CreateSessionParams{
+		UserID:            params.UserID,
+		TokenHash:         hashSessionToken(token),
+		UserAgent:         truncate(params.UserAgent, 512),
+		IpAddress:         params.IPAddress,
+		ExpiresAt:         pgtype.Timestamp{Time: now.Add(SessionIdleTTL), Valid: true},
+		AbsoluteExpiresAt: pgtype.Timestamp{Time: now.Add(SessionAbsoluteTTL), Valid: true},
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	// The login goes to the log of the organization the session starts in
+	member, err := sessionMembership(ctx, queries, params.UserID)
+	if err != nil {
+		return "", nil, err
+	}
+	s.recordAudit(ctx, auditEntry{
+		OrganizationID: member.OrganizationID,
+		UserID:         params.UserID,
+		Action:         AuditActionUserLogin,
+		TargetType:     "user",
+		TargetID:       params.UserID,
+	})
+
+	return token, toDomainSession(dbSession), nil
+}
+
+// sessionMembership returns the membership a new session of the user acts in
+func sessionMembership(ctx context.Context, queries *db.Queries, userID string) (Membership, error) {
+	row, err := queries.GetUserMembership(ctx, userID)
+	if err != nil {
+		return Membership{}, fmt.Errorf("failed to get user: %w", err)
+	}
+	if row.CurrentOrganizationID != nil && row.OrganizationRole.Valid {
+		return Membership{OrganizationID: *row.CurrentOrganizationID, UserID: userID, Role: row.OrganizationRole.String}, nil
+	}
+	return personalMembership(ctx, queries, userID)
+}
+
+// ValidateSession returns the active session for a cookie token and extends its expiry.
+// Sessions act in the user's current organization, or their personal one once they lose access to it.
+func (s *Service) ValidateSession(ctx context.Context, token string) (*Session, error) {
+	queries := s.getDB()
+
+	row, err := queries.GetActiveSessionByTokenHash(ctx, hashSessionToken(token))
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			return nil, apperrs.Client(apperrs.CodeUnauthorized, "session is invalid or has expired")
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	session := toDomainSession(db.Session{
+		ID:                row.ID,
+		UserID:            row.UserID,
+		TokenHash:         row.TokenHash,
+		UserAgent:         row.UserAgent,
+		IpAddress:         row.IpAddress,
+		CreatedAt:         row.CreatedAt,
+		LastSeenAt:        row.LastSeenAt,
+		ExpiresAt:         row.ExpiresAt,
+		AbsoluteExpiresAt: row.AbsoluteExpiresAt,
+	})
+	session.UserEmail = row.UserEmail
+	session.IsAdmin = row.UserIsAdmin
+
+	if row.CurrentOrganizationID != nil && row.OrganizationRole.Valid {
+		session.Membership = Membership{
+			OrganizationID: *row.CurrentOrganizationID,
+			UserID:         row.UserID,
+			Role:           row.OrganizationRole.String,
+		}
+		session.TwoFactorRequired = row.OrganizationRequiresTwoFactor && !row.TwoFactorEnabled
+	} else {
+		// The user left or was removed from their current organization
+		session.Membership, err = personalMembership(ctx, queries, row.UserID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Slide the expiry, but don't write on every request
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) > sessionTouchInterval {
+		err := queries.TouchSession(ctx, db.TouchSessionParams{
+			ID:        session.ID,
+			ExpiresAt: pgtype.Timestamp{Time: now.Add(SessionIdleTTL), Valid: true},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to touch session: %w", err)
+		}
+		session.LastSeenAt = now
+	}
+
+	return session, nil
+}
+
+// ListUserSessions returns the active sessions of a user, most recently used first
+func (s *Service) ListUserSessions(ctx context.Context, userID string) ([]Session, error) {
+	queries := s.getDB()
+
+	dbSessions, err := queries.ListActiveUserSessions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	sessions := make([]Session, 0, len(dbSessions))
+	for _, dbSession := range dbSessions {
+		sessions = append(sessions, *toDomainSession(dbSession))
+	}
+	return sessions, nil
+}
+
+// RevokeSession logs out one session of a user
+func (s *Service) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	queries := s.getDB()
+
+	rows, err := queries.DeleteUserSession(ctx, db.DeleteUserSessionParams{
+		ID:     sessionID,
+		UserID: userID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if rows == 0 {
+		return apperrs.Client(apperrs.CodeNotFound, "session not found")
+	}
+	return nil
+}
+
+// RevokeSessionToken logs out the session behind a cookie token
+func (s *Service) RevokeSessionToken(ctx context.Context, token string) error {
+	queries := s.getDB()
+
+	if err := queries.DeleteSessionByTokenHash(ctx, hashSessionToken(token)); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// RevokeAllSessions logs a user out everywhere
+func (s *Service) RevokeAllSessions(ctx context.Context, userID string) error {
+	queries := s.getDB()
+
+	if err := queries.DeleteUserSessions(ctx, userID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
+
+// ValidateGateSession reports whether the dashboard session behind a gate cookie may still open
+// an instance: the session is active and its user is a member of the organization that owns the instance.
+// Logging out, removal from the organization, transferring the instance and deleting the account all end it.
+func (s *Service) ValidateGateSession(ctx context.Context, sessionID, userID string, instance *Instance) (bool, error) {
+	valid, err := s.getDB().IsGateSessionValid(ctx, db.IsGateSessionValidParams{
+		ID:             sessionID,
+		UserID:         userID,
+		OrganizationID: instance.OrganizationID,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to validate gate session: %w", err)
+	}
+	return valid, nil
+}
+
+// CreateGateExchangeToken stores the ID of a token that hands a dashboard session over to the
+// ranx login of an instance until expiresAt. ConsumeGateExchangeToken accepts it once.
+func (s *Service) CreateGateExchangeToken(ctx context.Context, instanceID string, expiresAt time.Time) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate gate exchange token ID: %w", err)
+	}
+	id := hex.EncodeToString(b)
+
+	if err := s.getDB().CreateGateExchangeToken(ctx, db.CreateGateExchangeTokenParams{
+		ID:         id,
+		InstanceID: instanceID,
+		ExpiresAt:  pgtype.Timestamp{Time: expiresAt, Valid: true},
+	}); err != nil {
+		return "", fmt.Errorf("failed to store gate exchange token: %w", err)
+	}
+	return id, nil
+}
+
+// ConsumeGateExchangeToken marks a token from CreateGateExchangeToken used. It reports false when
+// the token is unknown, expired, issued for another instance or was already exchanged.
+func (s *Service) ConsumeGateExchangeToken(ctx context.Context, instanceID, id string) (bool, error) {
+	consumed, err := s.getDB().ConsumeGateExchangeToken(ctx, db.ConsumeGateExchangeTokenParams{
+		ID:         id,
+		InstanceID: instanceID,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to consume gate exchange token: %w", err)
+	}
+	return consumed == 1, nil
+}
+
+func toDomainSession(dbSession db.Session) *Session {
+	return &Session{
+		ID:         dbSession.ID,
+		UserID:     dbSession.UserID,
+		UserAgent:  dbSession.UserAgent,
+		IPAddress:  dbSession.IpAddress,
+		CreatedAt:  dbSession.CreatedAt.Time,
+		LastSeenAt: dbSession.LastSeenAt.Time,
+		ExpiresAt:  dbSession.ExpiresAt.Time,
+	}
+}
+
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/testdb"
+)
+
+func TestGateExchangeToken(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := testdb.Context()
+	member := createTestUser(t, s, "gated")
+	instance := createTestInstance(t, s, member, "gated")
+	other := createTestInstance(t, s, member, "other")
+
+	id, err := s.CreateGateExchangeToken(ctx, instance.ID, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to create gate exchange token: %v", err)
+	}
+	expired, err := s.CreateGateExchangeToken(ctx, instance.ID, time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		instanceID string
+		id         string
+		want       bool
+	}{
+		{name: "other instance", instanceID: other.ID, id: id},
+		{name: "expired", instanceID: instance.ID, id: expired},
+		{name: "unknown", instanceID: instance.ID, id: "unknown"},
+		{name: "first use", instanceID: instance.ID, id: id, want: true},
+		{name: "replayed", instanceID: instance.ID, id: id},
+	}
+
+	for _, tt := range tests {
+		consumed, err := s.ConsumeGateExchangeToken(ctx, tt.instanceID, tt.id)
+		if err != nil || consumed != tt.want {
+			t.Errorf("%s: expected %v, got %v %v", tt.name, tt.want, consumed, err)
+		}
+	}
+}
@@ -12,7 +12,8 @@ import (
 // Subscription represents a subscription
 type Subscription struct {
 	ID             string
-	UserID         string
+	UserID         string // The billing contact
+	OrganizationID string
 	ProductID      string
 	CustomerID     string
 	SubscriptionID string
@@ -27,6 +28,11 @@ func (s *Subscription) IsTrial() bool {
 	return s.Status == SubscriptionStatusTrial || s.Status == SubscriptionStatusTrialing
 }
 
+// IsExpiredFreeTrial returns true if the free trial ended without the organization subscribing
+func (s *Subscription) IsExpiredFreeTrial() bool {
+	return s.Status == SubscriptionStatusExpired && s.SubscriptionID == ""
+}
+
 // toDomainSubscription maps a db.Subscription to a Subscription (domain layer)
 func toDomainSubscription(sub db.Subscription) *Subscription {
 	var trialEndsAt *time.Time
@@ -36,6 +42,7 @@ func toDomainSubscription(sub db.Subscription) *Subscription {
 	return &Subscription{
 		ID:             sub.ID,
 		UserID:         sub.UserID,
+		OrganizationID: sub.OrganizationID,
 		ProductID:      sub.ProductID,
 		CustomerID:     sub.CustomerID,
 		SubscriptionID: sub.SubscriptionID,
@@ -47,32 +54,31 @@ func toDomainSubscription(sub db.Subscription) *Subscription {
 	}
 }
 
-// GetUserSubscription returns the subscription for a user (one subscription per user)
 // SyncSubscriptionQuantity syncs the instance count from our DB to LemonSqueezy subscription quantity.
 // It counts active instances (where deleted_at IS NULL) and updates LemonSqueezy if quantities differ.
-func (s *Service) SyncSubscriptionQuantity(ctx context.Context, userID string) error {
+func (s *Service) SyncSubscriptionQuantity(ctx context.Context, organizationID string) error {
 	l := appctx.GetLogger(ctx)
 	queries := s.getDB()
 
 	// Get subscription
-	sub, err := queries.GetSubscriptionByUserID(ctx, userID)
+	sub, err := queries.GetSubscriptionByOrganizationID(ctx, organizationID)
 	if err != nil {
 		return fmt.Errorf("failed to get subscription: %w", err)
 	}
 
 	// Skip if no LemonSqueezy subscription ID (e.g., trial users)
 	if sub.SubscriptionID == "" {
-		l.Debug("subscription has no LemonSqueezy subscription ID, skipping sync", "user_id", userID)
+		l.Debug("subscription has no LemonSqueezy subscription ID, skipping sync", "organization_id", organizationID)
 		return nil
 	}
 
 	// Count active instances in our DB
-	instanceCount, err := queries.CountActiveInstancesByUserID(ctx, userID)
+	instanceCount, err := queries.CountActiveInstancesByOrganizationID(ctx, organizationID)
 	if err != nil {
 		return fmt.Errorf("failed to count instances: %w", err)
 	}
 
-	l.Debug("syncing subscription quantity", "user_id", userID, "db_instance_count", instanceCount)
+	l.Debug("syncing subscription quantity", "organization_id", organizationID, "db_instance_count", instanceCount)
 
 	// Fetch subscription from LemonSqueezy
 	lsSub, err := s.lemonsqueezy.GetSubscription(ctx, sub.SubscriptionID)
@@ -86,17 +92,17 @@ func (s *Service) SyncSubscriptionQuantity(ctx context.Context, userID string) e
 		lsQuantity = lsSub.Data.Attributes.FirstSubscriptionItem.Quantity
 	}
 
-	l.Debug("fetched LemonSqueezy subscription", "user_id", userID, "ls_quantity", lsQuantity)
+	l.Debug("fetched LemonSqueezy subscription", "organization_id", organizationID, "ls_quantity", lsQuantity)
 
 	// If our DB count is zero, we might want to handle cancellation or alerts here.
 	// For now, we just log it.
 	if instanceCount == 0 && lsQuantity == 1 {
-		l.Info("user has zero active instances, consider handling cancellation", "user_id", userID)
+		l.Info("organization has zero active instances, consider handling cancellation", "organization_id", organizationID)
 	}
 
 	// If quantities match, nothing to do
 	if int64(lsQuantity) == instanceCount {
-		l.Debug("subscription quantities match, no update needed", "user_id", userID)
+		l.Debug("subscription quantities match, no update needed", "organization_id", organizationID)
 		return nil
 	}
 
@@ -113,15 +119,16 @@ func (s *Service) SyncSubscriptionQuantity(ctx context.Context, userID string) e
 		return fmt.Errorf("failed to update subscription quantity in DB: %w", err)
 	}
 
-	l.Debug("updated LemonSqueezy subscription quantity", "user_id", userID, "new_quantity", instanceCount)
+	l.Debug("updated LemonSqueezy subscription quantity", "organization_id", organizationID, "new_quantity", instanceCount)
 
 	return nil
 }
 
-func (s *Service) GetUserSubscription(ctx context.Context, userID string) (*Subscription, error) {
+// GetOrganizationSubscription returns the subscription of an organization (one subscription per organization)
+func (s *Service) GetOrganizationSubscription(ctx context.Context, organizationID string) (*Subscription, error) {
 	queries := s.getDB()
 
-	sub, err := queries.GetSubscriptionByUserID(ctx, userID)
+	sub, err := queries.GetSubscriptionByOrganizationID(ctx, organizationID)
 	if err != nil {
 		if db.IsNotFoundError(err) {
 			return nil, nil
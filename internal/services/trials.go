@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/config"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/aliuygur/n8n-saas-api/internal/emails"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// trialWarningBefore is how long before the end of a trial the owners are warned
+const trialWarningBefore = 24 * time.Hour
+
+// trialEnforcementTimeout is how long a run may take to stop the instances of a trial before
+// another run takes over, for the runs that died on the way
+const trialEnforcementTimeout = 30 * time.Minute
+
+// ExpireTrials warns the owners of trials ending within a day, tells them when the trial ended,
+// and stops the instances of trials whose grace period passed. It runs as the trials.expire job
+// every config.Trial.CheckInterval. Every step happens once per trial, a step that fails is retried
+// on the next run.
+func (s *Service) ExpireTrials(ctx context.Context) error {
+	l := appctx.GetLogger(ctx)
+	queries := s.getDB()
+
+	now := time.Now()
+
+	ending, err := queries.ListTrialsEndingSoon(ctx, pgtype.Timestamp{Time: now.Add(trialWarningBefore), Valid: true})
+	if err != nil {
+		return fmt.Errorf("failed to list trials ending soon: %w", err)
+	}
+	for _, sub := range ending {
+		email := emails.TrialEnding{
+			EndsAt:       sub.TrialEndsAt.Time,
+			SubscribeURL: s.config.Server.BaseURL("/subscription"),
+		}
+		if err := s.notifyOrganizationOwners(ctx, sub.OrganizationID, NotificationTrialEnding, email); err != nil {
+			l.Error("failed to warn about ending trial", "subscription_id", sub.ID, "error", err)
+			continue
+		}
+		if err := queries.MarkTrialWarningSent(ctx, sub.ID); err != nil {
+			return fmt.Errorf("failed to mark trial warning sent: %w", err)
+		}
+		s.recordAudit(ctx, auditEntry{
+			OrganizationID: sub.OrganizationID,
+			Action:         AuditActionSubscriptionTrialEnding,
+			TargetType:     "subscription",
+			TargetID:       sub.ID,
+			Metadata:       map[string]string{"trial_ends_at": sub.TrialEndsAt.Time.UTC().Format(time.RFC3339)},
+		})
+	}
+
+	ended, err := queries.ListEndedTrials(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list ended trials: %w", err)
+	}
+	for _, sub := range ended {
+		email := emails.TrialEnded{
+			// The grace period starts when the owners are told
+			StopsAt:      now.Add(s.config.Trial.GracePeriod),
+			Deletes:      s.config.Trial.ExpiryAction == config.TrialExpiryDelete,
+			SubscribeURL: s.config.Server.BaseURL("/subscription"),
+		}
+		if err := s.notifyOrganizationOwners(ctx, sub.OrganizationID, NotificationTrialEnded, email); err != nil {
+			l.Error("failed to tell about ended trial", "subscription_id", sub.ID, "error", err)
+			continue
+		}
+		if err := queries.MarkTrialEndedNoticeSent(ctx, sub.ID); err != nil {
+			return fmt.Errorf("failed to mark trial ended notice sent: %w", err)
+		}
+		s.recordAudit(ctx, auditEntry{
+			OrganizationID: sub.OrganizationID,
+			Action:         AuditActionSubscriptionTrialEnded,
+			TargetType:     "subscription",
+			TargetID:       sub.ID,
+			Metadata:       map[string]string{"trial_ends_at": sub.TrialEndsAt.Time.UTC().Format(time.RFC3339)},
+		})
+	}
+
+	pastGrace, err := queries.ListTrialsPastGrace(ctx, pgtype.Timestamp{Time: now.Add(-s.config.Trial.GracePeriod), Valid: true})
+	if err != nil {
+		return fmt.Errorf("failed to list trials past their grace period: %w", err)
+	}
+	for _, sub := range pastGrace {
+		if err := s.enforceTrialExpiry(ctx, sub); err != nil {
+			l.Error("failed to stop instances of expired trial", "subscription_id", sub.ID, "organization_id", sub.OrganizationID, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// enforceTrialExpiry hibernates or deletes the instances of an expired trial and expires the subscription.
+// The trial is claimed up front rather than locked, so checkouts and trial extensions never wait on the
+// cluster. One completing meanwhile keeps the subscription from expiring and its instances are woken again.
+func (s *Service) enforceTrialExpiry(ctx context.Context, sub db.Subscription) error {
+	l := appctx.GetLogger(ctx)
+	queries := s.getDB()
+
+	claimed, err := queries.ClaimTrialEnforcement(ctx, db.ClaimTrialEnforcementParams{
+		ID:          sub.ID,
+		StaleBefore: pgtype.Timestamp{Time: time.Now().Add(-trialEnforcementTimeout), Valid: true},
+	})
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			l.Info("trial no longer expires or is being enforced", "subscription_id", sub.ID, "organization_id", sub.OrganizationID)
+			return nil
+		}
+		return fmt.Errorf("failed to claim trial enforcement: %w", err)
+	}
+
+	action, instances, err := s.stopTrialInstances(ctx, sub.OrganizationID)
+	if err != nil {
+		// The instances already stopped stay stopped, the next run does the rest
+		if releaseErr := queries.ReleaseTrialEnforcement(ctx, db.ReleaseTrialEnforcementParams{
+			ID:        sub.ID,
+			ClaimedAt: claimed.TrialEnforcementClaimedAt,
+		}); releaseErr != nil {
+			l.Error("failed to release trial enforcement", "subscription_id", sub.ID, "error", releaseErr)
+		}
+		return err
+	}
+
+	expired, err := queries.MarkTrialEnforced(ctx, db.MarkTrialEnforcedParams{
+		ID:        sub.ID,
+		ClaimedAt: claimed.TrialEnforcementClaimedAt,
+	})
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			// The checkout or extension may have woken the instances before they were all stopped
+			l.Info("trial no longer expires, waking its instances", "subscription_id", sub.ID, "organization_id", sub.OrganizationID)
+			return s.wakeHibernatedInstances(ctx, sub.OrganizationID)
+		}
+		return fmt.Errorf("failed to expire subscription: %w", err)
+	}
+	s.publishSubscriptionUpdated(ctx, expired)
+
+	l.Info("expired trial", "subscription_id", sub.ID, "organization_id", sub.OrganizationID, "action", action, "instances", instances)
+	s.recordAudit(ctx, auditEntry{
+		OrganizationID: sub.OrganizationID,
+		Action:         AuditActionSubscriptionTrialExpired,
+		TargetType:     "subscription",
+		TargetID:       sub.ID,
+		Metadata:       map[string]string{"action": action, "instances": strconv.Itoa(instances)},
+	})
+	return nil
+}
+
+// stopTrialInstances hibernates or deletes the instances of an organization whose trial expired,
+// depending on config.Trial.ExpiryAction, and returns the action and how many instances it found
+func (s *Service) stopTrialInstances(ctx context.Context, organizationID string) (string, int, error) {
+	queries := s.getDB()
+	instances, err := queries.ListInstancesByOrganization(ctx, organizationID)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to list organization instances: %w", err)
+	}
+
+	action := s.config.Trial.ExpiryAction
+	switch action {
+	case config.TrialExpiryDelete:
+		// The owners hear about the instances that are gone, also when others are left for the next run
+		deleted, err := s.DeleteAllOrganizationInstances(ctx, organizationID)
+		s.notifyInstancesDeleted(ctx, organizationID, deleted, "your free trial ended")
+		if err != nil {
+			return "", 0, err
+		}
+	default:
+		for _, dbInst := range instances {
+			instance := toDomainInstance(dbInst)
+			if err := s.hibernateInstance(ctx, queries, &instance); err != nil {
+				return "", 0, err
+			}
+		}
+	}
+	return action, len(instances), nil
+}
+
+// hibernateInstance scales an instance to zero and hands its hostname to the proxy,
+// which tells visitors the trial ended
+func (s *Service) hibernateInstance(ctx context.Context, queries *db.Queries, instance *Instance) error {
+	if instance.Status == InstanceStatusHibernated {
+		return nil
+	}
+	if err := s.gke.ScaleDeployment(ctx, instance.Namespace, n8nDeploymentName, 0); err != nil {
+		return fmt.Errorf("failed to hibernate instance %s: %w", instance.ID, err)
+	}
+	if s.config.Routing.IsGateway() {
+		if err := s.deleteInstanceRoute(ctx, instance.Namespace); err != nil {
+			return fmt.Errorf("failed to delete route of instance %s: %w", instance.ID, err)
+		}
+	}
+	if _, err := queries.UpdateInstanceStatus(ctx, db.UpdateInstanceStatusParams{
+		ID:     instance.ID,
+		Status: InstanceStatusHibernated,
+	}); err != nil {
+		return fmt.Errorf("failed to update instance status: %w", err)
+	}
+	return nil
+}
+
+// wakeHibernatedInstances starts the instances hibernated at the end of a trial again,
+// once the organization subscribed or its trial was extended
+func (s *Service) wakeHibernatedInstances(ctx context.Context, organizationID string) error {
+	l := appctx.GetLogger(ctx)
+	queries := s.getDB()
+
+	instances, err := queries.ListHibernatedInstances(ctx, organizationID)
+	if err != nil {
+		return fmt.Errorf("failed to list hibernated instances: %w", err)
+	}
+
+	for _, dbInst := range instances {
+		instance := toDomainInstance(dbInst)
+		if err := s.gke.ScaleDeployment(ctx, instance.Namespace, n8nDeploymentName, 1); err != nil {
+			return fmt.Errorf("failed to wake instance %s: %w", instance.ID, err)
+		}
+		if _, err := queries.UpdateInstanceStatus(ctx, db.UpdateInstanceStatusParams{
+			ID:     instance.ID,
+			Status: InstanceStatusDeployed,
+		}); err != nil {
+			return fmt.Errorf("failed to update instance status: %w", err)
+		}
+		instance.Status = InstanceStatusDeployed
+		// The proxy keeps serving the instance if the route can't be created
+		if err := s.syncInstanceRoute(ctx, &instance); err != nil {
+			l.Error("failed to sync instance route", "instance_id", instance.ID, "error", err)
+		}
+		l.Info("woke hibernated instance", "instance_id", instance.ID, "organization_id", organizationID)
+	}
+	return nil
+}
+
+// freeTrialEnded returns true if the free trial of the organization is over and it did not subscribe
+func freeTrialEnded(sub db.Subscription, now time.Time) bool {
+	if sub.Status == SubscriptionStatusTrial {
+		return sub.TrialEndsAt.Valid && !sub.TrialEndsAt.Time.After(now)
+	}
+	return toDomainSubscription(sub).IsExpiredFreeTrial()
+}
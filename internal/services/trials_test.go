@@ -0,0 +1,217 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/aliuygur/n8n-saas-api/internal/testdb"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestFreeTrialEnded(t *testing.T) {
+	now := time.Now()
+	at := func(t time.Time) pgtype.Timestamp { return pgtype.Timestamp{Time: t, Valid: true} }
+
+	tests := []struct {
+		name string
+		sub  db.Subscription
+		want bool
+	}{
+		{name: "trial running", sub: db.Subscription{Status: SubscriptionStatusTrial, TrialEndsAt: at(now.Add(time.Hour))}, want: false},
+		{name: "trial ended", sub: db.Subscription{Status: SubscriptionStatusTrial, TrialEndsAt: at(now.Add(-time.Hour))}, want: true},
+		{name: "trial ends now", sub: db.Subscription{Status: SubscriptionStatusTrial, TrialEndsAt: at(now)}, want: true},
+		{name: "trial without end", sub: db.Subscription{Status: SubscriptionStatusTrial}, want: false},
+		{name: "expired free trial", sub: db.Subscription{Status: SubscriptionStatusExpired, TrialEndsAt: at(now.Add(-time.Hour))}, want: true},
+		{name: "expired paid subscription", sub: db.Subscription{Status: SubscriptionStatusExpired, SubscriptionID: "123"}, want: false},
+		{name: "subscribed", sub: db.Subscription{Status: SubscriptionStatusActive, SubscriptionID: "123", TrialEndsAt: at(now.Add(-time.Hour))}, want: false},
+		{name: "trial on Lemon Squeezy", sub: db.Subscription{Status: SubscriptionStatusTrialing, SubscriptionID: "123", TrialEndsAt: at(now.Add(-time.Hour))}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := freeTrialEnded(tt.sub, now); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// endTestTrial ends the trial of the organization and tells its owners a grace period ago
+func endTestTrial(t *testing.T, s *Service, organizationID string) db.Subscription {
+	t.Helper()
+	ctx := testdb.Context()
+
+	_, err := s.pool.Exec(ctx, `UPDATE subscriptions SET trial_ends_at = NOW() - INTERVAL '3 days',
+		trial_ended_notice_sent_at = NOW() - INTERVAL '3 days' WHERE organization_id = $1`, organizationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := s.getDB().GetSubscriptionByOrganizationID(ctx, organizationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sub
+}
+
+func TestEnforceTrialExpiry(t *testing.T) {
+	t.Run("trial expires", func(t *testing.T) {
+		s, _ := newTestService(t)
+		ctx := testdb.Context()
+		member := createTestUser(t, s, "expired")
+		endpoint := createTestWebhookEndpoint(t, s, member.OrganizationID, WebhookEventSubscriptionUpdated)
+		sub := endTestTrial(t, s, member.OrganizationID)
+
+		if err := s.enforceTrialExpiry(ctx, sub); err != nil {
+			t.Fatalf("Failed to enforce trial expiry: %v", err)
+		}
+
+		got, err := s.getDB().GetSubscriptionByOrganizationID(ctx, member.OrganizationID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != SubscriptionStatusExpired || !got.TrialEnforcedAt.Valid {
+			t.Errorf("Expected the trial to be expired, got %s enforced %v", got.Status, got.TrialEnforcedAt)
+		}
+		if countAuditEvents(t, s, AuditActionSubscriptionTrialExpired, sub.ID) != 1 {
+			t.Error("Expected the expiry to be audited")
+		}
+		deliveries, err := s.getDB().ListWebhookDeliveries(ctx, db.ListWebhookDeliveriesParams{EndpointID: endpoint.ID, MaxRows: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var event struct {
+			Data webhookSubscriptionData `json:"data"`
+		}
+		if len(deliveries) != 1 || json.Unmarshal([]byte(deliveries[0].Payload), &event) != nil || event.Data.Subscription.Status != SubscriptionStatusExpired {
+			t.Errorf("Expected the expired subscription to be published, got %+v", deliveries)
+		}
+
+		// Running again finds nothing to do
+		if err := s.enforceTrialExpiry(ctx, sub); err != nil || countAuditEvents(t, s, AuditActionSubscriptionTrialExpired, sub.ID) != 1 {
+			t.Errorf("Expected the second run to skip the trial, got %v", err)
+		}
+	})
+
+	t.Run("checkout completed meanwhile", func(t *testing.T) {
+		s, _ := newTestService(t)
+		ctx := testdb.Context()
+		member := createTestUser(t, s, "subscribed")
+		sub := endTestTrial(t, s, member.OrganizationID)
+
+		if _, err := s.pool.Exec(ctx, "UPDATE subscriptions SET status = 'active', subscription_id = '123' WHERE id = $1", sub.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.enforceTrialExpiry(ctx, sub); err != nil {
+			t.Fatalf("Expected the subscription to be skipped, got %v", err)
+		}
+
+		got, err := s.getDB().GetSubscriptionByOrganizationID(ctx, member.OrganizationID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != SubscriptionStatusActive || got.TrialEnforcedAt.Valid {
+			t.Errorf("Expected the subscription to stay active, got %s enforced %v", got.Status, got.TrialEnforcedAt)
+		}
+		if countAuditEvents(t, s, AuditActionSubscriptionTrialExpired, sub.ID) != 0 {
+			t.Error("Expected no expiry to be audited")
+		}
+	})
+
+	t.Run("checkout during enforcement wins", func(t *testing.T) {
+		s, _ := newTestService(t)
+		ctx := testdb.Context()
+		member := createTestUser(t, s, "racing")
+		sub := endTestTrial(t, s, member.OrganizationID)
+		queries := s.getDB()
+
+		claimed, err := queries.ClaimTrialEnforcement(ctx, db.ClaimTrialEnforcementParams{
+			ID:          sub.ID,
+			StaleBefore: pgtype.Timestamp{Time: time.Now().Add(-trialEnforcementTimeout), Valid: true},
+		})
+		if err != nil {
+			t.Fatalf("Failed to claim trial: %v", err)
+		}
+
+		// Another run leaves the claimed trial alone
+		if err := s.enforceTrialExpiry(ctx, sub); err != nil || countAuditEvents(t, s, AuditActionSubscriptionTrialExpired, sub.ID) != 0 {
+			t.Errorf("Expected the claimed trial to be skipped, got %v", err)
+		}
+
+		// The checkout doesn't wait for the instances to be stopped
+		checkout, err := s.pool.Begin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer checkout.Rollback(ctx)
+		if _, err := checkout.Exec(ctx, "SET LOCAL lock_timeout = '100ms'"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := checkout.Exec(ctx, "UPDATE subscriptions SET status = 'active', subscription_id = '123' WHERE id = $1", sub.ID); err != nil {
+			t.Fatalf("Expected the checkout not to wait for the enforcement, got %v", err)
+		}
+		if err := checkout.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := queries.MarkTrialEnforced(ctx, db.MarkTrialEnforcedParams{ID: sub.ID, ClaimedAt: claimed.TrialEnforcementClaimedAt}); !db.IsNotFoundError(err) {
+			t.Errorf("Expected the subscribed trial not to expire, got %v", err)
+		}
+	})
+
+	t.Run("trial extended during enforcement", func(t *testing.T) {
+		s, _ := newTestService(t)
+		ctx := testdb.Context()
+		member := createTestUser(t, s, "extended")
+		sub := endTestTrial(t, s, member.OrganizationID)
+		queries := s.getDB()
+
+		claimed, err := queries.ClaimTrialEnforcement(ctx, db.ClaimTrialEnforcementParams{
+			ID:          sub.ID,
+			StaleBefore: pgtype.Timestamp{Time: time.Now().Add(-trialEnforcementTimeout), Valid: true},
+		})
+		if err != nil {
+			t.Fatalf("Failed to claim trial: %v", err)
+		}
+		if _, err := queries.RestartTrial(ctx, db.RestartTrialParams{
+			ID:          sub.ID,
+			TrialEndsAt: pgtype.Timestamp{Time: time.Now().Add(7 * 24 * time.Hour), Valid: true},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := queries.MarkTrialEnforced(ctx, db.MarkTrialEnforcedParams{ID: sub.ID, ClaimedAt: claimed.TrialEnforcementClaimedAt}); !db.IsNotFoundError(err) {
+			t.Errorf("Expected the extended trial not to expire, got %v", err)
+		}
+		got, err := queries.GetSubscriptionByOrganizationID(ctx, member.OrganizationID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != SubscriptionStatusTrial || got.TrialEnforcementClaimedAt.Valid {
+			t.Errorf("Expected a running trial without claim, got %s claimed %v", got.Status, got.TrialEnforcementClaimedAt)
+		}
+	})
+
+	t.Run("abandoned claim is taken over", func(t *testing.T) {
+		s, _ := newTestService(t)
+		ctx := testdb.Context()
+		member := createTestUser(t, s, "abandoned")
+		sub := endTestTrial(t, s, member.OrganizationID)
+
+		if _, err := s.pool.Exec(ctx, "UPDATE subscriptions SET trial_enforcement_claimed_at = NOW() - INTERVAL '1 hour' WHERE id = $1", sub.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.enforceTrialExpiry(ctx, sub); err != nil {
+			t.Fatalf("Failed to enforce trial expiry: %v", err)
+		}
+
+		got, err := s.getDB().GetSubscriptionByOrganizationID(ctx, member.OrganizationID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != SubscriptionStatusExpired || got.TrialEnforcementClaimedAt.Valid {
+			t.Errorf("Expected the trial to be expired, got %s claimed %v", got.Status, got.TrialEnforcementClaimedAt)
+		}
+	})
+}
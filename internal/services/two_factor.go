@@ -0,0 +1,410 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/aliuygur/n8n-saas-api/internal/mailer"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/samber/lo"
+)
+
+const (
+	// totpPeriod and totpDigits are the RFC 6238 defaults every authenticator app supports
+	totpPeriod = 30
+	totpDigits = 6
+	// totpSkew is how many periods a code may be off to allow for clock drift
+	totpSkew = 1
+	// recoveryCodeCount is how many recovery codes are issued at a time
+	recoveryCodeCount = 10
+	// twoFactorMaxFailures incorrect codes lock two-factor checks for twoFactorLockout
+	twoFactorMaxFailures = 5
+	twoFactorLockout     = 15 * time.Minute
+	// totpIssuer names the account in authenticator apps
+	totpIssuer = "ranx.cloud"
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TwoFactorStatus is the two-factor authentication state of an account
+type TwoFactorStatus struct {
+	Enabled           bool
+	EnabledAt         *time.Time
+	RecoveryCodesLeft int
+}
+
+// TwoFactorSetup is a new authenticator secret the user still has to confirm with a code
+type TwoFactorSetup struct {
+	Secret string // Base32 secret for manual entry
+	URI    string // otpauth:// URI that authenticator apps import
+}
+
+// GetTwoFactorStatus returns whether the user has two-factor authentication enabled
+func (s *Service) GetTwoFactorStatus(ctx context.Context, userID string) (*TwoFactorStatus, error) {
+	queries := s.getDB()
+
+	user, err := queries.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	status := &TwoFactorStatus{Enabled: user.TotpEnabledAt.Valid}
+	if !status.Enabled {
+		return status, nil
+	}
+	status.EnabledAt = &user.TotpEnabledAt.Time
+
+	count, err := queries.CountUnusedRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	status.RecoveryCodesLeft = int(count)
+	return status, nil
+}
+
+// BeginTwoFactorSetup generates an authenticator secret for the user.
+// Two-factor authentication stays off until EnableTwoFactor confirms a code from it.
+func (s *Service) BeginTwoFactorSetup(ctx context.Context, userID string) (*TwoFactorSetup, error) {
+	queries := s.getDB()
+
+	user, err := queries.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.TotpEnabledAt.Valid {
+		return nil, apperrs.Client(apperrs.CodeConflict, "two-factor authentication is already enabled")
+	}
+
+	key := make([]byte, 20)
+	if _, err := rand.Read(key); err != nil {
+		return nil, apperrs.Server("failed to generate two-factor secret", err)
+	}
+	secret := totpEncoding.EncodeToString(key)
+
+	err = queries.SetUserTOTPSecret(ctx, db.SetUserTOTPSecretParams{
+		ID:         userID,
+		TotpSecret: pgtype.Text{String: secret, Valid: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store two-factor secret: %w", err)
+	}
+
+	label := url.PathEscape(totpIssuer + ":" + user.Email)
+	uri := "otpauth://totp/" + label + "?" + url.Values{
+		"secret":    {secret},
+		"issuer":    {totpIssuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(totpDigits)},
+		"period":    {fmt.Sprint(totpPeriod)},
+	}.Encode()
+
+	return &TwoFactorSetup{Secret: secret, URI: uri}, nil
+}
+
+// EnableTwoFactor turns on two-factor authentication once the user proved their authenticator
+// works, and returns recovery codes that are only shown this once
+func (s *Service) EnableTwoFactor(ctx context.Context, userID, code string) ([]string, error) {
+	queries, tx := s.getDBWithTx(ctx)
+	defer tx.Rollback(ctx)
+
+	user, err := queries.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.TotpEnabledAt.Valid {
+		return nil, apperrs.Client(apperrs.CodeConflict, "two-factor authentication is already enabled")
+	}
+	if !user.TotpSecret.Valid {
+		return nil, apperrs.Client(apperrs.CodeInvalidInput, "start the two-factor setup again")
+	}
+
+	step, ok := verifyTOTP(user.TotpSecret.String, normalizeTwoFactorCode(code), time.Now(), 0)
+	if !ok {
+		return nil, apperrs.Client(apperrs.CodeInvalidInput, "the code is incorrect, check the time on your device and try again")
+	}
+
+	if err := queries.EnableUserTOTP(ctx, db.EnableUserTOTPParams{ID: userID, TotpLastStep: step}); err != nil {
+		return nil, fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+
+	codes, err := replaceRecoveryCodes(ctx, queries, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, apperrs.Server("failed to commit transaction", err)
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor turns off two-factor authentication after checking a current code
+func (s *Service) DisableTwoFactor(ctx context.Context, userID, code string) error {
+	if err := s.VerifyTwoFactorCode(ctx, userID, code); err != nil {
+		return err
+	}
+	return s.clearTwoFactor(ctx, userID)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user after checking a current code
+func (s *Service) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	if err := s.VerifyTwoFactorCode(ctx, userID, code); err != nil {
+		return nil, err
+	}
+
+	queries, tx := s.getDBWithTx(ctx)
+	defer tx.Rollback(ctx)
+
+	codes, err := replaceRecoveryCodes(ctx, queries, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, apperrs.Server("failed to commit transaction", err)
+	}
+	return codes, nil
+}
+
+// VerifyTwoFactorCode checks an authenticator or recovery code of the user.
+// Every code works once, and repeated incorrect codes lock the checks for a while.
+func (s *Service) VerifyTwoFactorCode(ctx context.Context, userID, code string) error {
+	queries := s.getDB()
+
+	user, err := queries.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.TotpEnabledAt.Valid {
+		return apperrs.Client(apperrs.CodeInvalidInput, "two-factor authentication is not enabled")
+	}
+	if user.TwoFactorLockedUntil.Valid && time.Now().Before(user.TwoFactorLockedUntil.Time) {
+		return apperrs.Client(apperrs.CodeRateLimited, "too many incorrect codes, try again in a few minutes")
+	}
+
+	code = normalizeTwoFactorCode(code)
+	if len(code) == totpDigits {
+		if step, ok := verifyTOTP(user.TotpSecret.String, code, time.Now(), user.TotpLastStep); ok {
+			accepted, err := queries.AcceptTOTPStep(ctx, db.AcceptTOTPStepParams{ID: userID, TotpLastStep: step})
+			if err != nil {
+				return fmt.Errorf("failed to accept two-factor code: %w", err)
+			}
+			if accepted > 0 {
+				return nil
+			}
+		}
+	} else if code != "" {
+		used, err := queries.ConsumeRecoveryCode(ctx, db.ConsumeRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: hashRecoveryCode(code),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to consume recovery code: %w", err)
+		}
+		if used > 0 {
+			if err := queries.ResetTwoFactorFailures(ctx, userID); err != nil {
+				return fmt.Errorf("failed to reset two-factor failures: %w", err)
+			}
+			return nil
+		}
+	}
+
+	err = queries.RecordTwoFactorFailure(ctx, db.RecordTwoFactorFailureParams{
+		ID:          userID,
+		MaxFailures: twoFactorMaxFailures,
+		LockedUntil: pgtype.Timestamp{Time: time.Now().Add(twoFactorLockout), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record two-factor failure: %w", err)
+	}
+	return apperrs.Client(apperrs.CodeUnauthorized, "the code is incorrect")
+}
+
+// AdminResetTwoFactor turns off two-factor authentication of a user who lost their authenticator.
+// It's not up to organization owners, the account can belong to organizations that rely on it.
+// Admins can't reset their own, which would skip the code check of DisableTwoFactor.
+func (s *Service) AdminResetTwoFactor(ctx context.Context, adminID, userID string) error {
+	if err := s.requireAdmin(ctx, adminID); err != nil {
+		return err
+	}
+	if userID == adminID {
+		return apperrs.Client(apperrs.CodeForbidden, "turn off your own two-factor authentication on the account page")
+	}
+
+	user, err := s.getDB().GetUserByID(ctx, userID)
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			return apperrs.Client(apperrs.CodeNotFound, "user not found")
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.DeletedAt.Valid {
+		return apperrs.Client(apperrs.CodeConflict, "the account is deleted")
+	}
+	if !user.TotpEnabledAt.Valid {
+		return apperrs.Client(apperrs.CodeConflict, "two-factor authentication is not turned on")
+	}
+
+	if err := s.clearTwoFactor(ctx, userID); err != nil {
+		return err
+	}
+
+	s.recordAudit(ctx, auditEntry{
+		OrganizationID: lo.FromPtr(user.CurrentOrganizationID),
+		UserID:         adminID,
+		Action:         AuditActionUserTwoFactorReset,
+		TargetType:     "user",
+		TargetID:       userID,
+	})
+
+	// Tell the user, so a reset they didn't ask for doesn't go unnoticed
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your ranx.cloud two-factor authentication was reset",
+		Text: fmt.Sprintf("ranx.cloud support turned off two-factor authentication on your account.\n\n"+
+			"You can set it up again on your account page: %s\n", s.config.Server.BaseURL("/account")),
+	})
+	if err != nil {
+		return apperrs.Server("failed to send two-factor reset email", err)
+	}
+	return nil
+}
+
+// SetOrganizationRequireTwoFactor makes members turn on two-factor authentication before they can act in the organization
+func (s *Service) SetOrganizationRequireTwoFactor(ctx context.Context, member Membership, required bool) error {
+	if err := member.Require(RoleOwner); err != nil {
+		return err
+	}
+
+	queries := s.getDB()
+
+	org, err := queries.GetOrganization(ctx, member.OrganizationID)
+	if err != nil {
+		return fmt.Errorf("failed to get organization: %w", err)
+	}
+	if org.Personal {
+		return apperrs.Client(apperrs.CodeForbidden, "personal organizations can't require two-factor authentication")
+	}
+
+	if required {
+		// Otherwise the owner would lock themselves out with the first click
+		user, err := queries.GetUserByID(ctx, member.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+		if !user.TotpEnabledAt.Valid {
+			return apperrs.Client(apperrs.CodeInvalidInput, "turn on two-factor authentication for your own account first")
+		}
+	}
+
+	err = queries.SetOrganizationRequireTwoFactor(ctx, db.SetOrganizationRequireTwoFactorParams{
+		ID:               org.ID,
+		RequireTwoFactor: required,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update organization: %w", err)
+	}
+	return nil
+}
+
+// clearTwoFactor turns off two-factor authentication and deletes the recovery codes
+func (s *Service) clearTwoFactor(ctx context.Context, userID string) error {
+	queries, tx := s.getDBWithTx(ctx)
+	defer tx.Rollback(ctx)
+
+	if err := queries.DisableUserTOTP(ctx, userID); err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+	if err := queries.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return apperrs.Server("failed to commit transaction", err)
+	}
+	return nil
+}
+
+// replaceRecoveryCodes deletes the recovery codes of a user and issues new ones
+func replaceRecoveryCodes(ctx context.Context, queries *db.Queries, userID string) ([]string, error) {
+	if err := queries.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return nil, fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, apperrs.Server("failed to generate recovery code", err)
+		}
+		code := strings.ToLower(totpEncoding.EncodeToString(b))
+
+		err := queries.CreateRecoveryCode(ctx, db.CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: hashRecoveryCode(code),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to store recovery code: %w", err)
+		}
+		// Shown in two halves so it's easier to copy by hand
+		codes = append(codes, code[:8]+"-"+code[8:])
+	}
+	return codes, nil
+}
+
+// normalizeTwoFactorCode drops the spaces and dashes people type or paste with a code
+func normalizeTwoFactorCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code)))
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyTOTP returns the time step a code belongs to. Steps up to lastStep were already used
+// and are rejected, so an observed code can't be replayed.
+func verifyTOTP(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// totpCode computes the RFC 6238 code of a time step
+func totpCode(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1_000_000)
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/aliuygur/n8n-saas-api/internal/testdb"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// enableTestTwoFactor turns on two-factor authentication of a user without a setup
+func enableTestTwoFactor(t *testing.T, s *Service, userID string) {
+	t.Helper()
+	ctx := testdb.Context()
+	queries := s.getDB()
+
+	secret := pgtype.Text{String: "JBSWY3DPEHPK3PXP", Valid: true}
+	if err := queries.SetUserTOTPSecret(ctx, db.SetUserTOTPSecretParams{ID: userID, TotpSecret: secret}); err != nil {
+		t.Fatal(err)
+	}
+	if err := queries.EnableUserTOTP(ctx, db.EnableUserTOTPParams{ID: userID}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyTwoFactorCodeLockout(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := testdb.Context()
+	member := createTestUser(t, s, "locked")
+	enableTestTwoFactor(t, s, member.UserID)
+
+	for i := range twoFactorMaxFailures {
+		if err := s.VerifyTwoFactorCode(ctx, member.UserID, "000000"); !apperrs.CodeIs(err, apperrs.CodeUnauthorized) {
+			t.Fatalf("Expected incorrect code %d to be rejected, got %v", i+1, err)
+		}
+	}
+	if err := s.VerifyTwoFactorCode(ctx, member.UserID, "000000"); !apperrs.CodeIs(err, apperrs.CodeRateLimited) {
+		t.Fatalf("Expected the checks to be locked, got %v", err)
+	}
+
+	// The lockout ran out
+	if _, err := s.pool.Exec(ctx, "UPDATE users SET two_factor_locked_until = NOW() - INTERVAL '1 second' WHERE id = $1", member.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.VerifyTwoFactorCode(ctx, member.UserID, "000000"); !apperrs.CodeIs(err, apperrs.CodeUnauthorized) {
+		t.Fatalf("Expected an incorrect code after the lockout to be checked, got %v", err)
+	}
+	user, err := s.getDB().GetUserByID(ctx, member.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.TwoFactorFailures != 1 || user.TwoFactorLockedUntil.Valid {
+		t.Errorf("Expected the failures to start over, got %d failures locked until %v", user.TwoFactorFailures, user.TwoFactorLockedUntil)
+	}
+
+	// The user gets the full number of attempts again
+	for i := 1; i < twoFactorMaxFailures; i++ {
+		if err := s.VerifyTwoFactorCode(ctx, member.UserID, "000000"); !apperrs.CodeIs(err, apperrs.CodeUnauthorized) {
+			t.Fatalf("Expected incorrect code %d to be rejected, got %v", i+1, err)
+		}
+	}
+	if err := s.VerifyTwoFactorCode(ctx, member.UserID, "000000"); !apperrs.CodeIs(err, apperrs.CodeRateLimited) {
+		t.Errorf("Expected the checks to lock again, got %v", err)
+	}
+}
+
+func TestAuthenticateAPITokenTwoFactorRequired(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := testdb.Context()
+	member := createTestUser(t, s, "tokens")
+
+	secret, _, err := s.CreateAPIToken(ctx, CreateAPITokenParams{Member: member, Name: "ci", Scopes: []string{ScopeInstancesRead}})
+	if err != nil {
+		t.Fatalf("Failed to create API token: %v", err)
+	}
+
+	authenticate := func() *APIToken {
+		t.Helper()
+		token, err := s.AuthenticateAPIToken(ctx, secret)
+		if err != nil {
+			t.Fatalf("Failed to authenticate API token: %v", err)
+		}
+		return token
+	}
+
+	if authenticate().TwoFactorRequired {
+		t.Error("Expected no two-factor requirement by default")
+	}
+
+	err = s.getDB().SetOrganizationRequireTwoFactor(ctx, db.SetOrganizationRequireTwoFactorParams{ID: member.OrganizationID, RequireTwoFactor: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !authenticate().TwoFactorRequired {
+		t.Error("Expected the token to require two-factor authentication the user hasn't turned on")
+	}
+
+	enableTestTwoFactor(t, s, member.UserID)
+	if authenticate().TwoFactorRequired {
+		t.Error("Expected the requirement to be met once the user turned it on")
+	}
+}
+
+func TestAdminResetTwoFactor(t *testing.T) {
+	s, mail := newTestService(t)
+	ctx := testdb.Context()
+	admin := createTestUser(t, s, "admin")
+	user := createTestUser(t, s, "user")
+	owner := createTestUser(t, s, "owner")
+	enableTestTwoFactor(t, s, user.UserID)
+
+	if _, err := s.pool.Exec(ctx, "UPDATE users SET is_admin = TRUE WHERE id = $1", admin.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.AdminResetTwoFactor(ctx, owner.UserID, user.UserID); !apperrs.CodeIs(err, apperrs.CodeForbidden) {
+		t.Fatalf("Expected users who aren't admins to be rejected, got %v", err)
+	}
+	if err := s.AdminResetTwoFactor(ctx, admin.UserID, admin.UserID); !apperrs.CodeIs(err, apperrs.CodeForbidden) {
+		t.Fatalf("Expected admins to be unable to reset their own, got %v", err)
+	}
+
+	if err := s.AdminResetTwoFactor(ctx, admin.UserID, user.UserID); err != nil {
+		t.Fatalf("Expected the reset to succeed, got %v", err)
+	}
+
+	dbUser, err := s.getDB().GetUserByID(ctx, user.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dbUser.TotpEnabledAt.Valid || dbUser.TotpSecret.Valid {
+		t.Error("Expected two-factor authentication to be turned off")
+	}
+	if sent := mail.messages(); len(sent) != 1 || sent[0].To != "user@example.com" {
+		t.Errorf("Expected the user to be told about the reset, got %+v", sent)
+	}
+	if countAuditEvents(t, s, AuditActionUserTwoFactorReset, user.UserID) != 1 {
+		t.Error("Expected the reset to be audited")
+	}
+
+	if err := s.AdminResetTwoFactor(ctx, admin.UserID, user.UserID); !apperrs.CodeIs(err, apperrs.CodeConflict) {
+		t.Errorf("Expected a second reset to conflict, got %v", err)
+	}
+}
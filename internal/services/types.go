@@ -6,6 +6,8 @@ const (
 	InstanceStatusActive   = "active"
 	InstanceStatusFailed   = "failed"
 	InstanceStatusDeleted  = "deleted"
+	// InstanceStatusHibernated instances are scaled to zero after their trial ended
+	InstanceStatusHibernated = "hibernated"
 )
 
 const (
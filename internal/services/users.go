@@ -7,15 +7,15 @@ import (
 
 	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
 	"github.com/aliuygur/n8n-saas-api/internal/db"
-	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type User struct {
-	ID        string
-	Email     string
-	Name      string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID               string
+	Email            string
+	Name             string
+	TwoFactorEnabled bool // Logins need a code from the user's authenticator
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
 }
 
 func (s *Service) GetUserByEmail(ctx context.Context, email string) (*User, error) {
@@ -30,11 +30,12 @@ func (s *Service) GetUserByEmail(ctx context.Context, email string) (*User, erro
 	}
 
 	user := &User{
-		ID:        dbUser.ID,
-		Email:     dbUser.Email,
-		Name:      dbUser.Name,
-		CreatedAt: dbUser.CreatedAt.Time,
-		UpdatedAt: dbUser.UpdatedAt.Time,
+		ID:               dbUser.ID,
+		Email:            dbUser.Email,
+		Name:             dbUser.Name,
+		TwoFactorEnabled: dbUser.TotpEnabledAt.Valid,
+		CreatedAt:        dbUser.CreatedAt.Time,
+		UpdatedAt:        dbUser.UpdatedAt.Time,
 	}
 
 	return user, nil
@@ -52,11 +53,12 @@ func (s *Service) GetUser(ctx context.Context, userID string) (*User, error) {
 	}
 
 	user := &User{
-		ID:        dbUser.ID,
-		Email:     dbUser.Email,
-		Name:      dbUser.Name,
-		CreatedAt: dbUser.CreatedAt.Time,
-		UpdatedAt: dbUser.UpdatedAt.Time,
+		ID:               dbUser.ID,
+		Email:            dbUser.Email,
+		Name:             dbUser.Name,
+		TwoFactorEnabled: dbUser.TotpEnabledAt.Valid,
+		CreatedAt:        dbUser.CreatedAt.Time,
+		UpdatedAt:        dbUser.UpdatedAt.Time,
 	}
 
 	return user, nil
@@ -90,18 +92,19 @@ func (s *Service) CreateUser(ctx context.Context, params CreateUserParams) (*Use
 	}
 
 	user := &User{
-		ID:        dbUser.ID,
-		Email:     dbUser.Email,
-		Name:      dbUser.Name,
-		CreatedAt: dbUser.CreatedAt.Time,
-		UpdatedAt: dbUser.UpdatedAt.Time,
+		ID:               dbUser.ID,
+		Email:            dbUser.Email,
+		Name:             dbUser.Name,
+		TwoFactorEnabled: dbUser.TotpEnabledAt.Valid,
+		CreatedAt:        dbUser.CreatedAt.Time,
+		UpdatedAt:        dbUser.UpdatedAt.Time,
 	}
 
 	return user, nil
 }
 
 // GetOrCreateUser gets a user by email or creates a new one if not found
-// When creating a new user, also creates a trial subscription
+// When creating a new user, also creates their personal organization with a trial subscription
 func (s *Service) GetOrCreateUser(ctx context.Context, params CreateUserParams) (*User, error) {
 	tx, err := s.pool.Begin(ctx)
 	if err != nil {
@@ -123,21 +126,17 @@ func (s *Service) GetOrCreateUser(ctx context.Context, params CreateUserParams)
 				return nil, fmt.Errorf("failed to create user: %w", err)
 			}
 
-			// Create trial subscription for new user (trial starts when first instance is created)
-			_, err = queries.CreateSubscription(ctx, db.CreateSubscriptionParams{
-				UserID:         dbUser.ID,
-				ProductID:      "", // Empty for trial
-				VariantID:      "", // Empty for trial
-				CustomerID:     "", // Empty for trial
-				SubscriptionID: "", // Empty for trial
-				TrialEndsAt: pgtype.Timestamp{
-					Valid: false, // Will be set when first instance is created
-				},
-				Status:   SubscriptionStatusTrial,
-				Quantity: 1, // Default quantity for trial
+			// Every user gets a personal organization, which owns their trial subscription
+			org, err := createOrganization(ctx, queries, dbUser.ID, personalOrganizationName(dbUser), true)
+			if err != nil {
+				return nil, err
+			}
+			err = queries.SetUserCurrentOrganization(ctx, db.SetUserCurrentOrganizationParams{
+				ID:                    dbUser.ID,
+				CurrentOrganizationID: &org.ID,
 			})
 			if err != nil {
-				return nil, fmt.Errorf("failed to create trial subscription: %w", err)
+				return nil, fmt.Errorf("failed to set current organization: %w", err)
 			}
 		} else {
 			return nil, fmt.Errorf("failed to get user by email: %w", err)
@@ -149,12 +148,21 @@ func (s *Service) GetOrCreateUser(ctx context.Context, params CreateUserParams)
 	}
 
 	user := &User{
-		ID:        dbUser.ID,
-		Email:     dbUser.Email,
-		Name:      dbUser.Name,
-		CreatedAt: dbUser.CreatedAt.Time,
-		UpdatedAt: dbUser.UpdatedAt.Time,
+		ID:               dbUser.ID,
+		Email:            dbUser.Email,
+		Name:             dbUser.Name,
+		TwoFactorEnabled: dbUser.TotpEnabledAt.Valid,
+		CreatedAt:        dbUser.CreatedAt.Time,
+		UpdatedAt:        dbUser.UpdatedAt.Time,
 	}
 
 	return user, nil
 }
+
+// personalOrganizationName names the personal organization of a new user
+func personalOrganizationName(user db.User) string {
+	if user.Name != "" {
+		return user.Name
+	}
+	return user.Email
+}
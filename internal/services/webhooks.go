@@ -0,0 +1,538 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/aliuygur/n8n-saas-api/internal/jobs"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Events sent to webhook endpoints, named <target>.<event>
+const (
+	WebhookEventInstanceCreated     = "instance.created"
+	WebhookEventInstanceReady       = "instance.ready"
+	WebhookEventInstanceFailed      = "instance.failed"
+	WebhookEventInstanceDeleted     = "instance.deleted"
+	WebhookEventSubscriptionUpdated = "subscription.updated"
+)
+
+// WebhookEvents are the events an endpoint can subscribe to
+var WebhookEvents = []string{
+	WebhookEventInstanceCreated,
+	WebhookEventInstanceReady,
+	WebhookEventInstanceFailed,
+	WebhookEventInstanceDeleted,
+	WebhookEventSubscriptionUpdated,
+}
+
+// Webhook delivery statuses
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+const (
+	// WebhookSignatureHeader carries the hex HMAC-SHA256 of "<timestamp>.<body>", keyed with the endpoint secret
+	WebhookSignatureHeader = "X-Ranx-Signature"
+	// WebhookTimestampHeader carries the Unix time a delivery was signed at, receivers reject old
+	// timestamps so a captured request can't be replayed
+	WebhookTimestampHeader = "X-Ranx-Timestamp"
+	// webhookSecretPrefix marks signing secrets so secret scanners can recognize them
+	webhookSecretPrefix = "whsec_"
+	// maxWebhookEndpoints limits how many endpoints an organization can register
+	maxWebhookEndpoints = 10
+	// webhookMaxAttempts is how often a delivery is tried, the job queue backs off exponentially
+	// from 30s to an hour in between, so a receiver can be down for about three hours
+	webhookMaxAttempts = 8
+	// maxWebhookDeliveries limits how many deliveries the endpoint page shows
+	maxWebhookDeliveries = 100
+	// maxWebhookResponseBody is how much of a response is kept in the delivery log
+	maxWebhookResponseBody = 1024
+	// webhookDeliveryRetention is how long the delivery log is kept
+	webhookDeliveryRetention = 30 * 24 * time.Hour
+)
+
+// WebhookEndpoint is a URL an organization receives events at
+type WebhookEndpoint struct {
+	ID        string
+	URL       string
+	Secret    string
+	Events    []string // Empty receives every event
+	CreatedAt time.Time
+}
+
+// WebhookDelivery is an event sent to an endpoint, with the outcome of its last attempt
+type WebhookDelivery struct {
+	ID             string
+	EndpointID     string
+	EventID        string
+	Event          string
+	Payload        string
+	Status         string
+	Attempts       int32
+	ResponseStatus int32 // Zero when no response was received
+	ResponseBody   string
+	Error          string
+	CreatedAt      time.Time
+	LastAttemptAt  *time.Time
+	DeliveredAt    *time.Time
+}
+
+// webhookEvent is the JSON body of a delivery
+type webhookEvent struct {
+	ID             string    `json:"id"`
+	Type           string    `json:"type"`
+	CreatedAt      time.Time `json:"created_at"`
+	OrganizationID string    `json:"organization_id"`
+	Data           any       `json:"data"`
+}
+
+// webhookInstanceData is the data of instance events
+type webhookInstanceData struct {
+	Instance webhookInstance `json:"instance"`
+	Error    string          `json:"error,omitempty"` // Why provisioning failed
+}
+
+type webhookInstance struct {
+	ID         string    `json:"id,omitempty"` // Empty when provisioning failed before the instance was stored
+	Subdomain  string    `json:"subdomain"`
+	URL        string    `json:"url"`
+	Status     string    `json:"status,omitempty"`
+	AppVersion string    `json:"app_version,omitempty"`
+	CreatedAt  time.Time `json:"created_at,omitzero"`
+}
+
+// webhookSubscriptionData is the data of subscription events
+type webhookSubscriptionData struct {
+	Subscription webhookSubscription `json:"subscription"`
+}
+
+type webhookSubscription struct {
+	ID          string     `json:"id"`
+	Status      string     `json:"status"`
+	Quantity    int32      `json:"quantity"`
+	TrialEndsAt *time.Time `json:"trial_ends_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// deliverWebhookArgs are the arguments of JobDeliverWebhook
+type deliverWebhookArgs struct {
+	DeliveryID string `json:"delivery_id"`
+}
+
+func toDomainWebhookEndpoint(e db.WebhookEndpoint) WebhookEndpoint {
+	return WebhookEndpoint{
+		ID:        e.ID,
+		URL:       e.Url,
+		Secret:    e.Secret,
+		Events:    e.Events,
+		CreatedAt: e.CreatedAt.Time,
+	}
+}
+
+func toDomainWebhookDelivery(d db.WebhookDelivery) WebhookDelivery {
+	return WebhookDelivery{
+		ID:             d.ID,
+		EndpointID:     d.EndpointID,
+		EventID:        d.EventID,
+		Event:          d.Event,
+		Payload:        d.Payload,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		ResponseStatus: d.ResponseStatus,
+		ResponseBody:   d.ResponseBody,
+		Error:          d.Error,
+		CreatedAt:      d.CreatedAt.Time,
+		LastAttemptAt:  timestampPtr(d.LastAttemptAt),
+		DeliveredAt:    timestampPtr(d.DeliveredAt),
+	}
+}
+
+// ListWebhookEndpoints returns the webhook endpoints of the member's organization
+func (s *Service) ListWebhookEndpoints(ctx context.Context, member Membership) ([]WebhookEndpoint, error) {
+	if err := member.Require(RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	endpoints, err := s.getDB().ListWebhookEndpoints(ctx, member.OrganizationID)
+	if err != nil {
+		return nil, apperrs.Server("failed to list webhook endpoints", err)
+	}
+
+	result := make([]WebhookEndpoint, 0, len(endpoints))
+	for _, e := range endpoints {
+		result = append(result, toDomainWebhookEndpoint(e))
+	}
+	return result, nil
+}
+
+// GetWebhookEndpoint returns a webhook endpoint of the member's organization
+func (s *Service) GetWebhookEndpoint(ctx context.Context, member Membership, endpointID string) (*WebhookEndpoint, error) {
+	if err := member.Require(RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	endpoint, err := s.getDB().GetWebhookEndpoint(ctx, db.GetWebhookEndpointParams{
+		ID:             endpointID,
+		OrganizationID: member.OrganizationID,
+	})
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			return nil, apperrs.Client(apperrs.CodeNotFound, "webhook endpoint not found")
+		}
+		return nil, apperrs.Server("failed to get webhook endpoint", err)
+	}
+
+	result := toDomainWebhookEndpoint(endpoint)
+	return &result, nil
+}
+
+type CreateWebhookEndpointParams struct {
+	Member Membership
+	URL    string
+	Events []string // Empty subscribes to every event
+}
+
+// CreateWebhookEndpoint registers a URL that receives the events of the member's organization
+func (s *Service) CreateWebhookEndpoint(ctx context.Context, params CreateWebhookEndpointParams) (*WebhookEndpoint, error) {
+	if err := params.Member.Require(RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	url, err := normalizeWebhookURL(params.URL)
+	if err != nil {
+		return nil, err
+	}
+	if url == "" {
+		return nil, apperrs.Client(apperrs.CodeInvalidInput, "webhook URL is required")
+	}
+
+	events := make([]string, 0, len(params.Events))
+	for _, event := range params.Events {
+		if !slices.Contains(WebhookEvents, event) {
+			return nil, apperrs.Client(apperrs.CodeInvalidInput, fmt.Sprintf("unknown event %q", event))
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, apperrs.Server("failed to generate webhook secret", err)
+	}
+	secret := webhookSecretPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	queries, releaseLock := s.getDBWithLock(ctx, "webhook_endpoints_"+params.Member.OrganizationID)
+	defer releaseLock()
+
+	count, err := queries.CountWebhookEndpoints(ctx, params.Member.OrganizationID)
+	if err != nil {
+		return nil, apperrs.Server("failed to count webhook endpoints", err)
+	}
+	if count >= maxWebhookEndpoints {
+		return nil, apperrs.Client(apperrs.CodeInvalidInput, fmt.Sprintf("an organization can have at most %d webhook endpoints", maxWebhookEndpoints))
+	}
+
+	endpoint, err := queries.CreateWebhookEndpoint(ctx, db.CreateWebhookEndpointParams{
+		OrganizationID: params.Member.OrganizationID,
+		Url:            url,
+		Secret:         secret,
+		Events:         events,
+	})
+	if err != nil {
+		return nil, apperrs.Server("failed to create webhook endpoint", err)
+	}
+
+	result := toDomainWebhookEndpoint(endpoint)
+	return &result, nil
+}
+
+// DeleteWebhookEndpoint removes a webhook endpoint of the member's organization with its delivery log
+func (s *Service) DeleteWebhookEndpoint(ctx context.Context, member Membership, endpointID string) error {
+	if err := member.Require(RoleAdmin); err != nil {
+		return err
+	}
+
+	deleted, err := s.getDB().DeleteWebhookEndpoint(ctx, db.DeleteWebhookEndpointParams{
+		ID:             endpointID,
+		OrganizationID: member.OrganizationID,
+	})
+	if err != nil {
+		return apperrs.Server("failed to delete webhook endpoint", err)
+	}
+	if deleted == 0 {
+		return apperrs.Client(apperrs.CodeNotFound, "webhook endpoint not found")
+	}
+	return nil
+}
+
+// ListWebhookDeliveries returns the latest deliveries to a webhook endpoint of the member's organization
+func (s *Service) ListWebhookDeliveries(ctx context.Context, member Membership, endpointID string) ([]WebhookDelivery, error) {
+	endpoint, err := s.GetWebhookEndpoint(ctx, member, endpointID)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries, err := s.getDB().ListWebhookDeliveries(ctx, db.ListWebhookDeliveriesParams{
+		EndpointID: endpoint.ID,
+		MaxRows:    maxWebhookDeliveries,
+	})
+	if err != nil {
+		return nil, apperrs.Server("failed to list webhook deliveries", err)
+	}
+
+	result := make([]WebhookDelivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		result = append(result, toDomainWebhookDelivery(d))
+	}
+	return result, nil
+}
+
+// RedeliverWebhook sends the event of a delivery to its endpoint again, as a new delivery with the same event ID
+func (s *Service) RedeliverWebhook(ctx context.Context, member Membership, deliveryID string) (*WebhookDelivery, error) {
+	if err := member.Require(RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	queries, tx := s.getDBWithTx(ctx)
+	defer tx.Rollback(ctx)
+
+	original, err := queries.GetOrganizationWebhookDelivery(ctx, db.GetOrganizationWebhookDeliveryParams{
+		ID:             deliveryID,
+		OrganizationID: member.OrganizationID,
+	})
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			return nil, apperrs.Client(apperrs.CodeNotFound, "webhook delivery not found")
+		}
+		return nil, apperrs.Server("failed to get webhook delivery", err)
+	}
+
+	delivery, err := s.queueWebhookDelivery(ctx, queries, db.CreateWebhookDeliveryParams{
+		EndpointID: original.EndpointID,
+		EventID:    original.EventID,
+		Event:      original.Event,
+		Payload:    original.Payload,
+	})
+	if err != nil {
+		return nil, apperrs.Server("failed to queue webhook delivery", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, apperrs.Server("failed to commit transaction", err)
+	}
+
+	result := toDomainWebhookDelivery(delivery)
+	return &result, nil
+}
+
+// publishWebhookEvent queues an event to every endpoint of the organization subscribed to it.
+// Like the audit log it never fails the action that caused the event, errors are logged.
+func (s *Service) publishWebhookEvent(ctx context.Context, organizationID, event string, data any) {
+	if err := s.queueWebhookEvent(ctx, organizationID, event, data); err != nil {
+		appctx.GetLogger(ctx).Error("failed to publish webhook event", "organization_id", organizationID, "event", event, "error", err)
+	}
+}
+
+func (s *Service) queueWebhookEvent(ctx context.Context, organizationID, event string, data any) error {
+	queries, tx := s.getDBWithTx(ctx)
+	defer tx.Rollback(ctx)
+
+	endpoints, err := queries.ListWebhookEndpointsForEvent(ctx, db.ListWebhookEndpointsForEventParams{
+		OrganizationID: organizationID,
+		Event:          event,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list webhook endpoints: %w", err)
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Errorf("failed to generate event ID: %w", err)
+	}
+	eventID := "evt_" + hex.EncodeToString(b)
+
+	payload, err := json.Marshal(webhookEvent{
+		ID:             eventID,
+		Type:           event,
+		CreatedAt:      time.Now().UTC(),
+		OrganizationID: organizationID,
+		Data:           data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook event: %w", err)
+	}
+
+	for _, endpoint := range endpoints {
+		if _, err := s.queueWebhookDelivery(ctx, queries, db.CreateWebhookDeliveryParams{
+			EndpointID: endpoint.ID,
+			EventID:    eventID,
+			Event:      event,
+			Payload:    string(payload),
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// queueWebhookDelivery stores a delivery and enqueues the job sending it in the caller's transaction
+func (s *Service) queueWebhookDelivery(ctx context.Context, queries *db.Queries, params db.CreateWebhookDeliveryParams) (db.WebhookDelivery, error) {
+	delivery, err := queries.CreateWebhookDelivery(ctx, params)
+	if err != nil {
+		return db.WebhookDelivery{}, fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+	if _, err := s.jobs.EnqueueTx(ctx, queries, JobDeliverWebhook, deliverWebhookArgs{DeliveryID: delivery.ID}, jobs.EnqueueOptions{
+		MaxAttempts: webhookMaxAttempts,
+	}); err != nil {
+		return db.WebhookDelivery{}, err
+	}
+	return delivery, nil
+}
+
+// publishInstanceEvent publishes an instance event, errMsg is set for instance.failed
+func (s *Service) publishInstanceEvent(ctx context.Context, event string, instance *Instance, errMsg string) {
+	data := webhookInstanceData{
+		Instance: webhookInstance{
+			ID:         instance.ID,
+			Subdomain:  instance.Subdomain,
+			URL:        instance.GetInstanceURL(),
+			Status:     instance.Status,
+			AppVersion: instance.AppVersion,
+			CreatedAt:  instance.CreatedAt.UTC(),
+		},
+		Error: errMsg,
+	}
+	s.publishWebhookEvent(ctx, instance.OrganizationID, event, data)
+}
+
+// publishSubscriptionUpdated publishes the current state of a subscription
+func (s *Service) publishSubscriptionUpdated(ctx context.Context, sub db.Subscription) {
+	domain := toDomainSubscription(sub)
+	data := webhookSubscriptionData{
+		Subscription: webhookSubscription{
+			ID:          domain.ID,
+			Status:      domain.Status,
+			Quantity:    domain.Quantity,
+			TrialEndsAt: domain.TrialEndsAt,
+			UpdatedAt:   domain.UpdatedAt.UTC(),
+		},
+	}
+	s.publishWebhookEvent(ctx, domain.OrganizationID, WebhookEventSubscriptionUpdated, data)
+}
+
+// deliverWebhook is the JobDeliverWebhook handler, it posts a signed event to its endpoint
+func (s *Service) deliverWebhook(ctx context.Context, job *jobs.Job) error {
+	var args deliverWebhookArgs
+	if err := job.Bind(&args); err != nil {
+		return err
+	}
+
+	queries := s.getDB()
+	delivery, err := queries.GetWebhookDelivery(ctx, args.DeliveryID)
+	if err != nil {
+		// The endpoint was deleted meanwhile
+		if db.IsNotFoundError(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+	if delivery.Status != WebhookDeliveryPending {
+		return nil
+	}
+
+	responseStatus, responseBody, sendErr := postWebhook(ctx, delivery)
+
+	attempt := db.RecordWebhookDeliveryAttemptParams{
+		ID:             delivery.ID,
+		Status:         WebhookDeliverySucceeded,
+		ResponseStatus: int32(responseStatus),
+		ResponseBody:   responseBody,
+	}
+	if sendErr != nil {
+		attempt.Status = WebhookDeliveryPending
+		if job.Attempt >= job.MaxAttempts {
+			attempt.Status = WebhookDeliveryFailed
+		}
+		attempt.Error = truncate(sendErr.Error(), 500)
+	}
+	if err := queries.RecordWebhookDeliveryAttempt(ctx, attempt); err != nil {
+		return fmt.Errorf("failed to record webhook delivery attempt: %w", err)
+	}
+
+	return sendErr
+}
+
+// postWebhook sends a delivery and returns the response status and the start of the body
+func postWebhook(ctx context.Context, delivery db.GetWebhookDeliveryRow) (int, string, error) {
+	body := []byte(delivery.Payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ranx.cloud-webhooks")
+	req.Header.Set("X-Ranx-Event", delivery.Event)
+	req.Header.Set("X-Ranx-Delivery", delivery.ID)
+	timestamp := time.Now().Unix()
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(delivery.Secret, timestamp, body))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	responseBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxWebhookResponseBody))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, string(responseBody), fmt.Errorf("endpoint returned HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, string(responseBody), nil
+}
+
+// SignWebhookPayload returns the signature of a delivery body sent at timestamp, receivers compute
+// the same to verify it. Each attempt is signed again, so retries carry a fresh timestamp.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// pruneWebhookDeliveries is the JobPruneWebhookDeliveries handler, it deletes old entries of the delivery log
+func (s *Service) pruneWebhookDeliveries(ctx context.Context, job *jobs.Job) error {
+	deleted, err := s.getDB().DeleteWebhookDeliveriesBefore(ctx, pgtype.Timestamp{Time: time.Now().Add(-webhookDeliveryRetention), Valid: true})
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook deliveries: %w", err)
+	}
+	appctx.GetLogger(ctx).Info("pruned webhook deliveries", "deleted", deleted)
+	return nil
+}
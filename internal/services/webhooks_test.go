@@ -0,0 +1,258 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aliuygur/n8n-saas-api/internal/apperrs"
+	"github.com/aliuygur/n8n-saas-api/internal/db"
+	"github.com/aliuygur/n8n-saas-api/internal/jobs"
+	"github.com/aliuygur/n8n-saas-api/internal/testdb"
+)
+
+// fakeWebhookReceiver routes the calls webhookClient makes to handler for the rest of the test
+func fakeWebhookReceiver(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport := webhookClient.Transport
+	webhookClient.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.URL.Scheme = target.Scheme
+		req.URL.Host = target.Host
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	t.Cleanup(func() { webhookClient.Transport = transport })
+}
+
+// createTestWebhookEndpoint registers an endpoint without validating its URL
+func createTestWebhookEndpoint(t *testing.T, s *Service, organizationID string, events ...string) db.WebhookEndpoint {
+	t.Helper()
+	endpoint, err := s.getDB().CreateWebhookEndpoint(testdb.Context(), db.CreateWebhookEndpointParams{
+		OrganizationID: organizationID,
+		Url:            "https://hooks.example.com/ranx",
+		Secret:         "whsec_test",
+		Events:         append([]string{}, events...),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create webhook endpoint: %v", err)
+	}
+	return endpoint
+}
+
+// publishTestWebhookEvent queues an instance.ready event and returns its delivery to the endpoint
+func publishTestWebhookEvent(t *testing.T, s *Service, member Membership, endpointID string) db.WebhookDelivery {
+	t.Helper()
+	ctx := testdb.Context()
+	if err := s.queueWebhookEvent(ctx, member.OrganizationID, WebhookEventInstanceReady, webhookInstanceData{}); err != nil {
+		t.Fatalf("Failed to queue webhook event: %v", err)
+	}
+	deliveries, err := s.getDB().ListWebhookDeliveries(ctx, db.ListWebhookDeliveriesParams{EndpointID: endpointID, MaxRows: 100})
+	if err != nil || len(deliveries) == 0 {
+		t.Fatalf("Expected a delivery, got %d %v", len(deliveries), err)
+	}
+	return deliveries[0]
+}
+
+func deliverTestWebhook(s *Service, deliveryID string, attempt int32) error {
+	args, _ := json.Marshal(deliverWebhookArgs{DeliveryID: deliveryID})
+	return s.deliverWebhook(testdb.Context(), &jobs.Job{Kind: JobDeliverWebhook, Args: args, Attempt: attempt, MaxAttempts: webhookMaxAttempts})
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	body := []byte(`{"id":"evt_1"}`)
+	const timestamp = 1767225600
+
+	// HMAC-SHA256 of `1767225600.{"id":"evt_1"}` keyed with whsec_test
+	want := "45b40331de0325606dc5400202ade162460fbe48daf9401adfdcd0d7b4f35470"
+	if got := SignWebhookPayload("whsec_test", timestamp, body); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	if SignWebhookPayload("whsec_test", timestamp+1, body) == want {
+		t.Error("Expected the signature to change with the timestamp")
+	}
+	if SignWebhookPayload("whsec_other", timestamp, body) == want {
+		t.Error("Expected the signature to change with the secret")
+	}
+}
+
+func TestListWebhookEndpointsForEvent(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := testdb.Context()
+	member := createTestUser(t, s, "hooked")
+	other := createTestUser(t, s, "other")
+
+	all := createTestWebhookEndpoint(t, s, member.OrganizationID)
+	ready := createTestWebhookEndpoint(t, s, member.OrganizationID, WebhookEventInstanceReady, WebhookEventInstanceFailed)
+	billing := createTestWebhookEndpoint(t, s, member.OrganizationID, WebhookEventSubscriptionUpdated)
+	createTestWebhookEndpoint(t, s, other.OrganizationID)
+
+	tests := []struct {
+		event string
+		want  []string
+	}{
+		{WebhookEventInstanceReady, []string{all.ID, ready.ID}},
+		{WebhookEventInstanceFailed, []string{all.ID, ready.ID}},
+		{WebhookEventSubscriptionUpdated, []string{all.ID, billing.ID}},
+		{WebhookEventInstanceDeleted, []string{all.ID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.event, func(t *testing.T) {
+			endpoints, err := s.getDB().ListWebhookEndpointsForEvent(ctx, db.ListWebhookEndpointsForEventParams{
+				OrganizationID: member.OrganizationID,
+				Event:          tt.event,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range endpoints {
+				got = append(got, e.ID)
+			}
+			slices.Sort(got)
+			want := slices.Sorted(slices.Values(tt.want))
+			if !slices.Equal(got, want) {
+				t.Errorf("Expected %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestDeliverWebhook(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		attempt    int32
+		wantErr    bool
+		wantStatus string
+	}{
+		{name: "delivered", status: http.StatusOK, attempt: 1, wantStatus: WebhookDeliverySucceeded},
+		{name: "receiver failing is retried", status: http.StatusInternalServerError, attempt: 1, wantErr: true, wantStatus: WebhookDeliveryPending},
+		{name: "last attempt marks it failed", status: http.StatusInternalServerError, attempt: webhookMaxAttempts, wantErr: true, wantStatus: WebhookDeliveryFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestService(t)
+			ctx := testdb.Context()
+			member := createTestUser(t, s, "hooked")
+			endpoint := createTestWebhookEndpoint(t, s, member.OrganizationID)
+			delivery := publishTestWebhookEvent(t, s, member, endpoint.ID)
+
+			var requests int
+			fakeWebhookReceiver(t, func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				body, _ := io.ReadAll(r.Body)
+				timestamp, err := strconv.ParseInt(r.Header.Get(WebhookTimestampHeader), 10, 64)
+				if err != nil || time.Since(time.Unix(timestamp, 0)) > time.Minute {
+					t.Errorf("Expected a current timestamp, got %q", r.Header.Get(WebhookTimestampHeader))
+				}
+				if r.Header.Get(WebhookSignatureHeader) != SignWebhookPayload(endpoint.Secret, timestamp, body) {
+					t.Error("Expected the timestamp and body to be signed")
+				}
+				if r.Header.Get("X-Ranx-Event") != WebhookEventInstanceReady || r.Header.Get("X-Ranx-Delivery") != delivery.ID {
+					t.Errorf("Unexpected headers %v", r.Header)
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte("received"))
+			})
+
+			if err := deliverTestWebhook(s, delivery.ID, tt.attempt); (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+
+			got, err := s.getDB().GetWebhookDelivery(ctx, delivery.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.wantStatus || got.Attempts != 1 || got.ResponseStatus != int32(tt.status) || got.ResponseBody != "received" {
+				t.Errorf("Expected %s after one attempt with HTTP %d, got %+v", tt.wantStatus, tt.status, got)
+			}
+			if got.DeliveredAt.Valid != (tt.wantStatus == WebhookDeliverySucceeded) {
+				t.Errorf("Expected delivered %v, got %v", tt.wantStatus == WebhookDeliverySucceeded, got.DeliveredAt)
+			}
+			if tt.wantErr && got.Error != "endpoint returned HTTP 500" {
+				t.Errorf("Expected the error to be kept, got %q", got.Error)
+			}
+
+			// A delivery that isn't pending anymore is not sent again
+			if tt.wantStatus != WebhookDeliveryPending {
+				if err := deliverTestWebhook(s, delivery.ID, tt.attempt); err != nil || requests != 1 {
+					t.Errorf("Expected the job to skip the delivery, got %v after %d requests", err, requests)
+				}
+			}
+
+			// Deliveries of a deleted endpoint are dropped
+			if err := s.DeleteWebhookEndpoint(ctx, member, endpoint.ID); err != nil {
+				t.Fatal(err)
+			}
+			if err := deliverTestWebhook(s, delivery.ID, tt.attempt); err != nil || requests != 1 {
+				t.Errorf("Expected the job to drop the delivery, got %v after %d requests", err, requests)
+			}
+		})
+	}
+}
+
+func TestRedeliverWebhook(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := testdb.Context()
+	member := createTestUser(t, s, "hooked")
+	other := createTestUser(t, s, "other")
+	endpoint := createTestWebhookEndpoint(t, s, member.OrganizationID)
+	original := publishTestWebhookEvent(t, s, member, endpoint.ID)
+
+	status := http.StatusInternalServerError
+	fakeWebhookReceiver(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	})
+	if err := deliverTestWebhook(s, original.ID, webhookMaxAttempts); err == nil {
+		t.Fatal("Expected the delivery to fail")
+	}
+
+	viewer := member
+	viewer.Role = RoleViewer
+	if _, err := s.RedeliverWebhook(ctx, viewer, original.ID); !apperrs.CodeIs(err, apperrs.CodeForbidden) {
+		t.Errorf("Expected viewers to be rejected, got %v", err)
+	}
+	if _, err := s.RedeliverWebhook(ctx, other, original.ID); !apperrs.CodeIs(err, apperrs.CodeNotFound) {
+		t.Errorf("Expected other organizations not to find the delivery, got %v", err)
+	}
+
+	redelivery, err := s.RedeliverWebhook(ctx, member, original.ID)
+	if err != nil {
+		t.Fatalf("Failed to redeliver webhook: %v", err)
+	}
+	if redelivery.ID == original.ID || redelivery.EventID != original.EventID || redelivery.Payload != original.Payload || redelivery.Status != WebhookDeliveryPending {
+		t.Errorf("Expected a new pending delivery of the same event, got %+v", redelivery)
+	}
+
+	status = http.StatusNoContent
+	if err := deliverTestWebhook(s, redelivery.ID, 1); err != nil {
+		t.Fatalf("Failed to deliver webhook: %v", err)
+	}
+	deliveries, err := s.ListWebhookDeliveries(ctx, member, endpoint.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := map[string]string{}
+	for _, d := range deliveries {
+		statuses[d.ID] = d.Status
+	}
+	if len(deliveries) != 2 || statuses[original.ID] != WebhookDeliveryFailed || statuses[redelivery.ID] != WebhookDeliverySucceeded {
+		t.Errorf("Expected the failed delivery and its successful redelivery, got %v", statuses)
+	}
+}
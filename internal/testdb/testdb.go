@@ -0,0 +1,95 @@
+// Package testdb gives tests a database of their own to run against
+package testdb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/aliuygur/n8n-saas-api/internal/appctx"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// New returns a pool on a fresh schema of the database in TEST_DATABASE_URL with every
+// migration applied, dropped when the test ends. Tests that need it are skipped without
+// a database, unless REQUIRE_TEST_DATABASE is set, as make test-db and CI do.
+func New(t testing.TB) *pgxpool.Pool {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		if os.Getenv("REQUIRE_TEST_DATABASE") != "" {
+			t.Fatal("TEST_DATABASE_URL is not set")
+		}
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	schema := "test_" + hex.EncodeToString(b)
+
+	admin, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatalf("Failed to connect to the test database: %v", err)
+	}
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		admin.Close()
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+		admin.Close()
+	})
+
+	poolConfig, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	poolConfig.ConnConfig.RuntimeParams["search_path"] = schema + ", public"
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		t.Fatalf("Failed to connect to the test schema: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	migrations, err := filepath.Glob(filepath.Join(migrationsDir(), "*.up.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("No migrations found")
+	}
+	slices.Sort(migrations)
+	for _, path := range migrations {
+		sql, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Without arguments the statements of a file are sent together
+		if _, err := pool.Exec(ctx, string(sql)); err != nil {
+			t.Fatalf("Failed to apply %s: %v", filepath.Base(path), err)
+		}
+	}
+
+	return pool
+}
+
+// Context returns a context with the logger services and jobs read from requests
+func Context() context.Context {
+	return appctx.WithLogger(context.Background(), slog.New(slog.DiscardHandler))
+}
+
+// migrationsDir is the migrations directory of the repository, wherever the test runs from
+func migrationsDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "migrations")
+}
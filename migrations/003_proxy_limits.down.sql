@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS instance_limit_overrides;
+DROP TABLE IF EXISTS plan_limits;
@@ -0,0 +1,29 @@
+-- Rate limits applied by the tenant proxy, one row per plan
+CREATE TABLE plan_limits (
+    plan VARCHAR PRIMARY KEY,
+    requests_per_second INTEGER NOT NULL,     -- Token bucket refill rate per instance
+    burst INTEGER NOT NULL,                   -- Token bucket size per instance
+    ip_requests_per_second INTEGER NOT NULL,  -- Token bucket refill rate per client IP per instance
+    ip_burst INTEGER NOT NULL,                -- Token bucket size per client IP per instance
+    max_body_bytes BIGINT NOT NULL,           -- Maximum request body size (0 = unlimited)
+    max_concurrent INTEGER NOT NULL,          -- Maximum in-flight requests per instance (0 = unlimited)
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO plan_limits (plan, requests_per_second, burst, ip_requests_per_second, ip_burst, max_body_bytes, max_concurrent) VALUES
+    ('trial', 10, 20, 5, 10, 16777216, 20),
+    ('standard', 50, 100, 20, 40, 67108864, 100);
+
+-- Per-instance overrides set by admins, NULL columns fall back to the plan limits
+CREATE TABLE instance_limit_overrides (
+    instance_id UUID PRIMARY KEY REFERENCES instances(id) ON DELETE CASCADE,
+    requests_per_second INTEGER,
+    burst INTEGER,
+    ip_requests_per_second INTEGER,
+    ip_burst INTEGER,
+    max_body_bytes BIGINT,
+    max_concurrent INTEGER,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
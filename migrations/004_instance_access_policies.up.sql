@@ -0,0 +1,13 @@
+-- Access policies enforced by the tenant proxy in front of the n8n editor
+CREATE TABLE instance_access_policies (
+    instance_id UUID PRIMARY KEY REFERENCES instances(id) ON DELETE CASCADE,
+    allowed_cidrs TEXT[] NOT NULL DEFAULT '{}',  -- Empty allows any client address
+    auth_mode VARCHAR NOT NULL DEFAULT 'none',
+    basic_auth_username VARCHAR NOT NULL DEFAULT '',
+    basic_auth_password_hash VARCHAR NOT NULL DEFAULT '',
+    public_paths TEXT[] NOT NULL DEFAULT '{}',   -- Path prefixes that bypass the policy (webhooks, forms)
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Auth mode can be: 'none', 'basic', 'ranx'
@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS plan_limits_notify_change ON plan_limits;
+DROP TRIGGER IF EXISTS subscriptions_notify_change ON subscriptions;
+DROP TRIGGER IF EXISTS instance_limit_overrides_notify_change ON instance_limit_overrides;
+DROP TRIGGER IF EXISTS instance_access_policies_notify_change ON instance_access_policies;
+DROP TRIGGER IF EXISTS instances_notify_change ON instances;
+DROP FUNCTION IF EXISTS notify_plan_limits_change();
+DROP FUNCTION IF EXISTS notify_subscription_change();
+DROP FUNCTION IF EXISTS notify_instance_settings_change();
+DROP FUNCTION IF EXISTS notify_instance_change();
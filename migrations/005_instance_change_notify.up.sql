@@ -0,0 +1,80 @@
+-- Notify app replicas when routing data for a subdomain changes so they can evict
+-- their in-memory tenant cache. The payload is a subdomain, or '*' to flush everything.
+
+CREATE OR REPLACE FUNCTION notify_instance_change() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        PERFORM pg_notify('instance_changes', OLD.subdomain);
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') AND (TG_OP = 'INSERT' OR NEW.subdomain <> OLD.subdomain) THEN
+        PERFORM pg_notify('instance_changes', NEW.subdomain);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER instances_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON instances
+    FOR EACH ROW EXECUTE FUNCTION notify_instance_change();
+
+-- Per-instance settings tables keyed by instance_id
+CREATE OR REPLACE FUNCTION notify_instance_settings_change() RETURNS trigger AS $$
+DECLARE
+    changed_instance_id UUID;
+    changed_subdomain VARCHAR;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        changed_instance_id := OLD.instance_id;
+    ELSE
+        changed_instance_id := NEW.instance_id;
+    END IF;
+
+    SELECT subdomain INTO changed_subdomain FROM instances WHERE id = changed_instance_id;
+    IF changed_subdomain IS NOT NULL THEN
+        PERFORM pg_notify('instance_changes', changed_subdomain);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER instance_access_policies_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON instance_access_policies
+    FOR EACH ROW EXECUTE FUNCTION notify_instance_settings_change();
+
+CREATE TRIGGER instance_limit_overrides_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON instance_limit_overrides
+    FOR EACH ROW EXECUTE FUNCTION notify_instance_settings_change();
+
+-- Subscription status decides the plan limits of all instances of the user
+CREATE OR REPLACE FUNCTION notify_subscription_change() RETURNS trigger AS $$
+DECLARE
+    changed_subdomain VARCHAR;
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.status = OLD.status THEN
+        RETURN NULL;
+    END IF;
+
+    FOR changed_subdomain IN
+        SELECT subdomain FROM instances WHERE user_id = NEW.user_id AND deleted_at IS NULL
+    LOOP
+        PERFORM pg_notify('instance_changes', changed_subdomain);
+    END LOOP;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER subscriptions_notify_change
+    AFTER INSERT OR UPDATE ON subscriptions
+    FOR EACH ROW EXECUTE FUNCTION notify_subscription_change();
+
+-- Plan limits apply to many instances at once
+CREATE OR REPLACE FUNCTION notify_plan_limits_change() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('instance_changes', '*');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER plan_limits_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON plan_limits
+    FOR EACH STATEMENT EXECUTE FUNCTION notify_plan_limits_change();
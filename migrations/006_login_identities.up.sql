@@ -0,0 +1,25 @@
+-- External identities users sign in with, linked to users by verified email
+CREATE TABLE user_identities (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v7(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR NOT NULL,   -- 'google', 'github', 'microsoft', 'email'
+    subject VARCHAR NOT NULL,    -- Stable user ID at the provider
+    email VARCHAR NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX user_identities_provider_subject_key ON user_identities(provider, subject);
+CREATE INDEX idx_user_identities_user_id ON user_identities(user_id);
+
+-- Single use tokens for passwordless email login, only the SHA-256 of the token is stored
+CREATE TABLE magic_link_tokens (
+    token_hash VARCHAR PRIMARY KEY,
+    email VARCHAR NOT NULL,
+    return_to VARCHAR NOT NULL DEFAULT '',
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_magic_link_tokens_email_created_at ON magic_link_tokens(email, created_at);
@@ -0,0 +1,14 @@
+-- Server-side login sessions, the session cookie holds a random token and only its SHA-256 is stored
+CREATE TABLE sessions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v7(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR NOT NULL UNIQUE,
+    user_agent VARCHAR NOT NULL DEFAULT '',
+    ip_address VARCHAR NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,          -- Slides forward while the session is used
+    absolute_expires_at TIMESTAMP NOT NULL  -- Hard limit regardless of activity
+);
+
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
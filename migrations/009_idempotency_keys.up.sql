@@ -0,0 +1,14 @@
+-- Responses of API requests sent with an Idempotency-Key header, replayed when the request is retried.
+-- response_status is NULL while the first request is still being handled.
+CREATE TABLE idempotency_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v7(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    idempotency_key VARCHAR NOT NULL,
+    request_hash VARCHAR NOT NULL,   -- SHA-256 of method, path and body, a reused key must match
+    response_status INTEGER,
+    response_headers JSONB,
+    response_body BYTEA,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+    UNIQUE (user_id, idempotency_key)
+);
@@ -0,0 +1 @@
+ALTER TABLE instances DROP COLUMN IF EXISTS custom_env;
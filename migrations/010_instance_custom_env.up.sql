@@ -0,0 +1,2 @@
+-- Environment variables set by the user on the n8n container, on top of the ones we manage
+ALTER TABLE instances ADD COLUMN custom_env JSONB NOT NULL DEFAULT '{}';
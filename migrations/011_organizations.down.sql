@@ -0,0 +1,24 @@
+CREATE OR REPLACE FUNCTION notify_subscription_change() RETURNS trigger AS $$
+DECLARE
+    changed_subdomain VARCHAR;
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.status = OLD.status THEN
+        RETURN NULL;
+    END IF;
+
+    FOR changed_subdomain IN
+        SELECT subdomain FROM instances WHERE user_id = NEW.user_id AND deleted_at IS NULL
+    LOOP
+        PERFORM pg_notify('instance_changes', changed_subdomain);
+    END LOOP;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE api_tokens DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE instances DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE users DROP COLUMN IF EXISTS current_organization_id;
+DROP TABLE IF EXISTS organization_invitations;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,86 @@
+-- Organizations own instances, subscriptions and API tokens, users take part through memberships.
+-- Every user has a personal organization, created at signup.
+CREATE TABLE organizations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v7(),
+    name VARCHAR NOT NULL,
+    personal BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Roles: 'owner' manages billing and members, 'admin' manages instances, 'viewer' can only look
+CREATE TABLE organization_members (
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX idx_organization_members_user_id ON organization_members(user_id);
+
+-- Pending invitations, only the SHA-256 of the emailed token is stored
+CREATE TABLE organization_invitations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v7(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email VARCHAR NOT NULL,
+    role VARCHAR NOT NULL,
+    token_hash VARCHAR NOT NULL UNIQUE,
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_organization_invitations_email ON organization_invitations(organization_id, email);
+
+-- The organization the dashboard shows, switched by the user
+ALTER TABLE users ADD COLUMN current_organization_id UUID REFERENCES organizations(id) ON DELETE SET NULL;
+
+-- instances.user_id and subscriptions.user_id now record who created the instance or started the subscription
+ALTER TABLE instances ADD COLUMN organization_id UUID REFERENCES organizations(id);
+ALTER TABLE subscriptions ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+ALTER TABLE api_tokens ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+
+-- Move everything a user owned into their personal organization
+DO $$
+DECLARE
+    u RECORD;
+    org_id UUID;
+BEGIN
+    FOR u IN SELECT id, name, email FROM users LOOP
+        INSERT INTO organizations (name, personal) VALUES (COALESCE(NULLIF(u.name, ''), u.email), TRUE)
+        RETURNING id INTO org_id;
+
+        INSERT INTO organization_members (organization_id, user_id, role) VALUES (org_id, u.id, 'owner');
+
+        UPDATE users SET current_organization_id = org_id WHERE id = u.id;
+        UPDATE instances SET organization_id = org_id WHERE user_id = u.id;
+        UPDATE subscriptions SET organization_id = org_id WHERE user_id = u.id;
+        UPDATE api_tokens SET organization_id = org_id WHERE user_id = u.id;
+    END LOOP;
+END $$;
+
+ALTER TABLE instances ALTER COLUMN organization_id SET NOT NULL;
+ALTER TABLE subscriptions ALTER COLUMN organization_id SET NOT NULL;
+ALTER TABLE api_tokens ALTER COLUMN organization_id SET NOT NULL;
+
+CREATE INDEX idx_instances_organization_id ON instances(organization_id);
+CREATE UNIQUE INDEX idx_subscriptions_organization_id ON subscriptions(organization_id);
+
+-- Plan limits follow the subscription of the organization owning the instance
+CREATE OR REPLACE FUNCTION notify_subscription_change() RETURNS trigger AS $$
+DECLARE
+    changed_subdomain VARCHAR;
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.status = OLD.status THEN
+        RETURN NULL;
+    END IF;
+
+    FOR changed_subdomain IN
+        SELECT subdomain FROM instances WHERE organization_id = NEW.organization_id AND deleted_at IS NULL
+    LOOP
+        PERFORM pg_notify('instance_changes', changed_subdomain);
+    END LOOP;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
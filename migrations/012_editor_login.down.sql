@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS editor_login_tokens;
+ALTER TABLE instances DROP COLUMN IF EXISTS owner_setup_at;
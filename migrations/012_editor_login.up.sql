@@ -0,0 +1,13 @@
+-- Set once the n8n owner account ranx logs in with has been created on the instance
+ALTER TABLE instances ADD COLUMN owner_setup_at TIMESTAMP;
+
+-- Single use tokens that log a dashboard user into the n8n editor of an instance
+CREATE TABLE editor_login_tokens (
+    token_hash VARCHAR PRIMARY KEY,
+    instance_id UUID NOT NULL REFERENCES instances(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    return_to VARCHAR NOT NULL DEFAULT '',
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,7 @@
+ALTER TABLE organizations DROP COLUMN IF EXISTS require_two_factor;
+DROP TABLE IF EXISTS user_recovery_codes;
+ALTER TABLE users DROP COLUMN IF EXISTS two_factor_locked_until;
+ALTER TABLE users DROP COLUMN IF EXISTS two_factor_failures;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_last_step;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_enabled_at;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_secret;
@@ -0,0 +1,20 @@
+-- TOTP second factor of dashboard accounts. The secret is pending until the user confirms a code.
+ALTER TABLE users ADD COLUMN totp_secret VARCHAR;
+ALTER TABLE users ADD COLUMN totp_enabled_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0;  -- Last accepted time step, codes can't be replayed
+ALTER TABLE users ADD COLUMN two_factor_failures INT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN two_factor_locked_until TIMESTAMP;
+
+-- Single use recovery codes for a lost authenticator, only the SHA-256 of a code is stored
+CREATE TABLE user_recovery_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v7(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_user_recovery_codes_user_id ON user_recovery_codes(user_id);
+
+-- Members of organizations that require it must enable two-factor authentication to act in them
+ALTER TABLE organizations ADD COLUMN require_two_factor BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+-- Deleted accounts keep their row so subscriptions and instances still point somewhere,
+-- but the email and name are scrubbed and the email is freed for a new signup
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP;
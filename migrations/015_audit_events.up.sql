@@ -0,0 +1,29 @@
+-- Append-only record of who did what. Actors and organizations are plain references,
+-- so events outlive what they describe.
+CREATE TABLE audit_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v7(),
+    organization_id UUID,           -- NULL for events of the account itself, such as logins
+    actor_type VARCHAR NOT NULL,    -- 'user', 'system' or 'lemonsqueezy'
+    actor_user_id UUID,
+    action VARCHAR NOT NULL,        -- e.g. 'instance.delete'
+    target_type VARCHAR NOT NULL DEFAULT '',
+    target_id VARCHAR NOT NULL DEFAULT '',
+    metadata JSONB NOT NULL DEFAULT '{}',
+    ip_address VARCHAR NOT NULL DEFAULT '',
+    user_agent VARCHAR NOT NULL DEFAULT '',
+    request_id VARCHAR NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_audit_events_organization_id_created_at ON audit_events(organization_id, created_at DESC);
+CREATE INDEX idx_audit_events_actor_user_id_created_at ON audit_events(actor_user_id, created_at DESC);
+
+CREATE OR REPLACE FUNCTION reject_audit_event_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit events are append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+BEFORE UPDATE OR DELETE ON audit_events
+FOR EACH ROW EXECUTE FUNCTION reject_audit_event_change();
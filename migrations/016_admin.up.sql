@@ -0,0 +1,2 @@
+-- Operators of ranx, granted by hand: UPDATE users SET is_admin = TRUE WHERE email = '...';
+ALTER TABLE users ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_subscriptions_trial_ends_at;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS trial_enforcement_claimed_at;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS trial_enforced_at;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS trial_ended_notice_sent_at;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS trial_warning_sent_at;
@@ -0,0 +1,9 @@
+-- Progress of the trial expiry job, so each step happens once per trial.
+-- Extending a trial clears them.
+ALTER TABLE subscriptions ADD COLUMN trial_warning_sent_at TIMESTAMP;
+ALTER TABLE subscriptions ADD COLUMN trial_ended_notice_sent_at TIMESTAMP;
+ALTER TABLE subscriptions ADD COLUMN trial_enforced_at TIMESTAMP;
+-- Set while a run stops the instances of the trial, so no other run does it at the same time
+ALTER TABLE subscriptions ADD COLUMN trial_enforcement_claimed_at TIMESTAMP;
+
+CREATE INDEX idx_subscriptions_trial_ends_at ON subscriptions(trial_ends_at) WHERE status = 'trial';
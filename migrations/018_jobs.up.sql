@@ -0,0 +1,31 @@
+-- Background work shared by all app replicas. Workers claim pending jobs with FOR UPDATE SKIP LOCKED
+-- and hold them for a lease they keep extending, so the job of a crashed replica is picked up again.
+CREATE TABLE jobs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v7(),
+    kind VARCHAR NOT NULL,             -- e.g. 'trials.expire'
+    args JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR NOT NULL DEFAULT 'pending', -- 'pending', 'running', 'succeeded' or 'failed'
+    unique_key VARCHAR,                -- At most one pending or running job per kind and key
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    run_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMP,            -- Lease of the worker running the job
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX idx_jobs_pending_run_at ON jobs(run_at) WHERE status = 'pending';
+CREATE INDEX idx_jobs_running_locked_until ON jobs(locked_until) WHERE status = 'running';
+CREATE INDEX idx_jobs_finished_at ON jobs(finished_at) WHERE finished_at IS NOT NULL;
+CREATE UNIQUE INDEX idx_jobs_unique_key ON jobs(kind, unique_key)
+    WHERE unique_key IS NOT NULL AND status IN ('pending', 'running');
+
+-- When each recurring job runs next. The replica that locks a due row enqueues the run.
+CREATE TABLE job_schedules (
+    kind VARCHAR PRIMARY KEY,
+    spec VARCHAR NOT NULL,             -- Cron expression or @every <duration>
+    next_run_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,26 @@
+-- Emails sent to users. They are rendered when queued, so the log shows exactly what was sent.
+CREATE TABLE notifications (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v7(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR NOT NULL,             -- e.g. 'instance.ready'
+    recipient VARCHAR NOT NULL,
+    subject VARCHAR NOT NULL,
+    text_body TEXT NOT NULL,
+    html_body TEXT NOT NULL,
+    status VARCHAR NOT NULL DEFAULT 'pending', -- 'pending', 'sent' or 'failed'
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMP
+);
+
+CREATE INDEX idx_notifications_user_id_created_at ON notifications(user_id, created_at DESC);
+CREATE INDEX idx_notifications_created_at ON notifications(created_at DESC);
+
+-- Notifications a user turned off or back on, kinds without a row are sent
+CREATE TABLE notification_preferences (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, kind)
+);
@@ -0,0 +1,25 @@
+-- Health of each instance as seen by the uptime monitor, which probes n8n inside the cluster
+CREATE TABLE instance_monitors (
+    instance_id UUID PRIMARY KEY REFERENCES instances(id) ON DELETE CASCADE,
+    status VARCHAR NOT NULL DEFAULT 'unknown', -- 'unknown', 'up' or 'down'
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    failing_since TIMESTAMP,                   -- First failed check of the current outage
+    last_error TEXT NOT NULL DEFAULT '',
+    last_checked_at TIMESTAMP,
+    status_changed_at TIMESTAMP,
+    alert_webhook_url VARCHAR NOT NULL DEFAULT '', -- Slack incoming webhook or any URL taking a JSON POST
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Every probe of an instance, the uptime percentages are computed from these
+CREATE TABLE instance_health_checks (
+    instance_id UUID NOT NULL REFERENCES instances(id) ON DELETE CASCADE,
+    checked_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    up BOOLEAN NOT NULL,
+    latency_ms INTEGER NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (instance_id, checked_at)
+);
+
+CREATE INDEX idx_instance_health_checks_checked_at ON instance_health_checks(checked_at);
@@ -0,0 +1,32 @@
+-- URLs an organization registered to receive platform events, deliveries are signed with the secret
+CREATE TABLE webhook_endpoints (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v7(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    url VARCHAR NOT NULL,
+    secret VARCHAR NOT NULL,
+    events TEXT[] NOT NULL DEFAULT '{}', -- e.g. 'instance.created', empty receives every event
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_endpoints_organization_id ON webhook_endpoints(organization_id);
+
+-- An event sent to an endpoint with the outcome of its last attempt. Redelivering an event adds a delivery.
+CREATE TABLE webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v7(),
+    endpoint_id UUID NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+    event_id VARCHAR NOT NULL,         -- Shared by redeliveries, so receivers can skip duplicates
+    event VARCHAR NOT NULL,
+    payload TEXT NOT NULL,             -- The exact body that is signed and sent
+    status VARCHAR NOT NULL DEFAULT 'pending', -- 'pending', 'succeeded' or 'failed'
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_status INTEGER NOT NULL DEFAULT 0,
+    response_body TEXT NOT NULL DEFAULT '', -- Start of the last response
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_attempt_at TIMESTAMP,
+    delivered_at TIMESTAMP
+);
+
+CREATE INDEX idx_webhook_deliveries_endpoint_id_created_at ON webhook_deliveries(endpoint_id, created_at DESC);
+CREATE INDEX idx_webhook_deliveries_created_at ON webhook_deliveries(created_at);
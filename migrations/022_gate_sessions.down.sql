@@ -0,0 +1 @@
+ALTER TABLE editor_login_tokens DROP COLUMN IF EXISTS session_id;
@@ -0,0 +1,3 @@
+-- Editor logins hand the dashboard session to the gate cookie of the instance,
+-- which stops working once that session ends
+ALTER TABLE editor_login_tokens ADD COLUMN session_id UUID REFERENCES sessions(id) ON DELETE CASCADE;
@@ -0,0 +1,22 @@
+-- Audit events stay append-only, except that the IP address and user agent of a deleted
+-- account can be cleared. Every other column must keep its value.
+CREATE OR REPLACE FUNCTION reject_audit_event_change() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE'
+       AND NEW.ip_address = '' AND NEW.user_agent = ''
+       AND NEW.id = OLD.id
+       AND NEW.organization_id IS NOT DISTINCT FROM OLD.organization_id
+       AND NEW.actor_type = OLD.actor_type
+       AND NEW.actor_user_id IS NOT DISTINCT FROM OLD.actor_user_id
+       AND NEW.action = OLD.action
+       AND NEW.target_type = OLD.target_type
+       AND NEW.target_id = OLD.target_id
+       AND NEW.metadata = OLD.metadata
+       AND NEW.request_id = OLD.request_id
+       AND NEW.created_at = OLD.created_at
+    THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit events are append-only';
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS gate_exchange_tokens;
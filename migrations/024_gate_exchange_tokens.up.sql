@@ -0,0 +1,9 @@
+-- Tokens that hand a dashboard session over to the ranx login of an instance. The signed token
+-- carries the ID, which can only be exchanged once so a leaked URL can't be replayed.
+CREATE TABLE gate_exchange_tokens (
+    id VARCHAR PRIMARY KEY,
+    instance_id UUID NOT NULL REFERENCES instances(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
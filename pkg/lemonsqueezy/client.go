@@ -162,6 +162,35 @@ func (c *Client) UpdateSubscriptionItemQuantity(ctx context.Context, subscriptio
 	return nil
 }
 
+// CancelSubscription cancels a subscription by ID. LemonSqueezy keeps it running
+// until the end of the billing period and stops renewing it.
+func (c *Client) CancelSubscription(ctx context.Context, subscriptionID string) error {
+	url := fmt.Sprintf("%s/subscriptions/%s", baseURL, subscriptionID)
+	req, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	c.setHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
 func (c *Client) setHeaders(req *http.Request) {
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
 	req.Header.Set("Accept", "application/vnd.api+json")
@@ -0,0 +1,133 @@
+// Code generated by ranxapi-gen from api/openapi.json. DO NOT EDIT.
+
+package ranxapi
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// CreateInstanceRequest is the CreateInstanceRequest schema of the API
+type CreateInstanceRequest struct {
+	Subdomain string `json:"subdomain"`
+}
+
+// Instance is the Instance schema of the API
+type Instance struct {
+	AppVersion string            `json:"app_version"`
+	CreatedAt  time.Time         `json:"created_at"`
+	DeployedAt *time.Time        `json:"deployed_at,omitempty"`
+	Env        map[string]string `json:"env"`
+	ID         string            `json:"id"`
+	Status     string            `json:"status"`
+	Subdomain  string            `json:"subdomain"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	URL        string            `json:"url"`
+}
+
+// InstanceList is the InstanceList schema of the API
+type InstanceList struct {
+	Instances []Instance `json:"instances"`
+}
+
+// InstanceLogs is the InstanceLogs schema of the API
+type InstanceLogs struct {
+	Lines []string `json:"lines"`
+}
+
+// Subscription is the Subscription schema of the API
+type Subscription struct {
+	CreatedAt   time.Time  `json:"created_at"`
+	Quantity    int32      `json:"quantity"`
+	Status      string     `json:"status"`
+	Trial       bool       `json:"trial"`
+	TrialEndsAt *time.Time `json:"trial_ends_at,omitempty"`
+}
+
+// UpdateInstanceRequest is the UpdateInstanceRequest schema of the API
+type UpdateInstanceRequest struct {
+	AppVersion string            `json:"app_version"`
+	Env        map[string]string `json:"env"`
+}
+
+// CreateInstance calls POST /api/v1/instances
+//
+// Create an instance, provisioning continues in the background
+func (c *Client) CreateInstance(ctx context.Context, body CreateInstanceRequest) (*Instance, error) {
+	var out Instance
+	if err := c.do(ctx, http.MethodPost, "/api/v1/instances", body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteInstance calls DELETE /api/v1/instances/{id}
+//
+// Delete an instance and its data
+func (c *Client) DeleteInstance(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/instances/"+url.PathEscape(id), nil, nil)
+}
+
+// GetInstance calls GET /api/v1/instances/{id}
+//
+// Get an instance
+func (c *Client) GetInstance(ctx context.Context, id string) (*Instance, error) {
+	var out Instance
+	if err := c.do(ctx, http.MethodGet, "/api/v1/instances/"+url.PathEscape(id), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetInstanceLogs calls GET /api/v1/instances/{id}/logs
+//
+// Get the last 500 log lines of an instance
+func (c *Client) GetInstanceLogs(ctx context.Context, id string) (*InstanceLogs, error) {
+	var out InstanceLogs
+	if err := c.do(ctx, http.MethodGet, "/api/v1/instances/"+url.PathEscape(id)+"/logs", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSubscription calls GET /api/v1/subscription
+//
+// Get your subscription
+func (c *Client) GetSubscription(ctx context.Context) (*Subscription, error) {
+	var out Subscription
+	if err := c.do(ctx, http.MethodGet, "/api/v1/subscription", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListInstances calls GET /api/v1/instances
+//
+// List your instances
+func (c *Client) ListInstances(ctx context.Context) (*InstanceList, error) {
+	var out InstanceList
+	if err := c.do(ctx, http.MethodGet, "/api/v1/instances", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RestartInstance calls POST /api/v1/instances/{id}/restart
+//
+// Restart the n8n pods of an instance
+func (c *Client) RestartInstance(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPost, "/api/v1/instances/"+url.PathEscape(id)+"/restart", nil, nil)
+}
+
+// UpdateInstance calls PUT /api/v1/instances/{id}
+//
+// Set the n8n version and custom environment of an instance, unchanged values are a no-op
+func (c *Client) UpdateInstance(ctx context.Context, id string, body UpdateInstanceRequest) (*Instance, error) {
+	var out Instance
+	if err := c.do(ctx, http.MethodPut, "/api/v1/instances/"+url.PathEscape(id), body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,162 @@
+package ranxapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/aliuygur/n8n-saas-api/pkg/ranxapi/internal/codegen"
+)
+
+const specPath = "../../api/openapi.json"
+
+func TestGeneratedClientUpToDate(t *testing.T) {
+	spec, err := os.ReadFile(specPath)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", specPath, err)
+	}
+	want, err := codegen.Generate(spec)
+	if err != nil {
+		t.Fatalf("Failed to generate client: %v", err)
+	}
+	got, err := os.ReadFile("client_gen.go")
+	if err != nil {
+		t.Fatalf("Failed to read client_gen.go: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("client_gen.go is out of date, run go generate ./pkg/ranxapi")
+	}
+}
+
+type recordedRequest struct {
+	method, path, authorization, idempotencyKey, body string
+}
+
+func TestClientRequests(t *testing.T) {
+	var got recordedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = recordedRequest{r.Method, r.URL.EscapedPath(), r.Header.Get("Authorization"), r.Header.Get("Idempotency-Key"), string(body)}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"inst-1","instances":[{"id":"inst-1"}],"status":"active"}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(Config{Token: "ranx_test", BaseURL: server.URL + "/"})
+	ctx := context.Background()
+
+	// One case per operation, keyed by operationId
+	tests := map[string]struct {
+		call       func() error
+		wantMethod string
+		wantPath   string
+		wantBody   string
+		wantKey    string
+	}{
+		"ListInstances": {
+			call:       func() error { _, err := c.ListInstances(ctx); return err },
+			wantMethod: http.MethodGet, wantPath: "/api/v1/instances",
+		},
+		"CreateInstance": {
+			call: func() error {
+				_, err := c.CreateInstance(WithIdempotencyKey(ctx, "create-acme"), CreateInstanceRequest{Subdomain: "acme"})
+				return err
+			},
+			wantMethod: http.MethodPost, wantPath: "/api/v1/instances", wantBody: `{"subdomain":"acme"}`, wantKey: "create-acme",
+		},
+		"UpdateInstance": {
+			call: func() error {
+				_, err := c.UpdateInstance(ctx, "inst-1", UpdateInstanceRequest{AppVersion: "2.1.4", Env: map[string]string{"TZ": "UTC"}})
+				return err
+			},
+			wantMethod: http.MethodPut, wantPath: "/api/v1/instances/inst-1", wantBody: `{"app_version":"2.1.4","env":{"TZ":"UTC"}}`,
+		},
+		"GetInstance": {
+			call:       func() error { _, err := c.GetInstance(ctx, "inst/1"); return err },
+			wantMethod: http.MethodGet, wantPath: "/api/v1/instances/inst%2F1",
+		},
+		"DeleteInstance": {
+			call:       func() error { return c.DeleteInstance(ctx, "inst-1") },
+			wantMethod: http.MethodDelete, wantPath: "/api/v1/instances/inst-1",
+		},
+		"RestartInstance": {
+			call:       func() error { return c.RestartInstance(ctx, "inst-1") },
+			wantMethod: http.MethodPost, wantPath: "/api/v1/instances/inst-1/restart",
+		},
+		"GetInstanceLogs": {
+			call:       func() error { _, err := c.GetInstanceLogs(ctx, "inst-1"); return err },
+			wantMethod: http.MethodGet, wantPath: "/api/v1/instances/inst-1/logs",
+		},
+		"GetSubscription": {
+			call:       func() error { _, err := c.GetSubscription(ctx); return err },
+			wantMethod: http.MethodGet, wantPath: "/api/v1/subscription",
+		},
+	}
+
+	// Fail when the spec gains an operation this test doesn't exercise
+	spec, err := os.ReadFile(specPath)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", specPath, err)
+	}
+	var doc struct {
+		Paths map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		t.Fatalf("Failed to parse %s: %v", specPath, err)
+	}
+	for _, ops := range doc.Paths {
+		for _, op := range ops {
+			if _, ok := tests[op.OperationID]; !ok {
+				t.Errorf("Operation %s has no client test", op.OperationID)
+			}
+		}
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := tt.call(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got.method != tt.wantMethod || got.path != tt.wantPath {
+				t.Errorf("Expected %s %s, got %s %s", tt.wantMethod, tt.wantPath, got.method, got.path)
+			}
+			if got.authorization != "Bearer ranx_test" {
+				t.Errorf("Expected bearer token, got %q", got.authorization)
+			}
+			if got.idempotencyKey != tt.wantKey {
+				t.Errorf("Expected Idempotency-Key %q, got %q", tt.wantKey, got.idempotencyKey)
+			}
+			if got.body != tt.wantBody {
+				t.Errorf("Expected body %q, got %q", tt.wantBody, got.body)
+			}
+		})
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte(`{"error":{"code":"Conflict","message":"subdomain already taken"}}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(Config{Token: "ranx_test", BaseURL: server.URL})
+	_, err := c.CreateInstance(context.Background(), CreateInstanceRequest{Subdomain: "acme"})
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected *Error, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusConflict || apiErr.Code != "Conflict" || apiErr.Message != "subdomain already taken" {
+		t.Errorf("Unexpected error %+v", apiErr)
+	}
+}
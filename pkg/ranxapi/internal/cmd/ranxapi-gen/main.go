@@ -0,0 +1,34 @@
+// Command ranxapi-gen writes the generated part of the ranxapi client, run via go generate
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/aliuygur/n8n-saas-api/pkg/ranxapi/internal/codegen"
+)
+
+func main() {
+	specPath := flag.String("spec", "", "path to the OpenAPI document")
+	outPath := flag.String("out", "", "path of the generated Go file")
+	flag.Parse()
+
+	if *specPath == "" || *outPath == "" {
+		log.Fatal("-spec and -out are required")
+	}
+
+	spec, err := os.ReadFile(*specPath)
+	if err != nil {
+		log.Fatalf("Failed to read spec: %v", err)
+	}
+
+	src, err := codegen.Generate(spec)
+	if err != nil {
+		log.Fatalf("Failed to generate client: %v", err)
+	}
+
+	if err := os.WriteFile(*outPath, src, 0o644); err != nil {
+		log.Fatalf("Failed to write %s: %v", *outPath, err)
+	}
+}
@@ -0,0 +1,311 @@
+// Package codegen generates the ranxapi types and methods from the OpenAPI document
+package codegen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// handwritten are schemas implemented in client.go instead of generated
+var handwritten = map[string]bool{"Error": true, "ErrorDetail": true}
+
+// initialisms are written in upper case in Go names
+var initialisms = map[string]bool{"id": true, "url": true, "api": true}
+
+type spec struct {
+	Paths      map[string]map[string]operation `json:"paths"`
+	Components struct {
+		Schemas map[string]schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type operation struct {
+	OperationID string `json:"operationId"`
+	Summary     string `json:"summary"`
+	Parameters  []struct {
+		Name string `json:"name"`
+		In   string `json:"in"`
+	} `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]struct {
+			Schema schema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content map[string]struct {
+			Schema schema `json:"schema"`
+		} `json:"content"`
+	} `json:"responses"`
+}
+
+type schema struct {
+	Ref                  string            `json:"$ref"`
+	Type                 string            `json:"type"`
+	Format               string            `json:"format"`
+	Nullable             bool              `json:"nullable"`
+	AllOf                []schema          `json:"allOf"`
+	Items                *schema           `json:"items"`
+	Properties           map[string]schema `json:"properties"`
+	Required             []string          `json:"required"`
+	AdditionalProperties json.RawMessage   `json:"additionalProperties"`
+}
+
+// Generate returns the gofmt'd Go source of the client types and methods described by an OpenAPI document
+func Generate(specJSON []byte) ([]byte, error) {
+	var s spec
+	if err := json.Unmarshal(specJSON, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+
+	var buf bytes.Buffer
+
+	for _, name := range sortedKeys(s.Components.Schemas) {
+		if handwritten[name] {
+			continue
+		}
+		if err := writeType(&buf, name, s.Components.Schemas[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	type method struct {
+		path, method string
+		op           operation
+	}
+	var methods []method
+	for path, ops := range s.Paths {
+		for m, op := range ops {
+			methods = append(methods, method{path, m, op})
+		}
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].op.OperationID < methods[j].op.OperationID })
+
+	for _, m := range methods {
+		if err := writeMethod(&buf, m.path, m.method, m.op); err != nil {
+			return nil, err
+		}
+	}
+
+	// Only import what the declarations use
+	imports := []string{"context", "net/http"}
+	if bytes.Contains(buf.Bytes(), []byte("url.PathEscape(")) {
+		imports = append(imports, "net/url")
+	}
+	if bytes.Contains(buf.Bytes(), []byte("time.Time")) {
+		imports = append(imports, "time")
+	}
+
+	var file bytes.Buffer
+	file.WriteString("// Code generated by ranxapi-gen from api/openapi.json. DO NOT EDIT.\n\n")
+	file.WriteString("package ranxapi\n\nimport (\n")
+	for _, imp := range imports {
+		fmt.Fprintf(&file, "%q\n", imp)
+	}
+	file.WriteString(")\n\n")
+	file.Write(buf.Bytes())
+
+	src, err := format.Source(file.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated code: %w", err)
+	}
+	return src, nil
+}
+
+func writeType(buf *bytes.Buffer, name string, sc schema) error {
+	if sc.Type != "object" {
+		return fmt.Errorf("schema %s: only objects are supported", name)
+	}
+
+	fmt.Fprintf(buf, "// %s is the %s schema of the API\n", name, name)
+	fmt.Fprintf(buf, "type %s struct {\n", name)
+	for _, prop := range sortedKeys(sc.Properties) {
+		goType, err := goTypeOf(sc.Properties[prop])
+		if err != nil {
+			return fmt.Errorf("schema %s property %s: %w", name, prop, err)
+		}
+		tag := prop
+		if !slices.Contains(sc.Required, prop) {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(buf, "%s %s `json:%q`\n", goName(prop), goType, tag)
+	}
+	buf.WriteString("}\n\n")
+	return nil
+}
+
+func goTypeOf(sc schema) (string, error) {
+	if sc.Ref != "" {
+		return refName(sc.Ref), nil
+	}
+	if len(sc.AllOf) == 1 {
+		t, err := goTypeOf(sc.AllOf[0])
+		if err != nil {
+			return "", err
+		}
+		if sc.Nullable {
+			return "*" + t, nil
+		}
+		return t, nil
+	}
+
+	var t string
+	switch sc.Type {
+	case "string":
+		t = "string"
+		if sc.Format == "date-time" {
+			t = "time.Time"
+		}
+	case "boolean":
+		t = "bool"
+	case "integer":
+		t = "int64"
+		if sc.Format == "int32" {
+			t = "int32"
+		}
+	case "array":
+		if sc.Items == nil {
+			return "", fmt.Errorf("array without items")
+		}
+		item, err := goTypeOf(*sc.Items)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + item, nil
+	case "object":
+		if sc.Properties != nil {
+			return "", fmt.Errorf("inline objects are not supported")
+		}
+		var values schema
+		if err := json.Unmarshal(sc.AdditionalProperties, &values); err != nil {
+			// additionalProperties: true
+			return "map[string]any", nil
+		}
+		elem, err := goTypeOf(values)
+		if err != nil {
+			return "", err
+		}
+		return "map[string]" + elem, nil
+	default:
+		return "", fmt.Errorf("unsupported type %q", sc.Type)
+	}
+	if sc.Nullable {
+		return "*" + t, nil
+	}
+	return t, nil
+}
+
+func writeMethod(buf *bytes.Buffer, path, method string, op operation) error {
+	if op.OperationID == "" {
+		return fmt.Errorf("%s %s has no operationId", method, path)
+	}
+
+	args := []string{"ctx context.Context"}
+	for _, p := range op.Parameters {
+		switch {
+		case p.In == "path":
+			args = append(args, p.Name+" string")
+		case p.In == "header" && p.Name == "Idempotency-Key":
+			// Sent by Client.do from the context, see WithIdempotencyKey
+		default:
+			return fmt.Errorf("%s: unsupported %s parameter %s", op.OperationID, p.In, p.Name)
+		}
+	}
+
+	bodyArg := "nil"
+	if op.RequestBody != nil {
+		t, err := goTypeOf(op.RequestBody.Content["application/json"].Schema)
+		if err != nil {
+			return fmt.Errorf("%s request body: %w", op.OperationID, err)
+		}
+		args = append(args, "body "+t)
+		bodyArg = "body"
+	}
+
+	// The success response is the single 2xx entry
+	var respType string
+	for _, status := range sortedKeys(op.Responses) {
+		code, err := strconv.Atoi(status)
+		if err != nil || code < 200 || code > 299 {
+			continue
+		}
+		if content, ok := op.Responses[status].Content["application/json"]; ok {
+			if respType, err = goTypeOf(content.Schema); err != nil {
+				return fmt.Errorf("%s response: %w", op.OperationID, err)
+			}
+		}
+	}
+
+	fmt.Fprintf(buf, "// %s calls %s %s\n", op.OperationID, strings.ToUpper(method), path)
+	if op.Summary != "" {
+		fmt.Fprintf(buf, "//\n// %s\n", op.Summary)
+	}
+
+	httpMethod := "http.Method" + strings.ToUpper(method[:1]) + strings.ToLower(method[1:])
+	if respType == "" {
+		fmt.Fprintf(buf, "func (c *Client) %s(%s) error {\n", op.OperationID, strings.Join(args, ", "))
+		fmt.Fprintf(buf, "return c.do(ctx, %s, %s, %s, nil)\n}\n\n", httpMethod, pathExpr(path), bodyArg)
+		return nil
+	}
+	fmt.Fprintf(buf, "func (c *Client) %s(%s) (*%s, error) {\n", op.OperationID, strings.Join(args, ", "), respType)
+	fmt.Fprintf(buf, "var out %s\n", respType)
+	fmt.Fprintf(buf, "if err := c.do(ctx, %s, %s, %s, &out); err != nil {\nreturn nil, err\n}\n", httpMethod, pathExpr(path), bodyArg)
+	buf.WriteString("return &out, nil\n}\n\n")
+	return nil
+}
+
+// pathExpr returns a Go expression building path with its {name} segments escaped
+func pathExpr(path string) string {
+	var parts []string
+	var literal strings.Builder
+	for i, segment := range strings.Split(path, "/") {
+		if i > 0 {
+			literal.WriteString("/")
+		}
+		if name, ok := strings.CutPrefix(segment, "{"); ok {
+			parts = append(parts, strconv.Quote(literal.String()))
+			literal.Reset()
+			parts = append(parts, "url.PathEscape("+strings.TrimSuffix(name, "}")+")")
+			continue
+		}
+		literal.WriteString(segment)
+	}
+	if literal.Len() > 0 {
+		parts = append(parts, strconv.Quote(literal.String()))
+	}
+	return strings.Join(parts, " + ")
+}
+
+func refName(ref string) string {
+	return strings.TrimPrefix(ref, "#/components/schemas/")
+}
+
+// goName converts a snake_case JSON name to an exported Go name
+func goName(s string) string {
+	var b strings.Builder
+	for _, word := range strings.Split(s, "_") {
+		if word == "" {
+			continue
+		}
+		if initialisms[word] {
+			b.WriteString(strings.ToUpper(word))
+			continue
+		}
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return b.String()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}